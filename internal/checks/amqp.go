@@ -0,0 +1,295 @@
+package checks
+
+import (
+	"crypto/tls"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Default AMQP ports, without and with TLS
+const (
+	DefaultAMQPPort  = 5672
+	DefaultAMQPSPort = 5671
+)
+
+// AMQP 0-9-1 framing and the connection class methods a check uses
+const (
+	amqpFrameMethod    = 1
+	amqpFrameHeartbeat = 8
+	amqpFrameEnd       = 0xce
+	amqpMaxFrame       = 1 << 20
+
+	amqpConnection = 10
+	amqpStart      = 10
+	amqpStartOK    = 11
+	amqpTune       = 30
+	amqpTuneOK     = 31
+	amqpOpen       = 40
+	amqpOpenOK     = 41
+	amqpClose      = 50
+	amqpCloseOK    = 51
+)
+
+var amqpHeader = []byte("AMQP\x00\x00\x09\x01")
+
+// AMQPOptions says how an amqp check connects to the broker and whether it
+// logs in. Without a user it only checks the broker starts the handshake.
+type AMQPOptions struct {
+	VHost              string // Virtual host to open; empty means "/"
+	User               string
+	Password           string
+	Port               int  // 0 means DefaultAMQPPort, or DefaultAMQPSPort with TLS
+	TLS                bool // Connect with TLS (amqps)
+	InsecureSkipVerify bool // Accept a self-signed certificate, with TLS
+}
+
+type AMQPResult struct {
+	Latency time.Duration
+	Product string // The broker's name and version, e.g. "RabbitMQ 3.13.1"
+	Addr    string
+	TLS     *TLSInfo
+	OK      bool
+	Err     error
+}
+
+// AMQPConnect starts an AMQP 0-9-1 connection to the broker on host, as
+// RabbitMQ clients do. With opts.User it logs in with PLAIN and opens the
+// virtual host, so a wrong password or a missing vhost fails the check;
+// without one it stops once the broker has offered its login mechanisms.
+func AMQPConnect(host string, timeout time.Duration, opts AMQPOptions) AMQPResult {
+	port := opts.Port
+	if port == 0 {
+		port = DefaultAMQPPort
+		if opts.TLS {
+			port = DefaultAMQPSPort
+		}
+	}
+	addr := net.JoinHostPort(host, strconv.Itoa(port))
+	start := time.Now()
+	var conn net.Conn
+	var err error
+	if opts.TLS {
+		dialer := &net.Dialer{Timeout: timeout}
+		conn, err = tls.DialWithDialer(dialer, "tcp", addr, &tls.Config{ServerName: host, InsecureSkipVerify: opts.InsecureSkipVerify})
+	} else {
+		conn, err = net.DialTimeout("tcp", addr, timeout)
+	}
+	if err != nil {
+		return AMQPResult{Addr: dialedIP(err), Err: err}
+	}
+	defer conn.Close()
+	_ = conn.SetDeadline(start.Add(timeout))
+	res := AMQPResult{Addr: remoteIP(conn.RemoteAddr())}
+	if tc, ok := conn.(*tls.Conn); ok {
+		cs := tc.ConnectionState()
+		res.TLS = newTLSInfo(&cs, host, opts.InsecureSkipVerify)
+	}
+	c := &amqpConn{rw: conn}
+	res.Err = c.handshake(opts, &res)
+	res.Latency = time.Since(start)
+	res.OK = res.Err == nil
+	return res
+}
+
+// amqpConn reads and writes method frames on channel 0
+type amqpConn struct {
+	rw io.ReadWriter
+}
+
+func (c *amqpConn) handshake(opts AMQPOptions, res *AMQPResult) error {
+	if _, err := c.rw.Write(amqpHeader); err != nil {
+		return err
+	}
+	args, err := c.expect(amqpStart)
+	if err != nil {
+		return err
+	}
+	if len(args) < 2 {
+		return errors.New("amqp: malformed connection.start")
+	}
+	d := wireDecoder{b: args[2:]}
+	props := amqpTable(d.next(int(d.int32())))
+	mechanisms := string(d.next(int(d.int32())))
+	if d.bad {
+		return errors.New("amqp: malformed connection.start")
+	}
+	res.Product = strings.TrimSpace(props["product"] + " " + props["version"])
+	if opts.User == "" {
+		return nil
+	}
+	if !strings.Contains(" "+mechanisms+" ", " PLAIN ") {
+		return fmt.Errorf("broker doesn't accept PLAIN logins (it offers %s)", mechanisms)
+	}
+
+	var b []byte
+	b = amqpLongString(b, amqpClientProperties)
+	b = amqpShortString(b, "PLAIN")
+	b = amqpLongString(b, "\x00"+opts.User+"\x00"+opts.Password)
+	b = amqpShortString(b, "en_US")
+	if err := c.send(amqpStartOK, b); err != nil {
+		return err
+	}
+	args, err = c.expect(amqpTune)
+	if errors.Is(err, io.EOF) {
+		return errors.New("login refused")
+	}
+	if err != nil {
+		return err
+	}
+	if len(args) < 8 {
+		return errors.New("amqp: malformed connection.tune")
+	}
+	if binary.BigEndian.Uint32(args[2:]) == 0 {
+		binary.BigEndian.PutUint32(args[2:], amqpMaxFrame)
+	}
+	binary.BigEndian.PutUint16(args[6:], 0) // No heartbeats
+	if err := c.send(amqpTuneOK, args[:8]); err != nil {
+		return err
+	}
+
+	vhost := opts.VHost
+	if vhost == "" {
+		vhost = "/"
+	}
+	b = amqpShortString(nil, vhost)
+	b = append(b, 0, 0) // Reserved capabilities and insist
+	if err := c.send(amqpOpen, b); err != nil {
+		return err
+	}
+	if _, err := c.expect(amqpOpenOK); err != nil {
+		return err
+	}
+
+	b = binary.BigEndian.AppendUint16(nil, 200)
+	b = amqpShortString(b, "")
+	b = append(b, 0, 0, 0, 0) // Class and method causing the close: none
+	if err := c.send(amqpClose, b); err == nil {
+		_, _ = c.expect(amqpCloseOK)
+	}
+	return nil
+}
+
+// amqpClientProperties tells the broker who is connecting and asks it to
+// say why a login failed rather than just close the connection
+var amqpClientProperties = func() string {
+	caps := amqpShortString(nil, "authentication_failure_close")
+	caps = append(caps, 't', 1)
+	var b []byte
+	b = amqpShortString(b, "product")
+	b = append(b, 'S')
+	b = amqpLongString(b, DefaultUserAgent)
+	b = amqpShortString(b, "capabilities")
+	b = append(b, 'F')
+	b = amqpLongString(b, string(caps))
+	return string(b)
+}()
+
+// send writes a connection class method frame
+func (c *amqpConn) send(method uint16, args []byte) error {
+	b := []byte{amqpFrameMethod, 0, 0}
+	b = binary.BigEndian.AppendUint32(b, uint32(4+len(args)))
+	b = binary.BigEndian.AppendUint16(b, amqpConnection)
+	b = binary.BigEndian.AppendUint16(b, method)
+	b = append(b, args...)
+	_, err := c.rw.Write(append(b, amqpFrameEnd))
+	return err
+}
+
+// expect reads frames until a connection method arrives and returns its
+// arguments. A connection.close from the broker is returned as an error
+// with its reply code and text, e.g. "403 ACCESS_REFUSED - Login was
+// refused".
+func (c *amqpConn) expect(method uint16) ([]byte, error) {
+	for {
+		var h [7]byte
+		if _, err := io.ReadFull(c.rw, h[:]); err != nil {
+			return nil, err
+		}
+		if string(h[:4]) == "AMQP" {
+			var v [1]byte
+			_, _ = io.ReadFull(c.rw, v[:])
+			return nil, fmt.Errorf("broker wants AMQP %d.%d.%d, not 0-9-1", h[5], h[6], v[0])
+		}
+		n := binary.BigEndian.Uint32(h[3:])
+		if n > amqpMaxFrame {
+			return nil, errors.New("amqp: frame too large")
+		}
+		payload := make([]byte, n+1)
+		if _, err := io.ReadFull(c.rw, payload); err != nil {
+			return nil, err
+		}
+		if payload[n] != amqpFrameEnd {
+			return nil, errors.New("amqp: not an AMQP broker")
+		}
+		if h[0] == amqpFrameHeartbeat {
+			continue
+		}
+		if h[0] != amqpFrameMethod || n < 4 || binary.BigEndian.Uint16(payload) != amqpConnection {
+			return nil, errors.New("amqp: unexpected frame")
+		}
+		got, args := binary.BigEndian.Uint16(payload[2:]), payload[4:n]
+		switch got {
+		case method:
+			return args, nil
+		case amqpClose:
+			_ = c.send(amqpCloseOK, nil)
+			d := wireDecoder{b: args}
+			code := d.int16()
+			text := string(d.next(d.uint8()))
+			if d.bad {
+				return nil, errors.New("broker closed the connection")
+			}
+			return nil, fmt.Errorf("%d %s", code, text)
+		}
+		return nil, fmt.Errorf("amqp: unexpected method %d.%d", amqpConnection, got)
+	}
+}
+
+func amqpShortString(b []byte, s string) []byte {
+	return append(append(b, byte(len(s))), s...)
+}
+
+func amqpLongString(b []byte, s string) []byte {
+	b = binary.BigEndian.AppendUint32(b, uint32(len(s)))
+	return append(b, s...)
+}
+
+// amqpTable returns the string fields of a field table, stopping at the
+// first value it can't size
+func amqpTable(b []byte) map[string]string {
+	fields := map[string]string{}
+	d := wireDecoder{b: b}
+	for len(d.b) > 0 && !d.bad {
+		name := string(d.next(d.uint8()))
+		var n int
+		switch d.int8() {
+		case 'S', 'x', 'F', 'A':
+			n = int(d.int32())
+			if v := d.next(n); !d.bad {
+				fields[name] = string(v)
+			}
+			continue
+		case 't', 'b', 'B':
+			n = 1
+		case 's', 'u':
+			n = 2
+		case 'I', 'i', 'f':
+			n = 4
+		case 'D':
+			n = 5
+		case 'l', 'L', 'd', 'T':
+			n = 8
+		case 'V':
+		default:
+			return fields
+		}
+		d.next(n)
+	}
+	return fields
+}
@@ -0,0 +1,127 @@
+package checks
+
+import "time"
+
+// Checker runs the probes used by the scheduler. State depends on this rather
+// than the package functions so tests and demos can substitute a Fake.
+type Checker interface {
+	Ping(host string, timeout time.Duration, opts PingOptions) PingResult
+	HTTP(url string, timeout time.Duration, opts HTTPOptions) HTTPResult
+	TCP(host string, port int, timeout time.Duration, opts DialOptions) TCPResult
+	SSH(host string, timeout time.Duration, opts SSHOptions) SSHResult
+	WebSocket(url string, timeout time.Duration, opts WebSocketOptions) WebSocketResult
+	File(host string, timeout time.Duration, opts FileOptions) FileResult
+	S3(endpoint string, timeout time.Duration, opts S3Options) S3Result
+	IPP(url string, timeout time.Duration, opts IPPOptions) IPPResult
+	SMB(host string, timeout time.Duration, opts SMBOptions) SMBResult
+	Kafka(host string, timeout time.Duration, opts KafkaOptions) KafkaResult
+	AMQP(host string, timeout time.Duration, opts AMQPOptions) AMQPResult
+	Kubernetes(url string, timeout time.Duration, opts KubernetesOptions) KubernetesResult
+	Proxmox(host string, timeout time.Duration, opts ProxmoxOptions) ProxmoxResult
+	ESXi(host string, timeout time.Duration, opts ESXiOptions) ESXiResult
+	UPS(host string, timeout time.Duration, opts UPSOptions) UPSResult
+	Speedtest(host string, timeout time.Duration, opts SpeedtestOptions) SpeedtestResult
+	PublicIP(url string, timeout time.Duration, opts PublicIPOptions) PublicIPResult
+	Domain(domain string, timeout time.Duration, opts DomainOptions) DomainResult
+	DNSBL(host string, timeout time.Duration, opts DNSBLOptions) DNSBLResult
+}
+
+// Network is the Checker that probes real hosts
+type Network struct{}
+
+// Ping checks reachability via PingOnce
+func (Network) Ping(host string, timeout time.Duration, opts PingOptions) PingResult {
+	return PingOnce(host, timeout, opts)
+}
+
+// HTTP issues a GET via HTTPGet
+func (Network) HTTP(url string, timeout time.Duration, opts HTTPOptions) HTTPResult {
+	return HTTPGet(url, timeout, opts)
+}
+
+// TCP opens a connection via TCPCheck
+func (Network) TCP(host string, port int, timeout time.Duration, opts DialOptions) TCPResult {
+	return TCPCheck(host, port, timeout, opts)
+}
+
+// SSH runs a remote command via SSHRun
+func (Network) SSH(host string, timeout time.Duration, opts SSHOptions) SSHResult {
+	return SSHRun(host, timeout, opts)
+}
+
+// WebSocket performs a handshake via WebSocketProbe
+func (Network) WebSocket(url string, timeout time.Duration, opts WebSocketOptions) WebSocketResult {
+	return WebSocketProbe(url, timeout, opts)
+}
+
+// File finds a file's age via FileAge
+func (Network) File(host string, timeout time.Duration, opts FileOptions) FileResult {
+	return FileAge(host, timeout, opts)
+}
+
+// S3 checks a bucket or object via S3Head
+func (Network) S3(endpoint string, timeout time.Duration, opts S3Options) S3Result {
+	return S3Head(endpoint, timeout, opts)
+}
+
+// IPP asks a printer for its state via IPPStatus
+func (Network) IPP(url string, timeout time.Duration, opts IPPOptions) IPPResult {
+	return IPPStatus(url, timeout, opts)
+}
+
+// SMB connects to a file share via SMBConnect
+func (Network) SMB(host string, timeout time.Duration, opts SMBOptions) SMBResult {
+	return SMBConnect(host, timeout, opts)
+}
+
+// Kafka asks a Kafka broker for cluster metadata via KafkaMetadata
+func (Network) Kafka(host string, timeout time.Duration, opts KafkaOptions) KafkaResult {
+	return KafkaMetadata(host, timeout, opts)
+}
+
+// AMQP connects to a message broker via AMQPConnect
+func (Network) AMQP(host string, timeout time.Duration, opts AMQPOptions) AMQPResult {
+	return AMQPConnect(host, timeout, opts)
+}
+
+// Kubernetes asks a cluster's API server about its nodes or a deployment
+// via KubernetesStatus
+func (Network) Kubernetes(url string, timeout time.Duration, opts KubernetesOptions) KubernetesResult {
+	return KubernetesStatus(url, timeout, opts)
+}
+
+// Proxmox asks a Proxmox VE cluster about its nodes, guests and storage
+// via ProxmoxStatus
+func (Network) Proxmox(host string, timeout time.Duration, opts ProxmoxOptions) ProxmoxResult {
+	return ProxmoxStatus(host, timeout, opts)
+}
+
+// ESXi asks an ESXi host about its health and VMs via ESXiStatus
+func (Network) ESXi(host string, timeout time.Duration, opts ESXiOptions) ESXiResult {
+	return ESXiStatus(host, timeout, opts)
+}
+
+// UPS asks a NUT or apcupsd daemon about its UPS via UPSStatus
+func (Network) UPS(host string, timeout time.Duration, opts UPSOptions) UPSResult {
+	return UPSStatus(host, timeout, opts)
+}
+
+// Speedtest measures bandwidth to host, or from a download, via Speedtest
+func (Network) Speedtest(host string, timeout time.Duration, opts SpeedtestOptions) SpeedtestResult {
+	return Speedtest(host, timeout, opts)
+}
+
+// PublicIP looks up the monitor's public address via PublicIP
+func (Network) PublicIP(url string, timeout time.Duration, opts PublicIPOptions) PublicIPResult {
+	return PublicIP(url, timeout, opts)
+}
+
+// Domain asks a domain's registry when it expires via DomainExpiry
+func (Network) Domain(domain string, timeout time.Duration, opts DomainOptions) DomainResult {
+	return DomainExpiry(domain, timeout, opts)
+}
+
+// DNSBL looks a host's addresses up on blocklists via DNSBL
+func (Network) DNSBL(host string, timeout time.Duration, opts DNSBLOptions) DNSBLResult {
+	return DNSBL(host, timeout, opts)
+}
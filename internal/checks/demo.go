@@ -0,0 +1,371 @@
+package checks
+
+import (
+	"fmt"
+	"hash/fnv"
+	"math/rand/v2"
+	"net/http"
+	"path"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	demoOutageChance      = 0.002 // Per-probe chance a healthy target starts an outage
+	demoFlakyOutageChance = 0.02  // Same, for targets marked flaky
+	demoMinOutage         = 3     // Outage length in probes
+	demoMaxOutage         = 8
+)
+
+// Demo is a Checker that synthesizes plausible latency and occasional
+// outages without touching the network, for screenshots and UI work
+type Demo struct {
+	mu      sync.Mutex
+	rng     *rand.Rand
+	targets map[string]*demoTarget
+	flaky   map[string]bool
+	lease   int // Simulated public address, renumbered after each outage
+}
+
+type demoTarget struct {
+	base       float64 // Typical latency in ms
+	current    float64 // Random-walk latency in ms
+	outageLeft int     // Remaining failed probes in the current outage
+}
+
+// NewDemo returns a Demo seeded so runs are repeatable for a given seed
+func NewDemo(seed uint64) *Demo {
+	return &Demo{
+		rng:     rand.New(rand.NewPCG(seed, seed^0x9e3779b97f4a7c15)),
+		targets: make(map[string]*demoTarget),
+		flaky:   make(map[string]bool),
+	}
+}
+
+// SetFlaky makes outages much more frequent for a host address or URL
+func (d *Demo) SetFlaky(target string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.flaky[target] = true
+}
+
+// Ping implements Checker
+func (d *Demo) Ping(host string, timeout time.Duration, opts PingOptions) PingResult {
+	lat, up := d.next("ping "+host, host, 2, 40)
+	if !up {
+		return PingResult{OK: false, PacketsTx: opts.count(), Err: fmt.Errorf("request timeout for icmp_seq 0"), Method: opts.reportedMethod()}
+	}
+	return pingResult(opts.count(), d.spread(lat, opts.count()), opts.reportedMethod(), "")
+}
+
+// spread makes n round trip times scattered around lat, as a run of
+// several echo requests would see
+func (d *Demo) spread(lat time.Duration, n int) []time.Duration {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	rtts := make([]time.Duration, n)
+	for i := range rtts {
+		rtts[i] = lat
+		if n > 1 {
+			rtts[i] += time.Duration((d.rng.Float64() - 0.5) * 0.3 * float64(lat))
+		}
+	}
+	return rtts
+}
+
+// HTTP implements Checker
+func (d *Demo) HTTP(url string, timeout time.Duration, opts HTTPOptions) HTTPResult {
+	lat, up := d.next("http "+url, url, 40, 400)
+	if !up {
+		return HTTPResult{
+			Latency: lat,
+			Code:    503,
+			Status:  "503 Service Unavailable",
+			Header:  http.Header{"Content-Type": {"text/html"}, "Retry-After": {"30"}},
+			Body:    "<html><body><h1>503 Service Unavailable</h1>Demo outage</body></html>",
+		}
+	}
+	return HTTPResult{Latency: lat, Code: 200, Status: "200 OK", Timing: demoTiming(url, lat), Size: demoSize(url), Download: lat / 10}
+}
+
+// demoSize gives each demo URL its own typical page size
+func demoSize(url string) int64 {
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(url))
+	return 2048 + int64(h.Sum32()%200000)
+}
+
+// demoTiming splits a demo HTTP latency into phases in typical proportions
+func demoTiming(url string, lat time.Duration) HTTPTiming {
+	t := HTTPTiming{DNS: lat / 20, Connect: lat / 8}
+	if strings.HasPrefix(url, "https:") {
+		t.TLS = lat / 4
+	}
+	t.TTFB = lat - t.Total() - lat/20
+	return t
+}
+
+// TCP implements Checker
+func (d *Demo) TCP(host string, port int, timeout time.Duration, opts DialOptions) TCPResult {
+	lat, up := d.next(fmt.Sprintf("tcp %s:%d", host, port), host, 1, 30)
+	if !up {
+		return TCPResult{OK: false, Err: fmt.Errorf("dial tcp %s:%d: connect: connection refused", host, port)}
+	}
+	return TCPResult{OK: true, Latency: lat}
+}
+
+// SSH implements Checker
+func (d *Demo) SSH(host string, timeout time.Duration, opts SSHOptions) SSHResult {
+	lat, up := d.next("ssh "+host+" "+opts.Command, host, 80, 600)
+	if !up {
+		return SSHResult{Latency: lat, ExitCode: -1, Err: fmt.Errorf("ssh: connect to host %s port %d: Connection timed out", host, DefaultSSHPort)}
+	}
+	return SSHResult{Latency: lat, ExitCode: opts.ExpectExit, OK: true}
+}
+
+// WebSocket implements Checker
+func (d *Demo) WebSocket(url string, timeout time.Duration, opts WebSocketOptions) WebSocketResult {
+	lat, up := d.next("websocket "+url, url, 30, 300)
+	if !up {
+		return WebSocketResult{Latency: lat, Code: 502, Err: fmt.Errorf("handshake refused: 502 Bad Gateway")}
+	}
+	return WebSocketResult{Latency: lat, Code: 101, OK: true}
+}
+
+// File implements Checker. Files are a few hours old, and stale during an
+// outage, as if a nightly job had stopped running.
+func (d *Demo) File(host string, timeout time.Duration, opts FileOptions) FileResult {
+	lat, up := d.next("file "+host+" "+opts.Path, host, 1, 40)
+	age := time.Duration(lat.Milliseconds()%12+1) * time.Hour
+	if !up {
+		age = 3 * 24 * time.Hour
+	}
+	res := FileResult{Latency: lat, Name: path.Base(opts.Path), ModTime: time.Now().Add(-age)}
+	res.OK = opts.MaxAge <= 0 || age <= opts.MaxAge
+	return res
+}
+
+// S3 implements Checker. Objects were written a few hours ago.
+func (d *Demo) S3(endpoint string, timeout time.Duration, opts S3Options) S3Result {
+	lat, up := d.next("s3 "+endpoint+" "+opts.Bucket+" "+opts.Object, endpoint, 20, 200)
+	if !up {
+		return S3Result{Latency: lat, Code: 503, Err: fmt.Errorf("status 503 Service Unavailable")}
+	}
+	res := S3Result{Latency: lat, Code: 200, OK: true}
+	if opts.Object != "" {
+		res.Size = 1<<30 + int64(lat.Microseconds())*4096
+		res.LastModified = time.Now().Add(-time.Duration(lat.Milliseconds()%12+1) * time.Hour)
+	}
+	return res
+}
+
+// IPP implements Checker. Printers sit idle with their toner running down.
+func (d *Demo) IPP(url string, timeout time.Duration, opts IPPOptions) IPPResult {
+	lat, up := d.next("ipp "+url, url, 5, 60)
+	res := IPPResult{Latency: lat, State: "idle", Marker: "Black Toner", Level: int(lat.Microseconds() % 90)}
+	if !up {
+		res.State, res.Reasons, res.Message = "stopped", []string{"media-jam-error"}, "Paper jam"
+		return res
+	}
+	if res.Level < 15 {
+		res.Reasons = []string{"toner-low-warning"}
+	}
+	res.OK = true
+	return res
+}
+
+// SMB implements Checker
+func (d *Demo) SMB(host string, timeout time.Duration, opts SMBOptions) SMBResult {
+	lat, up := d.next("smb "+host+" "+opts.Share, host, 3, 30)
+	if !up {
+		return SMBResult{Latency: lat, Err: fmt.Errorf("dial tcp %s:445: connect: connection refused", host)}
+	}
+	return SMBResult{Latency: lat, Dialect: "3.0.2", OK: true}
+}
+
+// Kafka implements Checker. Now and then a broker restart leaves a
+// partition under-replicated.
+func (d *Demo) Kafka(host string, timeout time.Duration, opts KafkaOptions) KafkaResult {
+	lat, up := d.next("kafka "+host+" "+opts.Topic, host, 2, 25)
+	res := KafkaResult{Latency: lat, Brokers: 3, ClusterID: "demo-cluster", Topics: 12, Partitions: 36}
+	if opts.Topic != "" {
+		res.Topics, res.Partitions = 1, 6
+	}
+	if !up {
+		res.Leaderless = 2
+		res.Err = fmt.Errorf("%d of %d partitions have no leader", res.Leaderless, res.Partitions)
+		return res
+	}
+	if lat.Microseconds()%7 == 0 {
+		res.UnderReplicated = 1
+	}
+	res.OK = true
+	return res
+}
+
+// AMQP implements Checker
+func (d *Demo) AMQP(host string, timeout time.Duration, opts AMQPOptions) AMQPResult {
+	lat, up := d.next("amqp "+host+" "+opts.VHost, host, 2, 20)
+	if !up {
+		return AMQPResult{Latency: lat, Err: fmt.Errorf("dial tcp %s:%d: connect: connection refused", host, DefaultAMQPPort)}
+	}
+	return AMQPResult{Latency: lat, Product: "RabbitMQ 3.13.7", OK: true}
+}
+
+// Kubernetes implements Checker. A node drops out now and then, and a
+// deployment loses a replica while it is rescheduled.
+func (d *Demo) Kubernetes(url string, timeout time.Duration, opts KubernetesOptions) KubernetesResult {
+	lat, up := d.next("kubernetes "+url+" "+opts.Deployment, urlTarget(url), 5, 40)
+	if opts.Deployment != "" {
+		res := KubernetesResult{Latency: lat, Replicas: 3, Available: 3, OK: up}
+		if !up {
+			res.Available = 2
+		}
+		return res
+	}
+	res := KubernetesResult{Latency: lat, Nodes: 3, Ready: 3, OK: up}
+	if !up {
+		res.Ready, res.NotReady = 2, []string{"node-2"}
+	}
+	return res
+}
+
+// Proxmox implements Checker. A node goes offline now and then.
+func (d *Demo) Proxmox(host string, timeout time.Duration, opts ProxmoxOptions) ProxmoxResult {
+	lat, up := d.next("proxmox "+host, host, 20, 120)
+	res := ProxmoxResult{Latency: lat, Nodes: 3, Online: 3, Guests: 14, Running: 12, OK: up}
+	if !up {
+		res.Online, res.Offline = 2, []string{"pve3"}
+	}
+	if opts.VM != "" {
+		res.VM, res.VMState = "101 ("+opts.VM+") on pve1", "running"
+	}
+	return res
+}
+
+// ESXi implements Checker. The host goes red now and then, as when a power
+// supply fails.
+func (d *Demo) ESXi(host string, timeout time.Duration, opts ESXiOptions) ESXiResult {
+	lat, up := d.next("esxi "+host, host, 60, 300)
+	res := ESXiResult{Latency: lat, Product: "VMware ESXi 8.0.2 build-22380479", Status: "green", VMs: 8, Running: 6, OK: up}
+	if !up {
+		res.Status, res.Alarms = "red", []string{"Power Supply 2 red"}
+	}
+	if opts.VM != "" {
+		res.VMState = "poweredOn"
+	}
+	return res
+}
+
+// UPS implements Checker. The power goes out now and then, putting the UPS
+// on battery.
+func (d *Demo) UPS(host string, timeout time.Duration, opts UPSOptions) UPSResult {
+	lat, up := d.next("ups "+host, host, 1, 10)
+	res := UPSResult{Latency: lat, Model: "APC Back-UPS XS 700U", Status: []string{"online"}, Charge: 100, Runtime: 38 * time.Minute, Load: 21, OK: up}
+	if !up {
+		res.Status, res.Alarms = []string{"on battery", "discharging"}, []string{"on battery"}
+		res.Charge, res.Runtime = 64, 19*time.Minute
+	}
+	return res
+}
+
+// Speedtest implements Checker. The line slows to a crawl now and then, as
+// when the ISP has trouble.
+func (d *Demo) Speedtest(host string, timeout time.Duration, opts SpeedtestOptions) SpeedtestResult {
+	lat, up := d.next("speedtest "+host, host, 15, 25)
+	speed := int64(4_000_000)
+	if up {
+		// Slower as the simulated latency drifts up
+		speed = int64(500_000_000 * 20 * float64(time.Millisecond) / float64(lat))
+	}
+	return SpeedtestResult{Latency: lat, Speed: speed, Bytes: speed / 8 * 10, Duration: SpeedtestDuration, OK: up && speed >= opts.MinSpeed}
+}
+
+// PublicIP implements Checker. The ISP hands out a new address after each
+// outage, as dynamic ones often do.
+func (d *Demo) PublicIP(url string, timeout time.Duration, opts PublicIPOptions) PublicIPResult {
+	lat, up := d.next("publicip "+url, url, 30, 120)
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if !up {
+		d.lease++
+		return PublicIPResult{Err: fmt.Errorf("dial tcp: connect: network is unreachable")}
+	}
+	ip := fmt.Sprintf("203.0.113.%d", 10+d.lease%200)
+	if opts.IPVersion == 6 {
+		ip = fmt.Sprintf("2001:db8:%x::1", 0x10+d.lease)
+	}
+	return PublicIPResult{Latency: lat, IP: ip, OK: true}
+}
+
+// Domain implements Checker. Each domain gets a stable expiry some months
+// out, and the registry is unreachable now and then.
+func (d *Demo) Domain(domain string, timeout time.Duration, opts DomainOptions) DomainResult {
+	lat, up := d.next("domain "+domain, domain, 80, 400)
+	if !up {
+		return DomainResult{Domain: domain, Source: "rdap", Err: fmt.Errorf("rdap: status 503 Service Unavailable")}
+	}
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(domain))
+	expiry := time.Now().Add(time.Duration(20+h.Sum32()%400) * 24 * time.Hour).Truncate(24 * time.Hour)
+	return DomainResult{Latency: lat, Domain: domain, Expiry: expiry, Registrar: "Example Registrar, Inc.", Source: "rdap", OK: time.Until(expiry) > opts.Warn()}
+}
+
+// DNSBL implements Checker. A host is never listed, but while it is down
+// the last list times out.
+func (d *Demo) DNSBL(host string, timeout time.Duration, opts DNSBLOptions) DNSBLResult {
+	lat, up := d.next("dnsbl "+host, host, 20, 150)
+	lists := opts.Lists()
+	res := DNSBLResult{Latency: lat, IPs: []string{host}, Checked: len(lists), Addr: host, OK: true}
+	if !up {
+		last := lists[len(lists)-1]
+		res.Checked--
+		res.Failed = []string{last}
+		if res.Checked == 0 {
+			res.OK, res.Err = false, fmt.Errorf("%s: i/o timeout", last)
+		}
+	}
+	return res
+}
+
+// next advances the simulated state for key and returns its latency and
+// whether it is up. Each key gets a stable base latency in [minMS, maxMS)
+// derived from its name, then drifts around it.
+func (d *Demo) next(key, target string, minMS, maxMS float64) (time.Duration, bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	t, ok := d.targets[key]
+	if !ok {
+		h := fnv.New32a()
+		_, _ = h.Write([]byte(key))
+		base := minMS + float64(h.Sum32()%1000)/1000*(maxMS-minMS)
+		t = &demoTarget{base: base, current: base}
+		d.targets[key] = t
+	}
+
+	if t.outageLeft > 0 {
+		t.outageLeft--
+		return 0, false
+	}
+	chance := demoOutageChance
+	if d.flaky[target] {
+		chance = demoFlakyOutageChance
+	}
+	if d.rng.Float64() < chance {
+		t.outageLeft = demoMinOutage + d.rng.IntN(demoMaxOutage-demoMinOutage+1) - 1
+		return 0, false
+	}
+
+	// Mean-reverting random walk with the occasional spike
+	t.current += (t.base-t.current)*0.2 + d.rng.NormFloat64()*t.base*0.1
+	if t.current < t.base*0.3 {
+		t.current = t.base * 0.3
+	}
+	lat := t.current
+	if d.rng.Float64() < 0.03 {
+		lat *= 2 + d.rng.Float64()*3
+	}
+	return time.Duration(lat * float64(time.Millisecond)), true
+}
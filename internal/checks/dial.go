@@ -0,0 +1,152 @@
+package checks
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http/httptrace"
+	"sync"
+	"time"
+)
+
+// DialOptions picks the address family and local address a probe's
+// connections use. The zero value dials as the system would by default.
+type DialOptions struct {
+	IPVersion int    // 4 or 6 to force that family; 0 lets the resolver choose
+	Source    string // Local IP address or interface name to connect from
+}
+
+// network narrows a base network such as "tcp" to "tcp4" or "tcp6"
+func (o DialOptions) network(base string) string {
+	switch o.IPVersion {
+	case 4, 6:
+		return fmt.Sprintf("%s%d", base, o.IPVersion)
+	}
+	return base
+}
+
+// dialer returns a net.Dialer bound to o.Source. An interface name binds to
+// the first of its addresses in the requested family, which on a multi-homed
+// machine selects the route as long as source-based routing is configured.
+func (o DialOptions) dialer(timeout time.Duration) (*net.Dialer, error) {
+	d := &net.Dialer{Timeout: timeout}
+	if o.Source == "" {
+		return d, nil
+	}
+	ip, err := o.sourceIP()
+	if err != nil {
+		return nil, err
+	}
+	d.LocalAddr = &net.TCPAddr{IP: ip}
+	return d, nil
+}
+
+func (o DialOptions) sourceIP() (net.IP, error) {
+	if ip := net.ParseIP(o.Source); ip != nil {
+		if !o.familyMatches(ip) {
+			return nil, fmt.Errorf("source %s is not an IPv%d address", o.Source, o.IPVersion)
+		}
+		return ip, nil
+	}
+	ifi, err := net.InterfaceByName(o.Source)
+	if err != nil {
+		return nil, fmt.Errorf("source interface %q: %w", o.Source, err)
+	}
+	addrs, err := ifi.Addrs()
+	if err != nil {
+		return nil, fmt.Errorf("source interface %q: %w", o.Source, err)
+	}
+	var fallback net.IP
+	for _, a := range addrs {
+		ipNet, ok := a.(*net.IPNet)
+		if !ok || ipNet.IP.IsLinkLocalUnicast() || !o.familyMatches(ipNet.IP) {
+			continue
+		}
+		if o.IPVersion != 0 || ipNet.IP.To4() != nil {
+			return ipNet.IP, nil
+		}
+		if fallback == nil {
+			fallback = ipNet.IP // IPv6, used if the interface has no IPv4
+		}
+	}
+	if fallback != nil {
+		return fallback, nil
+	}
+	if o.IPVersion != 0 {
+		return nil, fmt.Errorf("source interface %q has no IPv%d address", o.Source, o.IPVersion)
+	}
+	return nil, fmt.Errorf("source interface %q has no usable address", o.Source)
+}
+
+// familyMatches reports whether ip is in the family o asks for, if any
+func (o DialOptions) familyMatches(ip net.IP) bool {
+	is4 := ip.To4() != nil
+	switch o.IPVersion {
+	case 4:
+		return is4
+	case 6:
+		return !is4
+	}
+	return true
+}
+
+// remoteIP returns the IP address at the far end of a connection
+func remoteIP(addr net.Addr) string {
+	switch a := addr.(type) {
+	case *net.TCPAddr:
+		return a.IP.String()
+	case *net.UDPAddr:
+		return a.IP.String()
+	case *net.IPAddr:
+		return a.IP.String()
+	case nil:
+		return ""
+	}
+	return hostOnly(addr.String())
+}
+
+// dialedIP returns the IP address a failed dial was trying, if it got as far
+// as resolving one
+func dialedIP(err error) string {
+	var op *net.OpError
+	if errors.As(err, &op) && op.Addr != nil {
+		return remoteIP(op.Addr)
+	}
+	return ""
+}
+
+func hostOnly(hostPort string) string {
+	if host, _, err := net.SplitHostPort(hostPort); err == nil {
+		return host
+	}
+	return hostPort
+}
+
+// tracedAddr notes the IP address a traced request connected to, or the
+// last one it tried if it never connected. Through a proxy, that is the
+// proxy's.
+type tracedAddr struct {
+	mu sync.Mutex
+	ip string
+}
+
+func (t *tracedAddr) context(ctx context.Context) context.Context {
+	return httptrace.WithClientTrace(ctx, &httptrace.ClientTrace{
+		// Dual-stack dials may start both families at once
+		ConnectStart: func(_, addr string) { t.set(hostOnly(addr)) },
+		GotConn:      func(info httptrace.GotConnInfo) { t.set(remoteIP(info.Conn.RemoteAddr())) },
+	})
+}
+
+func (t *tracedAddr) set(ip string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.ip = ip
+}
+
+func (t *tracedAddr) get() string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.ip
+}
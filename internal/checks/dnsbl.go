@@ -0,0 +1,196 @@
+package checks
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/netip"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DNSBLInterval is how often a dnsbl check looks its addresses up. Lists
+// are updated every few minutes, but free use is capped at a number of
+// queries a day, so it runs less often than other checks.
+const DNSBLInterval = time.Hour
+
+// DefaultBlocklists are the lists a dnsbl check looks addresses up on
+// unless configured otherwise: widely used ones that are free for
+// low-volume queries
+var DefaultBlocklists = []string{
+	"zen.spamhaus.org",
+	"bl.spamcop.net",
+	"b.barracudacentral.org",
+	"psbl.surriel.com",
+}
+
+// dnsblResolver looks up DNSBL zones; a variable so it can be pointed at
+// a test server
+var dnsblResolver = net.DefaultResolver
+
+// DNSBLOptions says which blocklists a dnsbl check looks the host up on
+type DNSBLOptions struct {
+	Blocklists []string // DNSBL zones, e.g. "zen.spamhaus.org"; empty means DefaultBlocklists
+}
+
+// DNSBLListing is an address found on a blocklist
+type DNSBLListing struct {
+	List   string // The blocklist's zone
+	IP     string // The address listed
+	Code   string // The list's answer, e.g. "127.0.0.2", which says why on most lists
+	Reason string // The list's TXT record, if it has one, often a link to delist
+}
+
+type DNSBLResult struct {
+	Latency  time.Duration
+	IPs      []string       // The host's addresses that were looked up
+	Listings []DNSBLListing // Empty when no list has any of them
+	Checked  int            // Lists that answered for every address
+	Failed   []string       // Lists that didn't answer, or refused the query
+	Addr     string         // The first address looked up
+	OK       bool           // Not listed anywhere
+	Err      error
+}
+
+// Lists returns the blocklists a dnsbl check looks up
+func (o DNSBLOptions) Lists() []string {
+	if len(o.Blocklists) == 0 {
+		return DefaultBlocklists
+	}
+	return o.Blocklists
+}
+
+// DNSBL looks up the addresses of host, such as a mail server, on the
+// blocklists in opts. An address is listed when a list's zone has an A
+// record for it, e.g. 2.0.0.127.zen.spamhaus.org for 127.0.0.2. The check
+// fails on any listing; a list that can't be asked is reported, but only
+// fails the check when no list answered.
+func DNSBL(host string, timeout time.Duration, opts DNSBLOptions) DNSBLResult {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	start := time.Now()
+	ips, err := dnsblAddrs(ctx, host)
+	if err != nil {
+		return DNSBLResult{Err: err}
+	}
+	res := DNSBLResult{Addr: ips[0].String()}
+	for _, ip := range ips {
+		res.IPs = append(res.IPs, ip.String())
+	}
+	lists := opts.Lists()
+	type answer struct {
+		listings []DNSBLListing
+		err      error
+	}
+	answers := make([]answer, len(lists))
+	var wg sync.WaitGroup
+	for i, list := range lists {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for _, ip := range ips {
+				l, err := dnsblLookup(ctx, list, ip)
+				if err != nil {
+					answers[i].err = err
+					return
+				}
+				if l != nil {
+					answers[i].listings = append(answers[i].listings, *l)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	res.Latency = time.Since(start)
+	var errs []error
+	for i, a := range answers {
+		res.Listings = append(res.Listings, a.listings...)
+		if a.err != nil {
+			res.Failed = append(res.Failed, lists[i])
+			errs = append(errs, a.err)
+		} else {
+			res.Checked++
+		}
+	}
+	if res.Checked == 0 && len(res.Listings) == 0 {
+		res.Err = errors.Join(errs...)
+		return res
+	}
+	res.OK = len(res.Listings) == 0
+	return res
+}
+
+// dnsblAddrs returns host's addresses, which is host itself when it is one
+func dnsblAddrs(ctx context.Context, host string) ([]netip.Addr, error) {
+	if ip, err := netip.ParseAddr(host); err == nil {
+		return []netip.Addr{ip.Unmap()}, nil
+	}
+	ips, err := dnsblResolver.LookupNetIP(ctx, "ip", host)
+	if err != nil {
+		return nil, err
+	}
+	if len(ips) == 0 {
+		return nil, fmt.Errorf("%s has no addresses", host)
+	}
+	for i := range ips {
+		ips[i] = ips[i].Unmap()
+	}
+	slices.SortFunc(ips, func(a, b netip.Addr) int { return a.Compare(b) })
+	return slices.Compact(ips), nil
+}
+
+// dnsblLookup asks list whether ip is on it, returning nil if not
+func dnsblLookup(ctx context.Context, list string, ip netip.Addr) (*DNSBLListing, error) {
+	name := dnsblName(ip, list)
+	codes, err := dnsblResolver.LookupHost(ctx, name)
+	if err != nil {
+		var dnsErr *net.DNSError
+		if errors.As(err, &dnsErr) && dnsErr.IsNotFound {
+			return nil, nil
+		}
+		return nil, err
+	}
+	code := ""
+	for _, c := range codes {
+		a, err := netip.ParseAddr(c)
+		if err != nil || !a.Is4() || a.As4()[0] != 127 {
+			return nil, fmt.Errorf("%s: unexpected answer %s", list, c)
+		}
+		// Spamhaus answers 127.255.255.x rather than a listing when it
+		// refuses a query, e.g. one relayed by a public resolver
+		if b := a.As4(); b[1] == 255 && b[2] == 255 {
+			return nil, fmt.Errorf("%s refused the query (%s); public DNS resolvers are blocked, so use your own", list, c)
+		}
+		if code == "" {
+			code = c
+		}
+	}
+	if code == "" {
+		return nil, nil
+	}
+	l := &DNSBLListing{List: list, IP: ip.String(), Code: code}
+	if txts, err := dnsblResolver.LookupTXT(ctx, name); err == nil && len(txts) > 0 {
+		l.Reason = truncate(strings.Join(txts, " "), 200)
+	}
+	return l, nil
+}
+
+// dnsblName returns the name looked up to ask list about ip: IPv4
+// addresses' octets, and IPv6 addresses' nibbles, in reverse order
+func dnsblName(ip netip.Addr, list string) string {
+	var b strings.Builder
+	if ip.Is4() {
+		o := ip.As4()
+		fmt.Fprintf(&b, "%d.%d.%d.%d.", o[3], o[2], o[1], o[0])
+	} else {
+		o := ip.As16()
+		for i := len(o) - 1; i >= 0; i-- {
+			fmt.Fprintf(&b, "%x.%x.", o[i]&0xf, o[i]>>4)
+		}
+	}
+	b.WriteString(strings.TrimSuffix(list, "."))
+	return b.String()
+}
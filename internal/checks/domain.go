@@ -0,0 +1,403 @@
+package checks
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultDomainWarnDays is how many days before it expires a domain
+	// check fails unless configured otherwise
+	DefaultDomainWarnDays = 30
+	// DomainInterval is how often a domain check looks its domain up.
+	// Registries rate-limit lookups and expiry dates change only on renewal,
+	// so it runs far less often than other checks.
+	DomainInterval = 6 * time.Hour
+
+	whoisPort        = "43"
+	whoisMaxBytes    = 64 << 10
+	rdapMaxBytes     = 1 << 20
+	rdapBootstrapTTL = 24 * time.Hour
+)
+
+// Where a domain check finds which registry to ask; variables so they can
+// be pointed at a test server
+var (
+	rdapBootstrapURL = "https://data.iana.org/rdap/dns.json"
+	whoisIANA        = "whois.iana.org"
+)
+
+// DomainOptions says which domain a domain check looks up and how soon
+// before it expires it fails
+type DomainOptions struct {
+	Domain   string // Registered domain, e.g. "example.com"; empty means the host's address
+	WarnDays int    // Fail this many days before expiry; 0 means DefaultDomainWarnDays
+
+	Identity ProbeIdentity // User-Agent and probe ID header of RDAP requests, set per run
+}
+
+type DomainResult struct {
+	Latency   time.Duration
+	Domain    string    // The domain looked up, which may be a parent of the one asked for
+	Expiry    time.Time // When the registration lapses
+	Registrar string    // Empty if not reported
+	Status    []string  // Registry statuses such as "redemption period"
+	Source    string    // "rdap" or "whois"
+	Addr      string    // IP address of the registry server, or the last one tried
+	OK        bool      // Registered, and not expiring within the warning
+	Err       error
+}
+
+// Warn returns how long before expiry a domain check fails
+func (o DomainOptions) Warn() time.Duration {
+	days := o.WarnDays
+	if days <= 0 {
+		days = DefaultDomainWarnDays
+	}
+	return time.Duration(days) * 24 * time.Hour
+}
+
+// errNoSuchDomain is a registry's answer for a name it has no record of
+var errNoSuchDomain = errors.New("no such domain")
+
+// DomainExpiry asks the registry of domain when its registration expires,
+// over RDAP where the registry offers it and WHOIS otherwise. A subdomain,
+// such as a host's name, is looked up as its nearest registered parent. It
+// fails when the domain expires within opts.Warn, or is being deleted.
+func DomainExpiry(domain string, timeout time.Duration, opts DomainOptions) DomainResult {
+	domain = strings.ToLower(strings.TrimSuffix(strings.TrimSpace(domain), "."))
+	if net.ParseIP(domain) != nil || !strings.Contains(domain, ".") {
+		return DomainResult{Err: fmt.Errorf("%q isn't a domain name; set the domain to check", domain)}
+	}
+	start := time.Now()
+	deadline := start.Add(timeout)
+	var res DomainResult
+	servers, suffix, err := rdapServers(domain, timeout, opts)
+	switch {
+	case err != nil:
+		res.Err = err
+	case servers != nil:
+		res = lookupParents(domain, suffix, func(name string) DomainResult {
+			return rdapDomain(servers, name, time.Until(deadline), opts)
+		})
+	default:
+		res = whoisDomain(domain, deadline)
+	}
+	res.Latency = time.Since(start)
+	if res.Err != nil {
+		return res
+	}
+	if res.Expiry.IsZero() {
+		res.Err = fmt.Errorf("%s's registry doesn't publish its expiry date over %s", res.Domain, strings.ToUpper(res.Source))
+		return res
+	}
+	res.OK = time.Until(res.Expiry) > opts.Warn()
+	for _, s := range res.Status {
+		if s == "redemption period" || s == "pending delete" {
+			res.OK = false
+		}
+	}
+	return res
+}
+
+// lookupParents looks up domain and, while the registry has no record of
+// it, its parents down to one label below suffix
+func lookupParents(domain, suffix string, lookup func(string) DomainResult) DomainResult {
+	labels := strings.Split(domain, ".")
+	minLabels := strings.Count(suffix, ".") + 2
+	var res DomainResult
+	for i := 0; len(labels)-i >= minLabels; i++ {
+		name := strings.Join(labels[i:], ".")
+		res = lookup(name)
+		res.Domain = name
+		if !errors.Is(res.Err, errNoSuchDomain) {
+			return res
+		}
+	}
+	res.Domain = domain
+	res.Err = fmt.Errorf("%s isn't registered", domain)
+	return res
+}
+
+// rdapBootstrap caches IANA's list of the RDAP servers of each TLD
+var rdapBootstrap struct {
+	mu      sync.Mutex
+	servers map[string][]string
+	fetched time.Time
+}
+
+// rdapServers returns the RDAP servers of the registry for domain and the
+// suffix they serve, e.g. "com" or "co.uk". It returns no servers and no
+// error when the registry has none, so WHOIS is used instead.
+func rdapServers(domain string, timeout time.Duration, opts DomainOptions) ([]string, string, error) {
+	rdapBootstrap.mu.Lock()
+	defer rdapBootstrap.mu.Unlock()
+	if rdapBootstrap.servers == nil || time.Since(rdapBootstrap.fetched) > rdapBootstrapTTL {
+		servers, err := fetchRDAPBootstrap(timeout, opts)
+		if err != nil && rdapBootstrap.servers == nil {
+			return nil, "", fmt.Errorf("rdap: fetching the list of registries: %w", err)
+		}
+		if err == nil {
+			rdapBootstrap.servers, rdapBootstrap.fetched = servers, time.Now()
+		}
+	}
+	labels := strings.Split(domain, ".")
+	for i := 1; i < len(labels); i++ {
+		suffix := strings.Join(labels[i:], ".")
+		if servers, ok := rdapBootstrap.servers[suffix]; ok {
+			return servers, suffix, nil
+		}
+	}
+	return nil, "", nil
+}
+
+func fetchRDAPBootstrap(timeout time.Duration, opts DomainOptions) (map[string][]string, error) {
+	var boot struct {
+		Services [][][]string `json:"services"`
+	}
+	if err := getJSON(rdapBootstrapURL, timeout, opts, nil, &boot); err != nil {
+		return nil, err
+	}
+	servers := map[string][]string{}
+	for _, svc := range boot.Services {
+		if len(svc) != 2 {
+			continue
+		}
+		for _, tld := range svc[0] {
+			servers[strings.ToLower(tld)] = svc[1]
+		}
+	}
+	return servers, nil
+}
+
+// rdapReply is the part of an RDAP domain object a domain check reads
+type rdapReply struct {
+	Events []struct {
+		Action string    `json:"eventAction"`
+		Date   time.Time `json:"eventDate"`
+	} `json:"events"`
+	Entities []struct {
+		Roles []string          `json:"roles"`
+		VCard []json.RawMessage `json:"vcardArray"`
+	} `json:"entities"`
+	Status []string `json:"status"`
+}
+
+// rdapDomain asks the first of servers that answers about name
+func rdapDomain(servers []string, name string, timeout time.Duration, opts DomainOptions) DomainResult {
+	res := DomainResult{Source: "rdap"}
+	var reply rdapReply
+	for _, base := range servers {
+		if !strings.HasSuffix(base, "/") {
+			base += "/"
+		}
+		res.Err = getJSON(base+"domain/"+name, timeout, opts, &res.Addr, &reply)
+		if res.Err == nil || errors.Is(res.Err, errNoSuchDomain) {
+			break
+		}
+	}
+	if res.Err != nil {
+		return res
+	}
+	for _, e := range reply.Events {
+		if e.Action == "expiration" {
+			res.Expiry = e.Date
+		}
+	}
+	for _, e := range reply.Entities {
+		for _, role := range e.Roles {
+			if role == "registrar" {
+				res.Registrar = vcardName(e.VCard)
+			}
+		}
+	}
+	res.Status = reply.Status
+	return res
+}
+
+// vcardName reads the fn property of a jCard, ["vcard", [[name, params,
+// type, value], ...]]
+func vcardName(card []json.RawMessage) string {
+	if len(card) < 2 {
+		return ""
+	}
+	var props [][]any
+	if json.Unmarshal(card[1], &props) != nil {
+		return ""
+	}
+	for _, p := range props {
+		if len(p) >= 4 && p[0] == "fn" {
+			if s, ok := p[3].(string); ok {
+				return s
+			}
+		}
+	}
+	return ""
+}
+
+// getJSON fetches url into v, recording the server's address in addr. A
+// 404 is errNoSuchDomain, as RDAP servers answer for names they don't hold.
+func getJSON(url string, timeout time.Duration, opts DomainOptions, addr *string, v any) error {
+	client, err := newHTTPClient(timeout, HTTPOptions{})
+	if err != nil {
+		return err
+	}
+	var traced tracedAddr
+	req, err := http.NewRequestWithContext(traced.context(context.Background()), http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/rdap+json, application/json")
+	opts.Identity.setHeaders(req.Header)
+	resp, err := client.Do(req)
+	if addr != nil {
+		*addr = traced.get()
+	}
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	switch {
+	case resp.StatusCode == http.StatusNotFound:
+		return errNoSuchDomain
+	case resp.StatusCode == http.StatusTooManyRequests:
+		return errors.New("rdap: the registry is rate limiting lookups")
+	case resp.StatusCode != http.StatusOK:
+		return fmt.Errorf("rdap: status %s", resp.Status)
+	}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, rdapMaxBytes)).Decode(v); err != nil {
+		return fmt.Errorf("rdap: malformed reply: %w", err)
+	}
+	return nil
+}
+
+// whoisServers caches the WHOIS server of each TLD, as IANA refers to it
+var whoisServers sync.Map
+
+// whoisDomain looks domain up with the WHOIS server of its TLD, for
+// registries without RDAP
+func whoisDomain(domain string, deadline time.Time) DomainResult {
+	res := DomainResult{Source: "whois"}
+	tld := domain[strings.LastIndexByte(domain, '.')+1:]
+	server, ok := whoisServers.Load(tld)
+	if !ok {
+		reply, addr, err := whois(whoisIANA, tld, deadline)
+		if err != nil {
+			res.Addr, res.Err = addr, fmt.Errorf("whois: asking IANA for .%s's server: %w", tld, err)
+			return res
+		}
+		server = whoisField(reply, "whois")
+		if server == "" {
+			res.Addr, res.Err = addr, fmt.Errorf("the .%s registry offers neither RDAP nor WHOIS", tld)
+			return res
+		}
+		whoisServers.Store(tld, server)
+	}
+	return lookupParents(domain, tld, func(name string) DomainResult {
+		res := DomainResult{Source: "whois"}
+		var reply string
+		reply, res.Addr, res.Err = whois(server.(string), name, deadline)
+		if res.Err != nil {
+			return res
+		}
+		if whoisNotFound(reply) {
+			res.Err = errNoSuchDomain
+			return res
+		}
+		res.Expiry = whoisExpiry(reply)
+		res.Registrar = whoisField(reply, "registrar", "registrar name", "sponsoring registrar")
+		return res
+	})
+}
+
+// whois sends query to server and returns its reply
+func whois(server, query string, deadline time.Time) (string, string, error) {
+	conn, err := net.DialTimeout("tcp", net.JoinHostPort(server, whoisPort), time.Until(deadline))
+	if err != nil {
+		return "", dialedIP(err), err
+	}
+	defer conn.Close()
+	addr := remoteIP(conn.RemoteAddr())
+	_ = conn.SetDeadline(deadline)
+	if _, err := io.WriteString(conn, query+"\r\n"); err != nil {
+		return "", addr, err
+	}
+	reply, err := io.ReadAll(io.LimitReader(conn, whoisMaxBytes))
+	if err != nil && len(reply) == 0 {
+		return "", addr, err
+	}
+	return string(reply), addr, nil
+}
+
+// whoisField returns the value of the first "key: value" line of reply
+// with one of keys, compared without case
+func whoisField(reply string, keys ...string) string {
+	sc := bufio.NewScanner(strings.NewReader(reply))
+	for sc.Scan() {
+		k, v, ok := strings.Cut(strings.TrimSpace(sc.Text()), ":")
+		if !ok {
+			continue
+		}
+		k = strings.ToLower(strings.TrimSpace(k))
+		for _, want := range keys {
+			if k == want && strings.TrimSpace(v) != "" {
+				return strings.TrimSpace(v)
+			}
+		}
+	}
+	return ""
+}
+
+// whoisExpiryKeys are what registries call the expiry date, most specific
+// first
+var whoisExpiryKeys = []string{
+	"registry expiry date", "registrar registration expiration date", "expiry date",
+	"expiration date", "expiration time", "expire date", "expires on", "expires",
+	"paid-till", "renewal date", "valid until", "expire",
+}
+
+// whoisDateLayouts are the date formats seen in WHOIS replies
+var whoisDateLayouts = []string{
+	time.RFC3339, "2006-01-02T15:04:05Z", "2006-01-02T15:04:05", "2006-01-02 15:04:05",
+	"2006-01-02", "2006.01.02", "2006/01/02", "02-Jan-2006", "02.01.2006", "02/01/2006",
+	"January 2 2006", "Mon Jan 2 15:04:05 MST 2006",
+}
+
+// whoisExpiry finds the expiry date in a WHOIS reply, or returns zero
+func whoisExpiry(reply string) time.Time {
+	for _, key := range whoisExpiryKeys {
+		v := whoisField(reply, key)
+		if v == "" {
+			continue
+		}
+		for _, s := range []string{v, strings.Fields(v)[0]} {
+			for _, layout := range whoisDateLayouts {
+				if t, err := time.Parse(layout, s); err == nil {
+					return t
+				}
+			}
+		}
+	}
+	return time.Time{}
+}
+
+// whoisNotFound reports whether a WHOIS reply says the domain isn't
+// registered
+func whoisNotFound(reply string) bool {
+	lower := strings.ToLower(reply)
+	for _, s := range []string{"no match for", "not found", "no entries found", "no data found", "status: free", "is available for registration"} {
+		if strings.Contains(lower, s) {
+			return true
+		}
+	}
+	return false
+}
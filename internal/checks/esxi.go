@@ -0,0 +1,334 @@
+package checks
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/cookiejar"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DefaultESXiPort is where an ESXi host serves its API
+const DefaultESXiPort = 443
+
+const esxiMaxResponse = 8 << 20
+
+// esxiHost is the managed object ID of the host on a standalone ESXi
+// server, which has exactly one
+const esxiHost = "ha-host"
+
+// ESXiOptions says how an esxi check logs in to the host's vSphere API and
+// what it looks at
+type ESXiOptions struct {
+	User               string
+	Password           string
+	VM                 string // Virtual machine, by name, that must be powered on; empty only counts VMs
+	MaxUsage           int    // Fail when memory or a datastore is fuller than this percentage; 0 means DefaultMaxUsage
+	Port               int    // 0 means DefaultESXiPort
+	InsecureSkipVerify bool   // Accept ESXi's self-signed certificate
+
+	Identity ProbeIdentity // User-Agent and probe ID header, set per run
+}
+
+type ESXiResult struct {
+	Latency     time.Duration
+	Product     string   // e.g. "VMware ESXi 8.0.2 build-22380479"
+	Status      string   // The host's overall status: green, yellow, red or gray
+	Maintenance bool     // The host is in maintenance mode
+	Alarms      []string // Red sensors and resources over the usage limit, e.g. "Power Supply 2 red"
+	Warnings    []string // Yellow sensors, which pass
+	VMs         int
+	Running     int    // VMs that are powered on
+	VMState     string // Power state of opts.VM, e.g. "poweredOn"
+	Addr        string // IP address the response came from, or the last one tried
+	TLS         *TLSInfo
+	OK          bool // The host isn't red, nothing is red or over the limit and opts.VM, if set, is on
+	Err         error
+}
+
+// ESXiStatus logs in to the vSphere API of the ESXi host on host and checks
+// its overall status, its hardware sensors, its memory and datastore usage
+// and, with opts.VM, that the VM is powered on. Yellow sensors are reported
+// but pass. A read-only user is enough; hosts managed by vCenter work too,
+// but vCenter itself doesn't.
+func ESXiStatus(host string, timeout time.Duration, opts ESXiOptions) ESXiResult {
+	port := opts.Port
+	if port == 0 {
+		port = DefaultESXiPort
+	}
+	client, err := newHTTPClient(timeout, HTTPOptions{NoFollowRedirects: true, InsecureSkipVerify: opts.InsecureSkipVerify})
+	if err != nil {
+		return ESXiResult{Err: err}
+	}
+	client.Jar, _ = cookiejar.New(nil)
+	defer client.CloseIdleConnections()
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	c := &vimClient{
+		client:   client,
+		url:      "https://" + net.JoinHostPort(host, strconv.Itoa(port)) + "/sdk",
+		ctx:      ctx,
+		identity: opts.Identity,
+	}
+	start := time.Now()
+	var res ESXiResult
+	res.Err = res.query(c, opts)
+	res.Latency = time.Since(start)
+	res.Addr = c.addr.get()
+	if c.tls != nil {
+		res.TLS = newTLSInfo(c.tls, host, opts.InsecureSkipVerify)
+	}
+	if res.Err != nil {
+		return res
+	}
+	res.OK = res.Status != "red" && len(res.Alarms) == 0 && (opts.VM == "" || res.VMState == "poweredOn")
+	return res
+}
+
+// query logs in, reads the host's and then its VMs' and datastores'
+// properties, and logs out
+func (r *ESXiResult) query(c *vimClient, opts ESXiOptions) error {
+	var sc struct {
+		SessionManager    string `xml:"returnval>sessionManager"`
+		PropertyCollector string `xml:"returnval>propertyCollector"`
+		FullName          string `xml:"returnval>about>fullName"`
+		APIType           string `xml:"returnval>about>apiType"`
+	}
+	if err := c.call(`<RetrieveServiceContent xmlns="urn:vim25"><_this type="ServiceInstance">ServiceInstance</_this></RetrieveServiceContent>`, &sc); err != nil {
+		return err
+	}
+	r.Product = sc.FullName
+	if sc.APIType != "HostAgent" {
+		return fmt.Errorf("%s is not an ESXi host; point the check at the host itself", sc.FullName)
+	}
+	login := fmt.Sprintf(`<Login xmlns="urn:vim25"><_this type="SessionManager">%s</_this><userName>%s</userName><password>%s</password></Login>`,
+		xmlEscape(sc.SessionManager), xmlEscape(opts.User), xmlEscape(opts.Password))
+	if err := c.call(login, nil); err != nil {
+		return err
+	}
+	defer c.call(fmt.Sprintf(`<Logout xmlns="urn:vim25"><_this type="SessionManager">%s</_this></Logout>`, xmlEscape(sc.SessionManager)), nil)
+
+	var hostProps vimProperties
+	spec := vimPropertySpec("HostSystem", []string{esxiHost},
+		"overallStatus", "runtime.inMaintenanceMode", "runtime.healthSystemRuntime.systemHealthInfo.numericSensorInfo",
+		"summary.quickStats.overallMemoryUsage", "summary.hardware.memorySize", "vm", "datastore")
+	if err := c.call(vimRetrieve(sc.PropertyCollector, spec), &hostProps); err != nil {
+		return err
+	}
+	if len(hostProps.Objects) == 0 {
+		return errors.New("esxi: host properties missing")
+	}
+	maxUsage := opts.MaxUsage
+	if maxUsage == 0 {
+		maxUsage = DefaultMaxUsage
+	}
+	var memUsedMB, memBytes float64
+	var vms, datastores []string
+	for _, p := range hostProps.Objects[0].Props {
+		switch p.Name {
+		case "overallStatus":
+			r.Status = p.Val.Text
+		case "runtime.inMaintenanceMode":
+			r.Maintenance = p.Val.Text == "true"
+		case "runtime.healthSystemRuntime.systemHealthInfo.numericSensorInfo":
+			for _, s := range p.Val.Sensors {
+				switch s.Health {
+				case "red":
+					r.Alarms = append(r.Alarms, s.Name+" red")
+				case "yellow":
+					r.Warnings = append(r.Warnings, s.Name+" yellow")
+				}
+			}
+		case "summary.quickStats.overallMemoryUsage":
+			memUsedMB, _ = strconv.ParseFloat(p.Val.Text, 64)
+		case "summary.hardware.memorySize":
+			memBytes, _ = strconv.ParseFloat(p.Val.Text, 64)
+		case "vm":
+			vms = p.Val.Refs
+		case "datastore":
+			datastores = p.Val.Refs
+		}
+	}
+	if pct, ok := usagePercent(memUsedMB*1024*1024, memBytes); ok && pct > maxUsage {
+		r.Alarms = append(r.Alarms, fmt.Sprintf("memory %d%%", pct))
+	}
+	if len(vms) == 0 && len(datastores) == 0 {
+		if opts.VM != "" {
+			return fmt.Errorf("no such VM %q", opts.VM)
+		}
+		return nil
+	}
+
+	var props vimProperties
+	specs := vimPropertySpec("VirtualMachine", vms, "name", "runtime.powerState") +
+		vimPropertySpec("Datastore", datastores, "summary.name", "summary.accessible", "summary.capacity", "summary.freeSpace")
+	if err := c.call(vimRetrieve(sc.PropertyCollector, specs), &props); err != nil {
+		return err
+	}
+	found := false
+	for _, obj := range props.Objects {
+		v := obj.values()
+		switch obj.Ref.Type {
+		case "VirtualMachine":
+			r.VMs++
+			if v["runtime.powerState"] == "poweredOn" {
+				r.Running++
+			}
+			if opts.VM != "" && v["name"] == opts.VM {
+				found = true
+				r.VMState = v["runtime.powerState"]
+			}
+		case "Datastore":
+			if v["summary.accessible"] != "true" {
+				r.Alarms = append(r.Alarms, "datastore "+v["summary.name"]+" inaccessible")
+				continue
+			}
+			capacity, _ := strconv.ParseFloat(v["summary.capacity"], 64)
+			free, _ := strconv.ParseFloat(v["summary.freeSpace"], 64)
+			if pct, ok := usagePercent(capacity-free, capacity); ok && pct > maxUsage {
+				r.Alarms = append(r.Alarms, fmt.Sprintf("datastore %s %d%% full", v["summary.name"], pct))
+			}
+		}
+	}
+	if opts.VM != "" && !found {
+		return fmt.Errorf("no such VM %q", opts.VM)
+	}
+	return nil
+}
+
+// vimClient makes SOAP calls to a vSphere API endpoint, keeping the
+// session cookie from Login
+type vimClient struct {
+	client   *http.Client
+	url      string
+	ctx      context.Context
+	identity ProbeIdentity
+	addr     tracedAddr
+	tls      *tls.ConnectionState
+}
+
+// call sends body, a request element, and decodes the response element
+// into out unless it is nil. A SOAP fault is returned as an error with its
+// message, e.g. "Cannot complete login due to an incorrect user name or
+// password."
+func (c *vimClient) call(body string, out any) error {
+	env := `<?xml version="1.0" encoding="UTF-8"?><soapenv:Envelope xmlns:soapenv="http://schemas.xmlsoap.org/soap/envelope/"><soapenv:Body>` +
+		body + `</soapenv:Body></soapenv:Envelope>`
+	req, err := http.NewRequestWithContext(c.addr.context(c.ctx), http.MethodPost, c.url, strings.NewReader(env))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "text/xml; charset=utf-8")
+	req.Header.Set("SOAPAction", "urn:vim25/6.5")
+	c.identity.setHeaders(req.Header)
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.TLS != nil {
+		c.tls = resp.TLS
+	}
+	b, err := io.ReadAll(io.LimitReader(resp.Body, esxiMaxResponse))
+	if err != nil {
+		return err
+	}
+	var reply struct {
+		Body struct {
+			Fault *struct {
+				String string `xml:"faultstring"`
+			} `xml:"Fault"`
+			Inner []byte `xml:",innerxml"`
+		} `xml:"Body"`
+	}
+	if err := xml.Unmarshal(b, &reply); err != nil {
+		if resp.StatusCode != http.StatusOK {
+			return fmt.Errorf("status %s", resp.Status)
+		}
+		return errors.New("esxi: not a vSphere API")
+	}
+	if reply.Body.Fault != nil {
+		return errors.New(strings.TrimSpace(reply.Body.Fault.String))
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("status %s", resp.Status)
+	}
+	if out == nil {
+		return nil
+	}
+	if err := xml.Unmarshal(bytes.TrimSpace(reply.Body.Inner), out); err != nil {
+		return errors.New("esxi: malformed response")
+	}
+	return nil
+}
+
+// vimProperties is a RetrievePropertiesResponse
+type vimProperties struct {
+	Objects []vimObjectContent `xml:"returnval"`
+}
+
+// vimObjectContent is one object's properties. Values are kept as text,
+// apart from the arrays a check reads.
+type vimObjectContent struct {
+	Ref struct {
+		Type string `xml:"type,attr"`
+	} `xml:"obj"`
+	Props []struct {
+		Name string `xml:"name"`
+		Val  struct {
+			Text    string   `xml:",chardata"`
+			Refs    []string `xml:"ManagedObjectReference"`
+			Sensors []struct {
+				Name   string `xml:"name"`
+				Health string `xml:"healthState>key"`
+			} `xml:"HostNumericSensorInfo"`
+		} `xml:"val"`
+	} `xml:"propSet"`
+}
+
+// values returns an object's simple properties by name
+func (o vimObjectContent) values() map[string]string {
+	v := make(map[string]string, len(o.Props))
+	for _, p := range o.Props {
+		v[p.Name] = p.Val.Text
+	}
+	return v
+}
+
+// vimPropertySpec asks for properties of the objects of type typ with the
+// given IDs, or nothing when there are none
+func vimPropertySpec(typ string, ids []string, paths ...string) string {
+	if len(ids) == 0 {
+		return ""
+	}
+	var b strings.Builder
+	b.WriteString("<specSet><propSet><type>" + typ + "</type>")
+	for _, p := range paths {
+		b.WriteString("<pathSet>" + p + "</pathSet>")
+	}
+	b.WriteString("</propSet>")
+	for _, id := range ids {
+		b.WriteString(`<objectSet><obj type="` + typ + `">` + xmlEscape(id) + "</obj></objectSet>")
+	}
+	b.WriteString("</specSet>")
+	return b.String()
+}
+
+// vimRetrieve is a RetrieveProperties request for specs
+func vimRetrieve(collector, specs string) string {
+	return `<RetrieveProperties xmlns="urn:vim25"><_this type="PropertyCollector">` + xmlEscape(collector) + "</_this>" + specs + "</RetrieveProperties>"
+}
+
+func xmlEscape(s string) string {
+	var b strings.Builder
+	_ = xml.EscapeText(&b, []byte(s))
+	return b.String()
+}
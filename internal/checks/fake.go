@@ -0,0 +1,437 @@
+package checks
+
+import (
+	"fmt"
+	"path"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Fake is an in-memory Checker with canned results, for deterministic tests
+// of the scheduler and dependency handling. Targets with no result set
+// succeed with zero latency.
+type Fake struct {
+	mu    sync.Mutex
+	ping  map[string]PingResult
+	http  map[string]HTTPResult
+	tcp   map[string]TCPResult
+	ssh   map[string]SSHResult
+	ws    map[string]WebSocketResult
+	files map[string]FileResult
+	s3    map[string]S3Result
+	ipp   map[string]IPPResult
+	smb   map[string]SMBResult
+	kafka map[string]KafkaResult
+	amqp  map[string]AMQPResult
+	kube  map[string]KubernetesResult
+	pve   map[string]ProxmoxResult
+	esxi  map[string]ESXiResult
+	ups   map[string]UPSResult
+	speed map[string]SpeedtestResult
+	pubIP map[string]PublicIPResult
+	dom   map[string]DomainResult
+	dnsbl map[string]DNSBLResult
+	calls []string
+}
+
+// NewFake returns a Fake where every probe succeeds until told otherwise
+func NewFake() *Fake {
+	return &Fake{
+		ping:  make(map[string]PingResult),
+		http:  make(map[string]HTTPResult),
+		tcp:   make(map[string]TCPResult),
+		ssh:   make(map[string]SSHResult),
+		ws:    make(map[string]WebSocketResult),
+		files: make(map[string]FileResult),
+		s3:    make(map[string]S3Result),
+		ipp:   make(map[string]IPPResult),
+		smb:   make(map[string]SMBResult),
+		kafka: make(map[string]KafkaResult),
+		amqp:  make(map[string]AMQPResult),
+		kube:  make(map[string]KubernetesResult),
+		pve:   make(map[string]ProxmoxResult),
+		esxi:  make(map[string]ESXiResult),
+		ups:   make(map[string]UPSResult),
+		speed: make(map[string]SpeedtestResult),
+		pubIP: make(map[string]PublicIPResult),
+		dom:   make(map[string]DomainResult),
+		dnsbl: make(map[string]DNSBLResult),
+	}
+}
+
+// SetPing sets the result returned for pings to host
+func (f *Fake) SetPing(host string, res PingResult) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.ping[host] = res
+}
+
+// SetHTTP sets the result returned for GETs of url
+func (f *Fake) SetHTTP(url string, res HTTPResult) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.http[url] = res
+}
+
+// SetTCP sets the result returned for connections to host:port
+func (f *Fake) SetTCP(host string, port int, res TCPResult) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.tcp[tcpKey(host, port)] = res
+}
+
+// SetSSH sets the result returned for commands run on host
+func (f *Fake) SetSSH(host string, res SSHResult) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.ssh[host] = res
+}
+
+// SetWebSocket sets the result returned for connections to url
+func (f *Fake) SetWebSocket(url string, res WebSocketResult) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.ws[url] = res
+}
+
+// SetFile sets the result returned for the file at path
+func (f *Fake) SetFile(path string, res FileResult) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.files[path] = res
+}
+
+// SetS3 sets the result returned for HEADs of the bucket or object at endpoint
+func (f *Fake) SetS3(endpoint string, opts S3Options, res S3Result) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.s3[s3Key(endpoint, opts)] = res
+}
+
+// SetIPP sets the result returned for the printer at url
+func (f *Fake) SetIPP(url string, res IPPResult) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.ipp[url] = res
+}
+
+// SetSMB sets the result returned for connections to share on host
+func (f *Fake) SetSMB(host, share string, res SMBResult) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.smb[host+`\`+share] = res
+}
+
+// SetKafka sets the result returned for the Kafka broker on host
+func (f *Fake) SetKafka(host string, res KafkaResult) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.kafka[host] = res
+}
+
+// SetAMQP sets the result returned for the AMQP broker on host
+func (f *Fake) SetAMQP(host string, res AMQPResult) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.amqp[host] = res
+}
+
+// SetKubernetes sets the result returned for deployment, or for the nodes
+// when it is empty, on the API server at url
+func (f *Fake) SetKubernetes(url, deployment string, res KubernetesResult) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.kube[url+" "+deployment] = res
+}
+
+// SetProxmox sets the result returned for the Proxmox VE API on host
+func (f *Fake) SetProxmox(host string, res ProxmoxResult) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.pve[host] = res
+}
+
+// SetESXi sets the result returned for the ESXi host on host
+func (f *Fake) SetESXi(host string, res ESXiResult) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.esxi[host] = res
+}
+
+// SetUPS sets the result returned for the UPS daemon on host
+func (f *Fake) SetUPS(host string, res UPSResult) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.ups[host] = res
+}
+
+// SetSpeedtest sets the result returned for speedtests of host
+func (f *Fake) SetSpeedtest(host string, res SpeedtestResult) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.speed[host] = res
+}
+
+// SetPublicIP sets the result returned for lookups from the service at url
+func (f *Fake) SetPublicIP(url string, res PublicIPResult) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.pubIP[url] = res
+}
+
+// SetDomain sets the result returned for lookups of domain
+func (f *Fake) SetDomain(domain string, res DomainResult) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.dom[domain] = res
+}
+
+// SetDNSBL sets the result returned for blocklist lookups of host
+func (f *Fake) SetDNSBL(host string, res DNSBLResult) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.dnsbl[host] = res
+}
+
+// SetHostDown makes every probe of host fail, as if it were unreachable
+func (f *Fake) SetHostDown(host string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.ping[host] = PingResult{OK: false, PacketsTx: 1, Err: fmt.Errorf("fake: %s unreachable", host)}
+}
+
+// Calls returns the probes made so far, e.g. "ping 10.0.0.1" or "tcp 10.0.0.1:22"
+func (f *Fake) Calls() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]string(nil), f.calls...)
+}
+
+// Ping implements Checker
+func (f *Fake) Ping(host string, timeout time.Duration, opts PingOptions) PingResult {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.calls = append(f.calls, "ping "+host)
+	if res, ok := f.ping[host]; ok {
+		return res
+	}
+	return PingResult{OK: true, PacketsTx: 1, PacketsRx: 1, Method: opts.reportedMethod()}
+}
+
+// HTTP implements Checker
+func (f *Fake) HTTP(url string, timeout time.Duration, opts HTTPOptions) HTTPResult {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.calls = append(f.calls, "http "+url)
+	if res, ok := f.http[url]; ok {
+		return res
+	}
+	return HTTPResult{Code: 200}
+}
+
+// TCP implements Checker
+func (f *Fake) TCP(host string, port int, timeout time.Duration, opts DialOptions) TCPResult {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	key := tcpKey(host, port)
+	f.calls = append(f.calls, "tcp "+key)
+	if res, ok := f.tcp[key]; ok {
+		return res
+	}
+	return TCPResult{OK: true}
+}
+
+// SSH implements Checker
+func (f *Fake) SSH(host string, timeout time.Duration, opts SSHOptions) SSHResult {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.calls = append(f.calls, "ssh "+host)
+	if res, ok := f.ssh[host]; ok {
+		return res
+	}
+	return SSHResult{OK: true, ExitCode: opts.ExpectExit}
+}
+
+// WebSocket implements Checker
+func (f *Fake) WebSocket(url string, timeout time.Duration, opts WebSocketOptions) WebSocketResult {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.calls = append(f.calls, "websocket "+url)
+	if res, ok := f.ws[url]; ok {
+		return res
+	}
+	return WebSocketResult{Code: 101, OK: true}
+}
+
+// File implements Checker
+func (f *Fake) File(host string, timeout time.Duration, opts FileOptions) FileResult {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.calls = append(f.calls, "file "+opts.Path)
+	if res, ok := f.files[opts.Path]; ok {
+		return res
+	}
+	return FileResult{Name: path.Base(opts.Path), ModTime: time.Now(), OK: true}
+}
+
+// S3 implements Checker
+func (f *Fake) S3(endpoint string, timeout time.Duration, opts S3Options) S3Result {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	key := s3Key(endpoint, opts)
+	f.calls = append(f.calls, "s3 "+key)
+	if res, ok := f.s3[key]; ok {
+		return res
+	}
+	res := S3Result{Code: 200, OK: true}
+	if opts.Object != "" {
+		res.LastModified = time.Now()
+	}
+	return res
+}
+
+// IPP implements Checker
+func (f *Fake) IPP(url string, timeout time.Duration, opts IPPOptions) IPPResult {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.calls = append(f.calls, "ipp "+url)
+	if res, ok := f.ipp[url]; ok {
+		return res
+	}
+	return IPPResult{State: "idle", OK: true}
+}
+
+// SMB implements Checker
+func (f *Fake) SMB(host string, timeout time.Duration, opts SMBOptions) SMBResult {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	key := host + `\` + opts.Share
+	f.calls = append(f.calls, "smb "+key)
+	if res, ok := f.smb[key]; ok {
+		return res
+	}
+	return SMBResult{Dialect: "3.0.2", OK: true}
+}
+
+// Kafka implements Checker
+func (f *Fake) Kafka(host string, timeout time.Duration, opts KafkaOptions) KafkaResult {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.calls = append(f.calls, "kafka "+host)
+	if res, ok := f.kafka[host]; ok {
+		return res
+	}
+	return KafkaResult{Brokers: 1, OK: true}
+}
+
+// AMQP implements Checker
+func (f *Fake) AMQP(host string, timeout time.Duration, opts AMQPOptions) AMQPResult {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.calls = append(f.calls, "amqp "+host)
+	if res, ok := f.amqp[host]; ok {
+		return res
+	}
+	return AMQPResult{Product: "RabbitMQ", OK: true}
+}
+
+// Kubernetes implements Checker
+func (f *Fake) Kubernetes(url string, timeout time.Duration, opts KubernetesOptions) KubernetesResult {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	key := url + " " + opts.Deployment
+	f.calls = append(f.calls, "kubernetes "+key)
+	if res, ok := f.kube[key]; ok {
+		return res
+	}
+	if opts.Deployment != "" {
+		return KubernetesResult{Replicas: 1, Available: 1, OK: true}
+	}
+	return KubernetesResult{Nodes: 1, Ready: 1, OK: true}
+}
+
+func s3Key(endpoint string, opts S3Options) string {
+	return strings.TrimSuffix(endpoint, "/") + "/" + opts.Bucket + "/" + opts.Object
+}
+
+func tcpKey(host string, port int) string {
+	return fmt.Sprintf("%s:%d", host, port)
+}
+
+// Proxmox implements Checker
+func (f *Fake) Proxmox(host string, timeout time.Duration, opts ProxmoxOptions) ProxmoxResult {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.calls = append(f.calls, "proxmox "+host)
+	if res, ok := f.pve[host]; ok {
+		return res
+	}
+	return ProxmoxResult{Nodes: 1, Online: 1, OK: true}
+}
+
+// ESXi implements Checker
+func (f *Fake) ESXi(host string, timeout time.Duration, opts ESXiOptions) ESXiResult {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.calls = append(f.calls, "esxi "+host)
+	if res, ok := f.esxi[host]; ok {
+		return res
+	}
+	return ESXiResult{Product: "VMware ESXi", Status: "green", OK: true}
+}
+
+// UPS implements Checker
+func (f *Fake) UPS(host string, timeout time.Duration, opts UPSOptions) UPSResult {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.calls = append(f.calls, "ups "+host)
+	if res, ok := f.ups[host]; ok {
+		return res
+	}
+	return UPSResult{Status: []string{"online"}, Charge: 100, Load: -1, OK: true}
+}
+
+// Speedtest implements Checker
+func (f *Fake) Speedtest(host string, timeout time.Duration, opts SpeedtestOptions) SpeedtestResult {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.calls = append(f.calls, "speedtest "+host)
+	if res, ok := f.speed[host]; ok {
+		return res
+	}
+	return SpeedtestResult{Speed: 100_000_000, Bytes: 125_000_000, Duration: SpeedtestDuration, OK: true}
+}
+
+// PublicIP implements Checker
+func (f *Fake) PublicIP(url string, timeout time.Duration, opts PublicIPOptions) PublicIPResult {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.calls = append(f.calls, "publicip "+url)
+	if res, ok := f.pubIP[url]; ok {
+		return res
+	}
+	return PublicIPResult{IP: "203.0.113.10", OK: true}
+}
+
+// Domain implements Checker
+func (f *Fake) Domain(domain string, timeout time.Duration, opts DomainOptions) DomainResult {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.calls = append(f.calls, "domain "+domain)
+	if res, ok := f.dom[domain]; ok {
+		return res
+	}
+	return DomainResult{Domain: domain, Expiry: time.Now().AddDate(1, 0, 0), Source: "rdap", OK: true}
+}
+
+// DNSBL implements Checker
+func (f *Fake) DNSBL(host string, timeout time.Duration, opts DNSBLOptions) DNSBLResult {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.calls = append(f.calls, "dnsbl "+host)
+	if res, ok := f.dnsbl[host]; ok {
+		return res
+	}
+	return DNSBLResult{IPs: []string{host}, Checked: len(opts.Lists()), Addr: host, OK: true}
+}
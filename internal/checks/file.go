@@ -0,0 +1,375 @@
+package checks
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path"
+	"time"
+)
+
+// FileOptions says which file or directory a file check watches and how old
+// it may get. A directory is as fresh as the newest entry directly in it, so
+// a backup job that adds a dated archive each night keeps it fresh.
+type FileOptions struct {
+	Path   string
+	MaxAge time.Duration // Fail when the newest file is older; 0 only reports its age
+	SFTP   bool          // Read Path on the host over SFTP instead of on the monitor
+
+	// SFTP login, as for ssh checks: key-based only
+	User    string
+	KeyFile string
+	Port    int // 0 means DefaultSSHPort
+}
+
+type FileResult struct {
+	Latency time.Duration
+	Name    string    // The newest file: Path itself, or an entry in it
+	ModTime time.Time // When Name was last modified
+	OK      bool      // Name is no older than MaxAge
+	Err     error     // Why the file couldn't be read
+}
+
+// FileAge finds when opts.Path, or the newest entry in it if it is a
+// directory, was last modified, on the monitor or over SFTP. Mounted network
+// shares, e.g. SMB or NFS, are read as local paths; one that hangs fails the
+// check after timeout.
+func FileAge(host string, timeout time.Duration, opts FileOptions) FileResult {
+	start := time.Now()
+	var res FileResult
+	if opts.SFTP {
+		res.Name, res.ModTime, res.Err = sftpNewest(host, timeout, opts)
+	} else {
+		done := make(chan struct{})
+		var name string
+		var mod time.Time
+		var err error
+		go func() {
+			name, mod, err = localNewest(opts.Path)
+			close(done)
+		}()
+		select {
+		case <-done:
+			res.Name, res.ModTime, res.Err = name, mod, err
+		case <-time.After(timeout):
+			res.Err = fmt.Errorf("%s: timed out after %v", opts.Path, timeout)
+		}
+	}
+	res.Latency = time.Since(start)
+	res.OK = res.Err == nil && (opts.MaxAge <= 0 || time.Since(res.ModTime) <= opts.MaxAge)
+	return res
+}
+
+// localNewest stats path, or its entries if it is a directory
+func localNewest(path string) (string, time.Time, error) {
+	fi, err := os.Stat(path)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	if !fi.IsDir() {
+		return fi.Name(), fi.ModTime(), nil
+	}
+	entries, err := os.ReadDir(path)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	var name string
+	var newest time.Time
+	for _, e := range entries {
+		info, err := e.Info()
+		if err != nil {
+			continue // Removed since it was listed
+		}
+		if info.ModTime().After(newest) {
+			name, newest = e.Name(), info.ModTime()
+		}
+	}
+	if name == "" {
+		return "", time.Time{}, fmt.Errorf("%s is empty", path)
+	}
+	return name, newest, nil
+}
+
+// SFTP version 3 packet types and attribute flags, from
+// draft-ietf-secsh-filexfer-02
+const (
+	sftpInit     = 1
+	sftpVersion  = 2
+	sftpClose    = 4
+	sftpOpenDir  = 11
+	sftpReadDir  = 12
+	sftpStat     = 17
+	sftpStatus   = 101
+	sftpHandle   = 102
+	sftpName     = 104
+	sftpAttrs    = 105
+	sftpEOF      = 1 // Status code ending a directory listing
+	sftpMaxEntry = 10000
+
+	attrSize        = 0x1
+	attrUIDGID      = 0x2
+	attrPermissions = 0x4
+	attrTimes       = 0x8
+	attrExtended    = 0x80000000
+	modeDir         = 0o040000
+	modeType        = 0o170000
+)
+
+// sftpNewest is localNewest over the host's SFTP subsystem, which works
+// for SFTP-only accounts that can't run commands. It speaks just enough of
+// the protocol to stat a path and list a directory.
+func sftpNewest(host string, timeout time.Duration, opts FileOptions) (string, time.Time, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	args := append([]string{"-s"}, sshArgs(host, timeout, opts.User, opts.KeyFile, opts.Port)...)
+	var stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, "ssh", append(args, "sftp")...)
+	cmd.Stderr = &stderr
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return "", time.Time{}, fmt.Errorf("sftp: %w", err)
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return "", time.Time{}, fmt.Errorf("sftp: %w", err)
+	}
+	if err := cmd.Start(); err != nil {
+		return "", time.Time{}, fmt.Errorf("sftp: %w", err)
+	}
+	c := &sftpConn{w: stdin, r: bufio.NewReader(stdout)}
+	name, mod, err := c.newest(opts.Path)
+	stdin.Close()
+	_ = cmd.Wait()
+
+	switch {
+	case ctx.Err() != nil:
+		return "", time.Time{}, fmt.Errorf("sftp: timed out after %v", timeout)
+	case cmd.ProcessState != nil && cmd.ProcessState.ExitCode() == sshFailure:
+		// ssh couldn't connect or log in; its own diagnostics say why
+		if msg := lastLine(truncate(stderr.String(), MaxSSHOutput)); msg != "" {
+			return "", time.Time{}, errors.New(msg)
+		}
+		return "", time.Time{}, errors.New("sftp: connection closed")
+	}
+	return name, mod, err
+}
+
+// sftpConn is a client session over an ssh subsystem's stdin and stdout
+type sftpConn struct {
+	w  io.Writer
+	r  *bufio.Reader
+	id uint32
+}
+
+// sftpFile is what a check needs from a file's attributes
+type sftpFile struct {
+	dir   bool
+	mtime time.Time
+}
+
+func (c *sftpConn) newest(target string) (string, time.Time, error) {
+	if err := c.send(sftpInit, binary.BigEndian.AppendUint32(nil, 3)); err != nil {
+		return "", time.Time{}, err
+	}
+	if typ, _, err := c.recv(); err != nil {
+		return "", time.Time{}, err
+	} else if typ != sftpVersion {
+		return "", time.Time{}, fmt.Errorf("sftp: unexpected reply %d to init", typ)
+	}
+
+	typ, body, err := c.request(sftpStat, sftpString(nil, target))
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	if typ != sftpAttrs {
+		return "", time.Time{}, sftpError(target, typ, body)
+	}
+	f, _, err := sftpReadAttrs(body)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	if !f.dir {
+		return path.Base(target), f.mtime, nil
+	}
+
+	typ, body, err = c.request(sftpOpenDir, sftpString(nil, target))
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	if typ != sftpHandle {
+		return "", time.Time{}, sftpError(target, typ, body)
+	}
+	handle, _, ok := sftpReadString(body)
+	if !ok {
+		return "", time.Time{}, errors.New("sftp: bad handle")
+	}
+	defer c.request(sftpClose, sftpString(nil, handle))
+
+	var name string
+	var newest time.Time
+	for seen := 0; seen < sftpMaxEntry; {
+		typ, body, err = c.request(sftpReadDir, sftpString(nil, handle))
+		if err != nil {
+			return "", time.Time{}, err
+		}
+		if typ == sftpStatus && len(body) >= 4 && binary.BigEndian.Uint32(body) == sftpEOF {
+			break
+		}
+		if typ != sftpName || len(body) < 4 {
+			return "", time.Time{}, sftpError(target, typ, body)
+		}
+		n := binary.BigEndian.Uint32(body)
+		body = body[4:]
+		for range n {
+			fname, rest, ok := sftpReadString(body)
+			if !ok {
+				return "", time.Time{}, errors.New("sftp: bad directory listing")
+			}
+			if _, rest, ok = sftpReadString(rest); !ok { // Long name, for humans
+				return "", time.Time{}, errors.New("sftp: bad directory listing")
+			}
+			f, rest, err := sftpReadAttrs(rest)
+			if err != nil {
+				return "", time.Time{}, err
+			}
+			body = rest
+			seen++
+			if fname != "." && fname != ".." && f.mtime.After(newest) {
+				name, newest = fname, f.mtime
+			}
+		}
+	}
+	if name == "" {
+		return "", time.Time{}, fmt.Errorf("%s is empty", target)
+	}
+	return name, newest, nil
+}
+
+// request sends a packet with the next request ID and returns the reply,
+// without the ID
+func (c *sftpConn) request(typ byte, payload []byte) (byte, []byte, error) {
+	c.id++
+	if err := c.send(typ, append(binary.BigEndian.AppendUint32(nil, c.id), payload...)); err != nil {
+		return 0, nil, err
+	}
+	rtyp, body, err := c.recv()
+	if err != nil {
+		return 0, nil, err
+	}
+	if len(body) < 4 || binary.BigEndian.Uint32(body) != c.id {
+		return 0, nil, errors.New("sftp: reply out of order")
+	}
+	return rtyp, body[4:], nil
+}
+
+func (c *sftpConn) send(typ byte, payload []byte) error {
+	pkt := binary.BigEndian.AppendUint32(nil, uint32(len(payload)+1))
+	pkt = append(append(pkt, typ), payload...)
+	_, err := c.w.Write(pkt)
+	return err
+}
+
+func (c *sftpConn) recv() (byte, []byte, error) {
+	var hdr [4]byte
+	if _, err := io.ReadFull(c.r, hdr[:]); err != nil {
+		return 0, nil, err
+	}
+	n := binary.BigEndian.Uint32(hdr[:])
+	if n == 0 || n > 1<<20 {
+		return 0, nil, fmt.Errorf("sftp: bad packet length %d", n)
+	}
+	pkt := make([]byte, n)
+	if _, err := io.ReadFull(c.r, pkt); err != nil {
+		return 0, nil, err
+	}
+	return pkt[0], pkt[1:], nil
+}
+
+// sftpError describes a status reply, e.g. "/backups: No such file"
+func sftpError(path string, typ byte, body []byte) error {
+	if typ != sftpStatus || len(body) < 4 {
+		return fmt.Errorf("sftp: unexpected reply %d", typ)
+	}
+	msg, _, ok := sftpReadString(body[4:])
+	if !ok || msg == "" {
+		msg = fmt.Sprintf("status %d", binary.BigEndian.Uint32(body))
+	}
+	return fmt.Errorf("%s: %s", path, msg)
+}
+
+func sftpString(b []byte, s string) []byte {
+	return append(binary.BigEndian.AppendUint32(b, uint32(len(s))), s...)
+}
+
+func sftpReadString(b []byte) (string, []byte, bool) {
+	if len(b) < 4 {
+		return "", nil, false
+	}
+	n := binary.BigEndian.Uint32(b)
+	if uint32(len(b)-4) < n {
+		return "", nil, false
+	}
+	return string(b[4 : 4+n]), b[4+n:], true
+}
+
+// sftpReadAttrs reads the type and modification time from an ATTRS block
+func sftpReadAttrs(b []byte) (sftpFile, []byte, error) {
+	var f sftpFile
+	bad := errors.New("sftp: bad file attributes")
+	if len(b) < 4 {
+		return f, nil, bad
+	}
+	flags := binary.BigEndian.Uint32(b)
+	b = b[4:]
+	skip := func(n int) bool {
+		if len(b) < n {
+			return false
+		}
+		b = b[n:]
+		return true
+	}
+	if flags&attrSize != 0 && !skip(8) {
+		return f, nil, bad
+	}
+	if flags&attrUIDGID != 0 && !skip(8) {
+		return f, nil, bad
+	}
+	if flags&attrPermissions != 0 {
+		if len(b) < 4 {
+			return f, nil, bad
+		}
+		f.dir = binary.BigEndian.Uint32(b)&modeType == modeDir
+		b = b[4:]
+	}
+	if flags&attrTimes != 0 {
+		if len(b) < 8 {
+			return f, nil, bad
+		}
+		f.mtime = time.Unix(int64(binary.BigEndian.Uint32(b[4:])), 0)
+		b = b[8:]
+	}
+	if flags&attrExtended != 0 {
+		if len(b) < 4 {
+			return f, nil, bad
+		}
+		n := binary.BigEndian.Uint32(b)
+		b = b[4:]
+		for range 2 * n {
+			var ok bool
+			if _, b, ok = sftpReadString(b); !ok {
+				return f, nil, bad
+			}
+		}
+	}
+	if flags&attrTimes == 0 {
+		return f, nil, errors.New("sftp: server sent no modification time")
+	}
+	return f, b, nil
+}
@@ -1,24 +1,173 @@
 package checks
 
 import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"crypto/tls"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net"
 	"net/http"
+	"net/url"
+	"strings"
 	"time"
 )
 
+const (
+	// DefaultMaxRedirects matches net/http's own redirect limit
+	DefaultMaxRedirects = 10
+	// MaxBodySnippet is how much of the response body HTTPGet keeps
+	MaxBodySnippet = 1024
+	// MaxContentBody is how much of the body content rules and hashing see
+	MaxContentBody = 4 << 20
+	// MaxBodySize is how much of the body HTTPGet reads to measure its size
+	MaxBodySize = 64 << 20
+)
+
 type HTTPResult struct {
-	Latency time.Duration
-	Code    int
-	Err     error
+	Latency    time.Duration
+	Code       int
+	Status     string        // Status line, e.g. "502 Bad Gateway"
+	Header     http.Header   // Response headers
+	Body       string        // Up to MaxBodySnippet bytes of the response body
+	Truncated  bool          // Body was longer than MaxBodySnippet
+	Hash       string        // Hex SHA-256 of the body, if HTTPOptions.WatchContent is set
+	ContentErr error         // Body broke a MustContain or MustNotContain rule
+	Addr       string        // IP address the final response came from, or the last one tried
+	Timing     HTTPTiming    // Where the final request's time went
+	TLS        *TLSInfo      // The final response's connection, for https
+	Size       int64         // Body bytes read, up to MaxBodySize
+	Download   time.Duration // Time reading the body took, after the headers
+	Err        error
 }
 
-func HTTPGet(url string, timeout time.Duration) HTTPResult {
-	client := &http.Client{Timeout: timeout}
-	start := time.Now()
-	resp, err := client.Get(url)
+// HTTPOptions controls optional client behaviour for an HTTP check. The zero
+// value follows up to DefaultMaxRedirects redirects, connects directly and
+// verifies TLS certificates.
+type HTTPOptions struct {
+	NoFollowRedirects  bool   // Report the redirect response itself instead of following it
+	MaxRedirects       int    // Redirect hops to follow; 0 means DefaultMaxRedirects
+	Proxy              string // Proxy URL, e.g. http://proxy:3128 or socks5://proxy:1080
+	InsecureSkipVerify bool   // Accept self-signed or otherwise invalid certificates
+	MustContain        string // Text the body must contain
+	MustNotContain     string // Text the body must not contain, e.g. "error"
+	WatchContent       bool   // Hash the body so changes can be detected
+	DialOptions               // Address family and source address
+
+	Identity ProbeIdentity // User-Agent and probe ID header, set per run
+}
+
+// watchesContent reports whether the whole body, not just a snippet, is needed
+func (o HTTPOptions) watchesContent() bool {
+	return o.MustContain != "" || o.MustNotContain != "" || o.WatchContent
+}
+
+func HTTPGet(url string, timeout time.Duration, opts HTTPOptions) HTTPResult {
+	client, err := newHTTPClient(timeout, opts)
 	if err != nil {
 		return HTTPResult{Err: err}
 	}
+	var addr tracedAddr
+	var timer httpTimer
+	req, err := http.NewRequestWithContext(timer.context(addr.context(context.Background())), http.MethodGet, url, nil)
+	if err != nil {
+		return HTTPResult{Err: err}
+	}
+	opts.Identity.setHeaders(req.Header)
+	// A fresh connection each run, so every phase is timed rather than only
+	// the first run's
+	req.Close = true
+	start := time.Now()
+	resp, err := client.Do(req)
+	if err != nil {
+		return HTTPResult{Addr: addr.get(), Timing: timer.get(), Err: err}
+	}
 	defer resp.Body.Close()
 	lat := time.Since(start)
-	return HTTPResult{Latency: lat, Code: resp.StatusCode}
+	res := HTTPResult{Latency: lat, Code: resp.StatusCode, Status: resp.Status, Header: resp.Header, Addr: addr.get(), Timing: timer.get()}
+	res.TLS = newTLSInfo(resp.TLS, resp.Request.URL.Hostname(), opts.InsecureSkipVerify)
+	// Keep the start of the body so failures can show what the server said.
+	// Read errors are ignored; the status code and content rules decide the check.
+	limit := int64(MaxBodySnippet + 1)
+	if opts.watchesContent() {
+		limit = MaxContentBody
+	}
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, limit))
+	// The rest is only counted
+	rest, _ := io.Copy(io.Discard, io.LimitReader(resp.Body, MaxBodySize-int64(len(body))))
+	res.Size = int64(len(body)) + rest
+	res.Download = time.Since(start) - lat
+	if opts.watchesContent() {
+		res.ContentErr = opts.checkContent(body)
+		if opts.WatchContent {
+			sum := sha256.Sum256(body)
+			res.Hash = hex.EncodeToString(sum[:])
+		}
+	}
+	if len(body) > MaxBodySnippet {
+		body = body[:MaxBodySnippet]
+		res.Truncated = true
+	}
+	res.Body = strings.ToValidUTF8(string(body), "\uFFFD")
+	return res
+}
+
+// checkContent applies the MustContain and MustNotContain rules to body
+func (o HTTPOptions) checkContent(body []byte) error {
+	if o.MustContain != "" && !bytes.Contains(body, []byte(o.MustContain)) {
+		return fmt.Errorf("body doesn't contain %q", o.MustContain)
+	}
+	if o.MustNotContain != "" && bytes.Contains(body, []byte(o.MustNotContain)) {
+		return fmt.Errorf("body contains %q", o.MustNotContain)
+	}
+	return nil
+}
+
+// newHTTPClient builds a client for one check. Checks without options share
+// http.DefaultTransport so connections are pooled as before.
+func newHTTPClient(timeout time.Duration, opts HTTPOptions) (*http.Client, error) {
+	client := &http.Client{Timeout: timeout}
+
+	maxRedirects := opts.MaxRedirects
+	if maxRedirects <= 0 {
+		maxRedirects = DefaultMaxRedirects
+	}
+	client.CheckRedirect = func(req *http.Request, via []*http.Request) error {
+		if opts.NoFollowRedirects {
+			return http.ErrUseLastResponse
+		}
+		if len(via) > maxRedirects {
+			return fmt.Errorf("stopped after %d redirects", maxRedirects)
+		}
+		return nil
+	}
+
+	if opts.Proxy == "" && !opts.InsecureSkipVerify && opts.DialOptions == (DialOptions{}) {
+		return client, nil
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if opts.DialOptions != (DialOptions{}) {
+		d, err := opts.dialer(timeout)
+		if err != nil {
+			return nil, err
+		}
+		network := opts.network("tcp")
+		transport.DialContext = func(ctx context.Context, _, addr string) (net.Conn, error) {
+			return d.DialContext(ctx, network, addr)
+		}
+	}
+	if opts.Proxy != "" {
+		proxyURL, err := url.Parse(opts.Proxy)
+		if err != nil {
+			return nil, fmt.Errorf("invalid proxy URL: %w", err)
+		}
+		transport.Proxy = http.ProxyURL(proxyURL)
+	}
+	if opts.InsecureSkipVerify {
+		transport.TLSClientConfig = &tls.Config{InsecureSkipVerify: true}
+	}
+	client.Transport = transport
+	return client, nil
 }
@@ -0,0 +1,126 @@
+package checks
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestHTTPGet(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/ok", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Probe", r.Header.Get("User-Agent"))
+		_, _ = w.Write([]byte("all systems go"))
+	})
+	mux.HandleFunc("/down", func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "database unavailable", http.StatusBadGateway)
+	})
+	mux.HandleFunc("/moved", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/ok", http.StatusFound)
+	})
+	mux.HandleFunc("/big", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(strings.Repeat("x", MaxBodySnippet*3)))
+	})
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	tests := []struct {
+		name      string
+		path      string
+		opts      HTTPOptions
+		code      int
+		body      string
+		truncated bool
+		size      int64
+	}{
+		{name: "ok", path: "/ok", code: 200, body: "all systems go", size: 14},
+		{name: "error status", path: "/down", code: 502, body: "database unavailable\n", size: 21},
+		{name: "redirect followed", path: "/moved", code: 200, body: "all systems go", size: 14},
+		{name: "redirect reported", path: "/moved", opts: HTTPOptions{NoFollowRedirects: true}, code: 302},
+		{name: "long body", path: "/big", code: 200, body: strings.Repeat("x", MaxBodySnippet), truncated: true, size: MaxBodySnippet * 3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res := HTTPGet(srv.URL+tt.path, 2*time.Second, tt.opts)
+			if res.Err != nil {
+				t.Fatalf("HTTPGet: %v", res.Err)
+			}
+			if res.Code != tt.code {
+				t.Errorf("Code = %d, want %d", res.Code, tt.code)
+			}
+			if tt.body != "" && res.Body != tt.body {
+				t.Errorf("Body = %q, want %q", res.Body, tt.body)
+			}
+			if res.Truncated != tt.truncated {
+				t.Errorf("Truncated = %v, want %v", res.Truncated, tt.truncated)
+			}
+			if tt.size != 0 && res.Size != tt.size {
+				t.Errorf("Size = %d, want %d", res.Size, tt.size)
+			}
+			if res.Addr != "127.0.0.1" {
+				t.Errorf("Addr = %q, want 127.0.0.1", res.Addr)
+			}
+			if res.Latency <= 0 {
+				t.Errorf("Latency = %v, want it measured", res.Latency)
+			}
+		})
+	}
+}
+
+func TestHTTPGetContentRules(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{"status":"degraded","error":"disk full"}`))
+	}))
+	defer srv.Close()
+
+	tests := []struct {
+		opts    HTTPOptions
+		wantErr string
+	}{
+		{HTTPOptions{MustContain: `"status"`}, ""},
+		{HTTPOptions{MustContain: `"status":"ok"`}, `body doesn't contain "\"status\":\"ok\""`},
+		{HTTPOptions{MustNotContain: "error"}, `body contains "error"`},
+	}
+	for _, tt := range tests {
+		res := HTTPGet(srv.URL, 2*time.Second, tt.opts)
+		got := ""
+		if res.ContentErr != nil {
+			got = res.ContentErr.Error()
+		}
+		if got != tt.wantErr {
+			t.Errorf("%+v: ContentErr = %q, want %q", tt.opts, got, tt.wantErr)
+		}
+	}
+
+	a := HTTPGet(srv.URL, 2*time.Second, HTTPOptions{WatchContent: true})
+	b := HTTPGet(srv.URL, 2*time.Second, HTTPOptions{WatchContent: true})
+	if a.Hash == "" || a.Hash != b.Hash {
+		t.Errorf("hashes of the same body = %q and %q, want equal and set", a.Hash, b.Hash)
+	}
+}
+
+func TestHTTPGetTimeout(t *testing.T) {
+	release := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+	}))
+	defer srv.Close()
+	defer close(release)
+
+	res := HTTPGet(srv.URL, 50*time.Millisecond, HTTPOptions{})
+	if res.Err == nil {
+		t.Fatalf("HTTPGet of a server that never answers = code %d, want a timeout", res.Code)
+	}
+}
+
+func TestHTTPGetRefused(t *testing.T) {
+	srv := httptest.NewServer(http.NotFoundHandler())
+	url := srv.URL
+	srv.Close()
+
+	if res := HTTPGet(url, time.Second, HTTPOptions{}); res.Err == nil {
+		t.Errorf("HTTPGet of a closed port = code %d, want an error", res.Code)
+	}
+}
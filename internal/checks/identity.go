@@ -0,0 +1,36 @@
+package checks
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+)
+
+// DefaultUserAgent is sent by http and websocket probes unless configured
+// otherwise, so target logs can tell probes from visitors
+const DefaultUserAgent = "POKE443 health check"
+
+// ProbeIdentity is how an http or websocket probe identifies itself to the
+// target
+type ProbeIdentity struct {
+	UserAgent string // Sent as the User-Agent header; empty for Go's default
+	Header    string // Header carrying ID, e.g. "X-Probe-ID"; empty sends none
+	ID        string // Unique to this probe; see NewProbeID
+}
+
+// NewProbeID returns a random ID for one probe
+func NewProbeID() string {
+	b := make([]byte, 8)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// setHeaders adds the identity to a request's headers
+func (p ProbeIdentity) setHeaders(h http.Header) {
+	if p.UserAgent != "" {
+		h.Set("User-Agent", p.UserAgent)
+	}
+	if p.Header != "" && p.ID != "" {
+		h.Set(p.Header, p.ID)
+	}
+}
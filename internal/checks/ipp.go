@@ -0,0 +1,228 @@
+package checks
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// DefaultIPPPort is where ipp:// and ipps:// URLs without a port are served
+const DefaultIPPPort = 631
+
+// IPP tags and codes used by Get-Printer-Attributes, from RFC 8010 and 8011
+const (
+	ippOperationTag   = 0x01
+	ippEndTag         = 0x03
+	ippInteger        = 0x21
+	ippEnum           = 0x23
+	ippKeyword        = 0x44
+	ippURI            = 0x45
+	ippCharset        = 0x47
+	ippNaturalLang    = 0x48
+	ippGetPrinterAttr = 0x000b
+	ippMaxResponse    = 1 << 20
+)
+
+// IPPOptions controls an ipp check. The zero value verifies certificates.
+type IPPOptions struct {
+	InsecureSkipVerify bool // Accept the self-signed certificates most printers have, for ipps
+
+	Identity ProbeIdentity // User-Agent and probe ID header, set per run
+}
+
+type IPPResult struct {
+	Latency time.Duration
+	State   string   // idle, processing or stopped
+	Reasons []string // printer-state-reasons other than "none", e.g. "toner-low-warning"
+	Message string   // printer-state-message, e.g. "Paper jam"
+	Marker  string   // The supply with the lowest level, e.g. "Black Toner"; empty if none is reported
+	Level   int      // Marker's level in percent
+	Addr    string   // IP address the response came from, or the last one tried
+	TLS     *TLSInfo
+	OK      bool // Not stopped and no "-error" reasons
+	Err     error
+}
+
+// IPPStatus asks the printer at rawURL, e.g. ipp://printer.local/ipp/print,
+// for its state with a Get-Printer-Attributes request. The printer passes
+// unless it is stopped or reports a reason ending in "-error", such as
+// media-jam-error; warnings such as toner-low-warning are reported but pass.
+func IPPStatus(rawURL string, timeout time.Duration, opts IPPOptions) IPPResult {
+	u, printerURI, err := ippURLs(rawURL)
+	if err != nil {
+		return IPPResult{Err: err}
+	}
+	client, err := newHTTPClient(timeout, HTTPOptions{NoFollowRedirects: true, InsecureSkipVerify: opts.InsecureSkipVerify})
+	if err != nil {
+		return IPPResult{Err: err}
+	}
+	var addr tracedAddr
+	req, err := http.NewRequestWithContext(addr.context(context.Background()), http.MethodPost, u.String(), bytes.NewReader(ippRequest(printerURI)))
+	if err != nil {
+		return IPPResult{Err: err}
+	}
+	req.Header.Set("Content-Type", "application/ipp")
+	opts.Identity.setHeaders(req.Header)
+	req.Close = true
+	start := time.Now()
+	resp, err := client.Do(req)
+	if err != nil {
+		return IPPResult{Addr: addr.get(), Err: err}
+	}
+	defer resp.Body.Close()
+	res := IPPResult{Addr: addr.get()}
+	res.TLS = newTLSInfo(resp.TLS, u.Hostname(), opts.InsecureSkipVerify)
+	if resp.StatusCode != http.StatusOK {
+		res.Latency = time.Since(start)
+		res.Err = fmt.Errorf("status %s", resp.Status)
+		return res
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, ippMaxResponse))
+	res.Latency = time.Since(start)
+	if err != nil {
+		res.Err = err
+		return res
+	}
+	if res.Err = res.parse(body); res.Err != nil {
+		return res
+	}
+	res.OK = res.State != "stopped"
+	for _, r := range res.Reasons {
+		if strings.HasSuffix(r, "-error") {
+			res.OK = false
+		}
+	}
+	return res
+}
+
+// ippURLs returns the http(s) URL to post to and the ipp(s) URI naming the
+// printer in the request
+func ippURLs(rawURL string) (*url.URL, string, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil || u.Host == "" {
+		return nil, "", fmt.Errorf("invalid printer URL %q", rawURL)
+	}
+	post, uri := *u, *u
+	switch u.Scheme {
+	case "ipp", "ipps":
+		if u.Port() == "" {
+			post.Host = net.JoinHostPort(u.Hostname(), fmt.Sprint(DefaultIPPPort))
+		}
+		post.Scheme = "http"
+		if u.Scheme == "ipps" {
+			post.Scheme = "https"
+		}
+	case "http":
+		uri.Scheme = "ipp"
+	case "https":
+		uri.Scheme = "ipps"
+	default:
+		return nil, "", fmt.Errorf("invalid printer URL %q: want ipp://, ipps://, http:// or https://", rawURL)
+	}
+	return &post, uri.String(), nil
+}
+
+// ippRequest encodes a Get-Printer-Attributes request for the attributes a
+// check reports
+func ippRequest(printerURI string) []byte {
+	b := []byte{2, 0} // IPP 2.0
+	b = binary.BigEndian.AppendUint16(b, ippGetPrinterAttr)
+	b = binary.BigEndian.AppendUint32(b, 1) // Request ID
+	b = append(b, ippOperationTag)
+	b = ippAttr(b, ippCharset, "attributes-charset", "utf-8")
+	b = ippAttr(b, ippNaturalLang, "attributes-natural-language", "en")
+	b = ippAttr(b, ippURI, "printer-uri", printerURI)
+	for i, a := range []string{"printer-state", "printer-state-reasons", "printer-state-message", "marker-names", "marker-levels"} {
+		name := ""
+		if i == 0 {
+			name = "requested-attributes" // Later values extend the set
+		}
+		b = ippAttr(b, ippKeyword, name, a)
+	}
+	return append(b, ippEndTag)
+}
+
+func ippAttr(b []byte, tag byte, name, value string) []byte {
+	b = append(b, tag)
+	b = binary.BigEndian.AppendUint16(b, uint16(len(name)))
+	b = append(b, name...)
+	b = binary.BigEndian.AppendUint16(b, uint16(len(value)))
+	return append(b, value...)
+}
+
+// parse reads the printer's state from a Get-Printer-Attributes response
+func (r *IPPResult) parse(b []byte) error {
+	bad := errors.New("ipp: malformed response")
+	if len(b) < 8 {
+		return bad
+	}
+	if status := binary.BigEndian.Uint16(b[2:]); status > 0xff {
+		return fmt.Errorf("ipp: printer refused the request (status 0x%04x)", status)
+	}
+	b = b[8:]
+	var name string
+	var markers []string
+	var levels []int
+	for len(b) > 0 {
+		tag := b[0]
+		b = b[1:]
+		if tag == ippEndTag {
+			break
+		}
+		if tag < 0x10 {
+			continue // Start of the next attribute group
+		}
+		if len(b) < 2 {
+			return bad
+		}
+		n := int(binary.BigEndian.Uint16(b))
+		if len(b) < 2+n+2 {
+			return bad
+		}
+		if n > 0 { // An empty name adds a value to the previous attribute
+			name = string(b[2 : 2+n])
+		}
+		b = b[2+n:]
+		n = int(binary.BigEndian.Uint16(b))
+		if len(b) < 2+n {
+			return bad
+		}
+		v := b[2 : 2+n]
+		b = b[2+n:]
+
+		switch {
+		case name == "printer-state" && tag == ippEnum && len(v) == 4:
+			r.State = ippStates[binary.BigEndian.Uint32(v)]
+		case name == "printer-state-reasons":
+			if s := string(v); s != "none" {
+				r.Reasons = append(r.Reasons, s)
+			}
+		case name == "printer-state-message":
+			r.Message = strings.TrimSpace(string(v))
+		case name == "marker-names":
+			markers = append(markers, string(v))
+		case name == "marker-levels" && tag == ippInteger && len(v) == 4:
+			levels = append(levels, int(int32(binary.BigEndian.Uint32(v))))
+		}
+	}
+	if r.State == "" {
+		return errors.New("ipp: printer sent no printer-state")
+	}
+	// Negative levels mean unknown or unavailable
+	for i, l := range levels {
+		if i < len(markers) && l >= 0 && (r.Marker == "" || l < r.Level) {
+			r.Marker, r.Level = markers[i], l
+		}
+	}
+	return nil
+}
+
+var ippStates = map[uint32]string{3: "idle", 4: "processing", 5: "stopped"}
@@ -0,0 +1,59 @@
+package checks
+
+import (
+	"math"
+	"time"
+)
+
+// MaxPingCount limits the echo requests one ping run sends
+const MaxPingCount = 20
+
+// PingInterval is the gap between the echo requests of one run
+const PingInterval = 200 * time.Millisecond
+
+// count is the echo requests each run sends
+func (o PingOptions) count() int {
+	return min(max(o.Count, 1), MaxPingCount)
+}
+
+// runTimeout allows a run of several echo requests to take longer than a
+// single one would, by the intervals between them
+func (o PingOptions) runTimeout(timeout time.Duration) time.Duration {
+	return timeout + time.Duration(o.count()-1)*PingInterval
+}
+
+// rttStats summarises the round trip times of a run's replies: their mean,
+// their standard deviation, and their jitter, the mean difference between
+// consecutive replies (RFC 3550's, without the smoothing). Both spreads are
+// zero with fewer than two replies.
+func rttStats(rtts []time.Duration) (avg, stddev, jitter time.Duration) {
+	if len(rtts) == 0 {
+		return 0, 0, 0
+	}
+	var sum time.Duration
+	for _, rtt := range rtts {
+		sum += rtt
+	}
+	avg = sum / time.Duration(len(rtts))
+	if len(rtts) < 2 {
+		return avg, 0, 0
+	}
+	var sq float64
+	var diffs time.Duration
+	for i, rtt := range rtts {
+		d := float64(rtt - avg)
+		sq += d * d
+		if i > 0 {
+			diffs += (rtt - rtts[i-1]).Abs()
+		}
+	}
+	stddev = time.Duration(math.Sqrt(sq / float64(len(rtts))))
+	jitter = diffs / time.Duration(len(rtts)-1)
+	return avg, stddev, jitter
+}
+
+// pingResult fills in a run's figures from the replies it got
+func pingResult(sent int, rtts []time.Duration, method, addr string) PingResult {
+	avg, stddev, jitter := rttStats(rtts)
+	return PingResult{OK: len(rtts) > 0, Latency: avg, StdDev: stddev, Jitter: jitter, PacketsTx: sent, PacketsRx: len(rtts), Method: method, Addr: addr}
+}
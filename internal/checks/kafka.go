@@ -0,0 +1,233 @@
+package checks
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"time"
+)
+
+// DefaultKafkaPort is where Kafka brokers listen unless configured otherwise
+const DefaultKafkaPort = 9092
+
+// Kafka protocol values used by a metadata request
+const (
+	kafkaMetadata       = 3
+	kafkaMetadataV      = 4 // Kafka 1.0 and later, including 4.x, which dropped v0-v3
+	kafkaClientID       = "POKE443"
+	kafkaMaxResponse    = 16 << 20
+	kafkaUnknownTopic   = 3
+	kafkaNoLeader       = 5
+	kafkaTopicForbidden = 29
+)
+
+// KafkaOptions says which broker port a kafka check connects to and which
+// topic, if any, it looks at
+type KafkaOptions struct {
+	Topic string // Topic that must exist with a leader for every partition; empty checks every topic
+	Port  int    // 0 means DefaultKafkaPort
+}
+
+type KafkaResult struct {
+	Latency         time.Duration
+	Brokers         int    // Brokers in the cluster
+	ClusterID       string // Empty for clusters too old to report one
+	Topics          int
+	Partitions      int
+	Leaderless      int // Partitions with no leader, which can't be read or written
+	UnderReplicated int // Partitions with fewer in-sync replicas than replicas
+	Addr            string
+	OK              bool // The broker answered and every partition has a leader
+	Err             error
+}
+
+// KafkaMetadata sends a metadata request to the broker on host, the request
+// every Kafka client starts with. It passes when the broker answers and every
+// partition, of opts.Topic or of all topics, has a leader. Only plaintext
+// listeners without SASL are supported.
+func KafkaMetadata(host string, timeout time.Duration, opts KafkaOptions) KafkaResult {
+	port := opts.Port
+	if port == 0 {
+		port = DefaultKafkaPort
+	}
+	start := time.Now()
+	conn, err := net.DialTimeout("tcp", net.JoinHostPort(host, strconv.Itoa(port)), timeout)
+	if err != nil {
+		return KafkaResult{Addr: dialedIP(err), Err: err}
+	}
+	defer conn.Close()
+	_ = conn.SetDeadline(start.Add(timeout))
+	res := KafkaResult{Addr: remoteIP(conn.RemoteAddr())}
+	if _, err := conn.Write(kafkaMetadataRequest(opts.Topic)); err != nil {
+		res.Err = err
+		return res
+	}
+	var size [4]byte
+	if _, err := io.ReadFull(conn, size[:]); err != nil {
+		res.Latency = time.Since(start)
+		if errors.Is(err, io.EOF) {
+			err = errors.New("kafka: broker closed the connection; the listener may need TLS or SASL, or the broker is older than Kafka 1.0")
+		}
+		res.Err = err
+		return res
+	}
+	n := binary.BigEndian.Uint32(size[:])
+	if n < 4 || n > kafkaMaxResponse {
+		res.Latency = time.Since(start)
+		res.Err = errors.New("kafka: not a Kafka broker")
+		return res
+	}
+	body := make([]byte, n)
+	_, err = io.ReadFull(conn, body)
+	res.Latency = time.Since(start)
+	if err != nil {
+		res.Err = err
+		return res
+	}
+	if res.Err = res.parse(body, opts.Topic); res.Err != nil {
+		return res
+	}
+	if res.Leaderless > 0 {
+		res.Err = fmt.Errorf("%d of %d partitions have no leader", res.Leaderless, res.Partitions)
+	}
+	res.OK = res.Err == nil
+	return res
+}
+
+// kafkaMetadataRequest encodes a metadata request for topic, or for every
+// topic when it is empty
+func kafkaMetadataRequest(topic string) []byte {
+	b := make([]byte, 4) // Size, filled in below
+	b = binary.BigEndian.AppendUint16(b, kafkaMetadata)
+	b = binary.BigEndian.AppendUint16(b, kafkaMetadataV)
+	b = binary.BigEndian.AppendUint32(b, 1) // Correlation ID
+	b = kafkaString(b, kafkaClientID)
+	if topic == "" {
+		b = binary.BigEndian.AppendUint32(b, 0xffffffff) // Null: all topics
+	} else {
+		b = binary.BigEndian.AppendUint32(b, 1)
+		b = kafkaString(b, topic)
+	}
+	b = append(b, 0) // Don't create the topic
+	binary.BigEndian.PutUint32(b, uint32(len(b)-4))
+	return b
+}
+
+func kafkaString(b []byte, s string) []byte {
+	b = binary.BigEndian.AppendUint16(b, uint16(len(s)))
+	return append(b, s...)
+}
+
+// parse reads a metadata response, after its size, counting brokers, topics
+// and the partitions that are leaderless or under-replicated
+func (r *KafkaResult) parse(b []byte, topic string) error {
+	d := wireDecoder{b: b}
+	if d.int32() != 1 {
+		return errors.New("kafka: not a Kafka broker")
+	}
+	d.int32() // Throttle time
+	r.Brokers = d.count()
+	for range r.Brokers {
+		d.int32() // Node ID
+		d.string()
+		d.int32()  // Port
+		d.string() // Rack
+	}
+	r.ClusterID = d.string()
+	d.int32() // Controller ID
+	r.Topics = d.count()
+	for range r.Topics {
+		code := d.int16()
+		name := d.string()
+		d.int8() // Internal
+		switch code {
+		case 0, kafkaNoLeader:
+		case kafkaUnknownTopic:
+			return fmt.Errorf("no such topic %q", name)
+		case kafkaTopicForbidden:
+			return fmt.Errorf("not authorised to describe topic %q", name)
+		default:
+			return fmt.Errorf("kafka: topic %q: error code %d", name, code)
+		}
+		parts := d.count()
+		r.Partitions += parts
+		for range parts {
+			d.int16() // Error code
+			d.int32() // Partition
+			leader := d.int32()
+			replicas := d.count()
+			for range replicas {
+				d.int32()
+			}
+			isr := d.count()
+			for range isr {
+				d.int32()
+			}
+			if leader < 0 {
+				r.Leaderless++
+			}
+			if isr < replicas {
+				r.UnderReplicated++
+			}
+		}
+		if code == kafkaNoLeader && parts == 0 {
+			r.Leaderless++ // A topic still being created
+		}
+	}
+	if d.bad {
+		return errors.New("kafka: malformed metadata response")
+	}
+	if topic != "" && r.Topics == 0 {
+		return fmt.Errorf("no such topic %q", topic)
+	}
+	return nil
+}
+
+// wireDecoder reads the big-endian fields of Kafka and AMQP messages,
+// noting rather than returning a short read so a message can be parsed in
+// one pass
+type wireDecoder struct {
+	b   []byte
+	bad bool
+}
+
+func (d *wireDecoder) next(n int) []byte {
+	if d.bad || n < 0 || len(d.b) < n {
+		d.bad = true
+		return make([]byte, max(n, 0))
+	}
+	v := d.b[:n]
+	d.b = d.b[n:]
+	return v
+}
+
+func (d *wireDecoder) uint8() int   { return int(d.next(1)[0]) }
+func (d *wireDecoder) int8() int8   { return int8(d.next(1)[0]) }
+func (d *wireDecoder) int16() int16 { return int16(binary.BigEndian.Uint16(d.next(2))) }
+func (d *wireDecoder) int32() int32 { return int32(binary.BigEndian.Uint32(d.next(4))) }
+
+// string reads a string, where a negative length means null
+func (d *wireDecoder) string() string {
+	n := d.int16()
+	if n < 0 {
+		return ""
+	}
+	return string(d.next(int(n)))
+}
+
+// count reads an array length, treating null as empty and an impossible
+// length as malformed
+func (d *wireDecoder) count() int {
+	n := int(d.int32())
+	if n < 0 {
+		return 0
+	}
+	if n > len(d.b) {
+		d.bad = true
+		return 0
+	}
+	return n
+}
@@ -0,0 +1,365 @@
+package checks
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// kubeMaxResponse caps the body read from the API server; a node list for a
+// small cluster is well under it
+const kubeMaxResponse = 8 << 20
+
+// KubernetesOptions says how a kubernetes check reaches the API server and
+// what it looks at. Credentials come from Kubeconfig, or Token for a
+// service account; with neither, requests are anonymous.
+type KubernetesOptions struct {
+	Kubeconfig         string // Path to a kubeconfig file; its server is used when the check has no URL
+	Context            string // Kubeconfig context; empty means its current context
+	Token              string // Bearer token, e.g. a service account's; overrides the kubeconfig's
+	Deployment         string // namespace/name, or name in the context's namespace; empty checks node readiness
+	InsecureSkipVerify bool   // Accept a certificate no CA in the kubeconfig or system vouches for
+
+	Identity ProbeIdentity // User-Agent and probe ID header, set per run
+}
+
+type KubernetesResult struct {
+	Latency   time.Duration
+	Nodes     int      // Nodes in the cluster, when checking nodes
+	Ready     int      // Nodes that are Ready
+	NotReady  []string // Names of the nodes that aren't
+	Replicas  int      // Replicas the deployment wants
+	Available int      // Replicas that are available
+	Addr      string   // IP address the response came from, or the last one tried
+	TLS       *TLSInfo
+	OK        bool // Every node is ready, or the deployment has all its replicas available
+	Err       error
+}
+
+// KubernetesStatus asks the API server at apiURL, e.g. https://k3s:6443,
+// whether every node is Ready or, with opts.Deployment, whether that
+// deployment has as many available replicas as it wants. An empty apiURL
+// means the kubeconfig's server. The account needs list on nodes or get on
+// deployments; the built-in view role has both.
+func KubernetesStatus(apiURL string, timeout time.Duration, opts KubernetesOptions) KubernetesResult {
+	kc, err := loadKubeClient(apiURL, opts)
+	if err != nil {
+		return KubernetesResult{Err: err}
+	}
+	path := "/api/v1/nodes"
+	if opts.Deployment != "" {
+		ns, name, ok := strings.Cut(opts.Deployment, "/")
+		if !ok {
+			ns, name = kc.namespace, opts.Deployment
+		}
+		path = "/apis/apps/v1/namespaces/" + url.PathEscape(ns) + "/deployments/" + url.PathEscape(name)
+	}
+	u := strings.TrimSuffix(kc.server, "/") + path
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = kc.tls
+	client := &http.Client{
+		Timeout:       timeout,
+		Transport:     transport,
+		CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse },
+	}
+	var addr tracedAddr
+	req, err := http.NewRequestWithContext(addr.context(context.Background()), http.MethodGet, u, nil)
+	if err != nil {
+		return KubernetesResult{Err: err}
+	}
+	req.Header.Set("Accept", "application/json")
+	if kc.token != "" {
+		req.Header.Set("Authorization", "Bearer "+kc.token)
+	}
+	opts.Identity.setHeaders(req.Header)
+	req.Close = true
+	start := time.Now()
+	resp, err := client.Do(req)
+	if err != nil {
+		return KubernetesResult{Addr: addr.get(), Err: err}
+	}
+	defer resp.Body.Close()
+	res := KubernetesResult{Addr: addr.get()}
+	res.TLS = newTLSInfo(resp.TLS, req.URL.Hostname(), kc.tls.InsecureSkipVerify)
+	body, err := io.ReadAll(io.LimitReader(resp.Body, kubeMaxResponse))
+	res.Latency = time.Since(start)
+	if err != nil {
+		res.Err = err
+		return res
+	}
+	if res.Err = kubeStatusError(resp, body, opts.Deployment); res.Err != nil {
+		return res
+	}
+	if opts.Deployment != "" {
+		res.Err = res.parseDeployment(body)
+	} else {
+		res.Err = res.parseNodes(body)
+	}
+	return res
+}
+
+// kubeStatusError explains a failed request, using the message in the
+// Status object the API server sends with errors
+func kubeStatusError(resp *http.Response, body []byte, deployment string) error {
+	if resp.StatusCode == http.StatusOK {
+		return nil
+	}
+	var status struct {
+		Message string `json:"message"`
+	}
+	_ = json.Unmarshal(body, &status)
+	switch resp.StatusCode {
+	case http.StatusUnauthorized:
+		return errors.New("unauthorised (401); check the token or client certificate")
+	case http.StatusForbidden:
+		if status.Message != "" {
+			return fmt.Errorf("forbidden (403): %s", status.Message)
+		}
+		return errors.New("forbidden (403)")
+	case http.StatusNotFound:
+		if deployment != "" {
+			return fmt.Errorf("no such deployment %q", deployment)
+		}
+	}
+	if status.Message != "" {
+		return fmt.Errorf("status %s: %s", resp.Status, status.Message)
+	}
+	return fmt.Errorf("status %s", resp.Status)
+}
+
+func (r *KubernetesResult) parseNodes(body []byte) error {
+	var list struct {
+		Items []struct {
+			Metadata struct {
+				Name string `json:"name"`
+			} `json:"metadata"`
+			Status struct {
+				Conditions []struct {
+					Type   string `json:"type"`
+					Status string `json:"status"`
+				} `json:"conditions"`
+			} `json:"status"`
+		} `json:"items"`
+	}
+	if err := json.Unmarshal(body, &list); err != nil {
+		return fmt.Errorf("kubernetes: malformed node list: %w", err)
+	}
+	for _, n := range list.Items {
+		r.Nodes++
+		ready := false
+		for _, c := range n.Status.Conditions {
+			if c.Type == "Ready" {
+				ready = c.Status == "True"
+			}
+		}
+		if ready {
+			r.Ready++
+		} else {
+			r.NotReady = append(r.NotReady, n.Metadata.Name)
+		}
+	}
+	if r.Nodes == 0 {
+		return errors.New("the cluster has no nodes")
+	}
+	r.OK = r.Ready == r.Nodes
+	return nil
+}
+
+func (r *KubernetesResult) parseDeployment(body []byte) error {
+	var d struct {
+		Spec struct {
+			Replicas *int `json:"replicas"`
+		} `json:"spec"`
+		Status struct {
+			AvailableReplicas int `json:"availableReplicas"`
+		} `json:"status"`
+	}
+	if err := json.Unmarshal(body, &d); err != nil {
+		return fmt.Errorf("kubernetes: malformed deployment: %w", err)
+	}
+	r.Replicas = 1 // The API's default
+	if d.Spec.Replicas != nil {
+		r.Replicas = *d.Spec.Replicas
+	}
+	r.Available = d.Status.AvailableReplicas
+	r.OK = r.Available >= r.Replicas
+	return nil
+}
+
+// kubeClient is what a request needs from a kubeconfig and the options
+type kubeClient struct {
+	server    string
+	namespace string
+	token     string
+	tls       *tls.Config
+}
+
+func loadKubeClient(apiURL string, opts KubernetesOptions) (*kubeClient, error) {
+	kc := &kubeClient{server: apiURL, namespace: "default", token: opts.Token, tls: &tls.Config{InsecureSkipVerify: opts.InsecureSkipVerify}}
+	if opts.Kubeconfig != "" {
+		if err := kc.load(opts.Kubeconfig, opts.Context); err != nil {
+			return nil, err
+		}
+		if apiURL != "" {
+			kc.server = apiURL
+		}
+		if opts.Token != "" {
+			kc.token, kc.tls.Certificates = opts.Token, nil
+		}
+		kc.tls.InsecureSkipVerify = kc.tls.InsecureSkipVerify || opts.InsecureSkipVerify
+	}
+	u, err := url.Parse(kc.server)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, fmt.Errorf("invalid API server URL %q", kc.server)
+	}
+	return kc, nil
+}
+
+// kubeconfig is the part of a kubeconfig file a check reads
+type kubeconfig struct {
+	CurrentContext string        `yaml:"current-context"`
+	Clusters       []kubeCluster `yaml:"clusters"`
+	Contexts       []kubeContext `yaml:"contexts"`
+	Users          []kubeUser    `yaml:"users"`
+}
+
+type kubeCluster struct {
+	Name    string `yaml:"name"`
+	Cluster struct {
+		Server     string `yaml:"server"`
+		CA         string `yaml:"certificate-authority"`
+		CAData     string `yaml:"certificate-authority-data"`
+		Insecure   bool   `yaml:"insecure-skip-tls-verify"`
+		ServerName string `yaml:"tls-server-name"`
+	} `yaml:"cluster"`
+}
+
+type kubeContext struct {
+	Name    string `yaml:"name"`
+	Context struct {
+		Cluster   string `yaml:"cluster"`
+		User      string `yaml:"user"`
+		Namespace string `yaml:"namespace"`
+	} `yaml:"context"`
+}
+
+type kubeUser struct {
+	Name string `yaml:"name"`
+	User struct {
+		Token        string    `yaml:"token"`
+		TokenFile    string    `yaml:"tokenFile"`
+		Cert         string    `yaml:"client-certificate"`
+		CertData     string    `yaml:"client-certificate-data"`
+		Key          string    `yaml:"client-key"`
+		KeyData      string    `yaml:"client-key-data"`
+		Exec         yaml.Node `yaml:"exec"`
+		AuthProvider yaml.Node `yaml:"auth-provider"`
+	} `yaml:"user"`
+}
+
+// load fills in the server, namespace and credentials of the named context
+// in the kubeconfig at path, or of its current context. Relative file paths
+// in it are relative to the file.
+func (kc *kubeClient) load(path, contextName string) error {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	var cfg kubeconfig
+	if err := yaml.Unmarshal(b, &cfg); err != nil {
+		return fmt.Errorf("kubeconfig %s: %w", path, err)
+	}
+	if contextName == "" {
+		contextName = cfg.CurrentContext
+	}
+	dir := filepath.Dir(path)
+	read := func(data, file string) ([]byte, error) {
+		if data != "" {
+			return base64.StdEncoding.DecodeString(data)
+		}
+		if file == "" {
+			return nil, nil
+		}
+		if !filepath.IsAbs(file) {
+			file = filepath.Join(dir, file)
+		}
+		return os.ReadFile(file)
+	}
+
+	i := slices.IndexFunc(cfg.Contexts, func(c kubeContext) bool { return c.Name == contextName })
+	if i < 0 {
+		return fmt.Errorf("kubeconfig %s has no context %q", path, contextName)
+	}
+	ctx := cfg.Contexts[i].Context
+	if ctx.Namespace != "" {
+		kc.namespace = ctx.Namespace
+	}
+
+	i = slices.IndexFunc(cfg.Clusters, func(c kubeCluster) bool { return c.Name == ctx.Cluster })
+	if i < 0 {
+		return fmt.Errorf("kubeconfig %s has no cluster %q", path, ctx.Cluster)
+	}
+	cluster := cfg.Clusters[i].Cluster
+	kc.server = cluster.Server
+	kc.tls.InsecureSkipVerify = cluster.Insecure
+	kc.tls.ServerName = cluster.ServerName
+	ca, err := read(cluster.CAData, cluster.CA)
+	if err != nil {
+		return fmt.Errorf("kubeconfig %s: certificate authority: %w", path, err)
+	}
+	if len(ca) > 0 {
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(ca) {
+			return fmt.Errorf("kubeconfig %s: no certificates in the certificate authority", path)
+		}
+		kc.tls.RootCAs = pool
+	}
+
+	i = slices.IndexFunc(cfg.Users, func(u kubeUser) bool { return u.Name == ctx.User })
+	if i < 0 {
+		return nil // Anonymous
+	}
+	user := cfg.Users[i].User
+	if !user.Exec.IsZero() || !user.AuthProvider.IsZero() {
+		return fmt.Errorf("kubeconfig %s: user %q gets credentials from a plugin, which isn't supported; use a service account token", path, ctx.User)
+	}
+	kc.token = user.Token
+	if user.TokenFile != "" {
+		tok, err := read("", user.TokenFile)
+		if err != nil {
+			return fmt.Errorf("kubeconfig %s: token file: %w", path, err)
+		}
+		kc.token = strings.TrimSpace(string(tok))
+	}
+	cert, err := read(user.CertData, user.Cert)
+	if err != nil {
+		return fmt.Errorf("kubeconfig %s: client certificate: %w", path, err)
+	}
+	key, err := read(user.KeyData, user.Key)
+	if err != nil {
+		return fmt.Errorf("kubeconfig %s: client key: %w", path, err)
+	}
+	if len(cert) > 0 {
+		pair, err := tls.X509KeyPair(cert, key)
+		if err != nil {
+			return fmt.Errorf("kubeconfig %s: client certificate: %w", path, err)
+		}
+		kc.tls.Certificates = []tls.Certificate{pair}
+	}
+	return nil
+}
@@ -0,0 +1,210 @@
+package checks
+
+import (
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ProbeLimits caps how many probes run at once
+type ProbeLimits struct {
+	Global  int            // Across every target; 0 for no cap
+	PerHost int            // Against any one target; 0 for no cap
+	Hosts   map[string]int // Per-target caps by lowercase host name or IP, overriding PerHost
+}
+
+// Limited is a Checker that enforces ProbeLimits on another Checker, so a
+// small device with many checks isn't probed by all of them at once. Probes
+// over a limit wait for a running one to finish. Targets are the host being
+// pinged or dialled, or the host in an HTTP or WebSocket URL.
+type Limited struct {
+	next Checker
+
+	mu      sync.Mutex
+	cond    *sync.Cond
+	limits  ProbeLimits
+	running int
+	byHost  map[string]int
+}
+
+// NewLimited wraps next with no limits; see SetLimits
+func NewLimited(next Checker) *Limited {
+	l := &Limited{next: next, byHost: make(map[string]int)}
+	l.cond = sync.NewCond(&l.mu)
+	return l
+}
+
+// SetLimits replaces the limits. Probes already running are unaffected.
+func (l *Limited) SetLimits(limits ProbeLimits) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.limits = limits
+	// Waiting probes may fit under the new limits
+	l.cond.Broadcast()
+}
+
+// acquire waits for a free slot for a probe of host and returns the function
+// that frees it
+func (l *Limited) acquire(host string) func() {
+	host = strings.ToLower(host)
+	l.mu.Lock()
+	for !l.freeLocked(host) {
+		l.cond.Wait()
+	}
+	l.running++
+	l.byHost[host]++
+	l.mu.Unlock()
+
+	return func() {
+		l.mu.Lock()
+		defer l.mu.Unlock()
+		l.running--
+		if l.byHost[host]--; l.byHost[host] == 0 {
+			delete(l.byHost, host)
+		}
+		l.cond.Broadcast()
+	}
+}
+
+func (l *Limited) freeLocked(host string) bool {
+	if l.limits.Global > 0 && l.running >= l.limits.Global {
+		return false
+	}
+	limit, ok := l.limits.Hosts[host]
+	if !ok {
+		limit = l.limits.PerHost
+	}
+	return limit <= 0 || l.byHost[host] < limit
+}
+
+// urlTarget returns the host in rawURL, so HTTP checks against a device
+// count towards its limit
+func urlTarget(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil || u.Hostname() == "" {
+		return rawURL
+	}
+	return u.Hostname()
+}
+
+// Ping runs next.Ping within the limits
+func (l *Limited) Ping(host string, timeout time.Duration, opts PingOptions) PingResult {
+	defer l.acquire(host)()
+	return l.next.Ping(host, timeout, opts)
+}
+
+// HTTP runs next.HTTP within the limits
+func (l *Limited) HTTP(url string, timeout time.Duration, opts HTTPOptions) HTTPResult {
+	defer l.acquire(urlTarget(url))()
+	return l.next.HTTP(url, timeout, opts)
+}
+
+// TCP runs next.TCP within the limits
+func (l *Limited) TCP(host string, port int, timeout time.Duration, opts DialOptions) TCPResult {
+	defer l.acquire(host)()
+	return l.next.TCP(host, port, timeout, opts)
+}
+
+// SSH runs next.SSH within the limits
+func (l *Limited) SSH(host string, timeout time.Duration, opts SSHOptions) SSHResult {
+	defer l.acquire(host)()
+	return l.next.SSH(host, timeout, opts)
+}
+
+// WebSocket runs next.WebSocket within the limits
+func (l *Limited) WebSocket(url string, timeout time.Duration, opts WebSocketOptions) WebSocketResult {
+	defer l.acquire(urlTarget(url))()
+	return l.next.WebSocket(url, timeout, opts)
+}
+
+// File runs next.File within the limits. Local files count towards the
+// host they are checked for, like its other checks.
+func (l *Limited) File(host string, timeout time.Duration, opts FileOptions) FileResult {
+	defer l.acquire(host)()
+	return l.next.File(host, timeout, opts)
+}
+
+// S3 runs next.S3 within the limits
+func (l *Limited) S3(endpoint string, timeout time.Duration, opts S3Options) S3Result {
+	defer l.acquire(urlTarget(endpoint))()
+	return l.next.S3(endpoint, timeout, opts)
+}
+
+// IPP runs next.IPP within the limits
+func (l *Limited) IPP(url string, timeout time.Duration, opts IPPOptions) IPPResult {
+	defer l.acquire(urlTarget(url))()
+	return l.next.IPP(url, timeout, opts)
+}
+
+// SMB runs next.SMB within the limits
+func (l *Limited) SMB(host string, timeout time.Duration, opts SMBOptions) SMBResult {
+	defer l.acquire(host)()
+	return l.next.SMB(host, timeout, opts)
+}
+
+// Kafka runs next.Kafka within the limits
+func (l *Limited) Kafka(host string, timeout time.Duration, opts KafkaOptions) KafkaResult {
+	defer l.acquire(host)()
+	return l.next.Kafka(host, timeout, opts)
+}
+
+// AMQP runs next.AMQP within the limits
+func (l *Limited) AMQP(host string, timeout time.Duration, opts AMQPOptions) AMQPResult {
+	defer l.acquire(host)()
+	return l.next.AMQP(host, timeout, opts)
+}
+
+// Kubernetes runs next.Kubernetes within the limits. Checks that take the
+// API server from a kubeconfig share one limit.
+func (l *Limited) Kubernetes(url string, timeout time.Duration, opts KubernetesOptions) KubernetesResult {
+	defer l.acquire(urlTarget(url))()
+	return l.next.Kubernetes(url, timeout, opts)
+}
+
+// Proxmox runs next.Proxmox within the limits
+func (l *Limited) Proxmox(host string, timeout time.Duration, opts ProxmoxOptions) ProxmoxResult {
+	defer l.acquire(host)()
+	return l.next.Proxmox(host, timeout, opts)
+}
+
+// ESXi runs next.ESXi within the limits
+func (l *Limited) ESXi(host string, timeout time.Duration, opts ESXiOptions) ESXiResult {
+	defer l.acquire(host)()
+	return l.next.ESXi(host, timeout, opts)
+}
+
+// UPS runs next.UPS within the limits
+func (l *Limited) UPS(host string, timeout time.Duration, opts UPSOptions) UPSResult {
+	defer l.acquire(host)()
+	return l.next.UPS(host, timeout, opts)
+}
+
+// Speedtest runs next.Speedtest within the limits, counting downloads
+// against the host serving the file
+func (l *Limited) Speedtest(host string, timeout time.Duration, opts SpeedtestOptions) SpeedtestResult {
+	target := host
+	if opts.URL != "" {
+		target = urlTarget(opts.URL)
+	}
+	defer l.acquire(target)()
+	return l.next.Speedtest(host, timeout, opts)
+}
+
+// PublicIP runs next.PublicIP within the limits of the lookup service
+func (l *Limited) PublicIP(url string, timeout time.Duration, opts PublicIPOptions) PublicIPResult {
+	defer l.acquire(urlTarget(publicIPURL(url)))()
+	return l.next.PublicIP(url, timeout, opts)
+}
+
+// Domain runs next.Domain within the limits
+func (l *Limited) Domain(domain string, timeout time.Duration, opts DomainOptions) DomainResult {
+	defer l.acquire(domain)()
+	return l.next.Domain(domain, timeout, opts)
+}
+
+// DNSBL runs next.DNSBL within the limits of host
+func (l *Limited) DNSBL(host string, timeout time.Duration, opts DNSBLOptions) DNSBLResult {
+	defer l.acquire(host)()
+	return l.next.DNSBL(host, timeout, opts)
+}
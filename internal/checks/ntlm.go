@@ -0,0 +1,193 @@
+package checks
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/md5"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"math/bits"
+	"strings"
+	"time"
+	"unicode/utf16"
+)
+
+// NTLM negotiate flags, from MS-NLMP 2.2.2.5
+const (
+	ntlmUnicode       = 0x00000001
+	ntlmRequestTarget = 0x00000004
+	ntlmSign          = 0x00000010
+	ntlmNTLM          = 0x00000200
+	ntlmAnonymous     = 0x00000800
+	ntlmAlwaysSign    = 0x00008000
+	ntlmExtendedSec   = 0x00080000
+	ntlmTargetInfo    = 0x00800000
+	ntlm128           = 0x20000000
+	ntlm56            = 0x80000000
+
+	ntlmFlags = ntlmUnicode | ntlmRequestTarget | ntlmSign | ntlmNTLM | ntlmAlwaysSign | ntlmExtendedSec | ntlmTargetInfo | ntlm128 | ntlm56
+
+	avTimestamp = 7 // MsvAvTimestamp in a challenge's target info
+)
+
+var ntlmSignature = []byte("NTLMSSP\x00")
+
+// ntlmNegotiate is the first message of an NTLM login
+func ntlmNegotiate() []byte {
+	b := append([]byte(nil), ntlmSignature...)
+	b = binary.LittleEndian.AppendUint32(b, 1)
+	b = binary.LittleEndian.AppendUint32(b, ntlmFlags)
+	return append(b, make([]byte, 16)...) // No domain or workstation
+}
+
+// ntlmChallenge is what an NTLM login needs from the server's reply
+type ntlmChallenge struct {
+	flags      uint32
+	challenge  []byte
+	targetInfo []byte
+}
+
+func parseNTLMChallenge(b []byte) (ntlmChallenge, error) {
+	bad := errors.New("ntlm: malformed challenge")
+	if len(b) < 48 || !bytes.Equal(b[:8], ntlmSignature) || binary.LittleEndian.Uint32(b[8:]) != 2 {
+		return ntlmChallenge{}, bad
+	}
+	c := ntlmChallenge{flags: binary.LittleEndian.Uint32(b[20:]), challenge: b[24:32]}
+	n, off := int(binary.LittleEndian.Uint16(b[40:])), int(binary.LittleEndian.Uint32(b[44:]))
+	if off+n > len(b) {
+		return ntlmChallenge{}, bad
+	}
+	c.targetInfo = b[off : off+n]
+	return c, nil
+}
+
+// timestamp is the server's time from its target info, if it sent one
+func (c ntlmChallenge) timestamp() ([]byte, bool) {
+	b := c.targetInfo
+	for len(b) >= 4 {
+		id, n := binary.LittleEndian.Uint16(b), int(binary.LittleEndian.Uint16(b[2:]))
+		if len(b) < 4+n || id == 0 {
+			break
+		}
+		if id == avTimestamp && n == 8 {
+			return b[4:12], true
+		}
+		b = b[4+n:]
+	}
+	return nil, false
+}
+
+// ntlmAuthenticate answers a challenge for user, which may be written
+// DOMAIN\user, and returns the message with the session key. With no user
+// it logs in anonymously and there is no key.
+func ntlmAuthenticate(c ntlmChallenge, user, password string) ([]byte, []byte) {
+	var domain string
+	if d, u, ok := strings.Cut(user, `\`); ok {
+		domain, user = d, u
+	}
+	flags := c.flags & ntlmFlags
+	lm, nt := make([]byte, 24), []byte(nil)
+	var sessionKey []byte
+	if user == "" {
+		flags |= ntlmAnonymous
+		lm = []byte{0}
+	} else {
+		ts, ok := c.timestamp()
+		if !ok {
+			ts = binary.LittleEndian.AppendUint64(nil, uint64(time.Now().UnixNano()/100+116444736000000000))
+		}
+		clientChallenge := make([]byte, 8)
+		_, _ = rand.Read(clientChallenge)
+		nt, sessionKey = ntlmV2Response(user, domain, password, c.challenge, clientChallenge, ts, c.targetInfo)
+	}
+
+	fields := [][]byte{lm, nt, utf16LE(domain), utf16LE(user), nil, nil} // Workstation, session key
+	const headerLen = 64
+	b := append([]byte(nil), ntlmSignature...)
+	b = binary.LittleEndian.AppendUint32(b, 3)
+	off := headerLen
+	for _, f := range fields {
+		b = binary.LittleEndian.AppendUint16(b, uint16(len(f)))
+		b = binary.LittleEndian.AppendUint16(b, uint16(len(f)))
+		b = binary.LittleEndian.AppendUint32(b, uint32(off))
+		off += len(f)
+	}
+	b = binary.LittleEndian.AppendUint32(b, flags)
+	for _, f := range fields {
+		b = append(b, f...)
+	}
+	return b, sessionKey
+}
+
+// ntlmV2Response computes the NTLMv2 response and session base key, as in
+// MS-NLMP 3.3.2
+func ntlmV2Response(user, domain, password string, serverChallenge, clientChallenge, timestamp, targetInfo []byte) ([]byte, []byte) {
+	key := hmacMD5(md4Sum(utf16LE(password)), utf16LE(strings.ToUpper(user)+domain))
+	temp := []byte{1, 1, 0, 0, 0, 0, 0, 0}
+	temp = append(temp, timestamp...)
+	temp = append(temp, clientChallenge...)
+	temp = append(temp, 0, 0, 0, 0)
+	temp = append(temp, targetInfo...)
+	temp = append(temp, 0, 0, 0, 0)
+	proof := hmacMD5(key, append(append([]byte(nil), serverChallenge...), temp...))
+	return append(proof, temp...), hmacMD5(key, proof)
+}
+
+func hmacMD5(key, data []byte) []byte {
+	h := hmac.New(md5.New, key)
+	h.Write(data)
+	return h.Sum(nil)
+}
+
+func utf16LE(s string) []byte {
+	var b []byte
+	for _, r := range utf16.Encode([]rune(s)) {
+		b = binary.LittleEndian.AppendUint16(b, r)
+	}
+	return b
+}
+
+// md4Sum is MD4 (RFC 1320), which NTLM hashes passwords with and the
+// standard library doesn't provide
+func md4Sum(msg []byte) []byte {
+	n := len(msg)
+	msg = append(append([]byte(nil), msg...), 0x80)
+	for len(msg)%64 != 56 {
+		msg = append(msg, 0)
+	}
+	msg = binary.LittleEndian.AppendUint64(msg, uint64(n)*8)
+
+	a, b, c, d := uint32(0x67452301), uint32(0xefcdab89), uint32(0x98badcfe), uint32(0x10325476)
+	var x [16]uint32
+	for ; len(msg) > 0; msg = msg[64:] {
+		for i := range x {
+			x[i] = binary.LittleEndian.Uint32(msg[4*i:])
+		}
+		aa, bb, cc, dd := a, b, c, d
+		for _, i := range [...]int{0, 4, 8, 12} {
+			a = bits.RotateLeft32(a+(b&c|^b&d)+x[i], 3)
+			d = bits.RotateLeft32(d+(a&b|^a&c)+x[i+1], 7)
+			c = bits.RotateLeft32(c+(d&a|^d&b)+x[i+2], 11)
+			b = bits.RotateLeft32(b+(c&d|^c&a)+x[i+3], 19)
+		}
+		for _, i := range [...]int{0, 1, 2, 3} {
+			a = bits.RotateLeft32(a+(b&c|b&d|c&d)+x[i]+0x5a827999, 3)
+			d = bits.RotateLeft32(d+(a&b|a&c|b&c)+x[i+4]+0x5a827999, 5)
+			c = bits.RotateLeft32(c+(d&a|d&b|a&b)+x[i+8]+0x5a827999, 9)
+			b = bits.RotateLeft32(b+(c&d|c&a|d&a)+x[i+12]+0x5a827999, 13)
+		}
+		for _, i := range [...]int{0, 2, 1, 3} {
+			a = bits.RotateLeft32(a+(b^c^d)+x[i]+0x6ed9eba1, 3)
+			d = bits.RotateLeft32(d+(a^b^c)+x[i+8]+0x6ed9eba1, 9)
+			c = bits.RotateLeft32(c+(d^a^b)+x[i+4]+0x6ed9eba1, 11)
+			b = bits.RotateLeft32(b+(c^d^a)+x[i+12]+0x6ed9eba1, 15)
+		}
+		a, b, c, d = a+aa, b+bb, c+cc, d+dd
+	}
+	var sum []byte
+	for _, v := range [...]uint32{a, b, c, d} {
+		sum = binary.LittleEndian.AppendUint32(sum, v)
+	}
+	return sum
+}
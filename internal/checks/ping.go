@@ -1,33 +1,45 @@
 //go:build !darwin
 
-
 package checks
 
 import (
 	"time"
+
 	ping "github.com/go-ping/ping"
 )
 
-func PingOnce(host string, timeout time.Duration) PingResult {
-	p, err := ping.NewPinger(host)
-	if err != nil {
-		return PingResult{OK: false, Err: err}
+// icmpPing sends opts' echo requests with go-ping. In auto mode it tries raw
+// ICMP first and, if that isn't permitted, unprivileged ICMP over a UDP
+// datagram socket. The error reports that neither method could be used.
+func icmpPing(host string, timeout time.Duration, opts PingOptions) (PingResult, error) {
+	privileged := []bool{true, false}
+	switch opts.Method {
+	case PingICMP:
+		privileged = []bool{true}
+	case PingUnprivileged:
+		privileged = []bool{false}
 	}
-	p.Count = 1
-	p.Timeout = timeout
-	// Try privileged ICMP first; if it fails (e.g., no perms), fall back to unprivileged UDP.
-	p.SetPrivileged(true)
-	if err := p.Run(); err != nil {
-		p.SetPrivileged(false)
-		if err2 := p.Run(); err2 != nil {
-			return PingResult{OK: false, Err: err2}
+	var lastErr error
+	for _, priv := range privileged {
+		p, err := ping.NewPinger(host)
+		if err != nil {
+			// Resolution failures won't be fixed by another method
+			return PingResult{OK: false, Err: err, Method: MethodICMP}, nil
 		}
+		p.Count = opts.count()
+		p.Interval = PingInterval
+		p.Timeout = opts.runTimeout(timeout)
+		p.SetPrivileged(priv)
+		if err := p.Run(); err != nil {
+			lastErr = err
+			continue
+		}
+		used := MethodICMP
+		if !priv {
+			used = MethodUDP
+		}
+		stats := p.Statistics()
+		return pingResult(stats.PacketsSent, stats.Rtts, used, p.IPAddr().String()), nil
 	}
-	stats := p.Statistics()
-	ok := stats.PacketsRecv > 0
-	lat := time.Duration(0)
-	if ok {
-		lat = stats.AvgRtt
-	}
-	return PingResult{OK: ok, Latency: lat, PacketsTx: stats.PacketsSent, PacketsRx: stats.PacketsRecv}
+	return PingResult{}, lastErr
 }
@@ -4,37 +4,61 @@ package checks
 
 import (
 	"context"
+	"errors"
 	"os/exec"
 	"regexp"
+	"strconv"
 	"time"
 )
 
-var timeRe = regexp.MustCompile(`time=([0-9]+\.?[0-9]*) ms`)
+var (
+	timeRe = regexp.MustCompile(`time=([0-9]+\.?[0-9]*) ms`)
+	addrRe = regexp.MustCompile(`^PING [^ ]+ \(([^)]+)\)`)
+)
 
-func PingOnce(host string, timeout time.Duration) PingResult {
+// pingedAddr returns the IP address ping reports it resolved the host to
+func pingedAddr(out []byte) string {
+	if m := addrRe.FindSubmatch(out); m != nil {
+		return string(m[1])
+	}
+	return ""
+}
+
+// icmpPing runs the system ping binary, which is setuid on macOS and so
+// needs no privileges here. The method is ignored; the error reports that
+// the binary couldn't be run at all.
+func icmpPing(host string, timeout time.Duration, opts PingOptions) (PingResult, error) {
 	// Try to locate ping
 	path, err := exec.LookPath("ping")
 	if err != nil {
 		// macOS usually has /sbin/ping
 		path = "/sbin/ping"
 	}
-	ctx, cancel := context.WithTimeout(context.Background(), timeout+500*time.Millisecond)
+	ctx, cancel := context.WithTimeout(context.Background(), opts.runTimeout(timeout)+500*time.Millisecond)
 	defer cancel()
-	cmd := exec.CommandContext(ctx, path, "-c", "1", "-W", "2000", host)
+	count := opts.count()
+	interval := strconv.FormatFloat(PingInterval.Seconds(), 'f', -1, 64)
+	cmd := exec.CommandContext(ctx, path, "-c", strconv.Itoa(count), "-i", interval, "-W", "2000", host)
 	out, err := cmd.CombinedOutput()
 	if ctx.Err() == context.DeadlineExceeded {
-		return PingResult{OK: false, Err: ctx.Err()}
+		return PingResult{OK: false, Err: ctx.Err(), Method: MethodExec}, nil
 	}
 	if err != nil {
-		return PingResult{OK: false, Err: err}
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) {
+			// ping ran but got no reply
+			return PingResult{OK: false, Err: err, Method: MethodExec, Addr: pingedAddr(out)}, nil
+		}
+		return PingResult{}, err
 	}
-	lat := time.Duration(0)
-	m := timeRe.FindStringSubmatch(string(out))
-	if len(m) == 2 {
-		// milliseconds to duration
+	// ping exits 0 when any reply arrived; each has its own time=
+	var rtts []time.Duration
+	for _, m := range timeRe.FindAllStringSubmatch(string(out), -1) {
 		if v, perr := time.ParseDuration(m[1] + "ms"); perr == nil {
-			lat = v
+			rtts = append(rtts, v)
 		}
 	}
-	return PingResult{OK: true, Latency: lat, PacketsTx: 1, PacketsRx: 1}
+	res := pingResult(count, rtts, MethodExec, pingedAddr(out))
+	res.OK = true
+	return res, nil
 }
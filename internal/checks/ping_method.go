@@ -0,0 +1,122 @@
+package checks
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// Ping methods a check can ask for
+const (
+	PingAuto         = ""             // ICMP, then unprivileged ICMP, then a TCP ping
+	PingICMP         = "icmp"         // Raw ICMP only; needs privileges on most systems
+	PingUnprivileged = "unprivileged" // ICMP over a UDP datagram socket only
+	PingTCP          = "tcp"          // TCP connect to PingOptions.TCPPort only
+)
+
+// Methods reported in PingResult.Method
+const (
+	MethodICMP = "icmp" // Raw ICMP socket
+	MethodUDP  = "udp"  // Unprivileged ICMP datagram socket
+	MethodExec = "exec" // System ping binary
+	MethodTCP  = "tcp"  // TCP connect; see PingResult.Method for the port
+)
+
+// DefaultTCPPingPort is used for TCP pings when the check sets no port
+const DefaultTCPPingPort = 80
+
+// PingOptions controls how a ping check reaches its host
+type PingOptions struct {
+	Method  string // One of the Ping* constants; empty is auto
+	TCPPort int    // Port for TCP pings; 0 means DefaultTCPPingPort
+	Count   int    // Echo requests per run, PingInterval apart; 0 means 1
+}
+
+// ParsePingMethod normalises a configured ping method, mapping "auto" to
+// PingAuto, and rejects unknown methods
+func ParsePingMethod(s string) (string, error) {
+	switch m := strings.ToLower(strings.TrimSpace(s)); m {
+	case "auto":
+		return PingAuto, nil
+	case PingAuto, PingICMP, PingUnprivileged, PingTCP:
+		return m, nil
+	}
+	return PingAuto, fmt.Errorf("%q must be auto, icmp, unprivileged or tcp", s)
+}
+
+// PingOnce checks that host is reachable using the method in opts, sending
+// opts.Count echo requests; timeout applies to each. In auto mode a TCP ping
+// is the last resort, used only if ICMP can't be sent at all (e.g. raw
+// sockets are forbidden), not when the host just doesn't reply.
+func PingOnce(host string, timeout time.Duration, opts PingOptions) PingResult {
+	if opts.Method == PingTCP {
+		return tcpPing(host, opts.port(), timeout, opts.count())
+	}
+	res, err := icmpPing(host, timeout, opts)
+	if err == nil {
+		return res
+	}
+	if opts.Method != PingAuto {
+		return PingResult{OK: false, Err: fmt.Errorf("%s ping unavailable: %w", opts.Method, err)}
+	}
+	return tcpPing(host, opts.port(), timeout, opts.count())
+}
+
+func (o PingOptions) port() int {
+	if o.TCPPort > 0 {
+		return o.TCPPort
+	}
+	return DefaultTCPPingPort
+}
+
+// reportedMethod is the PingResult.Method that fakes report for o
+func (o PingOptions) reportedMethod() string {
+	switch o.Method {
+	case PingTCP:
+		return MethodTCP + "/" + strconv.Itoa(o.port())
+	case PingUnprivileged:
+		return MethodUDP
+	default:
+		return MethodICMP
+	}
+}
+
+// tcpPing makes count connections, treating a completed handshake or a
+// refused connection as a reply: either way the host itself answered
+func tcpPing(host string, port int, timeout time.Duration, count int) PingResult {
+	method := MethodTCP + "/" + strconv.Itoa(port)
+	var rtts []time.Duration
+	var addr string
+	var lastErr error
+	for i := range count {
+		if i > 0 {
+			time.Sleep(PingInterval)
+		}
+		start := time.Now()
+		conn, err := net.DialTimeout("tcp", net.JoinHostPort(host, strconv.Itoa(port)), timeout)
+		lat := time.Since(start)
+		switch {
+		case err == nil:
+			addr = remoteIP(conn.RemoteAddr())
+			conn.Close()
+			rtts = append(rtts, lat)
+		case errors.Is(err, syscall.ECONNREFUSED):
+			addr = dialedIP(err)
+			rtts = append(rtts, lat)
+		default:
+			if addr == "" {
+				addr = dialedIP(err)
+			}
+			lastErr = err
+		}
+	}
+	res := pingResult(count, rtts, method, addr)
+	if !res.OK {
+		res.Err = lastErr
+	}
+	return res
+}
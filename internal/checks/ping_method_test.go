@@ -0,0 +1,44 @@
+package checks
+
+import (
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestPingOnceTCP(t *testing.T) {
+	// A refused connection still means the host answered
+	for name, port := range map[string]int{"open": listen(t), "refused": closedPort(t)} {
+		res := PingOnce("127.0.0.1", time.Second, PingOptions{Method: PingTCP, TCPPort: port})
+		if !res.OK {
+			t.Errorf("%s port: PingOnce = not ok, %v", name, res.Err)
+			continue
+		}
+		if want := "tcp/" + strconv.Itoa(port); res.Method != want {
+			t.Errorf("%s port: Method = %q, want %q", name, res.Method, want)
+		}
+		if res.PacketsTx != 1 || res.PacketsRx != 1 {
+			t.Errorf("%s port: packets = %d sent, %d received; want 1, 1", name, res.PacketsTx, res.PacketsRx)
+		}
+	}
+}
+
+func TestParsePingMethod(t *testing.T) {
+	tests := []struct {
+		in, want string
+		ok       bool
+	}{
+		{"", PingAuto, true},
+		{"auto", PingAuto, true},
+		{" ICMP ", PingICMP, true},
+		{"unprivileged", PingUnprivileged, true},
+		{"tcp", PingTCP, true},
+		{"arp", PingAuto, false},
+	}
+	for _, tt := range tests {
+		got, err := ParsePingMethod(tt.in)
+		if got != tt.want || (err == nil) != tt.ok {
+			t.Errorf("ParsePingMethod(%q) = %q, %v; want %q, ok %v", tt.in, got, err, tt.want, tt.ok)
+		}
+	}
+}
@@ -0,0 +1,118 @@
+package checks
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// scanWorkers caps how many ports one ports check dials at once; probe
+// limits apply on top
+const scanWorkers = 16
+
+// PortScanOptions lists the ports a ports check expects to find open and
+// closed. A port that refuses the connection or doesn't answer counts as
+// closed.
+type PortScanOptions struct {
+	Open   []int
+	Closed []int
+}
+
+// PortScanResult is the outcome of a ports check
+type PortScanResult struct {
+	OK        bool
+	Latency   time.Duration // Time taken by the whole scan
+	NotOpen   []int         // Expected open but not accepting connections
+	NotClosed []int         // Expected closed but accepting connections
+}
+
+// ScanPorts dials every port in opts on host through c, so probe limits
+// apply, and reports those not in the expected state
+func ScanPorts(c Checker, host string, timeout time.Duration, opts PortScanOptions, dial DialOptions) PortScanResult {
+	type job struct {
+		port     int
+		wantOpen bool
+	}
+	jobs := make(chan job)
+	var mu sync.Mutex
+	var res PortScanResult
+	var wg sync.WaitGroup
+	start := time.Now()
+	for range min(scanWorkers, len(opts.Open)+len(opts.Closed)) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := range jobs {
+				open := c.TCP(host, j.port, timeout, dial).OK
+				if open == j.wantOpen {
+					continue
+				}
+				mu.Lock()
+				if j.wantOpen {
+					res.NotOpen = append(res.NotOpen, j.port)
+				} else {
+					res.NotClosed = append(res.NotClosed, j.port)
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+	for _, p := range opts.Open {
+		jobs <- job{p, true}
+	}
+	for _, p := range opts.Closed {
+		jobs <- job{p, false}
+	}
+	close(jobs)
+	wg.Wait()
+	res.Latency = time.Since(start)
+	slices.Sort(res.NotOpen)
+	slices.Sort(res.NotClosed)
+	res.OK = len(res.NotOpen) == 0 && len(res.NotClosed) == 0
+	return res
+}
+
+// Message summarises the scan for the check row, e.g. "5 ports as
+// expected" or "not open: 443; open: 23"
+func (r PortScanResult) Message(opts PortScanOptions) string {
+	if r.OK {
+		n := len(opts.Open) + len(opts.Closed)
+		if n == 1 {
+			return "1 port as expected"
+		}
+		return fmt.Sprintf("%d ports as expected", n)
+	}
+	var parts []string
+	if len(r.NotOpen) > 0 {
+		parts = append(parts, "not open: "+FormatPorts(r.NotOpen))
+	}
+	if len(r.NotClosed) > 0 {
+		parts = append(parts, "open: "+FormatPorts(r.NotClosed))
+	}
+	return strings.Join(parts, "; ")
+}
+
+// FormatPorts writes sorted ports as a list with runs collapsed into
+// ranges, e.g. "22,80,8000-8010"; the form validate.Ports reads
+func FormatPorts(ports []int) string {
+	var b strings.Builder
+	for i := 0; i < len(ports); {
+		j := i
+		for j+1 < len(ports) && ports[j+1] == ports[j]+1 {
+			j++
+		}
+		if b.Len() > 0 {
+			b.WriteByte(',')
+		}
+		b.WriteString(strconv.Itoa(ports[i]))
+		if j > i {
+			b.WriteByte('-')
+			b.WriteString(strconv.Itoa(ports[j]))
+		}
+		i = j + 1
+	}
+	return b.String()
+}
@@ -0,0 +1,217 @@
+package checks
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"slices"
+	"strconv"
+	"time"
+)
+
+// DefaultProxmoxPort is where the Proxmox VE API is served
+const DefaultProxmoxPort = 8006
+
+// DefaultMaxUsage is how full, in percent, a hypervisor's memory or a
+// storage pool can get before proxmox and esxi checks fail
+const DefaultMaxUsage = 90
+
+const proxmoxMaxResponse = 8 << 20
+
+// ProxmoxOptions says how a proxmox check logs in to the Proxmox VE API and
+// what it looks at
+type ProxmoxOptions struct {
+	Token              string // API token, written user@realm!tokenid=secret
+	Node               string // Only look at this cluster node; empty means every node
+	VM                 string // Guest, by name or ID, that must be running; empty only counts guests
+	MaxUsage           int    // Fail when memory or a storage pool is fuller than this percentage; 0 means DefaultMaxUsage
+	Port               int    // 0 means DefaultProxmoxPort
+	InsecureSkipVerify bool   // Accept Proxmox's self-signed certificate
+
+	Identity ProbeIdentity // User-Agent and probe ID header, set per run
+}
+
+type ProxmoxResult struct {
+	Latency time.Duration
+	Nodes   int      // Cluster nodes looked at
+	Online  int      // Nodes that are online
+	Offline []string // Names of the nodes that aren't
+	Guests  int      // VMs and containers, not counting templates
+	Running int      // Guests that are running
+	VM      string   // The guest named by opts.VM, e.g. "101 (plex) on pve1"
+	VMState string   // Its status, e.g. "running" or "stopped"
+	Alarms  []string // Resources over the usage limit or unavailable, e.g. "storage local-lvm on pve1 96% full"
+	Addr    string   // IP address the response came from, or the last one tried
+	TLS     *TLSInfo
+	OK      bool // Every node is online, nothing is over the limit and opts.VM, if set, is running
+	Err     error
+}
+
+// ProxmoxStatus asks the Proxmox VE API on host for its cluster's resources
+// and checks that every node is online, that no node's memory or storage
+// pool is fuller than opts.MaxUsage and, with opts.VM, that the guest is
+// running. A token with the PVEAuditor role is enough.
+func ProxmoxStatus(host string, timeout time.Duration, opts ProxmoxOptions) ProxmoxResult {
+	port := opts.Port
+	if port == 0 {
+		port = DefaultProxmoxPort
+	}
+	client, err := newHTTPClient(timeout, HTTPOptions{NoFollowRedirects: true, InsecureSkipVerify: opts.InsecureSkipVerify})
+	if err != nil {
+		return ProxmoxResult{Err: err}
+	}
+	u := "https://" + net.JoinHostPort(host, strconv.Itoa(port)) + "/api2/json/cluster/resources"
+	var addr tracedAddr
+	req, err := http.NewRequestWithContext(addr.context(context.Background()), http.MethodGet, u, nil)
+	if err != nil {
+		return ProxmoxResult{Err: err}
+	}
+	req.Header.Set("Authorization", "PVEAPIToken="+opts.Token)
+	opts.Identity.setHeaders(req.Header)
+	req.Close = true
+	start := time.Now()
+	resp, err := client.Do(req)
+	if err != nil {
+		return ProxmoxResult{Addr: addr.get(), Err: err}
+	}
+	defer resp.Body.Close()
+	res := ProxmoxResult{Addr: addr.get()}
+	res.TLS = newTLSInfo(resp.TLS, host, opts.InsecureSkipVerify)
+	body, err := io.ReadAll(io.LimitReader(resp.Body, proxmoxMaxResponse))
+	res.Latency = time.Since(start)
+	if err != nil {
+		res.Err = err
+		return res
+	}
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusUnauthorized:
+		res.Err = errors.New("unauthorised (401); check the API token")
+		return res
+	default:
+		res.Err = fmt.Errorf("status %s", resp.Status)
+		return res
+	}
+	var list struct {
+		Data []proxmoxResource `json:"data"`
+	}
+	if err := json.Unmarshal(body, &list); err != nil {
+		res.Err = errors.New("proxmox: malformed cluster resources")
+		return res
+	}
+	res.Err = res.evaluate(list.Data, opts)
+	res.OK = res.Err == nil && len(res.Offline) == 0 && len(res.Alarms) == 0 && (opts.VM == "" || res.VMState == "running")
+	return res
+}
+
+// proxmoxResource is an entry of /cluster/resources: a node, guest or
+// storage pool
+type proxmoxResource struct {
+	Type     string  `json:"type"` // node, qemu, lxc or storage
+	Node     string  `json:"node"`
+	Status   string  `json:"status"`
+	Name     string  `json:"name"`
+	VMID     int     `json:"vmid"`
+	Storage  string  `json:"storage"`
+	Shared   int     `json:"shared"`
+	Template int     `json:"template"`
+	Mem      float64 `json:"mem"`
+	MaxMem   float64 `json:"maxmem"`
+	Disk     float64 `json:"disk"`
+	MaxDisk  float64 `json:"maxdisk"`
+}
+
+// evaluate counts the nodes and guests in opts.Node, or the whole cluster,
+// and notes what is offline or over the usage limit
+func (r *ProxmoxResult) evaluate(resources []proxmoxResource, opts ProxmoxOptions) error {
+	maxUsage := opts.MaxUsage
+	if maxUsage == 0 {
+		maxUsage = DefaultMaxUsage
+	}
+	sharedSeen := map[string]bool{}
+	var unavailable []proxmoxResource // Storage, reported once it's known whether its node is offline
+	found := false
+	for _, res := range resources {
+		if opts.Node != "" && res.Node != opts.Node {
+			continue
+		}
+		switch res.Type {
+		case "node":
+			r.Nodes++
+			if res.Status != "online" {
+				r.Offline = append(r.Offline, res.Node)
+				continue
+			}
+			r.Online++
+			if pct, ok := usagePercent(res.Mem, res.MaxMem); ok && pct > maxUsage {
+				r.Alarms = append(r.Alarms, fmt.Sprintf("%s memory %d%%", res.Node, pct))
+			}
+			if pct, ok := usagePercent(res.Disk, res.MaxDisk); ok && pct > maxUsage {
+				r.Alarms = append(r.Alarms, fmt.Sprintf("%s root disk %d%% full", res.Node, pct))
+			}
+		case "qemu", "lxc":
+			if res.Template == 1 {
+				continue
+			}
+			r.Guests++
+			if res.Status == "running" {
+				r.Running++
+			}
+			if opts.VM != "" && !found && (opts.VM == strconv.Itoa(res.VMID) || opts.VM == res.Name) {
+				found = true
+				r.VM = fmt.Sprintf("%d (%s) on %s", res.VMID, res.Name, res.Node)
+				r.VMState = res.Status
+			}
+		case "storage":
+			if res.Status != "available" {
+				unavailable = append(unavailable, res)
+				continue
+			}
+			if res.Shared == 1 {
+				if sharedSeen[res.Storage] {
+					continue // Listed once per node
+				}
+				sharedSeen[res.Storage] = true
+			}
+			where := res.Storage + " on " + res.Node
+			if res.Shared == 1 {
+				where = res.Storage
+			}
+			if pct, ok := usagePercent(res.Disk, res.MaxDisk); ok && pct > maxUsage {
+				r.Alarms = append(r.Alarms, fmt.Sprintf("storage %s %d%% full", where, pct))
+			}
+		}
+	}
+	for _, res := range unavailable {
+		if res.Shared == 1 {
+			if !sharedSeen[res.Storage] {
+				sharedSeen[res.Storage] = true
+				r.Alarms = append(r.Alarms, "storage "+res.Storage+" unavailable")
+			}
+		} else if !slices.Contains(r.Offline, res.Node) {
+			r.Alarms = append(r.Alarms, "storage "+res.Storage+" on "+res.Node+" unavailable")
+		}
+	}
+	switch {
+	case r.Nodes == 0 && opts.Node != "":
+		return fmt.Errorf("no such node %q", opts.Node)
+	case r.Nodes == 0:
+		return errors.New("no nodes visible; the token needs the PVEAuditor role on /")
+	case opts.VM != "" && !found:
+		return fmt.Errorf("no such guest %q", opts.VM)
+	}
+	return nil
+}
+
+// usagePercent returns used as a whole percentage of total, or false when
+// the total isn't known
+func usagePercent(used, total float64) (int, bool) {
+	if total <= 0 {
+		return 0, false
+	}
+	return int(used * 100 / total), true
+}
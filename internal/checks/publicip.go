@@ -0,0 +1,119 @@
+package checks
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// DefaultPublicIPURL is the lookup service a publicip check asks unless
+// configured otherwise. It answers with the caller's address as plain text.
+const DefaultPublicIPURL = "https://api64.ipify.org"
+
+const publicIPMaxBody = 4 << 10
+
+// PublicIPOptions says how a publicip check looks up the monitor's address
+type PublicIPOptions struct {
+	IPVersion int // 4 or 6 to look up that family's address; 0 lets the resolver choose
+
+	Identity ProbeIdentity // User-Agent and probe ID header, set per run
+}
+
+type PublicIPResult struct {
+	Latency time.Duration
+	IP      string // The monitor's public address as the service saw it
+	Addr    string // IP address of the lookup service, or the last one tried
+	OK      bool
+	Err     error
+}
+
+// publicIPURL returns the lookup service a publicip check asks
+func publicIPURL(url string) string {
+	if url == "" {
+		return DefaultPublicIPURL
+	}
+	return url
+}
+
+// PublicIP asks the lookup service at url, or DefaultPublicIPURL, which
+// address the monitor's requests come from. The service may answer with the
+// bare address, as ipify and icanhazip do, a JSON object with an "ip" field,
+// as ifconfig.co/json does, or "ip=" lines, as Cloudflare's /cdn-cgi/trace
+// does.
+func PublicIP(url string, timeout time.Duration, opts PublicIPOptions) PublicIPResult {
+	client, err := newHTTPClient(timeout, HTTPOptions{DialOptions: DialOptions{IPVersion: opts.IPVersion}})
+	if err != nil {
+		return PublicIPResult{Err: err}
+	}
+	var addr tracedAddr
+	req, err := http.NewRequestWithContext(addr.context(context.Background()), http.MethodGet, publicIPURL(url), nil)
+	if err != nil {
+		return PublicIPResult{Err: err}
+	}
+	opts.Identity.setHeaders(req.Header)
+	start := time.Now()
+	resp, err := client.Do(req)
+	if err != nil {
+		return PublicIPResult{Addr: addr.get(), Err: err}
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, publicIPMaxBody))
+	res := PublicIPResult{Latency: time.Since(start), Addr: addr.get()}
+	if err != nil {
+		res.Err = err
+		return res
+	}
+	if resp.StatusCode != http.StatusOK {
+		res.Err = fmt.Errorf("status %s", resp.Status)
+		return res
+	}
+	ip := parsePublicIP(body)
+	if ip == nil {
+		res.Err = fmt.Errorf("the service didn't answer with an address: %q", truncate(strings.TrimSpace(string(body)), 60))
+		return res
+	}
+	if ip.IsPrivate() || ip.IsLoopback() || ip.IsLinkLocalUnicast() {
+		res.Err = fmt.Errorf("the service answered with %s, which isn't public; is it on the local network?", ip)
+		return res
+	}
+	if v := ipVersion(ip); opts.IPVersion != 0 && v != opts.IPVersion {
+		res.Err = fmt.Errorf("asked for an IPv%d address but the service answered with %s", opts.IPVersion, ip)
+		return res
+	}
+	res.IP, res.OK = ip.String(), true
+	return res
+}
+
+// parsePublicIP finds the address in a lookup service's answer, or returns
+// nil
+func parsePublicIP(body []byte) net.IP {
+	text := strings.TrimSpace(string(body))
+	if ip := net.ParseIP(text); ip != nil {
+		return ip
+	}
+	var obj struct {
+		IP string `json:"ip"`
+	}
+	if json.Unmarshal(body, &obj) == nil {
+		return net.ParseIP(strings.TrimSpace(obj.IP))
+	}
+	for _, line := range strings.Split(text, "\n") {
+		if v, ok := strings.CutPrefix(strings.TrimSpace(line), "ip="); ok {
+			return net.ParseIP(v)
+		}
+	}
+	return nil
+}
+
+// ipVersion returns 4 or 6 for ip's family
+func ipVersion(ip net.IP) int {
+	if ip.To4() != nil {
+		return 4
+	}
+	return 6
+}
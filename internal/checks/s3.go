@@ -0,0 +1,189 @@
+package checks
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DefaultS3Region is signed for when an s3 check sets no region. MinIO and
+// most other S3-compatible stores accept it whatever their location.
+const DefaultS3Region = "us-east-1"
+
+// emptySHA256 is the hex SHA-256 of an empty request body
+const emptySHA256 = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+
+// S3Options says which bucket, and optionally which object, an s3 check
+// looks at and how to sign for it. Without an access key requests are
+// unsigned, for public buckets.
+type S3Options struct {
+	Bucket             string        // Appended to the endpoint path; empty if the endpoint names it, e.g. https://bucket.s3.amazonaws.com
+	Object             string        // Key of an object that must exist; empty checks the bucket
+	Region             string        // Signing region; empty means DefaultS3Region
+	AccessKey          string        // Access key ID
+	SecretKey          string        // Secret access key
+	MaxAge             time.Duration // Fail when the object was last modified longer ago; 0 for no limit
+	InsecureSkipVerify bool          // Accept self-signed certificates, e.g. on a home MinIO server
+
+	Identity ProbeIdentity // User-Agent and probe ID header, set per run
+}
+
+type S3Result struct {
+	Latency      time.Duration
+	Code         int
+	Size         int64     // The object's size, from Content-Length
+	LastModified time.Time // When the object was last written
+	Addr         string    // IP address the response came from, or the last one tried
+	TLS          *TLSInfo
+	OK           bool // Reachable, authorised, and the object is fresh enough
+	Err          error
+}
+
+// S3Head sends a signed HEAD request for the bucket, or the object, at
+// endpoint, e.g. https://s3.eu-west-1.amazonaws.com or http://minio:9000,
+// using path-style addressing. A 200 means the store is up, the credentials
+// work and the object exists.
+func S3Head(endpoint string, timeout time.Duration, opts S3Options) S3Result {
+	u, err := opts.objectURL(endpoint)
+	if err != nil {
+		return S3Result{Err: err}
+	}
+	client, err := newHTTPClient(timeout, HTTPOptions{NoFollowRedirects: true, InsecureSkipVerify: opts.InsecureSkipVerify})
+	if err != nil {
+		return S3Result{Err: err}
+	}
+	var addr tracedAddr
+	req, err := http.NewRequestWithContext(addr.context(context.Background()), http.MethodHead, u.String(), nil)
+	if err != nil {
+		return S3Result{Err: err}
+	}
+	opts.Identity.setHeaders(req.Header)
+	if opts.AccessKey != "" {
+		opts.sign(req, time.Now())
+	}
+	req.Close = true
+	start := time.Now()
+	resp, err := client.Do(req)
+	if err != nil {
+		return S3Result{Addr: addr.get(), Err: err}
+	}
+	resp.Body.Close()
+	res := S3Result{Latency: time.Since(start), Code: resp.StatusCode, Addr: addr.get()}
+	res.TLS = newTLSInfo(resp.TLS, u.Hostname(), opts.InsecureSkipVerify)
+	if res.Err = opts.statusError(resp); res.Err != nil {
+		return res
+	}
+	if opts.Object != "" {
+		res.Size, _ = strconv.ParseInt(resp.Header.Get("Content-Length"), 10, 64)
+		res.LastModified, _ = http.ParseTime(resp.Header.Get("Last-Modified"))
+	}
+	res.OK = opts.MaxAge <= 0 || opts.Object == "" || time.Since(res.LastModified) <= opts.MaxAge
+	return res
+}
+
+// objectURL appends the bucket and object to the endpoint's path
+func (o S3Options) objectURL(endpoint string) (*url.URL, error) {
+	u, err := url.Parse(endpoint)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, fmt.Errorf("invalid S3 endpoint %q", endpoint)
+	}
+	p := strings.TrimSuffix(u.Path, "/")
+	if o.Bucket != "" {
+		p += "/" + o.Bucket
+	}
+	p += "/" + strings.TrimPrefix(o.Object, "/")
+	u.Path, u.RawPath, u.RawQuery = p, awsEscapePath(p), ""
+	return u, nil
+}
+
+// statusError explains a failed HEAD. S3 sends no body with HEAD replies,
+// so the status code and a few headers are all there is.
+func (o S3Options) statusError(resp *http.Response) error {
+	what := "bucket"
+	if o.Object != "" {
+		what = "object"
+	}
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return nil
+	case http.StatusMovedPermanently, http.StatusBadRequest:
+		if r := resp.Header.Get("X-Amz-Bucket-Region"); r != "" && r != o.region() {
+			return fmt.Errorf("bucket is in region %s, not %s", r, o.region())
+		}
+	case http.StatusForbidden:
+		if o.AccessKey == "" {
+			return fmt.Errorf("access denied (403); the %s isn't public", what)
+		}
+		return fmt.Errorf("access denied (403); check the keys, region and permissions")
+	case http.StatusNotFound:
+		return fmt.Errorf("no such %s (404)", what)
+	}
+	return fmt.Errorf("status %s", resp.Status)
+}
+
+func (o S3Options) region() string {
+	if o.Region == "" {
+		return DefaultS3Region
+	}
+	return o.Region
+}
+
+// sign adds AWS Signature Version 4 headers to a request with no body
+func (o S3Options) sign(req *http.Request, now time.Time) {
+	now = now.UTC()
+	stamp := now.Format("20060102T150405Z")
+	day := stamp[:8]
+	req.Header.Set("X-Amz-Date", stamp)
+	req.Header.Set("X-Amz-Content-Sha256", emptySHA256)
+
+	const signed = "host;x-amz-content-sha256;x-amz-date"
+	canonical := strings.Join([]string{
+		req.Method,
+		req.URL.EscapedPath(),
+		"",
+		"host:" + req.URL.Host,
+		"x-amz-content-sha256:" + emptySHA256,
+		"x-amz-date:" + stamp,
+		"",
+		signed,
+		emptySHA256,
+	}, "\n")
+	scope := day + "/" + o.region() + "/s3/aws4_request"
+	sum := sha256.Sum256([]byte(canonical))
+	toSign := "AWS4-HMAC-SHA256\n" + stamp + "\n" + scope + "\n" + hex.EncodeToString(sum[:])
+
+	key := hmacSHA256([]byte("AWS4"+o.SecretKey), day)
+	key = hmacSHA256(key, o.region())
+	key = hmacSHA256(key, "s3")
+	key = hmacSHA256(key, "aws4_request")
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		o.AccessKey, scope, signed, hex.EncodeToString(hmacSHA256(key, toSign))))
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
+
+// awsEscapePath percent-encodes everything in p but unreserved characters
+// and slashes, as SigV4's canonical URI requires
+func awsEscapePath(p string) string {
+	var b strings.Builder
+	for i := 0; i < len(p); i++ {
+		c := p[i]
+		if 'A' <= c && c <= 'Z' || 'a' <= c && c <= 'z' || '0' <= c && c <= '9' || strings.IndexByte("-._~/", c) >= 0 {
+			b.WriteByte(c)
+		} else {
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
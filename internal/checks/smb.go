@@ -0,0 +1,387 @@
+package checks
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"time"
+)
+
+// DefaultSMBPort is the port smb checks connect to when none is set
+const DefaultSMBPort = 445
+
+// SMB2 commands, flags and status codes, from MS-SMB2
+const (
+	smbNegotiate     = 0
+	smbSessionSetup  = 1
+	smbTreeConnect   = 3
+	smbHeaderLen     = 64
+	smbFlagAsync     = 0x00000002
+	smbFlagSigned    = 0x00000008
+	smbSigningReq    = 0x0002
+	smbSessionGuest  = 0x0001
+	smbSessionNull   = 0x0002
+	smbSessionCrypt  = 0x0004
+	smbMaxMessage    = 1 << 16
+	statusPending    = 0x00000103
+	statusMoreNeeded = 0xc0000016
+)
+
+// smbDialects are offered in order of preference. SMB 3.1.1 is left out:
+// servers that insist on it fail the negotiation with "not supported".
+var smbDialects = []uint16{0x0202, 0x0210, 0x0300, 0x0302}
+
+var smbStatusText = map[uint32]string{
+	0xc0000022: "access denied",
+	0xc000006d: "logon failed: wrong user name or password",
+	0xc000006e: "logon failed: account restriction",
+	0xc0000071: "logon failed: password expired",
+	0xc0000072: "logon failed: account disabled",
+	0xc00000bb: "not supported; the server may only accept SMB 3.1.1",
+	0xc00000cc: "no such share",
+	0xc000015b: "logon failed: the account may not log on from the network",
+}
+
+// SMBOptions says which share an smb check connects to, and as whom. The
+// zero value only checks that the server negotiates SMB2 or later.
+type SMBOptions struct {
+	Share    string // Share name, e.g. "backups"; empty only negotiates
+	User     string // Login, optionally DOMAIN\user; empty logs in anonymously
+	Password string
+	Port     int // 0 means DefaultSMBPort
+}
+
+type SMBResult struct {
+	Latency         time.Duration
+	Dialect         string // e.g. "3.0.2"
+	SigningRequired bool
+	Guest           bool // The server logged the user in as guest
+	Addr            string
+	OK              bool
+	Err             error
+}
+
+// SMBConnect negotiates SMB2 with host and, if opts names a share, logs in
+// with NTLMv2 and connects to the share, as mounting it would. A tcp check
+// of port 445 passes even when the SMB service has stopped answering, the
+// share is gone or the login is refused; this doesn't.
+func SMBConnect(host string, timeout time.Duration, opts SMBOptions) SMBResult {
+	port := opts.Port
+	if port == 0 {
+		port = DefaultSMBPort
+	}
+	start := time.Now()
+	conn, err := net.DialTimeout("tcp", net.JoinHostPort(host, strconv.Itoa(port)), timeout)
+	if err != nil {
+		return SMBResult{Addr: dialedIP(err), Err: err}
+	}
+	defer conn.Close()
+	_ = conn.SetDeadline(start.Add(timeout))
+	res := SMBResult{Addr: remoteIP(conn.RemoteAddr())}
+	c := &smbConn{rw: conn}
+	res.Err = c.connect(host, opts, &res)
+	res.Latency = time.Since(start)
+	res.OK = res.Err == nil
+	return res
+}
+
+// smbConn is a client session on one connection
+type smbConn struct {
+	rw         io.ReadWriter
+	dialect    uint16
+	msgID      uint64
+	sessionID  uint64
+	signingKey []byte
+}
+
+func (c *smbConn) connect(host string, opts SMBOptions, res *SMBResult) error {
+	var neg []byte
+	neg = binary.LittleEndian.AppendUint16(neg, 36)
+	neg = binary.LittleEndian.AppendUint16(neg, uint16(len(smbDialects)))
+	neg = binary.LittleEndian.AppendUint16(neg, 1) // Signing enabled
+	neg = append(neg, make([]byte, 2+4)...)        // Reserved, capabilities
+	guid := make([]byte, 16)
+	_, _ = rand.Read(guid)
+	neg = append(neg, guid...)
+	neg = append(neg, make([]byte, 8)...) // Client start time
+	for _, d := range smbDialects {
+		neg = binary.LittleEndian.AppendUint16(neg, d)
+	}
+	status, body, err := c.request(smbNegotiate, neg, 0)
+	if err != nil {
+		return err
+	}
+	if status != 0 {
+		return smbError(status)
+	}
+	if len(body) < 6 {
+		return errors.New("smb: malformed negotiate response")
+	}
+	res.SigningRequired = binary.LittleEndian.Uint16(body[2:])&smbSigningReq != 0
+	c.dialect = binary.LittleEndian.Uint16(body[4:])
+	res.Dialect = fmt.Sprintf("%d.%d", c.dialect>>8, c.dialect>>4&0xf)
+	if c.dialect&0xf != 0 {
+		res.Dialect += fmt.Sprintf(".%d", c.dialect&0xf)
+	}
+	if opts.Share == "" {
+		return nil
+	}
+
+	status, body, err = c.sessionSetup(spnegoInit(ntlmNegotiate()))
+	if err != nil {
+		return err
+	}
+	if status != statusMoreNeeded {
+		return smbError(status)
+	}
+	blob := securityBuffer(body)
+	i := bytes.Index(blob, ntlmSignature)
+	if i < 0 {
+		return errors.New("smb: server didn't offer NTLM")
+	}
+	challenge, err := parseNTLMChallenge(blob[i:])
+	if err != nil {
+		return err
+	}
+	auth, sessionKey := ntlmAuthenticate(challenge, opts.User, opts.Password)
+	status, body, err = c.sessionSetup(spnegoResponse(auth))
+	if err != nil {
+		return err
+	}
+	if status != 0 {
+		return smbError(status)
+	}
+	if len(body) < 4 {
+		return errors.New("smb: malformed session setup response")
+	}
+	flags := binary.LittleEndian.Uint16(body[2:])
+	res.Guest = flags&(smbSessionGuest|smbSessionNull) != 0 && opts.User != ""
+	if flags&smbSessionCrypt != 0 {
+		return errors.New("smb: the server requires encryption, which this check doesn't support")
+	}
+	if sessionKey != nil && flags&(smbSessionGuest|smbSessionNull) == 0 {
+		c.signingKey = c.deriveSigningKey(sessionKey)
+	}
+
+	path := utf16LE(`\\` + host + `\` + opts.Share)
+	var tree []byte
+	tree = binary.LittleEndian.AppendUint16(tree, 9)
+	tree = binary.LittleEndian.AppendUint16(tree, 0)
+	tree = binary.LittleEndian.AppendUint16(tree, smbHeaderLen+8)
+	tree = binary.LittleEndian.AppendUint16(tree, uint16(len(path)))
+	tree = append(tree, path...)
+	status, _, err = c.request(smbTreeConnect, tree, 1)
+	if err != nil {
+		return err
+	}
+	if status != 0 {
+		return smbError(status)
+	}
+	return nil
+}
+
+// sessionSetup sends one leg of the login and returns the response body
+func (c *smbConn) sessionSetup(token []byte) (uint32, []byte, error) {
+	var b []byte
+	b = binary.LittleEndian.AppendUint16(b, 25)
+	b = append(b, 0, 1)                 // Flags, signing enabled
+	b = append(b, make([]byte, 4+4)...) // Capabilities, channel
+	b = binary.LittleEndian.AppendUint16(b, smbHeaderLen+24)
+	b = binary.LittleEndian.AppendUint16(b, uint16(len(token)))
+	b = append(b, make([]byte, 8)...) // Previous session
+	b = append(b, token...)
+	return c.request(smbSessionSetup, b, 1)
+}
+
+// securityBuffer returns the token in a session setup response body
+func securityBuffer(body []byte) []byte {
+	if len(body) < 8 {
+		return nil
+	}
+	off, n := int(binary.LittleEndian.Uint16(body[4:]))-smbHeaderLen, int(binary.LittleEndian.Uint16(body[6:]))
+	if off < 8 || off+n > len(body) {
+		return nil
+	}
+	return body[off : off+n]
+}
+
+// request sends a command and returns the status and body of its response,
+// signing it once the session has a key
+func (c *smbConn) request(cmd uint16, body []byte, creditCharge uint16) (uint32, []byte, error) {
+	h := make([]byte, smbHeaderLen)
+	copy(h, "\xfeSMB")
+	binary.LittleEndian.PutUint16(h[4:], smbHeaderLen)
+	if c.dialect != 0x0202 {
+		binary.LittleEndian.PutUint16(h[6:], creditCharge)
+	}
+	binary.LittleEndian.PutUint16(h[12:], cmd)
+	binary.LittleEndian.PutUint16(h[14:], 1) // Credits requested
+	binary.LittleEndian.PutUint64(h[24:], c.msgID)
+	binary.LittleEndian.PutUint64(h[40:], c.sessionID)
+	msg := append(h, body...)
+	if c.signingKey != nil {
+		binary.LittleEndian.PutUint32(msg[16:], smbFlagSigned)
+		copy(msg[48:], c.sign(msg))
+	}
+	c.msgID++
+
+	frame := binary.BigEndian.AppendUint32(nil, uint32(len(msg)))
+	if _, err := c.rw.Write(append(frame, msg...)); err != nil {
+		return 0, nil, err
+	}
+	for {
+		var hdr [4]byte
+		if _, err := io.ReadFull(c.rw, hdr[:]); err != nil {
+			return 0, nil, smbReadError(err)
+		}
+		n := binary.BigEndian.Uint32(hdr[:])
+		if n < smbHeaderLen || n > smbMaxMessage {
+			return 0, nil, errors.New("smb: not an SMB2 server")
+		}
+		resp := make([]byte, n)
+		if _, err := io.ReadFull(c.rw, resp); err != nil {
+			return 0, nil, smbReadError(err)
+		}
+		if string(resp[:4]) != "\xfeSMB" {
+			return 0, nil, errors.New("smb: not an SMB2 server")
+		}
+		status := binary.LittleEndian.Uint32(resp[8:])
+		flags := binary.LittleEndian.Uint32(resp[16:])
+		if status == statusPending && flags&smbFlagAsync != 0 {
+			continue // An interim response; the real one follows
+		}
+		if cmd == smbSessionSetup {
+			c.sessionID = binary.LittleEndian.Uint64(resp[40:])
+		}
+		return status, resp[smbHeaderLen:], nil
+	}
+}
+
+// deriveSigningKey turns the session key into the key messages are signed
+// with: the key itself for SMB 2, or one derived from it for SMB 3
+func (c *smbConn) deriveSigningKey(sessionKey []byte) []byte {
+	if c.dialect < 0x0300 {
+		return sessionKey
+	}
+	// SP800-108 counter mode KDF, MS-SMB2 3.1.4.2
+	var in []byte
+	in = binary.BigEndian.AppendUint32(in, 1)
+	in = append(in, "SMB2AESCMAC\x00\x00SmbSign\x00"...)
+	in = binary.BigEndian.AppendUint32(in, 128)
+	h := hmac.New(sha256.New, sessionKey)
+	h.Write(in)
+	return h.Sum(nil)[:16]
+}
+
+// sign returns msg's signature: HMAC-SHA256 for SMB 2, AES-CMAC for SMB 3
+func (c *smbConn) sign(msg []byte) []byte {
+	if c.dialect < 0x0300 {
+		h := hmac.New(sha256.New, c.signingKey)
+		h.Write(msg)
+		return h.Sum(nil)[:16]
+	}
+	return aesCMAC(c.signingKey, msg)
+}
+
+// aesCMAC is AES-CMAC (RFC 4493)
+func aesCMAC(key, msg []byte) []byte {
+	block, _ := aes.NewCipher(key) // Always 16 bytes
+	subkey := func(b []byte) []byte {
+		out := make([]byte, 16)
+		for i := range 15 {
+			out[i] = b[i]<<1 | b[i+1]>>7
+		}
+		out[15] = b[15] << 1
+		if b[0]&0x80 != 0 {
+			out[15] ^= 0x87
+		}
+		return out
+	}
+	l := make([]byte, 16)
+	block.Encrypt(l, l)
+	k1 := subkey(l)
+	k2 := subkey(k1)
+
+	n := (len(msg) + 15) / 16
+	last := make([]byte, 16)
+	if n > 0 && len(msg)%16 == 0 {
+		copy(last, msg[(n-1)*16:])
+		for i := range last {
+			last[i] ^= k1[i]
+		}
+	} else {
+		if n == 0 {
+			n = 1
+		}
+		rest := msg[(n-1)*16:]
+		copy(last, rest)
+		last[len(rest)] = 0x80
+		for i := range last {
+			last[i] ^= k2[i]
+		}
+	}
+	x := make([]byte, 16)
+	for i := range n - 1 {
+		for j := range 16 {
+			x[j] ^= msg[i*16+j]
+		}
+		block.Encrypt(x, x)
+	}
+	for j := range 16 {
+		x[j] ^= last[j]
+	}
+	block.Encrypt(x, x)
+	return x
+}
+
+func smbError(status uint32) error {
+	if msg, ok := smbStatusText[status]; ok {
+		return errors.New(msg)
+	}
+	return fmt.Errorf("smb: status 0x%08x", status)
+}
+
+func smbReadError(err error) error {
+	if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+		return errors.New("smb: connection closed by the server")
+	}
+	return err
+}
+
+// SPNEGO wrappers (RFC 4178) for NTLM tokens
+var (
+	oidSPNEGO = []byte{0x06, 0x06, 0x2b, 0x06, 0x01, 0x05, 0x05, 0x02}
+	oidNTLM   = []byte{0x06, 0x0a, 0x2b, 0x06, 0x01, 0x04, 0x01, 0x82, 0x37, 0x02, 0x02, 0x0a}
+)
+
+func spnegoInit(token []byte) []byte {
+	mechTypes := der(0xa0, der(0x30, oidNTLM))
+	mechToken := der(0xa2, der(0x04, token))
+	return der(0x60, append(append([]byte(nil), oidSPNEGO...), der(0xa0, der(0x30, append(mechTypes, mechToken...)))...))
+}
+
+func spnegoResponse(token []byte) []byte {
+	return der(0xa1, der(0x30, der(0xa2, der(0x04, token))))
+}
+
+// der encodes a DER tag, length and contents
+func der(tag byte, content []byte) []byte {
+	b := []byte{tag}
+	switch n := len(content); {
+	case n < 0x80:
+		b = append(b, byte(n))
+	case n < 0x100:
+		b = append(b, 0x81, byte(n))
+	default:
+		b = append(b, 0x82, byte(n>>8), byte(n))
+	}
+	return append(b, content...)
+}
@@ -0,0 +1,194 @@
+package checks
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os/exec"
+	"strconv"
+	"time"
+)
+
+const (
+	// DefaultIperfPort is where iperf3 servers listen unless configured otherwise
+	DefaultIperfPort = 5201
+	// SpeedtestDuration is how long a speedtest transfers data for: downloads
+	// stop after this, and iperf3 runs for as long
+	SpeedtestDuration = 10 * time.Second
+	// DefaultSpeedtestInterval is how often a speedtest check runs unless
+	// configured otherwise. Each run uses as much bandwidth as it can get, so
+	// it runs far less often than other checks.
+	DefaultSpeedtestInterval = time.Hour
+	// speedtestMinBytes is the least a download must carry to be timed;
+	// less arrives before TCP has got up to speed
+	speedtestMinBytes = 1 << 20
+)
+
+// SpeedtestOptions says how a speedtest check measures bandwidth and how
+// fast it must be
+type SpeedtestOptions struct {
+	URL      string        // File to download; empty runs iperf3 against the host instead
+	Upload   bool          // iperf3 measures sending to the server rather than receiving from it
+	Port     int           // iperf3 server port; 0 means DefaultIperfPort
+	MinSpeed int64         // Fail below this many bits per second; 0 for no limit
+	Interval time.Duration // How often the check runs; 0 means DefaultSpeedtestInterval
+
+	Identity ProbeIdentity // User-Agent and probe ID header of downloads, set per run
+}
+
+type SpeedtestResult struct {
+	Latency  time.Duration // Time to the response headers for downloads, the whole run for iperf3
+	Speed    int64         // Bits per second
+	Bytes    int64         // Data transferred
+	Duration time.Duration // How long the transfer took
+	Addr     string        // IP address of the server, or the last one tried
+	OK       bool          // Measured, and at least opts.MinSpeed
+	Err      error
+}
+
+// Every returns how often a speedtest check runs
+func (o SpeedtestOptions) Every() time.Duration {
+	if o.Interval <= 0 {
+		return DefaultSpeedtestInterval
+	}
+	return o.Interval
+}
+
+// Speedtest measures bandwidth to host by downloading opts.URL for up to
+// SpeedtestDuration or, without a URL, by running the system iperf3 client
+// against the iperf3 server on host, downloading unless opts.Upload is set.
+// It fails when the speed is below opts.MinSpeed.
+func Speedtest(host string, timeout time.Duration, opts SpeedtestOptions) SpeedtestResult {
+	var res SpeedtestResult
+	if opts.URL != "" {
+		res = download(timeout, opts)
+	} else {
+		res = iperf(host, timeout, opts)
+	}
+	res.OK = res.Err == nil && res.Speed >= opts.MinSpeed
+	return res
+}
+
+// download times fetching opts.URL, stopping after SpeedtestDuration so
+// large files can be used on fast links
+func download(timeout time.Duration, opts SpeedtestOptions) SpeedtestResult {
+	client, err := newHTTPClient(timeout, HTTPOptions{})
+	if err != nil {
+		return SpeedtestResult{Err: err}
+	}
+	var addr tracedAddr
+	req, err := http.NewRequestWithContext(addr.context(context.Background()), http.MethodGet, opts.URL, nil)
+	if err != nil {
+		return SpeedtestResult{Err: err}
+	}
+	// Time the bytes on the wire, which compression would shrink
+	req.Header.Set("Accept-Encoding", "identity")
+	opts.Identity.setHeaders(req.Header)
+	req.Close = true
+	start := time.Now()
+	resp, err := client.Do(req)
+	if err != nil {
+		return SpeedtestResult{Addr: addr.get(), Err: err}
+	}
+	defer resp.Body.Close()
+	res := SpeedtestResult{Latency: time.Since(start), Addr: addr.get()}
+	if resp.StatusCode != http.StatusOK {
+		res.Err = fmt.Errorf("status %s", resp.Status)
+		return res
+	}
+	buf := make([]byte, 64<<10)
+	first := time.Now()
+	for res.Duration < SpeedtestDuration {
+		n, err := resp.Body.Read(buf)
+		res.Bytes += int64(n)
+		res.Duration = time.Since(first)
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			res.Err = err
+			return res
+		}
+	}
+	if res.Bytes < speedtestMinBytes {
+		res.Err = fmt.Errorf("the file is only %s; use one big enough to take a few seconds", FormatBytes(res.Bytes))
+		return res
+	}
+	res.Speed = int64(float64(res.Bytes*8) / res.Duration.Seconds())
+	return res
+}
+
+// iperfReport is the part of iperf3's JSON output a speedtest reads
+type iperfReport struct {
+	Start struct {
+		Connected []struct {
+			RemoteHost string `json:"remote_host"`
+		} `json:"connected"`
+	} `json:"start"`
+	End struct {
+		SumReceived struct {
+			Seconds       float64 `json:"seconds"`
+			Bytes         int64   `json:"bytes"`
+			BitsPerSecond float64 `json:"bits_per_second"`
+		} `json:"sum_received"`
+	} `json:"end"`
+	Error string `json:"error"`
+}
+
+// iperf runs the iperf3 client against host for SpeedtestDuration and reads
+// the speed the receiving end saw
+func iperf(host string, timeout time.Duration, opts SpeedtestOptions) SpeedtestResult {
+	port := opts.Port
+	if port == 0 {
+		port = DefaultIperfPort
+	}
+	args := []string{"-c", host, "-p", strconv.Itoa(port), "-J", "-t", strconv.Itoa(int(SpeedtestDuration.Seconds()))}
+	if !opts.Upload {
+		args = append(args, "-R") // The server sends
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	start := time.Now()
+	out, err := exec.CommandContext(ctx, "iperf3", args...).Output()
+	res := SpeedtestResult{Latency: time.Since(start)}
+
+	var exitErr *exec.ExitError
+	switch {
+	case ctx.Err() != nil:
+		res.Err = fmt.Errorf("iperf3: timed out after %v", timeout)
+		return res
+	case errors.Is(err, exec.ErrNotFound):
+		res.Err = errors.New("iperf3 isn't installed on the monitor")
+		return res
+	case err != nil && !errors.As(err, &exitErr):
+		res.Err = fmt.Errorf("iperf3: %w", err)
+		return res
+	}
+	// iperf3 reports its own errors in the JSON, exiting 1
+	var report iperfReport
+	if jsonErr := json.Unmarshal(out, &report); jsonErr != nil {
+		if exitErr != nil && len(exitErr.Stderr) > 0 {
+			res.Err = fmt.Errorf("iperf3: %s", lastLine(string(exitErr.Stderr)))
+		} else {
+			res.Err = errors.New("iperf3: malformed report")
+		}
+		return res
+	}
+	if len(report.Start.Connected) > 0 {
+		res.Addr = report.Start.Connected[0].RemoteHost
+	}
+	if report.Error != "" {
+		res.Err = errors.New("iperf3: " + report.Error)
+		return res
+	}
+	sum := report.End.SumReceived
+	res.Bytes, res.Duration = sum.Bytes, time.Duration(sum.Seconds*float64(time.Second))
+	res.Speed = int64(sum.BitsPerSecond)
+	if res.Speed <= 0 {
+		res.Err = errors.New("iperf3: nothing was received")
+	}
+	return res
+}
@@ -0,0 +1,145 @@
+package checks
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os/exec"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// DefaultSSHPort is used when an ssh check sets no port
+	DefaultSSHPort = 22
+	// MaxSSHOutput is how much of a command's output SSHRun keeps
+	MaxSSHOutput = 1024
+	// sshFailure is the exit status ssh itself uses for connection and auth errors
+	sshFailure = 255
+)
+
+// SSHOptions describes the remote command an ssh check runs and what it
+// should produce. Authentication is key-based only: ssh runs in batch mode,
+// so a check never hangs waiting for a password.
+type SSHOptions struct {
+	User         string // Remote user; empty uses the local ssh default
+	KeyFile      string // Private key path; empty uses the agent and default keys
+	Port         int    // 0 means DefaultSSHPort
+	Command      string // Run by the remote shell
+	ExpectExit   int    // Required exit status, normally 0
+	ExpectOutput string // Optional regexp the output must match
+}
+
+type SSHResult struct {
+	Latency  time.Duration
+	ExitCode int    // -1 if the command didn't run
+	Output   string // Up to MaxSSHOutput bytes of combined stdout and stderr
+	OK       bool   // Exit status and output were as expected
+	Err      error  // Why the check failed
+}
+
+// SSHRun runs opts.Command on host with the system ssh client and checks its
+// exit status and output. New host keys are accepted and remembered; a
+// changed key fails the check, as it would interactively.
+func SSHRun(host string, timeout time.Duration, opts SSHOptions) SSHResult {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	args := append(sshArgs(host, timeout, opts.User, opts.KeyFile, opts.Port), opts.Command)
+
+	var out bytes.Buffer
+	cmd := exec.CommandContext(ctx, "ssh", args...)
+	cmd.Stdout = &out
+	cmd.Stderr = &out
+	start := time.Now()
+	err := cmd.Run()
+	res := SSHResult{Latency: time.Since(start), ExitCode: -1}
+	res.Output = strings.TrimSpace(truncate(out.String(), MaxSSHOutput))
+
+	var exitErr *exec.ExitError
+	switch {
+	case ctx.Err() != nil:
+		res.Err = fmt.Errorf("ssh: timed out after %v", timeout)
+		return res
+	case errors.As(err, &exitErr):
+		res.ExitCode = exitErr.ExitCode()
+	case err != nil:
+		res.Err = fmt.Errorf("ssh: %w", err)
+		return res
+	default:
+		res.ExitCode = 0
+	}
+	if res.ExitCode == sshFailure && opts.ExpectExit != sshFailure {
+		// ssh's own diagnostics already start with "ssh:"
+		msg := lastLine(res.Output)
+		if msg == "" {
+			msg = "ssh: connection failed"
+		}
+		res.Err = errors.New(msg)
+		return res
+	}
+	res.Err = opts.verify(res.ExitCode, res.Output)
+	res.OK = res.Err == nil
+	return res
+}
+
+// sshArgs are the ssh client arguments to log in to host, ending with the
+// destination so a command or -s subsystem can follow
+func sshArgs(host string, timeout time.Duration, user, keyFile string, port int) []string {
+	if port == 0 {
+		port = DefaultSSHPort
+	}
+	args := []string{
+		"-o", "BatchMode=yes",
+		"-o", "StrictHostKeyChecking=accept-new",
+		"-o", "ConnectTimeout=" + strconv.Itoa(max(1, int(timeout.Seconds()))),
+		"-p", strconv.Itoa(port),
+	}
+	if keyFile != "" {
+		args = append(args, "-i", keyFile, "-o", "IdentitiesOnly=yes")
+	}
+	target := host
+	if user != "" {
+		target = user + "@" + host
+	}
+	return append(args, "--", target)
+}
+
+// verify compares a command's exit status and output with what opts expects
+func (o SSHOptions) verify(exitCode int, output string) error {
+	if exitCode != o.ExpectExit {
+		if output != "" {
+			return fmt.Errorf("exit %d (expect %d): %s", exitCode, o.ExpectExit, lastLine(output))
+		}
+		return fmt.Errorf("exit %d (expect %d)", exitCode, o.ExpectExit)
+	}
+	if o.ExpectOutput == "" {
+		return nil
+	}
+	re, err := regexp.Compile(o.ExpectOutput)
+	if err != nil {
+		return fmt.Errorf("bad output pattern: %w", err)
+	}
+	if !re.MatchString(output) {
+		return fmt.Errorf("output doesn't match %q", o.ExpectOutput)
+	}
+	return nil
+}
+
+func truncate(s string, n int) string {
+	if len(s) > n {
+		return s[:n]
+	}
+	return s
+}
+
+func lastLine(s string) string {
+	s = strings.TrimSpace(s)
+	if i := strings.LastIndexByte(s, '\n'); i >= 0 {
+		return s[i+1:]
+	}
+	return s
+}
@@ -1,26 +1,31 @@
 package checks
 
 import (
-	"fmt"
 	"net"
+	"strconv"
 	"time"
 )
 
 type TCPResult struct {
 	Latency time.Duration
 	OK      bool
+	Addr    string // IP address connected to, or tried
 	Err     error
 }
 
 // TCPCheck attempts to connect to a TCP port and returns the result
-func TCPCheck(host string, port int, timeout time.Duration) TCPResult {
-	addr := fmt.Sprintf("%s:%d", host, port)
-	start := time.Now()
-	conn, err := net.DialTimeout("tcp", addr, timeout)
+func TCPCheck(host string, port int, timeout time.Duration, opts DialOptions) TCPResult {
+	d, err := opts.dialer(timeout)
 	if err != nil {
 		return TCPResult{OK: false, Err: err}
 	}
+	addr := net.JoinHostPort(host, strconv.Itoa(port))
+	start := time.Now()
+	conn, err := d.Dial(opts.network("tcp"), addr)
+	if err != nil {
+		return TCPResult{OK: false, Addr: dialedIP(err), Err: err}
+	}
 	defer conn.Close()
 	lat := time.Since(start)
-	return TCPResult{OK: true, Latency: lat}
+	return TCPResult{OK: true, Latency: lat, Addr: remoteIP(conn.RemoteAddr())}
 }
@@ -0,0 +1,57 @@
+package checks
+
+import (
+	"net"
+	"testing"
+	"time"
+)
+
+// listen opens a TCP listener on a free loopback port, accepting and
+// closing connections until the test ends
+func listen(t *testing.T) int {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			conn.Close()
+		}
+	}()
+	return ln.Addr().(*net.TCPAddr).Port
+}
+
+// closedPort returns a loopback port nothing is listening on
+func closedPort(t *testing.T) int {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	port := ln.Addr().(*net.TCPAddr).Port
+	ln.Close()
+	return port
+}
+
+func TestTCPCheck(t *testing.T) {
+	port := listen(t)
+	res := TCPCheck("127.0.0.1", port, time.Second, DialOptions{})
+	if !res.OK || res.Err != nil {
+		t.Fatalf("TCPCheck of an open port = %v, %v", res.OK, res.Err)
+	}
+	if res.Addr != "127.0.0.1" {
+		t.Errorf("Addr = %q, want 127.0.0.1", res.Addr)
+	}
+
+	port = closedPort(t)
+	res = TCPCheck("127.0.0.1", port, time.Second, DialOptions{})
+	if res.OK || res.Err == nil {
+		t.Errorf("TCPCheck of closed port %d = ok %v, err %v; want a failure", port, res.OK, res.Err)
+	}
+}
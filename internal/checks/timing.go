@@ -0,0 +1,88 @@
+package checks
+
+import (
+	"context"
+	"crypto/tls"
+	"net/http/httptrace"
+	"sync"
+	"time"
+)
+
+// HTTPTiming splits an HTTP check's latency into its phases, so a slowdown
+// can be put down to DNS, the network, TLS or the server. Phases that didn't
+// happen, e.g. DNS for an IP address, are zero.
+type HTTPTiming struct {
+	DNS     time.Duration // Looking up the host name
+	Connect time.Duration // Opening the TCP connection
+	TLS     time.Duration // TLS handshake
+	TTFB    time.Duration // From the request being sent to the first byte of the response
+}
+
+// Total is the time the phases account for
+func (t HTTPTiming) Total() time.Duration {
+	return t.DNS + t.Connect + t.TLS + t.TTFB
+}
+
+// IsZero reports whether no phase was timed
+func (t HTTPTiming) IsZero() bool {
+	return t == HTTPTiming{}
+}
+
+// httpTimer times the phases of a traced request. Each redirect hop starts
+// over, so the timing is the final response's.
+type httpTimer struct {
+	mu                                           sync.Mutex
+	dnsStart, connectStart, tlsStart, wroteStart time.Time
+	timing                                       HTTPTiming
+}
+
+func (t *httpTimer) context(ctx context.Context) context.Context {
+	return httptrace.WithClientTrace(ctx, &httptrace.ClientTrace{
+		GetConn:  func(string) { t.reset() },
+		DNSStart: func(httptrace.DNSStartInfo) { t.start(&t.dnsStart) },
+		DNSDone:  func(httptrace.DNSDoneInfo) { t.stop(&t.dnsStart, &t.timing.DNS) },
+		// Dual-stack dials may race two connections; the first to finish is
+		// the one kept
+		ConnectStart: func(_, _ string) { t.start(&t.connectStart) },
+		ConnectDone: func(_, _ string, err error) {
+			if err == nil {
+				t.stop(&t.connectStart, &t.timing.Connect)
+			}
+		},
+		TLSHandshakeStart:    func() { t.start(&t.tlsStart) },
+		TLSHandshakeDone:     func(tls.ConnectionState, error) { t.stop(&t.tlsStart, &t.timing.TLS) },
+		WroteRequest:         func(httptrace.WroteRequestInfo) { t.start(&t.wroteStart) },
+		GotFirstResponseByte: func() { t.stop(&t.wroteStart, &t.timing.TTFB) },
+	})
+}
+
+func (t *httpTimer) reset() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.dnsStart, t.connectStart, t.tlsStart, t.wroteStart = time.Time{}, time.Time{}, time.Time{}, time.Time{}
+	t.timing = HTTPTiming{}
+}
+
+// start notes when a phase began, unless it already has
+func (t *httpTimer) start(at *time.Time) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if at.IsZero() {
+		*at = time.Now()
+	}
+}
+
+// stop times a phase that was started and not yet timed
+func (t *httpTimer) stop(at *time.Time, d *time.Duration) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if !at.IsZero() && *d == 0 {
+		*d = time.Since(*at)
+	}
+}
+
+func (t *httpTimer) get() HTTPTiming {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.timing
+}
@@ -0,0 +1,168 @@
+package checks
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"slices"
+	"time"
+)
+
+// CertExpiryWarning is how close to expiry a certificate is warned about
+const CertExpiryWarning = 14 * 24 * time.Hour
+
+// TLSInfo describes the TLS connection a probe made and the certificates
+// the server presented
+type TLSInfo struct {
+	Version   uint16     // e.g. tls.VersionTLS13
+	Cipher    uint16     // Cipher suite ID
+	ALPN      string     // Negotiated protocol, e.g. "h2", if any
+	Chain     []CertInfo // As presented, the server's own certificate first
+	VerifyErr string     // Why the chain doesn't verify, for checks that skip verification
+}
+
+// CertInfo is what the details panel shows of one certificate
+type CertInfo struct {
+	Subject    string
+	Issuer     string
+	SANs       []string // DNS names and IP addresses it covers
+	NotBefore  time.Time
+	NotAfter   time.Time
+	Serial     string
+	SigAlg     string // e.g. "SHA256-RSA"
+	Key        string // e.g. "RSA 2048" or "ECDSA P-256"
+	SelfSigned bool
+	WeakSig    bool // Signed with MD5 or SHA-1
+	WeakKey    bool // RSA key under 2048 bits
+}
+
+// newTLSInfo records cs. When verification was skipped, the chain is
+// checked against the system roots here, so the panel can say whether it
+// would have passed.
+func newTLSInfo(cs *tls.ConnectionState, serverName string, skippedVerify bool) *TLSInfo {
+	if cs == nil || len(cs.PeerCertificates) == 0 {
+		return nil
+	}
+	info := &TLSInfo{Version: cs.Version, Cipher: cs.CipherSuite, ALPN: cs.NegotiatedProtocol}
+	for _, cert := range cs.PeerCertificates {
+		info.Chain = append(info.Chain, newCertInfo(cert))
+	}
+	if skippedVerify {
+		intermediates := x509.NewCertPool()
+		for _, cert := range cs.PeerCertificates[1:] {
+			intermediates.AddCert(cert)
+		}
+		_, err := cs.PeerCertificates[0].Verify(x509.VerifyOptions{DNSName: serverName, Intermediates: intermediates})
+		if err != nil {
+			info.VerifyErr = err.Error()
+		}
+	}
+	return info
+}
+
+func newCertInfo(cert *x509.Certificate) CertInfo {
+	ci := CertInfo{
+		Subject:    cert.Subject.String(),
+		Issuer:     cert.Issuer.String(),
+		SANs:       slices.Clone(cert.DNSNames),
+		NotBefore:  cert.NotBefore,
+		NotAfter:   cert.NotAfter,
+		Serial:     fmt.Sprintf("%X", cert.SerialNumber),
+		SigAlg:     cert.SignatureAlgorithm.String(),
+		SelfSigned: cert.CheckSignatureFrom(cert) == nil,
+	}
+	for _, ip := range cert.IPAddresses {
+		ci.SANs = append(ci.SANs, ip.String())
+	}
+	switch cert.SignatureAlgorithm {
+	case x509.MD2WithRSA, x509.MD5WithRSA, x509.SHA1WithRSA, x509.DSAWithSHA1, x509.ECDSAWithSHA1:
+		ci.WeakSig = true
+	}
+	switch key := cert.PublicKey.(type) {
+	case *rsa.PublicKey:
+		ci.Key = fmt.Sprintf("RSA %d", key.N.BitLen())
+		ci.WeakKey = key.N.BitLen() < 2048
+	case *ecdsa.PublicKey:
+		ci.Key = "ECDSA " + key.Curve.Params().Name
+	case ed25519.PublicKey:
+		ci.Key = "Ed25519"
+	default:
+		ci.Key = cert.PublicKeyAlgorithm.String()
+	}
+	return ci
+}
+
+// VersionName names the protocol version, e.g. "TLS 1.3"
+func (t TLSInfo) VersionName() string {
+	return tls.VersionName(t.Version)
+}
+
+// CipherName names the cipher suite, e.g. "TLS_AES_128_GCM_SHA256"
+func (t TLSInfo) CipherName() string {
+	return tls.CipherSuiteName(t.Cipher)
+}
+
+// Leaf is the server's own certificate
+func (t TLSInfo) Leaf() CertInfo {
+	if len(t.Chain) == 0 {
+		return CertInfo{}
+	}
+	return t.Chain[0]
+}
+
+// Warnings lists what is wrong or about to go wrong with the connection at
+// now: an outdated protocol or cipher, a certificate expiring within
+// CertExpiryWarning, weak signatures or keys, and a chain that doesn't verify
+func (t TLSInfo) Warnings(now time.Time) []string {
+	var out []string
+	if t.Version < tls.VersionTLS12 {
+		out = append(out, t.VersionName()+" is outdated; TLS 1.2 or later should be used")
+	}
+	if slices.ContainsFunc(tls.InsecureCipherSuites(), func(cs *tls.CipherSuite) bool { return cs.ID == t.Cipher }) {
+		out = append(out, "cipher "+t.CipherName()+" is insecure")
+	}
+	if len(t.Chain) > 0 {
+		leaf := t.Chain[0]
+		switch left := leaf.NotAfter.Sub(now); {
+		case left <= 0:
+			out = append(out, "certificate has expired")
+		case left < CertExpiryWarning:
+			out = append(out, "certificate expires in "+FormatDays(left))
+		}
+		if leaf.NotBefore.After(now) {
+			out = append(out, "certificate isn't valid yet")
+		}
+	}
+	for i, c := range t.Chain {
+		// A root's own signature isn't checked, so it doesn't matter
+		if c.WeakSig && !(c.SelfSigned && i > 0) {
+			out = append(out, fmt.Sprintf("%s is signed with %s", c.Subject, c.SigAlg))
+		}
+		if c.WeakKey {
+			out = append(out, fmt.Sprintf("%s has a weak %s key", c.Subject, c.Key))
+		}
+	}
+	if t.VerifyErr != "" {
+		out = append(out, "chain doesn't verify: "+t.VerifyErr)
+	}
+	return out
+}
+
+// FormatDays renders d as whole days, or hours under a day
+func FormatDays(d time.Duration) string {
+	if d < 24*time.Hour {
+		h := int(d.Hours())
+		if h == 1 {
+			return "1 hour"
+		}
+		return fmt.Sprintf("%d hours", h)
+	}
+	days := int(d.Hours() / 24)
+	if days == 1 {
+		return "1 day"
+	}
+	return fmt.Sprintf("%d days", days)
+}
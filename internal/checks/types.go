@@ -1,11 +1,97 @@
 package checks
 
-import "time"
+import (
+	"fmt"
+	"time"
+)
 
 type PingResult struct {
 	OK        bool
-	Latency   time.Duration
+	Latency   time.Duration // Mean of the replies
+	StdDev    time.Duration // Standard deviation of the replies, when several were sent
+	Jitter    time.Duration // Mean difference between consecutive replies, likewise
 	PacketsTx int
 	PacketsRx int
 	Err       error
+	Method    string // How the host was reached: "icmp", "udp", "exec" or "tcp/<port>"
+	Addr      string // IP address pinged
+}
+
+// RoundLatency trims d to three significant figures, e.g. 412µs, 12.3ms or
+// 1.25s. A single probe isn't timed any more precisely than that.
+func RoundLatency(d time.Duration) time.Duration {
+	unit := time.Duration(1)
+	for d >= 1000*unit {
+		unit *= 10
+	}
+	return d.Round(unit)
+}
+
+// FormatLatency renders d rounded by RoundLatency in the largest unit that
+// keeps it above 1, so LAN pings show as 412µs rather than 0ms
+func FormatLatency(d time.Duration) string {
+	if d <= 0 {
+		return "0ms"
+	}
+	return RoundLatency(d).String()
+}
+
+// FormatBytes renders n in the largest binary unit that keeps it above 1,
+// to three significant figures, e.g. 312 B, 12.3 KB or 1.25 MB
+func FormatBytes(n int64) string {
+	if n < 1024 {
+		return fmt.Sprintf("%d B", n)
+	}
+	v := float64(n)
+	unit := 0
+	for v >= 1024 && unit < 4 {
+		v /= 1024
+		unit++
+	}
+	units := [...]string{"B", "KB", "MB", "GB", "TB"}
+	switch {
+	case v >= 100:
+		return fmt.Sprintf("%.0f %s", v, units[unit])
+	case v >= 10:
+		return fmt.Sprintf("%.1f %s", v, units[unit])
+	}
+	return fmt.Sprintf("%.2f %s", v, units[unit])
+}
+
+// FormatSpeed renders bits per second in the largest decimal unit that keeps
+// it above 1, as network speeds are quoted, e.g. 850 kbps or 94.2 Mbps
+func FormatSpeed(bps int64) string {
+	if bps < 1000 {
+		return fmt.Sprintf("%d bps", bps)
+	}
+	v := float64(bps)
+	unit := 0
+	for v >= 1000 && unit < 3 {
+		v /= 1000
+		unit++
+	}
+	units := [...]string{"bps", "kbps", "Mbps", "Gbps"}
+	switch {
+	case v >= 100:
+		return fmt.Sprintf("%.0f %s", v, units[unit])
+	case v >= 10:
+		return fmt.Sprintf("%.1f %s", v, units[unit])
+	}
+	return fmt.Sprintf("%.2f %s", v, units[unit])
+}
+
+// FormatSpeedLimit writes a speed limit as it is configured, e.g. 50Mbps,
+// or "" for none
+func FormatSpeedLimit(bps int64) string {
+	switch {
+	case bps <= 0:
+		return ""
+	case bps%1_000_000_000 == 0:
+		return fmt.Sprintf("%dGbps", bps/1_000_000_000)
+	case bps%1_000_000 == 0:
+		return fmt.Sprintf("%dMbps", bps/1_000_000)
+	case bps%1_000 == 0:
+		return fmt.Sprintf("%dkbps", bps/1_000)
+	}
+	return fmt.Sprintf("%dbps", bps)
 }
@@ -0,0 +1,352 @@
+package checks
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// UPS daemons a ups check can ask
+const (
+	UPSNUT     = "nut"     // Network UPS Tools' upsd
+	UPSApcupsd = "apcupsd" // apcupsd's network information server
+)
+
+// Ports the UPS daemons listen on unless configured otherwise
+const (
+	DefaultNUTPort     = 3493
+	DefaultApcupsdPort = 3551
+)
+
+const upsMaxLines = 1000
+
+// UPSOptions says which daemon a ups check asks and when the battery counts
+// as low
+type UPSOptions struct {
+	Daemon    string // UPSNUT or UPSApcupsd; empty means UPSNUT
+	UPS       string // NUT's name for the UPS; empty means the only one upsd serves
+	MinCharge int    // Fail when the battery is charged less than this percentage; 0 leaves it to the UPS
+	Port      int    // 0 means the daemon's default port
+}
+
+type UPSResult struct {
+	Latency time.Duration
+	Model   string        // e.g. "APC Back-UPS XS 700U"; empty if not reported
+	Status  []string      // What the UPS reports, e.g. "online", "charging"
+	Alarms  []string      // The reasons it fails, e.g. "on battery" or "battery 40% (below 50%)"
+	Charge  float64       // Battery charge in percent, or -1 when not reported
+	Runtime time.Duration // Estimated time left on battery, 0 when not reported
+	Load    float64       // Output load in percent, or -1 when not reported
+	Addr    string        // IP address the daemon answered on, or the last one tried
+	OK      bool          // The UPS is on mains power, its battery isn't low and nothing needs attention
+	Err     error
+}
+
+// UPSStatus asks the NUT or apcupsd daemon on host for the state of its UPS.
+// It fails when the UPS is on battery, reports a low battery, a battery that
+// needs replacing, an overload or a shutdown, or, with opts.MinCharge, when
+// the battery is charged less than that.
+func UPSStatus(host string, timeout time.Duration, opts UPSOptions) UPSResult {
+	port := opts.Port
+	if port == 0 {
+		port = DefaultNUTPort
+		if opts.Daemon == UPSApcupsd {
+			port = DefaultApcupsdPort
+		}
+	}
+	start := time.Now()
+	conn, err := net.DialTimeout("tcp", net.JoinHostPort(host, strconv.Itoa(port)), timeout)
+	if err != nil {
+		return UPSResult{Addr: dialedIP(err), Err: err}
+	}
+	defer conn.Close()
+	_ = conn.SetDeadline(start.Add(timeout))
+	res := UPSResult{Addr: remoteIP(conn.RemoteAddr()), Charge: -1, Load: -1}
+	if opts.Daemon == UPSApcupsd {
+		res.Err = res.apcupsd(conn)
+	} else {
+		res.Err = res.nut(conn, opts.UPS)
+	}
+	res.Latency = time.Since(start)
+	if res.Err != nil {
+		return res
+	}
+	if opts.MinCharge > 0 && res.Charge >= 0 && res.Charge < float64(opts.MinCharge) {
+		res.Alarms = append(res.Alarms, fmt.Sprintf("battery %.0f%% (below %d%%)", res.Charge, opts.MinCharge))
+	}
+	res.OK = len(res.Alarms) == 0
+	return res
+}
+
+// nutStatus describes the flags of NUT's ups.status, and whether each fails
+// the check
+var nutStatus = map[string]struct {
+	desc  string
+	alarm bool
+}{
+	"OL":      {"online", false},
+	"OB":      {"on battery", true},
+	"LB":      {"low battery", true},
+	"HB":      {"high battery", false},
+	"RB":      {"replace battery", true},
+	"CHRG":    {"charging", false},
+	"DISCHRG": {"discharging", false},
+	"BYPASS":  {"on bypass", true},
+	"CAL":     {"calibrating", false},
+	"OFF":     {"output off", true},
+	"OVER":    {"overloaded", true},
+	"TRIM":    {"trimming voltage", false},
+	"BOOST":   {"boosting voltage", false},
+	"FSD":     {"forced shutdown", true},
+	"ALARM":   {"alarm", true},
+}
+
+// nut asks upsd for the variables of ups, or of the only UPS it serves
+func (r *UPSResult) nut(conn net.Conn, ups string) error {
+	rd := bufio.NewReader(conn)
+	if ups == "" {
+		names, err := nutList(conn, rd, "UPS", "")
+		if err != nil {
+			return err
+		}
+		switch len(names) {
+		case 0:
+			return errors.New("upsd serves no UPS")
+		case 1:
+			ups = names[0][0]
+		default:
+			var list []string
+			for _, n := range names {
+				list = append(list, n[0])
+			}
+			return fmt.Errorf("upsd serves %d UPSes (%s); set which to check", len(list), strings.Join(list, ", "))
+		}
+	}
+	vars, err := nutList(conn, rd, "VAR", ups)
+	if err != nil {
+		return err
+	}
+	_, _ = io.WriteString(conn, "LOGOUT\n")
+	v := map[string]string{}
+	for _, kv := range vars {
+		if len(kv) == 2 {
+			v[kv[0]] = kv[1]
+		}
+	}
+	status, ok := v["ups.status"]
+	if !ok {
+		return errors.New("upsd didn't report the UPS's status")
+	}
+	r.Model = strings.TrimSpace(strings.TrimSpace(v["device.mfr"]) + " " + strings.TrimSpace(v["device.model"]))
+	if r.Model == "" {
+		r.Model = strings.TrimSpace(strings.TrimSpace(v["ups.mfr"]) + " " + strings.TrimSpace(v["ups.model"]))
+	}
+	for _, flag := range strings.Fields(status) {
+		s, ok := nutStatus[flag]
+		if !ok {
+			r.Status = append(r.Status, strings.ToLower(flag))
+			continue
+		}
+		r.Status = append(r.Status, s.desc)
+		if s.alarm {
+			r.Alarms = append(r.Alarms, s.desc)
+		}
+	}
+	if alarm := strings.TrimSpace(v["ups.alarm"]); alarm != "" {
+		r.Alarms = append(r.Alarms, alarm)
+	}
+	if f, err := strconv.ParseFloat(v["battery.charge"], 64); err == nil {
+		r.Charge = f
+	}
+	if f, err := strconv.ParseFloat(v["battery.runtime"], 64); err == nil {
+		r.Runtime = time.Duration(f) * time.Second
+	}
+	if f, err := strconv.ParseFloat(v["ups.load"], 64); err == nil {
+		r.Load = f
+	}
+	return nil
+}
+
+// nutList sends "LIST <what> <ups>" and returns each entry's fields after
+// the UPS name, e.g. [battery.charge 100] for a VAR
+func nutList(conn net.Conn, rd *bufio.Reader, what, ups string) ([][]string, error) {
+	cmd := "LIST " + what
+	if ups != "" {
+		cmd += " " + ups
+	}
+	if _, err := io.WriteString(conn, cmd+"\n"); err != nil {
+		return nil, err
+	}
+	var entries [][]string
+	for range upsMaxLines {
+		line, err := rd.ReadString('\n')
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				err = errors.New("nut: upsd closed the connection")
+			}
+			return nil, err
+		}
+		fields := nutFields(strings.TrimRight(line, "\r\n"))
+		switch {
+		case len(fields) == 0:
+			continue
+		case fields[0] == "ERR":
+			return nil, nutError(fields, ups)
+		case fields[0] == "BEGIN":
+			continue
+		case fields[0] == "END":
+			return entries, nil
+		case fields[0] == what && ups == "" && len(fields) >= 2:
+			entries = append(entries, fields[1:])
+		case fields[0] == what && len(fields) >= 3:
+			entries = append(entries, fields[2:])
+		default:
+			return nil, errors.New("nut: not a NUT upsd")
+		}
+	}
+	return nil, errors.New("nut: reply too long")
+}
+
+// nutFields splits a upsd reply into words, unquoting quoted ones
+func nutFields(line string) []string {
+	var fields []string
+	for line = strings.TrimLeft(line, " "); line != ""; line = strings.TrimLeft(line, " ") {
+		if line[0] != '"' {
+			word, rest, _ := strings.Cut(line, " ")
+			fields = append(fields, word)
+			line = rest
+			continue
+		}
+		var b strings.Builder
+		i := 1
+		for ; i < len(line) && line[i] != '"'; i++ {
+			if line[i] == '\\' && i+1 < len(line) {
+				i++
+			}
+			b.WriteByte(line[i])
+		}
+		fields = append(fields, b.String())
+		line = line[min(i+1, len(line)):]
+	}
+	return fields
+}
+
+// nutError explains an ERR reply from upsd
+func nutError(fields []string, ups string) error {
+	code := ""
+	if len(fields) > 1 {
+		code = fields[1]
+	}
+	switch code {
+	case "UNKNOWN-UPS":
+		return fmt.Errorf("no such UPS %q", ups)
+	case "ACCESS-DENIED":
+		return errors.New("upsd denied access; check its LISTEN address and firewall")
+	case "DATA-STALE":
+		return errors.New("upsd's data is stale; is the UPS connected?")
+	case "DRIVER-NOT-CONNECTED":
+		return errors.New("upsd's driver for the UPS isn't running")
+	}
+	return fmt.Errorf("nut: upsd error %s", code)
+}
+
+// apcupsdStatus describes the words of apcupsd's STATUS, and whether each
+// fails the check
+var apcupsdStatus = map[string]struct {
+	desc  string
+	alarm bool
+}{
+	"ONLINE":      {"online", false},
+	"ONBATT":      {"on battery", true},
+	"LOWBATT":     {"low battery", true},
+	"REPLACEBATT": {"replace battery", true},
+	"CAL":         {"calibrating", false},
+	"TRIM":        {"trimming voltage", false},
+	"BOOST":       {"boosting voltage", false},
+	"OVERLOAD":    {"overloaded", true},
+	"SHUTTING":    {"shutting down", true},
+	"NOBATT":      {"no battery", true},
+	"SLAVE":       {"slave", false},
+	"SLAVEDOWN":   {"master down", true},
+}
+
+// apcupsd sends apcupsd's network information server the status command and
+// reads its KEY : value lines
+func (r *UPSResult) apcupsd(conn net.Conn) error {
+	if _, err := conn.Write(binary.BigEndian.AppendUint16(nil, 6)); err != nil {
+		return err
+	}
+	if _, err := io.WriteString(conn, "status"); err != nil {
+		return err
+	}
+	v := map[string]string{}
+	for range upsMaxLines {
+		var size [2]byte
+		if _, err := io.ReadFull(conn, size[:]); err != nil {
+			if errors.Is(err, io.EOF) {
+				err = errors.New("apcupsd: connection closed; is NETSERVER on and the monitor allowed?")
+			}
+			return err
+		}
+		n := binary.BigEndian.Uint16(size[:])
+		if n == 0 {
+			return r.apcupsdValues(v)
+		}
+		line := make([]byte, n)
+		if _, err := io.ReadFull(conn, line); err != nil {
+			return err
+		}
+		key, value, ok := strings.Cut(string(line), ":")
+		if !ok {
+			return errors.New("apcupsd: not an apcupsd network information server")
+		}
+		v[strings.TrimSpace(key)] = strings.TrimSpace(value)
+	}
+	return errors.New("apcupsd: reply too long")
+}
+
+func (r *UPSResult) apcupsdValues(v map[string]string) error {
+	status, ok := v["STATUS"]
+	if !ok {
+		return errors.New("apcupsd didn't report the UPS's status")
+	}
+	if status == "COMMLOST" {
+		return errors.New("apcupsd has lost contact with the UPS")
+	}
+	r.Model = v["MODEL"]
+	for _, word := range strings.Fields(status) {
+		if word == "DOWN" {
+			continue // The second word of "SHUTTING DOWN"
+		}
+		s, ok := apcupsdStatus[word]
+		if !ok {
+			r.Status = append(r.Status, strings.ToLower(word))
+			continue
+		}
+		r.Status = append(r.Status, s.desc)
+		if s.alarm {
+			r.Alarms = append(r.Alarms, s.desc)
+		}
+	}
+	// Values carry units, e.g. "100.0 Percent" or "45.0 Minutes"
+	number := func(key string) (float64, bool) {
+		f, err := strconv.ParseFloat(strings.Fields(v[key] + " x")[0], 64)
+		return f, err == nil
+	}
+	if f, ok := number("BCHARGE"); ok {
+		r.Charge = f
+	}
+	if f, ok := number("TIMELEFT"); ok {
+		r.Runtime = time.Duration(f * float64(time.Minute))
+	}
+	if f, ok := number("LOADPCT"); ok {
+		r.Load = f
+	}
+	return nil
+}
@@ -0,0 +1,102 @@
+package checks
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net/http"
+	"regexp"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// WebSocketOptions controls the optional exchange after a WebSocket
+// handshake. The zero value only checks that the upgrade succeeds.
+type WebSocketOptions struct {
+	Send   string // Text message to send once connected
+	Expect string // Regexp the first message received must match
+
+	Identity ProbeIdentity // User-Agent and probe ID header of the handshake
+}
+
+type WebSocketResult struct {
+	Latency time.Duration // Handshake, plus the round trip if a reply was awaited
+	Code    int           // HTTP status of the upgrade response, if any
+	Reply   string        // Up to MaxBodySnippet bytes of the first message received
+	Addr    string        // IP address connected to, or tried
+	TLS     *TLSInfo      // The connection, for wss
+	OK      bool
+	Err     error
+}
+
+// WebSocketProbe opens a WebSocket connection to url (ws:// or wss://). If
+// opts sends a message or expects one, it then waits for the first message
+// from the server and checks it against opts.Expect.
+func WebSocketProbe(url string, timeout time.Duration, opts WebSocketOptions) WebSocketResult {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	dialer := websocket.Dialer{HandshakeTimeout: timeout}
+	var addr tracedAddr
+	start := time.Now()
+	header := http.Header{}
+	opts.Identity.setHeaders(header)
+	conn, resp, err := dialer.DialContext(addr.context(ctx), url, header)
+	res := WebSocketResult{Latency: time.Since(start), Addr: addr.get()}
+	if res.Addr == "" {
+		res.Addr = dialedIP(err)
+	}
+	if resp != nil {
+		res.Code = resp.StatusCode
+	}
+	if err != nil {
+		if err == websocket.ErrBadHandshake && resp != nil {
+			res.Err = fmt.Errorf("handshake refused: %s", resp.Status)
+		} else {
+			res.Err = err
+		}
+		return res
+	}
+	defer conn.Close()
+	if tc, ok := conn.UnderlyingConn().(*tls.Conn); ok {
+		cs := tc.ConnectionState()
+		res.TLS = newTLSInfo(&cs, "", false)
+	}
+
+	if opts.Send != "" || opts.Expect != "" {
+		deadline, _ := ctx.Deadline()
+		_ = conn.SetWriteDeadline(deadline)
+		_ = conn.SetReadDeadline(deadline)
+		if opts.Send != "" {
+			if err := conn.WriteMessage(websocket.TextMessage, []byte(opts.Send)); err != nil {
+				res.Err = fmt.Errorf("send: %w", err)
+				return res
+			}
+		}
+		_, msg, err := conn.ReadMessage()
+		res.Latency = time.Since(start)
+		if err != nil {
+			res.Err = fmt.Errorf("no reply: %w", err)
+			return res
+		}
+		res.Reply = truncate(string(msg), MaxBodySnippet)
+		if opts.Expect != "" {
+			re, err := regexp.Compile(opts.Expect)
+			if err != nil {
+				res.Err = fmt.Errorf("bad reply pattern: %w", err)
+				return res
+			}
+			if !re.MatchString(res.Reply) {
+				res.Err = fmt.Errorf("reply doesn't match %q", opts.Expect)
+				return res
+			}
+		}
+	}
+
+	// Best effort; the check has passed whether or not the server acks
+	_ = conn.WriteControl(websocket.CloseMessage,
+		websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""), time.Now().Add(time.Second))
+	res.OK = true
+	return res
+}
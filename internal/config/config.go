@@ -1,41 +1,335 @@
 package config
 
 import (
+	"crypto/subtle"
 	"fmt"
 	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+	_ "time/tzdata" // Timezones work on hosts without a zoneinfo database
 
 	"github.com/knadh/koanf/parsers/toml"
 	"github.com/knadh/koanf/parsers/yaml"
 	"github.com/knadh/koanf/providers/file"
 	"github.com/knadh/koanf/v2"
+
+	"github.com/andrewsjg/simple-healthchecker/copilot/internal/validate"
 )
 
 type CheckType string
 
 const (
-	CheckPing CheckType = "ping"
-	CheckHTTP CheckType = "http"
-	CheckTCP  CheckType = "tcp"
+	CheckPing  CheckType = "ping"
+	CheckHTTP  CheckType = "http"
+	CheckTCP   CheckType = "tcp"
+	CheckSSH   CheckType = "ssh"
+	CheckWS    CheckType = "websocket"
+	CheckPorts CheckType = "ports"
+	// CheckComposite runs no probe of its own; it combines other checks'
+	// results by ID
+	CheckComposite CheckType = "composite"
+	// CheckWebhook runs no probe either; whatever it watches, such as a CI
+	// pipeline or backup script, posts its results to /api/webhook/<id>
+	CheckWebhook CheckType = "webhook"
+	// CheckFile fails when a file, or the newest file in a directory, is
+	// older than max_age, e.g. to catch backups that stopped running
+	CheckFile CheckType = "file"
+	// CheckS3 sends a signed HEAD for a bucket, or an object in it, on an
+	// S3-compatible store at url
+	CheckS3 CheckType = "s3"
+	// CheckIPP asks the printer at url for its state, failing when it has
+	// stopped or reports an error such as a paper jam
+	CheckIPP CheckType = "ipp"
+	// CheckSMB negotiates SMB with the host and connects to a share, as
+	// mounting it would
+	CheckSMB CheckType = "smb"
+	// CheckKafka asks the Kafka broker on the host for cluster metadata,
+	// failing when a partition has no leader
+	CheckKafka CheckType = "kafka"
+	// CheckAMQP opens an AMQP 0-9-1 connection to the broker on the host,
+	// e.g. RabbitMQ, logging in when a username is set
+	CheckAMQP CheckType = "amqp"
+	// CheckKubernetes asks a Kubernetes API server whether every node is
+	// ready, or whether a deployment has all its replicas available
+	CheckKubernetes CheckType = "kubernetes"
+	// CheckProxmox asks the Proxmox VE API on the host whether its
+	// cluster's nodes are online and a guest is running
+	CheckProxmox CheckType = "proxmox"
+	// CheckESXi logs in to the vSphere API of the ESXi host for its health
+	// and whether a VM is powered on
+	CheckESXi CheckType = "esxi"
+	// CheckUPS asks the NUT or apcupsd daemon on the host about its UPS,
+	// failing when it is on battery or its battery is low
+	CheckUPS CheckType = "ups"
+	// CheckSpeedtest measures bandwidth by downloading a file or with
+	// iperf3, failing when it is slower than a limit
+	CheckSpeedtest CheckType = "speedtest"
+	// CheckPublicIP asks a lookup service for the monitor's public address,
+	// notifying when it changes, e.g. for a home line with a dynamic one
+	CheckPublicIP CheckType = "publicip"
+	// CheckDomain asks a domain's registry over RDAP or WHOIS when its
+	// registration expires, failing weeks before it lapses
+	CheckDomain CheckType = "domain"
+	// CheckDNSBL looks the host's addresses up on DNS blocklists, failing
+	// when one lists them, e.g. for a mail server
+	CheckDNSBL CheckType = "dnsbl"
+)
+
+// LatencyCheckTypes are the check types whose latency is how long the
+// target took to answer, so they can have an expected latency. Composite
+// and webhook checks run no probe, and the rest time work, such as a
+// download or a registry lookup, rather than the target.
+var LatencyCheckTypes = []CheckType{
+	CheckPing, CheckTCP, CheckHTTP, CheckWS, CheckSSH, CheckPorts, CheckS3, CheckIPP, CheckSMB,
+	CheckKafka, CheckAMQP, CheckKubernetes, CheckProxmox, CheckESXi, CheckUPS,
+}
+
+// Severity says how much a failing check matters
+type Severity string
+
+const (
+	SeverityInfo     Severity = "info"     // Shown on the dashboard but not counted against overall health
+	SeverityWarning  Severity = "warning"  // The default
+	SeverityCritical Severity = "critical" // Highest notification priority
 )
 
+// OrDefault returns s, or SeverityWarning if it is unset
+func (s Severity) OrDefault() Severity {
+	if s == "" {
+		return SeverityWarning
+	}
+	return s
+}
+
 type Check struct {
 	Type           CheckType `koanf:"type" json:"type" yaml:"type" toml:"type"`
+	Name           string    `koanf:"name" json:"name,omitempty" yaml:"name,omitempty" toml:"name,omitempty"` // Display name, e.g. "Plex web UI"; defaults to the type and target
 	Enabled        bool      `koanf:"enabled" json:"enabled" yaml:"enabled" toml:"enabled"`
 	URL            string    `koanf:"url" json:"url" yaml:"url" toml:"url"`
 	Expect         int       `koanf:"expect" json:"expect" yaml:"expect" toml:"expect"`
-	Port           int       `koanf:"port" json:"port" yaml:"port" toml:"port"`                                             // TCP port for tcp checks
-	ID             string    `koanf:"id" json:"id" yaml:"id" toml:"id"`                                                     // Optional unique identifier for this check
-	DependsOn      string    `koanf:"depends_on" json:"depends_on" yaml:"depends_on" toml:"depends_on"`                     // ID of check this depends on
-	MQTTNotify     bool      `koanf:"mqtt_notify" json:"mqtt_notify" yaml:"mqtt_notify" toml:"mqtt_notify"`                 // Send MQTT notifications on state change
-	PushoverNotify bool      `koanf:"pushover_notify" json:"pushover_notify" yaml:"pushover_notify" toml:"pushover_notify"` // Send Pushover notifications
-	TelegramNotify bool      `koanf:"telegram_notify" json:"telegram_notify" yaml:"telegram_notify" toml:"telegram_notify"` // Send Telegram notifications
+	Port           int       `koanf:"port" json:"port" yaml:"port" toml:"port"`                                                           // TCP port for tcp checks
+	ID             string    `koanf:"id" json:"id" yaml:"id" toml:"id"`                                                                   // Optional unique identifier for this check
+	DependsOn      string    `koanf:"depends_on" json:"depends_on" yaml:"depends_on" toml:"depends_on"`                                   // ID of check this depends on
+	MQTTNotify     bool      `koanf:"mqtt_notify" json:"mqtt_notify" yaml:"mqtt_notify" toml:"mqtt_notify"`                               // Send MQTT notifications on state change
+	PushoverNotify bool      `koanf:"pushover_notify" json:"pushover_notify" yaml:"pushover_notify" toml:"pushover_notify"`               // Send Pushover notifications
+	TelegramNotify bool      `koanf:"telegram_notify" json:"telegram_notify" yaml:"telegram_notify" toml:"telegram_notify"`               // Send Telegram notifications
+	Notes          string    `koanf:"notes" json:"notes,omitempty" yaml:"notes,omitempty" toml:"notes,omitempty"`                         // What this check covers, shown on the dashboard and in alerts
+	RunbookURL     string    `koanf:"runbook_url" json:"runbook_url,omitempty" yaml:"runbook_url,omitempty" toml:"runbook_url,omitempty"` // Where to start when this check fails
+	Severity       Severity  `koanf:"severity" json:"severity,omitempty" yaml:"severity,omitempty" toml:"severity,omitempty"`             // info, warning (default) or critical
+
+	// Pass when the probe fails, e.g. to make sure a management interface
+	// isn't exposed or that a machine meant to be off really is
+	Invert bool `koanf:"invert" json:"invert,omitempty" yaml:"invert,omitempty" toml:"invert,omitempty"`
+
+	// Degraded when it passes but takes longer, e.g. "5ms"; overrides the
+	// check type's settings.latency.expected, and "off" ignores that
+	ExpectedLatency string `koanf:"expected_latency" json:"expected_latency,omitempty" yaml:"expected_latency,omitempty" toml:"expected_latency,omitempty"`
+
+	// Further notification channels, set in the edit dialog or here
+	ShoutrrrNotify []string `koanf:"shoutrrr_notify" json:"shoutrrr_notify,omitempty" yaml:"shoutrrr_notify,omitempty" toml:"shoutrrr_notify,omitempty"` // Labels of the Shoutrrr URLs to notify (see ShoutrrrSettings)
+	SMSNotify      bool     `koanf:"sms_notify" json:"sms_notify,omitempty" yaml:"sms_notify,omitempty" toml:"sms_notify,omitempty"`                     // Text the Twilio numbers; critical checks may also call them
+
+	// HTTP client options, only used by http checks (s3 checks also use
+	// insecure_skip_verify)
+	NoFollowRedirects  bool   `koanf:"no_follow_redirects" json:"no_follow_redirects,omitempty" yaml:"no_follow_redirects,omitempty" toml:"no_follow_redirects,omitempty"`     // Report redirects instead of following them
+	MaxRedirects       int    `koanf:"max_redirects" json:"max_redirects,omitempty" yaml:"max_redirects,omitempty" toml:"max_redirects,omitempty"`                             // Redirect hops to follow (default 10)
+	Proxy              string `koanf:"proxy" json:"proxy,omitempty" yaml:"proxy,omitempty" toml:"proxy,omitempty"`                                                             // http://, https:// or socks5:// proxy URL
+	InsecureSkipVerify bool   `koanf:"insecure_skip_verify" json:"insecure_skip_verify,omitempty" yaml:"insecure_skip_verify,omitempty" toml:"insecure_skip_verify,omitempty"` // Accept self-signed certificates
+	MustContain        string `koanf:"must_contain" json:"must_contain,omitempty" yaml:"must_contain,omitempty" toml:"must_contain,omitempty"`                                 // Fail unless the body contains this text
+	MustNotContain     string `koanf:"must_not_contain" json:"must_not_contain,omitempty" yaml:"must_not_contain,omitempty" toml:"must_not_contain,omitempty"`                 // Fail if the body contains this text
+	WatchContent       bool   `koanf:"watch_content" json:"watch_content,omitempty" yaml:"watch_content,omitempty" toml:"watch_content,omitempty"`                             // Fail when the body changes until the change is accepted
+	ContentHash        string `koanf:"content_hash" json:"content_hash,omitempty" yaml:"content_hash,omitempty" toml:"content_hash,omitempty"`                                 // Accepted body hash, maintained by watch_content
+	MinSize            int64  `koanf:"min_size" json:"min_size,omitempty" yaml:"min_size,omitempty" toml:"min_size,omitempty"`                                                 // Fail if the body is smaller, in bytes
+	MaxSize            int64  `koanf:"max_size" json:"max_size,omitempty" yaml:"max_size,omitempty" toml:"max_size,omitempty"`                                                 // Fail if the body is larger, in bytes
+
+	// Sent by http and websocket checks; overrides settings.probes.user_agent
+	UserAgent string `koanf:"user_agent" json:"user_agent,omitempty" yaml:"user_agent,omitempty" toml:"user_agent,omitempty"`
+
+	// Connection options, used by http, tcp and ports checks
+	IPVersion int    `koanf:"ip_version" json:"ip_version,omitempty" yaml:"ip_version,omitempty" toml:"ip_version,omitempty"` // 4 or 6 to force that address family
+	Source    string `koanf:"source" json:"source,omitempty" yaml:"source,omitempty" toml:"source,omitempty"`                 // Local IP address or interface name to connect from
+
+	// Ping options, only used by ping checks
+	PingMethod string `koanf:"ping_method" json:"ping_method,omitempty" yaml:"ping_method,omitempty" toml:"ping_method,omitempty"` // auto (default), icmp, unprivileged or tcp
+	PingPort   int    `koanf:"ping_port" json:"ping_port,omitempty" yaml:"ping_port,omitempty" toml:"ping_port,omitempty"`         // Port for tcp pings (default 80)
+	PingCount  int    `koanf:"ping_count" json:"ping_count,omitempty" yaml:"ping_count,omitempty" toml:"ping_count,omitempty"`     // Echo requests per run (default 1); two or more measure jitter
+	MaxJitter  string `koanf:"max_jitter" json:"max_jitter,omitempty" yaml:"max_jitter,omitempty" toml:"max_jitter,omitempty"`     // Fail when a run's jitter is higher, e.g. "30ms"
+
+	// Remote command options, only used by ssh checks (port defaults to 22)
+	SSHUser      string `koanf:"ssh_user" json:"ssh_user,omitempty" yaml:"ssh_user,omitempty" toml:"ssh_user,omitempty"`                     // Remote user
+	SSHKey       string `koanf:"ssh_key" json:"ssh_key,omitempty" yaml:"ssh_key,omitempty" toml:"ssh_key,omitempty"`                         // Private key file
+	Command      string `koanf:"command" json:"command,omitempty" yaml:"command,omitempty" toml:"command,omitempty"`                         // Command to run on the host
+	ExpectExit   int    `koanf:"expect_exit" json:"expect_exit,omitempty" yaml:"expect_exit,omitempty" toml:"expect_exit,omitempty"`         // Required exit status (default 0)
+	ExpectOutput string `koanf:"expect_output" json:"expect_output,omitempty" yaml:"expect_output,omitempty" toml:"expect_output,omitempty"` // Regexp the output must match
+
+	// Message exchange, only used by websocket checks (which use url)
+	WSSend   string `koanf:"ws_send" json:"ws_send,omitempty" yaml:"ws_send,omitempty" toml:"ws_send,omitempty"`         // Text message to send after the handshake
+	WSExpect string `koanf:"ws_expect" json:"ws_expect,omitempty" yaml:"ws_expect,omitempty" toml:"ws_expect,omitempty"` // Regexp the first reply must match
+
+	// Healthchecks.io signalling. By default only ping checks report to the
+	// host's healthchecks_ping_url; set healthchecks to choose for any check.
+	// A check with its own URL pings it with its own result alone.
+	Healthchecks        *bool  `koanf:"healthchecks" json:"healthchecks,omitempty" yaml:"healthchecks,omitempty" toml:"healthchecks,omitempty"`                                     // Report this check's result to the host's URL
+	HealthchecksPingURL string `koanf:"healthchecks_ping_url" json:"healthchecks_ping_url,omitempty" yaml:"healthchecks_ping_url,omitempty" toml:"healthchecks_ping_url,omitempty"` // This check's own ping URL
+
+	// Port scan, only used by ports checks: lists and ranges such as
+	// "22,80,8000-8010". Ports refusing or ignoring connections count as closed.
+	Ports       string `koanf:"ports" json:"ports,omitempty" yaml:"ports,omitempty" toml:"ports,omitempty"`                             // Ports that must be open
+	ClosedPorts string `koanf:"closed_ports" json:"closed_ports,omitempty" yaml:"closed_ports,omitempty" toml:"closed_ports,omitempty"` // Ports that must not be open, e.g. "23" for telnet
+
+	// Member check IDs, only used by composite checks, which pass when every
+	// all_of check and at least one any_of check is up
+	AllOf []string `koanf:"all_of" json:"all_of,omitempty" yaml:"all_of,omitempty" toml:"all_of,omitempty"`
+	AnyOf []string `koanf:"any_of" json:"any_of,omitempty" yaml:"any_of,omitempty" toml:"any_of,omitempty"`
+
+	// Inbound results, only used by webhook checks, which need an id
+	WebhookToken string `koanf:"webhook_token" json:"webhook_token,omitempty" yaml:"webhook_token,omitempty" toml:"webhook_token,omitempty"` // Bearer token senders must give; required when sign-in is on
+	MaxAge       string `koanf:"max_age" json:"max_age,omitempty" yaml:"max_age,omitempty" toml:"max_age,omitempty"`                         // Fail when no result has arrived, or the file hasn't changed, for this long, e.g. "25h"
+
+	// File freshness, only used by file checks (with max_age). Over SFTP the
+	// login uses ssh_user, ssh_key and port, as for ssh checks.
+	Path string `koanf:"path" json:"path,omitempty" yaml:"path,omitempty" toml:"path,omitempty"` // File, or directory whose newest entry counts
+	SFTP bool   `koanf:"sftp" json:"sftp,omitempty" yaml:"sftp,omitempty" toml:"sftp,omitempty"` // Read path on the host over SFTP instead of on the monitor
+
+	// Object storage, only used by s3 checks, whose url is the endpoint. Set
+	// object to require it to exist and, with max_age, to be recent.
+	Bucket    string `koanf:"bucket" json:"bucket,omitempty" yaml:"bucket,omitempty" toml:"bucket,omitempty"`                 // Empty if the url names it
+	Object    string `koanf:"object" json:"object,omitempty" yaml:"object,omitempty" toml:"object,omitempty"`                 // Key of an object that must exist
+	Region    string `koanf:"region" json:"region,omitempty" yaml:"region,omitempty" toml:"region,omitempty"`                 // Signing region (default us-east-1)
+	AccessKey string `koanf:"access_key" json:"access_key,omitempty" yaml:"access_key,omitempty" toml:"access_key,omitempty"` // Empty for unsigned requests to a public bucket
+	SecretKey string `koanf:"secret_key" json:"secret_key,omitempty" yaml:"secret_key,omitempty" toml:"secret_key,omitempty"`
+
+	// File shares, only used by smb checks, which connect to the host's
+	// address on port (default 445). Without a share only the server's
+	// SMB negotiation is checked.
+	Share string `koanf:"share" json:"share,omitempty" yaml:"share,omitempty" toml:"share,omitempty"` // Share name, e.g. "backups"
+
+	// Message brokers: kafka checks connect to the host's address on port
+	// (default 9092) and amqp checks on port (default 5672, or 5671 with
+	// tls), opening vhost when they log in
+	Topic string `koanf:"topic" json:"topic,omitempty" yaml:"topic,omitempty" toml:"topic,omitempty"` // Kafka topic whose partitions must all have leaders; empty checks every topic
+	VHost string `koanf:"vhost" json:"vhost,omitempty" yaml:"vhost,omitempty" toml:"vhost,omitempty"` // AMQP virtual host (default "/")
+	TLS   bool   `koanf:"tls" json:"tls,omitempty" yaml:"tls,omitempty" toml:"tls,omitempty"`         // Connect to the AMQP broker with TLS
+
+	// Clusters, only used by kubernetes checks, whose url is the API server,
+	// e.g. https://k3s:6443. Either url or kubeconfig is needed; with both,
+	// url replaces the kubeconfig's server. Without a deployment every node
+	// must be ready.
+	Kubeconfig  string `koanf:"kubeconfig" json:"kubeconfig,omitempty" yaml:"kubeconfig,omitempty" toml:"kubeconfig,omitempty"`         // Path to a kubeconfig file on the monitor
+	KubeContext string `koanf:"kube_context" json:"kube_context,omitempty" yaml:"kube_context,omitempty" toml:"kube_context,omitempty"` // Kubeconfig context (default its current context)
+	Token       string `koanf:"token" json:"token,omitempty" yaml:"token,omitempty" toml:"token,omitempty"`                             // Bearer token, e.g. a service account's, overriding the kubeconfig's; also proxmox checks' API token
+	Deployment  string `koanf:"deployment" json:"deployment,omitempty" yaml:"deployment,omitempty" toml:"deployment,omitempty"`         // "namespace/name", or a name in the context's namespace
+
+	// Hypervisors: proxmox checks log in to the host's address on port
+	// (default 8006) with an API token, written user@realm!tokenid=secret,
+	// and esxi checks on port (default 443) with username and password.
+	// Both fail when memory or a storage pool is fuller than max_usage.
+	Node     string `koanf:"node" json:"node,omitempty" yaml:"node,omitempty" toml:"node,omitempty"`                     // Proxmox cluster node to look at; empty means every node
+	VM       string `koanf:"vm" json:"vm,omitempty" yaml:"vm,omitempty" toml:"vm,omitempty"`                             // Guest, by name (or Proxmox ID), that must be running
+	MaxUsage int    `koanf:"max_usage" json:"max_usage,omitempty" yaml:"max_usage,omitempty" toml:"max_usage,omitempty"` // Percentage (default 90)
+
+	// Power, only used by ups checks, which ask the daemon on the host's
+	// address on port (default 3493 for nut, 3551 for apcupsd)
+	Daemon    string `koanf:"daemon" json:"daemon,omitempty" yaml:"daemon,omitempty" toml:"daemon,omitempty"`                 // nut (default) or apcupsd
+	UPS       string `koanf:"ups" json:"ups,omitempty" yaml:"ups,omitempty" toml:"ups,omitempty"`                             // NUT's name for the UPS; empty when upsd serves only one
+	MinCharge int    `koanf:"min_charge" json:"min_charge,omitempty" yaml:"min_charge,omitempty" toml:"min_charge,omitempty"` // Fail below this battery charge, in percent, as well as on the UPS's own low battery
+
+	// Bandwidth, only used by speedtest checks, which download url or,
+	// without one, run iperf3 against the server on the host's address on
+	// port (default 5201)
+	Upload   bool   `koanf:"upload" json:"upload,omitempty" yaml:"upload,omitempty" toml:"upload,omitempty"`             // iperf3 measures sending rather than receiving
+	MinSpeed string `koanf:"min_speed" json:"min_speed,omitempty" yaml:"min_speed,omitempty" toml:"min_speed,omitempty"` // Fail when slower, e.g. "50Mbps"
+	Interval string `koanf:"interval" json:"interval,omitempty" yaml:"interval,omitempty" toml:"interval,omitempty"`     // How often to test (default 1h, at least 5m)
+
+	// Public address, only used by publicip checks, which ask the lookup
+	// service at url (default https://api64.ipify.org) from the monitor
+	PublicIP string `koanf:"public_ip" json:"public_ip,omitempty" yaml:"public_ip,omitempty" toml:"public_ip,omitempty"` // Address last seen, maintained by the check so a change while stopped is noticed
+
+	// Registrations, only used by domain checks, which look domain up over
+	// RDAP, or WHOIS where the registry has no RDAP, every 6 hours
+	Domain   string `koanf:"domain" json:"domain,omitempty" yaml:"domain,omitempty" toml:"domain,omitempty"`             // e.g. "example.com"; empty means the host's address
+	WarnDays int    `koanf:"warn_days" json:"warn_days,omitempty" yaml:"warn_days,omitempty" toml:"warn_days,omitempty"` // Fail this many days before it expires (default 30)
+
+	// Blocklists, only used by dnsbl checks, which look the host's addresses
+	// up on each DNSBL zone every hour. Empty means zen.spamhaus.org,
+	// bl.spamcop.net, b.barracudacentral.org and psbl.surriel.com.
+	Blocklists []string `koanf:"blocklists" json:"blocklists,omitempty" yaml:"blocklists,omitempty" toml:"blocklists,omitempty"`
+
+	// Login for checks that need one: smb, where an empty username logs in
+	// anonymously and it may be written DOMAIN\user, amqp, where an empty
+	// username only checks the broker starts the handshake, and esxi
+	Username string `koanf:"username" json:"username,omitempty" yaml:"username,omitempty" toml:"username,omitempty"`
+	Password string `koanf:"password" json:"password,omitempty" yaml:"password,omitempty" toml:"password,omitempty"`
+
+	// When the check is monitored, e.g. "mon-fri 07:00-23:00" (see Schedule);
+	// outside it the check isn't run and doesn't count as down. Empty for always.
+	Schedule string `koanf:"schedule" json:"schedule,omitempty" yaml:"schedule,omitempty" toml:"schedule,omitempty"`
+}
+
+// ReportsToHost reports whether the check's result counts towards its host's
+// Healthchecks.io URL
+func (c Check) ReportsToHost() bool {
+	if c.Healthchecks != nil {
+		return *c.Healthchecks
+	}
+	return c.Type == CheckPing
+}
+
+// MaxAgeLimit returns how long a webhook check can go without a result, or
+// a file check's file without changing, before it fails, or 0 for no limit
+func (c Check) MaxAgeLimit() time.Duration {
+	d, _ := time.ParseDuration(c.MaxAge)
+	return max(d, 0)
+}
+
+// JitterLimit returns the jitter above which a ping check fails, or 0 if
+// there is none
+func (c Check) JitterLimit() time.Duration {
+	return optionalDuration(c.MaxJitter)
+}
+
+// LatencyLimit returns the latency above which the check is degraded: its
+// own expected_latency, or else its type's in settings, or 0 for none
+func (c Check) LatencyLimit(l LatencySettings) time.Duration {
+	switch c.ExpectedLatency {
+	case "":
+		return l.ExpectedFor(c.Type)
+	case LatencyOff:
+		return 0
+	}
+	return optionalDuration(c.ExpectedLatency)
+}
+
+// SpeedLimit returns the speed in bits per second below which a speedtest
+// check fails, or 0 if there is none
+func (c Check) SpeedLimit() int64 {
+	bps, _ := validate.Speed(c.MinSpeed)
+	return bps
+}
+
+// SpeedtestInterval returns how often a speedtest check runs, or 0 for the
+// default
+func (c Check) SpeedtestInterval() time.Duration {
+	return optionalDuration(c.Interval)
 }
 
 type Host struct {
-	Name                string  `koanf:"name" json:"name" yaml:"name" toml:"name"`
-	Address             string  `koanf:"address" json:"address" yaml:"address" toml:"address"`
-	Checks              []Check `koanf:"checks" json:"checks" yaml:"checks" toml:"checks"`
-	HealthchecksPingURL string  `koanf:"healthchecks_ping_url" json:"healthchecks_ping_url" yaml:"healthchecks_ping_url" toml:"healthchecks_ping_url"`
+	Name                string   `koanf:"name" json:"name" yaml:"name" toml:"name"`
+	Address             string   `koanf:"address" json:"address" yaml:"address" toml:"address"`
+	Checks              []Check  `koanf:"checks" json:"checks" yaml:"checks" toml:"checks"`
+	HealthchecksPingURL string   `koanf:"healthchecks_ping_url" json:"healthchecks_ping_url" yaml:"healthchecks_ping_url" toml:"healthchecks_ping_url"`
+	Notes               string   `koanf:"notes" json:"notes,omitempty" yaml:"notes,omitempty" toml:"notes,omitempty"`                                         // Free-text notes about the host
+	RunbookURL          string   `koanf:"runbook_url" json:"runbook_url,omitempty" yaml:"runbook_url,omitempty" toml:"runbook_url,omitempty"`                 // Runbook for the host's checks
+	Gateway             bool     `koanf:"gateway" json:"gateway,omitempty" yaml:"gateway,omitempty" toml:"gateway,omitempty"`                                 // Every other host implicitly depends on this one
+	Tags                []string `koanf:"tags" json:"tags,omitempty" yaml:"tags,omitempty" toml:"tags,omitempty"`                                             // Labels for grouping and searching, e.g. "office"
+	AlertIPChange       bool     `koanf:"alert_ip_change" json:"alert_ip_change,omitempty" yaml:"alert_ip_change,omitempty" toml:"alert_ip_change,omitempty"` // Notify when a checked hostname resolves to a new IP
+
+	// Probes at once against this host; overrides settings.concurrency.per_host
+	MaxConcurrentProbes int `koanf:"max_concurrent_probes" json:"max_concurrent_probes,omitempty" yaml:"max_concurrent_probes,omitempty" toml:"max_concurrent_probes,omitempty"`
+
+	// Include file the host was loaded from, if any
+	src hostSource
 }
 
 // MQTTSettings holds MQTT broker configuration
@@ -66,29 +360,465 @@ type TelegramSettings struct {
 	Silent         bool   `koanf:"silent" json:"silent" yaml:"silent" toml:"silent"`                                     // Send without notification sound
 }
 
+// ShoutrrrSettings holds notification URLs for the services Shoutrrr
+// supports (Slack, Discord, Teams, ntfy, Gotify, email and many more)
+type ShoutrrrSettings struct {
+	Enabled bool          `koanf:"enabled" json:"enabled" yaml:"enabled" toml:"enabled"`
+	URLs    []ShoutrrrURL `koanf:"urls" json:"urls" yaml:"urls" toml:"urls"`
+}
+
+// ShoutrrrURL is one notification URL. Checks pick the URLs they notify by
+// label, so one label can cover several services at once.
+type ShoutrrrURL struct {
+	Label string `koanf:"label" json:"label" yaml:"label" toml:"label"`
+	URL   string `koanf:"url" json:"url" yaml:"url" toml:"url"` // e.g. slack://token@channel or ntfy://ntfy.sh/topic
+}
+
+// TwilioSettings holds Twilio credentials for SMS and voice call alerts
+type TwilioSettings struct {
+	Enabled    bool     `koanf:"enabled" json:"enabled" yaml:"enabled" toml:"enabled"`
+	AccountSID string   `koanf:"account_sid" json:"account_sid" yaml:"account_sid" toml:"account_sid"`
+	AuthToken  string   `koanf:"auth_token" json:"auth_token" yaml:"auth_token" toml:"auth_token"`
+	From       string   `koanf:"from" json:"from" yaml:"from" toml:"from"`                                                       // Twilio number to send from, in E.164 format (+14155550100)
+	To         []string `koanf:"to" json:"to" yaml:"to" toml:"to"`                                                               // Numbers to alert, in E.164 format
+	CallAfter  string   `koanf:"call_after" json:"call_after,omitempty" yaml:"call_after,omitempty" toml:"call_after,omitempty"` // Call when a critical check stays down this long, e.g. "15m"; empty disables calls
+}
+
+// CallDelay returns how long a critical check must stay down before the
+// numbers are called, or 0 if calls are off
+func (t TwilioSettings) CallDelay() time.Duration {
+	return optionalDuration(t.CallAfter)
+}
+
+// AlertSettings controls when notifications are sent, across all channels
+type AlertSettings struct {
+	ReminderInterval  string                `koanf:"reminder_interval" json:"reminder_interval,omitempty" yaml:"reminder_interval,omitempty" toml:"reminder_interval,omitempty"`         // Re-notify while a check stays down, e.g. "6h"; empty disables
+	BatchWindow       string                `koanf:"batch_window" json:"batch_window,omitempty" yaml:"batch_window,omitempty" toml:"batch_window,omitempty"`                             // Combine alerts raised within this window, e.g. "30s"; empty disables
+	QuietHours        QuietHours            `koanf:"quiet_hours" json:"quiet_hours,omitempty" yaml:"quiet_hours,omitempty" toml:"quiet_hours,omitempty"`                                 // Applies to Pushover, Telegram, Shoutrrr and SMS
+	ChannelQuietHours map[string]QuietHours `koanf:"channel_quiet_hours" json:"channel_quiet_hours,omitempty" yaml:"channel_quiet_hours,omitempty" toml:"channel_quiet_hours,omitempty"` // Per-channel overrides keyed by "pushover" or "telegram"
+
+	// Failures in this long after startup show as pending and don't alert,
+	// e.g. "2m" while the network comes up after a reboot; empty disables
+	StartupGrace string `koanf:"startup_grace" json:"startup_grace,omitempty" yaml:"startup_grace,omitempty" toml:"startup_grace,omitempty"`
+
+	// A check that changes state more than FlapThreshold times within
+	// FlapWindow (default "1h") is flapping: its alerts are held back until
+	// it settles. 0 disables flap detection.
+	FlapThreshold int    `koanf:"flap_threshold" json:"flap_threshold,omitempty" yaml:"flap_threshold,omitempty" toml:"flap_threshold,omitempty"`
+	FlapWindow    string `koanf:"flap_window" json:"flap_window,omitempty" yaml:"flap_window,omitempty" toml:"flap_window,omitempty"`
+}
+
+// QuietHours is a daily window, in the server's local time, during which
+// non-critical alerts are held back and sent as a digest once it ends.
+// The window may wrap midnight, e.g. 23:00 to 07:00.
+type QuietHours struct {
+	Start string `koanf:"start" json:"start,omitempty" yaml:"start,omitempty" toml:"start,omitempty"` // "HH:MM"
+	End   string `koanf:"end" json:"end,omitempty" yaml:"end,omitempty" toml:"end,omitempty"`         // "HH:MM"
+}
+
+// Enabled reports whether the window is set and non-empty
+func (q QuietHours) Enabled() bool {
+	start, err1 := ParseClock(q.Start)
+	end, err2 := ParseClock(q.End)
+	return err1 == nil && err2 == nil && start != end
+}
+
+// Contains reports whether t, in the server's local time, falls inside the
+// window
+func (q QuietHours) Contains(t time.Time) bool {
+	if !q.Enabled() {
+		return false
+	}
+	t = t.Local()
+	start, _ := ParseClock(q.Start)
+	end, _ := ParseClock(q.End)
+	now := t.Hour()*60 + t.Minute()
+	if start < end {
+		return now >= start && now < end
+	}
+	return now >= start || now < end
+}
+
+// ParseClock parses a "HH:MM" time of day into minutes after midnight
+func ParseClock(s string) (int, error) {
+	t, err := time.Parse("15:04", s)
+	if err != nil {
+		return 0, fmt.Errorf("invalid time of day %q (want HH:MM)", s)
+	}
+	return t.Hour()*60 + t.Minute(), nil
+}
+
+// Reminder returns the still-down reminder interval, or 0 if reminders are off
+func (a AlertSettings) Reminder() time.Duration {
+	return optionalDuration(a.ReminderInterval)
+}
+
+// Batch returns the alert batching window, or 0 if alerts go out individually
+func (a AlertSettings) Batch() time.Duration {
+	return optionalDuration(a.BatchWindow)
+}
+
+// Grace returns the startup grace period, or 0 if failures alert straight away
+func (a AlertSettings) Grace() time.Duration {
+	return optionalDuration(a.StartupGrace)
+}
+
+// Flap returns how many state changes within what window make a check
+// flapping; a threshold of 0 means flap detection is off
+func (a AlertSettings) Flap() (threshold int, window time.Duration) {
+	if a.FlapThreshold <= 0 {
+		return 0, 0
+	}
+	window = optionalDuration(a.FlapWindow)
+	if window == 0 {
+		window = time.Hour
+	}
+	return a.FlapThreshold, window
+}
+
+// optionalDuration parses s, treating empty or invalid values as 0
+func optionalDuration(s string) time.Duration {
+	d, err := time.ParseDuration(s)
+	if err != nil || d < 0 {
+		return 0
+	}
+	return d
+}
+
 // Settings holds application-wide settings
 type Settings struct {
-	MQTT     MQTTSettings     `koanf:"mqtt" json:"mqtt" yaml:"mqtt" toml:"mqtt"`
-	Pushover PushoverSettings `koanf:"pushover" json:"pushover" yaml:"pushover" toml:"pushover"`
-	Telegram TelegramSettings `koanf:"telegram" json:"telegram" yaml:"telegram" toml:"telegram"`
+	MQTT          MQTTSettings          `koanf:"mqtt" json:"mqtt" yaml:"mqtt" toml:"mqtt"`
+	Pushover      PushoverSettings      `koanf:"pushover" json:"pushover" yaml:"pushover" toml:"pushover"`
+	Telegram      TelegramSettings      `koanf:"telegram" json:"telegram" yaml:"telegram" toml:"telegram"`
+	Shoutrrr      ShoutrrrSettings      `koanf:"shoutrrr" json:"shoutrrr,omitempty" yaml:"shoutrrr,omitempty" toml:"shoutrrr,omitempty"`
+	Twilio        TwilioSettings        `koanf:"twilio" json:"twilio,omitempty" yaml:"twilio,omitempty" toml:"twilio,omitempty"`
+	Alerts        AlertSettings         `koanf:"alerts" json:"alerts,omitempty" yaml:"alerts,omitempty" toml:"alerts,omitempty"`
+	SelfCheck     SelfCheckSettings     `koanf:"self_check" json:"self_check,omitempty" yaml:"self_check,omitempty" toml:"self_check,omitempty"`
+	Display       DisplaySettings       `koanf:"display" json:"display,omitempty" yaml:"display,omitempty" toml:"display,omitempty"`
+	Embed         EmbedSettings         `koanf:"embed" json:"embed,omitempty" yaml:"embed,omitempty" toml:"embed,omitempty"`
+	Metrics       MetricsSettings       `koanf:"metrics" json:"metrics,omitempty" yaml:"metrics,omitempty" toml:"metrics,omitempty"`
+	Tracing       TracingSettings       `koanf:"tracing" json:"tracing,omitempty" yaml:"tracing,omitempty" toml:"tracing,omitempty"`
+	HomeAssistant HomeAssistantSettings `koanf:"home_assistant" json:"home_assistant,omitempty" yaml:"home_assistant,omitempty" toml:"home_assistant,omitempty"`
+	Payloads      PayloadSettings       `koanf:"payloads" json:"payloads,omitempty" yaml:"payloads,omitempty" toml:"payloads,omitempty"`
+	Concurrency   ConcurrencySettings   `koanf:"concurrency" json:"concurrency,omitempty" yaml:"concurrency,omitempty" toml:"concurrency,omitempty"`
+	Probes        ProbeSettings         `koanf:"probes" json:"probes,omitempty" yaml:"probes,omitempty" toml:"probes,omitempty"`
+	Remote        RemoteSettings        `koanf:"remote" json:"remote,omitempty" yaml:"remote,omitempty" toml:"remote,omitempty"`
+	Auth          AuthSettings          `koanf:"auth" json:"auth,omitempty" yaml:"auth,omitempty" toml:"auth,omitempty"`
+	Latency       LatencySettings       `koanf:"latency" json:"latency,omitempty" yaml:"latency,omitempty" toml:"latency,omitempty"`
+}
+
+// LatencyOff is a check's expected_latency when it shouldn't be degraded
+// for being slow, whatever its type's default
+const LatencyOff = "off"
+
+// LatencySettings says how fast checks are expected to answer. A check that
+// passes but takes longer than its type's latency is degraded: it still
+// counts as up, but shows as slow and makes its host degraded.
+type LatencySettings struct {
+	Expected map[string]string `koanf:"expected" json:"expected,omitempty" yaml:"expected,omitempty" toml:"expected,omitempty"` // Check type -> latency, e.g. ping: "5ms" on a LAN, http: "500ms"
+
+	// So a check near its limit doesn't flicker between degraded and up, it
+	// is degraded after SlowRuns runs in a row over its expected latency
+	// (default 3), and clears on a run within ClearPercent of it (default
+	// 80, so 4ms for a 5ms limit)
+	SlowRuns     int `koanf:"slow_runs" json:"slow_runs,omitempty" yaml:"slow_runs,omitempty" toml:"slow_runs,omitempty"`
+	ClearPercent int `koanf:"clear_percent" json:"clear_percent,omitempty" yaml:"clear_percent,omitempty" toml:"clear_percent,omitempty"`
+}
+
+const (
+	DefaultSlowRuns     = 3
+	DefaultClearPercent = 80
+)
+
+// ExpectedFor returns the latency above which checks of type t are
+// degraded, or 0 if t has none
+func (l LatencySettings) ExpectedFor(t CheckType) time.Duration {
+	return optionalDuration(l.Expected[string(t)])
+}
+
+// Runs returns how many runs in a row over its expected latency degrade a
+// check
+func (l LatencySettings) Runs() int {
+	if l.SlowRuns <= 0 {
+		return DefaultSlowRuns
+	}
+	return l.SlowRuns
+}
+
+// ClearBelow returns the latency a degraded check expected within limit
+// must answer within to clear
+func (l LatencySettings) ClearBelow(limit time.Duration) time.Duration {
+	pct := l.ClearPercent
+	if pct <= 0 || pct > 100 {
+		pct = DefaultClearPercent
+	}
+	return limit * time.Duration(pct) / 100
+}
+
+// Role is what a signed-in user may do
+type Role string
+
+const (
+	RoleAdmin  Role = "admin"  // Everything
+	RoleViewer Role = "viewer" // Look, but not change anything
+)
+
+// AuthSettings puts the web UI and API behind single sign-on with an OpenID
+// Connect provider, API tokens, or both. Without either, anyone who can
+// reach the server can use it.
+type AuthSettings struct {
+	OIDC        OIDCSettings  `koanf:"oidc" json:"oidc,omitempty" yaml:"oidc,omitempty" toml:"oidc,omitempty"`
+	Roles       []RoleMapping `koanf:"roles" json:"roles,omitempty" yaml:"roles,omitempty" toml:"roles,omitempty"`                             // The role each identity provider group gets
+	DefaultRole Role          `koanf:"default_role" json:"default_role,omitempty" yaml:"default_role,omitempty" toml:"default_role,omitempty"` // For users in none of the groups; empty refuses them
+	DefaultTags []string      `koanf:"default_tags" json:"default_tags,omitempty" yaml:"default_tags,omitempty" toml:"default_tags,omitempty"` // Hosts users in none of the groups see, as for RoleMapping.Tags
+	Tokens      []APIToken    `koanf:"tokens" json:"tokens,omitempty" yaml:"tokens,omitempty" toml:"tokens,omitempty"`                         // Who may use the dashboard and API without signing in
+	Public      []string      `koanf:"public" json:"public,omitempty" yaml:"public,omitempty" toml:"public,omitempty"`                         // Path prefixes left open, e.g. "/metrics" for a scraper
+	SessionTTL  string        `koanf:"session_ttl" json:"session_ttl,omitempty" yaml:"session_ttl,omitempty" toml:"session_ttl,omitempty"`     // How long a sign-in lasts, e.g. "12h" (the default)
+}
+
+// OIDCSettings identifies the OpenID Connect provider and this server's
+// client registration with it
+type OIDCSettings struct {
+	Issuer       string   `koanf:"issuer" json:"issuer,omitempty" yaml:"issuer,omitempty" toml:"issuer,omitempty"` // e.g. https://login.example.com/realms/home
+	ClientID     string   `koanf:"client_id" json:"client_id,omitempty" yaml:"client_id,omitempty" toml:"client_id,omitempty"`
+	ClientSecret string   `koanf:"client_secret" json:"client_secret,omitempty" yaml:"client_secret,omitempty" toml:"client_secret,omitempty"` // Empty for a public client
+	RedirectURL  string   `koanf:"redirect_url" json:"redirect_url,omitempty" yaml:"redirect_url,omitempty" toml:"redirect_url,omitempty"`     // This server's /auth/callback, e.g. https://poke443.example.com/auth/callback
+	Scopes       []string `koanf:"scopes" json:"scopes,omitempty" yaml:"scopes,omitempty" toml:"scopes,omitempty"`                             // Defaults to openid, profile, email and groups
+	GroupsClaim  string   `koanf:"groups_claim" json:"groups_claim,omitempty" yaml:"groups_claim,omitempty" toml:"groups_claim,omitempty"`     // ID token claim listing the user's groups; defaults to "groups"
+}
+
+// RoleMapping gives the members of an identity provider group a role, and
+// can limit the hosts they see, as APIToken.Tags does
+type RoleMapping struct {
+	Group string   `koanf:"group" json:"group" yaml:"group" toml:"group"`
+	Role  Role     `koanf:"role" json:"role" yaml:"role" toml:"role"`
+	Tags  []string `koanf:"tags" json:"tags,omitempty" yaml:"tags,omitempty" toml:"tags,omitempty"` // Only hosts with one of these tags are shown; empty shows every host
+}
+
+// APIToken lets a script or a team use the dashboard and API, sent as
+// "Authorization: Bearer <token>" or as the password a browser asks for
+type APIToken struct {
+	Name  string   `koanf:"name" json:"name" yaml:"name" toml:"name"` // Who uses it, for the log, e.g. "grafana"
+	Token string   `koanf:"token" json:"token" yaml:"token" toml:"token"`
+	Role  Role     `koanf:"role" json:"role" yaml:"role" toml:"role"`
+	Tags  []string `koanf:"tags" json:"tags,omitempty" yaml:"tags,omitempty" toml:"tags,omitempty"` // Only hosts with one of these tags are shown; empty shows every host
+}
+
+// MinTokenLength is the shortest API token accepted, so one can't be guessed
+const MinTokenLength = 16
+
+// Enabled reports whether sign-in is required
+func (a AuthSettings) Enabled() bool {
+	return a.SingleSignOn() || len(a.Tokens) > 0
+}
+
+// SingleSignOn reports whether users sign in with the OpenID Connect
+// provider, rather than only with API tokens
+func (a AuthSettings) SingleSignOn() bool {
+	return a.OIDC.Issuer != ""
+}
+
+// RoleFor returns the most capable role any of groups maps to, or the
+// default role. An empty role means the user may not sign in.
+func (a AuthSettings) RoleFor(groups []string) Role {
+	role := a.DefaultRole
+	for _, m := range a.Roles {
+		if slices.Contains(groups, m.Group) && (m.Role == RoleAdmin || role == "") {
+			role = m.Role
+		}
+	}
+	return role
+}
+
+// TagsFor returns the tags limiting which hosts a user in groups sees, or
+// nil if they see every host. Admins see every host, as they can edit the
+// config anyway; a user in several groups sees the hosts any of them shows.
+func (a AuthSettings) TagsFor(groups []string) []string {
+	if a.RoleFor(groups) == RoleAdmin {
+		return nil
+	}
+	var tags []string
+	matched := false
+	for _, m := range a.Roles {
+		if !slices.Contains(groups, m.Group) {
+			continue
+		}
+		if len(m.Tags) == 0 {
+			return nil
+		}
+		matched = true
+		tags = append(tags, m.Tags...)
+	}
+	if !matched {
+		return a.DefaultTags
+	}
+	slices.Sort(tags)
+	return slices.Compact(tags)
+}
+
+// TokenFor returns the API token matching token, if any
+func (a AuthSettings) TokenFor(token string) (APIToken, bool) {
+	for _, t := range a.Tokens {
+		if len(t.Token) >= MinTokenLength && subtle.ConstantTimeCompare([]byte(t.Token), []byte(token)) == 1 {
+			return t, true
+		}
+	}
+	return APIToken{}, false
+}
+
+// Session returns how long a sign-in lasts, 12 hours unless set
+func (a AuthSettings) Session() time.Duration {
+	if d := optionalDuration(a.SessionTTL); d > 0 {
+		return d
+	}
+	return 12 * time.Hour
+}
+
+// IsPublic reports whether path is left open without signing in
+func (a AuthSettings) IsPublic(path string) bool {
+	return slices.ContainsFunc(a.Public, func(prefix string) bool {
+		return prefix != "" && strings.HasPrefix(path, prefix)
+	})
+}
+
+// OIDCScopes returns the scopes to request, always including openid
+func (a AuthSettings) OIDCScopes() []string {
+	scopes := a.OIDC.Scopes
+	if len(scopes) == 0 {
+		scopes = []string{"openid", "profile", "email", "groups"}
+	}
+	if !slices.Contains(scopes, "openid") {
+		scopes = append([]string{"openid"}, scopes...)
+	}
+	return scopes
+}
+
+// OIDCGroupsClaim returns the ID token claim that lists the user's groups
+func (a AuthSettings) OIDCGroupsClaim() string {
+	if a.OIDC.GroupsClaim == "" {
+		return "groups"
+	}
+	return a.OIDC.GroupsClaim
+}
+
+// RemoteSettings loads more hosts from a URL or git repo and refreshes them
+// periodically, so the fleet can be managed elsewhere. Remote hosts are
+// never written to the local config.
+type RemoteSettings struct {
+	URL     string `koanf:"url" json:"url,omitempty" yaml:"url,omitempty" toml:"url,omitempty"`                 // https://..., or git+https://host/repo.git//path/hosts.yaml?ref=main
+	Refresh string `koanf:"refresh" json:"refresh,omitempty" yaml:"refresh,omitempty" toml:"refresh,omitempty"` // How often to fetch it again, e.g. "5m" (the default)
+	Token   string `koanf:"token" json:"token,omitempty" yaml:"token,omitempty" toml:"token,omitempty"`         // Bearer token sent with http(s) requests
+}
+
+// RefreshInterval returns how often the remote hosts are fetched, 5 minutes
+// unless set, and never more often than every 30 seconds
+func (r RemoteSettings) RefreshInterval() time.Duration {
+	d := optionalDuration(r.Refresh)
+	if d <= 0 {
+		return 5 * time.Minute
+	}
+	return max(d, 30*time.Second)
+}
+
+// ConcurrencySettings caps how many probes run at once. Zero means no cap.
+type ConcurrencySettings struct {
+	MaxProbes int `koanf:"max_probes" json:"max_probes,omitempty" yaml:"max_probes,omitempty" toml:"max_probes,omitempty"` // Across every host
+	PerHost   int `koanf:"per_host" json:"per_host,omitempty" yaml:"per_host,omitempty" toml:"per_host,omitempty"`         // Against any one host, unless it sets max_concurrent_probes
+}
+
+// ProbeSettings controls how http and websocket probes identify themselves,
+// so the target's logs can tell them from real traffic
+type ProbeSettings struct {
+	UserAgent   string `koanf:"user_agent" json:"user_agent,omitempty" yaml:"user_agent,omitempty" toml:"user_agent,omitempty"`         // Defaults to "POKE443 health check"
+	ProbeHeader string `koanf:"probe_header" json:"probe_header,omitempty" yaml:"probe_header,omitempty" toml:"probe_header,omitempty"` // Header sent with a unique ID per probe, e.g. "X-Probe-ID"; empty sends none
+}
+
+// PayloadSettings pins the format of MQTT messages and event stream lines,
+// so consumers keep working when an upgrade changes them
+type PayloadSettings struct {
+	Compat string `koanf:"compat" json:"compat,omitempty" yaml:"compat,omitempty" toml:"compat,omitempty"` // Schema version to send, e.g. "v1", or "legacy" for unversioned payloads; empty means the latest
+}
+
+// HomeAssistantSettings configures publishing each check as a Home Assistant
+// entity through its REST API, for setups without an MQTT broker
+type HomeAssistantSettings struct {
+	Enabled bool   `koanf:"enabled" json:"enabled" yaml:"enabled" toml:"enabled"`
+	URL     string `koanf:"url" json:"url" yaml:"url" toml:"url"`                                           // Base URL, e.g. http://homeassistant.local:8123
+	Token   string `koanf:"token" json:"token" yaml:"token" toml:"token"`                                   // Long-lived access token
+	Prefix  string `koanf:"prefix" json:"prefix,omitempty" yaml:"prefix,omitempty" toml:"prefix,omitempty"` // Entity ID prefix; defaults to "poke443"
+}
+
+// TracingSettings configures OpenTelemetry spans for scheduler ticks, checks
+// and notifications, exported over OTLP/HTTP
+type TracingSettings struct {
+	Enabled     bool              `koanf:"enabled" json:"enabled" yaml:"enabled" toml:"enabled"`
+	Endpoint    string            `koanf:"endpoint" json:"endpoint,omitempty" yaml:"endpoint,omitempty" toml:"endpoint,omitempty"`                 // Collector URL, e.g. http://collector:4318; defaults to $OTEL_EXPORTER_OTLP_ENDPOINT
+	Headers     map[string]string `koanf:"headers" json:"headers,omitempty" yaml:"headers,omitempty" toml:"headers,omitempty"`                     // Sent with each export, e.g. an API key
+	ServiceName string            `koanf:"service_name" json:"service_name,omitempty" yaml:"service_name,omitempty" toml:"service_name,omitempty"` // Defaults to "poke443"
+}
+
+// Metrics export formats
+const (
+	MetricsInflux   = "influx"
+	MetricsGraphite = "graphite"
+)
+
+// MetricsSettings configures pushing every check result to a time-series
+// database after each scheduler run
+type MetricsSettings struct {
+	Enabled bool   `koanf:"enabled" json:"enabled" yaml:"enabled" toml:"enabled"`
+	Format  string `koanf:"format" json:"format" yaml:"format" toml:"format"`                                   // "influx" (line protocol over HTTP) or "graphite" (plaintext over TCP)
+	URL     string `koanf:"url" json:"url,omitempty" yaml:"url,omitempty" toml:"url,omitempty"`                 // InfluxDB write endpoint, e.g. http://influx:8086/api/v2/write?org=home&bucket=health
+	Token   string `koanf:"token" json:"token,omitempty" yaml:"token,omitempty" toml:"token,omitempty"`         // InfluxDB API token, sent as "Authorization: Token <token>"
+	Address string `koanf:"address" json:"address,omitempty" yaml:"address,omitempty" toml:"address,omitempty"` // Graphite host:port, e.g. graphite:2003
+	Prefix  string `koanf:"prefix" json:"prefix,omitempty" yaml:"prefix,omitempty" toml:"prefix,omitempty"`     // Graphite path prefix or InfluxDB measurement; defaults to "poke443"
+}
+
+// EmbedSettings controls the /embed/{host} status widget
+type EmbedSettings struct {
+	Secret string `koanf:"secret" json:"secret,omitempty" yaml:"secret,omitempty" toml:"secret,omitempty"` // When set, embeds need a token signed with it
+}
+
+// DisplaySettings controls how the web UI presents results
+type DisplaySettings struct {
+	Timezone string `koanf:"timezone" json:"timezone,omitempty" yaml:"timezone,omitempty" toml:"timezone,omitempty"` // IANA name, e.g. "Europe/London"; empty uses each browser's own zone
+}
+
+// LoadTimezone resolves an IANA timezone name such as "America/New_York".
+// "Local" is rejected: browsers can't resolve it, and it would silently mean
+// whatever zone the server happens to run in.
+func LoadTimezone(name string) (*time.Location, error) {
+	if name == "Local" {
+		return nil, fmt.Errorf("invalid timezone %q (want an IANA name, e.g. Europe/London)", name)
+	}
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		return nil, fmt.Errorf("invalid timezone %q (want an IANA name, e.g. Europe/London)", name)
+	}
+	return loc, nil
+}
+
+// SelfCheckSettings lists reliable endpoints probed before each run. If none
+// of them answer, the monitor itself is taken to be offline and the run is
+// skipped rather than recording every check as down.
+type SelfCheckSettings struct {
+	References []string `koanf:"references" json:"references,omitempty" yaml:"references,omitempty" toml:"references,omitempty"` // "host:port" TCP endpoints, e.g. "1.1.1.1:443"
 }
 
 type Config struct {
 	Hosts    []Host   `koanf:"hosts" json:"hosts" yaml:"hosts" toml:"hosts"`
 	Settings Settings `koanf:"settings" json:"settings" yaml:"settings" toml:"settings"`
+	Include  []string `koanf:"include" json:"include,omitempty" yaml:"include,omitempty" toml:"include,omitempty"` // Files, globs or directories of more hosts
+
+	// Include files read by Load, in order
+	includes []IncludeFile
 }
 
 func Load(path string) (*Config, error) {
 	k := koanf.New("")
-	ext := filepath.Ext(path)
-	var parser koanf.Parser
-	switch ext {
-	case ".yaml", ".yml":
-		parser = yaml.Parser()
-	case ".toml":
-		parser = toml.Parser()
-	default:
-		return nil, fmt.Errorf("unsupported config extension: %s", ext)
+	parser, err := parserFor(filepath.Ext(path))
+	if err != nil {
+		return nil, err
 	}
 	if err := k.Load(file.Provider(path), parser); err != nil {
 		return nil, err
@@ -97,11 +827,29 @@ func Load(path string) (*Config, error) {
 	if err := k.Unmarshal("", &cfg); err != nil {
 		return nil, err
 	}
-	// ensure at least one ping check if none provided
-	for i := range cfg.Hosts {
-		if len(cfg.Hosts[i].Checks) == 0 {
-			cfg.Hosts[i].Checks = []Check{{Type: CheckPing, Enabled: true}}
-		}
+	if err := cfg.loadIncludes(path); err != nil {
+		return nil, err
 	}
+	defaultChecks(cfg.Hosts)
 	return &cfg, nil
 }
+
+// defaultChecks gives hosts without checks a ping check
+func defaultChecks(hosts []Host) {
+	for i := range hosts {
+		if len(hosts[i].Checks) == 0 {
+			hosts[i].Checks = []Check{{Type: CheckPing, Enabled: true}}
+		}
+	}
+}
+
+func parserFor(ext string) (koanf.Parser, error) {
+	switch ext {
+	case ".yaml", ".yml":
+		return yaml.Parser(), nil
+	case ".toml":
+		return toml.Parser(), nil
+	default:
+		return nil, fmt.Errorf("unsupported config extension: %s", ext)
+	}
+}
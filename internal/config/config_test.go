@@ -0,0 +1,50 @@
+package config
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestTagsFor(t *testing.T) {
+	a := AuthSettings{
+		Roles: []RoleMapping{
+			{Group: "ops", Role: RoleAdmin, Tags: []string{"red"}},
+			{Group: "red-team", Role: RoleViewer, Tags: []string{"red"}},
+			{Group: "blue-team", Role: RoleViewer, Tags: []string{"blue", "shared"}},
+			{Group: "noc", Role: RoleViewer},
+		},
+		DefaultRole: RoleViewer,
+		DefaultTags: []string{"public"},
+	}
+	tests := []struct {
+		groups []string
+		want   []string
+	}{
+		{[]string{"red-team"}, []string{"red"}},
+		{[]string{"red-team", "blue-team"}, []string{"blue", "red", "shared"}},
+		{[]string{"red-team", "noc"}, nil},         // One group sees every host
+		{[]string{"red-team", "ops"}, nil},         // Admins see every host
+		{[]string{"visitors"}, []string{"public"}}, // In no group
+		{nil, []string{"public"}},
+	}
+	for _, tt := range tests {
+		if got := a.TagsFor(tt.groups); !slices.Equal(got, tt.want) {
+			t.Errorf("TagsFor(%q) = %q, want %q", tt.groups, got, tt.want)
+		}
+	}
+}
+
+func TestTokenFor(t *testing.T) {
+	a := AuthSettings{Tokens: []APIToken{
+		{Name: "grafana", Token: "0123456789abcdef", Role: RoleViewer},
+		{Name: "short", Token: "abc", Role: RoleAdmin},
+	}}
+	if tok, ok := a.TokenFor("0123456789abcdef"); !ok || tok.Name != "grafana" {
+		t.Errorf("TokenFor(grafana's token) = %q, %v", tok.Name, ok)
+	}
+	for _, token := range []string{"", "abc", "0123456789abcdeF"} {
+		if _, ok := a.TokenFor(token); ok {
+			t.Errorf("TokenFor(%q) matched", token)
+		}
+	}
+}
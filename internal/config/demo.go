@@ -0,0 +1,59 @@
+package config
+
+// Demo returns a sample home-lab config used by demo mode. Every notifier is
+// disabled so nothing leaves the machine.
+func Demo() *Config {
+	return &Config{
+		Hosts: []Host{
+			{
+				Name:    "Internet Gateway",
+				Address: "1.1.1.1",
+				Tags:    []string{"network"},
+				Checks:  []Check{{Type: CheckPing, Enabled: true, ID: "internet"}},
+			},
+			{
+				Name:    "Core Router",
+				Address: "192.168.1.1",
+				Tags:    []string{"network"},
+				Checks: []Check{
+					{Type: CheckPing, Enabled: true, ID: "router"},
+					{Type: CheckHTTP, Enabled: true, URL: "http://192.168.1.1/", Expect: 200, DependsOn: "router"},
+				},
+			},
+			{
+				Name:    "Wi-Fi Access Point",
+				Address: "192.168.1.5",
+				Tags:    []string{"network"},
+				Checks:  []Check{{Type: CheckPing, Enabled: true, DependsOn: "router"}},
+			},
+			{
+				Name:    "NAS",
+				Address: "192.168.1.20",
+				Tags:    []string{"storage", "lan"},
+				Checks: []Check{
+					{Type: CheckPing, Enabled: true, ID: "nas", DependsOn: "router"},
+					{Type: CheckTCP, Enabled: true, Port: 445, DependsOn: "nas"},
+					{Type: CheckHTTP, Enabled: true, URL: "http://192.168.1.20:5000/", Expect: 200, DependsOn: "nas"},
+				},
+			},
+			{
+				Name:    "Home Assistant",
+				Address: "192.168.1.30",
+				Tags:    []string{"home", "lan"},
+				Checks: []Check{
+					{Type: CheckPing, Enabled: true, DependsOn: "router"},
+					{Type: CheckHTTP, Enabled: true, URL: "http://192.168.1.30:8123/", Expect: 200, DependsOn: "router"},
+				},
+			},
+			{
+				Name:    "Public Website",
+				Address: "example.com",
+				Tags:    []string{"web"},
+				Checks: []Check{
+					{Type: CheckHTTP, Enabled: true, URL: "https://example.com/", Expect: 200, DependsOn: "internet"},
+					{Type: CheckTCP, Enabled: true, Port: 443, DependsOn: "internet"},
+				},
+			},
+		},
+	}
+}
@@ -0,0 +1,276 @@
+package config
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"github.com/knadh/koanf/v2"
+	"gopkg.in/yaml.v3"
+)
+
+// hostSource records where an included host was loaded from
+type hostSource struct {
+	file   string // Empty for hosts in the main config file; the URL for remote hosts
+	path   string // Field path of the host in file; empty when it is the whole file
+	remote bool   // Fetched from settings.remote, so never saved locally
+}
+
+// Remote reports whether the host was fetched from settings.remote
+func (h Host) Remote() bool {
+	return h.src.remote
+}
+
+// hostFile is the list form of an include file
+type hostFile struct {
+	Hosts []Host `koanf:"hosts" json:"hosts" yaml:"hosts" toml:"hosts"`
+}
+
+// IncludeFile is an include file and the hosts it holds
+type IncludeFile struct {
+	Path  string
+	Hosts []Host
+	list  bool // Written as a hosts list rather than a single host
+}
+
+// includeFiles expands the include entries of a config in dir: each is a
+// file, a glob, or a directory standing for the .yaml, .yml and .toml files
+// in it. Files come back in entry order, each entry's sorted by name.
+func includeFiles(dir string, entries []string) ([]string, error) {
+	var files []string
+	for _, entry := range entries {
+		p := entry
+		if !filepath.IsAbs(p) {
+			p = filepath.Join(dir, p)
+		}
+		var matches []string
+		if info, err := os.Stat(p); err == nil && info.IsDir() {
+			dirEntries, err := os.ReadDir(p)
+			if err != nil {
+				return nil, fmt.Errorf("include %s: %w", entry, err)
+			}
+			for _, e := range dirEntries {
+				switch filepath.Ext(e.Name()) {
+				case ".yaml", ".yml", ".toml":
+					if !e.IsDir() && !strings.HasPrefix(e.Name(), ".") {
+						matches = append(matches, filepath.Join(p, e.Name()))
+					}
+				}
+			}
+		} else {
+			var err error
+			if matches, err = filepath.Glob(p); err != nil {
+				return nil, fmt.Errorf("include %s: %w", entry, err)
+			}
+			if len(matches) == 0 && !strings.ContainsAny(entry, "*?[") {
+				return nil, fmt.Errorf("include %s: no such file or directory", entry)
+			}
+		}
+		slices.Sort(matches)
+		for _, m := range matches {
+			if !slices.Contains(files, m) {
+				files = append(files, m)
+			}
+		}
+	}
+	return files, nil
+}
+
+// loadHostFile reads an include file, which holds either one host or a
+// hosts list
+func loadHostFile(path string) ([]Host, bool, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, false, err
+	}
+	hosts, list, err := parseHosts(data, filepath.Ext(path), path)
+	if err != nil {
+		return nil, false, fmt.Errorf("%s: %w", path, err)
+	}
+	return hosts, list, nil
+}
+
+// ParseHosts reads the hosts in a config in the format ext names. Like an
+// include file it may hold one host or a hosts list; anything else, such as
+// a settings section, is ignored.
+func ParseHosts(data []byte, ext string) ([]Host, error) {
+	hosts, _, err := parseHosts(data, ext, "")
+	if err != nil {
+		return nil, err
+	}
+	defaultChecks(hosts)
+	return hosts, nil
+}
+
+// ParseRemoteHosts reads hosts fetched from url, as ParseHosts does
+func ParseRemoteHosts(data []byte, ext, url string) ([]Host, error) {
+	hosts, _, err := parseHosts(data, ext, url)
+	if err != nil {
+		return nil, err
+	}
+	for i := range hosts {
+		hosts[i].src.remote = true
+	}
+	defaultChecks(hosts)
+	return hosts, nil
+}
+
+// WithSourceOf returns h saved wherever old was loaded from, for a host
+// that replaces old
+func (h Host) WithSourceOf(old Host) Host {
+	h.src = old.src
+	return h
+}
+
+// SameHost reports whether two hosts are configured alike, wherever they
+// were loaded from. Unset and empty lists count as the same.
+func SameHost(a, b Host) bool {
+	ab, err1 := yaml.Marshal(a)
+	bb, err2 := yaml.Marshal(b)
+	return err1 == nil && err2 == nil && bytes.Equal(ab, bb)
+}
+
+// parseHosts reads one host or a hosts list, recording source as where
+// each came from
+func parseHosts(data []byte, ext, source string) ([]Host, bool, error) {
+	parser, err := parserFor(ext)
+	if err != nil {
+		return nil, false, err
+	}
+	k := koanf.New("")
+	if err := k.Load(bytesProvider(data), parser); err != nil {
+		return nil, false, err
+	}
+	if k.Exists("hosts") {
+		var f hostFile
+		if err := k.Unmarshal("", &f); err != nil {
+			return nil, false, err
+		}
+		for i := range f.Hosts {
+			f.Hosts[i].src = hostSource{file: source, path: fmt.Sprintf("hosts[%d]", i)}
+		}
+		return f.Hosts, true, nil
+	}
+	var h Host
+	if err := k.Unmarshal("", &h); err != nil {
+		return nil, false, err
+	}
+	h.src = hostSource{file: source}
+	return []Host{h}, false, nil
+}
+
+// bytesProvider is a koanf provider for data already in memory
+type bytesProvider []byte
+
+func (b bytesProvider) ReadBytes() ([]byte, error) {
+	return b, nil
+}
+
+func (b bytesProvider) Read() (map[string]any, error) {
+	return nil, errors.New("bytes provider does not support Read")
+}
+
+// loadIncludes appends the hosts of every include file after those of the
+// main file at path, which is skipped if an entry matches it
+func (c *Config) loadIncludes(path string) error {
+	files, err := includeFiles(filepath.Dir(path), c.Include)
+	if err != nil {
+		return err
+	}
+	for _, f := range files {
+		if filepath.Clean(f) == filepath.Clean(path) {
+			continue
+		}
+		hosts, list, err := loadHostFile(f)
+		if err != nil {
+			return err
+		}
+		c.Hosts = append(c.Hosts, hosts...)
+		c.includes = append(c.includes, IncludeFile{Path: f, list: list})
+	}
+	return nil
+}
+
+// SplitIncludes separates the hosts loaded from include files from the
+// main file's. The returned config is the main file's share; each include
+// file comes back with the hosts it holds now, none if all were deleted.
+// Hosts added since loading belong to the main file, and remote hosts to
+// neither.
+func (c *Config) SplitIncludes() (*Config, []IncludeFile) {
+	if len(c.includes) == 0 && !slices.ContainsFunc(c.Hosts, Host.Remote) {
+		return c, nil
+	}
+	main := *c
+	main.Hosts = nil
+	files := make([]IncludeFile, len(c.includes))
+	index := make(map[string]int, len(c.includes))
+	for i, f := range c.includes {
+		files[i] = IncludeFile{Path: f.Path, list: f.list}
+		index[f.Path] = i
+	}
+	for _, h := range c.Hosts {
+		if h.src.remote {
+			continue
+		}
+		if i, ok := index[h.src.file]; ok {
+			files[i].Hosts = append(files[i].Hosts, h)
+		} else {
+			main.Hosts = append(main.Hosts, h)
+		}
+	}
+	return &main, files
+}
+
+// Marshal encodes the include file's hosts in its format, keeping what it
+// can of orig as MarshalYAMLOver does. A file that held one host keeps that
+// form while it still holds one.
+func (f IncludeFile) Marshal(orig []byte) ([]byte, error) {
+	var v any = &hostFile{Hosts: f.Hosts}
+	if !f.list && len(f.Hosts) == 1 {
+		v = &f.Hosts[0]
+	}
+	switch ext := filepath.Ext(f.Path); ext {
+	case ".yaml", ".yml":
+		return MarshalYAMLOver(orig, v)
+	case ".toml":
+		var buf bytes.Buffer
+		if err := toml.NewEncoder(&buf).Encode(v); err != nil {
+			return nil, err
+		}
+		return buf.Bytes(), nil
+	default:
+		return nil, fmt.Errorf("unsupported config extension: %s", ext)
+	}
+}
+
+// includeProblems reports unknown keys in each include file, with field
+// paths relative to the file
+func (c *Config) includeProblems(probs *Problems, lines map[string]map[string]int) error {
+	for _, f := range c.includes {
+		data, err := os.ReadFile(f.Path)
+		if err != nil {
+			return err
+		}
+		raw, fl, err := parseRaw(filepath.Ext(f.Path), data)
+		if err != nil {
+			return fmt.Errorf("%s: %w", f.Path, err)
+		}
+		lines[f.Path] = fl
+		t := reflect.TypeOf(Host{})
+		if f.list {
+			t = reflect.TypeOf(hostFile{})
+		}
+		n := len(*probs)
+		unknownKeys(probs, t, raw, "")
+		for i := n; i < len(*probs); i++ {
+			(*probs)[i].File = f.Path
+		}
+	}
+	return nil
+}
@@ -0,0 +1,165 @@
+package config
+
+import (
+	"errors"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// hostNames lists the names of hosts in order
+func hostNames(hosts []Host) []string {
+	var names []string
+	for _, h := range hosts {
+		names = append(names, h.Name)
+	}
+	return names
+}
+
+func TestLoadIncludeOrder(t *testing.T) {
+	path := writeConfig(t,
+		"config.yaml", `include:
+  - conf.d
+  - extra/*.yaml
+  - conf.d/10-nas.yaml
+  - "*.yaml"
+hosts:
+  - name: router
+    address: 192.168.1.1
+`,
+		// A directory's files are read in name order
+		"conf.d/20-printer.toml", "name = \"printer\"\naddress = \"192.168.1.20\"\n",
+		"conf.d/10-nas.yaml", "name: nas\naddress: 192.168.1.10\n",
+		"conf.d/30-media.yml", "hosts:\n  - name: plex\n    address: 192.168.1.30\n  - name: jellyfin\n    address: 192.168.1.31\n",
+		// Hidden files and other extensions are skipped
+		"conf.d/.15-draft.yaml", "name: draft\naddress: 192.168.1.15\n",
+		"conf.d/README.md", "# Hosts\n",
+		"extra/b.yaml", "name: b\naddress: 10.0.0.2\n",
+		"extra/a.yaml", "name: a\naddress: 10.0.0.1\n",
+	)
+	cfg, err := Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The main file's hosts come first, then each entry's files, each file
+	// once however many entries match it; "*.yaml" matches the main file,
+	// which isn't read again
+	want := []string{"router", "nas", "printer", "plex", "jellyfin", "a", "b"}
+	if got := hostNames(cfg.Hosts); !slices.Equal(got, want) {
+		t.Errorf("hosts = %q, want %q", got, want)
+	}
+	// Hosts without checks get a ping check wherever they're from
+	for _, h := range cfg.Hosts {
+		if len(h.Checks) != 1 || h.Checks[0].Type != CheckPing {
+			t.Errorf("host %s has checks %+v, want a ping check", h.Name, h.Checks)
+		}
+	}
+
+	// Saving sends each host back to the file it came from
+	main, files := cfg.SplitIncludes()
+	if got := hostNames(main.Hosts); !slices.Equal(got, []string{"router"}) {
+		t.Errorf("main file hosts = %q, want router", got)
+	}
+	var got []string
+	for _, f := range files {
+		rel, _ := filepath.Rel(filepath.Dir(path), f.Path)
+		got = append(got, rel+": "+strings.Join(hostNames(f.Hosts), ","))
+	}
+	wantFiles := []string{
+		"conf.d/10-nas.yaml: nas",
+		"conf.d/20-printer.toml: printer",
+		"conf.d/30-media.yml: plex,jellyfin",
+		"extra/a.yaml: a",
+		"extra/b.yaml: b",
+	}
+	if !slices.Equal(got, wantFiles) {
+		t.Errorf("include files =\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(wantFiles, "\n"))
+	}
+}
+
+func TestLoadIncludeErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		files   []string
+		wantErr string
+	}{
+		{
+			name:    "missing file",
+			files:   []string{"config.yaml", "include: [hosts/nas.yaml]\n"},
+			wantErr: "include hosts/nas.yaml: no such file or directory",
+		},
+		{
+			name:    "unreadable include",
+			files:   []string{"config.yaml", "include: [conf.d]\n", "conf.d/nas.yaml", "name: [nas\n"},
+			wantErr: "nas.yaml",
+		},
+		{
+			name:  "glob matching nothing",
+			files: []string{"config.yaml", "include: [\"conf.d/*.yaml\"]\nhosts:\n  - name: router\n    address: 192.168.1.1\n"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Load(writeConfig(t, tt.files...))
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("Load = %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Load = %v, want an error mentioning %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestLoadStrictDuplicatesAcrossIncludes(t *testing.T) {
+	path := writeConfig(t,
+		"config.yaml", `include: [conf.d]
+hosts:
+  - name: router
+    address: 192.168.1.1
+    checks:
+      - type: http
+        id: web
+        url: http://192.168.1.1
+`,
+		"conf.d/a.yaml", `name: nas
+address: 192.168.1.10
+checks:
+  - type: http
+    id: web
+    url: http://192.168.1.10
+`,
+		"conf.d/b.yaml", `hosts:
+  - name: nas
+    address: 192.168.1.11
+    checks:
+      - type: ping
+        id: nas-ping
+`,
+	)
+	// Load keeps both, leaving the state to rename the check ID
+	cfg, err := Load(path)
+	if err != nil || len(cfg.Hosts) != 3 {
+		t.Fatalf("Load = %v, want 3 hosts", err)
+	}
+
+	_, err = LoadStrict(path)
+	var probs Problems
+	if !errors.As(err, &probs) || len(probs) != 2 {
+		t.Fatalf("LoadStrict = %v, want 2 problems", err)
+	}
+	a, b := filepath.Join(filepath.Dir(path), "conf.d", "a.yaml"), filepath.Join(filepath.Dir(path), "conf.d", "b.yaml")
+	want := []string{
+		a + `: line 5: checks[0].id: duplicate check id "web" (also hosts[0].checks[0])`,
+		b + `: line 2: hosts[0].name: duplicate host name "nas" (also ` + a + `: name)`,
+	}
+	for i, p := range probs {
+		if p.String() != want[i] {
+			t.Errorf("problem %d = %s\nwant %s", i, p, want[i])
+		}
+	}
+}
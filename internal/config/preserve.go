@@ -0,0 +1,240 @@
+package config
+
+import (
+	"bytes"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// MarshalYAMLOver encodes v (usually a *Config) as YAML, keeping as much of orig (the file it
+// replaces) as still applies: comments, key order, quoting, anchors and keys
+// the app doesn't know about. Hosts are matched by name and checks by id, so
+// comments stay with the entry they describe when others are added or
+// removed. Blank lines are not kept. If orig isn't a YAML mapping, v is
+// encoded from scratch.
+func MarshalYAMLOver(orig []byte, v any) ([]byte, error) {
+	var fresh yaml.Node
+	if err := fresh.Encode(v); err != nil {
+		return nil, err
+	}
+	var doc yaml.Node
+	if err := yaml.Unmarshal(orig, &doc); err != nil || len(doc.Content) == 0 || doc.Content[0].Kind != yaml.MappingNode {
+		return yaml.Marshal(v)
+	}
+	doc.Content[0] = mergeYAML(doc.Content[0], &fresh, reflect.Indirect(reflect.ValueOf(v)).Type())
+	var buf bytes.Buffer
+	enc := yaml.NewEncoder(&buf)
+	enc.SetIndent(yamlIndent(orig))
+	if err := enc.Encode(&doc); err != nil {
+		return nil, fmt.Errorf("encode config: %w", err)
+	}
+	if err := enc.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// HasTOMLComments reports whether a TOML file has comment lines. TOML
+// configs are written from scratch when saved, so these are lost; callers
+// warn about it rather than drop them silently.
+func HasTOMLComments(b []byte) bool {
+	for _, line := range strings.Split(string(b), "\n") {
+		if strings.HasPrefix(strings.TrimSpace(line), "#") {
+			return true
+		}
+	}
+	return false
+}
+
+// yamlIndent guesses a file's indent from its first indented line
+func yamlIndent(b []byte) int {
+	for _, line := range strings.Split(string(b), "\n") {
+		trimmed := strings.TrimLeft(line, " ")
+		if n := len(line) - len(trimmed); n > 0 && trimmed != "" && !strings.HasPrefix(trimmed, "#") {
+			return min(max(n, 2), 8)
+		}
+	}
+	return 4
+}
+
+// mergeYAML returns the node to write for a value of type t, given its
+// node in the old file and a freshly encoded one
+func mergeYAML(old, fresh *yaml.Node, t reflect.Type) *yaml.Node {
+	if sameYAML(old, fresh) {
+		return old
+	}
+	switch {
+	case old.Kind == yaml.MappingNode && fresh.Kind == yaml.MappingNode:
+		mergeMapping(old, fresh, t)
+		return old
+	case old.Kind == yaml.SequenceNode && fresh.Kind == yaml.SequenceNode && t.Kind() == reflect.Slice:
+		mergeSequence(old, fresh, t.Elem())
+		return old
+	}
+	fresh.HeadComment, fresh.LineComment, fresh.FootComment = old.HeadComment, old.LineComment, old.FootComment
+	if old.Kind == yaml.ScalarNode && fresh.Kind == yaml.ScalarNode && fresh.Tag == "!!str" {
+		// Quoting is always safe for strings, so keep the file's choice
+		if old.Style&(yaml.DoubleQuotedStyle|yaml.SingleQuotedStyle) != 0 {
+			fresh.Style = old.Style
+		}
+	}
+	return fresh
+}
+
+// mergeMapping updates old's values in place, drops fields the new config
+// has cleared and appends new keys with non-zero values. Keys that aren't
+// fields of t, and fields spelt out at their zero value, are left alone.
+func mergeMapping(old, fresh *yaml.Node, t reflect.Type) {
+	var fields map[string]reflect.Type
+	elem := t
+	if t.Kind() == reflect.Struct {
+		fields = koanfFields(t)
+	} else if t.Kind() == reflect.Map {
+		elem = t.Elem()
+	}
+	valueType := func(key string) (reflect.Type, bool) {
+		if fields == nil {
+			return elem, t.Kind() == reflect.Map
+		}
+		ft, ok := fields[key]
+		return ft, ok
+	}
+
+	// Keys merged in with "<<" count as present
+	var current map[string]any
+	_ = old.Decode(&current)
+
+	freshVals := make(map[string]*yaml.Node, len(fresh.Content)/2)
+	for i := 0; i+1 < len(fresh.Content); i += 2 {
+		freshVals[fresh.Content[i].Value] = fresh.Content[i+1]
+	}
+	seen := make(map[string]bool)
+	content := old.Content[:0:0]
+	for i := 0; i+1 < len(old.Content); i += 2 {
+		key, val := old.Content[i], old.Content[i+1]
+		if key.Tag == "!!merge" {
+			key.Tag = "" // Otherwise written back as "!!merge <<"
+		}
+		vt, known := valueType(key.Value)
+		nv, ok := freshVals[key.Value]
+		switch {
+		case ok:
+			val = mergeYAML(val, nv, vt)
+			seen[key.Value] = true
+		case known && !zeroYAML(val):
+			continue // Cleared since the file was written
+		}
+		content = append(content, key, val)
+	}
+	for i := 0; i+1 < len(fresh.Content); i += 2 {
+		key, val := fresh.Content[i], fresh.Content[i+1]
+		if seen[key.Value] || zeroYAML(val) {
+			continue
+		}
+		if v, ok := current[key.Value]; ok {
+			var fv any
+			if val.Decode(&fv) == nil && reflect.DeepEqual(v, fv) {
+				continue
+			}
+		}
+		content = append(content, key, pruneYAML(val))
+	}
+	old.Content = content
+}
+
+// mergeSequence lines up old and new items by their name or id, falling
+// back to position for items with neither
+func mergeSequence(old, fresh *yaml.Node, elem reflect.Type) {
+	used := make([]bool, len(old.Content))
+	items := make([]*yaml.Node, 0, len(fresh.Content))
+	for j, nv := range fresh.Content {
+		match := -1
+		if id := yamlIdentity(nv); id != "" {
+			for i, ov := range old.Content {
+				if !used[i] && yamlIdentity(ov) == id {
+					match = i
+					break
+				}
+			}
+		} else if j < len(old.Content) && !used[j] && yamlIdentity(old.Content[j]) == "" {
+			match = j
+		}
+		if match < 0 {
+			items = append(items, pruneYAML(nv))
+			continue
+		}
+		used[match] = true
+		items = append(items, mergeYAML(old.Content[match], nv, elem))
+	}
+	old.Content = items
+}
+
+// yamlIdentity returns the name or id of a mapping item, if it has one
+func yamlIdentity(n *yaml.Node) string {
+	if n.Kind != yaml.MappingNode {
+		return ""
+	}
+	for _, key := range []string{"name", "id"} {
+		for i := 0; i+1 < len(n.Content); i += 2 {
+			if n.Content[i].Value == key && n.Content[i+1].Value != "" {
+				return key + "=" + n.Content[i+1].Value
+			}
+		}
+	}
+	return ""
+}
+
+// sameYAML reports whether two nodes decode to the same value
+func sameYAML(a, b *yaml.Node) bool {
+	var av, bv any
+	if a.Decode(&av) != nil || b.Decode(&bv) != nil {
+		return false
+	}
+	return reflect.DeepEqual(av, bv)
+}
+
+// zeroYAML reports whether n holds a zero value, which a missing key
+// already means
+func zeroYAML(n *yaml.Node) bool {
+	switch n.Kind {
+	case yaml.ScalarNode:
+		var v any
+		if n.Decode(&v) != nil {
+			return false
+		}
+		return v == nil || reflect.ValueOf(v).IsZero()
+	case yaml.SequenceNode:
+		return len(n.Content) == 0
+	case yaml.MappingNode:
+		for i := 1; i < len(n.Content); i += 2 {
+			if !zeroYAML(n.Content[i]) {
+				return false
+			}
+		}
+		return true
+	}
+	return false
+}
+
+// pruneYAML drops keys with zero values from a new mapping and the
+// mappings under it, so added entries only spell out what is set
+func pruneYAML(n *yaml.Node) *yaml.Node {
+	switch n.Kind {
+	case yaml.MappingNode:
+		content := n.Content[:0]
+		for i := 0; i+1 < len(n.Content); i += 2 {
+			if !zeroYAML(n.Content[i+1]) {
+				content = append(content, n.Content[i], pruneYAML(n.Content[i+1]))
+			}
+		}
+		n.Content = content
+	case yaml.SequenceNode:
+		for _, c := range n.Content {
+			pruneYAML(c)
+		}
+	}
+	return n
+}
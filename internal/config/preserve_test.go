@@ -0,0 +1,210 @@
+package config
+
+import (
+	"testing"
+
+	"gopkg.in/yaml.v3"
+)
+
+const preserveOrig = `# Home lab
+hosts:
+  # Edge first
+  - name: router
+    address: 192.168.1.1 # LAN side
+    checks:
+      - type: ping
+        enabled: true
+        id: gw
+  - address: "10.0.0.5"
+    name: nas
+    tags: [storage, "office"]
+    checks:
+      - type: tcp
+        enabled: true
+        port: 445 # SMB
+unknown_key: kept
+`
+
+func TestMarshalYAMLOver(t *testing.T) {
+	tests := []struct {
+		name string
+		edit func(*Config)
+		want string
+	}{
+		{
+			name: "unchanged",
+			edit: func(*Config) {},
+			want: preserveOrig,
+		},
+		{
+			name: "host added",
+			edit: func(c *Config) {
+				c.Hosts = append(c.Hosts, Host{Name: "printer", Address: "10.0.0.9", Checks: []Check{{Type: "ping", Enabled: true}}})
+			},
+			want: `# Home lab
+hosts:
+  # Edge first
+  - name: router
+    address: 192.168.1.1 # LAN side
+    checks:
+      - type: ping
+        enabled: true
+        id: gw
+  - address: "10.0.0.5"
+    name: nas
+    tags: [storage, "office"]
+    checks:
+      - type: tcp
+        enabled: true
+        port: 445 # SMB
+  - name: printer
+    address: 10.0.0.9
+    checks:
+      - type: ping
+        enabled: true
+unknown_key: kept
+`,
+		},
+		{
+			name: "host removed takes its comment",
+			edit: func(c *Config) { c.Hosts = c.Hosts[1:] },
+			want: `# Home lab
+hosts:
+  - address: "10.0.0.5"
+    name: nas
+    tags: [storage, "office"]
+    checks:
+      - type: tcp
+        enabled: true
+        port: 445 # SMB
+unknown_key: kept
+`,
+		},
+		{
+			name: "hosts reordered keep their comments",
+			edit: func(c *Config) { c.Hosts[0], c.Hosts[1] = c.Hosts[1], c.Hosts[0] },
+			want: `# Home lab
+hosts:
+  - address: "10.0.0.5"
+    name: nas
+    tags: [storage, "office"]
+    checks:
+      - type: tcp
+        enabled: true
+        port: 445 # SMB
+  # Edge first
+  - name: router
+    address: 192.168.1.1 # LAN side
+    checks:
+      - type: ping
+        enabled: true
+        id: gw
+unknown_key: kept
+`,
+		},
+		{
+			name: "values and lists edited",
+			edit: func(c *Config) {
+				c.Hosts[0].Address = "192.168.1.254"
+				c.Hosts[0].Checks = append(c.Hosts[0].Checks, Check{Type: "http", Enabled: true, URL: "http://192.168.1.1", ID: "ui"})
+				c.Hosts[1].Tags = []string{"storage", "backup"}
+				c.Hosts[1].Checks[0].Port = 139
+			},
+			want: `# Home lab
+hosts:
+  # Edge first
+  - name: router
+    address: 192.168.1.254 # LAN side
+    checks:
+      - type: ping
+        enabled: true
+        id: gw
+      - type: http
+        enabled: true
+        url: http://192.168.1.1
+        id: ui
+  - address: "10.0.0.5"
+    name: nas
+    tags: [storage, "backup"]
+    checks:
+      - type: tcp
+        enabled: true
+        port: 139 # SMB
+unknown_key: kept
+`,
+		},
+		{
+			name: "list items removed",
+			edit: func(c *Config) {
+				c.Hosts[1].Tags = []string{"office"}
+				c.Hosts[1].Checks = nil
+			},
+			want: `# Home lab
+hosts:
+  # Edge first
+  - name: router
+    address: 192.168.1.1 # LAN side
+    checks:
+      - type: ping
+        enabled: true
+        id: gw
+  - address: "10.0.0.5"
+    name: nas
+    tags: [office]
+    checks: []
+unknown_key: kept
+`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var cfg Config
+			if err := yaml.Unmarshal([]byte(preserveOrig), &cfg); err != nil {
+				t.Fatal(err)
+			}
+			tt.edit(&cfg)
+			b, err := MarshalYAMLOver([]byte(preserveOrig), &cfg)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(b) != tt.want {
+				t.Errorf("got:\n%s\nwant:\n%s", b, tt.want)
+			}
+			var back Config
+			if err := yaml.Unmarshal(b, &back); err != nil {
+				t.Fatalf("output doesn't parse: %v", err)
+			}
+			if len(back.Hosts) != len(cfg.Hosts) {
+				t.Errorf("output has %d hosts, want %d", len(back.Hosts), len(cfg.Hosts))
+			}
+		})
+	}
+}
+
+func TestMarshalYAMLOverKeepsIndent(t *testing.T) {
+	orig := "hosts:\n    - name: router # Edge\n      address: 192.168.1.1\n"
+	b, err := MarshalYAMLOver([]byte(orig), &Config{Hosts: []Host{{Name: "router", Address: "192.168.1.2"}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "hosts:\n    - name: router # Edge\n      address: 192.168.1.2\n"; string(b) != want {
+		t.Errorf("got:\n%s\nwant:\n%s", b, want)
+	}
+}
+
+func TestHasTOMLComments(t *testing.T) {
+	tests := []struct {
+		in   string
+		want bool
+	}{
+		{"[[hosts]]\nname = \"router\"\n", false},
+		{"# Home lab\n[[hosts]]\nname = \"router\"\n", true},
+		{"[[hosts]]\n  # Edge first\nname = \"router\"\n", true},
+		{"", false},
+	}
+	for _, tt := range tests {
+		if got := HasTOMLComments([]byte(tt.in)); got != tt.want {
+			t.Errorf("HasTOMLComments(%q) = %v, want %v", tt.in, got, tt.want)
+		}
+	}
+}
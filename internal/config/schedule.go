@@ -0,0 +1,135 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Schedule is when a check is monitored, parsed from comma-separated windows
+// such as "mon-fri 07:00-23:00, sat-sun 09:00-22:00". Each window has days,
+// times or both: "sat-sun" is all day at weekends and "07:00-23:00" is every
+// day. Times are in the server's local time and may wrap midnight, in which
+// case the window belongs to the day it starts on. An empty Schedule means
+// always.
+type Schedule []ScheduleWindow
+
+// ScheduleWindow is one window of a Schedule
+type ScheduleWindow struct {
+	Days       [7]bool // Indexed by time.Weekday
+	Start, End int     // Minutes after midnight; equal for all day
+}
+
+var weekdays = map[string]time.Weekday{
+	"sun": time.Sunday, "mon": time.Monday, "tue": time.Tuesday, "wed": time.Wednesday,
+	"thu": time.Thursday, "fri": time.Friday, "sat": time.Saturday,
+}
+
+// ParseSchedule parses a schedule; empty text gives an empty Schedule
+func ParseSchedule(s string) (Schedule, error) {
+	var sched Schedule
+	for _, part := range strings.Split(s, ",") {
+		part = strings.ToLower(strings.TrimSpace(part))
+		if part == "" {
+			continue
+		}
+		w, err := parseScheduleWindow(part)
+		if err != nil {
+			return nil, err
+		}
+		sched = append(sched, w)
+	}
+	if sched == nil && strings.TrimSpace(s) != "" {
+		return nil, fmt.Errorf("invalid schedule %q", s)
+	}
+	return sched, nil
+}
+
+func parseScheduleWindow(part string) (ScheduleWindow, error) {
+	var w ScheduleWindow
+	fields := strings.Fields(part)
+	if len(fields) > 2 {
+		return w, fmt.Errorf("invalid schedule window %q (want e.g. mon-fri 07:00-23:00)", part)
+	}
+	days, times := "", ""
+	for _, f := range fields {
+		if strings.Contains(f, ":") {
+			times = f
+		} else {
+			days = f
+		}
+	}
+	if len(fields) == 2 && (days == "" || times == "") {
+		return w, fmt.Errorf("invalid schedule window %q (want e.g. mon-fri 07:00-23:00)", part)
+	}
+
+	if days == "" {
+		w.Days = [7]bool{true, true, true, true, true, true, true}
+	} else {
+		first, last, ok := strings.Cut(days, "-")
+		if !ok {
+			last = first
+		}
+		from, ok1 := weekdays[first]
+		to, ok2 := weekdays[last]
+		if !ok1 || !ok2 {
+			return w, fmt.Errorf("invalid days %q in schedule (want e.g. mon or mon-fri)", days)
+		}
+		// Ranges may wrap the weekend, e.g. fri-mon
+		for d := from; ; d = (d + 1) % 7 {
+			w.Days[d] = true
+			if d == to {
+				break
+			}
+		}
+	}
+
+	if times != "" {
+		start, end, ok := strings.Cut(times, "-")
+		if !ok {
+			return w, fmt.Errorf("invalid times %q in schedule (want HH:MM-HH:MM)", times)
+		}
+		var err error
+		if w.Start, err = ParseClock(start); err != nil {
+			return w, err
+		}
+		if w.End, err = ParseClock(end); err != nil {
+			return w, err
+		}
+		if w.Start == w.End {
+			return w, fmt.Errorf("schedule window %q is empty; leave out the times for all day", part)
+		}
+	}
+	return w, nil
+}
+
+// Active reports whether t, in the server's local time, falls inside the
+// schedule. Windows are wall-clock times, so on a daylight saving change a
+// window keeps its hours and the day it falls on is an hour shorter or longer.
+func (s Schedule) Active(t time.Time) bool {
+	if len(s) == 0 {
+		return true
+	}
+	t = t.Local()
+	for _, w := range s {
+		if w.contains(t) {
+			return true
+		}
+	}
+	return false
+}
+
+func (w ScheduleWindow) contains(t time.Time) bool {
+	day := t.Weekday()
+	now := t.Hour()*60 + t.Minute()
+	switch {
+	case w.Start == w.End:
+		return w.Days[day]
+	case w.Start < w.End:
+		return w.Days[day] && now >= w.Start && now < w.End
+	default:
+		// Wraps midnight: the early hours belong to the previous day's window
+		yesterday := (day + 6) % 7
+		return (w.Days[day] && now >= w.Start) || (w.Days[yesterday] && now < w.End)
+	}
+}
@@ -0,0 +1,56 @@
+package config
+
+import (
+	"testing"
+	"time"
+)
+
+func TestScheduleActiveAcrossDaylightSaving(t *testing.T) {
+	loc, err := time.LoadLocation("Europe/London")
+	if err != nil {
+		t.Skipf("no timezone data: %v", err)
+	}
+	saved := time.Local
+	time.Local = loc
+	defer func() { time.Local = saved }()
+
+	office, err := ParseSchedule("mon-fri 09:00-17:00")
+	if err != nil {
+		t.Fatal(err)
+	}
+	overnight, err := ParseSchedule("22:00-06:00")
+	if err != nil {
+		t.Fatal(err)
+	}
+	utc := func(month time.Month, day, hour, min int) time.Time {
+		return time.Date(2026, month, day, hour, min, 0, 0, time.UTC)
+	}
+	tests := []struct {
+		name  string
+		sched Schedule
+		at    time.Time
+		want  bool
+	}{
+		// Before the spring change London is on GMT, the same as UTC
+		{"office, Friday before spring, 08:30 GMT", office, utc(3, 27, 8, 30), false},
+		{"office, Friday before spring, 09:00 GMT", office, utc(3, 27, 9, 0), true},
+		// After it, the window keeps its local hours, an hour earlier in UTC
+		{"office, Monday after spring, 09:30 BST", office, utc(3, 30, 8, 30), true},
+		{"office, Monday after spring, 17:30 BST", office, utc(3, 30, 16, 30), false},
+		{"office, Monday after autumn, 08:30 GMT", office, utc(10, 26, 8, 30), false},
+		{"office, Monday after autumn, 09:00 GMT", office, utc(10, 26, 9, 0), true},
+		// The spring night is an hour short
+		{"overnight, spring, 05:59 BST", overnight, utc(3, 29, 4, 59), true},
+		{"overnight, spring, 06:00 BST", overnight, utc(3, 29, 5, 0), false},
+		// The autumn night is an hour long, and 01:30 happens twice
+		{"overnight, autumn, first 01:30 BST", overnight, utc(10, 25, 0, 30), true},
+		{"overnight, autumn, second 01:30 GMT", overnight, utc(10, 25, 1, 30), true},
+		{"overnight, autumn, 05:59 GMT", overnight, utc(10, 25, 5, 59), true},
+		{"overnight, autumn, 06:00 GMT", overnight, utc(10, 25, 6, 0), false},
+	}
+	for _, tt := range tests {
+		if got := tt.sched.Active(tt.at); got != tt.want {
+			t.Errorf("%s: Active(%v) = %v, want %v", tt.name, tt.at.In(loc), got, tt.want)
+		}
+	}
+}
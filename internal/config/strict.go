@@ -0,0 +1,690 @@
+package config
+
+import (
+	"fmt"
+	"maps"
+	"net"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+
+	"github.com/andrewsjg/simple-healthchecker/copilot/internal/checks"
+	"github.com/andrewsjg/simple-healthchecker/copilot/internal/validate"
+)
+
+// Problem is one mistake in a config file
+type Problem struct {
+	File    string // Include file the problem is in; empty for the main file
+	Line    int    // 1-based; 0 when the format doesn't record it (TOML)
+	Field   string // Dotted path, e.g. "hosts[2].checks[0].port"
+	Message string
+}
+
+func (p Problem) String() string {
+	s := p.Field + ": " + p.Message
+	if p.Line > 0 {
+		s = fmt.Sprintf("line %d: %s", p.Line, s)
+	}
+	if p.File != "" {
+		s = p.File + ": " + s
+	}
+	return s
+}
+
+// Problems lists every mistake found in a config file and its include
+// files, in file order
+type Problems []Problem
+
+func (p *Problems) add(field, format string, args ...any) {
+	*p = append(*p, Problem{Field: field, Message: fmt.Sprintf(format, args...)})
+}
+
+// Error implements error with one problem per line
+func (p Problems) Error() string {
+	lines := make([]string, 0, len(p))
+	for _, pr := range p {
+		lines = append(lines, pr.String())
+	}
+	return strings.Join(lines, "\n")
+}
+
+// LoadStrict loads a config like Load, but rejects unknown keys (usually
+// typos), out-of-range values and duplicate host names instead of ignoring
+// them. A rejected config returns Problems listing every mistake.
+func LoadStrict(path string) (*Config, error) {
+	cfg, err := Load(path)
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	raw, lines, err := parseRaw(filepath.Ext(path), data)
+	if err != nil {
+		return nil, err
+	}
+	var probs Problems
+	unknownKeys(&probs, reflect.TypeOf(Config{}), raw, "")
+	fileLines := map[string]map[string]int{"": lines}
+	if err := cfg.includeProblems(&probs, fileLines); err != nil {
+		return nil, err
+	}
+	cfg.check(&probs)
+	if len(probs) == 0 {
+		return cfg, nil
+	}
+	order := map[string]int{"": 0}
+	for i, f := range cfg.includes {
+		order[f.Path] = i + 1
+	}
+	for i := range probs {
+		probs[i].Line = fileLines[probs[i].File][probs[i].Field]
+	}
+	sort.SliceStable(probs, func(i, j int) bool {
+		if probs[i].File != probs[j].File {
+			return order[probs[i].File] < order[probs[j].File]
+		}
+		return probs[i].Line < probs[j].Line
+	})
+	return nil, probs
+}
+
+// parseRaw decodes a config file into plain maps and slices, along with
+// the line of each key where the format allows
+func parseRaw(ext string, data []byte) (any, map[string]int, error) {
+	lines := make(map[string]int)
+	var raw any
+	switch ext {
+	case ".yaml", ".yml":
+		var doc yaml.Node
+		if err := yaml.Unmarshal(data, &doc); err != nil {
+			return nil, nil, err
+		}
+		yamlLines(&doc, "", lines)
+		if err := doc.Decode(&raw); err != nil {
+			return nil, nil, err
+		}
+	case ".toml":
+		var m map[string]any
+		if err := toml.Unmarshal(data, &m); err != nil {
+			return nil, nil, err
+		}
+		raw = m
+	default:
+		return nil, nil, fmt.Errorf("unsupported config extension: %s", ext)
+	}
+	return raw, lines, nil
+}
+
+// yamlLines records the line of every key and list item under n
+func yamlLines(n *yaml.Node, path string, lines map[string]int) {
+	switch n.Kind {
+	case yaml.DocumentNode:
+		for _, c := range n.Content {
+			yamlLines(c, path, lines)
+		}
+	case yaml.MappingNode:
+		for i := 0; i+1 < len(n.Content); i += 2 {
+			p := joinPath(path, n.Content[i].Value)
+			lines[p] = n.Content[i].Line
+			yamlLines(n.Content[i+1], p, lines)
+		}
+	case yaml.SequenceNode:
+		for i, c := range n.Content {
+			p := fmt.Sprintf("%s[%d]", path, i)
+			lines[p] = c.Line
+			yamlLines(c, p, lines)
+		}
+	}
+}
+
+func joinPath(path, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
+
+// unknownKeys reports keys in v that t has no koanf field for. Values of
+// the wrong type are left for Load to report.
+func unknownKeys(probs *Problems, t reflect.Type, v any, path string) {
+	rv := reflect.ValueOf(v)
+	switch t.Kind() {
+	case reflect.Struct:
+		if rv.Kind() != reflect.Map {
+			return
+		}
+		fields := koanfFields(t)
+		for _, key := range sortedKeys(rv) {
+			p := joinPath(path, key)
+			ft, ok := fields[key]
+			if !ok {
+				if near := nearestKey(key, fields); near != "" {
+					probs.add(p, "unknown key (did you mean %q?)", near)
+				} else {
+					probs.add(p, "unknown key")
+				}
+				continue
+			}
+			unknownKeys(probs, ft, rv.MapIndex(reflect.ValueOf(key)).Interface(), p)
+		}
+	case reflect.Slice:
+		if rv.Kind() != reflect.Slice {
+			return
+		}
+		for i := range rv.Len() {
+			unknownKeys(probs, t.Elem(), rv.Index(i).Interface(), fmt.Sprintf("%s[%d]", path, i))
+		}
+	case reflect.Map:
+		if rv.Kind() != reflect.Map {
+			return
+		}
+		for _, key := range sortedKeys(rv) {
+			unknownKeys(probs, t.Elem(), rv.MapIndex(reflect.ValueOf(key)).Interface(), joinPath(path, key))
+		}
+	}
+}
+
+// sortedKeys returns the keys of a decoded map, which are always strings
+func sortedKeys(m reflect.Value) []string {
+	keys := make([]string, 0, m.Len())
+	for _, k := range m.MapKeys() {
+		keys = append(keys, k.String())
+	}
+	slices.Sort(keys)
+	return keys
+}
+
+// koanfFields maps the koanf keys of a struct to their field types
+func koanfFields(t reflect.Type) map[string]reflect.Type {
+	fields := make(map[string]reflect.Type, t.NumField())
+	for i := range t.NumField() {
+		f := t.Field(i)
+		if name, _, _ := strings.Cut(f.Tag.Get("koanf"), ","); name != "" && name != "-" {
+			fields[name] = f.Type
+		}
+	}
+	return fields
+}
+
+// nearestKey returns the known key closest to a misspelt one, if any is
+// within two edits
+func nearestKey(key string, fields map[string]reflect.Type) string {
+	best, bestDist := "", 3
+	for name := range fields {
+		if d := editDistance(key, name); d < bestDist || (d == bestDist && name < best) {
+			best, bestDist = name, d
+		}
+	}
+	return best
+}
+
+// editDistance is the Levenshtein distance between a and b
+func editDistance(a, b string) int {
+	prev := make([]int, len(b)+1)
+	cur := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		cur[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev, cur = cur, prev
+	}
+	return prev[len(b)]
+}
+
+// field is a config value to check, named by its path
+type field struct {
+	name, value string
+}
+
+// idList is a list of check IDs to check, named by its key
+type idList struct {
+	name string
+	ids  []string
+}
+
+// memberLists returns a composite check's member lists
+func (ch Check) memberLists() []idList {
+	return []idList{{"all_of", ch.AllOf}, {"any_of", ch.AnyOf}}
+}
+
+// check reports values Load accepts but the app would misread or ignore
+func (c *Config) check(probs *Problems) {
+	names := make(map[string]string)
+	ids := make(map[string]string)
+	allIDs := make(map[string]bool)
+	for _, h := range c.Hosts {
+		for _, ch := range h.Checks {
+			allIDs[ch.ID] = true
+		}
+	}
+	for i, h := range c.Hosts {
+		// Included hosts are named by their path in their own file
+		hp := fmt.Sprintf("hosts[%d]", i)
+		where := func(path string) string { return path }
+		if h.src.file != "" {
+			hp = h.src.path
+			where = func(path string) string { return h.src.file + ": " + path }
+		}
+		n := len(*probs)
+		if err := validate.Name(h.Name); err != nil {
+			probs.add(joinPath(hp, "name"), "%v", err)
+		} else if first, dup := names[h.Name]; dup {
+			probs.add(joinPath(hp, "name"), "duplicate host name %q (also %s)", h.Name, first)
+		} else {
+			names[h.Name] = where(joinPath(hp, "name"))
+		}
+		if err := validate.Address(h.Address); err != nil {
+			probs.add(joinPath(hp, "address"), "%v", err)
+		}
+		if _, err := validate.HealthchecksURL(h.HealthchecksPingURL); err != nil {
+			probs.add(joinPath(hp, "healthchecks_ping_url"), "%v", err)
+		}
+		if h.MaxConcurrentProbes < 0 {
+			probs.add(joinPath(hp, "max_concurrent_probes"), "must be 0 or more")
+		}
+		for j, ch := range h.Checks {
+			cp := joinPath(hp, fmt.Sprintf("checks[%d]", j))
+			ch.check(probs, cp)
+			if ch.Type == CheckDomain && ch.Domain == "" && net.ParseIP(h.Address) != nil {
+				probs.add(cp+".domain", "is required, as the host's address is an IP")
+			}
+			for _, f := range ch.memberLists() {
+				for _, id := range f.ids {
+					if id != "" && !allIDs[id] {
+						probs.add(cp+"."+f.name, "no check has id %q", id)
+					}
+				}
+			}
+			if ch.ID != "" {
+				if first, dup := ids[ch.ID]; dup {
+					probs.add(cp+".id", "duplicate check id %q (also %s)", ch.ID, first)
+				} else {
+					ids[ch.ID] = where(cp)
+				}
+			}
+		}
+		for k := n; k < len(*probs); k++ {
+			(*probs)[k].File = h.src.file
+		}
+	}
+	c.Settings.check(probs)
+}
+
+func (ch Check) check(probs *Problems, path string) {
+	switch ch.Type {
+	case CheckPing, CheckTCP, CheckSSH:
+	case CheckHTTP:
+		if err := validate.URL(ch.URL); err != nil {
+			probs.add(path+".url", "%v", err)
+		}
+	case CheckWS:
+		if err := validate.WebSocketURL(ch.URL); err != nil {
+			probs.add(path+".url", "%v", err)
+		}
+	case CheckPorts:
+		open, err1 := validate.Ports(ch.Ports)
+		if err1 != nil {
+			probs.add(path+".ports", "%v", err1)
+		}
+		closed, err2 := validate.Ports(ch.ClosedPorts)
+		if err2 != nil {
+			probs.add(path+".closed_ports", "%v", err2)
+		}
+		if err1 == nil && err2 == nil && len(open)+len(closed) == 0 {
+			probs.add(path+".ports", "a ports check needs ports or closed_ports")
+		}
+		for _, p := range open {
+			if slices.Contains(closed, p) {
+				probs.add(path+".closed_ports", "port %d is also in ports", p)
+			}
+		}
+	case CheckComposite:
+		if len(ch.AllOf)+len(ch.AnyOf) == 0 {
+			probs.add(path+".all_of", "a composite check needs all_of or any_of")
+		}
+		for _, f := range ch.memberLists() {
+			for _, id := range f.ids {
+				if err := validate.CheckID(id); err != nil || id == "" {
+					probs.add(path+"."+f.name, "%q is not a check id", id)
+				} else if id == ch.ID {
+					probs.add(path+"."+f.name, "a composite check cannot include itself")
+				}
+			}
+		}
+	case CheckWebhook:
+		if ch.ID == "" {
+			probs.add(path+".id", "a webhook check needs an id, which names it in its URL")
+		}
+	case CheckFile:
+		if strings.TrimSpace(ch.Path) == "" {
+			probs.add(path+".path", "a file check needs the path of a file or directory")
+		}
+		if ch.MaxAge == "" {
+			probs.add(path+".max_age", "a file check needs a max_age, e.g. 25h")
+		}
+	case CheckS3:
+		if err := validate.URL(ch.URL); err != nil {
+			probs.add(path+".url", "%v", err)
+		}
+		if (ch.AccessKey == "") != (ch.SecretKey == "") {
+			probs.add(path+".secret_key", "access_key and secret_key go together")
+		}
+		if ch.MaxAge != "" && ch.Object == "" {
+			probs.add(path+".max_age", "needs an object to check the age of")
+		}
+	case CheckIPP:
+		if err := validate.PrinterURL(ch.URL); err != nil {
+			probs.add(path+".url", "%v", err)
+		}
+	case CheckSMB:
+		if err := validate.ShareName(ch.Share); err != nil {
+			probs.add(path+".share", "%v", err)
+		}
+		if ch.Password != "" && ch.Username == "" {
+			probs.add(path+".username", "is required with a password")
+		}
+	case CheckKafka:
+		if err := validate.KafkaTopic(ch.Topic); err != nil {
+			probs.add(path+".topic", "%v", err)
+		}
+	case CheckAMQP:
+		if len(ch.VHost) > 255 {
+			probs.add(path+".vhost", "is too long")
+		}
+		if ch.Password != "" && ch.Username == "" {
+			probs.add(path+".username", "is required with a password")
+		}
+	case CheckKubernetes:
+		if ch.URL == "" && ch.Kubeconfig == "" {
+			probs.add(path+".url", "a kubernetes check needs the API server's url or a kubeconfig")
+		} else if err := validate.OptionalURL(ch.URL); err != nil {
+			probs.add(path+".url", "%v", err)
+		}
+		if ch.KubeContext != "" && ch.Kubeconfig == "" {
+			probs.add(path+".kube_context", "needs a kubeconfig")
+		}
+		if err := validate.Deployment(ch.Deployment); err != nil {
+			probs.add(path+".deployment", "%v", err)
+		}
+	case CheckProxmox:
+		if err := validate.ProxmoxToken(ch.Token); err != nil {
+			probs.add(path+".token", "%v", err)
+		}
+	case CheckESXi:
+		if ch.Username == "" {
+			probs.add(path+".username", "an esxi check needs a username, e.g. a read-only user")
+		}
+	case CheckUPS:
+		if err := validate.UPSDaemon(ch.Daemon); err != nil {
+			probs.add(path+".daemon", "%v", err)
+		}
+		if err := validate.UPSName(ch.UPS); err != nil {
+			probs.add(path+".ups", "%v", err)
+		} else if ch.UPS != "" && ch.Daemon == checks.UPSApcupsd {
+			probs.add(path+".ups", "only applies to nut; apcupsd has one UPS")
+		}
+	case CheckSpeedtest:
+		if err := validate.OptionalURL(ch.URL); err != nil {
+			probs.add(path+".url", "%v", err)
+		} else if ch.URL != "" && ch.Upload {
+			probs.add(path+".upload", "only applies to iperf3; leave out the url to use it")
+		}
+		if _, err := validate.Speed(ch.MinSpeed); err != nil {
+			probs.add(path+".min_speed", "%v", err)
+		}
+		if _, err := validate.SpeedtestInterval(ch.Interval); err != nil {
+			probs.add(path+".interval", "%v", err)
+		}
+	case CheckPublicIP:
+		if err := validate.OptionalURL(ch.URL); err != nil {
+			probs.add(path+".url", "%v", err)
+		}
+		if ch.PublicIP != "" && net.ParseIP(ch.PublicIP) == nil {
+			probs.add(path+".public_ip", "must be an IP address; remove it to take the next one looked up")
+		}
+	case CheckDomain:
+		if err := validate.Domain(ch.Domain); err != nil {
+			probs.add(path+".domain", "%v", err)
+		}
+		if ch.WarnDays < 0 || ch.WarnDays > 365 {
+			probs.add(path+".warn_days", "must be between 1 and 365")
+		}
+	case CheckDNSBL:
+		for k, list := range ch.Blocklists {
+			if _, err := validate.Blocklists(list); err != nil || strings.TrimSpace(list) == "" {
+				probs.add(fmt.Sprintf("%s.blocklists[%d]", path, k), "%q is not a blocklist zone such as zen.spamhaus.org", list)
+			}
+		}
+	default:
+		probs.add(path+".type", "unknown check type %q (want ping, http, tcp, ssh, websocket, ports, composite, webhook, file, s3, ipp, smb, kafka, amqp, kubernetes, proxmox, esxi, ups, speedtest, publicip, domain or dnsbl)", ch.Type)
+	}
+	if ch.Expect != 0 && (ch.Expect < 100 || ch.Expect > 599) {
+		probs.add(path+".expect", "must be an HTTP status code between 100 and 599")
+	}
+	for _, f := range []struct {
+		name string
+		port int
+	}{{"port", ch.Port}, {"ping_port", ch.PingPort}} {
+		if f.port < 0 || f.port > 65535 {
+			probs.add(path+"."+f.name, "must be between 1 and 65535")
+		}
+	}
+	if ch.MaxUsage < 0 || ch.MaxUsage > 100 {
+		probs.add(path+".max_usage", "must be a percentage between 1 and 100")
+	}
+	if ch.MinCharge < 0 || ch.MinCharge > 100 {
+		probs.add(path+".min_charge", "must be a percentage between 1 and 100")
+	}
+	if ch.PingCount < 0 || ch.PingCount > checks.MaxPingCount {
+		probs.add(path+".ping_count", "must be between 1 and %d", checks.MaxPingCount)
+	}
+	if ch.MaxJitter != "" {
+		if d, err := time.ParseDuration(ch.MaxJitter); err != nil || d <= 0 {
+			probs.add(path+".max_jitter", "%q is not a duration, e.g. 30ms", ch.MaxJitter)
+		} else if ch.PingCount < 2 {
+			probs.add(path+".max_jitter", "needs a ping_count of 2 or more")
+		}
+	}
+	if ch.MaxAge != "" {
+		if d, err := time.ParseDuration(ch.MaxAge); err != nil || d <= 0 {
+			probs.add(path+".max_age", "%q is not a duration, e.g. 25h", ch.MaxAge)
+		}
+	}
+	if ch.MaxRedirects < 0 {
+		probs.add(path+".max_redirects", "must be 0 or more")
+	}
+	if _, err := validate.HealthchecksURL(ch.HealthchecksPingURL); err != nil {
+		probs.add(path+".healthchecks_ping_url", "%v", err)
+	}
+	if ch.IPVersion != 0 && ch.IPVersion != 4 && ch.IPVersion != 6 {
+		probs.add(path+".ip_version", "must be 4 or 6")
+	}
+	switch ch.Severity {
+	case "", SeverityInfo, SeverityWarning, SeverityCritical:
+	default:
+		probs.add(path+".severity", "must be info, warning or critical")
+	}
+	if err := validate.CheckID(ch.ID); err != nil {
+		probs.add(path+".id", "%v", err)
+	}
+	if err := validate.DisplayName(ch.Name); err != nil {
+		probs.add(path+".name", "%v", err)
+	}
+	if err := validate.ProxyURL(ch.Proxy); err != nil {
+		probs.add(path+".proxy", "%v", err)
+	}
+	if ch.MinSize < 0 {
+		probs.add(path+".min_size", "must be 0 or more")
+	}
+	if ch.MaxSize < 0 {
+		probs.add(path+".max_size", "must be 0 or more")
+	}
+	if ch.MinSize > 0 && ch.MaxSize > 0 && ch.MinSize > ch.MaxSize {
+		probs.add(path+".max_size", "must be at least min_size (%d)", ch.MinSize)
+	}
+	if err := validate.UserAgent(ch.UserAgent); err != nil {
+		probs.add(path+".user_agent", "%v", err)
+	}
+	for _, f := range []field{{"expect_output", ch.ExpectOutput}, {"ws_expect", ch.WSExpect}} {
+		if err := validate.Regexp(f.value); err != nil {
+			probs.add(path+"."+f.name, "%v", err)
+		}
+	}
+	if _, err := ParseSchedule(ch.Schedule); err != nil {
+		probs.add(path+".schedule", "%v", err)
+	}
+	if ch.ExpectedLatency != "" && ch.ExpectedLatency != LatencyOff {
+		if d, err := time.ParseDuration(ch.ExpectedLatency); err != nil || d <= 0 {
+			probs.add(path+".expected_latency", "%q is not a duration, e.g. 5ms, or off", ch.ExpectedLatency)
+		} else if !slices.Contains(LatencyCheckTypes, ch.Type) {
+			probs.add(path+".expected_latency", "%s checks have no response time to expect", ch.Type)
+		}
+	}
+}
+
+func (s Settings) check(probs *Problems) {
+	durations := []field{
+		{"settings.alerts.reminder_interval", s.Alerts.ReminderInterval},
+		{"settings.alerts.batch_window", s.Alerts.BatchWindow},
+		{"settings.alerts.startup_grace", s.Alerts.StartupGrace},
+		{"settings.alerts.flap_window", s.Alerts.FlapWindow},
+		{"settings.twilio.call_after", s.Twilio.CallAfter},
+		{"settings.remote.refresh", s.Remote.Refresh},
+		{"settings.auth.session_ttl", s.Auth.SessionTTL},
+	}
+	for _, f := range durations {
+		if f.value == "" {
+			continue
+		}
+		if d, err := time.ParseDuration(f.value); err != nil || d < 0 {
+			probs.add(f.name, "%q is not a duration, e.g. 30s or 6h", f.value)
+		}
+	}
+	if s.Alerts.FlapThreshold < 0 {
+		probs.add("settings.alerts.flap_threshold", "must be 0 or more")
+	}
+	checkQuietHours(probs, "settings.alerts.quiet_hours", s.Alerts.QuietHours)
+	for _, channel := range slices.Sorted(maps.Keys(s.Alerts.ChannelQuietHours)) {
+		checkQuietHours(probs, "settings.alerts.channel_quiet_hours."+channel, s.Alerts.ChannelQuietHours[channel])
+	}
+	for _, t := range slices.Sorted(maps.Keys(s.Latency.Expected)) {
+		name := "settings.latency.expected." + t
+		if !slices.Contains(LatencyCheckTypes, CheckType(t)) {
+			probs.add(name, "%q is not a check type with a response time", t)
+		}
+		if d, err := time.ParseDuration(s.Latency.Expected[t]); err != nil || d <= 0 {
+			probs.add(name, "%q is not a duration, e.g. 5ms", s.Latency.Expected[t])
+		}
+	}
+	if s.Latency.SlowRuns < 0 {
+		probs.add("settings.latency.slow_runs", "must be 0 or more")
+	}
+	if s.Latency.ClearPercent < 0 || s.Latency.ClearPercent > 100 {
+		probs.add("settings.latency.clear_percent", "must be between 1 and 100, or 0 for the default")
+	}
+	if s.Concurrency.MaxProbes < 0 {
+		probs.add("settings.concurrency.max_probes", "must be 0 or more")
+	}
+	if s.Concurrency.PerHost < 0 {
+		probs.add("settings.concurrency.per_host", "must be 0 or more")
+	}
+	if err := validate.UserAgent(s.Probes.UserAgent); err != nil {
+		probs.add("settings.probes.user_agent", "%v", err)
+	}
+	if err := validate.HeaderName(s.Probes.ProbeHeader); err != nil {
+		probs.add("settings.probes.probe_header", "%v", err)
+	}
+	if tz := s.Display.Timezone; tz != "" {
+		if _, err := LoadTimezone(tz); err != nil {
+			probs.add("settings.display.timezone", "%v", err)
+		}
+	}
+	if f := s.Metrics.Format; s.Metrics.Enabled && f != MetricsInflux && f != MetricsGraphite {
+		probs.add("settings.metrics.format", "must be %s or %s", MetricsInflux, MetricsGraphite)
+	}
+	for i, n := range s.Twilio.To {
+		if err := validate.PhoneNumber(n); err != nil {
+			probs.add(fmt.Sprintf("settings.twilio.to[%d]", i), "%v", err)
+		}
+	}
+	s.Auth.check(probs)
+}
+
+func (a AuthSettings) check(probs *Problems) {
+	if !a.Enabled() {
+		return
+	}
+	if a.SingleSignOn() {
+		if err := validate.URL(a.OIDC.Issuer); err != nil {
+			probs.add("settings.auth.oidc.issuer", "%v", err)
+		}
+		if a.OIDC.ClientID == "" {
+			probs.add("settings.auth.oidc.client_id", "is required")
+		}
+		if err := validate.URL(a.OIDC.RedirectURL); err != nil {
+			probs.add("settings.auth.oidc.redirect_url", "%v", err)
+		}
+	}
+	validRole := func(r Role) bool { return r == RoleAdmin || r == RoleViewer }
+	for i, m := range a.Roles {
+		if m.Group == "" {
+			probs.add(fmt.Sprintf("settings.auth.roles[%d].group", i), "is required")
+		}
+		if !validRole(m.Role) {
+			probs.add(fmt.Sprintf("settings.auth.roles[%d].role", i), "must be %s or %s", RoleAdmin, RoleViewer)
+		}
+	}
+	if a.DefaultRole != "" && !validRole(a.DefaultRole) {
+		probs.add("settings.auth.default_role", "must be %s, %s or empty", RoleAdmin, RoleViewer)
+	}
+	seen := map[string]bool{}
+	for i, t := range a.Tokens {
+		path := fmt.Sprintf("settings.auth.tokens[%d]", i)
+		if t.Name == "" {
+			probs.add(path+".name", "is required")
+		}
+		switch {
+		case len(t.Token) < MinTokenLength:
+			probs.add(path+".token", "must be at least %d characters", MinTokenLength)
+		case seen[t.Token]:
+			probs.add(path+".token", "is the same as another token's")
+		}
+		seen[t.Token] = true
+		if !validRole(t.Role) {
+			probs.add(path+".role", "must be %s or %s", RoleAdmin, RoleViewer)
+		}
+	}
+	for i, p := range a.Public {
+		if !strings.HasPrefix(p, "/") {
+			probs.add(fmt.Sprintf("settings.auth.public[%d]", i), "%q must be a path starting with /", p)
+		}
+	}
+}
+
+func checkQuietHours(probs *Problems, path string, q QuietHours) {
+	for _, f := range []field{{"start", q.Start}, {"end", q.End}} {
+		if f.value == "" {
+			continue
+		}
+		if _, err := ParseClock(f.value); err != nil {
+			probs.add(path+"."+f.name, "%q is not a time of day, e.g. 23:00", f.value)
+		}
+	}
+}
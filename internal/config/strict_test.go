@@ -0,0 +1,243 @@
+package config
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// writeConfig writes files, named relative to a new directory, and returns
+// the path of the first
+func writeConfig(t *testing.T, files ...string) string {
+	t.Helper()
+	dir := t.TempDir()
+	for i := 0; i+1 < len(files); i += 2 {
+		p := filepath.Join(dir, files[i])
+		if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, []byte(files[i+1]), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return filepath.Join(dir, files[0])
+}
+
+func TestLoadStrict(t *testing.T) {
+	tests := []struct {
+		name   string
+		config string
+		want   []string // Problems, as Problem.String gives them; none if the config is fine
+	}{
+		{
+			name: "valid",
+			config: `hosts:
+  - name: router
+    address: 192.168.1.1
+    checks:
+      - type: http
+        url: http://192.168.1.1
+        expect: 204
+      - type: tcp
+        port: 22
+settings:
+  alerts:
+    reminder_interval: 6h
+`,
+		},
+		{
+			name: "misspelt key",
+			config: `hosts:
+  - name: router
+    adress: 192.168.1.1
+`,
+			want: []string{
+				// A missing key has no line, so comes first
+				`hosts[0].address: is required`,
+				`line 3: hosts[0].adress: unknown key (did you mean "address"?)`,
+			},
+		},
+		{
+			name: "unknown key with nothing near",
+			config: `hosts:
+  - name: router
+    address: 192.168.1.1
+    checks:
+      - type: ping
+        colour: blue
+`,
+			want: []string{`line 6: hosts[0].checks[0].colour: unknown key`},
+		},
+		{
+			name: "unknown settings section",
+			config: `hosts: []
+settings:
+  alert:
+    reminder_interval: 6h
+`,
+			want: []string{`line 3: settings.alert: unknown key (did you mean "alerts"?)`},
+		},
+		{
+			name: "out of range values",
+			config: `hosts:
+  - name: nas
+    address: 192.168.1.2
+    checks:
+      - type: tcp
+        port: 70000
+      - type: http
+        url: http://192.168.1.2
+        expect: 42
+      - type: ping
+        ping_count: -1
+`,
+			want: []string{
+				"line 6: hosts[0].checks[0].port: must be between 1 and 65535",
+				"line 9: hosts[0].checks[1].expect: must be an HTTP status code between 100 and 599",
+				"line 11: hosts[0].checks[2].ping_count: must be between 1 and 20",
+			},
+		},
+		{
+			name: "bad values",
+			config: `hosts:
+  - name: nas
+    address: "nas lan"
+    checks:
+      - type: http
+        url: nas.lan
+      - type: gopher
+settings:
+  alerts:
+    reminder_interval: daily
+`,
+			want: []string{
+				`line 3: hosts[0].address: "nas lan" is not a valid hostname or IP address`,
+				"line 6: hosts[0].checks[0].url: must start with http:// or https://",
+				"line 7: hosts[0].checks[1].type: unknown check type",
+				`line 10: settings.alerts.reminder_interval: "daily" is not a duration`,
+			},
+		},
+		{
+			name: "duplicate names and ids",
+			config: `hosts:
+  - name: nas
+    address: 192.168.1.2
+    checks:
+      - type: ping
+        id: nas
+  - name: nas
+    address: 192.168.1.3
+    checks:
+      - type: ping
+        id: nas
+`,
+			want: []string{
+				`line 7: hosts[1].name: duplicate host name "nas" (also hosts[0].name)`,
+				`line 11: hosts[1].checks[0].id: duplicate check id "nas" (also hosts[0].checks[0])`,
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := writeConfig(t, "config.yaml", tt.config)
+			cfg, err := LoadStrict(path)
+			if len(tt.want) == 0 {
+				if err != nil || cfg == nil {
+					t.Fatalf("LoadStrict = %v", err)
+				}
+				return
+			}
+			var probs Problems
+			if !errors.As(err, &probs) {
+				t.Fatalf("LoadStrict = %v, want Problems", err)
+			}
+			if cfg != nil {
+				t.Error("LoadStrict returned a config along with problems")
+			}
+			if len(probs) != len(tt.want) {
+				t.Fatalf("LoadStrict found %d problems, want %d:\n%v", len(probs), len(tt.want), probs)
+			}
+			// Problems come back in file order
+			want := strings.Join(tt.want, "\n")
+			for i, p := range probs {
+				if !strings.HasPrefix(p.String(), tt.want[i]) {
+					t.Errorf("problems =\n%v\nwant\n%s", probs, want)
+					break
+				}
+			}
+
+			// Load takes the same file, ignoring what it doesn't know
+			if _, err := Load(path); err != nil {
+				t.Errorf("Load = %v, want the config loaded anyway", err)
+			}
+		})
+	}
+}
+
+func TestLoadStrictTOML(t *testing.T) {
+	path := writeConfig(t, "config.toml", `[[hosts]]
+name = "router"
+address = "192.168.1.1"
+
+[[hosts.checks]]
+type = "tcp"
+prot = 22
+`)
+	_, err := LoadStrict(path)
+	var probs Problems
+	if !errors.As(err, &probs) || len(probs) != 1 {
+		t.Fatalf("LoadStrict = %v, want 1 problem", err)
+	}
+	// TOML records no lines
+	if got, want := probs[0].String(), `hosts[0].checks[0].prot: unknown key (did you mean "port"?)`; got != want {
+		t.Errorf("problem = %q, want %q", got, want)
+	}
+	if cfg, err := Load(path); err != nil || cfg.Hosts[0].Checks[0].Port != 0 {
+		t.Errorf("Load = %v, want the config without a port", err)
+	}
+}
+
+func TestLoadStrictIncludes(t *testing.T) {
+	path := writeConfig(t,
+		"config.yaml", `include: [conf.d]
+hosts:
+  - name: router
+    address: 192.168.1.1
+`,
+		"conf.d/nas.yaml", `name: nas
+address: 192.168.1.2
+checks:
+  - type: tcp
+    port: 22
+    tiemout: 5s
+`,
+		"conf.d/more.yaml", `hosts:
+  - name: router
+    address: 192.168.1.3
+`)
+	_, err := LoadStrict(path)
+	var probs Problems
+	if !errors.As(err, &probs) {
+		t.Fatalf("LoadStrict = %v, want Problems", err)
+	}
+	dir := filepath.Dir(path)
+	more, nas := filepath.Join(dir, "conf.d", "more.yaml"), filepath.Join(dir, "conf.d", "nas.yaml")
+	want := []Problem{
+		// Include files are read in name order
+		{File: more, Line: 2, Field: "hosts[0].name"},
+		{File: nas, Line: 6, Field: "checks[0].tiemout"},
+	}
+	if len(probs) != len(want) {
+		t.Fatalf("LoadStrict found %d problems, want %d:\n%v", len(probs), len(want), probs)
+	}
+	for i, w := range want {
+		if p := probs[i]; p.File != w.File || p.Line != w.Line || p.Field != w.Field {
+			t.Errorf("problem %d = %s, want %s in %s at line %d", i, p, w.Field, w.File, w.Line)
+		}
+	}
+	if !strings.Contains(probs[0].Message, `duplicate host name "router" (also hosts[0].name)`) {
+		t.Errorf("duplicate across files = %q", probs[0].Message)
+	}
+}
@@ -0,0 +1,190 @@
+// Package homeassistant publishes check results as Home Assistant entities
+// through its REST API. Each check becomes a connectivity binary_sensor
+// that is "on" while the check is up.
+package homeassistant
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/andrewsjg/simple-healthchecker/copilot/internal/config"
+)
+
+const (
+	defaultPrefix = "poke443"
+
+	// refreshInterval is how often an unchanged entity is pushed again. Home
+	// Assistant forgets REST-set states when it restarts, and each push with
+	// a new latency is another row in its history, so unchanged checks are
+	// refreshed now and then rather than on every run.
+	refreshInterval = 5 * time.Minute
+)
+
+// Result is one check's latest result
+type Result struct {
+	Time      time.Time
+	Host      string
+	CheckType string
+	Check     string // The check's ID, or its type and index if it has none
+	Status    string // "up", "down" or "blocked"
+	Latency   time.Duration
+	Message   string
+}
+
+// Client pushes entity states to Home Assistant
+type Client struct {
+	mu       sync.Mutex
+	settings config.HomeAssistantSettings
+	http     *http.Client
+	pushed   map[string]pushed // Entity ID -> what was last sent
+}
+
+type pushed struct {
+	status string
+	at     time.Time
+}
+
+// NewClient creates a new Home Assistant client
+func NewClient(settings config.HomeAssistantSettings) *Client {
+	return &Client{
+		settings: settings,
+		http: &http.Client{
+			Timeout: 10 * time.Second,
+		},
+		pushed: make(map[string]pushed),
+	}
+}
+
+// IsEnabled returns whether publishing is enabled and configured well enough
+// to try
+func (c *Client) IsEnabled() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.settings.Enabled && Validate(c.settings) == nil
+}
+
+// Validate reports a problem with the settings that would stop every push
+func Validate(settings config.HomeAssistantSettings) error {
+	u, err := url.Parse(settings.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("home assistant needs a url such as http://homeassistant.local:8123")
+	}
+	if settings.Token == "" {
+		return fmt.Errorf("home assistant needs a long-lived access token")
+	}
+	return nil
+}
+
+// EntityID returns the entity a check is published as, e.g.
+// binary_sensor.poke443_web_site
+func EntityID(prefix, host, check string) string {
+	if prefix == "" {
+		prefix = defaultPrefix
+	}
+	return "binary_sensor." + objectID(prefix+"_"+host+"_"+check)
+}
+
+var unsafeID = regexp.MustCompile(`[^a-z0-9]+`)
+
+// objectID lowercases s and replaces runs of other characters with "_", as
+// Home Assistant requires
+func objectID(s string) string {
+	return strings.Trim(unsafeID.ReplaceAllString(strings.ToLower(s), "_"), "_")
+}
+
+// Push sends the results whose status changed since the last push, or that
+// haven't been sent for refreshInterval. A failed push is retried with the
+// next results.
+func (c *Client) Push(results []Result) error {
+	c.mu.Lock()
+	settings := c.settings
+	var due []Result
+	for _, r := range results {
+		last, ok := c.pushed[EntityID(settings.Prefix, r.Host, r.Check)]
+		if !ok || last.status != r.Status || r.Time.Sub(last.at) >= refreshInterval {
+			due = append(due, r)
+		}
+	}
+	c.mu.Unlock()
+
+	if !settings.Enabled || len(due) == 0 {
+		return nil
+	}
+	if err := Validate(settings); err != nil {
+		return err
+	}
+	var errs []error
+	for _, r := range due {
+		id := EntityID(settings.Prefix, r.Host, r.Check)
+		if err := c.post(settings, id, r); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", id, err))
+			continue
+		}
+		c.mu.Lock()
+		c.pushed[id] = pushed{status: r.Status, at: r.Time}
+		c.mu.Unlock()
+	}
+	return errors.Join(errs...)
+}
+
+// post sets one entity's state. Blocked checks show as unavailable, so
+// automations watching for "off" only fire on genuine failures.
+func (c *Client) post(settings config.HomeAssistantSettings, id string, r Result) error {
+	state := "on"
+	switch r.Status {
+	case "down":
+		state = "off"
+	case "blocked":
+		state = "unavailable"
+	}
+	name := r.Host + " " + strings.ToUpper(r.CheckType)
+	if !strings.HasPrefix(r.Check, r.CheckType+"_") {
+		name += " " + r.Check
+	}
+	body, err := json.Marshal(map[string]any{
+		"state": state,
+		"attributes": map[string]any{
+			"friendly_name": name,
+			"device_class":  "connectivity",
+			"host":          r.Host,
+			"check":         r.Check,
+			"check_type":    r.CheckType,
+			"status":        r.Status,
+			"latency_ms":    float64(r.Latency.Microseconds()) / 1000,
+			"message":       r.Message,
+			"last_checked":  r.Time.Format(time.RFC3339),
+		},
+	})
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest(http.MethodPost, strings.TrimSuffix(settings.URL, "/")+"/api/states/"+id, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	return c.do(req, settings.Token)
+}
+
+func (c *Client) do(req *http.Request, token string) error {
+	req.Header.Set("Authorization", "Bearer "+token)
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("%s: %s", resp.Status, strings.TrimSpace(string(msg)))
+	}
+	return nil
+}
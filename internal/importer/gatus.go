@@ -0,0 +1,105 @@
+package importer
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/andrewsjg/simple-healthchecker/copilot/internal/config"
+)
+
+// gatusConfig is the part of a Gatus config that describes endpoints
+type gatusConfig struct {
+	Endpoints []gatusEndpoint `yaml:"endpoints"`
+}
+
+type gatusEndpoint struct {
+	Name       string   `yaml:"name"`
+	Group      string   `yaml:"group"`
+	URL        string   `yaml:"url"`
+	Enabled    *bool    `yaml:"enabled"`
+	Conditions []string `yaml:"conditions"`
+	Client     struct {
+		Insecure       bool `yaml:"insecure"`
+		IgnoreRedirect bool `yaml:"ignore-redirect"`
+	} `yaml:"client"`
+}
+
+var (
+	gatusStatus    = regexp.MustCompile(`^\[STATUS\]\s*==\s*(\d{3})$`)
+	gatusBody      = regexp.MustCompile(`^\[BODY\]\s*(==|!=)\s*pat\(\*([^*]+)\*\)$`)
+	gatusConnected = regexp.MustCompile(`^\[CONNECTED\]\s*==\s*true$`)
+)
+
+// Gatus converts the endpoints in a Gatus config. http(s), tcp, icmp and
+// ws(s) endpoints are imported with the conditions POKE443 can check: the
+// status code and body patterns like pat(*text*). Groups become host tags.
+func Gatus(data []byte) (Result, error) {
+	var cfg gatusConfig
+	if err := yaml.Unmarshal(data, &cfg); err != nil {
+		return Result{}, fmt.Errorf("invalid Gatus config: %w", err)
+	}
+	if len(cfg.Endpoints) == 0 {
+		return Result{}, fmt.Errorf("no endpoints found")
+	}
+	b := newBuilder("Gatus")
+
+	for _, e := range cfg.Endpoints {
+		c := config.Check{Enabled: e.Enabled == nil || *e.Enabled}
+		u, err := url.Parse(e.URL)
+		if err != nil || u.Host == "" {
+			b.notef("%s: can't read URL %q", e.Name, e.URL)
+			continue
+		}
+
+		address := u.Hostname()
+		switch u.Scheme {
+		case "http", "https":
+			c.Type = config.CheckHTTP
+			c.URL = e.URL
+			c.InsecureSkipVerify = e.Client.Insecure
+			c.NoFollowRedirects = e.Client.IgnoreRedirect
+		case "ws", "wss":
+			c.Type = config.CheckWS
+			c.URL = e.URL
+		case "tcp", "tls", "starttls":
+			host, port, ok := hostPort(u.Host)
+			if !ok {
+				b.notef("%s: no port in URL %q", e.Name, e.URL)
+				continue
+			}
+			address = host
+			c.Type = config.CheckTCP
+			c.Port = port
+			if u.Scheme != "tcp" {
+				b.notef("%s: imported as a TCP check; the TLS handshake isn't checked", e.Name)
+			}
+		case "icmp":
+			c.Type = config.CheckPing
+		default:
+			b.notef("%s: %s endpoints aren't supported", e.Name, u.Scheme)
+			continue
+		}
+
+		for _, cond := range e.Conditions {
+			cond = strings.TrimSpace(cond)
+			if m := gatusStatus.FindStringSubmatch(cond); m != nil && c.Type == config.CheckHTTP {
+				c.Expect, _ = strconv.Atoi(m[1])
+			} else if m := gatusBody.FindStringSubmatch(cond); m != nil && c.Type == config.CheckHTTP {
+				if m[1] == "==" {
+					c.MustContain = m[2]
+				} else {
+					c.MustNotContain = m[2]
+				}
+			} else if !gatusConnected.MatchString(cond) {
+				b.notef("%s: condition %q not imported", e.Name, cond)
+			}
+		}
+		b.add(e.Name, address, []string{e.Group}, c)
+	}
+	return b.res, nil
+}
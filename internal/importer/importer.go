@@ -0,0 +1,130 @@
+// Package importer converts monitors from other uptime tools into POKE443
+// hosts and checks, so switching doesn't mean re-entering everything.
+// Uptime Kuma backups (JSON) and Gatus configs (YAML) are supported.
+package importer
+
+import (
+	"bytes"
+	"fmt"
+	"net"
+	"net/url"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/andrewsjg/simple-healthchecker/copilot/internal/config"
+	"github.com/andrewsjg/simple-healthchecker/copilot/internal/validate"
+)
+
+// Result is the hosts an import produced
+type Result struct {
+	Source string // "Uptime Kuma" or "Gatus"
+	Hosts  []config.Host
+	Notes  []string // Monitors and settings that couldn't be carried over
+}
+
+// Parse converts an Uptime Kuma backup or a Gatus config, telling them apart
+// by content
+func Parse(data []byte) (Result, error) {
+	if trimmed := bytes.TrimSpace(data); len(trimmed) > 0 && trimmed[0] == '{' && bytes.Contains(trimmed, []byte(`"monitorList"`)) {
+		return Kuma(data)
+	}
+	res, err := Gatus(data)
+	if err != nil {
+		return Result{}, fmt.Errorf("not an Uptime Kuma backup or Gatus config: %w", err)
+	}
+	return res, nil
+}
+
+// builder collects checks into hosts, one host per address, so a site
+// monitored several ways shows up as one host with several checks
+type builder struct {
+	res    Result
+	byAddr map[string]int // Address -> index in res.Hosts
+	names  map[string]bool
+	ids    map[string]bool
+}
+
+func newBuilder(source string) *builder {
+	return &builder{
+		res:    Result{Source: source},
+		byAddr: make(map[string]int),
+		names:  make(map[string]bool),
+		ids:    make(map[string]bool),
+	}
+}
+
+// add adds c, named after the monitor it came from, to the host for
+// address. The host takes the name of its first monitor.
+func (b *builder) add(monitor, address string, tags []string, c config.Check) {
+	c.ID = unique(b.ids, slug(monitor))
+	if name := strings.TrimSpace(monitor); validate.DisplayName(name) == nil {
+		c.Name = name
+	}
+	i, ok := b.byAddr[address]
+	if !ok {
+		name := strings.TrimSpace(monitor)
+		if name == "" {
+			name = address
+		}
+		i = len(b.res.Hosts)
+		b.byAddr[address] = i
+		b.res.Hosts = append(b.res.Hosts, config.Host{Name: unique(b.names, name), Address: address})
+	}
+	h := &b.res.Hosts[i]
+	h.Checks = append(h.Checks, c)
+	for _, tag := range tags {
+		if tag != "" && !slices.Contains(h.Tags, tag) {
+			h.Tags = append(h.Tags, tag)
+		}
+	}
+}
+
+func (b *builder) notef(format string, args ...any) {
+	b.res.Notes = append(b.res.Notes, fmt.Sprintf(format, args...))
+}
+
+// unique returns s, or s with a numeric suffix if it is already in seen, and
+// records the result
+func unique(seen map[string]bool, s string) string {
+	name := s
+	for n := 2; seen[name]; n++ {
+		name = s + "-" + strconv.Itoa(n)
+	}
+	seen[name] = true
+	return name
+}
+
+var nonSlug = regexp.MustCompile(`[^a-z0-9]+`)
+
+// slug turns a monitor name into a check ID, e.g. "Web (prod)" -> "web-prod"
+func slug(s string) string {
+	s = strings.Trim(nonSlug.ReplaceAllString(strings.ToLower(s), "-"), "-")
+	if s == "" {
+		s = "check"
+	}
+	return s
+}
+
+// urlHost returns the host name in rawURL, or "" if there isn't one
+func urlHost(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return ""
+	}
+	return u.Hostname()
+}
+
+// hostPort splits "host:port", as in Gatus tcp:// URLs
+func hostPort(s string) (string, int, bool) {
+	host, portStr, err := net.SplitHostPort(s)
+	if err != nil {
+		return "", 0, false
+	}
+	port, err := strconv.Atoi(portStr)
+	if err != nil || port < 1 || port > 65535 {
+		return "", 0, false
+	}
+	return host, port, true
+}
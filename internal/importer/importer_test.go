@@ -0,0 +1,190 @@
+package importer
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/andrewsjg/simple-healthchecker/copilot/internal/config"
+)
+
+func readFixture(t *testing.T, name string) []byte {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join("testdata", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+// checkResult compares res with want host by host, so a failure names the
+// host that differs
+func checkResult(t *testing.T, res Result, want Result) {
+	t.Helper()
+	if res.Source != want.Source {
+		t.Errorf("Source = %q, want %q", res.Source, want.Source)
+	}
+	if len(res.Hosts) != len(want.Hosts) {
+		t.Fatalf("got %d hosts, want %d: %+v", len(res.Hosts), len(want.Hosts), res.Hosts)
+	}
+	for i := range want.Hosts {
+		if !reflect.DeepEqual(res.Hosts[i], want.Hosts[i]) {
+			t.Errorf("host %d =\n%+v\nwant\n%+v", i, res.Hosts[i], want.Hosts[i])
+		}
+	}
+	if !slices.Equal(res.Notes, want.Notes) {
+		t.Errorf("notes =\n%s\nwant\n%s", strings.Join(res.Notes, "\n"), strings.Join(want.Notes, "\n"))
+	}
+}
+
+func TestKuma(t *testing.T) {
+	res, err := Kuma(readFixture(t, "kuma.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkResult(t, res, Result{
+		Source: "Uptime Kuma",
+		Hosts: []config.Host{
+			{
+				// Monitors of one address share a host, named after the first
+				Name: "Plex web UI", Address: "plex.lan", Tags: []string{"Home lab", "media"},
+				Checks: []config.Check{
+					{
+						ID: "plex-web-ui", Name: "Plex web UI", Type: config.CheckHTTP, Enabled: true, Notes: "Media server",
+						URL: "https://plex.lan:32400/web", Expect: 200, InsecureSkipVerify: true,
+					},
+					{
+						ID: "plex-login", Name: "Plex login", Type: config.CheckHTTP, Enabled: true,
+						URL: "https://plex.lan:32400/login", Expect: 401, NoFollowRedirects: true, MustContain: "Sign in",
+					},
+				},
+			},
+			{
+				Name: "Error page", Address: "status.example.com",
+				Checks: []config.Check{{
+					ID: "error-page", Name: "Error page", Type: config.CheckHTTP,
+					URL: "https://status.example.com/", Expect: 200, MaxRedirects: 3, MustNotContain: "Internal Server Error",
+				}},
+			},
+			{
+				Name: "API health", Address: "api.example.com",
+				Checks: []config.Check{{
+					ID: "api-health", Name: "API health", Type: config.CheckHTTP, Enabled: true,
+					URL: "https://api.example.com/health", Expect: 200,
+				}},
+			},
+			{
+				Name: "NAS SSH", Address: "nas.lan", Tags: []string{"Home lab"},
+				Checks: []config.Check{{ID: "nas-ssh", Name: "NAS SSH", Type: config.CheckTCP, Enabled: true, Port: 22}},
+			},
+			{
+				Name: "Router", Address: "192.168.1.1",
+				Checks: []config.Check{{ID: "router", Name: "Router", Type: config.CheckPing, Enabled: true}},
+			},
+			{
+				Name: "Guest Wi-Fi off", Address: "192.168.50.1",
+				Checks: []config.Check{{ID: "guest-wi-fi-off", Name: "Guest Wi-Fi off", Type: config.CheckPing, Enabled: true, Invert: true}},
+			},
+		},
+		Notes: []string{
+			"Error page: accepted status codes 200-299, 300-399 imported as 200",
+			"API health: JSON query not imported; imported as a plain HTTP check",
+			"Postgres: postgres monitors aren't supported",
+			`Broken URL: no host in URL "not a url"`,
+			"No port: no host or port",
+			"No host: no host",
+		},
+	})
+}
+
+func TestGatus(t *testing.T) {
+	res, err := Gatus(readFixture(t, "gatus.yaml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkResult(t, res, Result{
+		Source: "Gatus",
+		Hosts: []config.Host{
+			{
+				Name: "website", Address: "example.com", Tags: []string{"public"},
+				Checks: []config.Check{
+					{
+						ID: "website", Name: "website", Type: config.CheckHTTP, Enabled: true,
+						URL: "https://example.com/", Expect: 200, MustContain: "Welcome",
+						InsecureSkipVerify: true, NoFollowRedirects: true,
+					},
+					{
+						ID: "website-errors", Name: "website errors", Type: config.CheckHTTP,
+						URL: "https://example.com/status", MustNotContain: "error",
+					},
+					{ID: "feed", Name: "feed", Type: config.CheckWS, Enabled: true, URL: "wss://example.com/feed"},
+				},
+			},
+			{
+				Name: "dns", Address: "192.168.1.53", Tags: []string{"core"},
+				Checks: []config.Check{{ID: "dns", Name: "dns", Type: config.CheckTCP, Enabled: true, Port: 53}},
+			},
+			{
+				Name: "mail", Address: "mail.example.com", Tags: []string{"core"},
+				Checks: []config.Check{{ID: "mail", Name: "mail", Type: config.CheckTCP, Enabled: true, Port: 587}},
+			},
+			{
+				Name: "router", Address: "192.168.1.1", Tags: []string{"core"},
+				Checks: []config.Check{{ID: "router", Name: "router", Type: config.CheckPing, Enabled: true}},
+			},
+		},
+		Notes: []string{
+			`website: condition "[RESPONSE_TIME] < 500" not imported`,
+			"mail: imported as a TCP check; the TLS handshake isn't checked",
+			`resolver: can't read URL "8.8.8.8"`,
+			"lookup: dns endpoints aren't supported",
+			`tcp without port: no port in URL "tcp://192.168.1.53"`,
+		},
+	})
+}
+
+func TestParse(t *testing.T) {
+	for name, source := range map[string]string{"kuma.json": "Uptime Kuma", "gatus.yaml": "Gatus"} {
+		res, err := Parse(readFixture(t, name))
+		if err != nil || res.Source != source {
+			t.Errorf("Parse(%s) = %q, %v; want %s", name, res.Source, err, source)
+		}
+	}
+}
+
+func TestMalformed(t *testing.T) {
+	tests := []struct {
+		name    string
+		parse   func([]byte) (Result, error)
+		data    string
+		wantErr string
+	}{
+		{"truncated Kuma backup", Kuma, `{"monitorList": [{"id": 1,`, "invalid Uptime Kuma backup"},
+		{"Kuma flag that isn't one", Kuma, `{"monitorList": [{"id": 1, "type": "ping", "active": "yes"}]}`, "invalid flag"},
+		{"Kuma list of the wrong type", Kuma, `{"monitorList": {"id": 1}}`, "invalid Uptime Kuma backup"},
+		{"invalid Gatus YAML", Gatus, "endpoints:\n  - name: [web\n", "invalid Gatus config"},
+		{"Gatus without endpoints", Gatus, "storage:\n  type: sqlite\n", "no endpoints found"},
+		{"neither", Parse, "just some text", "not an Uptime Kuma backup or Gatus config"},
+		{"empty", Parse, "", "not an Uptime Kuma backup or Gatus config"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res, err := tt.parse([]byte(tt.data))
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("error = %v, want one mentioning %q", err, tt.wantErr)
+			}
+			if len(res.Hosts) != 0 {
+				t.Errorf("got hosts %+v along with an error", res.Hosts)
+			}
+		})
+	}
+
+	// An empty backup imports nothing, without error
+	res, err := Kuma([]byte(`{"version": "1.23.11", "monitorList": []}`))
+	if err != nil || len(res.Hosts) != 0 || len(res.Notes) != 0 {
+		t.Errorf("Kuma(empty backup) = %+v, %v", res, err)
+	}
+}
@@ -0,0 +1,156 @@
+package importer
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/andrewsjg/simple-healthchecker/copilot/internal/config"
+)
+
+// kumaBackup is the part of an Uptime Kuma backup (Settings > Backup >
+// Export) that describes monitors
+type kumaBackup struct {
+	Version     string        `json:"version"`
+	MonitorList []kumaMonitor `json:"monitorList"`
+}
+
+type kumaMonitor struct {
+	ID            int       `json:"id"`
+	Name          string    `json:"name"`
+	Description   string    `json:"description"`
+	Type          string    `json:"type"`
+	URL           string    `json:"url"`
+	Hostname      string    `json:"hostname"`
+	Port          int       `json:"port"`
+	Active        kumaBool  `json:"active"`
+	Keyword       string    `json:"keyword"`
+	InvertKeyword kumaBool  `json:"invertKeyword"`
+	IgnoreTLS     kumaBool  `json:"ignoreTls"`
+	UpsideDown    kumaBool  `json:"upsideDown"`
+	MaxRedirects  *int      `json:"maxredirects"`
+	StatusCodes   []string  `json:"accepted_statuscodes"`
+	Parent        *int      `json:"parent"`
+	Tags          []kumaTag `json:"tags"`
+}
+
+type kumaTag struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+// kumaBool reads flags that Uptime Kuma writes as true/false or, straight
+// from SQLite, as 1/0
+type kumaBool bool
+
+func (b *kumaBool) UnmarshalJSON(data []byte) error {
+	switch string(data) {
+	case "true", "1":
+		*b = true
+	case "false", "0", "null":
+		*b = false
+	default:
+		return fmt.Errorf("invalid flag %s", data)
+	}
+	return nil
+}
+
+// Kuma converts the monitors in an Uptime Kuma backup. HTTP, keyword, port
+// and ping monitors are imported; the rest are listed in Notes. Monitor
+// groups become host tags.
+func Kuma(data []byte) (Result, error) {
+	var backup kumaBackup
+	if err := json.Unmarshal(data, &backup); err != nil {
+		return Result{}, fmt.Errorf("invalid Uptime Kuma backup: %w", err)
+	}
+	b := newBuilder("Uptime Kuma")
+
+	groups := make(map[int]string)
+	for _, m := range backup.MonitorList {
+		if m.Type == "group" {
+			groups[m.ID] = m.Name
+		}
+	}
+
+	for _, m := range backup.MonitorList {
+		var tags []string
+		if m.Parent != nil {
+			tags = append(tags, groups[*m.Parent])
+		}
+		for _, t := range m.Tags {
+			tags = append(tags, t.Name)
+		}
+		c := config.Check{Enabled: bool(m.Active), Notes: m.Description}
+
+		var address string
+		switch m.Type {
+		case "group":
+			continue
+		case "http", "keyword", "json-query":
+			address = urlHost(m.URL)
+			if address == "" {
+				b.notef("%s: no host in URL %q", m.Name, m.URL)
+				continue
+			}
+			c.Type = config.CheckHTTP
+			c.URL = m.URL
+			c.Expect = kumaExpect(b, m)
+			c.InsecureSkipVerify = bool(m.IgnoreTLS)
+			if m.MaxRedirects != nil {
+				if *m.MaxRedirects == 0 {
+					c.NoFollowRedirects = true
+				} else if *m.MaxRedirects != 10 {
+					c.MaxRedirects = *m.MaxRedirects
+				}
+			}
+			switch {
+			case m.Type == "json-query":
+				b.notef("%s: JSON query not imported; imported as a plain HTTP check", m.Name)
+			case m.Type == "keyword" && bool(m.InvertKeyword):
+				c.MustNotContain = m.Keyword
+			case m.Type == "keyword":
+				c.MustContain = m.Keyword
+			}
+		case "port":
+			if m.Hostname == "" || m.Port < 1 || m.Port > 65535 {
+				b.notef("%s: no host or port", m.Name)
+				continue
+			}
+			address = m.Hostname
+			c.Type = config.CheckTCP
+			c.Port = m.Port
+		case "ping":
+			if m.Hostname == "" {
+				b.notef("%s: no host", m.Name)
+				continue
+			}
+			address = m.Hostname
+			c.Type = config.CheckPing
+		default:
+			b.notef("%s: %s monitors aren't supported", m.Name, m.Type)
+			continue
+		}
+		c.Invert = bool(m.UpsideDown)
+		b.add(m.Name, address, tags, c)
+	}
+	return b.res, nil
+}
+
+// kumaExpect picks the status code an HTTP monitor expects. POKE443 checks
+// for one code, so ranges other than Uptime Kuma's default 200-299 are
+// noted.
+func kumaExpect(b *builder, m kumaMonitor) int {
+	if len(m.StatusCodes) == 1 {
+		if m.StatusCodes[0] == "200-299" {
+			return 200
+		}
+		if code, err := strconv.Atoi(m.StatusCodes[0]); err == nil {
+			return code
+		}
+	}
+	if len(m.StatusCodes) > 0 {
+		b.notef("%s: accepted status codes %s imported as 200", m.Name, strings.Join(m.StatusCodes, ", "))
+	}
+	return 200
+}
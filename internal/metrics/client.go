@@ -0,0 +1,176 @@
+package metrics
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/andrewsjg/simple-healthchecker/copilot/internal/config"
+)
+
+const defaultPrefix = "poke443"
+
+// Point is one check result
+type Point struct {
+	Time      time.Time
+	Host      string
+	CheckType string
+	Check     string // The check's ID, or its type and index if it has none
+	Status    string // "up", "down" or "blocked"
+	Latency   time.Duration
+}
+
+// Client pushes check results to InfluxDB or Graphite
+type Client struct {
+	mu       sync.RWMutex
+	settings config.MetricsSettings
+	http     *http.Client
+}
+
+// NewClient creates a new metrics client
+func NewClient(settings config.MetricsSettings) *Client {
+	return &Client{
+		settings: settings,
+		http: &http.Client{
+			Timeout: 10 * time.Second,
+		},
+	}
+}
+
+// IsEnabled returns whether metrics export is enabled and configured well
+// enough to try
+func (c *Client) IsEnabled() bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.settings.Enabled && Validate(c.settings) == nil
+}
+
+// Validate reports a problem with the settings that would stop every push
+func Validate(settings config.MetricsSettings) error {
+	switch settings.Format {
+	case config.MetricsInflux:
+		if settings.URL == "" {
+			return fmt.Errorf("influx metrics need a url")
+		}
+	case config.MetricsGraphite:
+		if _, _, err := net.SplitHostPort(settings.Address); err != nil {
+			return fmt.Errorf("graphite metrics need an address as host:port: %w", err)
+		}
+	default:
+		return fmt.Errorf("unknown metrics format %q (want %q or %q)", settings.Format, config.MetricsInflux, config.MetricsGraphite)
+	}
+	return nil
+}
+
+// Push sends points in the configured format
+func (c *Client) Push(points []Point) error {
+	c.mu.RLock()
+	settings := c.settings
+	c.mu.RUnlock()
+
+	if !settings.Enabled || len(points) == 0 {
+		return nil
+	}
+	if err := Validate(settings); err != nil {
+		return err
+	}
+	prefix := settings.Prefix
+	if prefix == "" {
+		prefix = defaultPrefix
+	}
+	if settings.Format == config.MetricsGraphite {
+		return c.pushGraphite(settings.Address, prefix, points)
+	}
+	return c.pushInflux(settings.URL, settings.Token, prefix, points)
+}
+
+// pushInflux writes points as line protocol, one line per check:
+//
+//	poke443,host=web,check=site,check_type=http up=1i,blocked=0i,latency_ms=12.5,status="up" 1700000000000000000
+func (c *Client) pushInflux(url, token, measurement string, points []Point) error {
+	var buf bytes.Buffer
+	for _, p := range points {
+		fmt.Fprintf(&buf, "%s,host=%s,check=%s,check_type=%s up=%di,blocked=%di,latency_ms=%s,status=%q %d\n",
+			escapeInflux(measurement), escapeInflux(p.Host), escapeInflux(p.Check), escapeInflux(p.CheckType),
+			boolInt(p.Status == "up"), boolInt(p.Status == "blocked"), latencyMS(p.Latency), p.Status, p.Time.UnixNano())
+	}
+	req, err := http.NewRequest(http.MethodPost, url, &buf)
+	if err != nil {
+		return fmt.Errorf("influx: %w", err)
+	}
+	req.Header.Set("Content-Type", "text/plain; charset=utf-8")
+	if token != "" {
+		req.Header.Set("Authorization", "Token "+token)
+	}
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return fmt.Errorf("influx: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("influx: %s: %s", resp.Status, strings.TrimSpace(string(body)))
+	}
+	return nil
+}
+
+// pushGraphite writes points in the plaintext protocol, three metrics per check:
+//
+//	poke443.web.site.up 1 1700000000
+//	poke443.web.site.blocked 0 1700000000
+//	poke443.web.site.latency_ms 12.5 1700000000
+func (c *Client) pushGraphite(address, prefix string, points []Point) error {
+	var buf bytes.Buffer
+	for _, p := range points {
+		path := prefix + "." + graphiteName(p.Host) + "." + graphiteName(p.Check)
+		ts := p.Time.Unix()
+		fmt.Fprintf(&buf, "%s.up %d %d\n", path, boolInt(p.Status == "up"), ts)
+		fmt.Fprintf(&buf, "%s.blocked %d %d\n", path, boolInt(p.Status == "blocked"), ts)
+		fmt.Fprintf(&buf, "%s.latency_ms %s %d\n", path, latencyMS(p.Latency), ts)
+	}
+	conn, err := net.DialTimeout("tcp", address, 5*time.Second)
+	if err != nil {
+		return fmt.Errorf("graphite: %w", err)
+	}
+	defer conn.Close()
+	_ = conn.SetWriteDeadline(time.Now().Add(10 * time.Second))
+	if _, err := conn.Write(buf.Bytes()); err != nil {
+		return fmt.Errorf("graphite: %w", err)
+	}
+	return nil
+}
+
+var influxEscaper = strings.NewReplacer(",", `\,`, "=", `\=`, " ", `\ `)
+
+// escapeInflux escapes a measurement, tag key or tag value for line protocol
+func escapeInflux(s string) string {
+	return influxEscaper.Replace(s)
+}
+
+var graphiteUnsafe = regexp.MustCompile(`[^A-Za-z0-9_-]+`)
+
+// graphiteName turns s into a single path segment, since dots would split it
+func graphiteName(s string) string {
+	if name := strings.Trim(graphiteUnsafe.ReplaceAllString(s, "_"), "_"); name != "" {
+		return name
+	}
+	return "_"
+}
+
+func latencyMS(d time.Duration) string {
+	return strconv.FormatFloat(float64(d.Microseconds())/1000, 'f', -1, 64)
+}
+
+func boolInt(b bool) int {
+	if b {
+		return 1
+	}
+	return 0
+}
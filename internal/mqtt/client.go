@@ -8,37 +8,66 @@ import (
 	"time"
 
 	"github.com/andrewsjg/simple-healthchecker/copilot/internal/config"
+	"github.com/andrewsjg/simple-healthchecker/copilot/internal/schema"
 	paho "github.com/eclipse/paho.mqtt.golang"
 )
 
 // StateChangeMessage represents a state change notification
 type StateChangeMessage struct {
-	Timestamp time.Time `json:"timestamp"`
-	Host      string    `json:"host"`
-	Address   string    `json:"address"`
-	CheckType string    `json:"check_type"`
-	CheckURL  string    `json:"check_url,omitempty"`
-	CheckID   string    `json:"check_id,omitempty"`
-	Status    string    `json:"status"` // "up", "down", "blocked"
-	LatencyMS int64     `json:"latency_ms,omitempty"`
-	Message   string    `json:"message,omitempty"`
+	SchemaVersion int `json:"schema_version,omitempty"` // Set when published on its own; see the schema package
+
+	Timestamp  time.Time      `json:"timestamp"`
+	Host       string         `json:"host"`
+	Address    string         `json:"address"`
+	CheckType  string         `json:"check_type"`
+	CheckURL   string         `json:"check_url,omitempty"`
+	CheckID    string         `json:"check_id,omitempty"`
+	CheckName  string         `json:"check_name,omitempty"` // Display name, if the check has one
+	Status     string         `json:"status"`               // "up", "down", "blocked"
+	LatencyMS  float64        `json:"latency_ms,omitempty"` // Microsecond precision, e.g. 0.412
+	Message    string         `json:"message,omitempty"`
+	Notes      string         `json:"notes,omitempty"`
+	Runbook    string         `json:"runbook_url,omitempty"`
+	Severity   string         `json:"severity,omitempty"`    // "info", "warning", "critical"
+	Outage     *OutageSummary `json:"outage,omitempty"`      // Set on recovery and reminders
+	Reminder   bool           `json:"reminder,omitempty"`    // Still-down reminder rather than a state change
+	Blocked    []string       `json:"blocked,omitempty"`     // Dependent checks blocked by this failure
+	HostStatus string         `json:"host_status,omitempty"` // The host's rollup status after the change: "up", "degraded", "down", ...
+}
+
+// OutageSummary describes the outage that a recovery message ends
+type OutageSummary struct {
+	Start           *time.Time `json:"start,omitempty"` // Omitted if the check was down at startup
+	DowntimeSeconds int64      `json:"downtime_seconds"`
+	FailedProbes    int        `json:"failed_probes"`
+	UptimePct       float64    `json:"uptime_pct"` // Since monitoring started
 }
 
 // Client manages MQTT connections and publishing
 type Client struct {
-	mu        sync.RWMutex
-	settings  config.MQTTSettings
-	client    paho.Client
-	connected bool
+	mu            sync.RWMutex
+	settings      config.MQTTSettings
+	client        paho.Client
+	connected     bool
+	schemaVersion int // Stamped on every payload; 0 sends the legacy format
 }
 
 // NewClient creates a new MQTT client
 func NewClient(settings config.MQTTSettings) *Client {
 	return &Client{
-		settings: settings,
+		settings:      settings,
+		schemaVersion: schema.Latest,
 	}
 }
 
+// SetSchemaVersion sets the payload schema version to publish, or
+// schema.Legacy for payloads without a schema_version field
+func (c *Client) SetSchemaVersion(v int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.schemaVersion = v
+}
+
 // Connect establishes connection to the MQTT broker
 func (c *Client) Connect() error {
 	c.mu.Lock()
@@ -132,6 +161,7 @@ func (c *Client) PublishStateChange(msg StateChangeMessage) error {
 		return nil
 	}
 
+	msg.SchemaVersion = c.schemaVersion
 	payload, err := json.Marshal(msg)
 	if err != nil {
 		return fmt.Errorf("failed to marshal message: %w", err)
@@ -161,6 +191,90 @@ func (c *Client) PublishStateChange(msg StateChangeMessage) error {
 	return nil
 }
 
+// BatchMessage combines state changes raised within the batching window
+type BatchMessage struct {
+	SchemaVersion int                  `json:"schema_version,omitempty"`
+	Timestamp     time.Time            `json:"timestamp"`
+	Count         int                  `json:"count"`
+	Changes       []StateChangeMessage `json:"changes"`
+}
+
+// PublishBatch publishes several state changes as one message on
+// baseTopic/batch
+func (c *Client) PublishBatch(msgs []StateChangeMessage) error {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	if !c.settings.Enabled || c.client == nil || !c.connected {
+		return nil
+	}
+
+	payload, err := json.Marshal(BatchMessage{SchemaVersion: c.schemaVersion, Timestamp: time.Now(), Count: len(msgs), Changes: msgs})
+	if err != nil {
+		return fmt.Errorf("failed to marshal message: %w", err)
+	}
+
+	baseTopic := c.settings.Topic
+	if baseTopic == "" {
+		baseTopic = "healthchecker/status"
+	}
+	topic := baseTopic + "/batch"
+
+	token := c.client.Publish(topic, 0, false, payload)
+	if !token.WaitTimeout(5 * time.Second) {
+		return fmt.Errorf("MQTT publish timeout")
+	}
+	if err := token.Error(); err != nil {
+		return fmt.Errorf("MQTT publish failed: %w", err)
+	}
+
+	log.Printf("MQTT published to %s: %d changes", topic, len(msgs))
+	return nil
+}
+
+// ConnectivityMessage reports that every host is failing at once, which
+// usually means the monitor itself has lost its network, or that it is over
+type ConnectivityMessage struct {
+	SchemaVersion int       `json:"schema_version,omitempty"`
+	Timestamp     time.Time `json:"timestamp"`
+	Status        string    `json:"status"` // "down", "up", "monitor_online"
+	Message       string    `json:"message"`
+	Checks        []string  `json:"checks,omitempty"` // Affected checks, on "down"
+}
+
+// PublishConnectivity publishes msg on baseTopic/connectivity
+func (c *Client) PublishConnectivity(msg ConnectivityMessage) error {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	if !c.settings.Enabled || c.client == nil || !c.connected {
+		return nil
+	}
+
+	msg.SchemaVersion = c.schemaVersion
+	payload, err := json.Marshal(msg)
+	if err != nil {
+		return fmt.Errorf("failed to marshal message: %w", err)
+	}
+
+	baseTopic := c.settings.Topic
+	if baseTopic == "" {
+		baseTopic = "healthchecker/status"
+	}
+	topic := baseTopic + "/connectivity"
+
+	token := c.client.Publish(topic, 0, false, payload)
+	if !token.WaitTimeout(5 * time.Second) {
+		return fmt.Errorf("MQTT publish timeout")
+	}
+	if err := token.Error(); err != nil {
+		return fmt.Errorf("MQTT publish failed: %w", err)
+	}
+
+	log.Printf("MQTT published to %s: %s", topic, msg.Status)
+	return nil
+}
+
 // IsConnected returns whether the client is connected
 func (c *Client) IsConnected() bool {
 	c.mu.RLock()
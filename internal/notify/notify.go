@@ -0,0 +1,41 @@
+// Package notify holds what the notification channels share: the outage a
+// recovery or reminder describes, and how an alert names its check and the
+// checks its failure blocks
+package notify
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// OutageSummary describes the outage that a recovery alert ends, or that a
+// reminder says is still going
+type OutageSummary struct {
+	Start        time.Time // Zero if the check was down at startup
+	Downtime     time.Duration
+	FailedProbes int
+	UptimePct    float64 // Since monitoring started
+}
+
+// CheckTitle names a check in an alert: its display name, or else its type
+func CheckTitle(name, checkType string) string {
+	if name != "" {
+		return name
+	}
+	return strings.ToUpper(checkType)
+}
+
+// maxBlockedNames is how many blocked checks BlockedSummary names before
+// cutting the list short
+const maxBlockedNames = 10
+
+// BlockedSummary describes the checks a failure blocks, e.g.
+// "3 dependent checks: a, b, c". Long lists are cut short.
+func BlockedSummary(blocked []string) string {
+	names := strings.Join(blocked, ", ")
+	if len(blocked) > maxBlockedNames {
+		names = fmt.Sprintf("%s and %d more", strings.Join(blocked[:maxBlockedNames], ", "), len(blocked)-maxBlockedNames)
+	}
+	return fmt.Sprintf("%d dependent checks: %s", len(blocked), names)
+}
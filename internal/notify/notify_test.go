@@ -0,0 +1,28 @@
+package notify
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestCheckTitle(t *testing.T) {
+	if got := CheckTitle("Plex web UI", "http"); got != "Plex web UI" {
+		t.Errorf("CheckTitle with a name = %q, want the name", got)
+	}
+	if got := CheckTitle("", "http"); got != "HTTP" {
+		t.Errorf("CheckTitle without a name = %q, want HTTP", got)
+	}
+}
+
+func TestBlockedSummary(t *testing.T) {
+	if got, want := BlockedSummary([]string{"nas PING", "plex HTTP"}), "2 dependent checks: nas PING, plex HTTP"; got != want {
+		t.Errorf("BlockedSummary = %q, want %q", got, want)
+	}
+	var many []string
+	for i := range 12 {
+		many = append(many, fmt.Sprintf("c%d", i))
+	}
+	if got, want := BlockedSummary(many), "12 dependent checks: c0, c1, c2, c3, c4, c5, c6, c7, c8, c9 and 2 more"; got != want {
+		t.Errorf("BlockedSummary of 12 = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,454 @@
+// Package oidc signs users in with an OpenID Connect provider using the
+// authorization code flow with PKCE, and verifies the ID tokens it returns
+package oidc
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// metadataTTL is how long discovery results and signing keys are kept
+	// before being fetched again
+	metadataTTL = time.Hour
+
+	// keyRefetchGap stops a token with an unknown key ID making every
+	// request fetch the keys again
+	keyRefetchGap = time.Minute
+
+	// clockSkew is how far the provider's clock may be from ours
+	clockSkew = time.Minute
+)
+
+// Provider is an OpenID Connect provider, found from its issuer URL
+type Provider struct {
+	issuer string
+	http   *http.Client
+
+	mu        sync.Mutex
+	meta      *metadata
+	metaAt    time.Time
+	keys      map[string]crypto.PublicKey // By key ID
+	keysAt    time.Time
+	keysTried time.Time
+}
+
+// metadata is the part of the discovery document this package uses
+type metadata struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	JWKSURI               string `json:"jwks_uri"`
+	EndSessionEndpoint    string `json:"end_session_endpoint"`
+}
+
+// NewProvider creates a provider for issuer. Nothing is fetched until it is
+// first used.
+func NewProvider(issuer string) *Provider {
+	return &Provider{
+		issuer: strings.TrimSuffix(issuer, "/"),
+		http:   &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+// Issuer returns the issuer URL the provider was created with
+func (p *Provider) Issuer() string {
+	return p.issuer
+}
+
+// AuthCodeURL returns where to send the browser to sign in. state and nonce
+// come back in the callback and the ID token; challenge is
+// Challenge(verifier).
+func (p *Provider) AuthCodeURL(ctx context.Context, clientID, redirectURL string, scopes []string, state, nonce, challenge string) (string, error) {
+	meta, err := p.discover(ctx)
+	if err != nil {
+		return "", err
+	}
+	u, err := url.Parse(meta.AuthorizationEndpoint)
+	if err != nil {
+		return "", fmt.Errorf("authorization endpoint: %w", err)
+	}
+	q := u.Query()
+	q.Set("response_type", "code")
+	q.Set("client_id", clientID)
+	q.Set("redirect_uri", redirectURL)
+	q.Set("scope", strings.Join(scopes, " "))
+	q.Set("state", state)
+	q.Set("nonce", nonce)
+	q.Set("code_challenge", challenge)
+	q.Set("code_challenge_method", "S256")
+	u.RawQuery = q.Encode()
+	return u.String(), nil
+}
+
+// EndSessionURL returns the provider's logout URL, or "" if it has none
+func (p *Provider) EndSessionURL(ctx context.Context) string {
+	meta, err := p.discover(ctx)
+	if err != nil {
+		return ""
+	}
+	return meta.EndSessionEndpoint
+}
+
+// Exchange trades an authorization code for the raw ID token
+func (p *Provider) Exchange(ctx context.Context, clientID, clientSecret, redirectURL, code, verifier string) (string, error) {
+	meta, err := p.discover(ctx)
+	if err != nil {
+		return "", err
+	}
+	form := url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {code},
+		"redirect_uri":  {redirectURL},
+		"client_id":     {clientID},
+		"code_verifier": {verifier},
+	}
+	if clientSecret != "" {
+		form.Set("client_secret", clientSecret)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, meta.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	resp, err := p.http.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("token request: %w", err)
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	var tok struct {
+		IDToken          string `json:"id_token"`
+		Error            string `json:"error"`
+		ErrorDescription string `json:"error_description"`
+	}
+	_ = json.Unmarshal(body, &tok)
+	if resp.StatusCode != http.StatusOK {
+		if tok.Error != "" {
+			return "", fmt.Errorf("token request: %s %s", tok.Error, tok.ErrorDescription)
+		}
+		return "", fmt.Errorf("token request: %s", resp.Status)
+	}
+	if tok.IDToken == "" {
+		return "", errors.New("token response has no id_token")
+	}
+	return tok.IDToken, nil
+}
+
+// Claims are the verified claims of an ID token
+type Claims map[string]any
+
+// String returns the string claim name, or ""
+func (c Claims) String(name string) string {
+	s, _ := c[name].(string)
+	return s
+}
+
+// Strings returns claim name as a list, whether the provider sent a list
+// or a single string
+func (c Claims) Strings(name string) []string {
+	switch v := c[name].(type) {
+	case string:
+		return []string{v}
+	case []any:
+		out := make([]string, 0, len(v))
+		for _, item := range v {
+			if s, ok := item.(string); ok {
+				out = append(out, s)
+			}
+		}
+		return out
+	}
+	return nil
+}
+
+// Username picks the most readable name the token carries for its user
+func (c Claims) Username() string {
+	for _, name := range []string{"preferred_username", "email", "name", "sub"} {
+		if s := c.String(name); s != "" {
+			return s
+		}
+	}
+	return ""
+}
+
+// Verify checks rawIDToken's signature against the provider's keys, that it
+// was issued by the provider for clientID and hasn't expired, and that it
+// carries nonce. It returns the token's claims.
+func (p *Provider) Verify(ctx context.Context, rawIDToken, clientID, nonce string) (Claims, error) {
+	parts := strings.Split(rawIDToken, ".")
+	if len(parts) != 3 {
+		return nil, errors.New("id token is not a JWT")
+	}
+	var header struct {
+		Alg string `json:"alg"`
+		Kid string `json:"kid"`
+	}
+	if err := decodeSegment(parts[0], &header); err != nil {
+		return nil, fmt.Errorf("id token header: %w", err)
+	}
+	sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, fmt.Errorf("id token signature: %w", err)
+	}
+	key, err := p.key(ctx, header.Kid)
+	if err != nil {
+		return nil, err
+	}
+	if err := verifySignature(header.Alg, key, []byte(parts[0]+"."+parts[1]), sig); err != nil {
+		return nil, err
+	}
+
+	var claims Claims
+	if err := decodeSegment(parts[1], &claims); err != nil {
+		return nil, fmt.Errorf("id token claims: %w", err)
+	}
+	if iss := claims.String("iss"); strings.TrimSuffix(iss, "/") != p.issuer {
+		return nil, fmt.Errorf("id token issued by %q, not %q", iss, p.issuer)
+	}
+	aud := claims.Strings("aud")
+	if !slices.Contains(aud, clientID) {
+		return nil, errors.New("id token is for another client")
+	}
+	if azp := claims.String("azp"); len(aud) > 1 && azp != "" && azp != clientID {
+		return nil, errors.New("id token is for another client")
+	}
+	now := time.Now()
+	exp, ok := claims["exp"].(float64)
+	if !ok || now.After(time.Unix(int64(exp), 0).Add(clockSkew)) {
+		return nil, errors.New("id token has expired")
+	}
+	if nbf, ok := claims["nbf"].(float64); ok && now.Add(clockSkew).Before(time.Unix(int64(nbf), 0)) {
+		return nil, errors.New("id token is not valid yet")
+	}
+	if claims.String("nonce") != nonce {
+		return nil, errors.New("id token nonce does not match")
+	}
+	return claims, nil
+}
+
+// discover fetches and caches the provider's discovery document
+func (p *Provider) discover(ctx context.Context) (*metadata, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.meta != nil && time.Since(p.metaAt) < metadataTTL {
+		return p.meta, nil
+	}
+	var meta metadata
+	if err := p.getJSON(ctx, p.issuer+"/.well-known/openid-configuration", &meta); err != nil {
+		return nil, fmt.Errorf("discovery: %w", err)
+	}
+	if strings.TrimSuffix(meta.Issuer, "/") != p.issuer {
+		return nil, fmt.Errorf("discovery: provider says its issuer is %q, not %q", meta.Issuer, p.issuer)
+	}
+	if meta.AuthorizationEndpoint == "" || meta.TokenEndpoint == "" || meta.JWKSURI == "" {
+		return nil, errors.New("discovery: document is missing endpoints")
+	}
+	p.meta, p.metaAt = &meta, time.Now()
+	return p.meta, nil
+}
+
+// key returns the signing key kid, fetching the provider's keys again if
+// they are stale or don't include it (the provider may have rotated them)
+func (p *Provider) key(ctx context.Context, kid string) (crypto.PublicKey, error) {
+	meta, err := p.discover(ctx)
+	if err != nil {
+		return nil, err
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	find := func() crypto.PublicKey {
+		if k, ok := p.keys[kid]; ok {
+			return k
+		}
+		// Tokens from a provider with one key may leave the key ID out
+		if kid == "" && len(p.keys) == 1 {
+			for _, k := range p.keys {
+				return k
+			}
+		}
+		return nil
+	}
+	if k := find(); k != nil && time.Since(p.keysAt) < metadataTTL {
+		return k, nil
+	}
+	if time.Since(p.keysTried) >= keyRefetchGap || p.keys == nil {
+		p.keysTried = time.Now()
+		var set jwks
+		if err := p.getJSON(ctx, meta.JWKSURI, &set); err != nil {
+			return nil, fmt.Errorf("signing keys: %w", err)
+		}
+		p.keys, p.keysAt = set.publicKeys(), time.Now()
+	}
+	if k := find(); k != nil {
+		return k, nil
+	}
+	return nil, fmt.Errorf("id token signed with unknown key %q", kid)
+}
+
+func (p *Provider) getJSON(ctx context.Context, u string, v any) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+	resp, err := p.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s: %s", u, resp.Status)
+	}
+	return json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(v)
+}
+
+// jwks is a JSON Web Key Set
+type jwks struct {
+	Keys []struct {
+		Kty string `json:"kty"`
+		Kid string `json:"kid"`
+		Use string `json:"use"`
+		N   string `json:"n"`
+		E   string `json:"e"`
+		Crv string `json:"crv"`
+		X   string `json:"x"`
+		Y   string `json:"y"`
+	} `json:"keys"`
+}
+
+// publicKeys returns the set's RSA and EC signing keys by key ID. Keys of
+// other types, for encryption or that don't parse are skipped.
+func (s jwks) publicKeys() map[string]crypto.PublicKey {
+	keys := make(map[string]crypto.PublicKey)
+	for _, k := range s.Keys {
+		if k.Use != "" && k.Use != "sig" {
+			continue
+		}
+		switch k.Kty {
+		case "RSA":
+			n, errN := base64.RawURLEncoding.DecodeString(k.N)
+			e, errE := base64.RawURLEncoding.DecodeString(k.E)
+			if errN != nil || errE != nil || len(e) == 0 || len(e) > 4 {
+				continue
+			}
+			keys[k.Kid] = &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}
+		case "EC":
+			var curve elliptic.Curve
+			switch k.Crv {
+			case "P-256":
+				curve = elliptic.P256()
+			case "P-384":
+				curve = elliptic.P384()
+			case "P-521":
+				curve = elliptic.P521()
+			default:
+				continue
+			}
+			x, errX := base64.RawURLEncoding.DecodeString(k.X)
+			y, errY := base64.RawURLEncoding.DecodeString(k.Y)
+			if errX != nil || errY != nil {
+				continue
+			}
+			pub := &ecdsa.PublicKey{Curve: curve, X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}
+			if !curve.IsOnCurve(pub.X, pub.Y) {
+				continue
+			}
+			keys[k.Kid] = pub
+		}
+	}
+	return keys
+}
+
+// verifySignature checks a JWS signature. Only asymmetric algorithms are
+// accepted, so a token can't be signed with something public such as the
+// client ID.
+func verifySignature(alg string, key crypto.PublicKey, signed, sig []byte) error {
+	var h crypto.Hash
+	switch alg[min(2, len(alg)):] {
+	case "256":
+		h = crypto.SHA256
+	case "384":
+		h = crypto.SHA384
+	case "512":
+		h = crypto.SHA512
+	default:
+		return fmt.Errorf("id token algorithm %q is not supported", alg)
+	}
+	hasher := h.New()
+	hasher.Write(signed)
+	digest := hasher.Sum(nil)
+	switch {
+	case strings.HasPrefix(alg, "RS"), strings.HasPrefix(alg, "PS"):
+		pub, ok := key.(*rsa.PublicKey)
+		if !ok {
+			return errors.New("id token key is not an RSA key")
+		}
+		var err error
+		if alg[0] == 'R' {
+			err = rsa.VerifyPKCS1v15(pub, h, digest, sig)
+		} else {
+			err = rsa.VerifyPSS(pub, h, digest, sig, nil)
+		}
+		if err != nil {
+			return errors.New("id token signature is invalid")
+		}
+		return nil
+	case strings.HasPrefix(alg, "ES"):
+		pub, ok := key.(*ecdsa.PublicKey)
+		if !ok {
+			return errors.New("id token key is not an EC key")
+		}
+		// The signature is r and s, each padded to the curve's size
+		if size := (pub.Curve.Params().BitSize + 7) / 8; len(sig) != 2*size {
+			return errors.New("id token signature is invalid")
+		}
+		r := new(big.Int).SetBytes(sig[:len(sig)/2])
+		s := new(big.Int).SetBytes(sig[len(sig)/2:])
+		if !ecdsa.Verify(pub, digest, r, s) {
+			return errors.New("id token signature is invalid")
+		}
+		return nil
+	}
+	return fmt.Errorf("id token algorithm %q is not supported", alg)
+}
+
+func decodeSegment(seg string, v any) error {
+	b, err := base64.RawURLEncoding.DecodeString(seg)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(b, v)
+}
+
+// RandomString returns a random URL-safe string, for state, nonce and PKCE
+// verifier values
+func RandomString() string {
+	b := make([]byte, 32)
+	_, _ = rand.Read(b)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// Challenge returns the S256 PKCE challenge for verifier
+func Challenge(verifier string) string {
+	sum := sha256.Sum256([]byte(verifier))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
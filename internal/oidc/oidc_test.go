@@ -0,0 +1,245 @@
+package oidc
+
+import (
+	"context"
+	"crypto"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+const clientID = "poke443"
+
+// fakeIssuer is a provider serving discovery and a key set that a test can
+// rotate
+type fakeIssuer struct {
+	*httptest.Server
+
+	mu      sync.Mutex
+	keys    map[string]*rsa.PrivateKey
+	fetches int // Of the key set
+}
+
+func newFakeIssuer(t *testing.T, kids ...string) *fakeIssuer {
+	t.Helper()
+	f := &fakeIssuer{keys: make(map[string]*rsa.PrivateKey)}
+	for _, kid := range kids {
+		f.keys[kid] = newKey(t)
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(metadata{
+			Issuer:                f.URL,
+			AuthorizationEndpoint: f.URL + "/authorize",
+			TokenEndpoint:         f.URL + "/token",
+			JWKSURI:               f.URL + "/jwks",
+		})
+	})
+	mux.HandleFunc("/jwks", func(w http.ResponseWriter, r *http.Request) {
+		f.mu.Lock()
+		defer f.mu.Unlock()
+		f.fetches++
+		var set []map[string]string
+		for kid, k := range f.keys {
+			set = append(set, map[string]string{
+				"kty": "RSA", "use": "sig", "kid": kid,
+				"n": base64.RawURLEncoding.EncodeToString(k.N.Bytes()),
+				"e": base64.RawURLEncoding.EncodeToString(big.NewInt(int64(k.E)).Bytes()),
+			})
+		}
+		_ = json.NewEncoder(w).Encode(map[string]any{"keys": set})
+	})
+	f.Server = httptest.NewServer(mux)
+	t.Cleanup(f.Close)
+	return f
+}
+
+func newKey(t *testing.T) *rsa.PrivateKey {
+	t.Helper()
+	k, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return k
+}
+
+// rotate replaces the key set with a single new key, kid
+func (f *fakeIssuer) rotate(t *testing.T, kid string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.keys = map[string]*rsa.PrivateKey{kid: newKey(t)}
+}
+
+func (f *fakeIssuer) key(kid string) *rsa.PrivateKey {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.keys[kid]
+}
+
+func (f *fakeIssuer) keyFetches() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.fetches
+}
+
+// claims returns valid claims for a token from f for nonce
+func (f *fakeIssuer) claims(nonce string) map[string]any {
+	now := time.Now()
+	return map[string]any{
+		"iss":   f.URL,
+		"aud":   clientID,
+		"sub":   "alice",
+		"nonce": nonce,
+		"iat":   now.Unix(),
+		"exp":   now.Add(time.Hour).Unix(),
+	}
+}
+
+// sign returns a JWT of header and claims, signed with key as the header's
+// alg says
+func sign(t *testing.T, key *rsa.PrivateKey, header, claims map[string]any) string {
+	t.Helper()
+	h, _ := json.Marshal(header)
+	c, _ := json.Marshal(claims)
+	signed := base64.RawURLEncoding.EncodeToString(h) + "." + base64.RawURLEncoding.EncodeToString(c)
+	var sig []byte
+	switch header["alg"] {
+	case "RS256":
+		digest := sha256.Sum256([]byte(signed))
+		var err error
+		if sig, err = rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, digest[:]); err != nil {
+			t.Fatal(err)
+		}
+	case "HS256":
+		// The classic confusion: the public key used as an HMAC secret
+		pub, _ := x509.MarshalPKIXPublicKey(&key.PublicKey)
+		mac := hmac.New(sha256.New, pub)
+		mac.Write([]byte(signed))
+		sig = mac.Sum(nil)
+	}
+	return signed + "." + base64.RawURLEncoding.EncodeToString(sig)
+}
+
+func TestVerify(t *testing.T) {
+	f := newFakeIssuer(t, "k1")
+	rs256 := map[string]any{"alg": "RS256", "kid": "k1"}
+	with := func(kv ...any) map[string]any {
+		c := f.claims("n0nce")
+		for i := 0; i < len(kv); i += 2 {
+			if kv[i+1] == nil {
+				delete(c, kv[i].(string))
+			} else {
+				c[kv[i].(string)] = kv[i+1]
+			}
+		}
+		return c
+	}
+	hour := time.Hour
+	tests := []struct {
+		name    string
+		header  map[string]any
+		claims  map[string]any
+		key     *rsa.PrivateKey // The provider's k1 if nil
+		wantErr string
+	}{
+		{"valid", rs256, with(), nil, ""},
+		{"valid with several audiences", rs256, with("aud", []string{"other", clientID}), nil, ""},
+		{"valid within clock skew", rs256, with("exp", time.Now().Add(-clockSkew/2).Unix()), nil, ""},
+		{"alg none", map[string]any{"alg": "none", "kid": "k1"}, with(), nil, "not supported"},
+		{"HS256 with the public key", map[string]any{"alg": "HS256", "kid": "k1"}, with(), nil, "not supported"},
+		{"signed by another key", rs256, with(), newKey(t), "signature is invalid"},
+		{"wrong issuer", rs256, with("iss", "https://evil.example.com"), nil, "issued by"},
+		{"wrong audience", rs256, with("aud", "someone-else"), nil, "another client"},
+		{"no audience", rs256, with("aud", nil), nil, "another client"},
+		{"wrong authorized party", rs256, with("aud", []string{"other", clientID}, "azp", "other"), nil, "another client"},
+		{"wrong nonce", rs256, with("nonce", "replayed"), nil, "nonce"},
+		{"no nonce", rs256, with("nonce", nil), nil, "nonce"},
+		{"expired", rs256, with("exp", time.Now().Add(-hour).Unix()), nil, "expired"},
+		{"no expiry", rs256, with("exp", nil), nil, "expired"},
+		{"not valid yet", rs256, with("nbf", time.Now().Add(hour).Unix()), nil, "not valid yet"},
+		{"unknown kid", map[string]any{"alg": "RS256", "kid": "k9"}, with(), newKey(t), "unknown key"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			key := tt.key
+			if key == nil {
+				key = f.key("k1")
+			}
+			claims, err := NewProvider(f.URL).Verify(context.Background(), sign(t, key, tt.header, tt.claims), clientID, "n0nce")
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("Verify: %v", err)
+				}
+				if claims.Username() != "alice" {
+					t.Errorf("Username() = %q, want alice", claims.Username())
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Verify error = %v, want one mentioning %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestVerifyRejectsChangedClaims(t *testing.T) {
+	f := newFakeIssuer(t, "k1")
+	raw := sign(t, f.key("k1"), map[string]any{"alg": "RS256", "kid": "k1"}, f.claims("n0nce"))
+	parts := strings.Split(raw, ".")
+	c := f.claims("n0nce")
+	c["sub"] = "admin"
+	b, _ := json.Marshal(c)
+	parts[1] = base64.RawURLEncoding.EncodeToString(b)
+
+	_, err := NewProvider(f.URL).Verify(context.Background(), strings.Join(parts, "."), clientID, "n0nce")
+	if err == nil || !strings.Contains(err.Error(), "signature is invalid") {
+		t.Errorf("Verify of a token with changed claims = %v, want an invalid signature", err)
+	}
+}
+
+func TestVerifyRefetchesRotatedKeys(t *testing.T) {
+	f := newFakeIssuer(t, "k1")
+	p := NewProvider(f.URL)
+	ctx := context.Background()
+	old := sign(t, f.key("k1"), map[string]any{"alg": "RS256", "kid": "k1"}, f.claims("n"))
+
+	if _, err := p.Verify(ctx, old, clientID, "n"); err != nil {
+		t.Fatalf("Verify before rotation: %v", err)
+	}
+	f.rotate(t, "k2")
+	next := sign(t, f.key("k2"), map[string]any{"alg": "RS256", "kid": "k2"}, f.claims("n"))
+
+	// Straight after a fetch, an unknown key doesn't fetch again, so bad
+	// tokens can't make every request hit the provider
+	if _, err := p.Verify(ctx, next, clientID, "n"); err == nil {
+		t.Fatal("Verify with a new key inside keyRefetchGap succeeded")
+	}
+	if n := f.keyFetches(); n != 1 {
+		t.Fatalf("key set fetched %d times inside keyRefetchGap, want 1", n)
+	}
+
+	p.mu.Lock()
+	p.keysTried = time.Now().Add(-keyRefetchGap)
+	p.mu.Unlock()
+	if _, err := p.Verify(ctx, next, clientID, "n"); err != nil {
+		t.Fatalf("Verify after rotation: %v", err)
+	}
+	if n := f.keyFetches(); n != 2 {
+		t.Errorf("key set fetched %d times, want 2", n)
+	}
+	// The known key is served from the cache from now on
+	if _, err := p.Verify(ctx, next, clientID, "n"); err != nil || f.keyFetches() != 2 {
+		t.Errorf("Verify again = %v after %d fetches; want success from the cache", err, f.keyFetches())
+	}
+}
@@ -10,6 +10,7 @@ import (
 	"time"
 
 	"github.com/andrewsjg/simple-healthchecker/copilot/internal/config"
+	"github.com/andrewsjg/simple-healthchecker/copilot/internal/notify"
 )
 
 // Priority levels for Pushover notifications
@@ -23,13 +24,22 @@ const (
 
 // AlertMessage represents a notification to be sent
 type AlertMessage struct {
-	Host      string
-	Address   string
-	CheckType string
-	CheckID   string
-	Status    string // "up", "down"
-	Message   string
-	LatencyMS int64
+	Host       string
+	Address    string
+	CheckType  string
+	CheckName  string // Display name, if the check has one
+	CheckID    string
+	Status     string // "up", "down"
+	Message    string
+	Latency    time.Duration
+	Notes      string                // What the check covers
+	Runbook    string                // URL of the runbook, if any
+	Severity   string                // "info", "warning" or "critical"; empty means warning
+	Outage     *notify.OutageSummary // Set on recovery and reminders
+	Reminder   bool                  // Still-down reminder rather than a state change
+	Time       time.Time             // When the state changed; used to timestamp digest lines
+	Blocked    []string              // Dependent checks blocked by this failure
+	HostStatus string                // The host's rollup status after the change, e.g. "degraded"
 }
 
 // Client manages Pushover notifications
@@ -78,21 +88,57 @@ func (c *Client) SendAlert(msg AlertMessage) error {
 	priority := PriorityHigh
 	sound := "falling"
 
+	switch msg.Severity {
+	case "critical":
+		// Emergency priority repeats until acknowledged, so keep it for critical checks
+		priority = PriorityEmergency
+		sound = "siren"
+	case "info":
+		priority = PriorityLow
+	}
+
+	if msg.HostStatus == "degraded" {
+		title = fmt.Sprintf("🟠 %s is DEGRADED", msg.Host)
+	}
+
+	if msg.Reminder {
+		title = fmt.Sprintf("⏰ %s is still DOWN", msg.Host)
+	}
+
 	if msg.Status == "up" {
 		title = fmt.Sprintf("✅ %s is UP", msg.Host)
 		priority = PriorityNormal
 		sound = "pushover"
+		if msg.Severity == "info" {
+			priority = PriorityLow
+		}
 	}
 
-	body := fmt.Sprintf("Host: %s (%s)\nCheck: %s", msg.Host, msg.Address, strings.ToUpper(msg.CheckType))
+	body := fmt.Sprintf("Host: %s (%s)\nCheck: %s", msg.Host, msg.Address, notify.CheckTitle(msg.CheckName, msg.CheckType))
 	if msg.CheckID != "" {
 		body += fmt.Sprintf(" [%s]", msg.CheckID)
 	}
 	if msg.Message != "" {
 		body += fmt.Sprintf("\n%s", msg.Message)
 	}
-	if msg.Status == "up" && msg.LatencyMS > 0 {
-		body += fmt.Sprintf("\nLatency: %dms", msg.LatencyMS)
+	if msg.HostStatus != "" {
+		body += fmt.Sprintf("\nHost status: %s", msg.HostStatus)
+	}
+	if msg.Status == "up" && msg.Latency > 0 {
+		body += fmt.Sprintf("\nLatency: %v", msg.Latency)
+	}
+	if o := msg.Outage; o != nil {
+		body += fmt.Sprintf("\nDown for %s", o.Downtime.Round(time.Second))
+		if !o.Start.IsZero() {
+			body += fmt.Sprintf(" (since %s)", o.Start.Local().Format("Jan 2 15:04"))
+		}
+		body += fmt.Sprintf(", %d failed probes\nUptime: %.2f%%", o.FailedProbes, o.UptimePct)
+	}
+	if len(msg.Blocked) > 0 {
+		body += fmt.Sprintf("\nBlocked: %s", notify.BlockedSummary(msg.Blocked))
+	}
+	if msg.Notes != "" {
+		body += fmt.Sprintf("\n\n%s", msg.Notes)
 	}
 
 	// Override sound if configured
@@ -110,6 +156,12 @@ func (c *Client) SendAlert(msg AlertMessage) error {
 		"sound":    {sound},
 	}
 
+	// Attach the runbook as Pushover's supplementary URL
+	if msg.Runbook != "" {
+		data.Set("url", msg.Runbook)
+		data.Set("url_title", "Runbook")
+	}
+
 	// Add device if specified
 	if settings.Device != "" {
 		data.Set("device", settings.Device)
@@ -136,6 +188,101 @@ func (c *Client) SendAlert(msg AlertMessage) error {
 	return nil
 }
 
+// SendDigest sends several alerts as a single low-priority notification,
+// one line per alert
+func (c *Client) SendDigest(title string, msgs []AlertMessage) error {
+	c.mu.RLock()
+	settings := c.settings
+	c.mu.RUnlock()
+
+	if !settings.Enabled || settings.UserKey == "" || settings.APIToken == "" || len(msgs) == 0 {
+		return nil
+	}
+
+	lines := make([]string, 0, len(msgs))
+	for _, msg := range msgs {
+		lines = append(lines, digestLine(msg))
+	}
+
+	data := url.Values{
+		"token":    {settings.APIToken},
+		"user":     {settings.UserKey},
+		"title":    {title},
+		"message":  {strings.Join(lines, "\n")},
+		"priority": {fmt.Sprintf("%d", PriorityLow)},
+	}
+	if settings.Device != "" {
+		data.Set("device", settings.Device)
+	}
+
+	resp, err := c.http.PostForm("https://api.pushover.net/1/messages.json", data)
+	if err != nil {
+		return fmt.Errorf("pushover request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("pushover returned status %d", resp.StatusCode)
+	}
+
+	log.Printf("Pushover digest sent: %d alerts", len(msgs))
+	return nil
+}
+
+// SendNotice sends a plain notification that isn't about a single check
+func (c *Client) SendNotice(title, message string) error {
+	c.mu.RLock()
+	settings := c.settings
+	c.mu.RUnlock()
+
+	if !settings.Enabled || settings.UserKey == "" || settings.APIToken == "" {
+		return nil
+	}
+
+	data := url.Values{
+		"token":    {settings.APIToken},
+		"user":     {settings.UserKey},
+		"title":    {title},
+		"message":  {message},
+		"priority": {fmt.Sprintf("%d", PriorityNormal)},
+	}
+	if settings.Device != "" {
+		data.Set("device", settings.Device)
+	}
+
+	resp, err := c.http.PostForm("https://api.pushover.net/1/messages.json", data)
+	if err != nil {
+		return fmt.Errorf("pushover request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("pushover returned status %d", resp.StatusCode)
+	}
+
+	log.Printf("Pushover notice sent: %s", title)
+	return nil
+}
+
+// digestLine summarises an alert as "15:04 🔴 host PING [id] - message"
+func digestLine(msg AlertMessage) string {
+	icon := "🔴"
+	if msg.Status == "up" {
+		icon = "✅"
+	}
+	line := fmt.Sprintf("%s %s %s %s", msg.Time.Local().Format("15:04"), icon, msg.Host, notify.CheckTitle(msg.CheckName, msg.CheckType))
+	if msg.CheckID != "" {
+		line += fmt.Sprintf(" [%s]", msg.CheckID)
+	}
+	if msg.Message != "" {
+		line += " - " + msg.Message
+	}
+	if n := len(msg.Blocked); n > 0 {
+		line += fmt.Sprintf(" (%d dependent checks blocked)", n)
+	}
+	return line
+}
+
 // TestNotification sends a test notification
 func (c *Client) TestNotification() error {
 	c.mu.RLock()
@@ -0,0 +1,231 @@
+// Package remote fetches hosts from outside the config file: an http(s)
+// URL (which covers public and presigned S3 objects) or a file in a git
+// repo, so the fleet can be managed GitOps-style.
+package remote
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/andrewsjg/simple-healthchecker/copilot/internal/config"
+)
+
+// maxSize caps how much of a remote config is read
+const maxSize = 10 << 20
+
+// Source fetches the hosts that settings.remote points at
+type Source struct {
+	settings config.RemoteSettings
+	http     *http.Client
+	cacheDir string // Where git repos are cloned
+}
+
+// NewSource creates a source for the given settings
+func NewSource(settings config.RemoteSettings) *Source {
+	cacheDir, err := os.UserCacheDir()
+	if err != nil {
+		cacheDir = os.TempDir()
+	}
+	return &Source{
+		settings: settings,
+		http: &http.Client{
+			Timeout: 30 * time.Second,
+		},
+		cacheDir: filepath.Join(cacheDir, "poke443", "remote"),
+	}
+}
+
+// gitLocation is a file in a git repo
+type gitLocation struct {
+	repo string // Anything git clone accepts
+	file string // Path within the repo
+	ref  string // Branch or tag; empty for the default branch
+}
+
+// parseGit splits a git+ URL such as
+// git+https://github.com/org/fleet.git//hosts.yaml?ref=main into the repo,
+// the file in it and the ref
+func parseGit(raw string) (gitLocation, error) {
+	rest, ok := strings.CutPrefix(raw, "git+")
+	if !ok {
+		return gitLocation{}, fmt.Errorf("not a git URL")
+	}
+	u, err := url.Parse(rest)
+	if err != nil {
+		return gitLocation{}, err
+	}
+	repo, file, ok := strings.Cut(u.Path, "//")
+	if !ok || strings.Trim(file, "/") == "" {
+		return gitLocation{}, fmt.Errorf("git URL needs the file after the repo, e.g. git+https://host/repo.git//hosts.yaml")
+	}
+	loc := gitLocation{file: path.Clean(strings.Trim(file, "/")), ref: u.Query().Get("ref")}
+	if loc.file == ".." || strings.HasPrefix(loc.file, "../") {
+		return gitLocation{}, fmt.Errorf("git URL file must be inside the repo")
+	}
+	// Either would be taken as an option by git
+	if strings.HasPrefix(loc.ref, "-") {
+		return gitLocation{}, fmt.Errorf("git URL ref can't start with -")
+	}
+	u.Path, u.RawPath, u.RawQuery = repo, "", ""
+	loc.repo = u.String()
+	if strings.HasPrefix(loc.repo, "-") {
+		return gitLocation{}, fmt.Errorf("git URL repo can't start with -")
+	}
+	return loc, nil
+}
+
+// Validate reports a problem with the settings that would stop every fetch
+func Validate(settings config.RemoteSettings) error {
+	if strings.HasPrefix(settings.URL, "git+") {
+		_, err := parseGit(settings.URL)
+		return err
+	}
+	u, err := url.Parse(settings.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("remote url must be http(s)://... or git+<repo>//<file>")
+	}
+	return nil
+}
+
+// Redact returns the URL without credentials or query string (which may
+// hold a presigned signature), for showing in the UI
+func Redact(raw string) string {
+	rest, isGit := strings.CutPrefix(raw, "git+")
+	u, err := url.Parse(rest)
+	if err != nil {
+		return ""
+	}
+	ref := u.Query().Get("ref")
+	u.User, u.RawQuery, u.Fragment = nil, "", ""
+	if !isGit {
+		return u.String()
+	}
+	if ref != "" {
+		u.RawQuery = url.Values{"ref": {ref}}.Encode()
+	}
+	return "git+" + u.String()
+}
+
+// Fetch downloads and parses the remote hosts
+func (s *Source) Fetch(ctx context.Context) ([]config.Host, error) {
+	if err := Validate(s.settings); err != nil {
+		return nil, err
+	}
+	var data []byte
+	var ext string
+	var err error
+	if strings.HasPrefix(s.settings.URL, "git+") {
+		data, ext, err = s.fetchGit(ctx)
+	} else {
+		data, ext, err = s.fetchHTTP(ctx)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return config.ParseRemoteHosts(data, ext, s.settings.URL)
+}
+
+// fetchHTTP downloads the URL, taking its format from the path's extension
+// or, failing that, the content type
+func (s *Source) fetchHTTP(ctx context.Context) ([]byte, string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, s.settings.URL, nil)
+	if err != nil {
+		return nil, "", err
+	}
+	if s.settings.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+s.settings.Token)
+	}
+	resp, err := s.http.Do(req)
+	if err != nil {
+		// The URL may hold a presigned signature, so leave it out
+		var uerr *url.Error
+		if errors.As(err, &uerr) {
+			err = uerr.Err
+		}
+		return nil, "", fmt.Errorf("fetch %s: %w", Redact(s.settings.URL), err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, "", fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxSize))
+	if err != nil {
+		return nil, "", err
+	}
+	ext := path.Ext(req.URL.Path)
+	if ext != ".yaml" && ext != ".yml" && ext != ".toml" {
+		ext = ".yaml"
+		if mt, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type")); strings.Contains(mt, "toml") {
+			ext = ".toml"
+		}
+	}
+	return data, ext, nil
+}
+
+// fetchGit brings a shallow clone of the repo up to date and reads the file
+// from it. Credentials come from git itself: SSH keys or a credential helper.
+func (s *Source) fetchGit(ctx context.Context) ([]byte, string, error) {
+	loc, err := parseGit(s.settings.URL)
+	if err != nil {
+		return nil, "", err
+	}
+	sum := sha256.Sum256([]byte(loc.repo + "#" + loc.ref))
+	dir := filepath.Join(s.cacheDir, hex.EncodeToString(sum[:8]))
+	ref := loc.ref
+	if ref == "" {
+		ref = "HEAD"
+	}
+	if _, err := os.Stat(filepath.Join(dir, ".git")); err != nil {
+		_ = os.RemoveAll(dir)
+		if err := os.MkdirAll(s.cacheDir, 0o755); err != nil {
+			return nil, "", err
+		}
+		args := []string{"clone", "--quiet", "--depth", "1"}
+		if loc.ref != "" {
+			args = append(args, "--branch", loc.ref)
+		}
+		if err := git(ctx, "", append(args, "--", loc.repo, dir)...); err != nil {
+			return nil, "", err
+		}
+	} else {
+		if err := git(ctx, dir, "fetch", "--quiet", "--depth", "1", "--", "origin", ref); err != nil {
+			return nil, "", err
+		}
+		if err := git(ctx, dir, "reset", "--quiet", "--hard", "FETCH_HEAD"); err != nil {
+			return nil, "", err
+		}
+	}
+	data, err := os.ReadFile(filepath.Join(dir, filepath.FromSlash(loc.file)))
+	if err != nil {
+		return nil, "", err
+	}
+	return data, path.Ext(loc.file), nil
+}
+
+// git runs a git command, returning its output as the error if it fails
+func git(ctx context.Context, dir string, args ...string) error {
+	cmd := exec.CommandContext(ctx, "git", args...)
+	cmd.Dir = dir
+	// Never stop to ask for a password
+	cmd.Env = append(os.Environ(), "GIT_TERMINAL_PROMPT=0")
+	if out, err := cmd.CombinedOutput(); err != nil {
+		if msg := strings.TrimSpace(string(out)); msg != "" {
+			return fmt.Errorf("git %s: %s", args[0], msg)
+		}
+		return fmt.Errorf("git %s: %w", args[0], err)
+	}
+	return nil
+}
@@ -0,0 +1,88 @@
+package remote
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/andrewsjg/simple-healthchecker/copilot/internal/config"
+)
+
+func TestParseGit(t *testing.T) {
+	tests := []struct {
+		raw     string
+		want    gitLocation
+		wantErr string
+	}{
+		{
+			raw:  "git+https://github.com/org/fleet.git//hosts.yaml?ref=main",
+			want: gitLocation{repo: "https://github.com/org/fleet.git", file: "hosts.yaml", ref: "main"},
+		},
+		{
+			raw:  "git+ssh://git@github.com/org/fleet.git//conf/hosts.toml",
+			want: gitLocation{repo: "ssh://git@github.com/org/fleet.git", file: "conf/hosts.toml"},
+		},
+		{raw: "https://github.com/org/fleet.git//hosts.yaml", wantErr: "not a git URL"},
+		{raw: "git+https://github.com/org/fleet.git", wantErr: "needs the file"},
+		{raw: "git+https://github.com/org/fleet.git//../../etc/passwd", wantErr: "inside the repo"},
+		{raw: "git+https://github.com/org/fleet.git//hosts.yaml?ref=--upload-pack=touch%20/tmp/pwned", wantErr: "ref can't start with -"},
+		{raw: "git+https://github.com/org/fleet.git//hosts.yaml?ref=-b", wantErr: "ref can't start with -"},
+		{raw: "git+--upload-pack=touch%20/tmp/pwned//hosts.yaml", wantErr: "repo can't start with -"},
+	}
+	for _, tt := range tests {
+		got, err := parseGit(tt.raw)
+		if tt.wantErr != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("parseGit(%q) error = %v, want one mentioning %q", tt.raw, err, tt.wantErr)
+			}
+			continue
+		}
+		if err != nil || got != tt.want {
+			t.Errorf("parseGit(%q) = %+v, %v; want %+v", tt.raw, got, err, tt.want)
+		}
+	}
+}
+
+func TestFetchGit(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+	repo := t.TempDir()
+	run := func(args ...string) {
+		t.Helper()
+		cmd := exec.Command("git", args...)
+		cmd.Dir = repo
+		cmd.Env = append(os.Environ(), "GIT_AUTHOR_NAME=test", "GIT_AUTHOR_EMAIL=test@example.com",
+			"GIT_COMMITTER_NAME=test", "GIT_COMMITTER_EMAIL=test@example.com")
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("git %s: %v\n%s", args[0], err, out)
+		}
+	}
+	commit := func(hosts string) {
+		t.Helper()
+		if err := os.WriteFile(filepath.Join(repo, "hosts.yaml"), []byte(hosts), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		run("add", "hosts.yaml")
+		run("commit", "--quiet", "-m", "hosts")
+	}
+	run("init", "--quiet", "--initial-branch", "main")
+	commit("hosts:\n  - name: router\n    address: 192.168.1.1\n")
+
+	s := NewSource(config.RemoteSettings{URL: "git+file://" + repo + "//hosts.yaml?ref=main"})
+	s.cacheDir = t.TempDir()
+	data, ext, err := s.fetchGit(context.Background())
+	if err != nil || ext != ".yaml" || !strings.Contains(string(data), "router") {
+		t.Fatalf("first fetch = %q, %q, %v; want the router", data, ext, err)
+	}
+
+	// A later fetch updates the clone
+	commit("hosts:\n  - name: nas\n    address: 192.168.1.2\n")
+	data, _, err = s.fetchGit(context.Background())
+	if err != nil || !strings.Contains(string(data), "nas") {
+		t.Fatalf("second fetch = %q, %v; want the nas", data, err)
+	}
+}
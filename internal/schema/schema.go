@@ -0,0 +1,47 @@
+// Package schema holds the JSON Schemas for the payloads POKE443 sends to
+// other programs (MQTT messages and the event stream) and resolves which
+// version to send. A version only ever gains optional fields; anything that
+// would break a consumer gets a new version, and consumers can pin the old
+// one with settings.payloads.compat.
+package schema
+
+import (
+	"embed"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Latest is the newest payload schema version
+const Latest = 1
+
+// Legacy is the unversioned format sent before schemas were published. Its
+// payloads carry no schema_version field.
+const Legacy = 0
+
+// Files holds the schemas as v<version>/<payload>.json
+//
+//go:embed v1/*.json
+var Files embed.FS
+
+// Resolve returns the payload version that compat asks for: "" for the
+// latest, "v1" (or "1") to pin a version, or "legacy" for the unversioned
+// format
+func Resolve(compat string) (int, error) {
+	compat = strings.ToLower(strings.TrimSpace(compat))
+	switch compat {
+	case "":
+		return Latest, nil
+	case "legacy":
+		return Legacy, nil
+	}
+	v, err := strconv.Atoi(strings.TrimPrefix(compat, "v"))
+	if err != nil || v < 1 || v > Latest {
+		modes := []string{"legacy"}
+		for n := 1; n <= Latest; n++ {
+			modes = append(modes, fmt.Sprintf("v%d", n))
+		}
+		return Latest, fmt.Errorf("unknown payload compat mode %q; use one of %s", compat, strings.Join(modes, ", "))
+	}
+	return v, nil
+}
@@ -0,0 +1,116 @@
+package server
+
+import (
+	"fmt"
+	"html/template"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/andrewsjg/simple-healthchecker/copilot/internal/state"
+)
+
+// annotatedEvent is an event on the analytics page with the notes on its
+// outage
+type annotatedEvent struct {
+	state.Event
+	Notes []state.Annotation
+}
+
+// annotateEvents pairs each event with the notes on its check that overlap
+// the outage it reports
+func annotateEvents(events []state.Event, hosts []state.HostAnalytics) []annotatedEvent {
+	notes := make(map[string][][]state.Annotation, len(hosts))
+	for _, h := range hosts {
+		for _, c := range h.Checks {
+			notes[h.Name] = append(notes[h.Name], c.Annotations)
+		}
+	}
+	out := make([]annotatedEvent, 0, len(events))
+	for _, e := range events {
+		ae := annotatedEvent{Event: e}
+		if checks := notes[e.HostName]; e.CheckType != "" && e.CheckIdx >= 0 && e.CheckIdx < len(checks) {
+			for _, a := range checks[e.CheckIdx] {
+				if !a.Start.After(e.Timestamp) && !a.End.Before(e.OutageStart()) {
+					ae.Notes = append(ae.Notes, a)
+				}
+			}
+		}
+		out = append(out, ae)
+	}
+	return out
+}
+
+// parseAnnotationTime reads a time posted by the analytics page: RFC 3339
+// from the events list, or a datetime-local value in the display timezone
+func (s *Server) parseAnnotationTime(v string) (time.Time, error) {
+	v = strings.TrimSpace(v)
+	if v == "" {
+		return time.Time{}, nil
+	}
+	if t, err := time.Parse(time.RFC3339, v); err == nil {
+		return t, nil
+	}
+	return time.ParseInLocation("2006-01-02T15:04", v, s.st.DisplayLocation())
+}
+
+// handleAnnotations adds a note to a span of a check's history. The page
+// reloads on success so the chart shows the new marker.
+func (s *Server) handleAnnotations(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.WriteHeader(405)
+		return
+	}
+	idx, _ := strconv.Atoi(r.FormValue("idx"))
+	start, err := s.parseAnnotationTime(r.FormValue("start"))
+	end, err2 := s.parseAnnotationTime(r.FormValue("end"))
+	if err != nil || err2 != nil {
+		err = fmt.Errorf("invalid time")
+	} else {
+		err = s.st.Annotate(r.FormValue("host"), idx, start, end, r.FormValue("note"))
+	}
+	if err != nil {
+		w.WriteHeader(422)
+		_, _ = w.Write([]byte(fmt.Sprintf(`<div class="alert alert-error">%s</div>`, template.HTMLEscapeString(err.Error()))))
+		return
+	}
+	w.Header().Set("HX-Refresh", "true")
+}
+
+// handleDeleteAnnotation removes a note from a check's history
+func (s *Server) handleDeleteAnnotation(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.WriteHeader(405)
+		return
+	}
+	idx, _ := strconv.Atoi(r.FormValue("idx"))
+	id, _ := strconv.Atoi(r.FormValue("id"))
+	if err := s.st.RemoveAnnotation(r.FormValue("host"), idx, id); err != nil {
+		w.WriteHeader(409)
+		_, _ = w.Write([]byte(err.Error()))
+		return
+	}
+	w.Header().Set("HX-Refresh", "true")
+}
+
+// writeAnnotationMarkers shades each annotated span of a smokeping chart,
+// with the note as its tooltip. The chart is laid out by data point rather
+// than by time, so a span covers the points that fall inside it.
+func writeAnnotationMarkers(b *svgBuilder, history []state.CheckDataPoint, notes []state.Annotation, paddingX, paddingY, chartWidth, chartHeight int) {
+	n := len(history)
+	for _, a := range notes {
+		if a.End.Before(history[0].Timestamp) || a.Start.After(history[n-1].Timestamp) {
+			continue
+		}
+		first := sort.Search(n, func(i int) bool { return !history[i].Timestamp.Before(a.Start) })
+		last := sort.Search(n, func(i int) bool { return history[i].Timestamp.After(a.End) })
+		x1 := float64(paddingX) + float64(chartWidth)*float64(first)/float64(n)
+		x2 := float64(paddingX) + float64(chartWidth)*float64(last)/float64(n)
+		// A moment between two data points still gets a visible marker
+		x2 = max(x2, x1+2)
+		fmt.Fprintf(b, `<g><title>%s</title><rect x="%.1f" y="%d" width="%.1f" height="%d" fill="rgba(245, 158, 11, 0.15)" stroke="rgba(245, 158, 11, 0.6)" stroke-width="0.5"/><path d="M%.1f,%d l3,-5 h-6 z" fill="#f59e0b"/></g>`,
+			template.HTMLEscapeString(a.Note), x1, paddingY, x2-x1, chartHeight, x1, paddingY)
+	}
+}
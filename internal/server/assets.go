@@ -0,0 +1,100 @@
+package server
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"embed"
+	"encoding/hex"
+	"io/fs"
+	"log"
+	"net/http"
+	"path"
+	"strings"
+	"time"
+)
+
+// htmx and its WebSocket extension are vendored into static/ so the UI works
+// without internet access. Until they have been fetched, pages load them from
+// the CDN instead.
+//go:generate sh -c "curl -fsSL https://unpkg.com/htmx.org@1.9.12/dist/htmx.min.js -o static/htmx.min.js"
+//go:generate sh -c "curl -fsSL https://unpkg.com/htmx.org@1.9.12/dist/ext/ws.js -o static/ws.js"
+
+//go:embed static
+var staticFS embed.FS
+
+// cdnFallbacks are used for vendored files missing from static/
+var cdnFallbacks = map[string]string{
+	"htmx.min.js": "https://unpkg.com/htmx.org@1.9.12",
+	"ws.js":       "https://unpkg.com/htmx.org@1.9.12/dist/ext/ws.js",
+}
+
+// assets serves the embedded stylesheets and scripts under /static/. Pages
+// link to fingerprinted names such as dashboard.1a2b3c4d5e.css, which change
+// with the content, so browsers can cache them for good.
+type assets struct {
+	files map[string]asset  // By fingerprinted name
+	paths map[string]string // URL path by plain name
+}
+
+type asset struct {
+	data []byte
+	etag string
+}
+
+func newAssets() *assets {
+	a := &assets{files: map[string]asset{}, paths: map[string]string{}}
+	sub, _ := fs.Sub(staticFS, "static")
+	entries, _ := fs.ReadDir(sub, ".")
+	for _, e := range entries {
+		if e.IsDir() {
+			continue
+		}
+		data, err := fs.ReadFile(sub, e.Name())
+		if err != nil {
+			continue
+		}
+		sum := sha256.Sum256(data)
+		hash := hex.EncodeToString(sum[:5])
+		ext := path.Ext(e.Name())
+		name := strings.TrimSuffix(e.Name(), ext) + "." + hash + ext
+		a.files[name] = asset{data: data, etag: `"` + hash + `"`}
+		a.paths[e.Name()] = "/static/" + name
+	}
+	for name, url := range cdnFallbacks {
+		if _, ok := a.paths[name]; !ok {
+			log.Printf("warning: static/%s isn't vendored, so pages load it from %s and need internet access; run go generate ./internal/server and rebuild", name, url)
+		}
+	}
+	return a
+}
+
+// path returns the URL to link to for the static file name
+func (a *assets) path(name string) string {
+	if p, ok := a.paths[name]; ok {
+		return p
+	}
+	if url, ok := cdnFallbacks[name]; ok {
+		return url
+	}
+	return "/static/" + name
+}
+
+func (a *assets) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	name := strings.TrimPrefix(r.URL.Path, "/static/")
+	f, ok := a.files[name]
+	if !ok {
+		// Plain names still work, for anything linking to them directly,
+		// but have to be revalidated
+		p, plain := a.paths[name]
+		if !plain {
+			http.NotFound(w, r)
+			return
+		}
+		f = a.files[strings.TrimPrefix(p, "/static/")]
+		w.Header().Set("Cache-Control", "no-cache")
+	} else {
+		w.Header().Set("Cache-Control", "public, max-age=31536000, immutable")
+	}
+	w.Header().Set("ETag", f.etag)
+	http.ServeContent(w, r, name, time.Time{}, bytes.NewReader(f.data))
+}
@@ -0,0 +1,433 @@
+package server
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/andrewsjg/simple-healthchecker/copilot/internal/config"
+	"github.com/andrewsjg/simple-healthchecker/copilot/internal/oidc"
+)
+
+const (
+	sessionCookie = "poke443_session"
+	loginCookie   = "poke443_login"
+
+	// loginTimeout is how long a user has to finish signing in at the
+	// provider
+	loginTimeout = 10 * time.Minute
+
+	// LocalTokenEnv holds the token that lets the menu bar app pause and
+	// resume monitoring while sign-in is on. If it isn't set, the server
+	// makes one up and sets it, so a process forked afterwards shares it.
+	LocalTokenEnv = "POKE443_LOCAL_TOKEN"
+)
+
+// authenticator keeps the OIDC provider and the key that signs session
+// cookies. The key is made fresh at start-up, so a restart signs everyone
+// out; the next page load sends them back through the provider, which
+// usually signs them straight in again.
+type authenticator struct {
+	key   []byte
+	local string // The menu bar's token; see LocalTokenEnv
+
+	mu       sync.Mutex
+	provider *oidc.Provider
+}
+
+func newAuthenticator() *authenticator {
+	key := make([]byte, 32)
+	_, _ = rand.Read(key)
+	local := os.Getenv(LocalTokenEnv)
+	if local == "" {
+		local = oidc.RandomString()
+		_ = os.Setenv(LocalTokenEnv, local)
+	}
+	return &authenticator{key: key, local: local}
+}
+
+// localSession returns the menu bar's session if r carries the local token.
+// It only pauses and resumes monitoring, and only from this machine.
+func (a *authenticator) localSession(r *http.Request, token string) (session, bool) {
+	if r.URL.Path != "/api/scheduler" || !hmac.Equal([]byte(token), []byte(a.local)) {
+		return session{}, false
+	}
+	host, _, _ := net.SplitHostPort(r.RemoteAddr)
+	if ip := net.ParseIP(host); ip == nil || !ip.IsLoopback() {
+		return session{}, false
+	}
+	return session{User: "menu bar", Role: config.RoleAdmin}, true
+}
+
+// providerFor returns the provider for issuer, replacing the cached one if
+// the issuer has been changed in the config
+func (a *authenticator) providerFor(issuer string) *oidc.Provider {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.provider == nil || a.provider.Issuer() != strings.TrimSuffix(issuer, "/") {
+		a.provider = oidc.NewProvider(issuer)
+	}
+	return a.provider
+}
+
+// session is who a request comes from: a signed-in user, kept in a signed
+// cookie, or an API token
+type session struct {
+	User    string      `json:"u"`
+	Role    config.Role `json:"r"`
+	Tags    []string    `json:"t,omitempty"` // Only hosts with one of these tags are shown; see config.RoleMapping
+	Expires int64       `json:"e"`
+}
+
+// scoped reports whether the user only sees some hosts
+func (sess session) scoped() bool {
+	return len(sess.Tags) > 0
+}
+
+// sessionKey holds the request's session in its context, once requireAuth
+// has found it
+type sessionKey struct{}
+
+// scopedPaths are the pages a user limited to some hosts can use. Each
+// shows only the hosts they may see, or one named by a host parameter,
+// which requireAuth checks; everything else covers every host.
+var scopedPaths = map[string]bool{
+	"/":                    true,
+	"/hosts":               true,
+	"/stats":               true,
+	"/ws/hosts":            true,
+	"/wallboard":           true,
+	"/wallboard/tiles":     true,
+	"/analytics/host":      true,
+	"/tls":                 true,
+	"/probe-log":           true,
+	"/pause-status":        true,
+	"/connectivity-banner": true,
+}
+
+// pendingLogin is kept in a signed cookie while the user is at the provider
+type pendingLogin struct {
+	State    string `json:"s"`
+	Nonce    string `json:"n"`
+	Verifier string `json:"v"`
+	Next     string `json:"x"` // Where to go once signed in
+	Expires  int64  `json:"e"`
+}
+
+// seal encodes v and signs it, for the value of cookie name. The name is
+// signed too, so one cookie's value can't be passed off as another's.
+func (a *authenticator) seal(name string, v any) string {
+	b, _ := json.Marshal(v)
+	payload := base64.RawURLEncoding.EncodeToString(b)
+	return payload + "." + a.sign(name, payload)
+}
+
+// open checks a sealed value of cookie name and decodes it into v
+func (a *authenticator) open(name, sealed string, v any) error {
+	payload, sig, ok := strings.Cut(sealed, ".")
+	if !ok || !hmac.Equal([]byte(sig), []byte(a.sign(name, payload))) {
+		return errors.New("bad signature")
+	}
+	b, err := base64.RawURLEncoding.DecodeString(payload)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(b, v)
+}
+
+func (a *authenticator) sign(name, payload string) string {
+	mac := hmac.New(sha256.New, a.key)
+	mac.Write([]byte(name + ":" + payload))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// session returns the request's signed-in user, if any
+func (a *authenticator) session(r *http.Request) (session, bool) {
+	if sess, ok := r.Context().Value(sessionKey{}).(session); ok {
+		return sess, true
+	}
+	c, err := r.Cookie(sessionCookie)
+	if err != nil {
+		return session{}, false
+	}
+	var sess session
+	if a.open(sessionCookie, c.Value, &sess) != nil || sess.Role == "" || time.Now().Unix() >= sess.Expires {
+		return session{}, false
+	}
+	return sess, true
+}
+
+// requireAuth sends requests without a session or API token to sign in
+// when sign-in is configured, stops viewers changing anything or seeing the
+// settings, and keeps
+// users limited to some hosts to the pages that show only those. A token
+// comes as a bearer header from scripts, or as the password of the login a
+// browser asks for. The sign-in pages, static files, signed embeds,
+// webhooks and any paths the config leaves public are let through.
+func (s *Server) requireAuth(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		settings := s.st.GetAuthSettings()
+		if !settings.Enabled() || s.isPublic(settings, r.URL.Path) {
+			next.ServeHTTP(w, r)
+			return
+		}
+		sess, ok := s.auth.session(r)
+		if token, found := requestToken(r); found {
+			if local, isLocal := s.auth.localSession(r, token); isLocal {
+				sess, ok = local, true
+			} else if tok, valid := settings.TokenFor(token); valid {
+				sess, ok = session{User: "token " + tok.Name, Role: tok.Role, Tags: tok.Tags}, true
+			} else {
+				signInRequired(w, r, settings)
+				return
+			}
+		}
+		if !ok {
+			signInRequired(w, r, settings)
+			return
+		}
+		if sess.Role != config.RoleAdmin && r.Method != http.MethodGet && r.Method != http.MethodHead {
+			http.Error(w, "viewers can't make changes", http.StatusForbidden)
+			return
+		}
+		if sess.Role != config.RoleAdmin && adminOnly(r.URL.Path) {
+			http.Error(w, "only admins can see the settings", http.StatusForbidden)
+			return
+		}
+		if sess.scoped() && !s.scopedAllowed(sess, r) {
+			http.Error(w, "not available to users who see only some hosts", http.StatusForbidden)
+			return
+		}
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), sessionKey{}, sess)))
+	})
+}
+
+// adminOnly reports whether path is one only admins may see, even to look:
+// the settings page and config history show passwords and tokens
+func adminOnly(path string) bool {
+	return path == "/settings" || strings.HasPrefix(path, "/settings/")
+}
+
+// requestToken returns the API token r carries, as a bearer header or as
+// the password of a browser's login
+func requestToken(r *http.Request) (string, bool) {
+	if token, found := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer "); found {
+		return token, true
+	}
+	_, token, found := r.BasicAuth()
+	return token, found
+}
+
+// scopedAllowed reports whether a user limited to some hosts may make
+// request r: it is for one of scopedPaths and, if it names a host, a host
+// they see. Changes are refused, as they could touch any host.
+func (s *Server) scopedAllowed(sess session, r *http.Request) bool {
+	if !scopedPaths[r.URL.Path] || (r.Method != http.MethodGet && r.Method != http.MethodHead) {
+		return false
+	}
+	name := r.URL.Query().Get("host")
+	if name == "" {
+		return true
+	}
+	hs, ok := s.st.GetHost(name)
+	return ok && hs.VisibleTo(sess.Tags)
+}
+
+// visibleTags returns the tags limiting which hosts the request's user
+// sees, or nil if they see every host
+func (s *Server) visibleTags(r *http.Request) []string {
+	if sess, ok := r.Context().Value(sessionKey{}).(session); ok {
+		return sess.Tags
+	}
+	return nil
+}
+
+func (s *Server) isPublic(settings config.AuthSettings, path string) bool {
+	switch {
+	case strings.HasPrefix(path, "/auth/"), strings.HasPrefix(path, "/static/"), strings.HasPrefix(path, "/favicon."):
+		return true
+	case strings.HasPrefix(path, "/embed/"):
+		// Signed embeds carry their own token; unsigned ones would show
+		// every host to anyone
+		return s.st.EmbedsSigned()
+	case strings.HasPrefix(path, "/api/webhook/"):
+		// Senders aren't signed in; with sign-in on, every webhook check
+		// needs its own token
+		return true
+	}
+	return settings.IsPublic(path)
+}
+
+// signInRequired sends a browser to sign in, coming back to the page it
+// was on. htmx requests are told to redirect the whole page, and anything
+// else gets a plain 401. Without single sign-on, it asks for an API token
+// instead: browsers show a login prompt, and the token goes in as its
+// password.
+func signInRequired(w http.ResponseWriter, r *http.Request, settings config.AuthSettings) {
+	if !settings.SingleSignOn() {
+		w.Header().Set("WWW-Authenticate", `Basic realm="poke443", charset="UTF-8"`)
+		http.Error(w, "an API token is required", http.StatusUnauthorized)
+		return
+	}
+	if r.Header.Get("HX-Request") == "true" {
+		next := "/"
+		if u, err := url.Parse(r.Header.Get("HX-Current-URL")); err == nil && u.Path != "" {
+			next = u.RequestURI()
+		}
+		w.Header().Set("HX-Redirect", "/auth/login?next="+url.QueryEscape(next))
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	if r.Method == http.MethodGet && strings.Contains(r.Header.Get("Accept"), "text/html") {
+		http.Redirect(w, r, "/auth/login?next="+url.QueryEscape(r.URL.RequestURI()), http.StatusFound)
+		return
+	}
+	http.Error(w, "sign-in required", http.StatusUnauthorized)
+}
+
+// handleLogin sends the browser to the provider to sign in
+func (s *Server) handleLogin(w http.ResponseWriter, r *http.Request) {
+	settings := s.st.GetAuthSettings()
+	if !settings.SingleSignOn() {
+		http.Redirect(w, r, "/", http.StatusFound)
+		return
+	}
+	login := pendingLogin{
+		State:    oidc.RandomString(),
+		Nonce:    oidc.RandomString(),
+		Verifier: oidc.RandomString(),
+		Next:     localPath(r.FormValue("next")),
+		Expires:  time.Now().Add(loginTimeout).Unix(),
+	}
+	p := s.auth.providerFor(settings.OIDC.Issuer)
+	target, err := p.AuthCodeURL(r.Context(), settings.OIDC.ClientID, settings.OIDC.RedirectURL,
+		settings.OIDCScopes(), login.State, login.Nonce, oidc.Challenge(login.Verifier))
+	if err != nil {
+		log.Printf("sign-in: %v", err)
+		http.Error(w, "the sign-in provider can't be reached", http.StatusBadGateway)
+		return
+	}
+	setCookie(w, settings, loginCookie, s.auth.seal(loginCookie, login), loginTimeout)
+	http.Redirect(w, r, target, http.StatusFound)
+}
+
+// handleLoginCallback is where the provider sends the browser back. The
+// code is exchanged for an ID token, whose groups decide the user's role.
+func (s *Server) handleLoginCallback(w http.ResponseWriter, r *http.Request) {
+	settings := s.st.GetAuthSettings()
+	if !settings.SingleSignOn() {
+		http.Redirect(w, r, "/", http.StatusFound)
+		return
+	}
+	var login pendingLogin
+	c, err := r.Cookie(loginCookie)
+	if err != nil || s.auth.open(loginCookie, c.Value, &login) != nil || time.Now().Unix() >= login.Expires ||
+		!hmac.Equal([]byte(login.State), []byte(r.FormValue("state"))) {
+		http.Error(w, "sign-in expired or was started elsewhere; try again", http.StatusBadRequest)
+		return
+	}
+	setCookie(w, settings, loginCookie, "", -1)
+	if e := r.FormValue("error"); e != "" {
+		log.Printf("sign-in refused by provider: %s %s", e, r.FormValue("error_description"))
+		http.Error(w, "the sign-in provider refused the sign-in", http.StatusForbidden)
+		return
+	}
+	p := s.auth.providerFor(settings.OIDC.Issuer)
+	raw, err := p.Exchange(r.Context(), settings.OIDC.ClientID, settings.OIDC.ClientSecret,
+		settings.OIDC.RedirectURL, r.FormValue("code"), login.Verifier)
+	if err != nil {
+		log.Printf("sign-in: %v", err)
+		http.Error(w, "sign-in failed", http.StatusBadGateway)
+		return
+	}
+	claims, err := p.Verify(r.Context(), raw, settings.OIDC.ClientID, login.Nonce)
+	if err != nil {
+		log.Printf("sign-in: %v", err)
+		http.Error(w, "sign-in failed", http.StatusForbidden)
+		return
+	}
+	user := claims.Username()
+	groups := claims.Strings(settings.OIDCGroupsClaim())
+	role := settings.RoleFor(groups)
+	if role == "" {
+		log.Printf("sign-in refused: %s is in none of the groups given a role", user)
+		http.Error(w, user+" is not allowed to use this dashboard", http.StatusForbidden)
+		return
+	}
+	ttl := settings.Session()
+	setCookie(w, settings, sessionCookie, s.auth.seal(sessionCookie, session{User: user, Role: role, Tags: settings.TagsFor(groups), Expires: time.Now().Add(ttl).Unix()}), ttl)
+	log.Printf("signed in: %s as %s", user, role)
+	http.Redirect(w, r, login.Next, http.StatusFound)
+}
+
+// handleLogout ends the session, and the provider's too if it supports that
+func (s *Server) handleLogout(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.WriteHeader(405)
+		return
+	}
+	settings := s.st.GetAuthSettings()
+	setCookie(w, settings, sessionCookie, "", -1)
+	target := "/"
+	if settings.SingleSignOn() {
+		if end := s.auth.providerFor(settings.OIDC.Issuer).EndSessionURL(r.Context()); end != "" {
+			target = end + "?client_id=" + url.QueryEscape(settings.OIDC.ClientID)
+		}
+	}
+	if r.Header.Get("HX-Request") == "true" {
+		w.Header().Set("HX-Redirect", target)
+		return
+	}
+	http.Redirect(w, r, target, http.StatusFound)
+}
+
+// handleSignedIn renders who is signed in, for the sidebar. It is empty
+// without single sign-on.
+func (s *Server) handleSignedIn(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if !s.st.GetAuthSettings().SingleSignOn() {
+		return
+	}
+	if sess, ok := s.auth.session(r); ok {
+		_ = s.tpl.ExecuteTemplate(w, "signed_in.html", sess)
+	}
+}
+
+// setCookie sets (or with a negative ttl, clears) one of the sign-in
+// cookies. They are only sent over HTTPS when the server is reached that way.
+func setCookie(w http.ResponseWriter, settings config.AuthSettings, name, value string, ttl time.Duration) {
+	maxAge := int(ttl.Seconds())
+	if ttl < 0 {
+		maxAge = -1
+	}
+	http.SetCookie(w, &http.Cookie{
+		Name:     name,
+		Value:    value,
+		Path:     "/",
+		MaxAge:   maxAge,
+		HttpOnly: true,
+		Secure:   strings.HasPrefix(settings.OIDC.RedirectURL, "https://"),
+		SameSite: http.SameSiteLaxMode,
+	})
+}
+
+// localPath returns next if it is a path on this server, so signing in
+// can't be used to redirect somewhere else
+func localPath(next string) string {
+	if !strings.HasPrefix(next, "/") || strings.HasPrefix(next, "//") || strings.HasPrefix(next, "/\\") {
+		return "/"
+	}
+	return next
+}
@@ -0,0 +1,112 @@
+package server
+
+import (
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/andrewsjg/simple-healthchecker/copilot/internal/config"
+	"github.com/andrewsjg/simple-healthchecker/copilot/internal/state"
+	"github.com/andrewsjg/simple-healthchecker/copilot/internal/validate"
+)
+
+// bulkEditForm is the data for bulkedit_modal.html
+type bulkEditForm struct {
+	Search string
+	Type   string
+}
+
+// handleBulkEditForm opens the bulk edit modal, starting from the
+// dashboard's current search
+func (s *Server) handleBulkEditForm(w http.ResponseWriter, r *http.Request) {
+	data := bulkEditForm{Search: strings.TrimSpace(r.FormValue("q"))}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	_ = s.tpl.ExecuteTemplate(w, "bulkedit_modal.html", data)
+}
+
+// handleBulkEditPreview lists the checks the modal's filter matches
+func (s *Server) handleBulkEditPreview(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	_ = s.tpl.ExecuteTemplate(w, "bulkedit_matches.html", s.st.MatchChecks(bulkEditFilter(r)))
+}
+
+// handleBulkEdit applies the modal's changes to every matching check, then
+// refreshes the dashboard and closes the modal
+func (s *Server) handleBulkEdit(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.WriteHeader(405)
+		return
+	}
+	f := bulkEditFilter(r)
+	var errs validate.Errors
+	e := parseBulkEdit(&errs, r)
+	if !errs.Any() && e.Empty() {
+		errs.Add("Changes", "choose at least one setting to change")
+	}
+	if !errs.Any() && len(s.st.MatchChecks(f)) == 0 {
+		errs.Add("Filter", "no checks match")
+	}
+	if errs.Any() {
+		s.renderFormErrors(w, "#bulkedit-errors", errs)
+		return
+	}
+	n, err := s.changes(r).BulkEditChecks(f, e)
+	if err != nil {
+		errs.Add("Changes", "%s", err)
+		s.renderFormErrors(w, "#bulkedit-errors", errs)
+		return
+	}
+	log.Printf("bulk edit changed %d checks", n)
+	data := s.queryHosts(r)
+	_ = s.tpl.ExecuteTemplate(w, "add_host_result.html", data)
+}
+
+// bulkEditFilter reads the modal's filter fields. They are named apart from
+// the dashboard's own filters, which are posted alongside them.
+func bulkEditFilter(r *http.Request) state.CheckFilter {
+	return state.CheckFilter{
+		Search: strings.TrimSpace(r.FormValue("match")),
+		Type:   config.CheckType(r.FormValue("match_type")),
+	}
+}
+
+// parseBulkEdit reads the modal's change fields. Selects left on
+// "Unchanged" post an empty value; dependency and schedule only change when
+// their box is ticked, so that an empty value can clear them.
+func parseBulkEdit(errs *validate.Errors, r *http.Request) state.BulkEdit {
+	var e state.BulkEdit
+	e.Enabled = parseOnOff(errs, "Enabled", r.FormValue("enabled"))
+	e.MQTTNotify = parseOnOff(errs, "MQTT", r.FormValue("mqtt_notify"))
+	e.PushoverNotify = parseOnOff(errs, "Pushover", r.FormValue("pushover_notify"))
+	e.TelegramNotify = parseOnOff(errs, "Telegram", r.FormValue("telegram_notify"))
+	e.SMSNotify = parseOnOff(errs, "SMS", r.FormValue("sms_notify"))
+	if v := r.FormValue("severity"); v != "" {
+		sev := parseSeverity(errs, "Check", v)
+		e.Severity = &sev
+	}
+	if r.FormValue("set_depends_on") == "true" {
+		dependsOn := strings.TrimSpace(r.FormValue("depends_on"))
+		errs.Check("Depends on", validate.CheckID(dependsOn))
+		e.DependsOn = &dependsOn
+	}
+	if r.FormValue("set_schedule") == "true" {
+		schedule := strings.TrimSpace(r.FormValue("schedule"))
+		_, err := config.ParseSchedule(schedule)
+		errs.Check("Schedule", err)
+		e.Schedule = &schedule
+	}
+	return e
+}
+
+// parseOnOff reads an "Unchanged / On / Off" select
+func parseOnOff(errs *validate.Errors, label, v string) *bool {
+	switch v {
+	case "":
+		return nil
+	case "true", "false":
+		on := v == "true"
+		return &on
+	}
+	errs.Add(label, "%q must be true or false", v)
+	return nil
+}
@@ -0,0 +1,957 @@
+package server
+
+import (
+	"fmt"
+	"html/template"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/andrewsjg/simple-healthchecker/copilot/internal/checks"
+	"github.com/andrewsjg/simple-healthchecker/copilot/internal/state"
+)
+
+// svgBuilder accumulates SVG markup without the per-call allocations of
+// fmt.Sprintf and string concatenation, which dominate chart rendering
+type svgBuilder struct {
+	strings.Builder
+	num []byte // Scratch space for formatting numbers
+}
+
+// point writes "x,y" to one decimal place, as %.1f,%.1f would
+func (b *svgBuilder) point(x, y float64) {
+	b.float(x)
+	b.WriteByte(',')
+	b.float(y)
+}
+
+func (b *svgBuilder) float(v float64) {
+	b.num = strconv.AppendFloat(b.num[:0], v, 'f', 1, 64)
+	b.Write(b.num)
+}
+
+func (b *svgBuilder) int(v int) {
+	b.num = strconv.AppendInt(b.num[:0], int64(v), 10)
+	b.Write(b.num)
+}
+
+// generateSparklineSVG creates an inline SVG sparkline chart from latency history
+func generateSparklineSVG(history []time.Duration, isOK bool) template.HTML {
+	if len(history) == 0 {
+		return template.HTML("")
+	}
+
+	width := 100
+	height := 24
+	padding := 2
+
+	// Find max value for scaling (minimum 1 to avoid division by zero)
+	maxVal := time.Duration(1)
+	for _, v := range history {
+		if v > maxVal {
+			maxVal = v
+		}
+	}
+
+	// Build the line path; the filled area (for the gradient effect) is the
+	// same path closed along the bottom edge
+	var line svgBuilder
+	line.Grow(len(history) * 12)
+	chartWidth := float64(width - 2*padding)
+	chartHeight := float64(height - 2*padding)
+	for i, v := range history {
+		x := float64(padding) + (float64(i)/float64(len(history)-1))*chartWidth
+		if len(history) == 1 {
+			x = float64(width) / 2
+		}
+		// Invert Y since SVG origin is top-left
+		y := float64(height-padding) - (float64(v)/float64(maxVal))*chartHeight
+		if i == 0 {
+			line.WriteByte('M')
+		} else {
+			line.WriteByte('L')
+		}
+		line.point(x, y)
+	}
+	lastX := float64(padding) + chartWidth
+	if len(history) == 1 {
+		lastX = float64(width) / 2
+	}
+	points := line.String()
+	area := fmt.Sprintf("%sL%.1f,%dL%d,%dZ", points, lastX, height-padding, padding, height-padding)
+
+	// Determine color based on current check status
+	lineColor := "#22c55e" // green by default
+	if !isOK {
+		lineColor = "#ef4444" // red if check is currently down
+	}
+
+	svg := fmt.Sprintf(`<svg class="sparkline" width="%d" height="%d" viewBox="0 0 %d %d">
+		<defs>
+			<linearGradient id="sparkGrad" x1="0%%" y1="0%%" x2="0%%" y2="100%%">
+				<stop offset="0%%" style="stop-color:%s;stop-opacity:0.3"/>
+				<stop offset="100%%" style="stop-color:%s;stop-opacity:0.05"/>
+			</linearGradient>
+		</defs>
+		<path d="%s" fill="url(#sparkGrad)" />
+		<path d="%s" fill="none" stroke="%s" stroke-width="1.5" stroke-linecap="round" stroke-linejoin="round"/>
+	</svg>`,
+		width, height, width, height,
+		lineColor, lineColor,
+		area,
+		points,
+		lineColor)
+
+	return template.HTML(svg)
+}
+
+// generateDonutChartSVG creates a donut/ring chart showing up/down/disabled percentages
+func generateDonutChartSVG(stats state.AggregateStats) template.HTML {
+	size := 120
+	strokeWidth := 12
+	radius := (size - strokeWidth) / 2
+	center := size / 2
+	circumference := 2 * math.Pi * float64(radius)
+
+	total := stats.ChecksUp + stats.ChecksDown + stats.ChecksParentFailed + stats.ChecksDisabled + stats.ChecksUnknown + stats.ChecksInfoDown
+	if total == 0 {
+		return template.HTML(fmt.Sprintf(`<svg width="%d" height="%d" viewBox="0 0 %d %d">
+			<circle cx="%d" cy="%d" r="%d" fill="none" stroke="#334155" stroke-width="%d"/>
+			<text x="%d" y="%d" text-anchor="middle" dominant-baseline="middle" fill="#64748b" font-size="12">No data</text>
+		</svg>`, size, size, size, size, center, center, radius, strokeWidth, center, center))
+	}
+
+	// Calculate segment sizes based on CURRENT status (for visual ring)
+	upPct := float64(stats.ChecksUp) / float64(total)
+	downPct := float64(stats.ChecksDown) / float64(total)
+	parentFailedPct := float64(stats.ChecksParentFailed) / float64(total)
+	disabledPct := float64(stats.ChecksDisabled) / float64(total)
+	unknownPct := float64(stats.ChecksUnknown) / float64(total)
+	infoDownPct := float64(stats.ChecksInfoDown) / float64(total)
+
+	upLen := circumference * upPct
+	downLen := circumference * downPct
+	parentFailedLen := circumference * parentFailedPct
+	disabledLen := circumference * disabledPct
+	unknownLen := circumference * unknownPct
+	infoDownLen := circumference * infoDownPct
+
+	upOffset := 0.0
+	downOffset := -upLen
+	parentFailedOffset := -upLen - downLen
+	disabledOffset := -upLen - downLen - parentFailedLen
+	unknownOffset := -upLen - downLen - parentFailedLen - disabledLen
+	infoDownOffset := -upLen - downLen - parentFailedLen - disabledLen - unknownLen
+
+	// Use historical uptime for the center percentage
+	// If no historical data yet, show current status percentage
+	displayPct := stats.OverallUptime
+	if displayPct == 0 && stats.ChecksUp > 0 {
+		// No historical data yet, show current percentage
+		displayPct = float64(stats.ChecksUp) / float64(stats.ChecksUp+stats.ChecksDown) * 100
+	}
+
+	// Choose center text color based on health
+	textColor := "#22c55e" // green
+	if stats.ChecksDown > 0 {
+		textColor = "#ef4444" // red
+	} else if stats.ChecksParentFailed > 0 {
+		textColor = "#f97316" // orange for parent-failed
+	} else if displayPct < 99 {
+		textColor = "#f59e0b" // amber
+	}
+
+	svg := fmt.Sprintf(`<svg width="%d" height="%d" viewBox="0 0 %d %d" class="donut-chart">
+		<circle cx="%d" cy="%d" r="%d" fill="none" stroke="#1e293b" stroke-width="%d"/>
+		<circle cx="%d" cy="%d" r="%d" fill="none" stroke="#22c55e" stroke-width="%d" 
+			stroke-dasharray="%.1f %.1f" stroke-dashoffset="%.1f" transform="rotate(-90 %d %d)"/>
+		<circle cx="%d" cy="%d" r="%d" fill="none" stroke="#ef4444" stroke-width="%d"
+			stroke-dasharray="%.1f %.1f" stroke-dashoffset="%.1f" transform="rotate(-90 %d %d)"/>
+		<circle cx="%d" cy="%d" r="%d" fill="none" stroke="#f97316" stroke-width="%d"
+			stroke-dasharray="%.1f %.1f" stroke-dashoffset="%.1f" transform="rotate(-90 %d %d)"/>
+		<circle cx="%d" cy="%d" r="%d" fill="none" stroke="#64748b" stroke-width="%d"
+			stroke-dasharray="%.1f %.1f" stroke-dashoffset="%.1f" transform="rotate(-90 %d %d)"/>
+		<circle cx="%d" cy="%d" r="%d" fill="none" stroke="#f59e0b" stroke-width="%d"
+			stroke-dasharray="%.1f %.1f" stroke-dashoffset="%.1f" transform="rotate(-90 %d %d)"/>
+		<circle cx="%d" cy="%d" r="%d" fill="none" stroke="#38bdf8" stroke-width="%d"
+			stroke-dasharray="%.1f %.1f" stroke-dashoffset="%.1f" transform="rotate(-90 %d %d)"/>
+		<text x="%d" y="%d" text-anchor="middle" dominant-baseline="middle" fill="%s" font-size="20" font-weight="600">%.1f%%</text>
+		<text x="%d" y="%d" text-anchor="middle" dominant-baseline="middle" fill="#64748b" font-size="10">uptime</text>
+	</svg>`,
+		size, size, size, size,
+		center, center, radius, strokeWidth,
+		center, center, radius, strokeWidth, upLen, circumference, upOffset, center, center,
+		center, center, radius, strokeWidth, downLen, circumference, downOffset, center, center,
+		center, center, radius, strokeWidth, parentFailedLen, circumference, parentFailedOffset, center, center, // Orange for parent-failed
+		center, center, radius, strokeWidth, disabledLen, circumference, disabledOffset, center, center,
+		center, center, radius, strokeWidth, unknownLen, circumference, unknownOffset, center, center,
+		center, center, radius, strokeWidth, infoDownLen, circumference, infoDownOffset, center, center, // Blue for info-severity failures
+		center, center-4, textColor, displayPct,
+		center, center+14)
+
+	return template.HTML(svg)
+}
+
+// generateHeatmapSVG creates a heatmap grid showing recent check results
+func generateHeatmapSVG(data []bool) template.HTML {
+	if len(data) == 0 {
+		return template.HTML("")
+	}
+
+	// Use smaller cells and single row for compact display
+	cellSize := 4
+	gap := 1
+	cols := len(data) // Single row
+	if cols > 30 {
+		cols = 30 // Max 30 cells
+		data = data[len(data)-30:]
+	}
+
+	width := cols*(cellSize+gap) - gap
+	height := cellSize
+
+	var b svgBuilder
+	b.Grow(80 + len(data)*70)
+	fmt.Fprintf(&b, `<svg width="%d" height="%d" viewBox="0 0 %d %d" class="heatmap">`, width, height, width, height)
+	for i, ok := range data {
+		color := "#22c55e"
+		if !ok {
+			color = "#ef4444"
+		}
+		b.WriteString(`<rect x="`)
+		b.int(i * (cellSize + gap))
+		b.WriteString(`" y="0" width="`)
+		b.int(cellSize)
+		b.WriteString(`" height="`)
+		b.int(cellSize)
+		b.WriteString(`" rx="1" fill="`)
+		b.WriteString(color)
+		b.WriteString(`"/>`)
+	}
+	b.WriteString(`</svg>`)
+	return template.HTML(b.String())
+}
+
+// generateUptimeBarSVG creates a horizontal bar showing uptime percentage
+func generateUptimeBarSVG(uptime float64) template.HTML {
+	width := 100
+	height := 8
+
+	color := uptimeColor(uptime)
+	fillWidth := int(float64(width) * uptime / 100)
+
+	return template.HTML(fmt.Sprintf(`<svg width="%d" height="%d" viewBox="0 0 %d %d" class="uptime-bar">
+		<rect x="0" y="0" width="%d" height="%d" rx="4" fill="#1e293b"/>
+		<rect x="0" y="0" width="%d" height="%d" rx="4" fill="%s"/>
+	</svg>`, width, height, width, height, width, height, fillWidth, height, color))
+}
+
+// uptimeColor is green from 99%, amber from 95% and red below
+func uptimeColor(uptime float64) string {
+	switch {
+	case uptime < 95:
+		return "#ef4444"
+	case uptime < 99:
+		return "#f59e0b"
+	}
+	return "#22c55e"
+}
+
+// smokepingCache holds rendered smokeping charts by the data they were
+// drawn from, so the analytics page only redraws the charts of checks with
+// new results or notes since it was last loaded
+var smokepingCache = chartCache{charts: make(map[smokepingKey]template.HTML)}
+
+// maxCachedCharts bounds smokepingCache; a few charts per check is plenty
+const maxCachedCharts = 512
+
+type chartCache struct {
+	mu     sync.Mutex
+	charts map[smokepingKey]template.HTML
+}
+
+func (c *chartCache) get(key smokepingKey) (template.HTML, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	svg, ok := c.charts[key]
+	return svg, ok
+}
+
+func (c *chartCache) put(key smokepingKey, svg template.HTML) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	// Charts go stale as soon as their check runs again, so rather than
+	// track use just start over when full
+	if len(c.charts) >= maxCachedCharts {
+		clear(c.charts)
+	}
+	c.charts[key] = svg
+}
+
+// smokepingKey identifies what a smokeping chart shows. Data point sequence
+// numbers are unique across checks and a history only changes by gaining
+// and dropping points at its ends, so its first and last points and its
+// length pin it down.
+type smokepingKey struct {
+	first, last   uint64
+	n             int
+	notes         string // IDs of the notes, which are never edited in place
+	width, height int
+	loc           *time.Location
+}
+
+func newSmokepingKey(history []state.CheckDataPoint, notes []state.Annotation, width, height int, loc *time.Location) (smokepingKey, bool) {
+	first, last := history[0].Seq, history[len(history)-1].Seq
+	if first == 0 || last == 0 {
+		return smokepingKey{}, false // Not recorded by a check, so not versioned
+	}
+	var ids []byte
+	for _, a := range notes {
+		ids = strconv.AppendInt(ids, int64(a.ID), 10)
+		ids = append(ids, ',')
+	}
+	return smokepingKey{first: first, last: last, n: len(history), notes: string(ids), width: width, height: height, loc: loc}, true
+}
+
+// generateSmokepingChartSVG creates a smokeping-style latency chart, marking
+// the spans covered by notes
+func generateSmokepingChartSVG(history []state.CheckDataPoint, notes []state.Annotation, width, height int, loc *time.Location) template.HTML {
+	if len(history) == 0 {
+		return template.HTML(fmt.Sprintf(`<svg width="%d" height="%d" viewBox="0 0 %d %d">
+			<text x="%d" y="%d" text-anchor="middle" dominant-baseline="middle" fill="#64748b" font-size="12">No data yet</text>
+		</svg>`, width, height, width, height, width/2, height/2))
+	}
+	key, ok := newSmokepingKey(history, notes, width, height, loc)
+	if !ok {
+		return renderSmokepingChartSVG(history, notes, width, height, loc)
+	}
+	if svg, ok := smokepingCache.get(key); ok {
+		return svg
+	}
+	svg := renderSmokepingChartSVG(history, notes, width, height, loc)
+	smokepingCache.put(key, svg)
+	return svg
+}
+
+// smokepingBucket summarises the latencies of the data points drawn at one x
+type smokepingBucket struct {
+	min, max, median, p75, p95 time.Duration
+	hasData                    bool
+	hasFailure                 bool
+	afterGap                   bool // Starts after a stretch with no data
+}
+
+func renderSmokepingChartSVG(history []state.CheckDataPoint, notes []state.Annotation, width, height int, loc *time.Location) template.HTML {
+	// Scale padding based on chart size
+	paddingX := 35
+	paddingY := 20
+	if height < 150 {
+		paddingY = 15
+	}
+	chartWidth := width - 2*paddingX
+	chartHeight := height - 2*paddingY
+
+	// Find max latency for scaling
+	maxLatency := time.Duration(1)
+	for _, dp := range history {
+		if dp.Latency > maxLatency {
+			maxLatency = dp.Latency
+		}
+	}
+	// Add 20% headroom; the floor keeps sub-millisecond LAN latencies visible
+	maxLatency = maxLatency * 6 / 5
+	if maxLatency < 100*time.Microsecond {
+		maxLatency = 100 * time.Microsecond
+	}
+
+	// Group data points into buckets for percentile calculation
+	bucketCount := chartWidth / 3 // One bucket per 3 pixels
+	if bucketCount > len(history) {
+		bucketCount = len(history)
+	}
+	if bucketCount < 1 {
+		bucketCount = 1
+	}
+
+	buckets := make([]smokepingBucket, bucketCount)
+	dataBuckets := 0
+	latencies := make([]time.Duration, 0, len(history)/bucketCount+1)
+	for bi := range buckets {
+		start := bi * len(history) / bucketCount
+		end := (bi + 1) * len(history) / bucketCount
+		if end > len(history) {
+			end = len(history)
+		}
+
+		latencies = latencies[:0]
+		for i := start; i < end; i++ {
+			if !history[i].OK {
+				buckets[bi].hasFailure = true
+			}
+			if history[i].Gap > 0 {
+				buckets[bi].afterGap = true
+			}
+			if history[i].Latency > 0 {
+				latencies = append(latencies, history[i].Latency)
+			}
+		}
+
+		if len(latencies) > 0 {
+			dataBuckets++
+			buckets[bi].hasData = true
+			slices.Sort(latencies)
+			buckets[bi].min = latencies[0]
+			buckets[bi].max = latencies[len(latencies)-1]
+			buckets[bi].median = latencies[len(latencies)/2]
+			buckets[bi].p75 = latencies[int(float64(len(latencies))*0.75)]
+			p95Idx := int(float64(len(latencies)) * 0.95)
+			if p95Idx >= len(latencies) {
+				p95Idx = len(latencies) - 1
+			}
+			buckets[bi].p95 = latencies[p95Idx]
+		}
+	}
+
+	var b svgBuilder
+	b.Grow(2048 + dataBuckets*100)
+	fmt.Fprintf(&b, `<svg width="%d" height="%d" viewBox="0 0 %d %d" class="smokeping-chart">`, width, height, width, height)
+
+	// Background
+	fmt.Fprintf(&b, `<rect x="0" y="0" width="%d" height="%d" fill="#0f172a"/>`, width, height)
+
+	// Grid lines - keep 5 for good granularity
+	gridLines := 5
+	for i := 0; i <= gridLines; i++ {
+		y := paddingY + i*chartHeight/gridLines
+		latencyVal := maxLatency - time.Duration(i)*maxLatency/time.Duration(gridLines)
+		fmt.Fprintf(&b, `<line x1="%d" y1="%d" x2="%d" y2="%d" stroke="#1e293b" stroke-width="0.5"/>`, paddingX, y, paddingX+chartWidth, y)
+		fmt.Fprintf(&b, `<text x="%d" y="%d" text-anchor="end" fill="#64748b" font-size="7">%s</text>`, paddingX-2, y+2, checks.FormatLatency(latencyVal))
+	}
+
+	// X-axis labels
+	first := history[0].Timestamp.In(loc)
+	last := history[len(history)-1].Timestamp.In(loc)
+	fmt.Fprintf(&b, `<text x="%d" y="%d" text-anchor="start" fill="#64748b" font-size="7" data-datetime="%s" data-layout="hm">%s</text>`,
+		paddingX, height-2, first.Format(time.RFC3339), first.Format(timeLayouts["hm"]))
+	fmt.Fprintf(&b, `<text x="%d" y="%d" text-anchor="end" fill="#64748b" font-size="7" data-datetime="%s" data-layout="hm">%s</text>`,
+		paddingX+chartWidth, height-2, last.Format(time.RFC3339), last.Format(timeLayouts["hm"]))
+
+	bucketWidth := float64(chartWidth) / float64(bucketCount)
+	xAt := func(bi int) float64 { return float64(paddingX) + float64(bi)*bucketWidth + bucketWidth/2 }
+	yAt := func(d time.Duration) float64 {
+		return float64(paddingY) + float64(chartHeight)*(1-float64(d)/float64(maxLatency))
+	}
+
+	// Draw smokeping-style bands, lightest first. Each is a polygon along
+	// one percentile left to right, then back along the other.
+	band := func(along, back func(smokepingBucket) time.Duration, fill string) {
+		if dataBuckets == 0 {
+			return
+		}
+		b.WriteString(`<polygon points="`)
+		sep := false
+		for bi, bk := range buckets {
+			if bk.hasData {
+				if sep {
+					b.WriteByte(' ')
+				}
+				b.point(xAt(bi), yAt(along(bk)))
+				sep = true
+			}
+		}
+		for bi := len(buckets) - 1; bi >= 0; bi-- {
+			if bk := buckets[bi]; bk.hasData {
+				b.WriteByte(' ')
+				b.point(xAt(bi), yAt(back(bk)))
+			}
+		}
+		b.WriteString(`" fill="`)
+		b.WriteString(fill)
+		b.WriteString(`"/>`)
+	}
+	band(func(bk smokepingBucket) time.Duration { return bk.max }, func(bk smokepingBucket) time.Duration { return bk.p95 }, "rgba(59, 130, 246, 0.1)")
+	band(func(bk smokepingBucket) time.Duration { return bk.p75 }, func(bk smokepingBucket) time.Duration { return bk.p95 }, "rgba(59, 130, 246, 0.2)")
+	band(func(bk smokepingBucket) time.Duration { return bk.median }, func(bk smokepingBucket) time.Duration { return bk.p75 }, "rgba(59, 130, 246, 0.4)")
+	band(func(bk smokepingBucket) time.Duration { return bk.min }, func(bk smokepingBucket) time.Duration { return bk.median }, "rgba(59, 130, 246, 0.6)")
+
+	// Median line
+	if dataBuckets > 0 {
+		b.WriteString(`<path d="`)
+		move := true
+		for bi, bk := range buckets {
+			if !bk.hasData {
+				continue
+			}
+			if move || bk.afterGap {
+				b.WriteByte('M')
+				move = false
+			} else {
+				b.WriteString(" L")
+			}
+			b.point(xAt(bi), yAt(bk.median))
+		}
+		b.WriteString(`" fill="none" stroke="#3b82f6" stroke-width="1"/>`)
+	}
+
+	// Packet loss markers (red dots)
+	for bi, bk := range buckets {
+		if bk.hasFailure {
+			b.WriteString(`<circle cx="`)
+			b.float(xAt(bi))
+			b.WriteString(`" cy="`)
+			b.float(float64(paddingY + chartHeight - 2))
+			b.WriteString(`" r="1.5" fill="#ef4444"/>`)
+		}
+	}
+
+	writeGapMarkers(&b, history, paddingX, paddingY, chartWidth, chartHeight)
+	writeAnnotationMarkers(&b, history, notes, paddingX, paddingY, chartWidth, chartHeight)
+
+	b.WriteString(`</svg>`)
+	return template.HTML(b.String())
+}
+
+// writeGapMarkers draws a dashed line before each data point recorded
+// after a stretch with no data, such as while the monitor slept. Charts
+// are laid out by data point, so the stretch itself takes no room.
+func writeGapMarkers(b *svgBuilder, history []state.CheckDataPoint, paddingX, paddingY, chartWidth, chartHeight int) {
+	n := len(history)
+	for i, dp := range history {
+		if dp.Gap <= 0 {
+			continue
+		}
+		x := float64(paddingX) + float64(chartWidth)*float64(i)/float64(n)
+		fmt.Fprintf(b, `<line x1="%.1f" y1="%d" x2="%.1f" y2="%d" stroke="#64748b" stroke-width="1" stroke-dasharray="2 2"><title>No data for %s</title></line>`,
+			x, paddingY, x, paddingY+chartHeight, formatMeanTime(dp.Gap))
+	}
+}
+
+// timingCache holds rendered timing charts, as smokepingCache does
+var timingCache = chartCache{charts: make(map[smokepingKey]template.HTML)}
+
+// timingPhases are the segments of a timing chart's bars, bottom up, with
+// what each takes from a data point
+var timingPhases = []struct {
+	name  string
+	color string
+	of    func(checks.HTTPTiming) time.Duration
+}{
+	{"DNS", "#a855f7", func(t checks.HTTPTiming) time.Duration { return t.DNS }},
+	{"connect", "#f59e0b", func(t checks.HTTPTiming) time.Duration { return t.Connect }},
+	{"TLS", "#22c55e", func(t checks.HTTPTiming) time.Duration { return t.TLS }},
+	{"first byte", "#3b82f6", func(t checks.HTTPTiming) time.Duration { return t.TTFB }},
+}
+
+// timingOtherColor marks the rest of the latency, e.g. following redirects
+const timingOtherColor = "#475569"
+
+// generateTimingChartSVG creates a stacked bar chart of where the time of an
+// http check's probes went, or nothing if none were timed
+func generateTimingChartSVG(history []state.CheckDataPoint, width, height int, loc *time.Location) template.HTML {
+	if !slices.ContainsFunc(history, func(dp state.CheckDataPoint) bool { return !dp.Timing.IsZero() }) {
+		return ""
+	}
+	key, ok := newSmokepingKey(history, nil, width, height, loc)
+	if !ok {
+		return renderTimingChartSVG(history, width, height, loc)
+	}
+	if svg, ok := timingCache.get(key); ok {
+		return svg
+	}
+	svg := renderTimingChartSVG(history, width, height, loc)
+	timingCache.put(key, svg)
+	return svg
+}
+
+func renderTimingChartSVG(history []state.CheckDataPoint, width, height int, loc *time.Location) template.HTML {
+	paddingX := 35
+	paddingY := 15
+	chartWidth := width - 2*paddingX
+	chartHeight := height - 2*paddingY
+
+	bucketCount := min(max(chartWidth/3, 1), len(history))
+
+	// Each bucket's bar is the average of its timed points
+	type bar struct {
+		phases  [4]time.Duration
+		other   time.Duration
+		latency time.Duration
+		timed   bool
+	}
+	bars := make([]bar, bucketCount)
+	maxLatency := time.Duration(1)
+	for bi := range bars {
+		start := bi * len(history) / bucketCount
+		end := (bi + 1) * len(history) / bucketCount
+		var n time.Duration
+		for _, dp := range history[start:end] {
+			if dp.Timing.IsZero() {
+				continue
+			}
+			for p, phase := range timingPhases {
+				bars[bi].phases[p] += phase.of(dp.Timing)
+			}
+			bars[bi].latency += max(dp.Latency, dp.Timing.Total())
+			n++
+		}
+		if n == 0 {
+			continue
+		}
+		bk := &bars[bi]
+		bk.timed = true
+		var total time.Duration
+		for p := range bk.phases {
+			bk.phases[p] /= n
+			total += bk.phases[p]
+		}
+		bk.latency /= n
+		bk.other = bk.latency - total
+		maxLatency = max(maxLatency, bk.latency)
+	}
+	maxLatency = maxLatency * 6 / 5
+	if maxLatency < 100*time.Microsecond {
+		maxLatency = 100 * time.Microsecond
+	}
+
+	var b svgBuilder
+	b.Grow(2048 + bucketCount*400)
+	fmt.Fprintf(&b, `<svg width="%d" height="%d" viewBox="0 0 %d %d" class="timing-chart">`, width, height, width, height)
+	fmt.Fprintf(&b, `<rect x="0" y="0" width="%d" height="%d" fill="#0f172a"/>`, width, height)
+
+	gridLines := 4
+	for i := 0; i <= gridLines; i++ {
+		y := paddingY + i*chartHeight/gridLines
+		latencyVal := maxLatency - time.Duration(i)*maxLatency/time.Duration(gridLines)
+		fmt.Fprintf(&b, `<line x1="%d" y1="%d" x2="%d" y2="%d" stroke="#1e293b" stroke-width="0.5"/>`, paddingX, y, paddingX+chartWidth, y)
+		fmt.Fprintf(&b, `<text x="%d" y="%d" text-anchor="end" fill="#64748b" font-size="7">%s</text>`, paddingX-2, y+2, checks.FormatLatency(latencyVal))
+	}
+
+	first := history[0].Timestamp.In(loc)
+	last := history[len(history)-1].Timestamp.In(loc)
+	fmt.Fprintf(&b, `<text x="%d" y="%d" text-anchor="start" fill="#64748b" font-size="7" data-datetime="%s" data-layout="hm">%s</text>`,
+		paddingX, height-2, first.Format(time.RFC3339), first.Format(timeLayouts["hm"]))
+	fmt.Fprintf(&b, `<text x="%d" y="%d" text-anchor="end" fill="#64748b" font-size="7" data-datetime="%s" data-layout="hm">%s</text>`,
+		paddingX+chartWidth, height-2, last.Format(time.RFC3339), last.Format(timeLayouts["hm"]))
+
+	bucketWidth := float64(chartWidth) / float64(bucketCount)
+	barWidth := max(bucketWidth-1, 1)
+	scale := float64(chartHeight) / float64(maxLatency)
+	segment := func(x, y, h float64, color string) {
+		if h <= 0 {
+			return
+		}
+		b.WriteString(`<rect x="`)
+		b.float(x)
+		b.WriteString(`" y="`)
+		b.float(y)
+		b.WriteString(`" width="`)
+		b.float(barWidth)
+		b.WriteString(`" height="`)
+		b.float(h)
+		b.WriteString(`" fill="`)
+		b.WriteString(color)
+		b.WriteString(`"/>`)
+	}
+	for bi, bk := range bars {
+		if !bk.timed {
+			continue
+		}
+		x := float64(paddingX) + float64(bi)*bucketWidth
+		y := float64(paddingY + chartHeight)
+		b.WriteString(`<g><title>`)
+		for p, phase := range timingPhases {
+			b.WriteString(phase.name)
+			b.WriteByte(' ')
+			b.WriteString(checks.FormatLatency(bk.phases[p]))
+			b.WriteString(", ")
+		}
+		b.WriteString("other ")
+		b.WriteString(checks.FormatLatency(bk.other))
+		b.WriteString(`</title>`)
+		for p, phase := range timingPhases {
+			h := float64(bk.phases[p]) * scale
+			y -= h
+			segment(x, y, h, phase.color)
+		}
+		if bk.other > 0 {
+			h := float64(bk.other) * scale
+			segment(x, y-h, h, timingOtherColor)
+		}
+		b.WriteString(`</g>`)
+	}
+
+	b.WriteString(`</svg>`)
+	return template.HTML(b.String())
+}
+
+// generateTimingLegend names the timing chart's colours, with the average
+// of each phase
+func generateTimingLegend(avg checks.HTTPTiming) template.HTML {
+	var b strings.Builder
+	b.WriteString(`<div class="timing-legend">`)
+	for _, phase := range timingPhases {
+		fmt.Fprintf(&b, `<span><i style="background:%s"></i>%s %s</span>`, phase.color, phase.name, checks.FormatLatency(phase.of(avg)))
+	}
+	fmt.Fprintf(&b, `<span><i style="background:%s"></i>other</span></div>`, timingOtherColor)
+	return template.HTML(b.String())
+}
+
+// lineChart describes a metric charted as a line over a check's history,
+// with the check's limits on it as dashed lines
+type lineChart struct {
+	class  string                           // CSS class of the <svg>
+	value  func(state.CheckDataPoint) int64 // The metric; 0 where it wasn't recorded
+	format func(int64) string               // Renders axis labels and limits
+	floor  int64                            // Smallest top of the y axis
+	limits []chartLimit
+}
+
+// chartLimit is a threshold drawn across a line chart; 0 is not drawn
+type chartLimit struct {
+	label string
+	value int64
+}
+
+// generateSizeChartSVG charts the response body sizes in an http check's
+// history, with its size limits as dashed lines, or nothing if no sizes were
+// recorded
+func generateSizeChartSVG(history []state.CheckDataPoint, minSize, maxSize int64, width, height int, loc *time.Location) template.HTML {
+	return renderLineChartSVG(history, lineChart{
+		class:  "size-chart",
+		value:  func(dp state.CheckDataPoint) int64 { return dp.Size },
+		format: checks.FormatBytes,
+		floor:  1024,
+		limits: []chartLimit{{"min", minSize}, {"max", maxSize}},
+	}, width, height, loc)
+}
+
+// generateJitterChartSVG charts the jitter of a ping check's runs, with its
+// jitter limit as a dashed line, or nothing if none was measured
+func generateJitterChartSVG(history []state.CheckDataPoint, maxJitter time.Duration, width, height int, loc *time.Location) template.HTML {
+	return renderLineChartSVG(history, lineChart{
+		class:  "jitter-chart",
+		value:  func(dp state.CheckDataPoint) int64 { return int64(dp.Jitter) },
+		format: func(v int64) string { return checks.FormatLatency(time.Duration(v)) },
+		floor:  int64(time.Millisecond),
+		limits: []chartLimit{{"max", int64(maxJitter)}},
+	}, width, height, loc)
+}
+
+// generateSpeedChartSVG charts the speeds a speedtest check measured, with
+// its speed limit as a dashed line, or nothing if none was measured
+func generateSpeedChartSVG(history []state.CheckDataPoint, minSpeed int64, width, height int, loc *time.Location) template.HTML {
+	return renderLineChartSVG(history, lineChart{
+		class:  "speed-chart",
+		value:  func(dp state.CheckDataPoint) int64 { return dp.Speed },
+		format: checks.FormatSpeed,
+		floor:  1_000_000,
+		limits: []chartLimit{{"min", minSpeed}},
+	}, width, height, loc)
+}
+
+// renderLineChartSVG draws lc's metric averaged over buckets of history,
+// leaving gaps where it wasn't recorded
+func renderLineChartSVG(history []state.CheckDataPoint, lc lineChart, width, height int, loc *time.Location) template.HTML {
+	if !slices.ContainsFunc(history, func(dp state.CheckDataPoint) bool { return lc.value(dp) > 0 }) {
+		return ""
+	}
+	paddingX := 35
+	paddingY := 15
+	chartWidth := width - 2*paddingX
+	chartHeight := height - 2*paddingY
+
+	bucketCount := min(max(chartWidth/3, 1), len(history))
+	values := make([]int64, bucketCount) // Average per bucket; 0 for none
+	afterGap := make([]bool, bucketCount)
+	var top int64
+	for _, limit := range lc.limits {
+		top = max(top, limit.value)
+	}
+	for bi := range values {
+		start := bi * len(history) / bucketCount
+		end := (bi + 1) * len(history) / bucketCount
+		var sum, n int64
+		for _, dp := range history[start:end] {
+			if v := lc.value(dp); v > 0 {
+				sum += v
+				n++
+			}
+			afterGap[bi] = afterGap[bi] || dp.Gap > 0
+		}
+		if n > 0 {
+			values[bi] = sum / n
+			top = max(top, values[bi])
+		}
+	}
+	top = max(top*6/5, lc.floor)
+
+	var b svgBuilder
+	b.Grow(2048 + bucketCount*12)
+	fmt.Fprintf(&b, `<svg width="%d" height="%d" viewBox="0 0 %d %d" class="%s">`, width, height, width, height, lc.class)
+	fmt.Fprintf(&b, `<rect x="0" y="0" width="%d" height="%d" fill="#0f172a"/>`, width, height)
+
+	gridLines := 4
+	for i := 0; i <= gridLines; i++ {
+		y := paddingY + i*chartHeight/gridLines
+		fmt.Fprintf(&b, `<line x1="%d" y1="%d" x2="%d" y2="%d" stroke="#1e293b" stroke-width="0.5"/>`, paddingX, y, paddingX+chartWidth, y)
+		fmt.Fprintf(&b, `<text x="%d" y="%d" text-anchor="end" fill="#64748b" font-size="7">%s</text>`, paddingX-2, y+2, lc.format(top-int64(i)*top/int64(gridLines)))
+	}
+
+	first := history[0].Timestamp.In(loc)
+	last := history[len(history)-1].Timestamp.In(loc)
+	fmt.Fprintf(&b, `<text x="%d" y="%d" text-anchor="start" fill="#64748b" font-size="7" data-datetime="%s" data-layout="hm">%s</text>`,
+		paddingX, height-2, first.Format(time.RFC3339), first.Format(timeLayouts["hm"]))
+	fmt.Fprintf(&b, `<text x="%d" y="%d" text-anchor="end" fill="#64748b" font-size="7" data-datetime="%s" data-layout="hm">%s</text>`,
+		paddingX+chartWidth, height-2, last.Format(time.RFC3339), last.Format(timeLayouts["hm"]))
+
+	yAt := func(v int64) float64 {
+		return float64(paddingY) + float64(chartHeight)*(1-float64(v)/float64(top))
+	}
+	for _, limit := range lc.limits {
+		if limit.value <= 0 {
+			continue
+		}
+		y := yAt(limit.value)
+		fmt.Fprintf(&b, `<line x1="%d" y1="%.1f" x2="%d" y2="%.1f" stroke="#f59e0b" stroke-width="0.75" stroke-dasharray="3 2"><title>%s %s</title></line>`,
+			paddingX, y, paddingX+chartWidth, y, limit.label, lc.format(limit.value))
+	}
+
+	bucketWidth := float64(chartWidth) / float64(bucketCount)
+	b.WriteString(`<path d="`)
+	move := true
+	for bi, v := range values {
+		if v == 0 {
+			move = true
+			continue
+		}
+		if move || afterGap[bi] {
+			b.WriteByte('M')
+			move = false
+		} else {
+			b.WriteString(" L")
+		}
+		b.point(float64(paddingX)+float64(bi)*bucketWidth+bucketWidth/2, yAt(v))
+	}
+	b.WriteString(`" fill="none" stroke="#06b6d4" stroke-width="1.25"/>`)
+	writeGapMarkers(&b, history, paddingX, paddingY, chartWidth, chartHeight)
+
+	b.WriteString(`</svg>`)
+	return template.HTML(b.String())
+}
+
+// trendDayLabel renders a trend day, e.g. "Oct 18"
+func trendDayLabel(day string) string {
+	t, err := time.Parse("2006-01-02", day)
+	if err != nil {
+		return day
+	}
+	return t.Format("Jan 2")
+}
+
+// writeTrendDayLabels labels the first and last day under a trend chart
+func writeTrendDayLabels(b *svgBuilder, days []state.DailyTrend, paddingX, chartWidth, height int) {
+	fmt.Fprintf(b, `<text x="%d" y="%d" text-anchor="start" fill="#64748b" font-size="7">%s</text>`,
+		paddingX, height-2, trendDayLabel(days[0].Day))
+	fmt.Fprintf(b, `<text x="%d" y="%d" text-anchor="end" fill="#64748b" font-size="7">%s</text>`,
+		paddingX+chartWidth, height-2, trendDayLabel(days[len(days)-1].Day))
+}
+
+// generateTrendUptimeSVG draws a bar for each day's uptime, coloured as
+// the uptime bars are, with a gap for days without runs
+func generateTrendUptimeSVG(days []state.DailyTrend, width, height int) template.HTML {
+	if len(days) == 0 {
+		return ""
+	}
+	paddingX := 35
+	paddingY := 10
+	chartWidth := width - 2*paddingX
+	chartHeight := height - 2*paddingY
+
+	var b svgBuilder
+	b.Grow(1024 + len(days)*160)
+	fmt.Fprintf(&b, `<svg width="%d" height="%d" viewBox="0 0 %d %d" class="trend-chart">`, width, height, width, height)
+	fmt.Fprintf(&b, `<rect x="0" y="0" width="%d" height="%d" fill="#0f172a"/>`, width, height)
+	for _, pct := range []int{100, 50, 0} {
+		y := paddingY + (100-pct)*chartHeight/100
+		fmt.Fprintf(&b, `<line x1="%d" y1="%d" x2="%d" y2="%d" stroke="#1e293b" stroke-width="0.5"/>`, paddingX, y, paddingX+chartWidth, y)
+		fmt.Fprintf(&b, `<text x="%d" y="%d" text-anchor="end" fill="#64748b" font-size="7">%d%%</text>`, paddingX-2, y+2, pct)
+	}
+	writeTrendDayLabels(&b, days, paddingX, chartWidth, height)
+
+	slot := float64(chartWidth) / float64(len(days))
+	barWidth := max(slot*0.8, 0.5)
+	for i, d := range days {
+		if d.Runs == 0 {
+			continue
+		}
+		uptime := d.Uptime()
+		h := max(float64(chartHeight)*uptime/100, 1)
+		fmt.Fprintf(&b, `<rect x="%.1f" y="%.1f" width="%.1f" height="%.1f" fill="%s"><title>%s: %.2f%% of %d runs</title></rect>`,
+			float64(paddingX)+float64(i)*slot+(slot-barWidth)/2, float64(paddingY+chartHeight)-h, barWidth, h,
+			uptimeColor(uptime), trendDayLabel(d.Day), uptime, d.Runs)
+	}
+	b.WriteString(`</svg>`)
+	return template.HTML(b.String())
+}
+
+// generateTrendLatencySVG draws each day's latency percentiles in the
+// smokeping chart's bands, with the median as a line across the day
+func generateTrendLatencySVG(days []state.DailyTrend, width, height int) template.HTML {
+	var maxLatency time.Duration
+	for _, d := range days {
+		maxLatency = max(maxLatency, d.Max)
+	}
+	if maxLatency == 0 {
+		return ""
+	}
+	maxLatency = max(maxLatency*6/5, 100*time.Microsecond)
+	paddingX := 35
+	paddingY := 15
+	chartWidth := width - 2*paddingX
+	chartHeight := height - 2*paddingY
+
+	var b svgBuilder
+	b.Grow(2048 + len(days)*400)
+	fmt.Fprintf(&b, `<svg width="%d" height="%d" viewBox="0 0 %d %d" class="trend-chart">`, width, height, width, height)
+	fmt.Fprintf(&b, `<rect x="0" y="0" width="%d" height="%d" fill="#0f172a"/>`, width, height)
+	gridLines := 4
+	for i := 0; i <= gridLines; i++ {
+		y := paddingY + i*chartHeight/gridLines
+		fmt.Fprintf(&b, `<line x1="%d" y1="%d" x2="%d" y2="%d" stroke="#1e293b" stroke-width="0.5"/>`, paddingX, y, paddingX+chartWidth, y)
+		fmt.Fprintf(&b, `<text x="%d" y="%d" text-anchor="end" fill="#64748b" font-size="7">%s</text>`, paddingX-2, y+2, checks.FormatLatency(maxLatency-time.Duration(i)*maxLatency/time.Duration(gridLines)))
+	}
+	writeTrendDayLabels(&b, days, paddingX, chartWidth, height)
+
+	yAt := func(d time.Duration) float64 {
+		return float64(paddingY) + float64(chartHeight)*(1-float64(d)/float64(maxLatency))
+	}
+	slot := float64(chartWidth) / float64(len(days))
+	barWidth := max(slot*0.8, 0.5)
+	for i, d := range days {
+		if d.Max == 0 {
+			continue
+		}
+		x := float64(paddingX) + float64(i)*slot + (slot-barWidth)/2
+		fmt.Fprintf(&b, `<g><title>%s: median %s, p95 %s, min %s, max %s</title>`, trendDayLabel(d.Day),
+			checks.FormatLatency(d.Median), checks.FormatLatency(d.P95), checks.FormatLatency(d.Min), checks.FormatLatency(d.Max))
+		for _, band := range []struct {
+			top, bottom time.Duration
+			fill        string
+		}{
+			{d.Max, d.P95, "rgba(59, 130, 246, 0.1)"},
+			{d.P95, d.P75, "rgba(59, 130, 246, 0.2)"},
+			{d.P75, d.Median, "rgba(59, 130, 246, 0.4)"},
+			{d.Median, d.Min, "rgba(59, 130, 246, 0.6)"},
+		} {
+			top, bottom := yAt(band.top), yAt(band.bottom)
+			fmt.Fprintf(&b, `<rect x="%.1f" y="%.1f" width="%.1f" height="%.1f" fill="%s"/>`, x, top, barWidth, max(bottom-top, 0), band.fill)
+		}
+		y := yAt(d.Median)
+		fmt.Fprintf(&b, `<line x1="%.1f" y1="%.1f" x2="%.1f" y2="%.1f" stroke="#3b82f6" stroke-width="1"/></g>`, x, y, x+barWidth, y)
+	}
+	b.WriteString(`</svg>`)
+	return template.HTML(b.String())
+}
@@ -0,0 +1,65 @@
+package server
+
+import (
+	"testing"
+	"time"
+
+	"github.com/andrewsjg/simple-healthchecker/copilot/internal/state"
+)
+
+// chartHistory returns n data points a minute apart, numbered from seq as
+// a check would number them, with the odd failure
+func chartHistory(n int, seq uint64) []state.CheckDataPoint {
+	start := time.Date(2026, 10, 1, 0, 0, 0, 0, time.UTC)
+	history := make([]state.CheckDataPoint, n)
+	for i := range history {
+		history[i] = state.CheckDataPoint{
+			Timestamp: start.Add(time.Duration(i) * time.Minute),
+			OK:        i%97 != 0,
+			Latency:   time.Duration(5+i%40) * time.Millisecond,
+			Seq:       seq + uint64(i),
+		}
+	}
+	return history
+}
+
+func TestSmokepingChartCache(t *testing.T) {
+	history := chartHistory(300, 5_000_000)
+	fresh := renderSmokepingChartSVG(history, nil, 800, 200, time.UTC)
+	if got := generateSmokepingChartSVG(history, nil, 800, 200, time.UTC); got != fresh {
+		t.Fatal("first chart differs from a fresh render")
+	}
+	if got := generateSmokepingChartSVG(history, nil, 800, 200, time.UTC); got != fresh {
+		t.Error("cached chart differs from a fresh render")
+	}
+	// One more point makes it a different chart
+	longer := append(history, chartHistory(1, 5_000_300)...)
+	if got := generateSmokepingChartSVG(longer, nil, 800, 200, time.UTC); got == fresh {
+		t.Error("a chart with a new point came from the cache")
+	}
+	if got := generateSmokepingChartSVG(history, nil, 400, 200, time.UTC); got == fresh {
+		t.Error("a narrower chart came from the cache")
+	}
+}
+
+func BenchmarkSmokepingChart(b *testing.B) {
+	history := chartHistory(1000, 1_000_000)
+	notes := []state.Annotation{{ID: 1, Start: history[100].Timestamp, End: history[160].Timestamp, Note: "switch firmware upgrade"}}
+
+	b.Run("cold", func(b *testing.B) {
+		b.ReportAllocs()
+		for b.Loop() {
+			smokepingCache.mu.Lock()
+			clear(smokepingCache.charts)
+			smokepingCache.mu.Unlock()
+			generateSmokepingChartSVG(history, notes, 800, 200, time.UTC)
+		}
+	})
+	b.Run("cached", func(b *testing.B) {
+		generateSmokepingChartSVG(history, notes, 800, 200, time.UTC)
+		b.ReportAllocs()
+		for b.Loop() {
+			generateSmokepingChartSVG(history, notes, 800, 200, time.UTC)
+		}
+	})
+}
@@ -0,0 +1,229 @@
+package server
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"html/template"
+	"io"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/andrewsjg/simple-healthchecker/copilot/internal/config"
+	"github.com/andrewsjg/simple-healthchecker/copilot/internal/importer"
+	"github.com/andrewsjg/simple-healthchecker/copilot/internal/state"
+)
+
+// maxImportSize caps uploaded import files; real configs are far smaller
+const maxImportSize = 10 << 20
+
+// previewTTL is how long a previewed change can still be applied
+const previewTTL = 15 * time.Minute
+
+// pendingChange is a previewed change to the hosts awaiting confirmation
+type pendingChange struct {
+	replace bool // Replace the local hosts rather than add to them
+	hosts   []config.Host
+	diff    state.ConfigDiff
+	source  string   // What the hosts came from, e.g. "Gatus" or the file name
+	notes   []string // Import notes, shown again once applied
+	expires time.Time
+}
+
+// pendingChanges holds previews by a random token until they are applied
+// or expire
+type pendingChanges struct {
+	mu sync.Mutex
+	m  map[string]pendingChange
+}
+
+func (p *pendingChanges) put(c pendingChange) string {
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+	token := hex.EncodeToString(b)
+	now := time.Now()
+	c.expires = now.Add(previewTTL)
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.m == nil {
+		p.m = make(map[string]pendingChange)
+	}
+	for t, old := range p.m {
+		if now.After(old.expires) {
+			delete(p.m, t)
+		}
+	}
+	p.m[token] = c
+	return token
+}
+
+// take removes and returns the change for token, if it hasn't expired
+func (p *pendingChanges) take(token string) (pendingChange, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	c, ok := p.m[token]
+	delete(p.m, token)
+	return c, ok && time.Now().Before(c.expires)
+}
+
+// configPreviewView is the data for config_preview.html
+type configPreviewView struct {
+	Token   string
+	Replace bool
+	Source  string
+	Diff    state.ConfigDiff
+	Notes   []string
+}
+
+// uploadedFile reads the "file" field of a multipart form, writing a 422
+// fragment and returning false if it can't
+func uploadedFile(w http.ResponseWriter, r *http.Request) ([]byte, string, bool) {
+	r.Body = http.MaxBytesReader(w, r.Body, maxImportSize)
+	file, header, err := r.FormFile("file")
+	if err != nil {
+		w.WriteHeader(422)
+		_, _ = w.Write([]byte(`<div class="alert alert-error">Choose a file to import.</div>`))
+		return nil, "", false
+	}
+	defer file.Close()
+	data, err := io.ReadAll(file)
+	if err != nil {
+		w.WriteHeader(422)
+		_, _ = w.Write([]byte(fmt.Sprintf(`<div class="alert alert-error">Error reading file: %s</div>`, template.HTMLEscapeString(err.Error()))))
+		return nil, "", false
+	}
+	return data, header.Filename, true
+}
+
+// handleImport previews the hosts an Uptime Kuma backup or Gatus config
+// would add; nothing changes until the preview is applied
+func (s *Server) handleImport(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.WriteHeader(405)
+		return
+	}
+	data, _, ok := uploadedFile(w, r)
+	if !ok {
+		return
+	}
+	res, err := importer.Parse(data)
+	if err == nil && len(res.Hosts) == 0 {
+		err = fmt.Errorf("no monitors could be imported")
+	}
+	if err != nil {
+		w.WriteHeader(422)
+		_, _ = w.Write([]byte(fmt.Sprintf(`<div class="alert alert-error">%s</div>`, template.HTMLEscapeString(err.Error()))))
+		return
+	}
+	diff := s.st.PreviewImport(res.Hosts)
+	if diff.Empty() {
+		w.WriteHeader(422)
+		_, _ = w.Write([]byte(fmt.Sprintf(`<div class="alert alert-error">Nothing to import from %s: every host name is already in use (%s).</div>`,
+			template.HTMLEscapeString(res.Source), template.HTMLEscapeString(strings.Join(diff.Skipped, ", ")))))
+		return
+	}
+	token := s.pending.put(pendingChange{hosts: res.Hosts, diff: diff, source: res.Source, notes: res.Notes})
+	_ = s.tpl.ExecuteTemplate(w, "config_preview.html", configPreviewView{Token: token, Source: res.Source, Diff: diff, Notes: res.Notes})
+}
+
+// handleReplaceHosts previews replacing the local hosts with those in an
+// uploaded POKE443 config; nothing changes until the preview is applied
+func (s *Server) handleReplaceHosts(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.WriteHeader(405)
+		return
+	}
+	data, name, ok := uploadedFile(w, r)
+	if !ok {
+		return
+	}
+	ext := strings.ToLower(filepath.Ext(name))
+	if ext != ".toml" && ext != ".yml" {
+		ext = ".yaml"
+	}
+	hosts, err := config.ParseHosts(data, ext)
+	if err == nil && (len(hosts) == 0 || len(hosts) == 1 && hosts[0].Name == "") {
+		err = fmt.Errorf("no hosts found in %s", name)
+	}
+	if err != nil {
+		w.WriteHeader(422)
+		_, _ = w.Write([]byte(fmt.Sprintf(`<div class="alert alert-error">%s</div>`, template.HTMLEscapeString(err.Error()))))
+		return
+	}
+	diff := s.st.PreviewReplace(hosts)
+	if diff.Empty() {
+		_, _ = w.Write([]byte(`<div class="alert alert-success">No changes: the hosts in the file match the running config.</div>`))
+		return
+	}
+	token := s.pending.put(pendingChange{replace: true, hosts: hosts, diff: diff, source: name})
+	_ = s.tpl.ExecuteTemplate(w, "config_preview.html", configPreviewView{Token: token, Replace: true, Source: name, Diff: diff})
+}
+
+// handleApplyChange applies a previewed import or replacement, refusing if
+// the hosts were edited after the preview was made
+func (s *Server) handleApplyChange(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.WriteHeader(405)
+		return
+	}
+	c, ok := s.pending.take(r.FormValue("token"))
+	if !ok {
+		w.WriteHeader(422)
+		_, _ = w.Write([]byte(`<div class="alert alert-error">This preview has expired or was already applied. Upload the file again.</div>`))
+		return
+	}
+	if !c.replace && s.st.HostsFingerprint() != c.diff.Fingerprint {
+		s.changedSincePreview(w)
+		return
+	}
+	if c.replace {
+		diff, err := s.changes(r).ReplaceHosts(c.hosts, c.diff.Fingerprint)
+		if errors.Is(err, state.ErrConfigChanged) {
+			s.changedSincePreview(w)
+			return
+		}
+		if err != nil {
+			w.WriteHeader(500)
+			_, _ = w.Write([]byte(fmt.Sprintf(`<div class="alert alert-error">Error saving config: %s</div>`, template.HTMLEscapeString(err.Error()))))
+			return
+		}
+		msg := fmt.Sprintf("Applied %s: %d hosts added, %d removed, %d changed.", c.source, len(diff.Added), len(diff.Removed), len(diff.Changed))
+		_, _ = w.Write([]byte(fmt.Sprintf(`<div class="alert alert-success">%s</div>`, template.HTMLEscapeString(msg))))
+		return
+	}
+
+	added, skipped, err := s.changes(r).ImportHosts(c.hosts)
+	if err != nil {
+		w.WriteHeader(500)
+		_, _ = w.Write([]byte(fmt.Sprintf(`<div class="alert alert-error">Error saving config: %s</div>`, template.HTMLEscapeString(err.Error()))))
+		return
+	}
+	class := "alert-success"
+	if len(added) == 0 {
+		class = "alert-error"
+	}
+	msg := fmt.Sprintf("Imported %d hosts from %s.", len(added), c.source)
+	if len(skipped) > 0 {
+		msg += fmt.Sprintf(" Skipped %d whose names are already in use: %s.", len(skipped), strings.Join(skipped, ", "))
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, `<div class="alert %s">%s`, class, template.HTMLEscapeString(msg))
+	if len(c.notes) > 0 {
+		b.WriteString("<ul>")
+		for _, note := range c.notes {
+			fmt.Fprintf(&b, "<li>%s</li>", template.HTMLEscapeString(note))
+		}
+		b.WriteString("</ul>")
+	}
+	b.WriteString("</div>")
+	_, _ = w.Write([]byte(b.String()))
+}
+
+func (s *Server) changedSincePreview(w http.ResponseWriter) {
+	w.WriteHeader(409)
+	_, _ = w.Write([]byte(`<div class="alert alert-error">The hosts were edited after this preview was made, so it wasn't applied. Upload the file again to see the changes against the current config.</div>`))
+}
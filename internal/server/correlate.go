@@ -0,0 +1,49 @@
+package server
+
+import (
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/andrewsjg/simple-healthchecker/copilot/internal/state"
+)
+
+// correlationWindow is a window the correlated outages section offers
+type correlationWindow struct {
+	Duration time.Duration
+	Label    string
+}
+
+var correlationWindows = []correlationWindow{
+	{time.Minute, "1 min"},
+	{state.DefaultCorrelationWindow, "2 min"},
+	{5 * time.Minute, "5 min"},
+	{15 * time.Minute, "15 min"},
+}
+
+// maxOutageClusters is how many clusters the section lists
+const maxOutageClusters = 10
+
+// correlatedView is the data for correlated_outages.html
+type correlatedView struct {
+	Window   time.Duration
+	Windows  []correlationWindow
+	Clusters []state.OutageCluster
+}
+
+// correlatedOutages groups the event log's outages by window, falling back
+// to the default for windows the section doesn't offer
+func (s *Server) correlatedOutages(window string) correlatedView {
+	w, _ := time.ParseDuration(window)
+	if !slices.ContainsFunc(correlationWindows, func(cw correlationWindow) bool { return cw.Duration == w }) {
+		w = state.DefaultCorrelationWindow
+	}
+	return correlatedView{Window: w, Windows: correlationWindows, Clusters: s.st.CorrelatedOutages(w, maxOutageClusters)}
+}
+
+// handleCorrelatedOutages redraws the correlated outages section for
+// another window
+func (s *Server) handleCorrelatedOutages(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	_ = s.tpl.ExecuteTemplate(w, "correlated_outages.html", s.correlatedOutages(r.FormValue("window")))
+}
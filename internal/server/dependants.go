@@ -0,0 +1,30 @@
+package server
+
+import (
+	"net/http"
+	"slices"
+
+	"github.com/andrewsjg/simple-healthchecker/copilot/internal/state"
+)
+
+// deleteImpact is the data for delete_impact.html
+type deleteImpact struct {
+	Host       string
+	Idx        int // The check being deleted, or -1 for the whole host
+	Dependants []state.Dependant
+	CanClear   bool // False if clearing references would leave a composite with no members
+}
+
+// renderDeleteImpact asks what to do with the checks that depend on the host
+// or check being deleted
+func (s *Server) renderDeleteImpact(w http.ResponseWriter, host string, idx int) {
+	deps := s.st.Dependants(host, idx)
+	data := deleteImpact{
+		Host:       host,
+		Idx:        idx,
+		Dependants: deps,
+		CanClear:   !slices.ContainsFunc(deps, func(d state.Dependant) bool { return d.Emptied }),
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	_ = s.tpl.ExecuteTemplate(w, "delete_impact.html", data)
+}
@@ -0,0 +1,47 @@
+package server
+
+import (
+	"net/http"
+	"net/url"
+
+	"github.com/andrewsjg/simple-healthchecker/copilot/internal/state"
+)
+
+// embedCard is the data for embed.html
+type embedCard struct {
+	state.HostStatus
+	Status string // One of the state.Host* statuses
+	Light  bool   // ?theme=light, for pages with a light background
+}
+
+// embedURL is the absolute URL of hostName's status widget, including its
+// token if embeds are signed
+func (s *Server) embedURL(r *http.Request, hostName string) string {
+	scheme := "http"
+	if r.TLS != nil || r.Header.Get("X-Forwarded-Proto") == "https" {
+		scheme = "https"
+	}
+	u := url.URL{Scheme: scheme, Host: r.Host, Path: "/embed/" + hostName}
+	if token := s.st.EmbedToken(hostName); token != "" {
+		u.RawQuery = url.Values{"token": {token}}.Encode()
+	}
+	return u.String()
+}
+
+// handleEmbed renders one host's card as a standalone page for iframing into
+// wikis and other dashboards. The token is checked before the host is looked
+// up, so a refused request doesn't reveal whether the host exists.
+func (s *Server) handleEmbed(w http.ResponseWriter, r *http.Request) {
+	name := r.PathValue("host")
+	if !s.st.CheckEmbedToken(name, r.FormValue("token")) {
+		http.Error(w, "invalid or missing embed token", http.StatusForbidden)
+		return
+	}
+	hs, ok := s.st.GetHost(name)
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	_ = s.tpl.ExecuteTemplate(w, "embed.html", embedCard{HostStatus: hs, Status: hs.Status(), Light: r.FormValue("theme") == "light"})
+}
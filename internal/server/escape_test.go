@@ -0,0 +1,82 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"html"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/andrewsjg/simple-healthchecker/copilot/internal/config"
+	"github.com/andrewsjg/simple-healthchecker/copilot/internal/state"
+)
+
+// hostileHost is a host name that breaks out of a single-quoted attribute
+// and opens a script if it isn't escaped
+const hostileHost = `x'"><script>alert(1)</script>`
+
+var hxValsAttr = regexp.MustCompile(`hx-vals='([^']*)'`)
+
+func TestHxVals(t *testing.T) {
+	got, err := hxVals("host", hostileHost, "idx", 2)
+	if err != nil {
+		t.Fatalf("hxVals: %v", err)
+	}
+	var vals map[string]string
+	if err := json.Unmarshal([]byte(got), &vals); err != nil {
+		t.Fatalf("hxVals = %s, not JSON: %v", got, err)
+	}
+	if vals["host"] != hostileHost || vals["idx"] != "2" {
+		t.Errorf("hxVals = %q, want the host and idx back", vals)
+	}
+	if _, err := hxVals("host"); err == nil {
+		t.Error("hxVals with an odd number of arguments didn't fail")
+	}
+}
+
+func TestTemplatesEscapeHostNames(t *testing.T) {
+	s := New(state.New(&config.Config{}))
+	tests := []struct {
+		template string
+		data     any
+	}{
+		{"toggle_button.html", struct {
+			Host    string
+			Idx     int
+			Enabled bool
+		}{hostileHost, 0, true}},
+		{"toggle_button.html", struct {
+			Host    string
+			Idx     int
+			Enabled bool
+		}{hostileHost, 1, false}},
+		{"hcurl_section.html", struct{ Host, URL, Error string }{hostileHost, "", ""}},
+		{"hcurl_section.html", struct{ Host, URL, Error string }{hostileHost, hostileHost, "invalid " + hostileHost}},
+	}
+	for _, tt := range tests {
+		var buf bytes.Buffer
+		if err := s.tpl.ExecuteTemplate(&buf, tt.template, tt.data); err != nil {
+			t.Fatalf("%s: %v", tt.template, err)
+		}
+		out := buf.String()
+		if strings.Contains(out, "<script>") {
+			t.Errorf("%s left the script tag in place:\n%s", tt.template, out)
+		}
+		attrs := hxValsAttr.FindAllStringSubmatch(out, -1)
+		if len(attrs) == 0 {
+			t.Fatalf("%s has no hx-vals:\n%s", tt.template, out)
+		}
+		for _, m := range attrs {
+			// The browser unescapes the attribute before htmx parses it
+			var vals map[string]string
+			if err := json.Unmarshal([]byte(html.UnescapeString(m[1])), &vals); err != nil {
+				t.Errorf("%s: hx-vals %s isn't JSON once unescaped: %v", tt.template, m[1], err)
+				continue
+			}
+			if vals["host"] != hostileHost {
+				t.Errorf("%s: hx-vals host = %q, want %q", tt.template, vals["host"], hostileHost)
+			}
+		}
+	}
+}
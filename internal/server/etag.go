@@ -0,0 +1,47 @@
+package server
+
+import (
+	"fmt"
+	"hash/fnv"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+)
+
+// bootID tells apart versions from before and after a restart, when the
+// state's version counts up from zero again and templates may have changed
+var bootID = time.Now().UnixNano()
+
+// notModified tags a fragment polled by htmx with an ETag made from the
+// state version and the request parameters it depends on, and reports
+// whether the browser already has that fragment. If it does, a 304 has been
+// sent and the caller should skip rendering. Browsers revalidate on every
+// poll and hand htmx the copy they hold, so nothing changes client side.
+// The user's role and host tags go into the tag too, since they change what
+// a fragment shows, and shared caches are told to keep out.
+func (s *Server) notModified(w http.ResponseWriter, r *http.Request, params ...string) bool {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		return false
+	}
+	h := fnv.New64a()
+	fmt.Fprintf(h, "%d/%d", bootID, s.st.Version())
+	if sess, ok := r.Context().Value(sessionKey{}).(session); ok {
+		tags := slices.Clone(sess.Tags)
+		slices.Sort(tags)
+		fmt.Fprintf(h, "\x00role=%s\x00tags=%s", sess.Role, strings.Join(tags, "\x00"))
+	}
+	for _, p := range params {
+		fmt.Fprintf(h, "\x00%s=%s", p, r.FormValue(p))
+	}
+	etag := fmt.Sprintf(`"%x"`, h.Sum64())
+	w.Header().Set("ETag", etag)
+	w.Header().Set("Cache-Control", "private, no-cache")
+	w.Header().Add("Vary", "Cookie, Authorization")
+	// Compressed responses carry the tag as weak, which the browser echoes
+	if inm := r.Header.Get("If-None-Match"); inm == etag || inm == "W/"+etag {
+		w.WriteHeader(http.StatusNotModified)
+		return true
+	}
+	return false
+}
@@ -0,0 +1,163 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/websocket"
+
+	"github.com/andrewsjg/simple-healthchecker/copilot/internal/state"
+)
+
+// streamHeartbeat is how often an idle stream shows it is still alive, so
+// proxies and clients don't time it out
+const streamHeartbeat = 30 * time.Second
+
+// streamEvent is one line (or WebSocket message) of the event stream
+type streamEvent struct {
+	SchemaVersion int `json:"schema_version,omitempty"` // See the schema package
+
+	Time      time.Time `json:"time"`
+	Type      string    `json:"type"` // "down", "recovered", "flapping", "ip_changed", "degraded", "latency_ok", "connectivity", "offline", "no_data" or "heartbeat"
+	Host      string    `json:"host,omitempty"`
+	CheckIdx  *int      `json:"check_idx,omitempty"` // Unset for events that aren't about one check
+	CheckID   string    `json:"check_id,omitempty"`
+	CheckName string    `json:"check_name,omitempty"`
+	CheckType string    `json:"check_type,omitempty"`
+	Message   string    `json:"message,omitempty"`
+	Downtime  float64   `json:"downtime_seconds,omitempty"` // For recoveries, and the length of a no_data gap
+	Blocked   []string  `json:"blocked,omitempty"`          // Dependent checks a failure blocks
+}
+
+func newStreamEvent(e state.Event, version int) streamEvent {
+	se := streamEvent{
+		SchemaVersion: version,
+		Time:          e.Timestamp,
+		Type:          e.EventType,
+		Host:          e.HostName,
+		CheckID:       e.CheckID,
+		CheckName:     e.CheckName,
+		CheckType:     string(e.CheckType),
+		Message:       e.Message,
+		Downtime:      e.Duration.Seconds(),
+		Blocked:       e.Blocked,
+	}
+	if e.CheckType != "" {
+		idx := e.CheckIdx
+		se.CheckIdx = &idx
+	}
+	return se
+}
+
+var streamUpgrader = websocket.Upgrader{}
+
+// handleEventStream streams every state change as it happens, for Node-RED,
+// n8n and similar flows without an MQTT broker. WebSocket requests get one
+// JSON message per event; anything else gets newline-delimited JSON. With
+// ?recent=N the last N events are sent first.
+func (s *Server) handleEventStream(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.WriteHeader(405)
+		return
+	}
+	if websocket.IsWebSocketUpgrade(r) {
+		s.streamWebSocket(w, r)
+		return
+	}
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming not supported", http.StatusInternalServerError)
+		return
+	}
+	backlog, events, cancel := state.SubscribeEvents(formInt(r, "recent", 0, 0, 500))
+	defer cancel()
+	version := s.st.PayloadSchemaVersion()
+
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no") // Stop nginx holding events back
+	enc := json.NewEncoder(w)
+	for _, e := range backlog {
+		_ = enc.Encode(newStreamEvent(e, version))
+	}
+	flusher.Flush()
+
+	heartbeat := time.NewTicker(streamHeartbeat)
+	defer heartbeat.Stop()
+	for {
+		var err error
+		select {
+		case e, ok := <-events:
+			if !ok {
+				return
+			}
+			err = enc.Encode(newStreamEvent(e, version))
+		case now := <-heartbeat.C:
+			err = enc.Encode(streamEvent{SchemaVersion: version, Time: now, Type: "heartbeat"})
+		case <-r.Context().Done():
+			return
+		case <-s.done:
+			return
+		}
+		if err != nil {
+			return
+		}
+		flusher.Flush()
+	}
+}
+
+// streamWebSocket is handleEventStream over a WebSocket. Heartbeats are
+// WebSocket pings, so every message is an event.
+func (s *Server) streamWebSocket(w http.ResponseWriter, r *http.Request) {
+	conn, err := streamUpgrader.Upgrade(w, r, nil)
+	if err != nil {
+		// Upgrade has already replied with an error
+		return
+	}
+	defer conn.Close()
+	backlog, events, cancel := state.SubscribeEvents(formInt(r, "recent", 0, 0, 500))
+	defer cancel()
+	version := s.st.PayloadSchemaVersion()
+
+	// Nothing is expected from the client, but reading is what handles its
+	// close frames and notices it going away
+	closed := make(chan struct{})
+	go func() {
+		defer close(closed)
+		for {
+			if _, _, err := conn.NextReader(); err != nil {
+				return
+			}
+		}
+	}()
+
+	for _, e := range backlog {
+		if conn.WriteJSON(newStreamEvent(e, version)) != nil {
+			return
+		}
+	}
+	heartbeat := time.NewTicker(streamHeartbeat)
+	defer heartbeat.Stop()
+	for {
+		var err error
+		select {
+		case e, ok := <-events:
+			if !ok {
+				_ = conn.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseTryAgainLater, "fell behind"))
+				return
+			}
+			err = conn.WriteJSON(newStreamEvent(e, version))
+		case <-heartbeat.C:
+			err = conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(10*time.Second))
+		case <-closed:
+			return
+		case <-s.done:
+			_ = conn.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseGoingAway, "shutting down"))
+			return
+		}
+		if err != nil {
+			return
+		}
+	}
+}
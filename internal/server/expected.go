@@ -0,0 +1,62 @@
+package server
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// expectDown is the data for expect_down.html
+type expectDown struct {
+	Host      string
+	Idx       int
+	Until     time.Time // Zero when no expected downtime is set
+	Durations []string
+}
+
+func newExpectDown(host string, idx int, until time.Time) expectDown {
+	if !until.After(time.Now()) {
+		until = time.Time{}
+	}
+	return expectDown{Host: host, Idx: idx, Until: until, Durations: muteDurations}
+}
+
+// handleExpectDown sets or clears a check's expected downtime, for a
+// duration (0 clears it) or until a datetime-local time in the display
+// timezone
+func (s *Server) handleExpectDown(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.WriteHeader(405)
+		return
+	}
+	host := r.FormValue("host")
+	idx, _ := strconv.Atoi(r.FormValue("idx"))
+
+	var until time.Time
+	if v := strings.TrimSpace(r.FormValue("until")); v != "" {
+		t, err := time.ParseInLocation("2006-01-02T15:04", v, s.st.DisplayLocation())
+		if err != nil {
+			w.WriteHeader(400)
+			_, _ = w.Write([]byte("invalid time"))
+			return
+		}
+		until = t
+	} else {
+		d, err := time.ParseDuration(r.FormValue("duration"))
+		if err != nil || d < 0 {
+			w.WriteHeader(400)
+			_, _ = w.Write([]byte("invalid duration"))
+			return
+		}
+		if d > 0 {
+			until = time.Now().Add(d)
+		}
+	}
+	if err := s.st.SetExpectedDown(host, idx, until); err != nil {
+		w.WriteHeader(409)
+		_, _ = w.Write([]byte(err.Error()))
+		return
+	}
+	_ = s.tpl.ExecuteTemplate(w, "expect_down.html", newExpectDown(host, idx, until))
+}
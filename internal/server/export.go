@@ -0,0 +1,124 @@
+package server
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/andrewsjg/simple-healthchecker/copilot/internal/state"
+)
+
+// exportOutage is one outage in the outages export
+type exportOutage struct {
+	Start     time.Time  `json:"start"`
+	End       *time.Time `json:"end,omitempty"` // Unset while ongoing
+	Host      string     `json:"host"`
+	CheckIdx  int        `json:"check_idx"`
+	CheckID   string     `json:"check_id,omitempty"`
+	CheckType string     `json:"check_type"`
+	Check     string     `json:"check"` // As the UI describes it
+	Downtime  float64    `json:"downtime_seconds,omitempty"`
+	NoData    float64    `json:"no_data_seconds,omitempty"` // Time within it with no data, e.g. while the monitor slept; not in Downtime
+	Ongoing   bool       `json:"ongoing"`
+	Message   string     `json:"message,omitempty"`
+	Blocked   int        `json:"blocked,omitempty"` // Dependent checks it blocked
+}
+
+// exportFormat is the format an export asks for: "csv" unless ?format=json
+func exportFormat(r *http.Request) string {
+	if r.FormValue("format") == "json" {
+		return "json"
+	}
+	return "csv"
+}
+
+// startExport sets the headers that save an export as a file named after
+// what it holds and when it was taken
+func (s *Server) startExport(w http.ResponseWriter, what, format string) {
+	if format == "json" {
+		w.Header().Set("Content-Type", "application/json")
+	} else {
+		w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+	}
+	name := fmt.Sprintf("poke443-%s-%s.%s", what, time.Now().In(s.st.DisplayLocation()).Format("20060102-1504"), format)
+	w.Header().Set("Content-Disposition", `attachment; filename="`+name+`"`)
+}
+
+// handleExportEvents downloads the event log, oldest first, as CSV or as
+// JSON in the event stream's format
+func (s *Server) handleExportEvents(w http.ResponseWriter, r *http.Request) {
+	events := state.GetEvents(0)
+	format := exportFormat(r)
+	s.startExport(w, "events", format)
+	loc := s.st.DisplayLocation()
+	if format == "json" {
+		version := s.st.PayloadSchemaVersion()
+		out := make([]streamEvent, 0, len(events))
+		for i := len(events) - 1; i >= 0; i-- {
+			out = append(out, newStreamEvent(events[i], version))
+			out[len(out)-1].Time = events[i].Timestamp.In(loc)
+		}
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		_ = enc.Encode(out)
+		return
+	}
+	cw := csv.NewWriter(w)
+	_ = cw.Write([]string{"time", "type", "host", "check_idx", "check_id", "check_name", "check_type", "message", "downtime_seconds", "blocked"})
+	for i := len(events) - 1; i >= 0; i-- {
+		e := events[i]
+		var idx, downtime string
+		if e.CheckType != "" {
+			idx = strconv.Itoa(e.CheckIdx)
+		}
+		if e.Duration > 0 {
+			downtime = strconv.FormatFloat(e.Duration.Seconds(), 'f', 0, 64)
+		}
+		_ = cw.Write([]string{e.Timestamp.In(loc).Format(time.RFC3339), e.EventType, e.HostName, idx, e.CheckID, e.CheckName,
+			string(e.CheckType), e.Message, downtime, strings.Join(e.Blocked, "; ")})
+	}
+	cw.Flush()
+}
+
+// handleExportOutages downloads the outages in the event log, each from
+// its down event to its recovery, oldest first, as CSV or JSON
+func (s *Server) handleExportOutages(w http.ResponseWriter, r *http.Request) {
+	loc := s.st.DisplayLocation()
+	var outs []exportOutage
+	for _, o := range s.st.Outages() {
+		eo := exportOutage{Start: o.Start.In(loc), Host: o.Host, CheckIdx: o.Idx, CheckID: o.CheckID, CheckType: string(o.CheckType),
+			Check: o.Label, NoData: o.NoData.Seconds(), Ongoing: o.Ongoing, Message: o.Message, Blocked: o.Blocked}
+		if !o.Ongoing {
+			end := o.Start.Add(o.Duration).In(loc)
+			eo.End, eo.Downtime = &end, o.Downtime().Seconds()
+		}
+		outs = append(outs, eo)
+	}
+	format := exportFormat(r)
+	s.startExport(w, "outages", format)
+	if format == "json" {
+		if outs == nil {
+			outs = []exportOutage{}
+		}
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		_ = enc.Encode(outs)
+		return
+	}
+	cw := csv.NewWriter(w)
+	_ = cw.Write([]string{"start", "end", "host", "check_idx", "check_id", "check_type", "check", "downtime_seconds", "ongoing", "message", "blocked", "no_data_seconds"})
+	for _, o := range outs {
+		var end, downtime string
+		if o.End != nil {
+			end = o.End.Format(time.RFC3339)
+			downtime = strconv.FormatFloat(o.Downtime, 'f', 0, 64)
+		}
+		_ = cw.Write([]string{o.Start.Format(time.RFC3339), end, o.Host, strconv.Itoa(o.CheckIdx), o.CheckID, o.CheckType, o.Check,
+			downtime, strconv.FormatBool(o.Ongoing), o.Message, strconv.Itoa(o.Blocked), strconv.FormatFloat(o.NoData, 'f', 0, 64)})
+	}
+	cw.Flush()
+}
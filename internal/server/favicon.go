@@ -0,0 +1,95 @@
+package server
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/andrewsjg/simple-healthchecker/copilot/internal/state"
+)
+
+// faviconColors are the tile colours for each statusLevel
+var faviconColors = map[string]string{
+	state.HostDown:     "#ef4444",
+	state.HostBlocked:  "#f97316",
+	state.HostPending:  "#64748b",
+	state.HostUp:       "#22c55e",
+	state.HostDisabled: "#334155",
+}
+
+// statusLevel sums up stats as one of the state.Host* statuses, the same way
+// a host's status sums up its checks. Down info-severity checks don't count.
+func statusLevel(stats state.AggregateStats) string {
+	switch {
+	case stats.ChecksDown > 0:
+		return state.HostDown
+	case stats.ChecksParentFailed > 0:
+		return state.HostBlocked
+	case stats.ChecksUnknown > 0:
+		return state.HostPending
+	case stats.ChecksUp > 0 || stats.ChecksInfoDown > 0:
+		return state.HostUp
+	}
+	return state.HostDisabled
+}
+
+// statusTitle is the page title prefix for stats, e.g. "(3↓) ", or "" when
+// nothing is failing
+func statusTitle(stats state.AggregateStats) string {
+	switch {
+	case stats.ChecksDown > 0:
+		return fmt.Sprintf("(%d↓) ", stats.ChecksDown)
+	case stats.ChecksParentFailed > 0:
+		return fmt.Sprintf("(%d blocked) ", stats.ChecksParentFailed)
+	}
+	return ""
+}
+
+// faviconSVG draws a rounded tile in level's colour. Failing tiles show the
+// number of failing checks; the rest show a tick, or a dash if nothing has run.
+func faviconSVG(level string, failing int) string {
+	var mark string
+	switch level {
+	case state.HostDown, state.HostBlocked:
+		n := strconv.Itoa(failing)
+		size := 22
+		if failing > 99 {
+			n, size = "99+", 13
+		} else if failing > 9 {
+			size = 17
+		}
+		mark = fmt.Sprintf(`<text x="16" y="16" dy=".35em" text-anchor="middle" font-family="Arial,Helvetica,sans-serif" font-weight="700" font-size="%d" fill="#fff">%s</text>`, size, n)
+	case state.HostUp:
+		mark = `<path d="M9 16.5l4.5 4.5L23 11" fill="none" stroke="#fff" stroke-width="3.5" stroke-linecap="round" stroke-linejoin="round"/>`
+	default:
+		mark = `<path d="M10 16h12" stroke="#fff" stroke-width="3.5" stroke-linecap="round"/>`
+	}
+	return fmt.Sprintf(`<svg xmlns="http://www.w3.org/2000/svg" viewBox="0 0 32 32"><rect width="32" height="32" rx="7" fill="%s"/>%s</svg>`, faviconColors[level], mark)
+}
+
+// faviconURL is the icon link for stats. The query names the level and
+// count so the URL changes, and browsers fetch the icon again, whenever the
+// icon would; the handler itself always draws the current status.
+func faviconURL(stats state.AggregateStats) string {
+	level := statusLevel(stats)
+	return fmt.Sprintf("/favicon.svg?%s-%d", level, failingCount(stats, level))
+}
+
+func failingCount(stats state.AggregateStats, level string) int {
+	switch level {
+	case state.HostDown:
+		return stats.ChecksDown
+	case state.HostBlocked:
+		return stats.ChecksParentFailed
+	}
+	return 0
+}
+
+// handleFavicon serves an icon showing the current overall status
+func (s *Server) handleFavicon(w http.ResponseWriter, r *http.Request) {
+	stats := s.st.GetAggregateStats()
+	level := statusLevel(stats)
+	w.Header().Set("Content-Type", "image/svg+xml")
+	w.Header().Set("Cache-Control", "no-cache")
+	_, _ = w.Write([]byte(faviconSVG(level, failingCount(stats, level))))
+}
@@ -0,0 +1,1089 @@
+package server
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/andrewsjg/simple-healthchecker/copilot/internal/checks"
+	"github.com/andrewsjg/simple-healthchecker/copilot/internal/config"
+	"github.com/andrewsjg/simple-healthchecker/copilot/internal/state"
+	"github.com/andrewsjg/simple-healthchecker/copilot/internal/validate"
+)
+
+// checkForm holds one check's submitted values after parsing and validation
+type checkForm struct {
+	Type           string
+	Name           string // Display name, empty for none
+	URL            string
+	Expect         int
+	Port           int
+	ID             string
+	DependsOn      string
+	MQTTNotify     bool
+	PushoverNotify bool
+	TelegramNotify bool
+	ShoutrrrNotify []string
+	SMSNotify      bool
+	Invert         bool
+	HTTPOpts       checks.HTTPOptions
+	DialOpts       checks.DialOptions
+	PingOpts       checks.PingOptions
+	SSHOpts        checks.SSHOptions
+	WSOpts         checks.WebSocketOptions
+	ScanOpts       checks.PortScanOptions
+	AllOf          []string // Composite members that must all be up
+	AnyOf          []string // Composite members of which one must be up
+	MaxAge         string   // How long a webhook check can go without a result
+	WebhookToken   string   // Token webhook senders must give
+	FileOpts       checks.FileOptions
+	S3Opts         checks.S3Options
+	IPPOpts        checks.IPPOptions
+	SMBOpts        checks.SMBOptions
+	KafkaOpts      checks.KafkaOptions
+	AMQPOpts       checks.AMQPOptions
+	KubernetesOpts checks.KubernetesOptions
+	ProxmoxOpts    checks.ProxmoxOptions
+	ESXiOpts       checks.ESXiOptions
+	UPSOpts        checks.UPSOptions
+	SpeedtestOpts  checks.SpeedtestOptions
+	PublicIPOpts   checks.PublicIPOptions
+	DomainOpts     checks.DomainOptions
+	DNSBLOpts      checks.DNSBLOptions
+	Notes          string
+	RunbookURL     string
+	Severity       config.Severity
+	Schedule       string
+	Idx            int // Existing check index, or -1 for a new check
+}
+
+// parseCheckForm validates the raw form values for a single check, recording any
+// problems in errs under the given label (e.g. "Check 2")
+func parseCheckForm(errs *validate.Errors, label, typ, url, expectStr, portStr, id, dependsOn string) checkForm {
+	cf := checkForm{Type: typ, URL: url, ID: id, DependsOn: dependsOn, Idx: -1}
+	switch config.CheckType(typ) {
+	case config.CheckHTTP:
+		errs.Check(label+" URL", validate.URL(url))
+		expect, err := validate.StatusCode(expectStr)
+		errs.Check(label+" expected status", err)
+		cf.Expect = expect
+	case config.CheckTCP:
+		port, err := validate.Port(portStr)
+		errs.Check(label+" port", err)
+		cf.Port = port
+	case config.CheckWS:
+		errs.Check(label+" URL", validate.WebSocketURL(url))
+	case config.CheckS3:
+		errs.Check(label+" endpoint", validate.URL(url))
+	case config.CheckIPP:
+		errs.Check(label+" printer URL", validate.PrinterURL(url))
+	case config.CheckKubernetes:
+		errs.Check(label+" API server URL", validate.OptionalURL(url))
+	case config.CheckSpeedtest:
+		errs.Check(label+" download URL", validate.OptionalURL(url))
+	case config.CheckPublicIP:
+		errs.Check(label+" lookup URL", validate.OptionalURL(url))
+	case config.CheckPing, config.CheckSSH, config.CheckPorts, config.CheckComposite, config.CheckFile, config.CheckSMB, config.CheckKafka, config.CheckAMQP, config.CheckProxmox, config.CheckESXi, config.CheckUPS, config.CheckDomain, config.CheckDNSBL:
+	case config.CheckWebhook:
+		if id == "" {
+			errs.Add(label+" ID", "a webhook check needs an ID, which names it in its URL")
+		}
+	default:
+		errs.Add(label+" type", "%q is not a supported check type", typ)
+	}
+	errs.Check(label+" ID", validate.CheckID(id))
+	errs.Check(label+" depends on", validate.CheckID(dependsOn))
+	if id != "" && id == dependsOn {
+		errs.Add(label+" depends on", "a check cannot depend on itself")
+	}
+	return cf
+}
+
+// parseName validates the optional display name of a check
+func (cf *checkForm) parseName(errs *validate.Errors, label, name string) {
+	cf.Name = strings.TrimSpace(name)
+	errs.Check(label+" name", validate.DisplayName(cf.Name))
+}
+
+// parseHTTPOptions validates the optional client settings of an http check.
+// redirects is "none" to report redirects rather than follow them; any other
+// value (including a missing field) keeps the default of following them.
+func (cf *checkForm) parseHTTPOptions(errs *validate.Errors, label, redirects, maxStr, proxy, insecure string) {
+	if config.CheckType(cf.Type) != config.CheckHTTP {
+		return
+	}
+	maxRedirects, err := validate.MaxRedirects(maxStr)
+	errs.Check(label+" max redirects", err)
+	proxy = strings.TrimSpace(proxy)
+	errs.Check(label+" proxy", validate.ProxyURL(proxy))
+	cf.HTTPOpts = checks.HTTPOptions{
+		NoFollowRedirects:  redirects == "none",
+		MaxRedirects:       maxRedirects,
+		Proxy:              proxy,
+		InsecureSkipVerify: insecure == "true",
+	}
+}
+
+// parseDialOptions validates the address family and source address of an
+// http, tcp or ports check
+func (cf *checkForm) parseDialOptions(errs *validate.Errors, label, ipVersion, source string) {
+	if t := config.CheckType(cf.Type); t != config.CheckHTTP && t != config.CheckTCP && t != config.CheckPorts {
+		return
+	}
+	v, err := validate.IPVersion(ipVersion)
+	errs.Check(label+" IP version", err)
+	source = strings.TrimSpace(source)
+	errs.Check(label+" source", validate.Source(source))
+	cf.DialOpts = checks.DialOptions{IPVersion: v, Source: source}
+}
+
+// parseContentRules reads the content watch settings of an http check. They
+// are applied after parseHTTPOptions, which resets cf.HTTPOpts.
+func (cf *checkForm) parseContentRules(errs *validate.Errors, label, mustContain, mustNotContain, watch string) {
+	if config.CheckType(cf.Type) != config.CheckHTTP {
+		return
+	}
+	if mustContain != "" && mustContain == mustNotContain {
+		errs.Add(label+" content", "a body cannot both contain and not contain %q", mustContain)
+	}
+	cf.HTTPOpts.MustContain = mustContain
+	cf.HTTPOpts.MustNotContain = mustNotContain
+	cf.HTTPOpts.WatchContent = watch == "true"
+}
+
+// parsePingOptions validates the probe method of a ping check. The port is
+// only read for tcp pings and defaults to checks.DefaultTCPPingPort when empty.
+func (cf *checkForm) parsePingOptions(errs *validate.Errors, label, method, portStr string) {
+	if config.CheckType(cf.Type) != config.CheckPing {
+		return
+	}
+	m, err := checks.ParsePingMethod(method)
+	errs.Check(label+" ping method", err)
+	cf.PingOpts = checks.PingOptions{Method: m}
+	if m != checks.PingTCP || strings.TrimSpace(portStr) == "" {
+		return
+	}
+	port, err := validate.Port(portStr)
+	errs.Check(label+" ping port", err)
+	cf.PingOpts.TCPPort = port
+}
+
+// parseSSHOptions validates the command, port and expectations of an ssh
+// check. An empty port means DefaultSSHPort and an empty exit status means 0.
+func (cf *checkForm) parseSSHOptions(errs *validate.Errors, label, portStr, user, key, command, exitStr, output string) {
+	if config.CheckType(cf.Type) != config.CheckSSH {
+		return
+	}
+	cf.SSHOpts = checks.SSHOptions{
+		User:         strings.TrimSpace(user),
+		KeyFile:      strings.TrimSpace(key),
+		Command:      strings.TrimSpace(command),
+		ExpectOutput: output,
+	}
+	if strings.TrimSpace(portStr) != "" {
+		port, err := validate.Port(portStr)
+		errs.Check(label+" port", err)
+		cf.SSHOpts.Port = port
+	}
+	if cf.SSHOpts.Command == "" {
+		errs.Add(label+" command", "is required")
+	}
+	exit, err := validate.ExitStatus(exitStr)
+	errs.Check(label+" expected exit status", err)
+	cf.SSHOpts.ExpectExit = exit
+	errs.Check(label+" expected output", validate.Regexp(output))
+}
+
+// parseWebSocketOptions validates the optional message exchange of a websocket check
+func (cf *checkForm) parseWebSocketOptions(errs *validate.Errors, label, send, expect string) {
+	if config.CheckType(cf.Type) != config.CheckWS {
+		return
+	}
+	errs.Check(label+" expected reply", validate.Regexp(expect))
+	cf.WSOpts = checks.WebSocketOptions{Send: send, Expect: expect}
+}
+
+// parsePortScanOptions validates the port lists of a ports check, which
+// must expect at least one port open or closed
+func (cf *checkForm) parsePortScanOptions(errs *validate.Errors, label, open, closed string) {
+	if config.CheckType(cf.Type) != config.CheckPorts {
+		return
+	}
+	var err error
+	cf.ScanOpts.Open, err = validate.Ports(open)
+	errs.Check(label+" open ports", err)
+	cf.ScanOpts.Closed, err = validate.Ports(closed)
+	errs.Check(label+" closed ports", err)
+	if strings.TrimSpace(open) == "" && strings.TrimSpace(closed) == "" {
+		errs.Add(label+" ports", "list at least one port expected open or closed")
+	}
+	for _, p := range cf.ScanOpts.Open {
+		if slices.Contains(cf.ScanOpts.Closed, p) {
+			errs.Add(label+" ports", "port %d cannot be expected both open and closed", p)
+		}
+	}
+}
+
+// parseCompositeOptions reads the comma-separated member check IDs of a
+// composite check, which must name at least one check other than itself
+func (cf *checkForm) parseCompositeOptions(errs *validate.Errors, label, allOf, anyOf string) {
+	if config.CheckType(cf.Type) != config.CheckComposite {
+		return
+	}
+	cf.AllOf, cf.AnyOf = parseLabels(allOf), parseLabels(anyOf)
+	if len(cf.AllOf)+len(cf.AnyOf) == 0 {
+		errs.Add(label+" members", "list at least one check ID")
+	}
+	for _, id := range append(slices.Clone(cf.AllOf), cf.AnyOf...) {
+		errs.Check(label+" members", validate.CheckID(id))
+		if id == cf.ID {
+			errs.Add(label+" members", "a composite check cannot include itself")
+		}
+	}
+}
+
+// parseWebhookOptions validates a webhook check's optional max age and token
+func (cf *checkForm) parseWebhookOptions(errs *validate.Errors, label, maxAge, token string) {
+	if config.CheckType(cf.Type) != config.CheckWebhook {
+		return
+	}
+	cf.MaxAge, cf.WebhookToken = strings.TrimSpace(maxAge), strings.TrimSpace(token)
+	if cf.MaxAge != "" {
+		if d, err := time.ParseDuration(cf.MaxAge); err != nil || d <= 0 {
+			errs.Add(label+" max age", "%q is not a duration, e.g. 25h", cf.MaxAge)
+		}
+	}
+}
+
+// parseFileOptions validates a file check's path and max age, both
+// required, and its optional SFTP login. An empty port means DefaultSSHPort.
+func (cf *checkForm) parseFileOptions(errs *validate.Errors, label, path, maxAge, sftp, user, key, portStr string) {
+	if config.CheckType(cf.Type) != config.CheckFile {
+		return
+	}
+	cf.MaxAge = strings.TrimSpace(maxAge)
+	cf.FileOpts = checks.FileOptions{
+		Path:    strings.TrimSpace(path),
+		SFTP:    sftp == "true",
+		User:    strings.TrimSpace(user),
+		KeyFile: strings.TrimSpace(key),
+	}
+	if cf.FileOpts.Path == "" {
+		errs.Add(label+" path", "is required")
+	}
+	if d, err := time.ParseDuration(cf.MaxAge); err != nil || d <= 0 {
+		errs.Add(label+" max age", "%q is not a duration, e.g. 25h", cf.MaxAge)
+	} else {
+		cf.FileOpts.MaxAge = d
+	}
+	if cf.FileOpts.SFTP && strings.TrimSpace(portStr) != "" {
+		port, err := validate.Port(portStr)
+		errs.Check(label+" port", err)
+		cf.FileOpts.Port = port
+	}
+	if !cf.FileOpts.SFTP {
+		cf.FileOpts.User, cf.FileOpts.KeyFile = "", ""
+	}
+}
+
+// parseS3Options reads an s3 check's bucket, object, credentials and
+// optional max age, which needs an object. When editing, an empty secret key
+// with an access key keeps the check's current secret.
+func (cf *checkForm) parseS3Options(errs *validate.Errors, label, bucket, object, region, accessKey, secretKey, maxAge, insecure string, editing bool) {
+	if config.CheckType(cf.Type) != config.CheckS3 {
+		return
+	}
+	cf.S3Opts = checks.S3Options{
+		Bucket:             strings.Trim(strings.TrimSpace(bucket), "/"),
+		Object:             strings.TrimPrefix(strings.TrimSpace(object), "/"),
+		Region:             strings.TrimSpace(region),
+		AccessKey:          strings.TrimSpace(accessKey),
+		SecretKey:          strings.TrimSpace(secretKey),
+		InsecureSkipVerify: insecure == "true",
+	}
+	if cf.S3Opts.SecretKey != "" && cf.S3Opts.AccessKey == "" {
+		errs.Add(label+" access key", "is required with a secret key")
+	} else if cf.S3Opts.AccessKey != "" && cf.S3Opts.SecretKey == "" && !editing {
+		errs.Add(label+" secret key", "is required with an access key")
+	}
+	cf.MaxAge = strings.TrimSpace(maxAge)
+	if cf.MaxAge == "" {
+		return
+	}
+	if d, err := time.ParseDuration(cf.MaxAge); err != nil || d <= 0 {
+		errs.Add(label+" max age", "%q is not a duration, e.g. 25h", cf.MaxAge)
+	} else if cf.S3Opts.Object == "" {
+		errs.Add(label+" max age", "needs an object to check the age of")
+	} else {
+		cf.S3Opts.MaxAge = d
+	}
+}
+
+// parseIPPOptions reads an ipp check's TLS option
+func (cf *checkForm) parseIPPOptions(insecure string) {
+	if config.CheckType(cf.Type) != config.CheckIPP {
+		return
+	}
+	cf.IPPOpts = checks.IPPOptions{InsecureSkipVerify: insecure == "true"}
+}
+
+// parseSMBOptions validates an smb check's optional share, login and port,
+// where an empty port means DefaultSMBPort. When editing, an empty password
+// with a username keeps the check's current password.
+func (cf *checkForm) parseSMBOptions(errs *validate.Errors, label, share, user, password, portStr string) {
+	if config.CheckType(cf.Type) != config.CheckSMB {
+		return
+	}
+	cf.SMBOpts = checks.SMBOptions{
+		Share:    strings.TrimSpace(share),
+		User:     strings.TrimSpace(user),
+		Password: password,
+	}
+	errs.Check(label+" share", validate.ShareName(cf.SMBOpts.Share))
+	if cf.SMBOpts.Password != "" && cf.SMBOpts.User == "" {
+		errs.Add(label+" username", "is required with a password")
+	}
+	if strings.TrimSpace(portStr) != "" {
+		port, err := validate.Port(portStr)
+		errs.Check(label+" port", err)
+		cf.SMBOpts.Port = port
+	}
+}
+
+// parseKafkaOptions validates a kafka check's optional topic and port, where
+// an empty port means DefaultKafkaPort
+func (cf *checkForm) parseKafkaOptions(errs *validate.Errors, label, topic, portStr string) {
+	if config.CheckType(cf.Type) != config.CheckKafka {
+		return
+	}
+	cf.KafkaOpts = checks.KafkaOptions{Topic: strings.TrimSpace(topic)}
+	errs.Check(label+" topic", validate.KafkaTopic(cf.KafkaOpts.Topic))
+	if strings.TrimSpace(portStr) != "" {
+		port, err := validate.Port(portStr)
+		errs.Check(label+" port", err)
+		cf.KafkaOpts.Port = port
+	}
+}
+
+// parseAMQPOptions validates an amqp check's vhost, login, port and TLS
+// options. When editing, an empty password with a username keeps the
+// check's current password.
+func (cf *checkForm) parseAMQPOptions(errs *validate.Errors, label, vhost, user, password, portStr, tlsStr, insecure string) {
+	if config.CheckType(cf.Type) != config.CheckAMQP {
+		return
+	}
+	cf.AMQPOpts = checks.AMQPOptions{
+		VHost:              strings.TrimSpace(vhost),
+		User:               strings.TrimSpace(user),
+		Password:           password,
+		TLS:                tlsStr == "true",
+		InsecureSkipVerify: insecure == "true",
+	}
+	if len(cf.AMQPOpts.VHost) > 255 {
+		errs.Add(label+" vhost", "is too long")
+	}
+	if cf.AMQPOpts.Password != "" && cf.AMQPOpts.User == "" {
+		errs.Add(label+" username", "is required with a password")
+	}
+	if strings.TrimSpace(portStr) != "" {
+		port, err := validate.Port(portStr)
+		errs.Check(label+" port", err)
+		cf.AMQPOpts.Port = port
+	}
+}
+
+// parseKubernetesOptions validates a kubernetes check's kubeconfig, token and
+// deployment. The check needs a URL or a kubeconfig to find the API server.
+// When editing, an empty token keeps the check's current one.
+func (cf *checkForm) parseKubernetesOptions(errs *validate.Errors, label, kubeconfig, kubeContext, token, deployment, insecure string) {
+	if config.CheckType(cf.Type) != config.CheckKubernetes {
+		return
+	}
+	cf.KubernetesOpts = checks.KubernetesOptions{
+		Kubeconfig:         strings.TrimSpace(kubeconfig),
+		Context:            strings.TrimSpace(kubeContext),
+		Token:              strings.TrimSpace(token),
+		Deployment:         strings.TrimSpace(deployment),
+		InsecureSkipVerify: insecure == "true",
+	}
+	if strings.TrimSpace(cf.URL) == "" && cf.KubernetesOpts.Kubeconfig == "" {
+		errs.Add(label+" API server URL", "is required without a kubeconfig")
+	}
+	if cf.KubernetesOpts.Context != "" && cf.KubernetesOpts.Kubeconfig == "" {
+		errs.Add(label+" context", "needs a kubeconfig")
+	}
+	errs.Check(label+" deployment", validate.Deployment(cf.KubernetesOpts.Deployment))
+}
+
+// parseProxmoxOptions validates a proxmox check's API token, node, guest,
+// usage limit and port. When editing, an empty token keeps the check's
+// current one.
+func (cf *checkForm) parseProxmoxOptions(errs *validate.Errors, label, token, node, vm, maxUsage, portStr, insecure string, editing bool) {
+	if config.CheckType(cf.Type) != config.CheckProxmox {
+		return
+	}
+	cf.ProxmoxOpts = checks.ProxmoxOptions{
+		Token:              strings.TrimSpace(token),
+		Node:               strings.TrimSpace(node),
+		VM:                 strings.TrimSpace(vm),
+		InsecureSkipVerify: insecure == "true",
+	}
+	if cf.ProxmoxOpts.Token != "" || !editing {
+		errs.Check(label+" API token", validate.ProxmoxToken(cf.ProxmoxOpts.Token))
+	}
+	usage, err := validate.MaxUsage(maxUsage)
+	errs.Check(label+" max usage", err)
+	cf.ProxmoxOpts.MaxUsage = usage
+	if strings.TrimSpace(portStr) != "" {
+		port, err := validate.Port(portStr)
+		errs.Check(label+" port", err)
+		cf.ProxmoxOpts.Port = port
+	}
+}
+
+// parseESXiOptions validates an esxi check's login, VM, usage limit and
+// port. When editing, an empty password keeps the check's current one.
+func (cf *checkForm) parseESXiOptions(errs *validate.Errors, label, user, password, vm, maxUsage, portStr, insecure string) {
+	if config.CheckType(cf.Type) != config.CheckESXi {
+		return
+	}
+	cf.ESXiOpts = checks.ESXiOptions{
+		User:               strings.TrimSpace(user),
+		Password:           password,
+		VM:                 strings.TrimSpace(vm),
+		InsecureSkipVerify: insecure == "true",
+	}
+	if cf.ESXiOpts.User == "" {
+		errs.Add(label+" username", "is required")
+	}
+	usage, err := validate.MaxUsage(maxUsage)
+	errs.Check(label+" max usage", err)
+	cf.ESXiOpts.MaxUsage = usage
+	if strings.TrimSpace(portStr) != "" {
+		port, err := validate.Port(portStr)
+		errs.Check(label+" port", err)
+		cf.ESXiOpts.Port = port
+	}
+}
+
+// parseUPSOptions validates a ups check's daemon, UPS name, battery limit
+// and port, where an empty port means the daemon's default
+func (cf *checkForm) parseUPSOptions(errs *validate.Errors, label, daemon, ups, minCharge, portStr string) {
+	if config.CheckType(cf.Type) != config.CheckUPS {
+		return
+	}
+	cf.UPSOpts = checks.UPSOptions{
+		Daemon: strings.TrimSpace(daemon),
+		UPS:    strings.TrimSpace(ups),
+	}
+	if cf.UPSOpts.Daemon == checks.UPSNUT {
+		cf.UPSOpts.Daemon = "" // The default
+	}
+	errs.Check(label+" daemon", validate.UPSDaemon(cf.UPSOpts.Daemon))
+	errs.Check(label+" UPS", validate.UPSName(cf.UPSOpts.UPS))
+	if cf.UPSOpts.Daemon == checks.UPSApcupsd {
+		cf.UPSOpts.UPS = "" // apcupsd has one UPS
+	}
+	charge, err := validate.MinCharge(minCharge)
+	errs.Check(label+" min charge", err)
+	cf.UPSOpts.MinCharge = charge
+	if strings.TrimSpace(portStr) != "" {
+		port, err := validate.Port(portStr)
+		errs.Check(label+" port", err)
+		cf.UPSOpts.Port = port
+	}
+}
+
+// parseSpeedtestOptions validates a speedtest check's direction, speed limit,
+// interval and iperf3 port. With a download URL there is no direction or
+// port to set.
+func (cf *checkForm) parseSpeedtestOptions(errs *validate.Errors, label, upload, minSpeed, interval, portStr string) {
+	if config.CheckType(cf.Type) != config.CheckSpeedtest {
+		return
+	}
+	cf.SpeedtestOpts = checks.SpeedtestOptions{URL: strings.TrimSpace(cf.URL)}
+	speed, err := validate.Speed(minSpeed)
+	errs.Check(label+" min speed", err)
+	cf.SpeedtestOpts.MinSpeed = speed
+	every, err := validate.SpeedtestInterval(interval)
+	errs.Check(label+" interval", err)
+	cf.SpeedtestOpts.Interval = every
+	if cf.SpeedtestOpts.URL != "" {
+		return
+	}
+	cf.SpeedtestOpts.Upload = upload == "true"
+	if strings.TrimSpace(portStr) != "" {
+		port, err := validate.Port(portStr)
+		errs.Check(label+" port", err)
+		cf.SpeedtestOpts.Port = port
+	}
+}
+
+// parsePublicIPOptions validates the address family a publicip check looks
+// up, which shares the ip_version field of the dial options
+func (cf *checkForm) parsePublicIPOptions(errs *validate.Errors, label, ipVersion string) {
+	if config.CheckType(cf.Type) != config.CheckPublicIP {
+		return
+	}
+	v, err := validate.IPVersion(ipVersion)
+	errs.Check(label+" IP version", err)
+	cf.PublicIPOpts = checks.PublicIPOptions{IPVersion: v}
+}
+
+// parseDomainOptions validates the domain a domain check looks up, where
+// empty means the host's address, and how many days before expiry it fails
+func (cf *checkForm) parseDomainOptions(errs *validate.Errors, label, domain, warnDays string) {
+	if config.CheckType(cf.Type) != config.CheckDomain {
+		return
+	}
+	domain = strings.ToLower(strings.TrimSuffix(strings.TrimSpace(domain), "."))
+	errs.Check(label+" domain", validate.Domain(domain))
+	days, err := validate.WarnDays(warnDays)
+	errs.Check(label+" warn days", err)
+	cf.DomainOpts = checks.DomainOptions{Domain: domain, WarnDays: days}
+}
+
+// parseDNSBLOptions validates the blocklists a dnsbl check looks the host up
+// on, where empty means the default ones
+func (cf *checkForm) parseDNSBLOptions(errs *validate.Errors, label, blocklists string) {
+	if config.CheckType(cf.Type) != config.CheckDNSBL {
+		return
+	}
+	lists, err := validate.Blocklists(blocklists)
+	errs.Check(label+" blocklists", err)
+	cf.DNSBLOpts = checks.DNSBLOptions{Blocklists: lists}
+}
+
+// parseSeverity validates a submitted severity, where empty means the default
+func parseSeverity(errs *validate.Errors, label, s string) config.Severity {
+	sev := config.Severity(strings.TrimSpace(s))
+	switch sev {
+	case "", config.SeverityInfo, config.SeverityWarning, config.SeverityCritical:
+	default:
+		errs.Add(label+" severity", "%q must be info, warning or critical", s)
+	}
+	return sev.OrDefault()
+}
+
+// parseLabels splits a comma-separated list, such as Shoutrrr labels or
+// check IDs, dropping blanks and repeats
+func parseLabels(s string) []string {
+	var labels []string
+	for _, label := range strings.Split(s, ",") {
+		if label = strings.TrimSpace(label); label != "" && !slices.Contains(labels, label) {
+			labels = append(labels, label)
+		}
+	}
+	return labels
+}
+
+// checkShoutrrrLabels records an error for every label no Shoutrrr URL has
+func (s *Server) checkShoutrrrLabels(errs *validate.Errors, forms []checkForm) {
+	known := s.st.ShoutrrrLabels()
+	for i, cf := range forms {
+		for _, label := range cf.ShoutrrrNotify {
+			if !slices.Contains(known, label) {
+				errs.Add(fmt.Sprintf("Check %d Shoutrrr labels", i+1), "no Shoutrrr URL is labelled %q", label)
+			}
+		}
+	}
+}
+
+// checkCompositeMembers records an error for every composite member that
+// is neither an existing check's ID nor submitted alongside
+func (s *Server) checkCompositeMembers(errs *validate.Errors, forms []checkForm) {
+	submitted := make(map[string]bool)
+	for _, cf := range forms {
+		submitted[cf.ID] = true
+	}
+	for i, cf := range forms {
+		for _, id := range append(slices.Clone(cf.AllOf), cf.AnyOf...) {
+			if !submitted[id] && !s.st.CheckIDInUse(id, "") {
+				errs.Add(fmt.Sprintf("Check %d members", i+1), "no check has ID %q", id)
+			}
+		}
+	}
+}
+
+// checkIDsUnique records an error for every check whose ID is used more than
+// once in the submission, or by an existing check that is not being edited
+func (s *Server) checkIDsUnique(errs *validate.Errors, hostName string, forms []checkForm) {
+	editing := make([]int, 0, len(forms))
+	for _, cf := range forms {
+		if cf.Idx >= 0 {
+			editing = append(editing, cf.Idx)
+		}
+	}
+	seen := make(map[string]bool)
+	for i, cf := range forms {
+		if cf.ID == "" {
+			continue
+		}
+		label := fmt.Sprintf("Check %d ID", i+1)
+		if seen[cf.ID] {
+			errs.Add(label, "%q is used more than once", cf.ID)
+			continue
+		}
+		seen[cf.ID] = true
+		if s.st.CheckIDInUse(cf.ID, hostName, editing...) {
+			errs.Add(label, "%q is already used by another check", cf.ID)
+		}
+	}
+}
+
+// checkIDsStillNeeded records an error for every existing check whose ID
+// the submission clears while other checks still depend on it. Renamed IDs
+// are fine: their references follow them.
+func (s *Server) checkIDsStillNeeded(errs *validate.Errors, hostName string, forms []checkForm) {
+	hs, ok := s.st.GetHost(hostName)
+	if !ok {
+		return
+	}
+	for i, cf := range forms {
+		if cf.ID != "" || cf.Idx < 0 || cf.Idx >= len(hs.Checks) || hs.Checks[cf.Idx].ID == "" {
+			continue
+		}
+		old := hs.Checks[cf.Idx].ID
+		for _, d := range s.st.Dependants(hostName, cf.Idx) {
+			if !d.Direct || (d.Host == hostName && !formReferences(forms, d.Idx, old)) {
+				continue
+			}
+			errs.Add(fmt.Sprintf("Check %d ID", i+1), "%s depends on %q; change the ID rather than clearing it, or remove that dependency first", d.Label, old)
+			break
+		}
+	}
+}
+
+// formReferences reports whether the submitted check at idx still depends
+// on, or combines, id. Checks not in the submission keep their references.
+func formReferences(forms []checkForm, idx int, id string) bool {
+	for _, cf := range forms {
+		if cf.Idx == idx {
+			return cf.DependsOn == id || slices.Contains(cf.AllOf, id) || slices.Contains(cf.AnyOf, id)
+		}
+	}
+	return true
+}
+
+// followIDRenames points the submitted references to IDs the submission
+// renames at their new IDs. The form was filled in with the old IDs, so
+// without this saving a later check would undo the rename of an earlier one.
+func (s *Server) followIDRenames(hostName string, forms []checkForm) {
+	hs, ok := s.st.GetHost(hostName)
+	if !ok {
+		return
+	}
+	renames := make(map[string]string)
+	for _, cf := range forms {
+		if cf.Idx >= 0 && cf.Idx < len(hs.Checks) {
+			if old := hs.Checks[cf.Idx].ID; old != "" && cf.ID != "" && old != cf.ID {
+				renames[old] = cf.ID
+			}
+		}
+	}
+	if len(renames) == 0 {
+		return
+	}
+	rename := func(id string) string {
+		if to, ok := renames[id]; ok {
+			return to
+		}
+		return id
+	}
+	for i := range forms {
+		cf := &forms[i]
+		cf.DependsOn = rename(cf.DependsOn)
+		for j := range cf.AllOf {
+			cf.AllOf[j] = rename(cf.AllOf[j])
+		}
+		for j := range cf.AnyOf {
+			cf.AnyOf[j] = rename(cf.AnyOf[j])
+		}
+	}
+}
+
+// renderFormErrors swaps the validation problems into the form's error
+// container rather than replacing the modal, so the user's input is kept
+func (s *Server) renderFormErrors(w http.ResponseWriter, target string, errs validate.Errors) {
+	w.Header().Set("HX-Retarget", target)
+	w.Header().Set("HX-Reswap", "innerHTML")
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(http.StatusUnprocessableEntity)
+	_ = s.tpl.ExecuteTemplate(w, "form_errors.html", errs)
+}
+
+// parseIndexedChecks reads the type_N, url_N, ... fields posted by the edit
+// host modal for count existing checks
+func parseIndexedChecks(r *http.Request, errs *validate.Errors, count int) []checkForm {
+	forms := make([]checkForm, 0, count)
+	for i := 0; i < count; i++ {
+		cf := parseCheckForm(errs, fmt.Sprintf("Check %d", i+1),
+			r.FormValue(fmt.Sprintf("type_%d", i)),
+			r.FormValue(fmt.Sprintf("url_%d", i)),
+			r.FormValue(fmt.Sprintf("expect_%d", i)),
+			r.FormValue(fmt.Sprintf("port_%d", i)),
+			r.FormValue(fmt.Sprintf("id_%d", i)),
+			r.FormValue(fmt.Sprintf("depends_on_%d", i)))
+		cf.parseName(errs, fmt.Sprintf("Check %d", i+1), r.FormValue(fmt.Sprintf("check_name_%d", i)))
+		cf.MQTTNotify = r.FormValue(fmt.Sprintf("mqtt_notify_%d", i)) == "true"
+		cf.PushoverNotify = r.FormValue(fmt.Sprintf("pushover_notify_%d", i)) == "true"
+		cf.TelegramNotify = r.FormValue(fmt.Sprintf("telegram_notify_%d", i)) == "true"
+		cf.ShoutrrrNotify = parseLabels(r.FormValue(fmt.Sprintf("shoutrrr_%d", i)))
+		cf.SMSNotify = r.FormValue(fmt.Sprintf("sms_notify_%d", i)) == "true"
+		cf.Invert = r.FormValue(fmt.Sprintf("invert_%d", i)) == "true"
+		cf.parseHTTPOptions(errs, fmt.Sprintf("Check %d", i+1),
+			r.FormValue(fmt.Sprintf("redirects_%d", i)),
+			r.FormValue(fmt.Sprintf("max_redirects_%d", i)),
+			r.FormValue(fmt.Sprintf("proxy_%d", i)),
+			r.FormValue(fmt.Sprintf("insecure_skip_verify_%d", i)))
+		cf.parseContentRules(errs, fmt.Sprintf("Check %d", i+1),
+			r.FormValue(fmt.Sprintf("must_contain_%d", i)),
+			r.FormValue(fmt.Sprintf("must_not_contain_%d", i)),
+			r.FormValue(fmt.Sprintf("watch_content_%d", i)))
+		cf.parseDialOptions(errs, fmt.Sprintf("Check %d", i+1),
+			r.FormValue(fmt.Sprintf("ip_version_%d", i)),
+			r.FormValue(fmt.Sprintf("source_%d", i)))
+		cf.parsePublicIPOptions(errs, fmt.Sprintf("Check %d", i+1),
+			r.FormValue(fmt.Sprintf("ip_version_%d", i)))
+		cf.Notes = strings.TrimSpace(r.FormValue(fmt.Sprintf("notes_%d", i)))
+		cf.RunbookURL = strings.TrimSpace(r.FormValue(fmt.Sprintf("runbook_url_%d", i)))
+		errs.Check(fmt.Sprintf("Check %d runbook URL", i+1), validate.OptionalURL(cf.RunbookURL))
+		cf.Schedule = strings.TrimSpace(r.FormValue(fmt.Sprintf("schedule_%d", i)))
+		_, err := config.ParseSchedule(cf.Schedule)
+		errs.Check(fmt.Sprintf("Check %d schedule", i+1), err)
+		cf.parsePingOptions(errs, fmt.Sprintf("Check %d", i+1),
+			r.FormValue(fmt.Sprintf("ping_method_%d", i)),
+			r.FormValue(fmt.Sprintf("ping_port_%d", i)))
+		cf.parseSSHOptions(errs, fmt.Sprintf("Check %d", i+1),
+			r.FormValue(fmt.Sprintf("port_%d", i)),
+			r.FormValue(fmt.Sprintf("ssh_user_%d", i)),
+			r.FormValue(fmt.Sprintf("ssh_key_%d", i)),
+			r.FormValue(fmt.Sprintf("command_%d", i)),
+			r.FormValue(fmt.Sprintf("expect_exit_%d", i)),
+			r.FormValue(fmt.Sprintf("expect_output_%d", i)))
+		cf.parseWebSocketOptions(errs, fmt.Sprintf("Check %d", i+1),
+			r.FormValue(fmt.Sprintf("ws_send_%d", i)),
+			r.FormValue(fmt.Sprintf("ws_expect_%d", i)))
+		cf.parsePortScanOptions(errs, fmt.Sprintf("Check %d", i+1),
+			r.FormValue(fmt.Sprintf("ports_%d", i)),
+			r.FormValue(fmt.Sprintf("closed_ports_%d", i)))
+		cf.parseCompositeOptions(errs, fmt.Sprintf("Check %d", i+1),
+			r.FormValue(fmt.Sprintf("all_of_%d", i)),
+			r.FormValue(fmt.Sprintf("any_of_%d", i)))
+		cf.parseS3Options(errs, fmt.Sprintf("Check %d", i+1),
+			r.FormValue(fmt.Sprintf("bucket_%d", i)),
+			r.FormValue(fmt.Sprintf("object_%d", i)),
+			r.FormValue(fmt.Sprintf("region_%d", i)),
+			r.FormValue(fmt.Sprintf("access_key_%d", i)),
+			r.FormValue(fmt.Sprintf("secret_key_%d", i)),
+			r.FormValue(fmt.Sprintf("max_age_%d", i)),
+			r.FormValue(fmt.Sprintf("insecure_skip_verify_%d", i)), true)
+		cf.parseIPPOptions(r.FormValue(fmt.Sprintf("insecure_skip_verify_%d", i)))
+		cf.parseSMBOptions(errs, fmt.Sprintf("Check %d", i+1),
+			r.FormValue(fmt.Sprintf("share_%d", i)),
+			r.FormValue(fmt.Sprintf("username_%d", i)),
+			r.FormValue(fmt.Sprintf("password_%d", i)),
+			r.FormValue(fmt.Sprintf("port_%d", i)))
+		cf.parseKafkaOptions(errs, fmt.Sprintf("Check %d", i+1),
+			r.FormValue(fmt.Sprintf("topic_%d", i)),
+			r.FormValue(fmt.Sprintf("port_%d", i)))
+		cf.parseAMQPOptions(errs, fmt.Sprintf("Check %d", i+1),
+			r.FormValue(fmt.Sprintf("vhost_%d", i)),
+			r.FormValue(fmt.Sprintf("username_%d", i)),
+			r.FormValue(fmt.Sprintf("password_%d", i)),
+			r.FormValue(fmt.Sprintf("port_%d", i)),
+			r.FormValue(fmt.Sprintf("tls_%d", i)),
+			r.FormValue(fmt.Sprintf("insecure_skip_verify_%d", i)))
+		cf.parseKubernetesOptions(errs, fmt.Sprintf("Check %d", i+1),
+			r.FormValue(fmt.Sprintf("kubeconfig_%d", i)),
+			r.FormValue(fmt.Sprintf("kube_context_%d", i)),
+			r.FormValue(fmt.Sprintf("token_%d", i)),
+			r.FormValue(fmt.Sprintf("deployment_%d", i)),
+			r.FormValue(fmt.Sprintf("insecure_skip_verify_%d", i)))
+		cf.parseProxmoxOptions(errs, fmt.Sprintf("Check %d", i+1),
+			r.FormValue(fmt.Sprintf("token_%d", i)),
+			r.FormValue(fmt.Sprintf("node_%d", i)),
+			r.FormValue(fmt.Sprintf("vm_%d", i)),
+			r.FormValue(fmt.Sprintf("max_usage_%d", i)),
+			r.FormValue(fmt.Sprintf("port_%d", i)),
+			r.FormValue(fmt.Sprintf("insecure_skip_verify_%d", i)), true)
+		cf.parseESXiOptions(errs, fmt.Sprintf("Check %d", i+1),
+			r.FormValue(fmt.Sprintf("username_%d", i)),
+			r.FormValue(fmt.Sprintf("password_%d", i)),
+			r.FormValue(fmt.Sprintf("vm_%d", i)),
+			r.FormValue(fmt.Sprintf("max_usage_%d", i)),
+			r.FormValue(fmt.Sprintf("port_%d", i)),
+			r.FormValue(fmt.Sprintf("insecure_skip_verify_%d", i)))
+		cf.parseUPSOptions(errs, fmt.Sprintf("Check %d", i+1),
+			r.FormValue(fmt.Sprintf("daemon_%d", i)),
+			r.FormValue(fmt.Sprintf("ups_%d", i)),
+			r.FormValue(fmt.Sprintf("min_charge_%d", i)),
+			r.FormValue(fmt.Sprintf("port_%d", i)))
+		cf.parseSpeedtestOptions(errs, fmt.Sprintf("Check %d", i+1),
+			r.FormValue(fmt.Sprintf("upload_%d", i)),
+			r.FormValue(fmt.Sprintf("min_speed_%d", i)),
+			r.FormValue(fmt.Sprintf("interval_%d", i)),
+			r.FormValue(fmt.Sprintf("port_%d", i)))
+		cf.parseDomainOptions(errs, fmt.Sprintf("Check %d", i+1),
+			r.FormValue(fmt.Sprintf("domain_%d", i)),
+			r.FormValue(fmt.Sprintf("warn_days_%d", i)))
+		cf.parseDNSBLOptions(errs, fmt.Sprintf("Check %d", i+1),
+			r.FormValue(fmt.Sprintf("blocklists_%d", i)))
+		cf.parseFileOptions(errs, fmt.Sprintf("Check %d", i+1),
+			r.FormValue(fmt.Sprintf("path_%d", i)),
+			r.FormValue(fmt.Sprintf("max_age_%d", i)),
+			r.FormValue(fmt.Sprintf("sftp_%d", i)),
+			r.FormValue(fmt.Sprintf("ssh_user_%d", i)),
+			r.FormValue(fmt.Sprintf("ssh_key_%d", i)),
+			r.FormValue(fmt.Sprintf("port_%d", i)))
+		cf.Severity = parseSeverity(errs, fmt.Sprintf("Check %d", i+1), r.FormValue(fmt.Sprintf("severity_%d", i)))
+		cf.Idx = i
+		forms = append(forms, cf)
+	}
+	return forms
+}
+
+// addCheck appends a validated check to the named host
+func (s *Server) addCheck(st *state.State, host string, cf checkForm) error {
+	hs, ok := st.GetHost(host)
+	if !ok {
+		return fmt.Errorf("host not found")
+	}
+	idx := len(hs.Checks)
+	var err error
+	switch config.CheckType(cf.Type) {
+	case config.CheckHTTP:
+		err = st.AddHTTPCheck(host, cf.URL, cf.Expect, cf.HTTPOpts, cf.ID, cf.DependsOn, cf.MQTTNotify, cf.PushoverNotify, cf.TelegramNotify)
+	case config.CheckTCP:
+		err = st.AddTCPCheck(host, cf.Port, cf.ID, cf.DependsOn, cf.MQTTNotify, cf.PushoverNotify, cf.TelegramNotify)
+	case config.CheckWS:
+		err = st.AddWebSocketCheck(host, cf.URL, cf.WSOpts, cf.ID, cf.DependsOn, cf.MQTTNotify, cf.PushoverNotify, cf.TelegramNotify)
+	case config.CheckSSH:
+		err = st.AddSSHCheck(host, cf.SSHOpts, cf.ID, cf.DependsOn, cf.MQTTNotify, cf.PushoverNotify, cf.TelegramNotify)
+	case config.CheckPorts:
+		err = st.AddPortsCheck(host, cf.ScanOpts, cf.ID, cf.DependsOn, cf.MQTTNotify, cf.PushoverNotify, cf.TelegramNotify)
+	case config.CheckComposite:
+		err = st.AddCompositeCheck(host, cf.AllOf, cf.AnyOf, cf.ID, cf.DependsOn, cf.MQTTNotify, cf.PushoverNotify, cf.TelegramNotify)
+	case config.CheckWebhook:
+		err = st.AddWebhookCheck(host, cf.MaxAge, cf.WebhookToken, cf.ID, cf.DependsOn, cf.MQTTNotify, cf.PushoverNotify, cf.TelegramNotify)
+	case config.CheckS3:
+		err = st.AddS3Check(host, cf.URL, cf.S3Opts, cf.MaxAge, cf.ID, cf.DependsOn, cf.MQTTNotify, cf.PushoverNotify, cf.TelegramNotify)
+	case config.CheckIPP:
+		err = st.AddIPPCheck(host, cf.URL, cf.IPPOpts, cf.ID, cf.DependsOn, cf.MQTTNotify, cf.PushoverNotify, cf.TelegramNotify)
+	case config.CheckSMB:
+		err = st.AddSMBCheck(host, cf.SMBOpts, cf.ID, cf.DependsOn, cf.MQTTNotify, cf.PushoverNotify, cf.TelegramNotify)
+	case config.CheckKafka:
+		err = st.AddKafkaCheck(host, cf.KafkaOpts, cf.ID, cf.DependsOn, cf.MQTTNotify, cf.PushoverNotify, cf.TelegramNotify)
+	case config.CheckAMQP:
+		err = st.AddAMQPCheck(host, cf.AMQPOpts, cf.ID, cf.DependsOn, cf.MQTTNotify, cf.PushoverNotify, cf.TelegramNotify)
+	case config.CheckKubernetes:
+		err = st.AddKubernetesCheck(host, cf.URL, cf.KubernetesOpts, cf.ID, cf.DependsOn, cf.MQTTNotify, cf.PushoverNotify, cf.TelegramNotify)
+	case config.CheckProxmox:
+		err = st.AddProxmoxCheck(host, cf.ProxmoxOpts, cf.ID, cf.DependsOn, cf.MQTTNotify, cf.PushoverNotify, cf.TelegramNotify)
+	case config.CheckESXi:
+		err = st.AddESXiCheck(host, cf.ESXiOpts, cf.ID, cf.DependsOn, cf.MQTTNotify, cf.PushoverNotify, cf.TelegramNotify)
+	case config.CheckUPS:
+		err = st.AddUPSCheck(host, cf.UPSOpts, cf.ID, cf.DependsOn, cf.MQTTNotify, cf.PushoverNotify, cf.TelegramNotify)
+	case config.CheckSpeedtest:
+		err = st.AddSpeedtestCheck(host, cf.SpeedtestOpts, cf.ID, cf.DependsOn, cf.MQTTNotify, cf.PushoverNotify, cf.TelegramNotify)
+	case config.CheckDomain:
+		err = st.AddDomainCheck(host, cf.DomainOpts, cf.ID, cf.DependsOn, cf.MQTTNotify, cf.PushoverNotify, cf.TelegramNotify)
+	case config.CheckDNSBL:
+		err = st.AddDNSBLCheck(host, cf.DNSBLOpts, cf.ID, cf.DependsOn, cf.MQTTNotify, cf.PushoverNotify, cf.TelegramNotify)
+	case config.CheckPublicIP:
+		err = st.AddPublicIPCheck(host, strings.TrimSpace(cf.URL), cf.PublicIPOpts, cf.ID, cf.DependsOn, cf.MQTTNotify, cf.PushoverNotify, cf.TelegramNotify)
+	case config.CheckFile:
+		err = st.AddFileCheck(host, cf.FileOpts, cf.MaxAge, cf.ID, cf.DependsOn, cf.MQTTNotify, cf.PushoverNotify, cf.TelegramNotify)
+	default:
+		err = st.AddPingCheck(host, cf.ID, cf.DependsOn, cf.MQTTNotify, cf.PushoverNotify, cf.TelegramNotify)
+	}
+	if err != nil {
+		return err
+	}
+	if cf.Name != "" {
+		if err := st.SetCheckName(host, idx, cf.Name); err != nil {
+			return err
+		}
+	}
+	if cf.DialOpts != (checks.DialOptions{}) {
+		if err := st.SetCheckDialOptions(host, idx, cf.DialOpts); err != nil {
+			return err
+		}
+	}
+	if cf.PingOpts != (checks.PingOptions{}) {
+		if err := st.SetCheckPingOptions(host, idx, cf.PingOpts); err != nil {
+			return err
+		}
+	}
+	if cf.SMSNotify {
+		if err := st.SetCheckSMS(host, idx, true); err != nil {
+			return err
+		}
+	}
+	if cf.Invert {
+		if err := st.SetCheckInvert(host, idx, true); err != nil {
+			return err
+		}
+	}
+	if cf.Severity == config.SeverityWarning {
+		return nil
+	}
+	return st.SetCheckSeverity(host, idx, cf.Severity)
+}
+
+// updateCheck applies a validated edit to the existing check at cf.Idx
+func (s *Server) updateCheck(st *state.State, host string, cf checkForm) error {
+	switch config.CheckType(cf.Type) {
+	case config.CheckHTTP:
+		return st.UpdateHTTPCheck(host, cf.Idx, cf.URL, cf.Expect, cf.HTTPOpts, cf.ID, cf.DependsOn, cf.MQTTNotify, cf.PushoverNotify, cf.TelegramNotify)
+	case config.CheckTCP:
+		return st.UpdateTCPCheck(host, cf.Idx, cf.Port, cf.ID, cf.DependsOn, cf.MQTTNotify, cf.PushoverNotify, cf.TelegramNotify)
+	case config.CheckSSH:
+		if err := st.UpdateCheckDependencies(host, cf.Idx, cf.ID, cf.DependsOn, cf.MQTTNotify, cf.PushoverNotify, cf.TelegramNotify); err != nil {
+			return err
+		}
+		return st.SetCheckSSHOptions(host, cf.Idx, cf.SSHOpts)
+	case config.CheckWS:
+		if err := st.UpdateCheckDependencies(host, cf.Idx, cf.ID, cf.DependsOn, cf.MQTTNotify, cf.PushoverNotify, cf.TelegramNotify); err != nil {
+			return err
+		}
+		return st.SetCheckWebSocket(host, cf.Idx, cf.URL, cf.WSOpts)
+	case config.CheckPorts:
+		if err := st.UpdateCheckDependencies(host, cf.Idx, cf.ID, cf.DependsOn, cf.MQTTNotify, cf.PushoverNotify, cf.TelegramNotify); err != nil {
+			return err
+		}
+		return st.SetCheckPorts(host, cf.Idx, cf.ScanOpts)
+	case config.CheckComposite:
+		if err := st.UpdateCheckDependencies(host, cf.Idx, cf.ID, cf.DependsOn, cf.MQTTNotify, cf.PushoverNotify, cf.TelegramNotify); err != nil {
+			return err
+		}
+		return st.SetCheckComposite(host, cf.Idx, cf.AllOf, cf.AnyOf)
+	case config.CheckS3:
+		if err := st.UpdateCheckDependencies(host, cf.Idx, cf.ID, cf.DependsOn, cf.MQTTNotify, cf.PushoverNotify, cf.TelegramNotify); err != nil {
+			return err
+		}
+		return st.SetCheckS3(host, cf.Idx, cf.URL, cf.S3Opts, cf.MaxAge)
+	case config.CheckIPP:
+		if err := st.UpdateCheckDependencies(host, cf.Idx, cf.ID, cf.DependsOn, cf.MQTTNotify, cf.PushoverNotify, cf.TelegramNotify); err != nil {
+			return err
+		}
+		return st.SetCheckIPP(host, cf.Idx, cf.URL, cf.IPPOpts)
+	case config.CheckSMB:
+		if err := st.UpdateCheckDependencies(host, cf.Idx, cf.ID, cf.DependsOn, cf.MQTTNotify, cf.PushoverNotify, cf.TelegramNotify); err != nil {
+			return err
+		}
+		return st.SetCheckSMB(host, cf.Idx, cf.SMBOpts)
+	case config.CheckKafka:
+		if err := st.UpdateCheckDependencies(host, cf.Idx, cf.ID, cf.DependsOn, cf.MQTTNotify, cf.PushoverNotify, cf.TelegramNotify); err != nil {
+			return err
+		}
+		return st.SetCheckKafka(host, cf.Idx, cf.KafkaOpts)
+	case config.CheckAMQP:
+		if err := st.UpdateCheckDependencies(host, cf.Idx, cf.ID, cf.DependsOn, cf.MQTTNotify, cf.PushoverNotify, cf.TelegramNotify); err != nil {
+			return err
+		}
+		return st.SetCheckAMQP(host, cf.Idx, cf.AMQPOpts)
+	case config.CheckKubernetes:
+		if err := st.UpdateCheckDependencies(host, cf.Idx, cf.ID, cf.DependsOn, cf.MQTTNotify, cf.PushoverNotify, cf.TelegramNotify); err != nil {
+			return err
+		}
+		return st.SetCheckKubernetes(host, cf.Idx, cf.URL, cf.KubernetesOpts)
+	case config.CheckProxmox:
+		if err := st.UpdateCheckDependencies(host, cf.Idx, cf.ID, cf.DependsOn, cf.MQTTNotify, cf.PushoverNotify, cf.TelegramNotify); err != nil {
+			return err
+		}
+		return st.SetCheckProxmox(host, cf.Idx, cf.ProxmoxOpts)
+	case config.CheckESXi:
+		if err := st.UpdateCheckDependencies(host, cf.Idx, cf.ID, cf.DependsOn, cf.MQTTNotify, cf.PushoverNotify, cf.TelegramNotify); err != nil {
+			return err
+		}
+		return st.SetCheckESXi(host, cf.Idx, cf.ESXiOpts)
+	case config.CheckUPS:
+		if err := st.UpdateCheckDependencies(host, cf.Idx, cf.ID, cf.DependsOn, cf.MQTTNotify, cf.PushoverNotify, cf.TelegramNotify); err != nil {
+			return err
+		}
+		return st.SetCheckUPS(host, cf.Idx, cf.UPSOpts)
+	case config.CheckSpeedtest:
+		if err := st.UpdateCheckDependencies(host, cf.Idx, cf.ID, cf.DependsOn, cf.MQTTNotify, cf.PushoverNotify, cf.TelegramNotify); err != nil {
+			return err
+		}
+		return st.SetCheckSpeedtest(host, cf.Idx, cf.SpeedtestOpts)
+	case config.CheckPublicIP:
+		if err := st.UpdateCheckDependencies(host, cf.Idx, cf.ID, cf.DependsOn, cf.MQTTNotify, cf.PushoverNotify, cf.TelegramNotify); err != nil {
+			return err
+		}
+		return st.SetCheckPublicIP(host, cf.Idx, strings.TrimSpace(cf.URL), cf.PublicIPOpts)
+	case config.CheckDomain:
+		if err := st.UpdateCheckDependencies(host, cf.Idx, cf.ID, cf.DependsOn, cf.MQTTNotify, cf.PushoverNotify, cf.TelegramNotify); err != nil {
+			return err
+		}
+		return st.SetCheckDomain(host, cf.Idx, cf.DomainOpts)
+	case config.CheckDNSBL:
+		if err := st.UpdateCheckDependencies(host, cf.Idx, cf.ID, cf.DependsOn, cf.MQTTNotify, cf.PushoverNotify, cf.TelegramNotify); err != nil {
+			return err
+		}
+		return st.SetCheckDNSBL(host, cf.Idx, cf.DNSBLOpts)
+	case config.CheckFile:
+		if err := st.UpdateCheckDependencies(host, cf.Idx, cf.ID, cf.DependsOn, cf.MQTTNotify, cf.PushoverNotify, cf.TelegramNotify); err != nil {
+			return err
+		}
+		return st.SetCheckFile(host, cf.Idx, cf.FileOpts, cf.MaxAge)
+	default:
+		// For ping checks, just update the dependencies
+		return st.UpdateCheckDependencies(host, cf.Idx, cf.ID, cf.DependsOn, cf.MQTTNotify, cf.PushoverNotify, cf.TelegramNotify)
+	}
+}
+
+// updateChecks applies validated edits, including notes, to existing checks.
+// Failures are logged so one bad check doesn't stop the rest being saved.
+func (s *Server) updateChecks(st *state.State, host string, forms []checkForm) {
+	s.followIDRenames(host, forms)
+	for _, cf := range forms {
+		if err := s.updateCheck(st, host, cf); err != nil {
+			log.Printf("update check %d on %q failed: %v", cf.Idx, host, err)
+			continue
+		}
+		if err := st.SetCheckName(host, cf.Idx, cf.Name); err != nil {
+			log.Printf("update name for check %d on %q failed: %v", cf.Idx, host, err)
+		}
+		if err := st.SetCheckNotes(host, cf.Idx, cf.Notes, cf.RunbookURL); err != nil {
+			log.Printf("update notes for check %d on %q failed: %v", cf.Idx, host, err)
+		}
+		if err := st.SetCheckSeverity(host, cf.Idx, cf.Severity); err != nil {
+			log.Printf("update severity for check %d on %q failed: %v", cf.Idx, host, err)
+		}
+		if err := st.SetCheckSchedule(host, cf.Idx, cf.Schedule); err != nil {
+			log.Printf("update schedule for check %d on %q failed: %v", cf.Idx, host, err)
+		}
+		if err := st.SetCheckShoutrrr(host, cf.Idx, cf.ShoutrrrNotify); err != nil {
+			log.Printf("update shoutrrr labels for check %d on %q failed: %v", cf.Idx, host, err)
+		}
+		if err := st.SetCheckSMS(host, cf.Idx, cf.SMSNotify); err != nil {
+			log.Printf("update sms for check %d on %q failed: %v", cf.Idx, host, err)
+		}
+		if err := st.SetCheckInvert(host, cf.Idx, cf.Invert); err != nil {
+			log.Printf("update invert for check %d on %q failed: %v", cf.Idx, host, err)
+		}
+		if t := config.CheckType(cf.Type); t == config.CheckHTTP || t == config.CheckTCP || t == config.CheckPorts {
+			if err := st.SetCheckDialOptions(host, cf.Idx, cf.DialOpts); err != nil {
+				log.Printf("update source for check %d on %q failed: %v", cf.Idx, host, err)
+			}
+		}
+		if config.CheckType(cf.Type) == config.CheckPing {
+			if err := st.SetCheckPingOptions(host, cf.Idx, cf.PingOpts); err != nil {
+				log.Printf("update ping method for check %d on %q failed: %v", cf.Idx, host, err)
+			}
+		}
+	}
+}
+
+// formIndex returns vals[i], or "" when the parallel form array is short
+func formIndex(vals []string, i int) string {
+	if i < len(vals) {
+		return vals[i]
+	}
+	return ""
+}
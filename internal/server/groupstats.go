@@ -0,0 +1,89 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/andrewsjg/simple-healthchecker/copilot/internal/state"
+)
+
+// groupStatsView is the data for stats_groups.html
+type groupStatsView struct {
+	By     string // "tag" or "host"
+	Groups []state.GroupStats
+}
+
+// apiStats is the JSON form of state.AggregateStats
+type apiStats struct {
+	Name          string  `json:"name,omitempty"`
+	Hosts         int     `json:"hosts"`
+	HostsUp       int     `json:"hosts_up"`
+	HostsDegraded int     `json:"hosts_degraded"`
+	HostsDown     int     `json:"hosts_down"`
+	Checks        int     `json:"checks"`
+	Up            int     `json:"up"`
+	Down          int     `json:"down"`
+	Blocked       int     `json:"blocked"`
+	InfoDown      int     `json:"info_down"`
+	Pending       int     `json:"pending"`
+	Disabled      int     `json:"disabled"`
+	OffSchedule   int     `json:"off_schedule"`
+	ExpectedDown  int     `json:"expected_down"`
+	UptimePct     float64 `json:"uptime_pct"`
+}
+
+func newAPIStats(name string, st state.AggregateStats) apiStats {
+	return apiStats{
+		Name:          name,
+		Hosts:         st.TotalHosts,
+		HostsUp:       st.HostsUp,
+		HostsDegraded: st.HostsDegraded,
+		HostsDown:     st.HostsDown,
+		Checks:        st.TotalChecks,
+		Up:            st.ChecksUp,
+		Down:          st.ChecksDown,
+		Blocked:       st.ChecksParentFailed,
+		InfoDown:      st.ChecksInfoDown,
+		Pending:       st.ChecksUnknown,
+		Disabled:      st.ChecksDisabled,
+		OffSchedule:   st.ChecksOffSchedule,
+		ExpectedDown:  st.ChecksExpectedDown,
+		UptimePct:     st.OverallUptime,
+	}
+}
+
+// handleAPIStats returns the overall statistics as JSON, plus a breakdown
+// per tag or per host with ?group=tag|host
+func (s *Server) handleAPIStats(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.WriteHeader(405)
+		return
+	}
+	out := struct {
+		Overall apiStats   `json:"overall"`
+		Groups  []apiStats `json:"groups,omitempty"`
+	}{Overall: newAPIStats("", s.st.GetAggregateStats())}
+	if by := r.FormValue("group"); by != "" {
+		groups, err := s.st.GetGroupedStats(by)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		out.Groups = make([]apiStats, 0, len(groups))
+		for _, g := range groups {
+			out.Groups = append(out.Groups, newAPIStats(g.Name, g.Stats))
+		}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(out)
+}
+
+// groupedStats loads the per-group view for the analytics page, by tag
+// unless by is "host"
+func (s *Server) groupedStats(by string) groupStatsView {
+	if by != "host" {
+		by = "tag"
+	}
+	groups, _ := s.st.GetGroupedStats(by)
+	return groupStatsView{By: by, Groups: groups}
+}
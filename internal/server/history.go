@@ -0,0 +1,75 @@
+package server
+
+import (
+	"fmt"
+	"html/template"
+	"net"
+	"net/http"
+	"strconv"
+
+	"github.com/andrewsjg/simple-healthchecker/copilot/internal/state"
+)
+
+// changes returns the state to make r's changes through, so any config
+// saves they cause are put down to whoever sent it in the change history:
+// the signed-in user, or the client's address when sign-in isn't
+// configured
+func (s *Server) changes(r *http.Request) *state.State {
+	actor := r.RemoteAddr
+	if host, _, err := net.SplitHostPort(r.RemoteAddr); err == nil {
+		actor = host
+	}
+	if sess, ok := s.auth.session(r); ok {
+		actor = sess.User
+	}
+	return s.st.As(actor, r.Method+" "+r.URL.Path)
+}
+
+// historyDiffView is the data for history_diff.html
+type historyDiffView struct {
+	Revision state.Revision
+	Changes  []state.ConfigChange
+}
+
+// handleHistory renders the list of saved config versions, newest first
+func (s *Server) handleHistory(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	_ = s.tpl.ExecuteTemplate(w, "history_list.html", s.st.Revisions())
+}
+
+// handleHistoryDiff renders what changed in one config version
+func (s *Server) handleHistoryDiff(w http.ResponseWriter, r *http.Request) {
+	id, _ := strconv.Atoi(r.FormValue("id"))
+	rev, changes, ok := s.st.RevisionChanges(id)
+	if !ok {
+		w.WriteHeader(404)
+		_, _ = w.Write([]byte(`<div class="alert alert-error">That version is no longer in the history.</div>`))
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	_ = s.tpl.ExecuteTemplate(w, "history_diff.html", historyDiffView{Revision: rev, Changes: changes})
+}
+
+// handleHistoryRestore previews putting the hosts back as they were in a
+// saved version, to be applied like an uploaded replacement
+func (s *Server) handleHistoryRestore(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.WriteHeader(405)
+		return
+	}
+	id, _ := strconv.Atoi(r.FormValue("id"))
+	rev, hosts, err := s.st.RevisionHosts(id)
+	if err != nil {
+		w.WriteHeader(422)
+		_, _ = w.Write([]byte(fmt.Sprintf(`<div class="alert alert-error">%s</div>`, template.HTMLEscapeString(err.Error()))))
+		return
+	}
+	diff := s.st.PreviewReplace(hosts)
+	if diff.Empty() {
+		_, _ = w.Write([]byte(`<div class="alert alert-success">No changes: the hosts already match that version.</div>`))
+		return
+	}
+	source := fmt.Sprintf("version %d (%s)", rev.ID, rev.Time.In(s.st.DisplayLocation()).Format("Jan 2 15:04"))
+	token := s.pending.put(pendingChange{replace: true, hosts: hosts, diff: diff, source: source})
+	_ = s.tpl.ExecuteTemplate(w, "config_preview.html", configPreviewView{Token: token, Replace: true, Source: source, Diff: diff})
+}
@@ -0,0 +1,155 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"hash/fnv"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/gorilla/websocket"
+
+	"github.com/andrewsjg/simple-healthchecker/copilot/internal/state"
+)
+
+// hostsSocketBatch is how long the hosts socket lets changes gather before
+// pushing them, as check results tend to arrive in bursts
+const hostsSocketBatch = 500 * time.Millisecond
+
+// hostsSync is pushed when hosts are added, removed or renamed. Swapping it
+// in makes the dashboard reload the grid with its own filters and sort.
+const hostsSync = `<div id="hosts-sync" hidden hx-get="/hosts" hx-include="#host-filters" hx-target="#hosts" hx-trigger="load"></div>`
+
+// hostsFilter is the grid's filters as the dashboard sends them when they
+// change, using the ws extension's ws-send
+type hostsFilter struct {
+	Search string `json:"q"`
+	Status string `json:"status"`
+	Sort   string `json:"sort"`
+}
+
+// handleHostsSocket pushes host cards to the dashboard as they change, for
+// the htmx ws extension to swap in by ID. A toggle, edit or check result in
+// one browser shows in every other one straight away, rather than on their
+// next poll. Only the cards matching the grid's search, status filter and
+// sort are pushed: the socket is opened with them in its query string, and
+// the dashboard sends them again whenever they change.
+func (s *Server) handleHostsSocket(w http.ResponseWriter, r *http.Request) {
+	conn, err := streamUpgrader.Upgrade(w, r, nil)
+	if err != nil {
+		// Upgrade has already replied with an error
+		return
+	}
+	defer conn.Close()
+	conn.SetReadLimit(4096)
+
+	// Reading also handles the client's close frames and notices it going
+	// away
+	q := s.hostQuery(r)
+	filters := make(chan state.HostQuery)
+	closed, left := make(chan struct{}), make(chan struct{})
+	defer close(left)
+	go func() {
+		defer close(closed)
+		for {
+			_, data, err := conn.ReadMessage()
+			if err != nil {
+				return
+			}
+			var f hostsFilter
+			if json.Unmarshal(data, &f) != nil {
+				continue
+			}
+			next := state.HostQuery{Search: strings.TrimSpace(f.Search), Status: f.Status, Sort: f.Sort, Tags: q.Tags}
+			select {
+			case filters <- next:
+			case <-left:
+				return
+			}
+		}
+	}()
+
+	// The page was rendered with the current cards, so the first pass only
+	// notes what the browser already has
+	sent := map[string]uint64{}
+	changed := s.st.Changed()
+	names, _ := s.renderChangedCards(sent, nil, q)
+
+	heartbeat := time.NewTicker(streamHeartbeat)
+	defer heartbeat.Stop()
+	for {
+		var err error
+		select {
+		case q = <-filters:
+			// The grid reloads itself when its filters change, so this
+			// only notes what it will show
+			names, _ = s.renderChangedCards(sent, nil, q)
+		case <-changed:
+			select {
+			case <-time.After(hostsSocketBatch):
+			case <-closed:
+				return
+			case <-s.done:
+				return
+			}
+			changed = s.st.Changed()
+			var msg []byte
+			names, msg = s.renderChangedCards(sent, names, q)
+			if len(msg) > 0 {
+				err = conn.WriteMessage(websocket.TextMessage, msg)
+			}
+		case <-heartbeat.C:
+			err = conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(10*time.Second))
+		case <-closed:
+			return
+		case <-s.done:
+			_ = conn.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseGoingAway, "shutting down"))
+			return
+		}
+		if err != nil {
+			return
+		}
+	}
+}
+
+// renderChangedCards renders the cards of the hosts q matches, as the grid
+// shows them, returning the ones that differ from what sent says the
+// browser has, and hostsSync if the hosts matched or their order changed
+// since names. A host coming to match the status filter, or no longer
+// matching it, reloads the grid that way. sent is updated to match. Nil
+// names marks the first pass, which only fills in sent.
+func (s *Server) renderChangedCards(sent map[string]uint64, names []string, q state.HostQuery) ([]string, []byte) {
+	hosts := s.st.QueryHosts(q)
+	current := make([]string, len(hosts))
+	for i, hs := range hosts {
+		current[i] = hs.Name
+	}
+	var msg, card bytes.Buffer
+	if names != nil && !slices.Equal(names, current) {
+		msg.WriteString(hostsSync)
+	}
+	for _, hs := range hosts {
+		card.Reset()
+		if s.tpl.ExecuteTemplate(&card, "host_card.html", hs) != nil {
+			continue
+		}
+		h := fnv.New64a()
+		h.Write(card.Bytes())
+		sum := h.Sum64()
+		if prev, ok := sent[hs.Name]; ok && prev == sum {
+			continue
+		}
+		if names != nil {
+			msg.Write(card.Bytes())
+		}
+		sent[hs.Name] = sum
+	}
+	for name := range sent {
+		if !slices.Contains(current, name) {
+			delete(sent, name)
+		}
+	}
+	return current, msg.Bytes()
+}
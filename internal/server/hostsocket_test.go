@@ -0,0 +1,114 @@
+package server
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+
+	"github.com/andrewsjg/simple-healthchecker/copilot/internal/config"
+	"github.com/andrewsjg/simple-healthchecker/copilot/internal/state"
+)
+
+func TestChangedCardsFollowFilters(t *testing.T) {
+	st := state.New(&config.Config{Hosts: []config.Host{
+		{Name: "alpha", Address: "10.0.0.1", Checks: []config.Check{{Type: config.CheckPing, Enabled: true}}},
+		{Name: "beta", Address: "10.0.0.2", Checks: []config.Check{{Type: config.CheckPing, Enabled: true}}},
+		{Name: "gamma", Address: "10.0.0.3", Checks: []config.Check{{Type: config.CheckPing, Enabled: true}}},
+	}})
+	s := New(st)
+
+	// Checks that haven't run yet leave every host pending
+	tests := []struct {
+		name     string
+		query    state.HostQuery
+		toggle   string // Host whose check is disabled, making it disabled
+		wantSync bool
+		want     []string // Hosts whose cards are pushed
+	}{
+		{"no filter", state.HostQuery{}, "beta", false, []string{"beta"}},
+		{"host leaves the status filter", state.HostQuery{Status: state.HostPending}, "beta", true, nil},
+		{"host joins the status filter", state.HostQuery{Status: state.HostDisabled}, "beta", true, []string{"beta"}},
+		{"change outside the search", state.HostQuery{Search: "alpha"}, "beta", false, nil},
+		{"change inside the search", state.HostQuery{Search: "10.0.0.2"}, "beta", false, []string{"beta"}},
+		{"order changes with the sort", state.HostQuery{Sort: "status"}, "alpha", true, []string{"alpha"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, h := range []string{"alpha", "beta", "gamma"} {
+				st.Toggle(h, 0, true)
+			}
+			sent := map[string]uint64{}
+			names, _ := s.renderChangedCards(sent, nil, tt.query)
+
+			st.Toggle(tt.toggle, 0, false)
+			_, msg := s.renderChangedCards(sent, names, tt.query)
+			body := string(msg)
+			if got := strings.Contains(body, `id="hosts-sync"`); got != tt.wantSync {
+				t.Errorf("grid reload pushed = %v, want %v", got, tt.wantSync)
+			}
+			for _, h := range []string{"alpha", "beta", "gamma"} {
+				want := false
+				for _, w := range tt.want {
+					want = want || w == h
+				}
+				if got := strings.Contains(body, `id="host-`+h+`"`); got != want {
+					t.Errorf("card for %s pushed = %v, want %v", h, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestSocketURLCarriesFilters(t *testing.T) {
+	tests := []struct {
+		query state.HostQuery
+		want  string
+	}{
+		{state.HostQuery{}, "/ws/hosts"},
+		{state.HostQuery{Tags: []string{"red"}}, "/ws/hosts"},
+		{state.HostQuery{Search: "nas lan", Status: "down", Sort: "uptime"}, "/ws/hosts?q=nas+lan&sort=uptime&status=down"},
+	}
+	for _, tt := range tests {
+		if got := (hostsView{Query: tt.query}).SocketURL(); got != tt.want {
+			t.Errorf("SocketURL(%+v) = %q, want %q", tt.query, got, tt.want)
+		}
+	}
+}
+
+func TestHostsSocketTakesFiltersFromTheDashboard(t *testing.T) {
+	st := state.New(&config.Config{Hosts: []config.Host{
+		{Name: "alpha", Address: "10.0.0.1", Checks: []config.Check{{Type: config.CheckPing, Enabled: true}}},
+		{Name: "beta", Address: "10.0.0.2", Checks: []config.Check{{Type: config.CheckPing, Enabled: true}}},
+	}})
+	srv := httptest.NewServer(New(st).handler())
+	defer srv.Close()
+
+	// The page opens the socket with the grid's filters
+	if body := get(srv.Config.Handler, "/?q=alpha", "").Body.String(); !strings.Contains(body, `ws-connect="/ws/hosts?q=alpha"`) {
+		t.Fatal("the dashboard doesn't open the socket with its search")
+	}
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(srv.URL, "http")+"/ws/hosts?q=alpha", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	// As ws-send sends the filter form when the search changes
+	if err := conn.WriteJSON(map[string]any{"q": "beta", "status": "", "sort": "", "HEADERS": map[string]string{"HX-Request": "true"}}); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(100 * time.Millisecond)
+	st.Toggle("beta", 0, false)
+
+	_ = conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	_, msg, err := conn.ReadMessage()
+	if err != nil {
+		t.Fatalf("nothing pushed after beta changed: %v", err)
+	}
+	if body := string(msg); !strings.Contains(body, `id="host-beta"`) || strings.Contains(body, `id="host-alpha"`) {
+		t.Errorf("pushed %q, want beta's card alone", body)
+	}
+}
@@ -0,0 +1,74 @@
+package server
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/andrewsjg/simple-healthchecker/copilot/internal/state"
+)
+
+// monitorRows is how many recent runs the monitor health page lists
+const monitorRows = 60
+
+// tickRow is one scheduler run on the monitor health page
+type tickRow struct {
+	state.TickStats
+	Pct int // Duration as a percentage of the interval, capped at 100
+}
+
+// monitorView is the data for monitor.html
+type monitorView struct {
+	Health state.SchedulerHealth
+	Last   state.TickStats
+	HasRun bool
+	Rows   []tickRow
+}
+
+// handleMonitor shows how the monitoring loop itself is doing: how long
+// recent runs took, and whether any overran the interval
+func (s *Server) handleMonitor(w http.ResponseWriter, r *http.Request) {
+	h := s.st.GetSchedulerHealth()
+	v := monitorView{Health: h}
+	v.Last, v.HasRun = h.Last()
+	for i, t := range h.Recent {
+		if i == monitorRows {
+			break
+		}
+		row := tickRow{TickStats: t}
+		if h.Interval > 0 {
+			row.Pct = min(100, int(100*t.Duration/h.Interval))
+		}
+		v.Rows = append(v.Rows, row)
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	_ = s.tpl.ExecuteTemplate(w, "monitor.html", v)
+}
+
+// handleMetrics exposes the scheduler's own metrics in the Prometheus text
+// format, so the monitor can itself be monitored
+func (s *Server) handleMetrics(w http.ResponseWriter, r *http.Request) {
+	h := s.st.GetSchedulerHealth()
+	var b strings.Builder
+	metric := func(name, kind, help string, value float64) {
+		fmt.Fprintf(&b, "# HELP %s %s\n# TYPE %s %s\n%s %g\n", name, help, name, kind, name, value)
+	}
+	metric("poke443_scheduler_interval_seconds", "gauge", "Configured interval between scheduler runs.", h.Interval.Seconds())
+	metric("poke443_scheduler_runs_total", "counter", "Scheduler runs since startup, including Run now.", float64(h.Runs))
+	metric("poke443_scheduler_overruns_total", "counter", "Scheduled runs that took longer than the interval.", float64(h.Overruns))
+	metric("poke443_scheduler_skipped_ticks_total", "counter", "Scheduled runs skipped because the one before was still going.", float64(h.SkippedTicks))
+	metric("poke443_scheduler_gaps_total", "counter", "Stretches with no runs, e.g. while the machine slept, which count as no data.", float64(h.Gaps))
+	metric("poke443_scheduler_gap_seconds_total", "counter", "Time in those stretches.", h.GapTime.Seconds())
+	metric("poke443_scheduler_run_seconds_total", "counter", "Time spent in scheduler runs.", h.RunTime.Seconds())
+	metric("poke443_scheduler_checks_total", "counter", "Checks run.", float64(h.Checks))
+	metric("poke443_scheduler_check_failures_total", "counter", "Checks run that were down.", float64(h.Failures))
+	metric("poke443_scheduler_notifications_total", "counter", "Notifications delivered during scheduler runs.", float64(h.Notifications))
+	metric("poke443_scheduler_notification_seconds_total", "counter", "Time spent delivering notifications during scheduler runs.", h.NotifyTime.Seconds())
+	if last, ok := h.Last(); ok {
+		metric("poke443_scheduler_last_run_timestamp_seconds", "gauge", "When the last scheduler run started, as a Unix time.", float64(last.Start.UnixNano())/float64(time.Second))
+		metric("poke443_scheduler_last_run_duration_seconds", "gauge", "How long the last scheduler run took.", last.Duration.Seconds())
+	}
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	_, _ = w.Write([]byte(b.String()))
+}
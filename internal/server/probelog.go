@@ -0,0 +1,36 @@
+package server
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/andrewsjg/simple-healthchecker/copilot/internal/state"
+)
+
+// probeLogView is the data for probe_log_modal.html
+type probeLogView struct {
+	Host     string
+	Idx      int
+	Check    state.CheckStatus
+	Attempts []state.ProbeAttempt
+}
+
+// handleProbeLog opens the drawer listing a check's recent probe attempts.
+// With rows set it renders just the list, which the drawer refreshes.
+func (s *Server) handleProbeLog(w http.ResponseWriter, r *http.Request) {
+	host := r.FormValue("host")
+	idx, _ := strconv.Atoi(r.FormValue("idx"))
+	c, attempts, err := s.st.ProbeLog(host, idx)
+	if err != nil {
+		w.WriteHeader(404)
+		_, _ = w.Write([]byte(err.Error()))
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	view := probeLogView{Host: host, Idx: idx, Check: c, Attempts: attempts}
+	if r.FormValue("rows") != "" {
+		_ = s.tpl.ExecuteTemplate(w, "probe_log_rows.html", view)
+		return
+	}
+	_ = s.tpl.ExecuteTemplate(w, "probe_log_modal.html", view)
+}
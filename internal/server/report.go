@@ -0,0 +1,63 @@
+package server
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/andrewsjg/simple-healthchecker/copilot/internal/state"
+)
+
+// reportPreset is a span the report page offers a link to
+type reportPreset struct {
+	Label    string
+	From, To string // YYYY-MM-DD
+}
+
+// reportView is the data for report.html
+type reportView struct {
+	state.Report
+	From, To  string // The span's first and last day, as YYYY-MM-DD
+	Presets   []reportPreset
+	Error     string
+	Generated time.Time
+}
+
+// reportPresets are the last full month, the month so far and the last 90
+// days, counted from today
+func reportPresets(today time.Time) []reportPreset {
+	monthStart := time.Date(today.Year(), today.Month(), 1, 0, 0, 0, 0, today.Location())
+	day := func(t time.Time) string { return t.Format("2006-01-02") }
+	return []reportPreset{
+		{"Last month", day(monthStart.AddDate(0, -1, 0)), day(monthStart.AddDate(0, 0, -1))},
+		{"This month", day(monthStart), day(today)},
+		{"Last 90 days", day(today.AddDate(0, 0, -89)), day(today)},
+	}
+}
+
+// handleReport renders the printable status report for ?from= to ?to=,
+// both YYYY-MM-DD and included, or for last month when neither is given.
+// It is laid out to be printed, or saved as a PDF, from the browser.
+func (s *Server) handleReport(w http.ResponseWriter, r *http.Request) {
+	loc := s.st.DisplayLocation()
+	now := time.Now().In(loc)
+	v := reportView{Presets: reportPresets(now), Generated: now}
+	v.From, v.To = r.FormValue("from"), r.FormValue("to")
+	if v.From == "" && v.To == "" {
+		v.From, v.To = v.Presets[0].From, v.Presets[0].To
+	}
+	from, err := time.ParseInLocation("2006-01-02", v.From, loc)
+	to, err2 := time.ParseInLocation("2006-01-02", v.To, loc)
+	switch {
+	case err != nil || err2 != nil:
+		v.Error = "Choose the first and last day of the report."
+	case to.Before(from):
+		v.Error = "The report ends before it starts."
+	default:
+		v.Report = s.st.Report(from, to)
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if v.Error != "" {
+		w.WriteHeader(422)
+	}
+	_ = s.tpl.ExecuteTemplate(w, "report.html", v)
+}
@@ -4,19 +4,23 @@ import (
 	"context"
 	"embed"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"html/template"
 	"io"
 	"log"
-	"math"
 	"net/http"
-	"sort"
+	"net/url"
 	"strconv"
 	"strings"
 	"time"
 
+	"github.com/andrewsjg/simple-healthchecker/copilot/internal/checks"
 	"github.com/andrewsjg/simple-healthchecker/copilot/internal/config"
+	"github.com/andrewsjg/simple-healthchecker/copilot/internal/schema"
 	"github.com/andrewsjg/simple-healthchecker/copilot/internal/state"
+	"github.com/andrewsjg/simple-healthchecker/copilot/internal/twilio"
+	"github.com/andrewsjg/simple-healthchecker/copilot/internal/validate"
 )
 
 //go:embed templates/*
@@ -26,10 +30,17 @@ type Server struct {
 	st   *state.State
 	http *http.Server
 	tpl  *template.Template
+	done chan struct{} // Closed on shutdown to end open event streams
+
+	pending pendingChanges // Previewed imports awaiting confirmation
+	assets  *assets        // Embedded CSS and JS served under /static/
+	auth    *authenticator // Single sign-on, when configured
 }
 
 func New(st *state.State) *Server {
+	static := newAssets()
 	funcs := template.FuncMap{
+		"asset": static.path,
 		"slug": func(s string) string {
 			b := make([]rune, 0, len(s))
 			for _, r := range s {
@@ -45,21 +56,69 @@ func New(st *state.State) *Server {
 		"donutChart":             generateDonutChartSVG,
 		"heatmap":                generateHeatmapSVG,
 		"uptimeBar":              generateUptimeBarSVG,
-		"smokepingChart":         generateSmokepingChartSVG,
 		"formatUptime":           formatUptime,
+		"meanTime":               formatMeanTime,
+		"ageLimit":               formatAgeLimit,
+		"latency":                checks.FormatLatency,
 		"healthColor":            healthScoreColor,
 		"healthColorWithBlocked": healthScoreColorWithBlocked,
 		"checkUptime":            calculateCheckUptime,
 		"checkHeatmap":           extractHeatmapData,
+		"hxVals":                 hxVals,
+		"anyEnabled":             anyChecksEnabled,
+		"join":                   strings.Join,
+		"displayTimezone":        st.DisplayTimezone,
+		"statusTitle":            statusTitle,
+		"faviconURL":             faviconURL,
+		"expectDown":             newExpectDown,
+		"ports":                  checks.FormatPorts,
+		"timingLegend":           generateTimingLegend,
+		"bytes":                  checks.FormatBytes,
+		"trendUptimeChart":       generateTrendUptimeSVG,
+		"trendLatencyChart":      generateTrendLatencySVG,
+		"throughput":             formatThroughput,
+		"speed":                  checks.FormatSpeed,
+		"speedLimit":             checks.FormatSpeedLimit,
+		// Times are shown in the configured display timezone
+		"smokepingChart": func(history []state.CheckDataPoint, notes []state.Annotation, width, height int) template.HTML {
+			return generateSmokepingChartSVG(history, notes, width, height, st.DisplayLocation())
+		},
+		"timingChart": func(history []state.CheckDataPoint, width, height int) template.HTML {
+			return generateTimingChartSVG(history, width, height, st.DisplayLocation())
+		},
+		"sizeChart": func(history []state.CheckDataPoint, minSize, maxSize int64, width, height int) template.HTML {
+			return generateSizeChartSVG(history, minSize, maxSize, width, height, st.DisplayLocation())
+		},
+		"jitterChart": func(history []state.CheckDataPoint, maxJitter time.Duration, width, height int) template.HTML {
+			return generateJitterChartSVG(history, maxJitter, width, height, st.DisplayLocation())
+		},
+		"speedChart": func(history []state.CheckDataPoint, minSpeed int64, width, height int) template.HTML {
+			return generateSpeedChartSVG(history, minSpeed, width, height, st.DisplayLocation())
+		},
+		"localTime": func(t time.Time, layout string) template.HTML {
+			return localTime(t, layout, st.DisplayLocation())
+		},
 	}
 	tpl := template.Must(template.New("").Funcs(funcs).ParseFS(templatesFS, "templates/*.html", "templates/check_config_fragment.html"))
-	return &Server{st: st, tpl: tpl}
+	return &Server{st: st, tpl: tpl, done: make(chan struct{}), assets: static, auth: newAuthenticator()}
 }
 
 func (s *Server) Start(addr string) error {
+	s.http = &http.Server{Addr: addr, Handler: s.handler()}
+	// Shutdown waits for handlers to return, which streams never would
+	s.http.RegisterOnShutdown(func() { close(s.done) })
+	return s.http.ListenAndServe()
+}
+
+// handler routes requests to the pages and API, behind sign-in when it is
+// configured
+func (s *Server) handler() http.Handler {
 	mux := http.NewServeMux()
 	mux.HandleFunc("/", s.handleIndex)
 	mux.HandleFunc("/toggle", s.handleToggle)
+	mux.HandleFunc("/toggle-host", s.handleToggleHost)
+	mux.HandleFunc("/accept-content", s.handleAcceptContent)
+	mux.HandleFunc("/expect-down", s.handleExpectDown)
 	mux.HandleFunc("/hcurl", s.handleHCURL)
 	mux.HandleFunc("/addhost", s.handleAddHost)
 	mux.HandleFunc("/addhost-form", s.handleAddHostForm)
@@ -74,20 +133,68 @@ func (s *Server) Start(addr string) error {
 	mux.HandleFunc("/edithost-updatecheck", s.handleEditUpdateCheck)
 	mux.HandleFunc("/edithost-savechecks", s.handleEditSaveChecks)
 	mux.HandleFunc("/check-config", s.handleCheckConfig)
+	mux.HandleFunc("/bulkedit-form", s.handleBulkEditForm)
+	mux.HandleFunc("/probe-log", s.handleProbeLog)
+	mux.HandleFunc("/tls", s.handleTLSDetails)
+	mux.HandleFunc("/bulkedit-preview", s.handleBulkEditPreview)
+	mux.HandleFunc("/bulkedit", s.handleBulkEdit)
 	mux.HandleFunc("/silence-all", s.handleSilenceAll)
 	mux.HandleFunc("/enable-all", s.handleEnableAll)
+	mux.HandleFunc("/run-now", s.handleRunNow)
+	mux.HandleFunc("/pause", s.handlePause)
+	mux.HandleFunc("/pause-status", s.handlePauseStatus)
+	mux.HandleFunc("/connectivity-banner", s.handleConnectivityBanner)
+	mux.HandleFunc("/api/scheduler", s.handleAPIScheduler)
+	mux.HandleFunc("/api/stats", s.handleAPIStats)
+	mux.HandleFunc("/api/events/stream", s.handleEventStream)
+	mux.HandleFunc("/api/webhook/{id}", s.handleWebhook)
+	mux.HandleFunc("/ws/hosts", s.handleHostsSocket)
+	// JSON Schemas for MQTT and event stream payloads, e.g. /schema/v1/state-change.json
+	mux.Handle("/schema/", http.StripPrefix("/schema/", http.FileServerFS(schema.Files)))
 	mux.HandleFunc("/stats", s.handleStats)
+	mux.Handle("/static/", s.assets)
+	mux.HandleFunc("/auth/login", s.handleLogin)
+	mux.HandleFunc("/auth/callback", s.handleLoginCallback)
+	mux.HandleFunc("/auth/logout", s.handleLogout)
+	mux.HandleFunc("/auth/me", s.handleSignedIn)
+	mux.HandleFunc("/favicon.svg", s.handleFavicon)
+	mux.Handle("/favicon.ico", http.RedirectHandler("/favicon.svg", http.StatusMovedPermanently))
+	mux.HandleFunc("/embed/{host}", s.handleEmbed)
+	mux.HandleFunc("/wallboard", s.handleWallboard)
+	mux.HandleFunc("/wallboard/tiles", s.handleWallboardTiles)
 	mux.HandleFunc("/analytics", s.handleAnalytics)
 	mux.HandleFunc("/analytics/host", s.handleHostAnalytics)
+	mux.HandleFunc("/analytics/trends", s.handleTrends)
+	mux.HandleFunc("/analytics/compare", s.handleCompareTrends)
+	mux.HandleFunc("/analytics/correlated", s.handleCorrelatedOutages)
+	mux.HandleFunc("/analytics/export/events", s.handleExportEvents)
+	mux.HandleFunc("/analytics/export/outages", s.handleExportOutages)
+	mux.HandleFunc("/report", s.handleReport)
+	mux.HandleFunc("/analytics/annotations", s.handleAnnotations)
+	mux.HandleFunc("/analytics/annotations/delete", s.handleDeleteAnnotation)
 	mux.HandleFunc("/events", s.handleEvents)
+	mux.HandleFunc("/monitor", s.handleMonitor)
+	mux.HandleFunc("/metrics", s.handleMetrics)
 	mux.HandleFunc("/settings", s.handleSettings)
 	mux.HandleFunc("/settings/mqtt", s.handleSettingsMQTT)
 	mux.HandleFunc("/settings/pushover", s.handleSettingsPushover)
 	mux.HandleFunc("/settings/pushover/test", s.handleTestPushover)
 	mux.HandleFunc("/settings/telegram", s.handleSettingsTelegram)
 	mux.HandleFunc("/settings/telegram/test", s.handleTestTelegram)
-	s.http = &http.Server{Addr: addr, Handler: logRequests(mux)}
-	return s.http.ListenAndServe()
+	mux.HandleFunc("/settings/shoutrrr/test", s.handleTestShoutrrr)
+	mux.HandleFunc("/settings/twilio", s.handleSettingsTwilio)
+	mux.HandleFunc("/settings/twilio/test", s.handleTestTwilio)
+	mux.HandleFunc("/settings/mute", s.handleSettingsMute)
+	mux.HandleFunc("/settings/alerts", s.handleSettingsAlerts)
+	mux.HandleFunc("/settings/latency", s.handleSettingsLatency)
+	mux.HandleFunc("/settings/display", s.handleSettingsDisplay)
+	mux.HandleFunc("/settings/import", s.handleImport)
+	mux.HandleFunc("/settings/replace-hosts", s.handleReplaceHosts)
+	mux.HandleFunc("/settings/apply-change", s.handleApplyChange)
+	mux.HandleFunc("/settings/history", s.handleHistory)
+	mux.HandleFunc("/settings/history/diff", s.handleHistoryDiff)
+	mux.HandleFunc("/settings/history/restore", s.handleHistoryRestore)
+	return logRequests(compress(s.requireAuth(mux)))
 }
 
 func (s *Server) Stop() error {
@@ -98,12 +205,22 @@ func (s *Server) Stop() error {
 }
 
 func (s *Server) handleIndex(w http.ResponseWriter, r *http.Request) {
+	tags := s.visibleTags(r)
 	data := struct {
-		Hosts []*state.HostStatus
-		Stats state.AggregateStats
+		hostsView
+		Stats        state.AggregateStats
+		Warnings     []string
+		Paused       bool
+		Connectivity connectivityBanner
 	}{
-		Hosts: s.st.Snapshot(),
-		Stats: s.st.GetAggregateStats(),
+		hostsView:    s.queryHosts(r),
+		Stats:        s.st.GetAggregateStatsFor(tags),
+		Paused:       s.st.IsPaused(),
+		Connectivity: s.connectivityBanner(),
+	}
+	if len(tags) == 0 {
+		// Config warnings name hosts of every tenant
+		data.Warnings = s.st.Warnings()
 	}
 	_ = s.tpl.ExecuteTemplate(w, "index.html", data)
 }
@@ -118,31 +235,75 @@ func (s *Server) handleToggle(w http.ResponseWriter, r *http.Request) {
 	enabled := r.FormValue("enabled") == "true"
 	idx, _ := strconv.Atoi(idxStr)
 	s.st.Toggle(host, idx, enabled)
-	_, _ = fmt.Fprint(w, toggleButton(host, idx, enabled))
+	data := struct {
+		Host    string
+		Idx     int
+		Enabled bool
+	}{Host: host, Idx: idx, Enabled: enabled}
+	_ = s.tpl.ExecuteTemplate(w, "toggle_button.html", data)
 }
 
-func (s *Server) handleAddHost(w http.ResponseWriter, r *http.Request) {
+func (s *Server) handleToggleHost(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
 		w.WriteHeader(405)
 		return
 	}
-	name := r.FormValue("name")
-	addr := r.FormValue("address")
-	hcurl := r.FormValue("hcurl")
-	if name == "" || addr == "" {
-		w.WriteHeader(400)
-		_, _ = w.Write([]byte("name and address required"))
+	host := r.FormValue("host")
+	enabled := r.FormValue("enabled") == "true"
+	if err := s.changes(r).SetHostEnabled(host, enabled); err != nil {
+		w.WriteHeader(409)
+		_, _ = w.Write([]byte(err.Error()))
+		return
+	}
+	data := s.queryHosts(r)
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	_ = s.tpl.ExecuteTemplate(w, "hosts.html", data)
+}
+
+// handleAcceptContent makes a watched page's changed content the new
+// baseline. The Accept button is replaced with nothing on success.
+func (s *Server) handleAcceptContent(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.WriteHeader(405)
 		return
 	}
-	if err := s.st.AddHostWithoutDefaultCheck(name, addr, hcurl); err != nil {
+	idx, _ := strconv.Atoi(r.FormValue("idx"))
+	if err := s.changes(r).AcceptContentChange(r.FormValue("host"), idx); err != nil {
 		w.WriteHeader(409)
 		_, _ = w.Write([]byte(err.Error()))
 		return
 	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	_, _ = w.Write([]byte(""))
+}
+
+func (s *Server) handleAddHost(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.WriteHeader(405)
+		return
+	}
+	name := r.FormValue("name")
+	addr := r.FormValue("address")
+	hcurl := r.FormValue("hcurl")
+	notes := strings.TrimSpace(r.FormValue("notes"))
+	runbook := strings.TrimSpace(r.FormValue("runbook_url"))
+
+	var errs validate.Errors
+	errs.Check("Host name", validate.Name(name))
+	errs.Check("Address", validate.Address(addr))
+	hcurl, err := validate.HealthchecksURL(hcurl)
+	errs.Check("Healthchecks.io URL", err)
+	errs.Check("Runbook URL", validate.OptionalURL(runbook))
+	tags, err := validate.Tags(r.FormValue("tags"))
+	errs.Check("Tags", err)
+	if _, exists := s.st.GetHost(name); exists {
+		errs.Add("Host name", "%q already exists", name)
+	}
 
 	// Process checks from the form (checks_type, checks_url, etc. are arrays)
 	_ = r.ParseForm()
 	types := r.Form["checks_type"]
+	names := r.Form["checks_name"]
 	urls := r.Form["checks_url"]
 	expects := r.Form["checks_expect"]
 	ports := r.Form["checks_port"]
@@ -151,94 +312,196 @@ func (s *Server) handleAddHost(w http.ResponseWriter, r *http.Request) {
 	mqttNotifies := r.Form["checks_mqtt_notify"]
 	pushoverNotifies := r.Form["checks_pushover_notify"]
 	telegramNotifies := r.Form["checks_telegram_notify"]
-
-	// Also check the direct checkbox (not in hidden fields)
-	directMqttNotify := r.FormValue("mqtt_notify") == "true"
-	directPushoverNotify := r.FormValue("pushover_notify") == "true"
-	directTelegramNotify := r.FormValue("telegram_notify") == "true"
-	directType := r.FormValue("type")
-	directURL := r.FormValue("url")
-	directPort := r.FormValue("port")
-	directID := r.FormValue("id")
-	directDependsOn := r.FormValue("depends_on")
-	directExpectStr := r.FormValue("expect")
-
-	// If no checks were added via "Add" button, use the current form state
+	inverts := r.Form["checks_invert"]
+	redirects := r.Form["checks_redirects"]
+	maxRedirects := r.Form["checks_max_redirects"]
+	proxies := r.Form["checks_proxy"]
+	insecures := r.Form["checks_insecure_skip_verify"]
+	mustContains := r.Form["checks_must_contain"]
+	mustNotContains := r.Form["checks_must_not_contain"]
+	watchContents := r.Form["checks_watch_content"]
+	ipVersions := r.Form["checks_ip_version"]
+	sources := r.Form["checks_source"]
+	severities := r.Form["checks_severity"]
+	pingMethods := r.Form["checks_ping_method"]
+	pingPorts := r.Form["checks_ping_port"]
+	sshUsers := r.Form["checks_ssh_user"]
+	sshKeys := r.Form["checks_ssh_key"]
+	commands := r.Form["checks_command"]
+	expectExits := r.Form["checks_expect_exit"]
+	expectOutputs := r.Form["checks_expect_output"]
+	wsSends := r.Form["checks_ws_send"]
+	wsExpects := r.Form["checks_ws_expect"]
+	scanPorts := r.Form["checks_ports"]
+	closedPorts := r.Form["checks_closed_ports"]
+	allOfs := r.Form["checks_all_of"]
+	anyOfs := r.Form["checks_any_of"]
+	maxAges := r.Form["checks_max_age"]
+	webhookTokens := r.Form["checks_webhook_token"]
+	filePaths := r.Form["checks_path"]
+	sftps := r.Form["checks_sftp"]
+	buckets := r.Form["checks_bucket"]
+	objects := r.Form["checks_object"]
+	regions := r.Form["checks_region"]
+	accessKeys := r.Form["checks_access_key"]
+	secretKeys := r.Form["checks_secret_key"]
+	shares := r.Form["checks_share"]
+	usernames := r.Form["checks_username"]
+	passwords := r.Form["checks_password"]
+	topics := r.Form["checks_topic"]
+	vhosts := r.Form["checks_vhost"]
+	tlsFlags := r.Form["checks_tls"]
+	kubeconfigs := r.Form["checks_kubeconfig"]
+	kubeContexts := r.Form["checks_kube_context"]
+	tokens := r.Form["checks_token"]
+	deployments := r.Form["checks_deployment"]
+	nodes := r.Form["checks_node"]
+	vms := r.Form["checks_vm"]
+	maxUsages := r.Form["checks_max_usage"]
+	daemons := r.Form["checks_daemon"]
+	upsNames := r.Form["checks_ups"]
+	minCharges := r.Form["checks_min_charge"]
+	uploads := r.Form["checks_upload"]
+	minSpeeds := r.Form["checks_min_speed"]
+	intervals := r.Form["checks_interval"]
+	domains := r.Form["checks_domain"]
+	warnDays := r.Form["checks_warn_days"]
+	blocklists := r.Form["checks_blocklists"]
+
+	var forms []checkForm
 	if len(types) == 0 {
-		// Add check based on current form state (type selector, mqtt checkbox, etc.)
-		switch directType {
-		case "http":
-			expectVal := 200
-			if directExpectStr != "" {
-				if v, err := strconv.Atoi(directExpectStr); err == nil {
-					expectVal = v
-				}
-			}
-			_ = s.st.AddHTTPCheck(name, directURL, expectVal, directID, directDependsOn, directMqttNotify, directPushoverNotify, directTelegramNotify)
-		case "tcp":
-			portVal := 0
-			if directPort != "" {
-				if v, err := strconv.Atoi(directPort); err == nil {
-					portVal = v
-				}
-			}
-			_ = s.st.AddTCPCheck(name, portVal, directID, directDependsOn, directMqttNotify, directPushoverNotify, directTelegramNotify)
-		default:
-			// Default to ping
-			_ = s.st.AddPingCheck(name, directID, directDependsOn, directMqttNotify, directPushoverNotify, directTelegramNotify)
+		// If no checks were added via "Add" button, use the current form state
+		// (type selector, mqtt checkbox, etc.), defaulting to ping
+		directType := r.FormValue("type")
+		if directType == "" {
+			directType = string(config.CheckPing)
 		}
+		cf := parseCheckForm(&errs, "Check 1", directType, r.FormValue("url"), r.FormValue("expect"),
+			r.FormValue("port"), r.FormValue("id"), r.FormValue("depends_on"))
+		cf.parseName(&errs, "Check 1", r.FormValue("check_name"))
+		cf.MQTTNotify = r.FormValue("mqtt_notify") == "true"
+		cf.PushoverNotify = r.FormValue("pushover_notify") == "true"
+		cf.TelegramNotify = r.FormValue("telegram_notify") == "true"
+		cf.Invert = r.FormValue("invert") == "true"
+		cf.parseHTTPOptions(&errs, "Check 1", r.FormValue("redirects"), r.FormValue("max_redirects"),
+			r.FormValue("proxy"), r.FormValue("insecure_skip_verify"))
+		cf.parseContentRules(&errs, "Check 1", r.FormValue("must_contain"), r.FormValue("must_not_contain"), r.FormValue("watch_content"))
+		cf.parseDialOptions(&errs, "Check 1", r.FormValue("ip_version"), r.FormValue("source"))
+		cf.parsePublicIPOptions(&errs, "Check 1", r.FormValue("ip_version"))
+		cf.parsePingOptions(&errs, "Check 1", r.FormValue("ping_method"), r.FormValue("ping_port"))
+		cf.parseSSHOptions(&errs, "Check 1", r.FormValue("port"), r.FormValue("ssh_user"), r.FormValue("ssh_key"),
+			r.FormValue("command"), r.FormValue("expect_exit"), r.FormValue("expect_output"))
+		cf.parseWebSocketOptions(&errs, "Check 1", r.FormValue("ws_send"), r.FormValue("ws_expect"))
+		cf.parsePortScanOptions(&errs, "Check 1", r.FormValue("ports"), r.FormValue("closed_ports"))
+		cf.parseCompositeOptions(&errs, "Check 1", r.FormValue("all_of"), r.FormValue("any_of"))
+		cf.parseWebhookOptions(&errs, "Check 1", r.FormValue("max_age"), r.FormValue("webhook_token"))
+		cf.parseS3Options(&errs, "Check 1", r.FormValue("bucket"), r.FormValue("object"), r.FormValue("region"),
+			r.FormValue("access_key"), r.FormValue("secret_key"), r.FormValue("max_age"), r.FormValue("insecure_skip_verify"), false)
+		cf.parseIPPOptions(r.FormValue("insecure_skip_verify"))
+		cf.parseSMBOptions(&errs, "Check 1", r.FormValue("share"), r.FormValue("username"), r.FormValue("password"), r.FormValue("port"))
+		cf.parseKafkaOptions(&errs, "Check 1", r.FormValue("topic"), r.FormValue("port"))
+		cf.parseAMQPOptions(&errs, "Check 1", r.FormValue("vhost"), r.FormValue("username"), r.FormValue("password"), r.FormValue("port"),
+			r.FormValue("tls"), r.FormValue("insecure_skip_verify"))
+		cf.parseKubernetesOptions(&errs, "Check 1", r.FormValue("kubeconfig"), r.FormValue("kube_context"), r.FormValue("token"),
+			r.FormValue("deployment"), r.FormValue("insecure_skip_verify"))
+		cf.parseProxmoxOptions(&errs, "Check 1", r.FormValue("token"), r.FormValue("node"), r.FormValue("vm"), r.FormValue("max_usage"),
+			r.FormValue("port"), r.FormValue("insecure_skip_verify"), false)
+		cf.parseESXiOptions(&errs, "Check 1", r.FormValue("username"), r.FormValue("password"), r.FormValue("vm"), r.FormValue("max_usage"),
+			r.FormValue("port"), r.FormValue("insecure_skip_verify"))
+		cf.parseUPSOptions(&errs, "Check 1", r.FormValue("daemon"), r.FormValue("ups"), r.FormValue("min_charge"), r.FormValue("port"))
+		cf.parseSpeedtestOptions(&errs, "Check 1", r.FormValue("upload"), r.FormValue("min_speed"), r.FormValue("interval"), r.FormValue("port"))
+		cf.parseDomainOptions(&errs, "Check 1", r.FormValue("domain"), r.FormValue("warn_days"))
+		cf.parseDNSBLOptions(&errs, "Check 1", r.FormValue("blocklists"))
+		cf.parseFileOptions(&errs, "Check 1", r.FormValue("path"), r.FormValue("max_age"), r.FormValue("sftp"),
+			r.FormValue("ssh_user"), r.FormValue("ssh_key"), r.FormValue("port"))
+		cf.Severity = parseSeverity(&errs, "Check 1", r.FormValue("severity"))
+		forms = append(forms, cf)
 	} else {
 		for i, typ := range types {
-			id := ""
-			if i < len(ids) {
-				id = ids[i]
-			}
-			dependsOn := ""
-			if i < len(dependsOns) {
-				dependsOn = dependsOns[i]
-			}
-			mqttNotify := false
-			if i < len(mqttNotifies) {
-				mqttNotify = mqttNotifies[i] == "true"
-			}
-			pushoverNotify := false
-			if i < len(pushoverNotifies) {
-				pushoverNotify = pushoverNotifies[i] == "true"
-			}
-			telegramNotify := false
-			if i < len(telegramNotifies) {
-				telegramNotify = telegramNotifies[i] == "true"
-			}
+			cf := parseCheckForm(&errs, fmt.Sprintf("Check %d", i+1), typ,
+				formIndex(urls, i), formIndex(expects, i), formIndex(ports, i),
+				formIndex(ids, i), formIndex(dependsOns, i))
+			cf.parseName(&errs, fmt.Sprintf("Check %d", i+1), formIndex(names, i))
+			cf.MQTTNotify = formIndex(mqttNotifies, i) == "true"
+			cf.PushoverNotify = formIndex(pushoverNotifies, i) == "true"
+			cf.TelegramNotify = formIndex(telegramNotifies, i) == "true"
+			cf.Invert = formIndex(inverts, i) == "true"
+			cf.parseHTTPOptions(&errs, fmt.Sprintf("Check %d", i+1), formIndex(redirects, i),
+				formIndex(maxRedirects, i), formIndex(proxies, i), formIndex(insecures, i))
+			cf.parseContentRules(&errs, fmt.Sprintf("Check %d", i+1), formIndex(mustContains, i), formIndex(mustNotContains, i), formIndex(watchContents, i))
+			cf.parseDialOptions(&errs, fmt.Sprintf("Check %d", i+1), formIndex(ipVersions, i), formIndex(sources, i))
+			cf.parsePublicIPOptions(&errs, fmt.Sprintf("Check %d", i+1), formIndex(ipVersions, i))
+			cf.parsePingOptions(&errs, fmt.Sprintf("Check %d", i+1), formIndex(pingMethods, i), formIndex(pingPorts, i))
+			cf.parseSSHOptions(&errs, fmt.Sprintf("Check %d", i+1), formIndex(ports, i), formIndex(sshUsers, i), formIndex(sshKeys, i),
+				formIndex(commands, i), formIndex(expectExits, i), formIndex(expectOutputs, i))
+			cf.parseWebSocketOptions(&errs, fmt.Sprintf("Check %d", i+1), formIndex(wsSends, i), formIndex(wsExpects, i))
+			cf.parsePortScanOptions(&errs, fmt.Sprintf("Check %d", i+1), formIndex(scanPorts, i), formIndex(closedPorts, i))
+			cf.parseCompositeOptions(&errs, fmt.Sprintf("Check %d", i+1), formIndex(allOfs, i), formIndex(anyOfs, i))
+			cf.parseWebhookOptions(&errs, fmt.Sprintf("Check %d", i+1), formIndex(maxAges, i), formIndex(webhookTokens, i))
+			cf.parseS3Options(&errs, fmt.Sprintf("Check %d", i+1), formIndex(buckets, i), formIndex(objects, i), formIndex(regions, i),
+				formIndex(accessKeys, i), formIndex(secretKeys, i), formIndex(maxAges, i), formIndex(insecures, i), false)
+			cf.parseIPPOptions(formIndex(insecures, i))
+			cf.parseSMBOptions(&errs, fmt.Sprintf("Check %d", i+1), formIndex(shares, i), formIndex(usernames, i), formIndex(passwords, i), formIndex(ports, i))
+			cf.parseKafkaOptions(&errs, fmt.Sprintf("Check %d", i+1), formIndex(topics, i), formIndex(ports, i))
+			cf.parseAMQPOptions(&errs, fmt.Sprintf("Check %d", i+1), formIndex(vhosts, i), formIndex(usernames, i), formIndex(passwords, i), formIndex(ports, i),
+				formIndex(tlsFlags, i), formIndex(insecures, i))
+			cf.parseKubernetesOptions(&errs, fmt.Sprintf("Check %d", i+1), formIndex(kubeconfigs, i), formIndex(kubeContexts, i), formIndex(tokens, i),
+				formIndex(deployments, i), formIndex(insecures, i))
+			cf.parseProxmoxOptions(&errs, fmt.Sprintf("Check %d", i+1), formIndex(tokens, i), formIndex(nodes, i), formIndex(vms, i), formIndex(maxUsages, i),
+				formIndex(ports, i), formIndex(insecures, i), false)
+			cf.parseESXiOptions(&errs, fmt.Sprintf("Check %d", i+1), formIndex(usernames, i), formIndex(passwords, i), formIndex(vms, i), formIndex(maxUsages, i),
+				formIndex(ports, i), formIndex(insecures, i))
+			cf.parseUPSOptions(&errs, fmt.Sprintf("Check %d", i+1), formIndex(daemons, i), formIndex(upsNames, i), formIndex(minCharges, i), formIndex(ports, i))
+			cf.parseSpeedtestOptions(&errs, fmt.Sprintf("Check %d", i+1), formIndex(uploads, i), formIndex(minSpeeds, i), formIndex(intervals, i), formIndex(ports, i))
+			cf.parseDomainOptions(&errs, fmt.Sprintf("Check %d", i+1), formIndex(domains, i), formIndex(warnDays, i))
+			cf.parseDNSBLOptions(&errs, fmt.Sprintf("Check %d", i+1), formIndex(blocklists, i))
+			cf.parseFileOptions(&errs, fmt.Sprintf("Check %d", i+1), formIndex(filePaths, i), formIndex(maxAges, i), formIndex(sftps, i),
+				formIndex(sshUsers, i), formIndex(sshKeys, i), formIndex(ports, i))
+			cf.Severity = parseSeverity(&errs, fmt.Sprintf("Check %d", i+1), formIndex(severities, i))
+			forms = append(forms, cf)
+		}
+	}
+	s.checkIDsUnique(&errs, name, forms)
+	s.checkCompositeMembers(&errs, forms)
+	if errs.Any() {
+		s.renderFormErrors(w, "#addhost-errors", errs)
+		return
+	}
 
-			switch typ {
-			case "ping":
-				_ = s.st.AddPingCheck(name, id, dependsOn, mqttNotify, pushoverNotify, telegramNotify)
-			case "http":
-				url := ""
-				if i < len(urls) {
-					url = urls[i]
-				}
-				expect := 200
-				if i < len(expects) {
-					if v, err := strconv.Atoi(expects[i]); err == nil {
-						expect = v
-					}
-				}
-				_ = s.st.AddHTTPCheck(name, url, expect, id, dependsOn, mqttNotify, pushoverNotify, telegramNotify)
-			case "tcp":
-				port := 0
-				if i < len(ports) {
-					if v, err := strconv.Atoi(ports[i]); err == nil {
-						port = v
-					}
-				}
-				_ = s.st.AddTCPCheck(name, port, id, dependsOn, mqttNotify, pushoverNotify, telegramNotify)
-			}
+	st := s.changes(r)
+	if err := st.AddHostWithoutDefaultCheck(name, addr, hcurl); err != nil {
+		w.WriteHeader(409)
+		_, _ = w.Write([]byte(err.Error()))
+		return
+	}
+	if notes != "" || runbook != "" {
+		if err := st.SetHostNotes(name, notes, runbook); err != nil {
+			log.Printf("set notes on %q failed: %v", name, err)
+		}
+	}
+	if r.FormValue("gateway") == "true" {
+		if err := st.SetHostGateway(name, true); err != nil {
+			log.Printf("set gateway on %q failed: %v", name, err)
+		}
+	}
+	if r.FormValue("alert_ip_change") == "true" {
+		if err := st.SetHostAlertIPChange(name, true); err != nil {
+			log.Printf("set IP change alerts on %q failed: %v", name, err)
+		}
+	}
+	if len(tags) > 0 {
+		if err := st.SetHostTags(name, tags); err != nil {
+			log.Printf("set tags on %q failed: %v", name, err)
+		}
+	}
+	for _, cf := range forms {
+		if err := s.addCheck(st, name, cf); err != nil {
+			log.Printf("add check to %q failed: %v", name, err)
 		}
 	}
 
 	// Return refreshed hosts grid
-	data := struct{ Hosts []*state.HostStatus }{Hosts: s.st.Snapshot()}
+	data := s.queryHosts(r)
 	_ = s.tpl.ExecuteTemplate(w, "add_host_result.html", data)
 }
 
@@ -252,34 +515,105 @@ func (s *Server) handleCloseModal(w http.ResponseWriter, r *http.Request) {
 }
 
 func (s *Server) handleHosts(w http.ResponseWriter, r *http.Request) {
-	data := struct{ Hosts []*state.HostStatus }{Hosts: s.st.Snapshot()}
+	if s.notModified(w, r, "q", "status", "sort") {
+		return
+	}
+	data := s.queryHosts(r)
 	w.Header().Set("Content-Type", "text/html; charset=utf-8")
 	_ = s.tpl.ExecuteTemplate(w, "hosts.html", data)
 }
 
-func (s *Server) handleAddHostCheckRow(w http.ResponseWriter, r *http.Request) {
-	typ := r.FormValue("type")
-	url := r.FormValue("url")
-	expectStr := r.FormValue("expect")
-	portStr := r.FormValue("port")
-	id := r.FormValue("id")
-	dependsOn := r.FormValue("depends_on")
-	mqttNotify := r.FormValue("mqtt_notify") == "true"
-	pushoverNotify := r.FormValue("pushover_notify") == "true"
-	telegramNotify := r.FormValue("telegram_notify") == "true"
-	expect := 200
-	if expectStr != "" {
-		if v, err := strconv.Atoi(expectStr); err == nil {
-			expect = v
-		}
+// hostsView is the data for hosts.html: the hosts matching the dashboard's
+// search, status filter and sort, which every request that re-renders the
+// grid carries as q, status and sort
+type hostsView struct {
+	Hosts []*state.HostStatus
+	Query state.HostQuery
+}
+
+func (s *Server) queryHosts(r *http.Request) hostsView {
+	q := s.hostQuery(r)
+	return hostsView{Hosts: s.st.QueryHosts(q), Query: q}
+}
+
+// hostQuery reads the dashboard's search, status filter and sort from r
+func (s *Server) hostQuery(r *http.Request) state.HostQuery {
+	return state.HostQuery{
+		Search: strings.TrimSpace(r.FormValue("q")),
+		Status: r.FormValue("status"),
+		Sort:   r.FormValue("sort"),
+		Tags:   s.visibleTags(r),
 	}
-	port := 0
-	if portStr != "" {
-		if v, err := strconv.Atoi(portStr); err == nil {
-			port = v
+}
+
+// SocketURL is the hosts socket's URL for the grid's filters, so the cards
+// it pushes are the ones the grid shows
+func (v hostsView) SocketURL() string {
+	q := url.Values{}
+	for key, val := range map[string]string{"q": v.Query.Search, "status": v.Query.Status, "sort": v.Query.Sort} {
+		if val != "" {
+			q.Set(key, val)
 		}
 	}
-	data := map[string]any{"Type": typ, "URL": url, "Expect": expect, "Port": port, "ID": id, "DependsOn": dependsOn, "MQTTNotify": mqttNotify, "PushoverNotify": pushoverNotify, "TelegramNotify": telegramNotify}
+	if len(q) == 0 {
+		return "/ws/hosts"
+	}
+	return "/ws/hosts?" + q.Encode()
+}
+
+func (s *Server) handleAddHostCheckRow(w http.ResponseWriter, r *http.Request) {
+	typ := r.FormValue("type")
+	if typ == "" {
+		typ = string(config.CheckPing)
+	}
+	var errs validate.Errors
+	cf := parseCheckForm(&errs, "Check", typ, r.FormValue("url"), r.FormValue("expect"),
+		r.FormValue("port"), r.FormValue("id"), r.FormValue("depends_on"))
+	cf.parseName(&errs, "Check", r.FormValue("check_name"))
+	cf.parseHTTPOptions(&errs, "Check", r.FormValue("redirects"), r.FormValue("max_redirects"),
+		r.FormValue("proxy"), r.FormValue("insecure_skip_verify"))
+	cf.parseContentRules(&errs, "Check", r.FormValue("must_contain"), r.FormValue("must_not_contain"), r.FormValue("watch_content"))
+	cf.parseDialOptions(&errs, "Check", r.FormValue("ip_version"), r.FormValue("source"))
+	cf.parsePublicIPOptions(&errs, "Check", r.FormValue("ip_version"))
+	cf.parsePingOptions(&errs, "Check", r.FormValue("ping_method"), r.FormValue("ping_port"))
+	cf.parseSSHOptions(&errs, "Check", r.FormValue("port"), r.FormValue("ssh_user"), r.FormValue("ssh_key"),
+		r.FormValue("command"), r.FormValue("expect_exit"), r.FormValue("expect_output"))
+	cf.parseWebSocketOptions(&errs, "Check", r.FormValue("ws_send"), r.FormValue("ws_expect"))
+	cf.parsePortScanOptions(&errs, "Check", r.FormValue("ports"), r.FormValue("closed_ports"))
+	cf.parseCompositeOptions(&errs, "Check", r.FormValue("all_of"), r.FormValue("any_of"))
+	cf.parseWebhookOptions(&errs, "Check", r.FormValue("max_age"), r.FormValue("webhook_token"))
+	cf.parseS3Options(&errs, "Check", r.FormValue("bucket"), r.FormValue("object"), r.FormValue("region"),
+		r.FormValue("access_key"), r.FormValue("secret_key"), r.FormValue("max_age"), r.FormValue("insecure_skip_verify"), false)
+	cf.parseIPPOptions(r.FormValue("insecure_skip_verify"))
+	cf.parseSMBOptions(&errs, "Check", r.FormValue("share"), r.FormValue("username"), r.FormValue("password"), r.FormValue("port"))
+	cf.parseKafkaOptions(&errs, "Check", r.FormValue("topic"), r.FormValue("port"))
+	cf.parseAMQPOptions(&errs, "Check", r.FormValue("vhost"), r.FormValue("username"), r.FormValue("password"), r.FormValue("port"),
+		r.FormValue("tls"), r.FormValue("insecure_skip_verify"))
+	cf.parseKubernetesOptions(&errs, "Check", r.FormValue("kubeconfig"), r.FormValue("kube_context"), r.FormValue("token"),
+		r.FormValue("deployment"), r.FormValue("insecure_skip_verify"))
+	cf.parseProxmoxOptions(&errs, "Check", r.FormValue("token"), r.FormValue("node"), r.FormValue("vm"), r.FormValue("max_usage"),
+		r.FormValue("port"), r.FormValue("insecure_skip_verify"), false)
+	cf.parseESXiOptions(&errs, "Check", r.FormValue("username"), r.FormValue("password"), r.FormValue("vm"), r.FormValue("max_usage"),
+		r.FormValue("port"), r.FormValue("insecure_skip_verify"))
+	cf.parseUPSOptions(&errs, "Check", r.FormValue("daemon"), r.FormValue("ups"), r.FormValue("min_charge"), r.FormValue("port"))
+	cf.parseSpeedtestOptions(&errs, "Check", r.FormValue("upload"), r.FormValue("min_speed"), r.FormValue("interval"), r.FormValue("port"))
+	cf.parseDomainOptions(&errs, "Check", r.FormValue("domain"), r.FormValue("warn_days"))
+	cf.parseDNSBLOptions(&errs, "Check", r.FormValue("blocklists"))
+	cf.parseFileOptions(&errs, "Check", r.FormValue("path"), r.FormValue("max_age"), r.FormValue("sftp"),
+		r.FormValue("ssh_user"), r.FormValue("ssh_key"), r.FormValue("port"))
+	cf.Severity = parseSeverity(&errs, "Check", r.FormValue("severity"))
+	if s.st.CheckIDInUse(cf.ID, "") {
+		errs.Add("Check ID", "%q is already used by another check", cf.ID)
+	}
+	if errs.Any() {
+		s.renderFormErrors(w, "#addhost-errors", errs)
+		return
+	}
+	cf.MQTTNotify = r.FormValue("mqtt_notify") == "true"
+	cf.PushoverNotify = r.FormValue("pushover_notify") == "true"
+	cf.TelegramNotify = r.FormValue("telegram_notify") == "true"
+	cf.Invert = r.FormValue("invert") == "true"
+	data := map[string]any{"Type": cf.Type, "Name": cf.Name, "URL": cf.URL, "Expect": cf.Expect, "Port": cf.Port, "ID": cf.ID, "DependsOn": cf.DependsOn, "MQTTNotify": cf.MQTTNotify, "PushoverNotify": cf.PushoverNotify, "TelegramNotify": cf.TelegramNotify, "Invert": cf.Invert, "HTTPOpts": cf.HTTPOpts, "DialOpts": cf.DialOpts, "PingOpts": cf.PingOpts, "SSHOpts": cf.SSHOpts, "WSOpts": cf.WSOpts, "ScanOpts": cf.ScanOpts, "AllOf": cf.AllOf, "AnyOf": cf.AnyOf, "MaxAge": cf.MaxAge, "WebhookToken": cf.WebhookToken, "FileOpts": cf.FileOpts, "S3Opts": cf.S3Opts, "IPPOpts": cf.IPPOpts, "SMBOpts": cf.SMBOpts, "KafkaOpts": cf.KafkaOpts, "AMQPOpts": cf.AMQPOpts, "KubernetesOpts": cf.KubernetesOpts, "ProxmoxOpts": cf.ProxmoxOpts, "ESXiOpts": cf.ESXiOpts, "UPSOpts": cf.UPSOpts, "SpeedtestOpts": cf.SpeedtestOpts, "PublicIPOpts": cf.PublicIPOpts, "DomainOpts": cf.DomainOpts, "DNSBLOpts": cf.DNSBLOpts, "Severity": cf.Severity}
 	_ = s.tpl.ExecuteTemplate(w, "addhost_check_row.html", data)
 }
 
@@ -290,7 +624,17 @@ func (s *Server) handleEditHostForm(w http.ResponseWriter, r *http.Request) {
 		w.WriteHeader(404)
 		return
 	}
-	_ = s.tpl.ExecuteTemplate(w, "edithost_modal.html", hs)
+	s.renderEditHost(w, r, hs)
+}
+
+// renderEditHost renders the edit dialog for hs
+func (s *Server) renderEditHost(w http.ResponseWriter, r *http.Request, hs state.HostStatus) {
+	data := struct {
+		state.HostStatus
+		EmbedURL       string
+		ShoutrrrLabels []string // Offered for each check when Shoutrrr is set up
+	}{hs, s.embedURL(r, hs.Name), s.st.ShoutrrrLabels()}
+	_ = s.tpl.ExecuteTemplate(w, "edithost_modal.html", data)
 }
 
 func (s *Server) handleEditHost(w http.ResponseWriter, r *http.Request) {
@@ -302,55 +646,57 @@ func (s *Server) handleEditHost(w http.ResponseWriter, r *http.Request) {
 	name := r.FormValue("name")
 	addr := r.FormValue("address")
 	hcurl := r.FormValue("hcurl")
-	if name == "" || addr == "" {
-		w.WriteHeader(400)
+	notes := strings.TrimSpace(r.FormValue("notes"))
+	runbook := strings.TrimSpace(r.FormValue("runbook_url"))
+
+	var errs validate.Errors
+	errs.Check("Host name", validate.Name(name))
+	errs.Check("Address", validate.Address(addr))
+	hcurl, err := validate.HealthchecksURL(hcurl)
+	errs.Check("Healthchecks.io URL", err)
+	errs.Check("Runbook URL", validate.OptionalURL(runbook))
+	tags, err := validate.Tags(r.FormValue("tags"))
+	errs.Check("Tags", err)
+	if name != old {
+		if _, exists := s.st.GetHost(name); exists {
+			errs.Add("Host name", "%q already exists", name)
+		}
+	}
+	count, _ := strconv.Atoi(r.FormValue("check_count"))
+	forms := parseIndexedChecks(r, &errs, count)
+	s.checkIDsUnique(&errs, old, forms)
+	s.checkIDsStillNeeded(&errs, old, forms)
+	s.checkShoutrrrLabels(&errs, forms)
+	s.checkCompositeMembers(&errs, forms)
+	if errs.Any() {
+		s.renderFormErrors(w, "#edithost-errors", errs)
 		return
 	}
-	if err := s.st.UpdateHost(old, name, addr, hcurl); err != nil {
+
+	st := s.changes(r)
+	if err := st.UpdateHost(old, name, addr, hcurl); err != nil {
 		w.WriteHeader(409)
 		_, _ = w.Write([]byte(err.Error()))
 		return
 	}
 
-	// Also save check changes
-	hostForChecks := name // Use the new name after rename
-	countStr := r.FormValue("check_count")
-	count, _ := strconv.Atoi(countStr)
-
-	for i := 0; i < count; i++ {
-		typ := r.FormValue(fmt.Sprintf("type_%d", i))
-		id := r.FormValue(fmt.Sprintf("id_%d", i))
-		dependsOn := r.FormValue(fmt.Sprintf("depends_on_%d", i))
-		mqttNotify := r.FormValue(fmt.Sprintf("mqtt_notify_%d", i)) == "true"
-		pushoverNotify := r.FormValue(fmt.Sprintf("pushover_notify_%d", i)) == "true"
-		telegramNotify := r.FormValue(fmt.Sprintf("telegram_notify_%d", i)) == "true"
-
-		if typ == "http" {
-			url := r.FormValue(fmt.Sprintf("url_%d", i))
-			expectStr := r.FormValue(fmt.Sprintf("expect_%d", i))
-			expect := 200
-			if expectStr != "" {
-				if v, err := strconv.Atoi(expectStr); err == nil {
-					expect = v
-				}
-			}
-			_ = s.st.UpdateHTTPCheck(hostForChecks, i, url, expect, id, dependsOn, mqttNotify, pushoverNotify, telegramNotify)
-		} else if typ == "tcp" {
-			portStr := r.FormValue(fmt.Sprintf("port_%d", i))
-			port := 0
-			if portStr != "" {
-				if v, err := strconv.Atoi(portStr); err == nil {
-					port = v
-				}
-			}
-			_ = s.st.UpdateTCPCheck(hostForChecks, i, port, id, dependsOn, mqttNotify, pushoverNotify, telegramNotify)
-		} else if typ == "ping" {
-			// For ping checks, just update the dependencies
-			_ = s.st.UpdateCheckDependencies(hostForChecks, i, id, dependsOn, mqttNotify, pushoverNotify, telegramNotify)
-		}
+	if err := st.SetHostNotes(name, notes, runbook); err != nil {
+		log.Printf("set notes on %q failed: %v", name, err)
+	}
+	if err := st.SetHostGateway(name, r.FormValue("gateway") == "true"); err != nil {
+		log.Printf("set gateway on %q failed: %v", name, err)
+	}
+	if err := st.SetHostAlertIPChange(name, r.FormValue("alert_ip_change") == "true"); err != nil {
+		log.Printf("set IP change alerts on %q failed: %v", name, err)
 	}
+	if err := st.SetHostTags(name, tags); err != nil {
+		log.Printf("set tags on %q failed: %v", name, err)
+	}
+
+	// Also save check changes, using the new name after rename
+	s.updateChecks(st, name, forms)
 
-	data := struct{ Hosts []*state.HostStatus }{Hosts: s.st.Snapshot()}
+	data := s.queryHosts(r)
 	_ = s.tpl.ExecuteTemplate(w, "add_host_result.html", data)
 }
 
@@ -360,12 +706,17 @@ func (s *Server) handleDeleteHost(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	name := r.FormValue("name")
-	if err := s.st.DeleteHost(name); err != nil {
+	err := s.changes(r).DeleteHost(name, state.OrphanAction(r.FormValue("orphans")))
+	if errors.Is(err, state.ErrHasDependants) {
+		s.renderDeleteImpact(w, name, -1)
+		return
+	}
+	if err != nil {
 		w.WriteHeader(409)
 		_, _ = w.Write([]byte(err.Error()))
 		return
 	}
-	data := struct{ Hosts []*state.HostStatus }{Hosts: s.st.Snapshot()}
+	data := s.queryHosts(r)
 	_ = s.tpl.ExecuteTemplate(w, "add_host_result.html", data)
 }
 
@@ -381,28 +732,25 @@ func (s *Server) handleHCURL(w http.ResponseWriter, r *http.Request) {
 	}
 	host := r.FormValue("host")
 	url := r.FormValue("url")
-	action := r.FormValue("action")
-	if action == "clear" {
+	if r.FormValue("action") == "clear" {
 		url = ""
 	}
-	log.Printf("HCURL update request: host=%q url=%q", host, url)
-	s.st.SetHCURL(host, url)
-	fmt.Fprint(w, hcurlSection(host, url))
-}
-
-func hcurlSection(host, url string) string {
-	return fmt.Sprintf(`
-	<div class="field has-addons">
-	  <div class="control is-expanded">
-	    <input class="input" type="text" name="url" placeholder="Healthchecks.io ping URL" value="%s">
-	  </div>
-	  <div class="control">
-	    <button class="button is-link" hx-post="/hcurl" hx-include="closest .field" hx-vals='{"host":"%s"}' hx-target="#hc-%s" hx-swap="outerHTML">Save</button>
-	  </div>
-	  <div class="control">
-	    <button class="button is-light is-danger" hx-post="/hcurl" hx-vals='{"host":"%s","action":"clear"}' hx-target="#hc-%s" hx-swap="outerHTML">Clear</button>
-	  </div>
-	</div>`, template.HTMLEscapeString(url), host, host, host, host)
+	data := struct{ Host, URL, Error string }{Host: host, URL: url}
+	normalized, err := validate.HealthchecksURL(url)
+	if err != nil {
+		w.WriteHeader(422)
+		data.Error = "Healthchecks.io URL " + err.Error()
+		_ = s.tpl.ExecuteTemplate(w, "hcurl_section.html", data)
+		return
+	}
+	log.Printf("HCURL update request: host=%q url=%q", host, normalized)
+	if err := s.changes(r).SetHCURL(host, normalized); err != nil {
+		w.WriteHeader(409)
+		_, _ = w.Write([]byte(err.Error()))
+		return
+	}
+	data.URL = normalized
+	_ = s.tpl.ExecuteTemplate(w, "hcurl_section.html", data)
 }
 
 func (s *Server) handleAddHTTPForm(w http.ResponseWriter, r *http.Request) {
@@ -429,12 +777,21 @@ func (s *Server) handleAddHTTP(w http.ResponseWriter, r *http.Request) {
 			expect = v
 		}
 	}
-	if err := s.st.AddHTTPCheck(host, url, expect, id, dependsOn, mqttNotify, pushoverNotify, telegramNotify); err != nil {
+	var errs validate.Errors
+	cf := checkForm{Type: string(config.CheckHTTP)}
+	cf.parseHTTPOptions(&errs, "Check", r.FormValue("redirects"), r.FormValue("max_redirects"),
+		r.FormValue("proxy"), r.FormValue("insecure_skip_verify"))
+	if errs.Any() {
+		w.WriteHeader(400)
+		_, _ = w.Write([]byte(errs.Error()))
+		return
+	}
+	if err := s.changes(r).AddHTTPCheck(host, url, expect, cf.HTTPOpts, id, dependsOn, mqttNotify, pushoverNotify, telegramNotify); err != nil {
 		w.WriteHeader(409)
 		_, _ = w.Write([]byte(err.Error()))
 		return
 	}
-	data := struct{ Hosts []*state.HostStatus }{Hosts: s.st.Snapshot()}
+	data := s.queryHosts(r)
 	_ = s.tpl.ExecuteTemplate(w, "add_host_result.html", data)
 }
 
@@ -444,45 +801,62 @@ func (s *Server) handleEditAddCheck(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	host := r.FormValue("host")
-	typ := r.FormValue("type")
-	url := r.FormValue("url")
-	expectStr := r.FormValue("expect")
-	portStr := r.FormValue("port")
-	id := r.FormValue("id")
-	dependsOn := r.FormValue("depends_on")
-	mqttNotify := r.FormValue("mqtt_notify") == "true"
-	pushoverNotify := r.FormValue("pushover_notify") == "true"
-	telegramNotify := r.FormValue("telegram_notify") == "true"
-	expect := 200
-	if expectStr != "" {
-		if v, err := strconv.Atoi(expectStr); err == nil {
-			expect = v
-		}
-	}
-	port := 0
-	if portStr != "" {
-		if v, err := strconv.Atoi(portStr); err == nil {
-			port = v
-		}
-	}
-	var err error
-	switch typ {
-	case "ping":
-		err = s.st.AddPingCheck(host, id, dependsOn, mqttNotify, pushoverNotify, telegramNotify)
-	case "http":
-		err = s.st.AddHTTPCheck(host, url, expect, id, dependsOn, mqttNotify, pushoverNotify, telegramNotify)
-	case "tcp":
-		err = s.st.AddTCPCheck(host, port, id, dependsOn, mqttNotify, pushoverNotify, telegramNotify)
-	default:
-		err = fmt.Errorf("unknown type")
+	var errs validate.Errors
+	cf := parseCheckForm(&errs, "New check", r.FormValue("type"), r.FormValue("url"), r.FormValue("expect"),
+		r.FormValue("port"), r.FormValue("id"), r.FormValue("depends_on"))
+	cf.parseName(&errs, "New check", r.FormValue("check_name"))
+	cf.parseHTTPOptions(&errs, "New check", r.FormValue("redirects"), r.FormValue("max_redirects"),
+		r.FormValue("proxy"), r.FormValue("insecure_skip_verify"))
+	cf.parseContentRules(&errs, "New check", r.FormValue("must_contain"), r.FormValue("must_not_contain"), r.FormValue("watch_content"))
+	cf.parseDialOptions(&errs, "New check", r.FormValue("ip_version"), r.FormValue("source"))
+	cf.parsePublicIPOptions(&errs, "New check", r.FormValue("ip_version"))
+	cf.parsePingOptions(&errs, "New check", r.FormValue("ping_method"), r.FormValue("ping_port"))
+	cf.parseSSHOptions(&errs, "New check", r.FormValue("port"), r.FormValue("ssh_user"), r.FormValue("ssh_key"),
+		r.FormValue("command"), r.FormValue("expect_exit"), r.FormValue("expect_output"))
+	cf.parseWebSocketOptions(&errs, "New check", r.FormValue("ws_send"), r.FormValue("ws_expect"))
+	cf.parsePortScanOptions(&errs, "New check", r.FormValue("ports"), r.FormValue("closed_ports"))
+	cf.parseCompositeOptions(&errs, "New check", r.FormValue("all_of"), r.FormValue("any_of"))
+	cf.parseWebhookOptions(&errs, "New check", r.FormValue("max_age"), r.FormValue("webhook_token"))
+	cf.parseS3Options(&errs, "New check", r.FormValue("bucket"), r.FormValue("object"), r.FormValue("region"),
+		r.FormValue("access_key"), r.FormValue("secret_key"), r.FormValue("max_age"), r.FormValue("insecure_skip_verify"), false)
+	cf.parseIPPOptions(r.FormValue("insecure_skip_verify"))
+	cf.parseSMBOptions(&errs, "New check", r.FormValue("share"), r.FormValue("username"), r.FormValue("password"), r.FormValue("port"))
+	cf.parseKafkaOptions(&errs, "New check", r.FormValue("topic"), r.FormValue("port"))
+	cf.parseAMQPOptions(&errs, "New check", r.FormValue("vhost"), r.FormValue("username"), r.FormValue("password"), r.FormValue("port"),
+		r.FormValue("tls"), r.FormValue("insecure_skip_verify"))
+	cf.parseKubernetesOptions(&errs, "New check", r.FormValue("kubeconfig"), r.FormValue("kube_context"), r.FormValue("token"),
+		r.FormValue("deployment"), r.FormValue("insecure_skip_verify"))
+	cf.parseProxmoxOptions(&errs, "New check", r.FormValue("token"), r.FormValue("node"), r.FormValue("vm"), r.FormValue("max_usage"),
+		r.FormValue("port"), r.FormValue("insecure_skip_verify"), false)
+	cf.parseESXiOptions(&errs, "New check", r.FormValue("username"), r.FormValue("password"), r.FormValue("vm"), r.FormValue("max_usage"),
+		r.FormValue("port"), r.FormValue("insecure_skip_verify"))
+	cf.parseUPSOptions(&errs, "New check", r.FormValue("daemon"), r.FormValue("ups"), r.FormValue("min_charge"), r.FormValue("port"))
+	cf.parseSpeedtestOptions(&errs, "New check", r.FormValue("upload"), r.FormValue("min_speed"), r.FormValue("interval"), r.FormValue("port"))
+	cf.parseDomainOptions(&errs, "New check", r.FormValue("domain"), r.FormValue("warn_days"))
+	cf.parseDNSBLOptions(&errs, "New check", r.FormValue("blocklists"))
+	cf.parseFileOptions(&errs, "New check", r.FormValue("path"), r.FormValue("max_age"), r.FormValue("sftp"),
+		r.FormValue("ssh_user"), r.FormValue("ssh_key"), r.FormValue("port"))
+	cf.Severity = parseSeverity(&errs, "New check", r.FormValue("severity"))
+	if s.st.CheckIDInUse(cf.ID, host) {
+		errs.Add("New check ID", "%q is already used by another check", cf.ID)
+	}
+	s.checkCompositeMembers(&errs, []checkForm{cf})
+	if errs.Any() {
+		s.renderFormErrors(w, "#edithost-errors", errs)
+		return
 	}
-	if err != nil {
+	cf.MQTTNotify = r.FormValue("mqtt_notify") == "true"
+	cf.PushoverNotify = r.FormValue("pushover_notify") == "true"
+	cf.TelegramNotify = r.FormValue("telegram_notify") == "true"
+	cf.SMSNotify = r.FormValue("sms_notify") == "true"
+	cf.Invert = r.FormValue("invert") == "true"
+	if err := s.addCheck(s.changes(r), host, cf); err != nil {
 		w.WriteHeader(409)
 		_, _ = w.Write([]byte(err.Error()))
 		return
 	}
 	hs, _ := s.st.GetHost(host)
-	_ = s.tpl.ExecuteTemplate(w, "edithost_modal.html", hs)
+	s.renderEditHost(w, r, hs)
 }
 
 func (s *Server) handleEditDelCheck(w http.ResponseWriter, r *http.Request) {
@@ -493,13 +867,18 @@ func (s *Server) handleEditDelCheck(w http.ResponseWriter, r *http.Request) {
 	host := r.FormValue("host")
 	idxStr := r.FormValue("idx")
 	idx, _ := strconv.Atoi(idxStr)
-	if err := s.st.RemoveCheck(host, idx); err != nil {
+	err := s.changes(r).RemoveCheck(host, idx, state.OrphanAction(r.FormValue("orphans")))
+	if errors.Is(err, state.ErrHasDependants) {
+		s.renderDeleteImpact(w, host, idx)
+		return
+	}
+	if err != nil {
 		w.WriteHeader(409)
 		_, _ = w.Write([]byte(err.Error()))
 		return
 	}
 	hs, _ := s.st.GetHost(host)
-	_ = s.tpl.ExecuteTemplate(w, "edithost_modal.html", hs)
+	s.renderEditHost(w, r, hs)
 }
 
 func (s *Server) handleEditSaveChecks(w http.ResponseWriter, r *http.Request) {
@@ -508,43 +887,21 @@ func (s *Server) handleEditSaveChecks(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	host := r.FormValue("host")
-	countStr := r.FormValue("check_count")
-	count, _ := strconv.Atoi(countStr)
-
-	for i := 0; i < count; i++ {
-		typ := r.FormValue(fmt.Sprintf("type_%d", i))
-		id := r.FormValue(fmt.Sprintf("id_%d", i))
-		dependsOn := r.FormValue(fmt.Sprintf("depends_on_%d", i))
-		mqttNotify := r.FormValue(fmt.Sprintf("mqtt_notify_%d", i)) == "true"
-		pushoverNotify := r.FormValue(fmt.Sprintf("pushover_notify_%d", i)) == "true"
-		telegramNotify := r.FormValue(fmt.Sprintf("telegram_notify_%d", i)) == "true"
-
-		if typ == "http" {
-			url := r.FormValue(fmt.Sprintf("url_%d", i))
-			expectStr := r.FormValue(fmt.Sprintf("expect_%d", i))
-			expect := 200
-			if expectStr != "" {
-				if v, err := strconv.Atoi(expectStr); err == nil {
-					expect = v
-				}
-			}
-			_ = s.st.UpdateHTTPCheck(host, i, url, expect, id, dependsOn, mqttNotify, pushoverNotify, telegramNotify)
-		} else if typ == "tcp" {
-			portStr := r.FormValue(fmt.Sprintf("port_%d", i))
-			port := 0
-			if portStr != "" {
-				if v, err := strconv.Atoi(portStr); err == nil {
-					port = v
-				}
-			}
-			_ = s.st.UpdateTCPCheck(host, i, port, id, dependsOn, mqttNotify, pushoverNotify, telegramNotify)
-		} else {
-			// For ping checks, just update the dependencies
-			_ = s.st.UpdateCheckDependencies(host, i, id, dependsOn, mqttNotify, pushoverNotify, telegramNotify)
-		}
+	count, _ := strconv.Atoi(r.FormValue("check_count"))
+
+	var errs validate.Errors
+	forms := parseIndexedChecks(r, &errs, count)
+	s.checkIDsUnique(&errs, host, forms)
+	s.checkIDsStillNeeded(&errs, host, forms)
+	s.checkShoutrrrLabels(&errs, forms)
+	s.checkCompositeMembers(&errs, forms)
+	if errs.Any() {
+		s.renderFormErrors(w, "#edithost-errors", errs)
+		return
 	}
+	s.updateChecks(s.changes(r), host, forms)
 	hs, _ := s.st.GetHost(host)
-	_ = s.tpl.ExecuteTemplate(w, "edithost_modal.html", hs)
+	s.renderEditHost(w, r, hs)
 }
 
 func (s *Server) handleEditUpdateCheck(w http.ResponseWriter, r *http.Request) {
@@ -553,38 +910,58 @@ func (s *Server) handleEditUpdateCheck(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	host := r.FormValue("host")
-	idxStr := r.FormValue("idx")
-	idx, _ := strconv.Atoi(idxStr)
-	url := r.FormValue("url")
-	expectStr := r.FormValue("expect")
-	id := r.FormValue("id")
-	dependsOn := r.FormValue("depends_on")
-	mqttNotify := r.FormValue("mqtt_notify") == "true"
-	pushoverNotify := r.FormValue("pushover_notify") == "true"
-	telegramNotify := r.FormValue("telegram_notify") == "true"
-	expect := 200
-	if expectStr != "" {
-		if v, err := strconv.Atoi(expectStr); err == nil {
-			expect = v
-		}
+	idx, err := strconv.Atoi(r.FormValue("idx"))
+	var errs validate.Errors
+	if err != nil {
+		errs.Add("Check", "index %q is not a number", r.FormValue("idx"))
+	}
+	cf := parseCheckForm(&errs, "Check", string(config.CheckHTTP), r.FormValue("url"), r.FormValue("expect"),
+		"", r.FormValue("id"), r.FormValue("depends_on"))
+	cf.parseHTTPOptions(&errs, "Check", r.FormValue("redirects"), r.FormValue("max_redirects"),
+		r.FormValue("proxy"), r.FormValue("insecure_skip_verify"))
+	cf.parseContentRules(&errs, "Check", r.FormValue("must_contain"), r.FormValue("must_not_contain"), r.FormValue("watch_content"))
+	cf.Idx = idx
+	if s.st.CheckIDInUse(cf.ID, host, idx) {
+		errs.Add("Check ID", "%q is already used by another check", cf.ID)
+	}
+	s.checkIDsStillNeeded(&errs, host, []checkForm{cf})
+	if errs.Any() {
+		s.renderFormErrors(w, "#edithost-errors", errs)
+		return
 	}
-	if err := s.st.UpdateHTTPCheck(host, idx, url, expect, id, dependsOn, mqttNotify, pushoverNotify, telegramNotify); err != nil {
+	cf.MQTTNotify = r.FormValue("mqtt_notify") == "true"
+	cf.PushoverNotify = r.FormValue("pushover_notify") == "true"
+	cf.TelegramNotify = r.FormValue("telegram_notify") == "true"
+	if err := s.updateCheck(s.changes(r), host, cf); err != nil {
 		w.WriteHeader(409)
 		_, _ = w.Write([]byte(err.Error()))
 		return
 	}
 	hs, _ := s.st.GetHost(host)
-	_ = s.tpl.ExecuteTemplate(w, "edithost_modal.html", hs)
+	s.renderEditHost(w, r, hs)
 }
 
 func (s *Server) handleStats(w http.ResponseWriter, r *http.Request) {
+	tags := s.visibleTags(r)
+	by := r.FormValue("group")
+	if by != "" && len(tags) > 0 {
+		http.Error(w, "not available to users who see only some hosts", http.StatusForbidden)
+		return
+	}
+	if s.notModified(w, r, "format", "group") {
+		return
+	}
 	data := struct {
 		Stats state.AggregateStats
 	}{
-		Stats: s.st.GetAggregateStats(),
+		Stats: s.st.GetAggregateStatsFor(tags),
 	}
 	w.Header().Set("Content-Type", "text/html; charset=utf-8")
 
+	if by != "" {
+		_ = s.tpl.ExecuteTemplate(w, "stats_groups.html", s.groupedStats(by))
+		return
+	}
 	format := r.FormValue("format")
 	if format == "compact" {
 		_ = s.tpl.ExecuteTemplate(w, "stats_compact.html", data)
@@ -593,482 +970,158 @@ func (s *Server) handleStats(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
-func (s *Server) handleSilenceAll(w http.ResponseWriter, r *http.Request) {
+func (s *Server) handlePause(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
 		w.WriteHeader(405)
 		return
 	}
-	s.st.SetAllEnabled(false)
-	data := struct{ Hosts []*state.HostStatus }{Hosts: s.st.Snapshot()}
-	w.Header().Set("Content-Type", "text/html; charset=utf-8")
-	_ = s.tpl.ExecuteTemplate(w, "hosts.html", data)
+	s.st.SetPaused(r.FormValue("paused") == "true")
+	s.handlePauseStatus(w, r)
 }
 
-func (s *Server) handleEnableAll(w http.ResponseWriter, r *http.Request) {
+// handleRunNow starts an immediate run of one host's checks (host=name) or of
+// every check. Results show on the dashboard's next refresh.
+func (s *Server) handleRunNow(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
 		w.WriteHeader(405)
 		return
 	}
-	s.st.SetAllEnabled(true)
-	data := struct{ Hosts []*state.HostStatus }{Hosts: s.st.Snapshot()}
-	w.Header().Set("Content-Type", "text/html; charset=utf-8")
-	_ = s.tpl.ExecuteTemplate(w, "hosts.html", data)
-}
-
-func toggleButton(host string, idx int, enabled bool) string {
-	if enabled {
-		return fmt.Sprintf(`<button class="check-toggle disable" hx-post="/toggle" hx-vals='{"host":"%s","idx":"%d","enabled":"false"}' hx-target="this" hx-swap="outerHTML">Disable</button>`, host, idx)
+	if err := s.st.RunNow(r.FormValue("host")); err != nil {
+		w.WriteHeader(409)
+		_, _ = w.Write([]byte(err.Error()))
+		return
 	}
-	return fmt.Sprintf(`<button class="check-toggle enable" hx-post="/toggle" hx-vals='{"host":"%s","idx":"%d","enabled":"true"}' hx-target="this" hx-swap="outerHTML">Enable</button>`, host, idx)
+	w.WriteHeader(http.StatusAccepted)
 }
 
-func logRequests(next http.Handler) http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		log.Printf("%s %s", r.Method, r.URL.Path)
-		next.ServeHTTP(w, r)
-	})
+// connectivityBanner is the data for connectivity_banner.html
+type connectivityBanner struct {
+	MonitorOffline   time.Time // The monitor itself can't reach the network
+	ConnectivityDown time.Time // Every host is failing at once
 }
 
-// generateSparklineSVG creates an inline SVG sparkline chart from latency history
-func generateSparklineSVG(history []int64, isOK bool) template.HTML {
-	if len(history) == 0 {
-		return template.HTML("")
-	}
-
-	width := 100
-	height := 24
-	padding := 2
-
-	// Find max value for scaling (minimum 1 to avoid division by zero)
-	maxVal := int64(1)
-	for _, v := range history {
-		if v > maxVal {
-			maxVal = v
-		}
-	}
-
-	// Build SVG path
-	points := make([]string, 0, len(history))
-	chartWidth := float64(width - 2*padding)
-	chartHeight := float64(height - 2*padding)
-
-	for i, v := range history {
-		x := float64(padding) + (float64(i)/float64(len(history)-1))*chartWidth
-		if len(history) == 1 {
-			x = float64(width) / 2
-		}
-		// Invert Y since SVG origin is top-left
-		y := float64(height-padding) - (float64(v)/float64(maxVal))*chartHeight
-		if i == 0 {
-			points = append(points, fmt.Sprintf("M%.1f,%.1f", x, y))
-		} else {
-			points = append(points, fmt.Sprintf("L%.1f,%.1f", x, y))
-		}
-	}
-
-	// Determine color based on current check status
-	lineColor := "#22c55e" // green by default
-	if !isOK {
-		lineColor = "#ef4444" // red if check is currently down
-	}
-
-	// Build filled area path (for gradient effect)
-	areaPoints := make([]string, 0, len(history)+2)
-	for i, v := range history {
-		x := float64(padding) + (float64(i)/float64(len(history)-1))*chartWidth
-		if len(history) == 1 {
-			x = float64(width) / 2
-		}
-		y := float64(height-padding) - (float64(v)/float64(maxVal))*chartHeight
-		if i == 0 {
-			areaPoints = append(areaPoints, fmt.Sprintf("M%.1f,%.1f", x, y))
-		} else {
-			areaPoints = append(areaPoints, fmt.Sprintf("L%.1f,%.1f", x, y))
-		}
-	}
-	// Close the area path
-	lastX := float64(padding) + chartWidth
-	if len(history) == 1 {
-		lastX = float64(width) / 2
-	}
-	areaPoints = append(areaPoints, fmt.Sprintf("L%.1f,%d", lastX, height-padding))
-	areaPoints = append(areaPoints, fmt.Sprintf("L%d,%d", padding, height-padding))
-	areaPoints = append(areaPoints, "Z")
-
-	svg := fmt.Sprintf(`<svg class="sparkline" width="%d" height="%d" viewBox="0 0 %d %d">
-		<defs>
-			<linearGradient id="sparkGrad" x1="0%%" y1="0%%" x2="0%%" y2="100%%">
-				<stop offset="0%%" style="stop-color:%s;stop-opacity:0.3"/>
-				<stop offset="100%%" style="stop-color:%s;stop-opacity:0.05"/>
-			</linearGradient>
-		</defs>
-		<path d="%s" fill="url(#sparkGrad)" />
-		<path d="%s" fill="none" stroke="%s" stroke-width="1.5" stroke-linecap="round" stroke-linejoin="round"/>
-	</svg>`,
-		width, height, width, height,
-		lineColor, lineColor,
-		joinStrings(areaPoints),
-		joinStrings(points),
-		lineColor)
-
-	return template.HTML(svg)
-}
-
-func joinStrings(s []string) string {
-	result := ""
-	for _, str := range s {
-		result += str
+func (s *Server) connectivityBanner() connectivityBanner {
+	return connectivityBanner{
+		MonitorOffline:   s.st.MonitorOfflineSince(),
+		ConnectivityDown: s.st.ConnectivityDownSince(),
 	}
-	return result
 }
 
-// generateDonutChartSVG creates a donut/ring chart showing up/down/disabled percentages
-func generateDonutChartSVG(stats state.AggregateStats) template.HTML {
-	size := 120
-	strokeWidth := 12
-	radius := (size - strokeWidth) / 2
-	center := size / 2
-	circumference := 2 * math.Pi * float64(radius)
-
-	total := stats.ChecksUp + stats.ChecksDown + stats.ChecksParentFailed + stats.ChecksDisabled + stats.ChecksUnknown
-	if total == 0 {
-		return template.HTML(fmt.Sprintf(`<svg width="%d" height="%d" viewBox="0 0 %d %d">
-			<circle cx="%d" cy="%d" r="%d" fill="none" stroke="#334155" stroke-width="%d"/>
-			<text x="%d" y="%d" text-anchor="middle" dominant-baseline="middle" fill="#64748b" font-size="12">No data</text>
-		</svg>`, size, size, size, size, center, center, radius, strokeWidth, center, center))
-	}
-
-	// Calculate segment sizes based on CURRENT status (for visual ring)
-	upPct := float64(stats.ChecksUp) / float64(total)
-	downPct := float64(stats.ChecksDown) / float64(total)
-	parentFailedPct := float64(stats.ChecksParentFailed) / float64(total)
-	disabledPct := float64(stats.ChecksDisabled) / float64(total)
-	unknownPct := float64(stats.ChecksUnknown) / float64(total)
-
-	upLen := circumference * upPct
-	downLen := circumference * downPct
-	parentFailedLen := circumference * parentFailedPct
-	disabledLen := circumference * disabledPct
-	unknownLen := circumference * unknownPct
-
-	upOffset := 0.0
-	downOffset := -upLen
-	parentFailedOffset := -upLen - downLen
-	disabledOffset := -upLen - downLen - parentFailedLen
-	unknownOffset := -upLen - downLen - parentFailedLen - disabledLen
-
-	// Use historical uptime for the center percentage
-	// If no historical data yet, show current status percentage
-	displayPct := stats.OverallUptime
-	if displayPct == 0 && stats.ChecksUp > 0 {
-		// No historical data yet, show current percentage
-		displayPct = float64(stats.ChecksUp) / float64(stats.ChecksUp+stats.ChecksDown) * 100
-	}
-
-	// Choose center text color based on health
-	textColor := "#22c55e" // green
-	if stats.ChecksDown > 0 {
-		textColor = "#ef4444" // red
-	} else if stats.ChecksParentFailed > 0 {
-		textColor = "#f97316" // orange for parent-failed
-	} else if displayPct < 99 {
-		textColor = "#f59e0b" // amber
-	}
-
-	svg := fmt.Sprintf(`<svg width="%d" height="%d" viewBox="0 0 %d %d" class="donut-chart">
-		<circle cx="%d" cy="%d" r="%d" fill="none" stroke="#1e293b" stroke-width="%d"/>
-		<circle cx="%d" cy="%d" r="%d" fill="none" stroke="#22c55e" stroke-width="%d" 
-			stroke-dasharray="%.1f %.1f" stroke-dashoffset="%.1f" transform="rotate(-90 %d %d)"/>
-		<circle cx="%d" cy="%d" r="%d" fill="none" stroke="#ef4444" stroke-width="%d"
-			stroke-dasharray="%.1f %.1f" stroke-dashoffset="%.1f" transform="rotate(-90 %d %d)"/>
-		<circle cx="%d" cy="%d" r="%d" fill="none" stroke="#f97316" stroke-width="%d"
-			stroke-dasharray="%.1f %.1f" stroke-dashoffset="%.1f" transform="rotate(-90 %d %d)"/>
-		<circle cx="%d" cy="%d" r="%d" fill="none" stroke="#64748b" stroke-width="%d"
-			stroke-dasharray="%.1f %.1f" stroke-dashoffset="%.1f" transform="rotate(-90 %d %d)"/>
-		<circle cx="%d" cy="%d" r="%d" fill="none" stroke="#f59e0b" stroke-width="%d"
-			stroke-dasharray="%.1f %.1f" stroke-dashoffset="%.1f" transform="rotate(-90 %d %d)"/>
-		<text x="%d" y="%d" text-anchor="middle" dominant-baseline="middle" fill="%s" font-size="20" font-weight="600">%.1f%%</text>
-		<text x="%d" y="%d" text-anchor="middle" dominant-baseline="middle" fill="#64748b" font-size="10">uptime</text>
-	</svg>`,
-		size, size, size, size,
-		center, center, radius, strokeWidth,
-		center, center, radius, strokeWidth, upLen, circumference, upOffset, center, center,
-		center, center, radius, strokeWidth, downLen, circumference, downOffset, center, center,
-		center, center, radius, strokeWidth, parentFailedLen, circumference, parentFailedOffset, center, center, // Orange for parent-failed
-		center, center, radius, strokeWidth, disabledLen, circumference, disabledOffset, center, center,
-		center, center, radius, strokeWidth, unknownLen, circumference, unknownOffset, center, center,
-		center, center-4, textColor, displayPct,
-		center, center+14)
-
-	return template.HTML(svg)
-}
-
-// generateHeatmapSVG creates a heatmap grid showing recent check results
-func generateHeatmapSVG(data []bool) template.HTML {
-	if len(data) == 0 {
-		return template.HTML("")
-	}
-
-	// Use smaller cells and single row for compact display
-	cellSize := 4
-	gap := 1
-	cols := len(data) // Single row
-	if cols > 30 {
-		cols = 30 // Max 30 cells
-		data = data[len(data)-30:]
-	}
-
-	width := cols*(cellSize+gap) - gap
-	height := cellSize
-
-	var cells string
-	for i, ok := range data {
-		x := i * (cellSize + gap)
-		color := "#22c55e"
-		if !ok {
-			color = "#ef4444"
-		}
-		cells += fmt.Sprintf(`<rect x="%d" y="0" width="%d" height="%d" rx="1" fill="%s"/>`, x, cellSize, cellSize, color)
+func (s *Server) handleConnectivityBanner(w http.ResponseWriter, r *http.Request) {
+	if s.notModified(w, r) {
+		return
 	}
-
-	return template.HTML(fmt.Sprintf(`<svg width="%d" height="%d" viewBox="0 0 %d %d" class="heatmap">%s</svg>`, width, height, width, height, cells))
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	_ = s.tpl.ExecuteTemplate(w, "connectivity_banner.html", s.connectivityBanner())
 }
 
-// generateUptimeBarSVG creates a horizontal bar showing uptime percentage
-func generateUptimeBarSVG(uptime float64) template.HTML {
-	width := 100
-	height := 8
-
-	color := "#22c55e"
-	if uptime < 99 {
-		color = "#f59e0b"
-	}
-	if uptime < 95 {
-		color = "#ef4444"
+func (s *Server) handlePauseStatus(w http.ResponseWriter, r *http.Request) {
+	if s.notModified(w, r) {
+		return
 	}
-
-	fillWidth := int(float64(width) * uptime / 100)
-
-	return template.HTML(fmt.Sprintf(`<svg width="%d" height="%d" viewBox="0 0 %d %d" class="uptime-bar">
-		<rect x="0" y="0" width="%d" height="%d" rx="4" fill="#1e293b"/>
-		<rect x="0" y="0" width="%d" height="%d" rx="4" fill="%s"/>
-	</svg>`, width, height, width, height, width, height, fillWidth, height, color))
+	data := struct{ Paused bool }{Paused: s.st.IsPaused()}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	_ = s.tpl.ExecuteTemplate(w, "pause_control.html", data)
 }
 
-// generateSmokepingChartSVG creates a smokeping-style latency chart
-func generateSmokepingChartSVG(history []state.CheckDataPoint, width, height int) template.HTML {
-	if len(history) == 0 {
-		return template.HTML(fmt.Sprintf(`<svg width="%d" height="%d" viewBox="0 0 %d %d">
-			<text x="%d" y="%d" text-anchor="middle" dominant-baseline="middle" fill="#64748b" font-size="12">No data yet</text>
-		</svg>`, width, height, width, height, width/2, height/2))
-	}
-
-	// Scale padding based on chart size
-	paddingX := 35
-	paddingY := 20
-	if height < 150 {
-		paddingY = 15
-	}
-	chartWidth := width - 2*paddingX
-	chartHeight := height - 2*paddingY
-
-	// Find max latency for scaling
-	maxLatency := int64(1)
-	for _, dp := range history {
-		if dp.LatencyMS > maxLatency {
-			maxLatency = dp.LatencyMS
+// handleAPIScheduler reports (GET) or sets (POST paused=true|false) whether
+// monitoring is paused. The menu bar process uses this to pause/resume.
+func (s *Server) handleAPIScheduler(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+	case http.MethodPost:
+		paused, err := strconv.ParseBool(r.FormValue("paused"))
+		if err != nil {
+			http.Error(w, "paused must be true or false", http.StatusBadRequest)
+			return
 		}
+		s.st.SetPaused(paused)
+	default:
+		w.WriteHeader(405)
+		return
 	}
-	// Add 20% headroom
-	maxLatency = int64(float64(maxLatency) * 1.2)
-	if maxLatency < 10 {
-		maxLatency = 10
-	}
-
-	// Group data points into buckets for percentile calculation
-	bucketCount := chartWidth / 3 // One bucket per 3 pixels
-	if bucketCount > len(history) {
-		bucketCount = len(history)
-	}
-	if bucketCount < 1 {
-		bucketCount = 1
-	}
-
-	type bucket struct {
-		min, max, median, p75, p95 int64
-		hasData                    bool
-		hasFailure                 bool
-	}
-
-	buckets := make([]bucket, bucketCount)
-
-	for bi := 0; bi < bucketCount; bi++ {
-		start := bi * len(history) / bucketCount
-		end := (bi + 1) * len(history) / bucketCount
-		if end > len(history) {
-			end = len(history)
-		}
-
-		var latencies []int64
-		for i := start; i < end; i++ {
-			if !history[i].OK {
-				buckets[bi].hasFailure = true
-			}
-			if history[i].LatencyMS > 0 {
-				latencies = append(latencies, history[i].LatencyMS)
-			}
-		}
-
-		if len(latencies) > 0 {
-			buckets[bi].hasData = true
-			sort.Slice(latencies, func(i, j int) bool { return latencies[i] < latencies[j] })
-			buckets[bi].min = latencies[0]
-			buckets[bi].max = latencies[len(latencies)-1]
-			buckets[bi].median = latencies[len(latencies)/2]
-			buckets[bi].p75 = latencies[int(float64(len(latencies))*0.75)]
-			p95Idx := int(float64(len(latencies)) * 0.95)
-			if p95Idx >= len(latencies) {
-				p95Idx = len(latencies) - 1
-			}
-			buckets[bi].p95 = latencies[p95Idx]
-		}
-	}
-
-	// Build SVG
-	var svg string
-	svg += fmt.Sprintf(`<svg width="%d" height="%d" viewBox="0 0 %d %d" class="smokeping-chart">`, width, height, width, height)
-
-	// Background
-	svg += fmt.Sprintf(`<rect x="0" y="0" width="%d" height="%d" fill="#0f172a"/>`, width, height)
-
-	// Grid lines - keep 5 for good granularity
-	gridLines := 5
-	for i := 0; i <= gridLines; i++ {
-		y := paddingY + i*chartHeight/gridLines
-		latencyVal := maxLatency - int64(i)*maxLatency/int64(gridLines)
-		svg += fmt.Sprintf(`<line x1="%d" y1="%d" x2="%d" y2="%d" stroke="#1e293b" stroke-width="0.5"/>`, paddingX, y, paddingX+chartWidth, y)
-		svg += fmt.Sprintf(`<text x="%d" y="%d" text-anchor="end" fill="#64748b" font-size="7">%dms</text>`, paddingX-2, y+2, latencyVal)
-	}
-
-	// X-axis labels
-	if len(history) > 0 {
-		first := history[0].Timestamp.Format("15:04")
-		last := history[len(history)-1].Timestamp.Format("15:04")
-		svg += fmt.Sprintf(`<text x="%d" y="%d" text-anchor="start" fill="#64748b" font-size="7">%s</text>`, paddingX, height-2, first)
-		svg += fmt.Sprintf(`<text x="%d" y="%d" text-anchor="end" fill="#64748b" font-size="7">%s</text>`, paddingX+chartWidth, height-2, last)
-	}
-
-	// Draw smokeping-style bands
-	bucketWidth := float64(chartWidth) / float64(bucketCount)
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(map[string]bool{"paused": s.st.IsPaused()})
+}
 
-	// P95 to Max band (lightest)
-	var maxPoints, p95PointsRevForMax []string
-	for bi, b := range buckets {
-		if !b.hasData {
-			continue
-		}
-		x := float64(paddingX) + float64(bi)*bucketWidth + bucketWidth/2
-		yMax := float64(paddingY) + float64(chartHeight)*(1-float64(b.max)/float64(maxLatency))
-		yP95 := float64(paddingY) + float64(chartHeight)*(1-float64(b.p95)/float64(maxLatency))
-		maxPoints = append(maxPoints, fmt.Sprintf("%.1f,%.1f", x, yMax))
-		p95PointsRevForMax = append([]string{fmt.Sprintf("%.1f,%.1f", x, yP95)}, p95PointsRevForMax...)
-	}
-	if len(maxPoints) > 0 {
-		svg += fmt.Sprintf(`<polygon points="%s %s" fill="rgba(59, 130, 246, 0.1)"/>`,
-			joinStrings2(maxPoints, " "), joinStrings2(p95PointsRevForMax, " "))
+func (s *Server) handleSilenceAll(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.WriteHeader(405)
+		return
 	}
+	s.st.SetAllEnabled(false)
+	data := s.queryHosts(r)
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	_ = s.tpl.ExecuteTemplate(w, "hosts.html", data)
+}
 
-	// P75 to P95 band
-	var p75Points, p95PointsRev []string
-	for bi, b := range buckets {
-		if !b.hasData {
-			continue
-		}
-		x := float64(paddingX) + float64(bi)*bucketWidth + bucketWidth/2
-		yP75 := float64(paddingY) + float64(chartHeight)*(1-float64(b.p75)/float64(maxLatency))
-		yP95 := float64(paddingY) + float64(chartHeight)*(1-float64(b.p95)/float64(maxLatency))
-		p75Points = append(p75Points, fmt.Sprintf("%.1f,%.1f", x, yP75))
-		p95PointsRev = append([]string{fmt.Sprintf("%.1f,%.1f", x, yP95)}, p95PointsRev...)
-	}
-	if len(p75Points) > 0 {
-		svg += fmt.Sprintf(`<polygon points="%s %s" fill="rgba(59, 130, 246, 0.2)"/>`,
-			joinStrings2(p75Points, " "), joinStrings2(p95PointsRev, " "))
+func (s *Server) handleEnableAll(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.WriteHeader(405)
+		return
 	}
+	s.st.SetAllEnabled(true)
+	data := s.queryHosts(r)
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	_ = s.tpl.ExecuteTemplate(w, "hosts.html", data)
+}
 
-	// Median to P75 band
-	var medianPoints, p75PointsRev []string
-	for bi, b := range buckets {
-		if !b.hasData {
-			continue
+// anyChecksEnabled reports whether at least one check on a host is enabled
+func anyChecksEnabled(checks []state.CheckStatus) bool {
+	for _, c := range checks {
+		if c.Enabled {
+			return true
 		}
-		x := float64(paddingX) + float64(bi)*bucketWidth + bucketWidth/2
-		yMedian := float64(paddingY) + float64(chartHeight)*(1-float64(b.median)/float64(maxLatency))
-		yP75 := float64(paddingY) + float64(chartHeight)*(1-float64(b.p75)/float64(maxLatency))
-		medianPoints = append(medianPoints, fmt.Sprintf("%.1f,%.1f", x, yMedian))
-		p75PointsRev = append([]string{fmt.Sprintf("%.1f,%.1f", x, yP75)}, p75PointsRev...)
-	}
-	if len(medianPoints) > 0 {
-		svg += fmt.Sprintf(`<polygon points="%s %s" fill="rgba(59, 130, 246, 0.4)"/>`,
-			joinStrings2(medianPoints, " "), joinStrings2(p75PointsRev, " "))
 	}
+	return false
+}
 
-	// Min to Median band (darkest)
-	var minPoints, medianPointsRev []string
-	for bi, b := range buckets {
-		if !b.hasData {
-			continue
-		}
-		x := float64(paddingX) + float64(bi)*bucketWidth + bucketWidth/2
-		yMin := float64(paddingY) + float64(chartHeight)*(1-float64(b.min)/float64(maxLatency))
-		yMedian := float64(paddingY) + float64(chartHeight)*(1-float64(b.median)/float64(maxLatency))
-		minPoints = append(minPoints, fmt.Sprintf("%.1f,%.1f", x, yMin))
-		medianPointsRev = append([]string{fmt.Sprintf("%.1f,%.1f", x, yMedian)}, medianPointsRev...)
+// hxVals builds the JSON object for an hx-vals attribute from key/value pairs.
+// Values are JSON-encoded here and then attribute-escaped by html/template, so
+// host names containing quotes or markup cannot break out of the attribute.
+func hxVals(kv ...any) (string, error) {
+	if len(kv)%2 != 0 {
+		return "", fmt.Errorf("hxVals: odd number of arguments")
 	}
-	if len(minPoints) > 0 {
-		svg += fmt.Sprintf(`<polygon points="%s %s" fill="rgba(59, 130, 246, 0.6)"/>`,
-			joinStrings2(minPoints, " "), joinStrings2(medianPointsRev, " "))
-	}
-
-	// Median line
-	var medianPath string
-	for bi, b := range buckets {
-		if !b.hasData {
-			continue
-		}
-		x := float64(paddingX) + float64(bi)*bucketWidth + bucketWidth/2
-		y := float64(paddingY) + float64(chartHeight)*(1-float64(b.median)/float64(maxLatency))
-		if medianPath == "" {
-			medianPath = fmt.Sprintf("M%.1f,%.1f", x, y)
-		} else {
-			medianPath += fmt.Sprintf(" L%.1f,%.1f", x, y)
+	vals := make(map[string]string, len(kv)/2)
+	for i := 0; i < len(kv); i += 2 {
+		key, ok := kv[i].(string)
+		if !ok {
+			return "", fmt.Errorf("hxVals: key %v is not a string", kv[i])
 		}
+		vals[key] = fmt.Sprint(kv[i+1])
 	}
-	if medianPath != "" {
-		svg += fmt.Sprintf(`<path d="%s" fill="none" stroke="#3b82f6" stroke-width="1"/>`, medianPath)
+	b, err := json.Marshal(vals)
+	if err != nil {
+		return "", err
 	}
+	return string(b), nil
+}
 
-	// Packet loss markers (red dots)
-	for bi, b := range buckets {
-		if b.hasFailure {
-			x := float64(paddingX) + float64(bi)*bucketWidth + bucketWidth/2
-			y := float64(paddingY + chartHeight - 2)
-			svg += fmt.Sprintf(`<circle cx="%.1f" cy="%.1f" r="1.5" fill="#ef4444"/>`, x, y)
-		}
-	}
+func logRequests(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		log.Printf("%s %s", r.Method, r.URL.Path)
+		next.ServeHTTP(w, r)
+	})
+}
 
-	svg += `</svg>`
-	return template.HTML(svg)
+// timeLayouts are the formats localTime offers, as Go layouts for the
+// server-rendered text. static/localtime.js has the browser equivalents.
+var timeLayouts = map[string]string{
+	"time":     "15:04:05",
+	"hm":       "15:04",
+	"datetime": "Jan 2 15:04:05",
+	"datehm":   "Jan 2 15:04",
+	"date":     "Jan 2 2006",
 }
 
-func joinStrings2(s []string, sep string) string {
-	result := ""
-	for i, str := range s {
-		if i > 0 {
-			result += sep
-		}
-		result += str
-	}
-	return result
+// localTime renders t as a <time> element in loc. Pages re-render it in the
+// display timezone, or the browser's own zone if none is configured, and the
+// title gives the full timestamp with its offset.
+func localTime(t time.Time, layout string, loc *time.Location) template.HTML {
+	t = t.In(loc)
+	return template.HTML(fmt.Sprintf(`<time datetime="%s" data-layout="%s" title="%s">%s</time>`,
+		t.Format(time.RFC3339), layout, t.Format("2006-01-02 15:04:05 MST (-07:00)"), t.Format(timeLayouts[layout])))
 }
 
 func formatUptime(uptime float64) string {
@@ -1082,6 +1135,49 @@ func formatUptime(uptime float64) string {
 	return fmt.Sprintf("%.1f%%", uptime)
 }
 
+// formatAgeLimit shows a max age as it would be written in the config, e.g.
+// "25h" rather than "25h0m0s", so it can be edited and saved again
+func formatAgeLimit(d time.Duration) string {
+	if d <= 0 {
+		return ""
+	}
+	s := d.String()
+	if strings.HasSuffix(s, "m0s") {
+		s = strings.TrimSuffix(s, "0s")
+	}
+	if strings.HasSuffix(s, "h0m") {
+		s = strings.TrimSuffix(s, "0m")
+	}
+	return s
+}
+
+// formatMeanTime shows an MTTR or MTBF to two units, e.g. "3h 20m", or a
+// dash when there is nothing to average yet
+func formatMeanTime(d time.Duration) string {
+	switch {
+	case d <= 0:
+		return "—"
+	case d < time.Minute:
+		return fmt.Sprintf("%ds", int(d.Round(time.Second)/time.Second))
+	case d < time.Hour:
+		d = d.Round(time.Second)
+		return fmt.Sprintf("%dm %ds", int(d/time.Minute), int(d%time.Minute/time.Second))
+	case d < 24*time.Hour:
+		d = d.Round(time.Minute)
+		return fmt.Sprintf("%dh %dm", int(d/time.Hour), int(d%time.Hour/time.Minute))
+	}
+	d = d.Round(time.Hour)
+	return fmt.Sprintf("%dd %dh", int(d/(24*time.Hour)), int(d%(24*time.Hour)/time.Hour))
+}
+
+// formatThroughput shows a download speed, or a dash when there is none
+func formatThroughput(bytesPerSec float64) string {
+	if bytesPerSec <= 0 {
+		return "—"
+	}
+	return checks.FormatBytes(int64(bytesPerSec)) + "/s"
+}
+
 func healthScoreColor(score int) string {
 	if score >= 95 {
 		return "#22c55e"
@@ -1122,14 +1218,21 @@ func extractHeatmapData(history []state.CheckDataPoint) []bool {
 
 // Analytics handlers
 func (s *Server) handleAnalytics(w http.ResponseWriter, r *http.Request) {
+	hosts := s.st.GetAllHostAnalytics()
 	data := struct {
-		Hosts  []state.HostAnalytics
-		Stats  state.AggregateStats
-		Events []state.Event
+		Hosts      []state.HostAnalytics
+		Stats      state.AggregateStats
+		Groups     groupStatsView
+		Flappy     []state.FlapStat
+		Correlated correlatedView
+		Events     []annotatedEvent
 	}{
-		Hosts:  s.st.GetAllHostAnalytics(),
-		Stats:  s.st.GetAggregateStats(),
-		Events: state.GetEvents(20),
+		Hosts:      hosts,
+		Stats:      s.st.GetAggregateStats(),
+		Groups:     s.groupedStats(r.FormValue("group")),
+		Flappy:     s.st.Flappiest(10),
+		Correlated: s.correlatedOutages(r.FormValue("window")),
+		Events:     annotateEvents(state.GetEvents(20), hosts),
 	}
 	_ = s.tpl.ExecuteTemplate(w, "analytics.html", data)
 }
@@ -1161,6 +1264,22 @@ func (s *Server) handleSettings(w http.ResponseWriter, r *http.Request) {
 		PushoverEnabled bool
 		Telegram        config.TelegramSettings
 		TelegramEnabled bool
+		MQTTMute        muteControl
+		PushoverMute    muteControl
+		TelegramMute    muteControl
+		Shoutrrr        config.ShoutrrrSettings
+		ShoutrrrEnabled bool
+		ShoutrrrLabels  []string
+		ShoutrrrMute    muteControl
+		Twilio          config.TwilioSettings
+		TwilioEnabled   bool
+		SMSMute         muteControl
+		Alerts          config.AlertSettings
+		QuietChannels   map[string]string // Channel -> label, for per-channel quiet hours
+		Latency         config.LatencySettings
+		LatencyTypes    []config.CheckType // Check types that can have an expected latency
+		Display         config.DisplaySettings
+		Remote          state.RemoteStatus
 	}{
 		MQTT:            mqttSettings,
 		MQTTConnected:   s.st.IsMQTTConnected(),
@@ -1168,10 +1287,283 @@ func (s *Server) handleSettings(w http.ResponseWriter, r *http.Request) {
 		PushoverEnabled: s.st.IsPushoverEnabled(),
 		Telegram:        telegramSettings,
 		TelegramEnabled: s.st.IsTelegramEnabled(),
+		MQTTMute:        s.muteControl(state.ChannelMQTT),
+		PushoverMute:    s.muteControl(state.ChannelPushover),
+		TelegramMute:    s.muteControl(state.ChannelTelegram),
+		Shoutrrr:        s.st.GetShoutrrrSettings(),
+		ShoutrrrEnabled: s.st.IsShoutrrrEnabled(),
+		ShoutrrrLabels:  s.st.ShoutrrrLabels(),
+		ShoutrrrMute:    s.muteControl(state.ChannelShoutrrr),
+		Twilio:          s.st.GetTwilioSettings(),
+		TwilioEnabled:   s.st.IsTwilioEnabled(),
+		SMSMute:         s.muteControl(state.ChannelSMS),
+		Alerts:          s.st.GetAlertSettings(),
+		QuietChannels:   make(map[string]string),
+		Latency:         s.st.GetLatencySettings(),
+		LatencyTypes:    config.LatencyCheckTypes,
+		Display:         s.st.GetDisplaySettings(),
+		Remote:          s.st.GetRemoteStatus(),
+	}
+	for _, ch := range state.QuietChannels {
+		data.QuietChannels[ch] = channelLabels[ch]
 	}
 	_ = s.tpl.ExecuteTemplate(w, "settings.html", data)
 }
 
+// muteDurations are the mute lengths offered on the settings page
+var muteDurations = []string{"1h", "8h", "24h"}
+
+var channelLabels = map[string]string{
+	state.ChannelMQTT:     "MQTT",
+	state.ChannelPushover: "Pushover",
+	state.ChannelTelegram: "Telegram",
+	state.ChannelShoutrrr: "Shoutrrr",
+	state.ChannelSMS:      "SMS",
+}
+
+// muteControl is the data for mute_control.html
+type muteControl struct {
+	Channel   string
+	Label     string
+	Until     time.Time
+	Remaining string
+	Durations []string
+}
+
+func (s *Server) muteControl(channel string) muteControl {
+	until := s.st.ChannelMutedUntil(channel)
+	return muteControl{
+		Channel:   channel,
+		Label:     channelLabels[channel],
+		Until:     until,
+		Remaining: formatRemaining(time.Until(until)),
+		Durations: muteDurations,
+	}
+}
+
+// formatRemaining renders a countdown such as "7h 59m"
+func formatRemaining(d time.Duration) string {
+	if d < time.Minute {
+		return "<1m"
+	}
+	d = d.Round(time.Minute)
+	h := int(d.Hours())
+	m := int(d.Minutes()) % 60
+	if h == 0 {
+		return fmt.Sprintf("%dm", m)
+	}
+	return fmt.Sprintf("%dh %dm", h, m)
+}
+
+// handleSettingsMute renders a channel's mute control, or on POST mutes it
+// for the given duration ("0" unmutes)
+func (s *Server) handleSettingsMute(w http.ResponseWriter, r *http.Request) {
+	channel := r.FormValue("channel")
+	if _, ok := channelLabels[channel]; !ok {
+		w.WriteHeader(400)
+		_, _ = w.Write([]byte("unknown channel"))
+		return
+	}
+	if r.Method == http.MethodPost {
+		d, err := time.ParseDuration(r.FormValue("duration"))
+		if err != nil || d < 0 {
+			w.WriteHeader(400)
+			_, _ = w.Write([]byte("invalid duration"))
+			return
+		}
+		if d == 0 {
+			err = s.st.UnmuteChannel(channel)
+		} else {
+			err = s.st.MuteChannel(channel, d)
+		}
+		if err != nil {
+			w.WriteHeader(409)
+			_, _ = w.Write([]byte(err.Error()))
+			return
+		}
+	}
+	_ = s.tpl.ExecuteTemplate(w, "mute_control.html", s.muteControl(channel))
+}
+
+func (s *Server) handleSettingsAlerts(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.WriteHeader(405)
+		return
+	}
+
+	interval := strings.TrimSpace(r.FormValue("reminder_interval"))
+	if interval != "" {
+		d, err := time.ParseDuration(interval)
+		if err != nil || d < time.Minute {
+			w.WriteHeader(422)
+			_, _ = w.Write([]byte(`<div class="alert alert-error">Reminder interval must be a duration of at least 1m, e.g. 6h.</div>`))
+			return
+		}
+	}
+	batch := strings.TrimSpace(r.FormValue("batch_window"))
+	if batch != "" {
+		d, err := time.ParseDuration(batch)
+		if err != nil || d < time.Second || d > 10*time.Minute {
+			w.WriteHeader(422)
+			_, _ = w.Write([]byte(`<div class="alert alert-error">Batching window must be a duration between 1s and 10m, e.g. 30s.</div>`))
+			return
+		}
+	}
+	grace := strings.TrimSpace(r.FormValue("startup_grace"))
+	if grace != "" {
+		d, err := time.ParseDuration(grace)
+		if err != nil || d < 0 || d > time.Hour {
+			w.WriteHeader(422)
+			_, _ = w.Write([]byte(`<div class="alert alert-error">Startup grace period must be a duration of up to 1h, e.g. 2m.</div>`))
+			return
+		}
+	}
+	flapThreshold := 0
+	if v := strings.TrimSpace(r.FormValue("flap_threshold")); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			w.WriteHeader(422)
+			_, _ = w.Write([]byte(`<div class="alert alert-error">Flap threshold must be a whole number of state changes, e.g. 5.</div>`))
+			return
+		}
+		flapThreshold = n
+	}
+	flapWindow := strings.TrimSpace(r.FormValue("flap_window"))
+	if flapWindow != "" {
+		d, err := time.ParseDuration(flapWindow)
+		if err != nil || d < time.Minute || d > 24*time.Hour {
+			w.WriteHeader(422)
+			_, _ = w.Write([]byte(`<div class="alert alert-error">Flap window must be a duration between 1m and 24h, e.g. 1h.</div>`))
+			return
+		}
+	}
+	settings := config.AlertSettings{ReminderInterval: interval, BatchWindow: batch, StartupGrace: grace, FlapThreshold: flapThreshold, FlapWindow: flapWindow}
+
+	var errs []string
+	settings.QuietHours = parseQuietHours(&errs, "Quiet hours", r.FormValue("quiet_start"), r.FormValue("quiet_end"))
+	for _, channel := range state.QuietChannels {
+		qh := parseQuietHours(&errs, channelLabels[channel]+" quiet hours", r.FormValue("quiet_start_"+channel), r.FormValue("quiet_end_"+channel))
+		if qh != (config.QuietHours{}) {
+			if settings.ChannelQuietHours == nil {
+				settings.ChannelQuietHours = make(map[string]config.QuietHours)
+			}
+			settings.ChannelQuietHours[channel] = qh
+		}
+	}
+	if len(errs) > 0 {
+		w.WriteHeader(422)
+		_, _ = w.Write([]byte(fmt.Sprintf(`<div class="alert alert-error">%s</div>`, template.HTMLEscapeString(strings.Join(errs, "; ")))))
+		return
+	}
+
+	if err := s.changes(r).UpdateAlertSettings(settings); err != nil {
+		w.WriteHeader(500)
+		_, _ = w.Write([]byte(fmt.Sprintf(`<div class="alert alert-error">Error saving settings: %s</div>`, template.HTMLEscapeString(err.Error()))))
+		return
+	}
+
+	_, _ = w.Write([]byte(`<div class="alert alert-success">Alert settings saved successfully.</div>`))
+}
+
+// parseQuietHours reads a start/end pair from the alert settings form. Both
+// must be set, or neither.
+func parseQuietHours(errs *[]string, label, start, end string) config.QuietHours {
+	qh := config.QuietHours{Start: strings.TrimSpace(start), End: strings.TrimSpace(end)}
+	if qh.Start == "" && qh.End == "" {
+		return qh
+	}
+	if qh.Start == "" || qh.End == "" {
+		*errs = append(*errs, label+" need both a start and an end")
+		return config.QuietHours{}
+	}
+	for _, t := range []string{qh.Start, qh.End} {
+		if _, err := config.ParseClock(t); err != nil {
+			*errs = append(*errs, fmt.Sprintf("%s: %v", label, err))
+			return config.QuietHours{}
+		}
+	}
+	if qh.Start == qh.End {
+		*errs = append(*errs, label+" must start and end at different times")
+		return config.QuietHours{}
+	}
+	return qh
+}
+
+func (s *Server) handleSettingsLatency(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.WriteHeader(405)
+		return
+	}
+
+	var settings config.LatencySettings
+	var errs []string
+	for _, t := range config.LatencyCheckTypes {
+		v := strings.TrimSpace(r.FormValue("latency_" + string(t)))
+		if v == "" {
+			continue
+		}
+		if d, err := time.ParseDuration(v); err != nil || d <= 0 {
+			errs = append(errs, fmt.Sprintf("%s: %q is not a duration, e.g. 5ms", t, v))
+			continue
+		}
+		if settings.Expected == nil {
+			settings.Expected = make(map[string]string)
+		}
+		settings.Expected[string(t)] = v
+	}
+	if v := strings.TrimSpace(r.FormValue("slow_runs")); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			errs = append(errs, fmt.Sprintf("slow runs: %q is not a whole number", v))
+		}
+		settings.SlowRuns = n
+	}
+	if v := strings.TrimSpace(r.FormValue("clear_percent")); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 || n > 100 {
+			errs = append(errs, fmt.Sprintf("clear below: %q is not a percentage from 1 to 100", v))
+		}
+		settings.ClearPercent = n
+	}
+	if len(errs) > 0 {
+		w.WriteHeader(422)
+		_, _ = w.Write([]byte(fmt.Sprintf(`<div class="alert alert-error">Expected latency %s</div>`, template.HTMLEscapeString(strings.Join(errs, "; ")))))
+		return
+	}
+
+	if err := s.changes(r).UpdateLatencySettings(settings); err != nil {
+		w.WriteHeader(500)
+		_, _ = w.Write([]byte(fmt.Sprintf(`<div class="alert alert-error">Error saving settings: %s</div>`, template.HTMLEscapeString(err.Error()))))
+		return
+	}
+
+	_, _ = w.Write([]byte(`<div class="alert alert-success">Expected latencies saved; checks are classified against them from their next run.</div>`))
+}
+
+func (s *Server) handleSettingsDisplay(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.WriteHeader(405)
+		return
+	}
+
+	settings := config.DisplaySettings{Timezone: strings.TrimSpace(r.FormValue("timezone"))}
+	if settings.Timezone != "" {
+		if _, err := config.LoadTimezone(settings.Timezone); err != nil {
+			w.WriteHeader(422)
+			_, _ = w.Write([]byte(fmt.Sprintf(`<div class="alert alert-error">%s</div>`, template.HTMLEscapeString(err.Error()))))
+			return
+		}
+	}
+
+	if err := s.changes(r).UpdateDisplaySettings(settings); err != nil {
+		w.WriteHeader(500)
+		_, _ = w.Write([]byte(fmt.Sprintf(`<div class="alert alert-error">Error saving settings: %s</div>`, template.HTMLEscapeString(err.Error()))))
+		return
+	}
+
+	_, _ = w.Write([]byte(`<div class="alert alert-success">Display settings saved. Reload open pages to apply them.</div>`))
+}
+
 func (s *Server) handleSettingsMQTT(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
 		w.WriteHeader(405)
@@ -1194,9 +1586,9 @@ func (s *Server) handleSettingsMQTT(w http.ResponseWriter, r *http.Request) {
 		Topic:    topic,
 	}
 
-	if err := s.st.UpdateMQTTSettings(settings); err != nil {
+	if err := s.changes(r).UpdateMQTTSettings(settings); err != nil {
 		w.WriteHeader(500)
-		_, _ = w.Write([]byte(fmt.Sprintf(`<div class="alert alert-error">Error saving settings: %s</div>`, err.Error())))
+		_, _ = w.Write([]byte(fmt.Sprintf(`<div class="alert alert-error">Error saving settings: %s</div>`, template.HTMLEscapeString(err.Error()))))
 		return
 	}
 
@@ -1224,9 +1616,9 @@ func (s *Server) handleSettingsPushover(w http.ResponseWriter, r *http.Request)
 		Sound:    sound,
 	}
 
-	if err := s.st.UpdatePushoverSettings(settings); err != nil {
+	if err := s.changes(r).UpdatePushoverSettings(settings); err != nil {
 		w.WriteHeader(500)
-		_, _ = w.Write([]byte(fmt.Sprintf(`<div class="alert alert-error">Error saving settings: %s</div>`, err.Error())))
+		_, _ = w.Write([]byte(fmt.Sprintf(`<div class="alert alert-error">Error saving settings: %s</div>`, template.HTMLEscapeString(err.Error()))))
 		return
 	}
 
@@ -1264,7 +1656,7 @@ func (s *Server) handleTestPushover(w http.ResponseWriter, r *http.Request) {
 	// Create a temporary test with the provided credentials
 	if err := testPushoverWithSettings(apiToken, userKey); err != nil {
 		w.WriteHeader(500)
-		_, _ = w.Write([]byte(fmt.Sprintf(`<div class="alert alert-error">Test failed: %s</div>`, err.Error())))
+		_, _ = w.Write([]byte(fmt.Sprintf(`<div class="alert alert-error">Test failed: %s</div>`, template.HTMLEscapeString(err.Error()))))
 		return
 	}
 
@@ -1291,9 +1683,9 @@ func (s *Server) handleSettingsTelegram(w http.ResponseWriter, r *http.Request)
 		Silent:         silent,
 	}
 
-	if err := s.st.UpdateTelegramSettings(settings); err != nil {
+	if err := s.changes(r).UpdateTelegramSettings(settings); err != nil {
 		w.WriteHeader(500)
-		_, _ = w.Write([]byte(fmt.Sprintf(`<div class="alert alert-error">Error saving settings: %s</div>`, err.Error())))
+		_, _ = w.Write([]byte(fmt.Sprintf(`<div class="alert alert-error">Error saving settings: %s</div>`, template.HTMLEscapeString(err.Error()))))
 		return
 	}
 
@@ -1337,13 +1729,104 @@ func (s *Server) handleTestTelegram(w http.ResponseWriter, r *http.Request) {
 
 	if err := testTelegramWithSettings(tempSettings); err != nil {
 		w.WriteHeader(500)
-		_, _ = w.Write([]byte(fmt.Sprintf(`<div class="alert alert-error">Test failed: %s</div>`, err.Error())))
+		_, _ = w.Write([]byte(fmt.Sprintf(`<div class="alert alert-error">Test failed: %s</div>`, template.HTMLEscapeString(err.Error()))))
 		return
 	}
 
 	_, _ = w.Write([]byte(`<div class="alert alert-success">Test notification sent! Check your Telegram.</div>`))
 }
 
+// handleTestShoutrrr sends a test notification to the Shoutrrr URLs with
+// the posted label. URLs live in the config file, so unlike the other
+// channels there are no unsaved values to test.
+func (s *Server) handleTestShoutrrr(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.WriteHeader(405)
+		return
+	}
+	label := r.FormValue("label")
+	if err := s.st.TestShoutrrr(label); err != nil {
+		w.WriteHeader(500)
+		_, _ = w.Write([]byte(fmt.Sprintf(`<div class="alert alert-error">Test failed: %s</div>`, template.HTMLEscapeString(err.Error()))))
+		return
+	}
+	_, _ = w.Write([]byte(fmt.Sprintf(`<div class="alert alert-success">Test notification sent to %s!</div>`, template.HTMLEscapeString(label))))
+}
+
+func (s *Server) handleSettingsTwilio(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.WriteHeader(405)
+		return
+	}
+
+	settings, err := twilioSettingsFromForm(r)
+	if err != nil {
+		w.WriteHeader(422)
+		_, _ = w.Write([]byte(fmt.Sprintf(`<div class="alert alert-error">%s</div>`, template.HTMLEscapeString(err.Error()))))
+		return
+	}
+	if err := s.changes(r).UpdateTwilioSettings(settings); err != nil {
+		w.WriteHeader(500)
+		_, _ = w.Write([]byte(fmt.Sprintf(`<div class="alert alert-error">Error saving settings: %s</div>`, template.HTMLEscapeString(err.Error()))))
+		return
+	}
+
+	_, _ = w.Write([]byte(`<div class="alert alert-success">SMS settings saved successfully.</div>`))
+}
+
+func (s *Server) handleTestTwilio(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.WriteHeader(405)
+		return
+	}
+
+	// Test the values in the form, so they can be tried before saving
+	settings, err := twilioSettingsFromForm(r)
+	if err != nil {
+		w.WriteHeader(422)
+		_, _ = w.Write([]byte(fmt.Sprintf(`<div class="alert alert-error">%s</div>`, template.HTMLEscapeString(err.Error()))))
+		return
+	}
+	if settings.AuthToken == "" {
+		settings.AuthToken = s.st.GetTwilioSettings().AuthToken
+	}
+	if err := twilio.NewClient(settings).TestNotification(); err != nil {
+		w.WriteHeader(500)
+		_, _ = w.Write([]byte(fmt.Sprintf(`<div class="alert alert-error">Test failed: %s</div>`, template.HTMLEscapeString(err.Error()))))
+		return
+	}
+
+	_, _ = w.Write([]byte(`<div class="alert alert-success">Test message sent! Check your phone.</div>`))
+}
+
+// twilioSettingsFromForm reads and validates the SMS settings form
+func twilioSettingsFromForm(r *http.Request) (config.TwilioSettings, error) {
+	settings := config.TwilioSettings{
+		Enabled:    r.FormValue("twilio_enabled") == "true",
+		AccountSID: strings.TrimSpace(r.FormValue("twilio_account_sid")),
+		AuthToken:  strings.TrimSpace(r.FormValue("twilio_auth_token")),
+		From:       strings.TrimSpace(r.FormValue("twilio_from")),
+		CallAfter:  strings.TrimSpace(r.FormValue("twilio_call_after")),
+	}
+	if settings.From != "" {
+		if err := validate.PhoneNumber(settings.From); err != nil {
+			return settings, fmt.Errorf("From number %v", err)
+		}
+	}
+	to, err := validate.PhoneNumbers(r.FormValue("twilio_to"))
+	if err != nil {
+		return settings, fmt.Errorf("To numbers: %v", err)
+	}
+	settings.To = to
+	if settings.CallAfter != "" {
+		d, err := time.ParseDuration(settings.CallAfter)
+		if err != nil || d < time.Minute {
+			return settings, fmt.Errorf("Call after must be a duration of at least 1m, e.g. 15m.")
+		}
+	}
+	return settings, nil
+}
+
 // testTelegramWithSettings sends a test notification using the provided settings
 func testTelegramWithSettings(settings config.TelegramSettings) error {
 	// Use simple text without MarkdownV2 escaping for test message
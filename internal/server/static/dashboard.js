@@ -0,0 +1,155 @@
+// Update stats after hosts are loaded
+document.body.addEventListener('htmx:afterSwap', function(evt) {
+  if (evt.detail.target.id === 'hosts') {
+    const upCount = document.querySelectorAll('.status-up').length;
+    const downCount = document.querySelectorAll('.status-down').length;
+    const statsUp = document.getElementById('stats-up');
+    const statsDown = document.getElementById('stats-down');
+    if (statsUp) statsUp.textContent = upCount;
+    if (statsDown) statsDown.textContent = downCount;
+  }
+});
+
+// Show the add-host dialog's list of checks once one is added
+document.body.addEventListener('htmx:afterSwap', function(evt) {
+  if (evt.detail.target.id === 'added-checks') {
+    const container = document.getElementById('added-checks');
+    if (container && container.children.length > 0) {
+      container.style.display = 'block';
+    }
+  }
+});
+
+// Keep the search and sort in the address bar so a reload or bookmark keeps them
+document.getElementById('host-filters').addEventListener('htmx:afterRequest', function() {
+  const params = new URLSearchParams();
+  new FormData(document.getElementById('host-filters')).forEach(function(value, key) {
+    if (value) params.set(key, value);
+  });
+  const query = params.toString();
+  history.replaceState(null, '', query ? '/?' + query : '/');
+});
+
+// Command palette: "/" or Ctrl/Cmd+K opens it, arrows pick, Enter runs, Esc closes
+(function() {
+  const palette = document.getElementById('command-palette');
+  const input = document.getElementById('command-palette-input');
+  const list = document.getElementById('command-palette-list');
+  let matches = [];
+  let active = 0;
+
+  function post(url, values, target) {
+    htmx.ajax('POST', url, {values: values, target: target || '#hosts', swap: 'innerHTML'});
+  }
+
+  // Runs in the background; results show on the next refresh of #hosts
+  function runNow(host) {
+    fetch('/run-now', {method: 'POST', body: new URLSearchParams(host ? {host: host} : {})});
+  }
+
+  // Commands are rebuilt on open so they follow the hosts currently shown
+  function commands() {
+    const cmds = [
+      {label: 'Add host', run: function() { htmx.ajax('GET', '/addhost-form', {target: '#modal', swap: 'innerHTML'}); }},
+      {label: 'Bulk edit checks', run: function() { htmx.ajax('GET', '/bulkedit-form', {target: '#modal', swap: 'innerHTML', source: '#host-filters'}); }},
+      {label: 'Run all checks now', run: function() { runNow(); }},
+      {label: 'Silence all hosts', run: function() { post('/silence-all', {}); }},
+      {label: 'Enable all hosts', run: function() { post('/enable-all', {}); }},
+      {label: 'Pause monitoring', run: function() { post('/pause', {paused: 'true'}, '#pause-control'); }},
+      {label: 'Resume monitoring', run: function() { post('/pause', {paused: 'false'}, '#pause-control'); }},
+      {label: 'Open analytics', run: function() { window.location = '/analytics'; }},
+      {label: 'Open wallboard', run: function() { window.location = '/wallboard'; }},
+      {label: 'Open settings', run: function() { window.location = '/settings'; }}
+    ];
+    document.querySelectorAll('.host-card[data-host]').forEach(function(card) {
+      const host = card.dataset.host;
+      cmds.push(
+        {label: host, hint: 'Go to host', run: function() {
+          const el = document.getElementById(card.id);
+          if (!el) return;
+          el.scrollIntoView({behavior: 'smooth', block: 'center'});
+          el.classList.add('flash');
+          setTimeout(function() { el.classList.remove('flash'); }, 1500);
+        }},
+        {label: 'Run checks on ' + host, run: function() { runNow(host); }},
+        {label: 'Silence ' + host, run: function() { post('/toggle-host', {host: host, enabled: 'false'}); }},
+        {label: 'Enable ' + host, run: function() { post('/toggle-host', {host: host, enabled: 'true'}); }},
+        {label: 'Edit ' + host, run: function() { htmx.ajax('GET', '/edithost-form?host=' + encodeURIComponent(host), {target: '#modal', swap: 'innerHTML'}); }}
+      );
+    });
+    return cmds;
+  }
+
+  function render() {
+    const words = input.value.toLowerCase().split(/\s+/).filter(Boolean);
+    matches = commands().filter(function(c) {
+      const text = (c.label + ' ' + (c.hint || '')).toLowerCase();
+      return words.every(function(w) { return text.includes(w); });
+    });
+    active = Math.min(active, Math.max(matches.length - 1, 0));
+    list.replaceChildren();
+    if (matches.length === 0) {
+      const li = document.createElement('li');
+      li.className = 'command-palette-empty';
+      li.textContent = 'No matching hosts or commands';
+      list.appendChild(li);
+      return;
+    }
+    matches.forEach(function(c, i) {
+      const li = document.createElement('li');
+      li.className = 'command-palette-item' + (i === active ? ' active' : '');
+      li.textContent = c.label;
+      if (c.hint) {
+        const hint = document.createElement('small');
+        hint.textContent = c.hint;
+        li.appendChild(hint);
+      }
+      li.addEventListener('mousedown', function(evt) { evt.preventDefault(); choose(i); });
+      list.appendChild(li);
+    });
+    list.children[active].scrollIntoView({block: 'nearest'});
+  }
+
+  function open() {
+    palette.hidden = false;
+    input.value = '';
+    active = 0;
+    render();
+    input.focus();
+  }
+
+  function close() {
+    palette.hidden = true;
+    input.blur();
+  }
+
+  function choose(i) {
+    const cmd = matches[i];
+    close();
+    if (cmd) cmd.run();
+  }
+
+  document.addEventListener('keydown', function(evt) {
+    if (!palette.hidden) return;
+    const typing = evt.target.closest('input, textarea, select, [contenteditable]');
+    if ((evt.key === '/' && !typing) || (evt.key === 'k' && (evt.ctrlKey || evt.metaKey))) {
+      evt.preventDefault();
+      open();
+    }
+  });
+  input.addEventListener('input', function() { active = 0; render(); });
+  input.addEventListener('keydown', function(evt) {
+    if (evt.key === 'Escape') {
+      close();
+    } else if (evt.key === 'ArrowDown' || evt.key === 'ArrowUp') {
+      evt.preventDefault();
+      const step = evt.key === 'ArrowDown' ? 1 : -1;
+      active = (active + step + matches.length) % Math.max(matches.length, 1);
+      render();
+    } else if (evt.key === 'Enter') {
+      evt.preventDefault();
+      choose(active);
+    }
+  });
+  input.addEventListener('blur', close);
+})();
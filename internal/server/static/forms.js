@@ -0,0 +1,8 @@
+// Validation failures come back as 422 and stale previews as 409, each with
+// an error fragment; let htmx swap it
+document.body.addEventListener('htmx:beforeSwap', function(evt) {
+  if (evt.detail.xhr.status === 422 || evt.detail.xhr.status === 409) {
+    evt.detail.shouldSwap = true;
+    evt.detail.isError = false;
+  }
+});
@@ -0,0 +1,27 @@
+// Re-render <time data-layout> elements (and chart labels) in the display
+// timezone, or the browser's own zone if none is configured. The layouts
+// mirror timeLayouts in server.go.
+(function() {
+  var zone = document.currentScript.dataset.timezone || Intl.DateTimeFormat().resolvedOptions().timeZone;
+  var layouts = {
+    time: {hour: '2-digit', minute: '2-digit', second: '2-digit'},
+    hm: {hour: '2-digit', minute: '2-digit'},
+    datetime: {month: 'short', day: 'numeric', hour: '2-digit', minute: '2-digit', second: '2-digit'},
+    datehm: {month: 'short', day: 'numeric', hour: '2-digit', minute: '2-digit'},
+    date: {year: 'numeric', month: 'short', day: 'numeric'}
+  };
+  htmx.onLoad(function(root) {
+    root.querySelectorAll('[data-layout]').forEach(function(el) {
+      var when = new Date(el.getAttribute('datetime') || el.getAttribute('data-datetime'));
+      var layout = layouts[el.getAttribute('data-layout')];
+      if (isNaN(when) || !layout) return;
+      el.textContent = when.toLocaleString(undefined, Object.assign({timeZone: zone, hourCycle: 'h23'}, layout));
+      if (el.hasAttribute('title')) {
+        el.setAttribute('title', when.toLocaleString(undefined, {timeZone: zone, dateStyle: 'medium', timeStyle: 'long'}) + ' (' + zone + ')');
+      }
+    });
+    root.querySelectorAll('[data-tz-label]').forEach(function(el) {
+      el.textContent = zone;
+    });
+  });
+})();
@@ -0,0 +1,15 @@
+// Keep the tab title and favicon in step with the latest status_signal.html
+// fragment, so a background tab shows when something is down
+(function() {
+  var base = document.title.replace(/^\(.*?\) /, '');
+  htmx.onLoad(function(root) {
+    var signals = root.querySelectorAll('[data-status-title]');
+    var signal = signals[signals.length - 1];
+    if (!signal) return;
+    document.title = signal.getAttribute('data-status-title') + base;
+    var icon = document.querySelector('link[rel="icon"]');
+    if (icon && icon.getAttribute('href') !== signal.getAttribute('data-status-icon')) {
+      icon.setAttribute('href', signal.getAttribute('data-status-icon'));
+    }
+  });
+})();
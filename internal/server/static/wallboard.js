@@ -0,0 +1,8 @@
+// Double-click toggles full screen; browsers only allow it from a user gesture
+document.addEventListener('dblclick', function() {
+  if (document.fullscreenElement) {
+    document.exitFullscreen();
+  } else if (document.documentElement.requestFullscreen) {
+    document.documentElement.requestFullscreen();
+  }
+});
@@ -0,0 +1,33 @@
+package server
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/andrewsjg/simple-healthchecker/copilot/internal/state"
+)
+
+// tlsView is the data for tls_modal.html
+type tlsView struct {
+	Host     string
+	Check    state.CheckStatus
+	Warnings []string
+	Now      time.Time
+}
+
+// handleTLSDetails opens the panel showing the certificate chain and
+// connection of an https or wss check
+func (s *Server) handleTLSDetails(w http.ResponseWriter, r *http.Request) {
+	host := r.FormValue("host")
+	idx, _ := strconv.Atoi(r.FormValue("idx"))
+	hs, ok := s.st.GetHost(host)
+	if !ok || idx < 0 || idx >= len(hs.Checks) || hs.Checks[idx].TLS == nil {
+		w.WriteHeader(404)
+		_, _ = w.Write([]byte("no TLS details for this check"))
+		return
+	}
+	c := hs.Checks[idx]
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	_ = s.tpl.ExecuteTemplate(w, "tls_modal.html", tlsView{Host: host, Check: c, Warnings: c.TLSWarnings(), Now: time.Now()})
+}
@@ -0,0 +1,167 @@
+package server
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/andrewsjg/simple-healthchecker/copilot/internal/checks"
+	"github.com/andrewsjg/simple-healthchecker/copilot/internal/state"
+)
+
+// trendRange is a span the trends panel offers
+type trendRange struct {
+	Days  int
+	Label string
+}
+
+var trendRanges = []trendRange{{7, "Week"}, {30, "Month"}, {90, "Quarter"}, {365, "Year"}}
+
+// defaultTrendDays is the span the trends panel opens with
+const defaultTrendDays = 30
+
+// trendsView is the data for trends.html
+type trendsView struct {
+	Host   string
+	Idx    int
+	Days   int
+	Ranges []trendRange
+	Trend  state.Trend
+
+	// Periods the comparison form starts with: the last week and the week
+	// before, as YYYY-MM-DD
+	BeforeFrom, BeforeTo, AfterFrom, AfterTo string
+}
+
+// handleTrends renders a check's daily uptime and latency over the chosen
+// span, for the trends panel on the analytics page
+func (s *Server) handleTrends(w http.ResponseWriter, r *http.Request) {
+	host := r.FormValue("host")
+	idx, _ := strconv.Atoi(r.FormValue("idx"))
+	days, _ := strconv.Atoi(r.FormValue("days"))
+	if !slices.ContainsFunc(trendRanges, func(tr trendRange) bool { return tr.Days == days }) {
+		days = defaultTrendDays
+	}
+	trend, ok := s.st.CheckTrend(host, idx, days)
+	if !ok {
+		w.WriteHeader(404)
+		return
+	}
+	today := time.Now().In(s.st.DisplayLocation())
+	day := func(ago int) string { return today.AddDate(0, 0, -ago).Format("2006-01-02") }
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	_ = s.tpl.ExecuteTemplate(w, "trends.html", trendsView{
+		Host: host, Idx: idx, Days: days, Ranges: trendRanges, Trend: trend,
+		BeforeFrom: day(13), BeforeTo: day(7), AfterFrom: day(6), AfterTo: day(0),
+	})
+}
+
+// compareRow is one measure in the before/after comparison. Better and
+// Worse colour the change; neither is set when it is too small to matter.
+type compareRow struct {
+	Label                 string
+	Before, After, Change string
+	Better, Worse         bool
+}
+
+// compareView is the data for trends_compare.html
+type compareView struct {
+	Before, After state.Trend
+	Rows          []compareRow
+}
+
+// handleCompareTrends compares a check's uptime and latency over two spans
+// of days, e.g. before and after an ISP change
+func (s *Server) handleCompareTrends(w http.ResponseWriter, r *http.Request) {
+	host := r.FormValue("host")
+	idx, _ := strconv.Atoi(r.FormValue("idx"))
+	loc := s.st.DisplayLocation()
+	var spans [2][2]time.Time
+	for i, prefix := range []string{"before", "after"} {
+		for j, suffix := range []string{"from", "to"} {
+			t, err := time.ParseInLocation("2006-01-02", r.FormValue(prefix+"_"+suffix), loc)
+			if err != nil {
+				w.WriteHeader(422)
+				_, _ = w.Write([]byte(`<div class="alert alert-error">Choose the first and last day of both periods.</div>`))
+				return
+			}
+			spans[i][j] = t
+		}
+		if spans[i][1].Before(spans[i][0]) {
+			w.WriteHeader(422)
+			_, _ = w.Write([]byte(fmt.Sprintf(`<div class="alert alert-error">The %s period ends before it starts.</div>`, prefix)))
+			return
+		}
+	}
+	before, ok := s.st.CheckTrendBetween(host, idx, spans[0][0], spans[0][1])
+	after, ok2 := s.st.CheckTrendBetween(host, idx, spans[1][0], spans[1][1])
+	if !ok || !ok2 {
+		w.WriteHeader(404)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	_ = s.tpl.ExecuteTemplate(w, "trends_compare.html", compareView{Before: before, After: after, Rows: compareTrends(before, after)})
+}
+
+// compareTrends lists the measures of before and after with the change
+// between them. Measures missing from either side show no change.
+func compareTrends(before, after state.Trend) []compareRow {
+	rows := []compareRow{{
+		Label:  "Days with data",
+		Before: strconv.Itoa(before.ActiveDays()),
+		After:  strconv.Itoa(after.ActiveDays()),
+	}, {
+		Label:  "Runs",
+		Before: strconv.FormatInt(before.Runs, 10),
+		After:  strconv.FormatInt(after.Runs, 10),
+	}}
+
+	uptime := compareRow{Label: "Uptime", Before: "—", After: "—"}
+	if before.Runs > 0 {
+		uptime.Before = formatUptime(before.Uptime())
+	}
+	if after.Runs > 0 {
+		uptime.After = formatUptime(after.Uptime())
+	}
+	if before.Runs > 0 && after.Runs > 0 {
+		d := after.Uptime() - before.Uptime()
+		uptime.Change = fmt.Sprintf("%+.2f pts", d)
+		uptime.Better, uptime.Worse = d >= 0.01, d <= -0.01
+	}
+	rows = append(rows, uptime)
+
+	for _, m := range []struct {
+		label         string
+		before, after time.Duration
+	}{
+		{"Median latency", before.Median, after.Median},
+		{"P95 latency", before.P95, after.P95},
+		{"Max latency", before.Max, after.Max},
+	} {
+		row := compareRow{Label: m.label, Before: "—", After: "—"}
+		if m.before > 0 {
+			row.Before = checks.FormatLatency(m.before)
+		}
+		if m.after > 0 {
+			row.After = checks.FormatLatency(m.after)
+		}
+		if m.before > 0 && m.after > 0 && m.after == m.before {
+			row.Change = "no change"
+		} else if m.before > 0 && m.after > 0 {
+			d := m.after - m.before
+			pct := float64(d) / float64(m.before) * 100
+			sign := "+"
+			if d < 0 {
+				sign = "−"
+			}
+			row.Change = fmt.Sprintf("%s%s (%s%.0f%%)", sign, checks.FormatLatency(d.Abs()), sign, math.Abs(pct))
+			// Lower latency is better; changes under 5% are noise
+			row.Better, row.Worse = pct <= -5, pct >= 5
+		}
+		rows = append(rows, row)
+	}
+	return rows
+}
@@ -0,0 +1,93 @@
+package server
+
+import (
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/andrewsjg/simple-healthchecker/copilot/internal/state"
+)
+
+const (
+	wallboardPerPage = 12 // Tiles per page unless ?per_page= says otherwise
+	wallboardRotate  = 10 // Seconds per page unless ?rotate= says otherwise
+)
+
+// wallboardTile is one host on the wallboard
+type wallboardTile struct {
+	Host    *state.HostStatus
+	Status  string              // One of the state.Host* statuses
+	Failing []state.CheckStatus // Enabled checks that are down or blocked
+}
+
+// wallboardPage is the data for wallboard_tiles.html. The tiles element
+// reloads itself with Next every Rotate seconds, which both refreshes the
+// results and steps through the pages.
+type wallboardPage struct {
+	Tiles   []wallboardTile
+	Stats   state.AggregateStats
+	Page    int // 1-based
+	Pages   int
+	Rotate  int    // Seconds until the next page
+	Next    string // URL of the next page's tiles
+	Updated time.Time
+}
+
+// wallboard reads the wallboard's options from r: the usual q and status
+// filters, sort (worst status first by default), per_page, rotate and page
+func (s *Server) wallboard(r *http.Request) wallboardPage {
+	q := state.HostQuery{Search: r.FormValue("q"), Status: r.FormValue("status"), Sort: r.FormValue("sort"), Tags: s.visibleTags(r)}
+	if q.Sort == "" {
+		q.Sort = "status"
+	}
+	perPage := formInt(r, "per_page", wallboardPerPage, 1, 100)
+	rotate := formInt(r, "rotate", wallboardRotate, 3, 3600)
+	hosts := s.st.QueryHosts(q)
+
+	pages := max(1, (len(hosts)+perPage-1)/perPage)
+	page := formInt(r, "page", 1, 1, pages)
+	start := (page - 1) * perPage
+	end := min(start+perPage, len(hosts))
+
+	p := wallboardPage{Stats: s.st.GetAggregateStatsFor(q.Tags), Page: page, Pages: pages, Rotate: rotate, Updated: time.Now()}
+	for _, hs := range hosts[start:end] {
+		tile := wallboardTile{Host: hs, Status: hs.Status()}
+		for _, c := range hs.Checks {
+			if c.Enabled && !c.OffSchedule && !c.ExpectedDown() && !c.Pending() && !c.OK {
+				tile.Failing = append(tile.Failing, c)
+			}
+		}
+		p.Tiles = append(p.Tiles, tile)
+	}
+
+	next := url.Values{}
+	for key, vals := range r.URL.Query() {
+		next[key] = vals
+	}
+	next.Set("page", strconv.Itoa(page%pages+1))
+	p.Next = "/wallboard/tiles?" + next.Encode()
+	return p
+}
+
+// formInt reads an integer form value, using def if it is missing or
+// invalid and clamping it to [lo, hi]
+func formInt(r *http.Request, name string, def, lo, hi int) int {
+	n, err := strconv.Atoi(r.FormValue(name))
+	if err != nil {
+		return def
+	}
+	return min(max(n, lo), hi)
+}
+
+// handleWallboard renders the full-screen wallboard page
+func (s *Server) handleWallboard(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	_ = s.tpl.ExecuteTemplate(w, "wallboard.html", s.wallboard(r))
+}
+
+// handleWallboardTiles renders one page of wallboard tiles
+func (s *Server) handleWallboardTiles(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	_ = s.tpl.ExecuteTemplate(w, "wallboard_tiles.html", s.wallboard(r))
+}
@@ -0,0 +1,92 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/andrewsjg/simple-healthchecker/copilot/internal/state"
+)
+
+// webhookPost is the JSON body of a webhook post. Status is a word such as
+// "ok" or "fail", or an exit status, so `status=$?` works from a script.
+type webhookPost struct {
+	Status   json.RawMessage `json:"status"`
+	Message  string          `json:"message"`
+	Duration float64         `json:"duration"` // Seconds the job took
+}
+
+// handleWebhook takes a result for the webhook check named in the path
+// from a CI pipeline, backup script or anything else that can send a POST,
+// as JSON or form values. The token is sent as a bearer token or ?token=.
+func (s *Server) handleWebhook(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		w.WriteHeader(405)
+		return
+	}
+	var post webhookPost
+	status := ""
+	if ct, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type")); ct == "application/json" {
+		if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 64<<10)).Decode(&post); err != nil {
+			http.Error(w, "body is not a JSON object", http.StatusBadRequest)
+			return
+		}
+		if string(post.Status) != "null" {
+			status = strings.Trim(string(post.Status), `"`)
+		}
+	} else {
+		r.Body = http.MaxBytesReader(w, r.Body, 64<<10)
+		status, post.Message = r.FormValue("status"), r.FormValue("message")
+		if v := r.FormValue("duration"); v != "" {
+			d, err := strconv.ParseFloat(v, 64)
+			if err != nil {
+				http.Error(w, "duration must be a number of seconds", http.StatusBadRequest)
+				return
+			}
+			post.Duration = d
+		}
+	}
+	ok, valid := webhookStatus(status)
+	if !valid {
+		http.Error(w, `status must be ok, fail or an exit status`, http.StatusBadRequest)
+		return
+	}
+	token := r.FormValue("token")
+	if h := r.Header.Get("Authorization"); strings.HasPrefix(h, "Bearer ") {
+		token = strings.TrimPrefix(h, "Bearer ")
+	}
+	res := state.WebhookResult{At: time.Now(), OK: ok, Message: strings.TrimSpace(post.Message)}
+	if post.Duration > 0 {
+		res.Latency = time.Duration(post.Duration * float64(time.Second))
+	}
+	switch err := s.st.PostWebhook(r.PathValue("id"), token, s.st.GetAuthSettings().Enabled(), res); {
+	case errors.Is(err, state.ErrWebhookNotFound):
+		http.Error(w, err.Error(), http.StatusNotFound)
+	case errors.Is(err, state.ErrWebhookToken):
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+	case err != nil:
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	default:
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// webhookStatus reads a posted status: none, ok, up, pass, success or 0
+// pass, and fail, down, error, failure or any other number fails
+func webhookStatus(v string) (ok, valid bool) {
+	switch strings.ToLower(strings.TrimSpace(v)) {
+	case "", "ok", "up", "pass", "success", "true", "0":
+		return true, true
+	case "fail", "down", "error", "failure", "false":
+		return false, true
+	}
+	if _, err := strconv.Atoi(v); err == nil {
+		return false, true
+	}
+	return false, false
+}
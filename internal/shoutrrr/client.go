@@ -0,0 +1,251 @@
+// Package shoutrrr sends alerts through containrrr/shoutrrr, which reaches
+// dozens of services (Slack, Discord, Teams, ntfy, Gotify, email...) from a
+// single URL each.
+package shoutrrr
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/andrewsjg/simple-healthchecker/copilot/internal/config"
+	"github.com/andrewsjg/simple-healthchecker/copilot/internal/notify"
+	"github.com/containrrr/shoutrrr/pkg/router"
+	"github.com/containrrr/shoutrrr/pkg/types"
+)
+
+// AlertMessage represents a notification to be sent
+type AlertMessage struct {
+	Labels     []string // Which configured URLs to send to
+	Host       string
+	Address    string
+	CheckType  string
+	CheckName  string // Display name, if the check has one
+	CheckID    string
+	Status     string // "up", "down"
+	Message    string
+	Latency    time.Duration
+	Notes      string                // What the check covers
+	Runbook    string                // URL of the runbook, if any
+	Severity   string                // "info", "warning" or "critical"; empty means warning
+	Outage     *notify.OutageSummary // Set on recovery and reminders
+	Reminder   bool                  // Still-down reminder rather than a state change
+	Time       time.Time             // When the state changed; used to timestamp digest lines
+	Blocked    []string              // Dependent checks blocked by this failure
+	HostStatus string                // The host's rollup status after the change, e.g. "degraded"
+}
+
+// Client sends notifications to the configured Shoutrrr URLs. URLs sharing
+// a label form one sender, so a single label can fan out to many services.
+type Client struct {
+	enabled bool
+	labels  []string // In config order
+	senders map[string]*router.ServiceRouter
+}
+
+// NewClient creates a client for settings. URLs that Shoutrrr can't parse
+// are skipped; Validate reports them.
+func NewClient(settings config.ShoutrrrSettings) *Client {
+	c := &Client{enabled: settings.Enabled, senders: make(map[string]*router.ServiceRouter)}
+	for _, u := range settings.URLs {
+		if u.Label == "" || u.URL == "" {
+			continue
+		}
+		sender, ok := c.senders[u.Label]
+		if !ok {
+			// New can't fail without URLs
+			sender, _ = router.New(nil)
+		}
+		if err := sender.AddService(u.URL); err != nil {
+			continue
+		}
+		if !ok {
+			c.senders[u.Label] = sender
+			c.labels = append(c.labels, u.Label)
+		}
+	}
+	return c
+}
+
+// Validate reports URLs without a label or that Shoutrrr can't parse
+func Validate(settings config.ShoutrrrSettings) error {
+	var errs []error
+	for i, u := range settings.URLs {
+		switch {
+		case u.Label == "":
+			errs = append(errs, fmt.Errorf("shoutrrr url %d has no label", i+1))
+		case u.URL == "":
+			errs = append(errs, fmt.Errorf("shoutrrr url %q is empty", u.Label))
+		default:
+			if _, err := router.New(nil, u.URL); err != nil {
+				// Errors can quote the URL, and with it any credentials, so
+				// name the service only
+				scheme, _, _ := strings.Cut(u.URL, "://")
+				errs = append(errs, fmt.Errorf("shoutrrr url %q: invalid %s URL", u.Label, scheme))
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// IsEnabled returns whether Shoutrrr notifications are enabled
+func (c *Client) IsEnabled() bool {
+	return c.enabled && len(c.senders) > 0
+}
+
+// Labels returns the configured labels in config order
+func (c *Client) Labels() []string {
+	return c.labels
+}
+
+// HasLabel reports whether label names any configured URL
+func (c *Client) HasLabel(label string) bool {
+	_, ok := c.senders[label]
+	return ok
+}
+
+// SendAlert sends a notification for one check to msg.Labels
+func (c *Client) SendAlert(msg AlertMessage) error {
+	if !c.IsEnabled() {
+		return nil
+	}
+
+	var title string
+	switch {
+	case msg.Status == "up":
+		title = fmt.Sprintf("✅ %s is UP", msg.Host)
+	case msg.Reminder:
+		title = fmt.Sprintf("⏰ %s is still DOWN", msg.Host)
+	case msg.Severity == "critical":
+		title = fmt.Sprintf("🚨 %s is DOWN (critical)", msg.Host)
+	case msg.HostStatus == "degraded":
+		title = fmt.Sprintf("🟠 %s is DEGRADED", msg.Host)
+	default:
+		title = fmt.Sprintf("🔴 %s is DOWN", msg.Host)
+	}
+
+	// Not every service shows the title, so the body repeats it
+	body := title + "\n\n"
+	body += fmt.Sprintf("Host: %s (%s)\nCheck: %s", msg.Host, msg.Address, notify.CheckTitle(msg.CheckName, msg.CheckType))
+	if msg.CheckID != "" {
+		body += fmt.Sprintf(" [%s]", msg.CheckID)
+	}
+	if msg.Message != "" {
+		body += fmt.Sprintf("\nDetails: %s", msg.Message)
+	}
+	if msg.HostStatus != "" {
+		body += fmt.Sprintf("\nHost status: %s", msg.HostStatus)
+	}
+	if msg.Status == "up" && msg.Latency > 0 {
+		body += fmt.Sprintf("\nLatency: %v", msg.Latency)
+	}
+	if o := msg.Outage; o != nil {
+		body += fmt.Sprintf("\nOutage: %s", o.Downtime.Round(time.Second))
+		if !o.Start.IsZero() {
+			body += fmt.Sprintf(" since %s", o.Start.Local().Format("Jan 2 15:04"))
+		}
+		body += fmt.Sprintf("\nFailed probes: %d\nUptime: %.2f%%", o.FailedProbes, o.UptimePct)
+	}
+	if len(msg.Blocked) > 0 {
+		body += fmt.Sprintf("\nBlocked: %s", notify.BlockedSummary(msg.Blocked))
+	}
+	if msg.Notes != "" {
+		body += fmt.Sprintf("\nNotes: %s", msg.Notes)
+	}
+	if msg.Runbook != "" {
+		body += fmt.Sprintf("\nRunbook: %s", msg.Runbook)
+	}
+
+	if err := c.send(msg.Labels, title, body); err != nil {
+		return err
+	}
+	log.Printf("Shoutrrr notification sent: %s - %s", msg.Host, msg.Status)
+	return nil
+}
+
+// SendDigest sends several alerts as one message per label, one line per
+// alert. Each label gets only the alerts addressed to it.
+func (c *Client) SendDigest(title string, msgs []AlertMessage) error {
+	if !c.IsEnabled() || len(msgs) == 0 {
+		return nil
+	}
+
+	lines := make(map[string][]string)
+	var order []string
+	for _, msg := range msgs {
+		icon := "🔴"
+		if msg.Status == "up" {
+			icon = "✅"
+		}
+		line := fmt.Sprintf("%s %s %s %s", icon, msg.Time.Local().Format("15:04"), msg.Host, notify.CheckTitle(msg.CheckName, msg.CheckType))
+		if msg.CheckID != "" {
+			line += fmt.Sprintf(" [%s]", msg.CheckID)
+		}
+		if msg.Message != "" {
+			line += " - " + msg.Message
+		}
+		if n := len(msg.Blocked); n > 0 {
+			line += fmt.Sprintf(" (%d dependent checks blocked)", n)
+		}
+		for _, label := range msg.Labels {
+			if _, ok := lines[label]; !ok {
+				order = append(order, label)
+			}
+			lines[label] = append(lines[label], line)
+		}
+	}
+
+	var errs []error
+	for _, label := range order {
+		body := title + "\n\n" + strings.Join(lines[label], "\n")
+		errs = append(errs, c.send([]string{label}, title, body))
+	}
+	if err := errors.Join(errs...); err != nil {
+		return err
+	}
+	log.Printf("Shoutrrr digest sent: %d alerts", len(msgs))
+	return nil
+}
+
+// SendNotice sends a plain message that isn't about a single check
+func (c *Client) SendNotice(labels []string, title, message string) error {
+	if !c.IsEnabled() {
+		return nil
+	}
+	if err := c.send(labels, title, title+"\n\n"+message); err != nil {
+		return err
+	}
+	log.Printf("Shoutrrr notice sent: %s", title)
+	return nil
+}
+
+// TestNotification sends a test message to every URL with label
+func (c *Client) TestNotification(label string) error {
+	if !c.HasLabel(label) {
+		return fmt.Errorf("no shoutrrr urls labelled %q", label)
+	}
+	title := "✅ POKE443 Test Notification"
+	return c.send([]string{label}, title, title+"\n\nThis is a test notification from POKE443. If you see this, Shoutrrr is configured correctly!")
+}
+
+// send delivers body to each distinct label's URLs. Unknown labels are
+// skipped; the state package warns about them at startup.
+func (c *Client) send(labels []string, title, body string) error {
+	seen := make(map[string]bool)
+	var errs []error
+	for _, label := range labels {
+		sender, ok := c.senders[label]
+		if !ok || seen[label] {
+			continue
+		}
+		seen[label] = true
+		for _, err := range sender.Send(body, &types.Params{"title": title}) {
+			if err != nil {
+				errs = append(errs, fmt.Errorf("shoutrrr %q: %w", label, err))
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
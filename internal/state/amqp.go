@@ -0,0 +1,108 @@
+package state
+
+import (
+	"fmt"
+
+	"github.com/andrewsjg/simple-healthchecker/copilot/internal/checks"
+	"github.com/andrewsjg/simple-healthchecker/copilot/internal/config"
+)
+
+// amqpOptionsFromConfig extracts an amqp check's connection and login
+func amqpOptionsFromConfig(c config.Check) checks.AMQPOptions {
+	return checks.AMQPOptions{
+		VHost:              c.VHost,
+		User:               c.Username,
+		Password:           c.Password,
+		Port:               c.Port,
+		TLS:                c.TLS,
+		InsecureSkipVerify: c.InsecureSkipVerify,
+	}
+}
+
+// setCfgAMQPOptions copies an amqp check's connection and login into its config
+func setCfgAMQPOptions(c *config.Check, opts checks.AMQPOptions) {
+	c.VHost = opts.VHost
+	c.Username = opts.User
+	c.Password = opts.Password
+	c.Port = opts.Port
+	c.TLS = opts.TLS
+	c.InsecureSkipVerify = opts.InsecureSkipVerify
+}
+
+// amqpMessage describes an amqp check's result, e.g. "RabbitMQ 3.13.1",
+// and the vhost it opened when it logged in
+func amqpMessage(res checks.AMQPResult, opts checks.AMQPOptions) string {
+	if res.Err != nil {
+		return res.Err.Error()
+	}
+	msg := res.Product
+	if msg == "" {
+		msg = "AMQP broker"
+	}
+	if opts.User != "" {
+		vhost := opts.VHost
+		if vhost == "" {
+			vhost = "/"
+		}
+		msg += fmt.Sprintf(", vhost %s open as %s", vhost, opts.User)
+	}
+	return msg
+}
+
+// AddAMQPCheck appends a message broker check to the named host
+func (s *State) AddAMQPCheck(hostName string, opts checks.AMQPOptions, id, dependsOn string, mqttNotify, pushoverNotify, telegramNotify bool) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	hs, ok := s.hosts[hostName]
+	if !ok {
+		return fmt.Errorf("host not found")
+	}
+	if opts.Password != "" && opts.User == "" {
+		return fmt.Errorf("a password needs a username")
+	}
+	if s.checkIDInUseLocked(id, "") {
+		return fmt.Errorf("check id %q already in use", id)
+	}
+	c := config.Check{Type: config.CheckAMQP, Enabled: true, ID: id, DependsOn: dependsOn, MQTTNotify: mqttNotify, PushoverNotify: pushoverNotify, TelegramNotify: telegramNotify}
+	setCfgAMQPOptions(&c, opts)
+	hs.Checks = append(hs.Checks, CheckStatus{Type: config.CheckAMQP, Enabled: true, AMQPOpts: opts, ID: id, DependsOn: dependsOn, MQTTNotify: mqttNotify, PushoverNotify: pushoverNotify, TelegramNotify: telegramNotify, Severity: config.SeverityWarning})
+	for i := range s.cfg.Hosts {
+		if s.cfg.Hosts[i].Name == hostName {
+			s.cfg.Hosts[i].Checks = append(s.cfg.Hosts[i].Checks, c)
+			break
+		}
+	}
+	s.rebuildCheckIndex()
+	return s.saveConfigLocked()
+}
+
+// SetCheckAMQP updates the connection and login of the amqp check at idx.
+// An empty password with a username keeps the current one.
+func (s *State) SetCheckAMQP(hostName string, idx int, opts checks.AMQPOptions) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	hs, ok := s.hosts[hostName]
+	if !ok {
+		return fmt.Errorf("host not found")
+	}
+	if idx < 0 || idx >= len(hs.Checks) {
+		return fmt.Errorf("bad index")
+	}
+	c := &hs.Checks[idx]
+	if c.Type != config.CheckAMQP {
+		return fmt.Errorf("not amqp check")
+	}
+	if opts.Password == "" && opts.User != "" {
+		opts.Password = c.AMQPOpts.Password
+	}
+	c.AMQPOpts = opts
+	for i := range s.cfg.Hosts {
+		if s.cfg.Hosts[i].Name == hostName {
+			if idx < len(s.cfg.Hosts[i].Checks) {
+				setCfgAMQPOptions(&s.cfg.Hosts[i].Checks[idx], opts)
+			}
+			break
+		}
+	}
+	return s.saveConfigLocked()
+}
@@ -0,0 +1,92 @@
+package state
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+)
+
+// maxAnnotations caps the notes kept per check
+const maxAnnotations = 100
+
+// Annotation is a note on a span of a check's history, e.g. "ISP
+// maintenance". Annotations are kept with the history, in memory, and
+// dropped once the history they cover has been trimmed.
+type Annotation struct {
+	ID    int
+	Start time.Time
+	End   time.Time // Equal to Start for a single moment
+	Note  string
+}
+
+// Annotate attaches a note to the span from start to end of the check at idx
+func (s *State) Annotate(hostName string, idx int, start, end time.Time, note string) error {
+	note = strings.TrimSpace(note)
+	if note == "" {
+		return fmt.Errorf("note is empty")
+	}
+	if end.IsZero() {
+		end = start
+	}
+	if start.IsZero() || end.Before(start) {
+		return fmt.Errorf("invalid time range")
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	hs, ok := s.hosts[hostName]
+	if !ok {
+		return fmt.Errorf("host not found")
+	}
+	if idx < 0 || idx >= len(hs.Checks) {
+		return fmt.Errorf("bad index")
+	}
+	c := &hs.Checks[idx]
+	s.annotationSeq++
+	// Snapshots share the old slice, so build a new one
+	notes := append(slices.Clone(c.Annotations), Annotation{ID: s.annotationSeq, Start: start.UTC(), End: end.UTC(), Note: note})
+	slices.SortStableFunc(notes, func(a, b Annotation) int { return a.Start.Compare(b.Start) })
+	if len(notes) > maxAnnotations {
+		notes = notes[len(notes)-maxAnnotations:]
+	}
+	c.Annotations = notes
+	return nil
+}
+
+// RemoveAnnotation deletes the note with the given ID from the check at idx
+func (s *State) RemoveAnnotation(hostName string, idx, id int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	hs, ok := s.hosts[hostName]
+	if !ok {
+		return fmt.Errorf("host not found")
+	}
+	if idx < 0 || idx >= len(hs.Checks) {
+		return fmt.Errorf("bad index")
+	}
+	c := &hs.Checks[idx]
+	n := len(c.Annotations)
+	c.Annotations = slices.DeleteFunc(slices.Clone(c.Annotations), func(a Annotation) bool { return a.ID == id })
+	if len(c.Annotations) == n {
+		return fmt.Errorf("annotation not found")
+	}
+	return nil
+}
+
+// pruneAnnotations drops notes that end before the oldest data point
+func (c *CheckStatus) pruneAnnotations() {
+	if len(c.Annotations) == 0 || len(c.FullHistory) == 0 {
+		return
+	}
+	oldest := c.FullHistory[0].Timestamp
+	stale := func(a Annotation) bool { return a.End.Before(oldest) }
+	if slices.ContainsFunc(c.Annotations, stale) {
+		c.Annotations = slices.DeleteFunc(slices.Clone(c.Annotations), stale)
+	}
+}
+
+// OutageStart is when the outage an event reports began: for recoveries,
+// that is the downtime before the event; otherwise the event itself
+func (e Event) OutageStart() time.Time {
+	return e.Timestamp.Add(-e.Duration)
+}
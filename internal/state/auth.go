@@ -0,0 +1,10 @@
+package state
+
+import "github.com/andrewsjg/simple-healthchecker/copilot/internal/config"
+
+// GetAuthSettings returns the current sign-in settings
+func (s *State) GetAuthSettings() config.AuthSettings {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.cfg.Settings.Auth
+}
@@ -0,0 +1,146 @@
+package state
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/andrewsjg/simple-healthchecker/copilot/internal/mqtt"
+	"github.com/andrewsjg/simple-healthchecker/copilot/internal/pushover"
+	"github.com/andrewsjg/simple-healthchecker/copilot/internal/shoutrrr"
+	"github.com/andrewsjg/simple-healthchecker/copilot/internal/telegram"
+	"github.com/andrewsjg/simple-healthchecker/copilot/internal/twilio"
+)
+
+// alertBatch collects the alerts raised during one batching window so a
+// burst of failures (say, a dead switch) goes out as one message per channel
+type alertBatch struct {
+	mqtt     []mqtt.StateChangeMessage
+	pushover []pushover.AlertMessage
+	telegram []telegram.AlertMessage
+	shoutrrr []shoutrrr.AlertMessage
+	sms      []twilio.AlertMessage
+}
+
+// batchLocked returns the open alert batch, starting one and scheduling its
+// flush if needed. It returns nil when batching is off.
+func (s *State) batchLocked() *alertBatch {
+	window := s.cfg.Settings.Alerts.Batch()
+	if window <= 0 {
+		return nil
+	}
+	if s.batch == nil {
+		s.batch = &alertBatch{}
+		time.AfterFunc(window, s.flushBatch)
+	}
+	return s.batch
+}
+
+// flushBatch sends the open batch. A channel with a single alert gets the
+// usual message; more than one are combined.
+func (s *State) flushBatch() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	b := s.batch
+	s.batch = nil
+	if b == nil {
+		return
+	}
+
+	switch len(b.mqtt) {
+	case 0:
+	case 1:
+		if err := s.notifyTraced(ChannelMQTT, "alert", func() error { return s.mqttClient.PublishStateChange(b.mqtt[0]) }); err != nil {
+			log.Printf("MQTT publish error: %v", err)
+		}
+	default:
+		if err := s.notifyTraced(ChannelMQTT, "batch", func() error { return s.mqttClient.PublishBatch(b.mqtt) }); err != nil {
+			log.Printf("MQTT publish error: %v", err)
+		}
+	}
+
+	switch len(b.pushover) {
+	case 0:
+	case 1:
+		if err := s.notifyTraced(ChannelPushover, "alert", func() error { return s.pushoverClient.SendAlert(b.pushover[0]) }); err != nil {
+			log.Printf("Pushover error: %v", err)
+		}
+	default:
+		down := 0
+		for _, msg := range b.pushover {
+			if msg.Status == "down" {
+				down++
+			}
+		}
+		if err := s.notifyTraced(ChannelPushover, "batch", func() error { return s.pushoverClient.SendDigest(batchTitle(down, len(b.pushover)-down), b.pushover) }); err != nil {
+			log.Printf("Pushover error: %v", err)
+		}
+	}
+
+	switch len(b.telegram) {
+	case 0:
+	case 1:
+		if err := s.notifyTraced(ChannelTelegram, "alert", func() error { return s.telegramClient.SendAlert(b.telegram[0]) }); err != nil {
+			log.Printf("Telegram error: %v", err)
+		}
+	default:
+		down := 0
+		for _, msg := range b.telegram {
+			if msg.Status == "down" {
+				down++
+			}
+		}
+		if err := s.notifyTraced(ChannelTelegram, "batch", func() error { return s.telegramClient.SendDigest(batchTitle(down, len(b.telegram)-down), b.telegram) }); err != nil {
+			log.Printf("Telegram error: %v", err)
+		}
+	}
+
+	switch len(b.shoutrrr) {
+	case 0:
+	case 1:
+		if err := s.notifyTraced(ChannelShoutrrr, "alert", func() error { return s.shoutrrrClient.SendAlert(b.shoutrrr[0]) }); err != nil {
+			log.Printf("Shoutrrr error: %v", err)
+		}
+	default:
+		down := 0
+		for _, msg := range b.shoutrrr {
+			if msg.Status == "down" {
+				down++
+			}
+		}
+		if err := s.notifyTraced(ChannelShoutrrr, "batch", func() error { return s.shoutrrrClient.SendDigest(batchTitle(down, len(b.shoutrrr)-down), b.shoutrrr) }); err != nil {
+			log.Printf("Shoutrrr error: %v", err)
+		}
+	}
+
+	switch len(b.sms) {
+	case 0:
+	case 1:
+		if err := s.notifyTraced(ChannelSMS, "alert", func() error { return s.twilioClient.SendAlert(b.sms[0]) }); err != nil {
+			log.Printf("Twilio error: %v", err)
+		}
+	default:
+		down := 0
+		for _, msg := range b.sms {
+			if msg.Status == "down" {
+				down++
+			}
+		}
+		if err := s.notifyTraced(ChannelSMS, "batch", func() error { return s.twilioClient.SendDigest(batchTitle(down, len(b.sms)-down), b.sms) }); err != nil {
+			log.Printf("Twilio error: %v", err)
+		}
+	}
+}
+
+// batchTitle summarises a combined alert, e.g. "🔴 12 checks down, 1 up"
+func batchTitle(down, up int) string {
+	switch {
+	case up == 0:
+		return fmt.Sprintf("🔴 %d checks down", down)
+	case down == 0:
+		return fmt.Sprintf("✅ %d checks up", up)
+	default:
+		return fmt.Sprintf("🔴 %d checks down, %d up", down, up)
+	}
+}
@@ -0,0 +1,142 @@
+package state
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/andrewsjg/simple-healthchecker/copilot/internal/config"
+)
+
+// CheckFilter picks the checks a bulk edit applies to
+type CheckFilter struct {
+	Search string           // Case-insensitive words that must all match the host's name, address or a tag, or the check's URL, ID or name
+	Type   config.CheckType // Empty matches every type
+}
+
+// CheckRef identifies one check matched by a CheckFilter
+type CheckRef struct {
+	Host  string
+	Idx   int
+	Label string
+}
+
+// BulkEdit is a set of changes applied to every check a filter matches.
+// Nil fields are left unchanged.
+type BulkEdit struct {
+	Enabled        *bool
+	Severity       *config.Severity
+	MQTTNotify     *bool
+	PushoverNotify *bool
+	TelegramNotify *bool
+	SMSNotify      *bool
+	DependsOn      *string // Empty clears the dependency
+	Schedule       *string // Empty monitors always
+}
+
+// Empty reports whether e changes nothing
+func (e BulkEdit) Empty() bool {
+	return e == BulkEdit{}
+}
+
+// MatchChecks returns the checks f matches, in config order
+func (s *State) MatchChecks(f CheckFilter) []CheckRef {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.matchChecksLocked(f)
+}
+
+func (s *State) matchChecksLocked(f CheckFilter) []CheckRef {
+	words := strings.Fields(strings.ToLower(f.Search))
+	var refs []CheckRef
+	for _, hs := range s.orderedHostsLocked() {
+		for i := range hs.Checks {
+			c := &hs.Checks[i]
+			if (f.Type == "" || c.Type == f.Type) && c.matches(hs, words) {
+				refs = append(refs, CheckRef{Host: hs.Name, Idx: i, Label: checkLabel(hs, c)})
+			}
+		}
+	}
+	return refs
+}
+
+func (c *CheckStatus) matches(hs *HostStatus, words []string) bool {
+	fields := []string{hs.Name, hs.Address, c.URL, c.FileOpts.Path, c.SMBOpts.Share, c.KafkaOpts.Topic, c.KubernetesOpts.Deployment, c.ProxmoxOpts.VM, c.ESXiOpts.VM, c.UPSOpts.UPS, c.PublicIP, c.DomainOpts.Domain, c.ID, c.Name}
+	fields = append(fields, c.DNSBLOpts.Blocklists...)
+	fields = append(fields, hs.Tags...)
+	text := strings.ToLower(strings.Join(fields, "\n"))
+	for _, w := range words {
+		if !strings.Contains(text, w) {
+			return false
+		}
+	}
+	return true
+}
+
+// BulkEditChecks applies e to every check f matches and saves the config
+// once. Nothing is changed if e is invalid for any of them. It returns the
+// number of checks changed.
+func (s *State) BulkEditChecks(f CheckFilter, e BulkEdit) (int, error) {
+	var sched config.Schedule
+	if e.Schedule != nil {
+		trimmed := strings.TrimSpace(*e.Schedule)
+		e.Schedule = &trimmed
+		var err error
+		if sched, err = config.ParseSchedule(trimmed); err != nil {
+			return 0, err
+		}
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	refs := s.matchChecksLocked(f)
+	if e.DependsOn != nil && *e.DependsOn != "" {
+		for _, ref := range refs {
+			if s.hosts[ref.Host].Checks[ref.Idx].ID == *e.DependsOn {
+				return 0, fmt.Errorf("%s cannot depend on itself", ref.Label)
+			}
+		}
+	}
+	for _, ref := range refs {
+		c := &s.hosts[ref.Host].Checks[ref.Idx]
+		var cc *config.Check
+		for i := range s.cfg.Hosts {
+			if s.cfg.Hosts[i].Name == ref.Host {
+				if ref.Idx < len(s.cfg.Hosts[i].Checks) {
+					cc = &s.cfg.Hosts[i].Checks[ref.Idx]
+				}
+				break
+			}
+		}
+		if cc == nil {
+			cc = &config.Check{}
+		}
+		if e.Enabled != nil {
+			c.Enabled, cc.Enabled = *e.Enabled, *e.Enabled
+		}
+		if e.Severity != nil {
+			c.Severity, cc.Severity = e.Severity.OrDefault(), *e.Severity
+		}
+		if e.MQTTNotify != nil {
+			c.MQTTNotify, cc.MQTTNotify = *e.MQTTNotify, *e.MQTTNotify
+		}
+		if e.PushoverNotify != nil {
+			c.PushoverNotify, cc.PushoverNotify = *e.PushoverNotify, *e.PushoverNotify
+		}
+		if e.TelegramNotify != nil {
+			c.TelegramNotify, cc.TelegramNotify = *e.TelegramNotify, *e.TelegramNotify
+		}
+		if e.SMSNotify != nil {
+			c.SMSNotify, cc.SMSNotify = *e.SMSNotify, *e.SMSNotify
+		}
+		if e.DependsOn != nil {
+			c.DependsOn, cc.DependsOn = *e.DependsOn, *e.DependsOn
+		}
+		if e.Schedule != nil {
+			c.Schedule, cc.Schedule = *e.Schedule, *e.Schedule
+			c.schedule = sched
+		}
+	}
+	if len(refs) == 0 {
+		return 0, nil
+	}
+	return len(refs), s.saveConfigLocked()
+}
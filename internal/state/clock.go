@@ -0,0 +1,29 @@
+package state
+
+import (
+	"log"
+	"time"
+)
+
+// runTimeLocked returns the time to record a run starting at now. History
+// is kept in UTC, so a daylight saving change, which only moves local
+// times, never reorders it; local days and hours are worked out when
+// displayed. If the wall clock has gone back since the last run, e.g.
+// corrected by NTP or set by hand, the run is placed after that one by the
+// time that really passed, as the monotonic clock has it, so history stays
+// in order and no outage or gap comes out negative. Runs catch up with the
+// wall clock once it passes them again.
+func (s *State) runTimeLocked(now time.Time) time.Time {
+	last := s.lastRunAt
+	if !last.IsZero() && now.Round(0).Before(last.Round(0)) {
+		// Sub uses the monotonic clock when both have a reading; without
+		// one, e.g. for a simulated time, the run shares the last one's time
+		elapsed := max(now.Sub(last), 0)
+		log.Printf("warning: the clock is %v behind the last run; recording this run %v after it",
+			last.Round(0).Sub(now.Round(0)).Round(time.Second), elapsed.Round(time.Millisecond))
+		now = last.Add(elapsed)
+	}
+	// Kept with its monotonic reading, which UTC drops
+	s.lastRunAt = now
+	return now.UTC()
+}
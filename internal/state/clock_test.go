@@ -0,0 +1,148 @@
+package state
+
+import (
+	"testing"
+	"time"
+
+	"github.com/andrewsjg/simple-healthchecker/copilot/internal/config"
+)
+
+func loadLondon(t *testing.T) *time.Location {
+	t.Helper()
+	loc, err := time.LoadLocation("Europe/London")
+	if err != nil {
+		t.Skipf("no timezone data: %v", err)
+	}
+	return loc
+}
+
+// pingState returns a State with one ping check on host, showing times in
+// Europe/London
+func pingState(host string) *State {
+	st, _ := newFakeState(&config.Config{
+		Hosts:    []config.Host{{Name: host, Address: "10.0.0.50", Checks: []config.Check{{Type: config.CheckPing, Enabled: true}}}},
+		Settings: config.Settings{Display: config.DisplaySettings{Timezone: "Europe/London"}},
+	})
+	return st
+}
+
+func TestHistoryAcrossDaylightSaving(t *testing.T) {
+	loc := loadLondon(t)
+	tests := []struct {
+		name  string
+		start time.Time // Local time an hour and a half before the change
+	}{
+		// Clocks go forward from 01:00 GMT to 02:00 BST
+		{"spring forward", time.Date(2026, 3, 29, 0, 30, 0, 0, time.UTC)},
+		// Clocks go back from 02:00 BST to 01:00 GMT, so 01:00-02:00 happens twice
+		{"fall back", time.Date(2026, 10, 24, 23, 30, 0, 0, time.UTC)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			st := pingState("dst-" + tt.name)
+			// Every quarter of an hour for three hours, passed in as local
+			// times, as the scheduler's time.Now is
+			for i := range 12 {
+				st.runAt(tt.start.Add(time.Duration(i) * 15 * time.Minute).In(loc))
+			}
+			history := check(t, st, "dst-"+tt.name, 0).FullHistory
+			if len(history) != 12 {
+				t.Fatalf("history has %d points, want 12", len(history))
+			}
+			for i, dp := range history {
+				if dp.Timestamp.Location() != time.UTC {
+					t.Errorf("point %d at %v isn't in UTC", i, dp.Timestamp)
+				}
+				if i > 0 && dp.Timestamp.Sub(history[i-1].Timestamp) != 15*time.Minute {
+					t.Errorf("point %d is %v after the one before, want 15m", i, dp.Timestamp.Sub(history[i-1].Timestamp))
+				}
+			}
+		})
+	}
+}
+
+func TestHistoryWhenTheClockGoesBack(t *testing.T) {
+	st := pingState("clock-back")
+	start := time.Date(2026, 6, 1, 12, 0, 0, 0, time.UTC)
+	st.runAt(start)
+	st.runAt(start.Add(time.Minute))
+	// Set back an hour, e.g. by hand; a simulated time has no monotonic
+	// reading, so the run shares the last one's time
+	st.runAt(start.Add(-time.Hour))
+	st.runAt(start.Add(2 * time.Minute))
+
+	history := check(t, st, "clock-back", 0).FullHistory
+	want := []time.Time{start, start.Add(time.Minute), start.Add(time.Minute), start.Add(2 * time.Minute)}
+	if len(history) != len(want) {
+		t.Fatalf("history has %d points, want %d", len(history), len(want))
+	}
+	for i, dp := range history {
+		if !dp.Timestamp.Equal(want[i]) || dp.Timestamp.Location() != time.UTC {
+			t.Errorf("point %d at %v, want %v in UTC", i, dp.Timestamp, want[i])
+		}
+	}
+}
+
+func TestRunTimeLocked(t *testing.T) {
+	loc := loadLondon(t)
+	s := State{shared: &shared{}}
+	// 01:30 BST, then 01:10 GMT 40 minutes later, which reads earlier
+	first := time.Date(2026, 10, 25, 0, 30, 0, 0, time.UTC).In(loc)
+	if got := s.runTimeLocked(first); got.Location() != time.UTC || !got.Equal(first) {
+		t.Errorf("runTimeLocked(%v) = %v, want the same instant in UTC", first, got)
+	}
+	later := first.Add(40 * time.Minute)
+	if later.Hour() != 1 || later.Minute() != 10 {
+		t.Fatalf("40 minutes after %v is %v; the fall-back day should repeat 01:00-02:00", first, later)
+	}
+	if got := s.runTimeLocked(later); !got.Equal(later) {
+		t.Errorf("runTimeLocked(%v) = %v; a repeated local hour is still later", later, got)
+	}
+	if got := s.runTimeLocked(first); !got.Equal(later) {
+		t.Errorf("runTimeLocked after the clock went back = %v, want %v", got, later)
+	}
+}
+
+func TestTrendsAcrossDaylightSaving(t *testing.T) {
+	loc := loadLondon(t)
+	tests := []struct {
+		name     string
+		day      time.Time // Midnight before the change, local time
+		wantRuns int64     // Hourly runs on the day of the change
+	}{
+		{"spring forward", time.Date(2026, 3, 29, 0, 0, 0, 0, loc), 23},
+		{"fall back", time.Date(2026, 10, 25, 0, 0, 0, 0, loc), 25},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			host := "trend-" + tt.name
+			st := pingState(host)
+			// Hourly from the day before until the day after
+			from, to := tt.day.AddDate(0, 0, -1), tt.day.AddDate(0, 0, 2)
+			for at := from; at.Before(to); at = at.Add(time.Hour) {
+				st.runAt(at)
+			}
+			trend, ok := st.CheckTrendBetween(host, 0, from, to.Add(-time.Hour))
+			if !ok {
+				t.Fatal("no trend")
+			}
+			days := trend.Days
+			if len(days) != 3 {
+				t.Fatalf("trend has %d days, want 3: %+v", len(days), days)
+			}
+			want := []struct {
+				day  string
+				runs int64
+			}{
+				{from.Format(trendDayLayout), 24},
+				{tt.day.Format(trendDayLayout), tt.wantRuns},
+				{tt.day.AddDate(0, 0, 1).Format(trendDayLayout), 24},
+			}
+			for i, w := range want {
+				if days[i].Day != w.day || days[i].Runs != w.runs {
+					t.Errorf("day %d = %s with %d runs, want %s with %d", i, days[i].Day, days[i].Runs, w.day, w.runs)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,133 @@
+package state
+
+import (
+	"fmt"
+	"log"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/andrewsjg/simple-healthchecker/copilot/internal/config"
+)
+
+// checkCompositeMembers records a warning for each composite check member
+// that no check has as its ID. Such members count as down.
+func (s *State) checkCompositeMembers() {
+	for _, hs := range s.orderedHostsLocked() {
+		for _, c := range hs.Checks {
+			if c.Type != config.CheckComposite {
+				continue
+			}
+			for _, id := range append(slices.Clone(c.AllOf), c.AnyOf...) {
+				if _, ok := s.checksByID[id]; !ok {
+					msg := fmt.Sprintf("COMPOSITE check on %q: no check has ID %q, so it counts as down", hs.Name, id)
+					log.Printf("warning: %s", msg)
+					s.warnings = append(s.warnings, msg)
+				}
+			}
+		}
+	}
+}
+
+// CompositeExpr describes what a composite check combines, e.g.
+// "web AND db AND (db-a OR db-b)"
+func (c CheckStatus) CompositeExpr() string {
+	parts := slices.Clone(c.AllOf)
+	if len(c.AnyOf) > 0 {
+		anyOf := strings.Join(c.AnyOf, " OR ")
+		if len(c.AllOf) > 0 && len(c.AnyOf) > 1 {
+			anyOf = "(" + anyOf + ")"
+		}
+		parts = append(parts, anyOf)
+	}
+	return strings.Join(parts, " AND ")
+}
+
+// compositeResultLocked combines the latest results of a composite check's
+// members. Members that are disabled, off schedule, within expected downtime
+// or not yet checked are left out; unknown IDs count as down. The latency is
+// that of the slowest member that is up.
+func (s *State) compositeResultLocked(c *CheckStatus) (bool, time.Duration, string) {
+	var latency time.Duration
+	counted, up := 0, 0
+	// member reports whether the member is up, and whether it counts at all
+	member := func(id string) (bool, bool) {
+		m, ok := s.checksByID[id]
+		if !ok {
+			counted++
+			return false, true
+		}
+		if !m.Enabled || m.OffSchedule || m.CheckedAt.IsZero() || (m.Expected && !m.OK) {
+			return false, false
+		}
+		counted++
+		if m.OK {
+			up++
+			latency = max(latency, m.Latency)
+		}
+		return m.OK, true
+	}
+
+	var down, anyDown []string
+	for _, id := range c.AllOf {
+		if ok, counts := member(id); counts && !ok {
+			down = append(down, id)
+		}
+	}
+	anyUp, anyCounted := false, false
+	for _, id := range c.AnyOf {
+		ok, counts := member(id)
+		anyUp = anyUp || ok
+		anyCounted = anyCounted || counts
+		if counts && !ok {
+			anyDown = append(anyDown, id)
+		}
+	}
+
+	var problems []string
+	if len(down) > 0 {
+		problems = append(problems, "down: "+strings.Join(down, ", "))
+	}
+	if anyCounted && !anyUp {
+		problems = append(problems, "none up of: "+strings.Join(anyDown, ", "))
+	}
+	if len(problems) > 0 {
+		return false, 0, strings.Join(problems, "; ")
+	}
+	if counted == 0 {
+		return true, 0, "no members checked yet"
+	}
+	return true, latency, fmt.Sprintf("%d of %d members up", up, counted)
+}
+
+// runOrderLocked returns the runtime hosts in config order, with hosts that
+// have composite checks last so those see this run's member results
+func (s *State) runOrderLocked() []*HostStatus {
+	var plain, combined []*HostStatus
+	for _, hs := range s.orderedHostsLocked() {
+		if slices.ContainsFunc(hs.Checks, isComposite) {
+			combined = append(combined, hs)
+		} else {
+			plain = append(plain, hs)
+		}
+	}
+	return append(plain, combined...)
+}
+
+// checkRunOrder returns the indexes of checks in the order to run them:
+// as configured, but with composite checks after the rest
+func checkRunOrder(checks []CheckStatus) []int {
+	order := make([]int, 0, len(checks))
+	for pass := range 2 {
+		for i := range checks {
+			if isComposite(checks[i]) == (pass == 1) {
+				order = append(order, i)
+			}
+		}
+	}
+	return order
+}
+
+func isComposite(c CheckStatus) bool {
+	return c.Type == config.CheckComposite
+}
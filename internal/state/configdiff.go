@@ -0,0 +1,280 @@
+package state
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+
+	"github.com/andrewsjg/simple-healthchecker/copilot/internal/config"
+)
+
+// ErrConfigChanged is returned when applying a change previewed against
+// hosts that have since been edited
+var ErrConfigChanged = errors.New("the hosts changed since the preview")
+
+// HostChange describes how one host would change
+type HostChange struct {
+	Name    string
+	Details []string // e.g. "address 10.0.0.1 → 10.0.0.2", "http check web added"
+}
+
+// ConfigDiff previews a change to the local hosts before it is applied
+type ConfigDiff struct {
+	Added     []HostChange
+	Removed   []HostChange
+	Changed   []HostChange
+	Skipped   []string // Imported hosts whose names are already in use
+	Unchanged int
+
+	// Checks added, removed and changed across every host, including
+	// those of added and removed hosts
+	ChecksAdded, ChecksRemoved, ChecksChanged int
+
+	// Fingerprint of the hosts the preview was made against
+	Fingerprint string
+}
+
+// Empty reports whether applying the change would do nothing
+func (d ConfigDiff) Empty() bool {
+	return len(d.Added)+len(d.Removed)+len(d.Changed) == 0
+}
+
+// HostsFingerprint identifies the current local hosts, so a preview can be
+// checked against them when it is applied
+func (s *State) HostsFingerprint() string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.fingerprintLocked()
+}
+
+func (s *State) fingerprintLocked() string {
+	b, _ := json.Marshal(s.localHostsLocked())
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:8])
+}
+
+// localHostsLocked returns the configured hosts that aren't remote
+func (s *State) localHostsLocked() []config.Host {
+	var local []config.Host
+	for _, h := range s.cfg.Hosts {
+		if !h.Remote() {
+			local = append(local, h)
+		}
+	}
+	return local
+}
+
+// PreviewImport shows what ImportHosts would add and skip
+func (s *State) PreviewImport(hosts []config.Host) ConfigDiff {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	d := ConfigDiff{Fingerprint: s.fingerprintLocked(), Unchanged: len(s.cfg.Hosts)}
+	seen := make(map[string]bool)
+	for _, h := range hosts {
+		if _, exists := s.hosts[h.Name]; exists || seen[h.Name] {
+			d.Skipped = append(d.Skipped, h.Name)
+			continue
+		}
+		seen[h.Name] = true
+		d.Added = append(d.Added, HostChange{Name: h.Name, Details: checkSummary(h)})
+		d.ChecksAdded += len(h.Checks)
+	}
+	return d
+}
+
+// PreviewReplace shows what ReplaceHosts would add, remove and change
+func (s *State) PreviewReplace(hosts []config.Host) ConfigDiff {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return diffHosts(s.localHostsLocked(), hosts, s.fingerprintLocked())
+}
+
+// ReplaceHosts makes hosts the local hosts, as previewed by PreviewReplace
+// against fingerprint. Hosts that are unchanged keep their state, changed
+// ones keep the history of checks that still match, and removed ones are
+// dropped with their history. Remote hosts are left alone unless a new
+// local host takes their name.
+func (s *State) ReplaceHosts(hosts []config.Host, fingerprint string) (ConfigDiff, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.fingerprintLocked() != fingerprint {
+		return ConfigDiff{}, ErrConfigChanged
+	}
+	old := make(map[string]config.Host)
+	for _, h := range s.cfg.Hosts {
+		old[h.Name] = h
+	}
+	d := diffHosts(s.localHostsLocked(), hosts, fingerprint)
+
+	var next []config.Host
+	seen := make(map[string]bool)
+	for _, h := range hosts {
+		if seen[h.Name] {
+			continue
+		}
+		seen[h.Name] = true
+		prev, existed := old[h.Name]
+		if existed && !prev.Remote() {
+			// Stays in the include file it came from
+			h = h.WithSourceOf(prev)
+		}
+		s.syncHostLocked(h, prev, existed)
+		next = append(next, h)
+	}
+	for _, h := range s.cfg.Hosts {
+		switch {
+		case seen[h.Name]:
+		case h.Remote():
+			next = append(next, h)
+		default:
+			delete(s.hosts, h.Name)
+		}
+	}
+	s.cfg.Hosts = next
+	s.rebuildCheckIndex()
+	return d, s.saveConfigLocked()
+}
+
+// syncHostLocked brings the runtime state of h up to date with its config.
+// prev is its config before, if it existed: an unchanged host is left as
+// it is, and a changed one is rebuilt keeping its checks' history.
+func (s *State) syncHostLocked(h, prev config.Host, existed bool) {
+	was, ok := s.hosts[h.Name]
+	switch {
+	case !existed || !ok:
+		s.hosts[h.Name] = s.hostStatusFromConfig(h)
+	case !config.SameHost(prev, h) || prev.Remote() != h.Remote():
+		hs := s.hostStatusFromConfig(h)
+		carryCheckHistory(was, hs)
+		s.hosts[h.Name] = hs
+	}
+}
+
+// diffHosts compares the current hosts with proposed ones, matching hosts
+// by name and checks as carryCheckHistory does
+func diffHosts(current, proposed []config.Host, fingerprint string) ConfigDiff {
+	d := ConfigDiff{Fingerprint: fingerprint}
+	byName := make(map[string]config.Host, len(current))
+	for _, h := range current {
+		byName[h.Name] = h
+	}
+	seen := make(map[string]bool)
+	for _, h := range proposed {
+		if seen[h.Name] {
+			continue
+		}
+		seen[h.Name] = true
+		prev, ok := byName[h.Name]
+		switch {
+		case !ok:
+			d.Added = append(d.Added, HostChange{Name: h.Name, Details: checkSummary(h)})
+			d.ChecksAdded += len(h.Checks)
+		case config.SameHost(prev, h):
+			d.Unchanged++
+		default:
+			c := HostChange{Name: h.Name}
+			c.Details = hostDetails(prev, h, &d)
+			d.Changed = append(d.Changed, c)
+		}
+	}
+	for _, h := range current {
+		if !seen[h.Name] {
+			d.Removed = append(d.Removed, HostChange{Name: h.Name, Details: checkSummary(h)})
+			d.ChecksRemoved += len(h.Checks)
+		}
+	}
+	return d
+}
+
+// checkSummary lists a host's checks, for added and removed hosts
+func checkSummary(h config.Host) []string {
+	out := make([]string, 0, len(h.Checks))
+	for i, c := range h.Checks {
+		out = append(out, cfgCheckLabel(c, i))
+	}
+	return out
+}
+
+// cfgCheckLabel names a configured check by its ID, or its type and
+// position if it has none
+func cfgCheckLabel(c config.Check, idx int) string {
+	if c.ID != "" {
+		return fmt.Sprintf("%s check %s", c.Type, c.ID)
+	}
+	return fmt.Sprintf("%s check #%d", c.Type, idx+1)
+}
+
+// hostDetails lists what differs between two versions of a host, counting
+// check changes in d
+func hostDetails(prev, h config.Host, d *ConfigDiff) []string {
+	var out []string
+	field := func(name, was, now string) {
+		switch {
+		case was == now:
+		case was == "":
+			out = append(out, fmt.Sprintf("%s set to %q", name, now))
+		case now == "":
+			out = append(out, name+" cleared")
+		default:
+			out = append(out, fmt.Sprintf("%s %s → %s", name, was, now))
+		}
+	}
+	field("address", prev.Address, h.Address)
+	if prev.HealthchecksPingURL != h.HealthchecksPingURL {
+		out = append(out, "healthchecks.io URL changed")
+	}
+	if prev.Notes != h.Notes || prev.RunbookURL != h.RunbookURL {
+		out = append(out, "notes changed")
+	}
+	if !slices.Equal(prev.Tags, h.Tags) {
+		out = append(out, "tags changed")
+	}
+	if prev.Gateway != h.Gateway {
+		out = append(out, fmt.Sprintf("gateway %t → %t", prev.Gateway, h.Gateway))
+	}
+	if prev.MaxConcurrentProbes != h.MaxConcurrentProbes {
+		out = append(out, fmt.Sprintf("max_concurrent_probes %d → %d", prev.MaxConcurrentProbes, h.MaxConcurrentProbes))
+	}
+
+	used := make([]bool, len(prev.Checks))
+	for i, c := range h.Checks {
+		match := matchCfgCheck(prev.Checks, used, c, i)
+		if match < 0 {
+			out = append(out, cfgCheckLabel(c, i)+" added")
+			d.ChecksAdded++
+			continue
+		}
+		used[match] = true
+		if !config.SameHost(config.Host{Checks: prev.Checks[match : match+1]}, config.Host{Checks: []config.Check{c}}) {
+			out = append(out, cfgCheckLabel(c, i)+" changed")
+			d.ChecksChanged++
+		}
+	}
+	for j, c := range prev.Checks {
+		if !used[j] {
+			out = append(out, cfgCheckLabel(c, j)+" removed")
+			d.ChecksRemoved++
+		}
+	}
+	if len(out) == 0 {
+		out = append(out, "other options changed")
+	}
+	return out
+}
+
+// matchCfgCheck finds the check in checks that c at idx replaces: the one
+// with its ID, or with neither an ID and the same type and position
+func matchCfgCheck(checks []config.Check, used []bool, c config.Check, idx int) int {
+	for j, w := range checks {
+		if used[j] || w.Type != c.Type {
+			continue
+		}
+		if c.ID != "" && w.ID == c.ID || c.ID == "" && w.ID == "" && j == idx {
+			return j
+		}
+	}
+	return -1
+}
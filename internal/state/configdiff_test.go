@@ -0,0 +1,135 @@
+package state
+
+import (
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/andrewsjg/simple-healthchecker/copilot/internal/config"
+)
+
+// changeNames lists the hosts in changes
+func changeNames(changes []HostChange) []string {
+	var names []string
+	for _, c := range changes {
+		names = append(names, c.Name)
+	}
+	return names
+}
+
+func TestPreviewReplace(t *testing.T) {
+	st, _ := newFakeState(&config.Config{Hosts: []config.Host{
+		{Name: "router", Address: "192.168.1.1"},
+		{Name: "nas", Address: "192.168.1.10", Tags: []string{"storage"}, Checks: []config.Check{
+			{Type: config.CheckPing, Enabled: true},
+			{Type: config.CheckHTTP, ID: "web", URL: "http://192.168.1.10", Enabled: true},
+			{Type: config.CheckTCP, ID: "smb", Port: 445, Enabled: true},
+		}},
+		{Name: "printer", Address: "192.168.1.20", Checks: []config.Check{
+			{Type: config.CheckPing, Enabled: true},
+			{Type: config.CheckTCP, Port: 631, Enabled: true},
+		}},
+	}})
+	d := st.PreviewReplace([]config.Host{
+		{Name: "router", Address: "192.168.1.1"},
+		{Name: "nas", Address: "192.168.1.11", Checks: []config.Check{
+			// Matched by ID though it moved
+			{Type: config.CheckHTTP, ID: "web", URL: "http://192.168.1.11", Enabled: true},
+			{Type: config.CheckPing, Enabled: true},
+			{Type: config.CheckSSH, ID: "uptime", Enabled: true},
+		}},
+		{Name: "plex", Address: "192.168.1.30", Checks: []config.Check{
+			{Type: config.CheckPing, Enabled: true},
+			{Type: config.CheckHTTP, ID: "plex-web", URL: "http://192.168.1.30:32400", Enabled: true},
+		}},
+		// Only the first of a repeated name counts
+		{Name: "plex", Address: "192.168.1.31"},
+	})
+
+	if got := changeNames(d.Added); !slices.Equal(got, []string{"plex"}) {
+		t.Errorf("added = %q, want plex", got)
+	}
+	if got := changeNames(d.Removed); !slices.Equal(got, []string{"printer"}) {
+		t.Errorf("removed = %q, want printer", got)
+	}
+	if got := changeNames(d.Changed); !slices.Equal(got, []string{"nas"}) {
+		t.Fatalf("changed = %q, want nas", got)
+	}
+	if d.Unchanged != 1 || d.Empty() {
+		t.Errorf("unchanged = %d, empty = %v; want 1 and false", d.Unchanged, d.Empty())
+	}
+
+	// The ping check without an ID moved from first to second, so it no
+	// longer matches by position
+	wantDetails := []string{
+		"address 192.168.1.10 → 192.168.1.11",
+		"tags changed",
+		"http check web changed",
+		"ping check #2 added",
+		"ssh check uptime added",
+		"ping check #1 removed",
+		"tcp check smb removed",
+	}
+	if got := d.Changed[0].Details; !slices.Equal(got, wantDetails) {
+		t.Errorf("nas details =\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(wantDetails, "\n"))
+	}
+	if got := d.Added[0].Details; !slices.Equal(got, []string{"ping check #1", "http check plex-web"}) {
+		t.Errorf("plex details = %q", got)
+	}
+	// Added and removed hosts' checks count too
+	if d.ChecksAdded != 2+2 || d.ChecksRemoved != 2+2 || d.ChecksChanged != 1 {
+		t.Errorf("checks added, removed, changed = %d, %d, %d; want 4, 4, 1", d.ChecksAdded, d.ChecksRemoved, d.ChecksChanged)
+	}
+
+	// Previewing the hosts as they are changes nothing
+	if d := st.PreviewReplace(slices.Clone(st.cfg.Hosts)); !d.Empty() || d.Unchanged != 3 {
+		t.Errorf("preview of the same hosts = %+v, want 3 unchanged", d)
+	}
+}
+
+func TestPreviewImport(t *testing.T) {
+	st, _ := newFakeState(&config.Config{Hosts: []config.Host{{Name: "router", Address: "192.168.1.1"}}})
+	d := st.PreviewImport([]config.Host{
+		{Name: "router", Address: "10.0.0.1"},
+		{Name: "nas", Address: "192.168.1.10", Checks: []config.Check{{Type: config.CheckPing, Enabled: true}}},
+		{Name: "nas", Address: "192.168.1.11"},
+	})
+	if got := changeNames(d.Added); !slices.Equal(got, []string{"nas"}) {
+		t.Errorf("added = %q, want nas", got)
+	}
+	if !slices.Equal(d.Skipped, []string{"router", "nas"}) {
+		t.Errorf("skipped = %q, want router and the second nas", d.Skipped)
+	}
+	if len(d.Removed)+len(d.Changed) != 0 || d.ChecksAdded != 1 {
+		t.Errorf("import preview = %+v, want one host and check added", d)
+	}
+}
+
+func TestReplaceHostsChecksFingerprint(t *testing.T) {
+	st, _ := newFakeState(&config.Config{Hosts: []config.Host{{Name: "router", Address: "192.168.1.1"}}})
+	proposed := []config.Host{{Name: "nas", Address: "192.168.1.10"}}
+	d := st.PreviewReplace(proposed)
+
+	// An edit after the preview makes it stale
+	if err := st.SetHostTags("router", []string{"core"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := st.ReplaceHosts(proposed, d.Fingerprint); !errors.Is(err, ErrConfigChanged) {
+		t.Fatalf("ReplaceHosts with a stale preview = %v, want ErrConfigChanged", err)
+	}
+	if _, ok := st.GetHost("router"); !ok {
+		t.Fatal("a stale ReplaceHosts removed router")
+	}
+
+	d = st.PreviewReplace(proposed)
+	if _, err := st.ReplaceHosts(proposed, d.Fingerprint); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := st.GetHost("router"); ok {
+		t.Error("router is still there after being replaced")
+	}
+	if _, ok := st.GetHost("nas"); !ok {
+		t.Error("nas wasn't added")
+	}
+}
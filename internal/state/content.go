@@ -0,0 +1,77 @@
+package state
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/andrewsjg/simple-healthchecker/copilot/internal/checks"
+	"github.com/andrewsjg/simple-healthchecker/copilot/internal/config"
+)
+
+// checkContentLocked applies an http check's content rules to a response
+// that had the expected status, returning why the content is unacceptable or
+// "" if it passes. With watch_content the first body seen becomes the
+// accepted baseline; any later change fails the check until it is accepted.
+func (s *State) checkContentLocked(hostName string, idx int, c *CheckStatus, res checks.HTTPResult) string {
+	if res.ContentErr != nil {
+		return res.ContentErr.Error()
+	}
+	if !c.HTTPOpts.WatchContent || res.Hash == "" {
+		return ""
+	}
+	switch {
+	case c.ContentHash == "":
+		c.ContentHash = res.Hash
+		s.setCfgContentHashLocked(hostName, idx, res.Hash)
+		if err := s.saveConfigLocked(); err != nil {
+			log.Printf("save content baseline for %q failed: %v", hostName, err)
+		}
+	case res.Hash != c.ContentHash:
+		c.ChangedHash = res.Hash
+		return fmt.Sprintf("content changed (sha256 %.12s)", res.Hash)
+	}
+	// Unchanged, or changed back to the accepted content
+	c.ChangedHash = ""
+	return ""
+}
+
+// AcceptContentChange makes the changed content of the http check at idx the
+// new baseline, so the check passes again from its next run
+func (s *State) AcceptContentChange(hostName string, idx int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	hs, ok := s.hosts[hostName]
+	if !ok {
+		return fmt.Errorf("host not found")
+	}
+	if idx < 0 || idx >= len(hs.Checks) {
+		return fmt.Errorf("bad index")
+	}
+	c := &hs.Checks[idx]
+	if c.Type != config.CheckHTTP || c.ChangedHash == "" {
+		return fmt.Errorf("no content change to accept")
+	}
+	c.ContentHash = c.ChangedHash
+	c.ChangedHash = ""
+	s.setCfgContentHashLocked(hostName, idx, c.ContentHash)
+	return s.saveConfigLocked()
+}
+
+// resetContentLocked forgets the accepted content of c, e.g. because its URL
+// changed, so the next response becomes the baseline
+func (s *State) resetContentLocked(hostName string, idx int, c *CheckStatus) {
+	c.ContentHash = ""
+	c.ChangedHash = ""
+	s.setCfgContentHashLocked(hostName, idx, "")
+}
+
+func (s *State) setCfgContentHashLocked(hostName string, idx int, hash string) {
+	for i := range s.cfg.Hosts {
+		if s.cfg.Hosts[i].Name == hostName {
+			if idx < len(s.cfg.Hosts[i].Checks) {
+				s.cfg.Hosts[i].Checks[idx].ContentHash = hash
+			}
+			return
+		}
+	}
+}
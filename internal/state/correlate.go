@@ -0,0 +1,154 @@
+package state
+
+import (
+	"slices"
+	"sort"
+	"time"
+
+	"github.com/andrewsjg/simple-healthchecker/copilot/internal/config"
+)
+
+// DefaultCorrelationWindow is how close together outages must begin to be
+// grouped, unless the analytics page asks for another window
+const DefaultCorrelationWindow = 2 * time.Minute
+
+// OutageCluster is a group of outages, on any hosts, that began within a
+// window of the first of them: likely one cause, such as a switch reboot
+type OutageCluster struct {
+	Start   time.Time     // When the first outage began
+	Spread  time.Duration // From the first outage beginning to the last, to the second
+	Outages []Outage      // In the order they began
+	Hosts   int
+	Shared  []string // Tags every host in the cluster has, as a hint to the cause
+}
+
+// Outage is one check's outage, from its down event to its recovery
+type Outage struct {
+	Host      string
+	Idx       int
+	CheckID   string
+	CheckType config.CheckType
+	Label     string
+	Start     time.Time
+	Duration  time.Duration // Zero while ongoing
+	NoData    time.Duration // Time within it the monitor recorded nothing, e.g. asleep, so it isn't counted as down
+	Ongoing   bool
+	Message   string // Why it went down
+	Blocked   int    // Dependent checks it blocked
+}
+
+// Downtime is how long the outage has been down, leaving out any time the
+// monitor recorded nothing; zero while ongoing, like Duration
+func (o Outage) Downtime() time.Duration {
+	if o.Ongoing {
+		return 0
+	}
+	return o.Duration - o.NoData
+}
+
+// Outages returns the outages in the event log, oldest first. Blocked
+// checks aren't outages of their own; each outage counts the checks it
+// blocked instead.
+func (s *State) Outages() []Outage {
+	type key struct {
+		host string
+		idx  int
+	}
+	var outs []Outage
+	open := make(map[key]int) // Index in outs of each check's ongoing outage
+	eventLogMutex.RLock()
+	for _, e := range eventLog {
+		if e.CheckType == "" {
+			continue
+		}
+		k := key{e.HostName, e.CheckIdx}
+		switch e.EventType {
+		case "down":
+			if _, ok := open[k]; !ok {
+				open[k] = len(outs)
+				outs = append(outs, Outage{Host: e.HostName, Idx: e.CheckIdx, CheckID: e.CheckID, CheckType: e.CheckType,
+					Start: e.Timestamp, Ongoing: true, Message: e.Message, Blocked: len(e.Blocked)})
+			}
+		case "recovered":
+			if i, ok := open[k]; ok {
+				outs[i].Duration, outs[i].Ongoing = e.Timestamp.Sub(outs[i].Start), false
+				delete(open, k)
+			}
+		}
+	}
+	spans := noDataSpansLocked()
+	eventLogMutex.RUnlock()
+	now := time.Now()
+	for i := range outs {
+		o := &outs[i]
+		end := o.Start.Add(o.Duration)
+		if o.Ongoing {
+			end = now
+		}
+		o.NoData = noDataWithin(spans, o.Start, end)
+	}
+	sort.SliceStable(outs, func(i, j int) bool { return outs[i].Start.Before(outs[j].Start) })
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	for i := range outs {
+		o := &outs[i]
+		if hs, ok := s.hosts[o.Host]; ok && o.Idx >= 0 && o.Idx < len(hs.Checks) {
+			o.Label = checkLabel(hs, &hs.Checks[o.Idx])
+		} else {
+			o.Label = o.Host
+		}
+	}
+	return outs
+}
+
+// CorrelatedOutages groups the outages in the event log that began within
+// window of each other, returning the groups of at least two checks,
+// newest first
+func (s *State) CorrelatedOutages(window time.Duration, limit int) []OutageCluster {
+	outs := s.Outages()
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	var clusters []OutageCluster
+	for i := 0; i < len(outs); {
+		j := i + 1
+		for j < len(outs) && outs[j].Start.Sub(outs[i].Start) <= window {
+			j++
+		}
+		if j-i >= 2 {
+			clusters = append(clusters, s.outageClusterLocked(outs[i:j]))
+		}
+		i = j
+	}
+	slices.Reverse(clusters)
+	if limit > 0 && len(clusters) > limit {
+		clusters = clusters[:limit]
+	}
+	return clusters
+}
+
+// outageClusterLocked finds what the hosts of outs, which began within the
+// window of the first, have in common
+func (s *State) outageClusterLocked(outs []Outage) OutageCluster {
+	cl := OutageCluster{Start: outs[0].Start, Spread: outs[len(outs)-1].Start.Sub(outs[0].Start).Round(time.Second), Outages: outs}
+	hosts := make(map[string]bool)
+	for _, o := range outs {
+		hosts[o.Host] = true
+	}
+	cl.Hosts = len(hosts)
+	first := true
+	for name := range hosts {
+		var tags []string
+		if hs, ok := s.hosts[name]; ok {
+			tags = hs.Tags
+		}
+		if first {
+			cl.Shared = slices.Clone(tags)
+			first = false
+			continue
+		}
+		cl.Shared = slices.DeleteFunc(cl.Shared, func(t string) bool { return !slices.Contains(tags, t) })
+	}
+	slices.Sort(cl.Shared)
+	return cl
+}
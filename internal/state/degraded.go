@@ -0,0 +1,83 @@
+package state
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/andrewsjg/simple-healthchecker/copilot/internal/checks"
+	"github.com/andrewsjg/simple-healthchecker/copilot/internal/config"
+)
+
+// noteLatencyLocked classifies the run of the check at idx just recorded
+// against its expected latency. A check that passed but took longer for
+// the configured number of runs in a row is degraded until a run passes
+// comfortably within it, below the clear threshold, so one that hovers
+// around its limit doesn't flicker. Going down or being inverted, with no
+// response time to go by, ends it quietly, as the down event says more.
+func (s *State) noteLatencyLocked(hs *HostStatus, idx int, now time.Time) {
+	c := &hs.Checks[idx]
+	if c.ExpectedLatency <= 0 || !c.OK || c.Invert || c.Latency <= 0 {
+		c.Degraded, c.DegradedSince, c.slowRuns = false, time.Time{}, 0
+		return
+	}
+	settings := s.cfg.Settings.Latency
+	if c.Latency > c.ExpectedLatency {
+		c.slowRuns++
+	} else {
+		c.slowRuns = 0
+	}
+	var msg, eventType string
+	switch clear := settings.ClearBelow(c.ExpectedLatency); {
+	case !c.Degraded && c.slowRuns >= settings.Runs():
+		c.Degraded, c.DegradedSince = true, now
+		eventType = "degraded"
+		msg = fmt.Sprintf("Answered in %s, expected within %s", checks.FormatLatency(c.Latency), checks.FormatLatency(c.ExpectedLatency))
+		if c.slowRuns > 1 {
+			msg += fmt.Sprintf(", %d runs in a row", c.slowRuns)
+		}
+	case c.Degraded && c.Latency <= clear:
+		eventType = "latency_ok"
+		msg = fmt.Sprintf("Answered in %s, within %s, after %v slow", checks.FormatLatency(c.Latency), checks.FormatLatency(clear), now.Sub(c.DegradedSince).Round(time.Second))
+		c.Degraded, c.DegradedSince = false, time.Time{}
+	default:
+		return
+	}
+	logEvent(Event{
+		Timestamp: now,
+		HostName:  hs.Name,
+		CheckIdx:  idx,
+		CheckID:   c.ID,
+		CheckName: c.Name,
+		CheckType: c.Type,
+		EventType: eventType,
+		Message:   msg,
+	})
+}
+
+// GetLatencySettings returns the expected latency of each check type
+func (s *State) GetLatencySettings() config.LatencySettings {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.cfg.Settings.Latency
+}
+
+// UpdateLatencySettings sets the expected latency of each check type and
+// applies it to checks without their own, from their next run
+func (s *State) UpdateLatencySettings(settings config.LatencySettings) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.cfg.Settings.Latency = settings
+	for _, h := range s.cfg.Hosts {
+		hs, ok := s.hosts[h.Name]
+		if !ok {
+			continue
+		}
+		for i, c := range h.Checks {
+			if i < len(hs.Checks) {
+				hs.Checks[i].ExpectedLatency = c.LatencyLimit(settings)
+			}
+		}
+	}
+	return s.saveConfigLocked()
+}
@@ -0,0 +1,29 @@
+package state
+
+import (
+	"time"
+
+	"github.com/andrewsjg/simple-healthchecker/copilot/internal/checks"
+	"github.com/andrewsjg/simple-healthchecker/copilot/internal/config"
+)
+
+// demoBackfill is how many past scheduler ticks of history demo mode fakes
+// at startup, so charts and heatmaps are populated on first load
+const demoBackfill = 360
+
+// NewDemo returns a State for demo mode: the sample hosts from config.Demo,
+// probed by a checks.Demo instead of the network. It has no config path, so
+// edits made in the UI are never written to disk. An entry point offering
+// demo mode calls it in place of New and starts the server as usual.
+func NewDemo(interval time.Duration) *State {
+	st := New(config.Demo())
+	demo := checks.NewDemo(uint64(time.Now().UnixNano()))
+	demo.SetFlaky("192.168.1.5")
+	st.SetChecker(demo)
+
+	start := time.Now().Add(-demoBackfill * interval)
+	for i := 0; i < demoBackfill; i++ {
+		st.runAt(start.Add(time.Duration(i) * interval))
+	}
+	return st
+}
@@ -0,0 +1,246 @@
+package state
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"slices"
+
+	"github.com/andrewsjg/simple-healthchecker/copilot/internal/config"
+)
+
+// ErrHasDependants is returned when deleting a host or check that other
+// checks depend on without saying what should happen to them
+var ErrHasDependants = errors.New("other checks depend on it")
+
+// OrphanAction says what deleting a host or check does to the checks that
+// depend on it, through depends_on or as a composite member
+type OrphanAction string
+
+const (
+	OrphanBlock   OrphanAction = ""        // Refuse the delete with ErrHasDependants
+	OrphanClear   OrphanAction = "clear"   // Remove the references to the deleted checks
+	OrphanCascade OrphanAction = "cascade" // Delete the dependants too, and theirs in turn
+)
+
+// Dependant is a check that would be left referencing a deleted one
+type Dependant struct {
+	CheckRef
+	Direct  bool // References a deleted check itself, rather than another dependant
+	Emptied bool // A composite whose members would all be deleted
+}
+
+// Dependants returns the checks that depend on the check at idx of
+// hostName, or on any of the host's checks if idx is negative. Checks that
+// only depend on those are included too, as a cascading delete removes them.
+func (s *State) Dependants(hostName string, idx int) []Dependant {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.dependantsLocked(s.deleteSetLocked(hostName, idx))
+}
+
+// deleteSet holds the indices of the checks being deleted, by host
+type deleteSet map[string][]int
+
+func (s *State) deleteSetLocked(hostName string, idx int) deleteSet {
+	hs, ok := s.hosts[hostName]
+	if !ok {
+		return deleteSet{}
+	}
+	if idx >= 0 {
+		if idx >= len(hs.Checks) {
+			return deleteSet{}
+		}
+		return deleteSet{hostName: {idx}}
+	}
+	all := make([]int, len(hs.Checks))
+	for i := range all {
+		all[i] = i
+	}
+	return deleteSet{hostName: all}
+}
+
+// idsLocked returns the check IDs of the checks in del
+func (s *State) idsLocked(del deleteSet) map[string]bool {
+	ids := make(map[string]bool)
+	for host, idxs := range del {
+		for _, i := range idxs {
+			if id := s.hosts[host].Checks[i].ID; id != "" {
+				ids[id] = true
+			}
+		}
+	}
+	return ids
+}
+
+// dependantsLocked returns the checks depending on del, adding them to it
+func (s *State) dependantsLocked(del deleteSet) []Dependant {
+	var out []Dependant
+	direct := s.idsLocked(del)
+	gone := s.idsLocked(del)
+	// Repeat until no more checks depend on the deleted ones or their
+	// dependants, so the list covers everything a cascade would remove
+	for changed := true; changed; {
+		changed = false
+		for _, hs := range s.orderedHostsLocked() {
+			for i := range hs.Checks {
+				c := &hs.Checks[i]
+				if slices.Contains(del[hs.Name], i) || !c.references(gone) {
+					continue
+				}
+				d := Dependant{CheckRef: CheckRef{Host: hs.Name, Idx: i, Label: checkLabel(hs, c)}, Direct: c.references(direct)}
+				if d.Direct && c.Type == config.CheckComposite {
+					d.Emptied = !slices.ContainsFunc(append(slices.Clone(c.AllOf), c.AnyOf...), func(id string) bool { return !direct[id] })
+				}
+				out = append(out, d)
+				del[hs.Name] = append(del[hs.Name], i)
+				if c.ID != "" {
+					gone[c.ID] = true
+				}
+				changed = true
+			}
+		}
+	}
+	return out
+}
+
+// references reports whether c depends on, or combines, any of ids
+func (c *CheckStatus) references(ids map[string]bool) bool {
+	return ids[c.DependsOn] || slices.ContainsFunc(c.AllOf, func(id string) bool { return ids[id] }) ||
+		slices.ContainsFunc(c.AnyOf, func(id string) bool { return ids[id] })
+}
+
+// resolveOrphansLocked applies action to the checks that depend on del,
+// returning the full set of checks to delete
+func (s *State) resolveOrphansLocked(del deleteSet, action OrphanAction) (deleteSet, error) {
+	direct := s.idsLocked(del)
+	all := deleteSet{}
+	for host, idxs := range del {
+		all[host] = slices.Clone(idxs)
+	}
+	deps := s.dependantsLocked(all)
+	if len(deps) == 0 {
+		return del, nil
+	}
+	switch action {
+	case OrphanCascade:
+		return all, nil
+	case OrphanClear:
+		for _, d := range deps {
+			if d.Emptied {
+				return nil, fmt.Errorf("%s would be left with no members", d.Label)
+			}
+		}
+		for _, d := range deps {
+			if !d.Direct {
+				continue
+			}
+			c := &s.hosts[d.Host].Checks[d.Idx]
+			clearReferences(&c.DependsOn, &c.AllOf, &c.AnyOf, direct)
+			if cc := s.configCheckLocked(d.Host, d.Idx); cc != nil {
+				clearReferences(&cc.DependsOn, &cc.AllOf, &cc.AnyOf, direct)
+			}
+		}
+		return del, nil
+	}
+	return nil, ErrHasDependants
+}
+
+func clearReferences(dependsOn *string, allOf, anyOf *[]string, ids map[string]bool) {
+	if ids[*dependsOn] {
+		*dependsOn = ""
+	}
+	// Member lists are shared with snapshots and the config, so replace
+	// them rather than editing them in place
+	drop := func(id string) bool { return ids[id] }
+	*allOf = slices.DeleteFunc(slices.Clone(*allOf), drop)
+	*anyOf = slices.DeleteFunc(slices.Clone(*anyOf), drop)
+}
+
+// renameReferencesLocked points every depends_on and composite member
+// naming oldID at newID instead, so changing a check's ID doesn't orphan the
+// checks that depend on it. Clearing an ID leaves its references alone.
+func (s *State) renameReferencesLocked(oldID, newID string) {
+	if oldID == "" || newID == "" || oldID == newID {
+		return
+	}
+	// Member lists are shared with snapshots and the config, so replace
+	// them rather than editing them in place
+	renameIn := func(ids *[]string) bool {
+		if !slices.Contains(*ids, oldID) {
+			return false
+		}
+		*ids = slices.Clone(*ids)
+		for i := range *ids {
+			if (*ids)[i] == oldID {
+				(*ids)[i] = newID
+			}
+		}
+		return true
+	}
+	rename := func(dependsOn *string, allOf, anyOf *[]string) bool {
+		changed := *dependsOn == oldID
+		if changed {
+			*dependsOn = newID
+		}
+		changed = renameIn(allOf) || changed
+		return renameIn(anyOf) || changed
+	}
+	n := 0
+	for _, hs := range s.hosts {
+		for i := range hs.Checks {
+			c := &hs.Checks[i]
+			if rename(&c.DependsOn, &c.AllOf, &c.AnyOf) {
+				n++
+			}
+		}
+	}
+	for i := range s.cfg.Hosts {
+		for j := range s.cfg.Hosts[i].Checks {
+			c := &s.cfg.Hosts[i].Checks[j]
+			rename(&c.DependsOn, &c.AllOf, &c.AnyOf)
+		}
+	}
+	if n > 0 {
+		log.Printf("check id %q renamed to %q in %d dependent checks", oldID, newID, n)
+	}
+}
+
+// configCheckLocked returns the config entry of the check at idx of hostName
+func (s *State) configCheckLocked(hostName string, idx int) *config.Check {
+	for i := range s.cfg.Hosts {
+		if s.cfg.Hosts[i].Name == hostName {
+			if idx >= 0 && idx < len(s.cfg.Hosts[i].Checks) {
+				return &s.cfg.Hosts[i].Checks[idx]
+			}
+			return nil
+		}
+	}
+	return nil
+}
+
+// removeChecksLocked deletes the checks in del from the runtime state and
+// the config, keeping the two in step
+func (s *State) removeChecksLocked(del deleteSet) {
+	for host, idxs := range del {
+		if hs, ok := s.hosts[host]; ok {
+			hs.Checks = dropIndices(hs.Checks, idxs)
+		}
+		for i := range s.cfg.Hosts {
+			if s.cfg.Hosts[i].Name == host {
+				s.cfg.Hosts[i].Checks = dropIndices(s.cfg.Hosts[i].Checks, idxs)
+				break
+			}
+		}
+	}
+}
+
+func dropIndices[T any](items []T, idxs []int) []T {
+	out := items[:0]
+	for i, item := range items {
+		if !slices.Contains(idxs, i) {
+			out = append(out, item)
+		}
+	}
+	return out
+}
@@ -0,0 +1,78 @@
+package state
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/andrewsjg/simple-healthchecker/copilot/internal/config"
+)
+
+// checkDisplayTimezone records a warning if the configured display timezone
+// can't be loaded; times are then shown in each browser's own zone
+func (s *State) checkDisplayTimezone() {
+	name := s.cfg.Settings.Display.Timezone
+	if name == "" {
+		return
+	}
+	loc, err := config.LoadTimezone(name)
+	if err != nil {
+		msg := fmt.Sprintf("Display timezone ignored: %v", err)
+		log.Printf("warning: %s", msg)
+		s.warnings = append(s.warnings, msg)
+		return
+	}
+	s.displayLoc = loc
+}
+
+// DisplayTimezone returns the configured display timezone's name, or "" if
+// each browser shows times in its own zone
+func (s *State) DisplayTimezone() string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if s.displayLoc == nil {
+		return ""
+	}
+	return s.displayLoc.String()
+}
+
+// DisplayLocation returns the configured display timezone, falling back to
+// the server's local time. Pages still convert to the browser's zone when
+// none is configured; this is for text rendered without that chance.
+func (s *State) DisplayLocation() *time.Location {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.displayLocationLocked()
+}
+
+func (s *State) displayLocationLocked() *time.Location {
+	if s.displayLoc == nil {
+		return time.Local
+	}
+	return s.displayLoc
+}
+
+// GetDisplaySettings returns the current display settings
+func (s *State) GetDisplaySettings() config.DisplaySettings {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.cfg.Settings.Display
+}
+
+// UpdateDisplaySettings sets the display timezone, which must be empty or a
+// name config.LoadTimezone accepts
+func (s *State) UpdateDisplaySettings(settings config.DisplaySettings) error {
+	var loc *time.Location
+	if settings.Timezone != "" {
+		var err error
+		if loc, err = config.LoadTimezone(settings.Timezone); err != nil {
+			return err
+		}
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.cfg.Settings.Display = settings
+	s.displayLoc = loc
+	return s.saveConfigLocked()
+}
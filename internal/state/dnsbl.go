@@ -0,0 +1,100 @@
+package state
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/andrewsjg/simple-healthchecker/copilot/internal/checks"
+	"github.com/andrewsjg/simple-healthchecker/copilot/internal/config"
+)
+
+// dnsblMessage describes a dnsbl check's result, e.g. "192.0.2.5 is listed
+// on bl.spamcop.net (127.0.0.2)" or "192.0.2.5 is on none of 4 blocklists"
+func dnsblMessage(res checks.DNSBLResult) string {
+	if res.Err != nil {
+		return res.Err.Error()
+	}
+	var msg string
+	if len(res.Listings) > 0 {
+		listed := make([]string, 0, len(res.Listings))
+		for _, l := range res.Listings {
+			s := fmt.Sprintf("%s on %s (%s)", l.IP, l.List, l.Code)
+			if l.Reason != "" {
+				s += ": " + l.Reason
+			}
+			listed = append(listed, s)
+		}
+		msg = "Listed: " + strings.Join(listed, "; ")
+	} else {
+		msg = fmt.Sprintf("%s is on none of %d blocklists", strings.Join(res.IPs, ", "), res.Checked)
+		if res.Checked == 1 {
+			msg = fmt.Sprintf("%s is not on the blocklist", strings.Join(res.IPs, ", "))
+		}
+	}
+	if len(res.Failed) > 0 {
+		msg += fmt.Sprintf("; %s didn't answer", strings.Join(res.Failed, ", "))
+	}
+	return msg
+}
+
+// dnsblDue reports whether a dnsbl check is due to look its addresses up:
+// it hasn't yet, no list answered last time, or checks.DNSBLInterval has
+// passed
+func (c *CheckStatus) dnsblDue(now time.Time) bool {
+	return c.CheckedAt.IsZero() || c.DNSBLChecked == 0 || now.Sub(c.CheckedAt) >= checks.DNSBLInterval
+}
+
+// AddDNSBLCheck appends a dnsbl check looking the host up on opts'
+// blocklists to the named host
+func (s *State) AddDNSBLCheck(hostName string, opts checks.DNSBLOptions, id, dependsOn string, mqttNotify, pushoverNotify, telegramNotify bool) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	hs, ok := s.hosts[hostName]
+	if !ok {
+		return fmt.Errorf("host not found")
+	}
+	if s.checkIDInUseLocked(id, "") {
+		return fmt.Errorf("check id %q already in use", id)
+	}
+	c := config.Check{Type: config.CheckDNSBL, Enabled: true, Blocklists: slices.Clone(opts.Blocklists), ID: id, DependsOn: dependsOn, MQTTNotify: mqttNotify, PushoverNotify: pushoverNotify, TelegramNotify: telegramNotify}
+	hs.Checks = append(hs.Checks, CheckStatus{Type: config.CheckDNSBL, Enabled: true, DNSBLOpts: opts, ID: id, DependsOn: dependsOn, MQTTNotify: mqttNotify, PushoverNotify: pushoverNotify, TelegramNotify: telegramNotify, Severity: config.SeverityWarning})
+	for i := range s.cfg.Hosts {
+		if s.cfg.Hosts[i].Name == hostName {
+			s.cfg.Hosts[i].Checks = append(s.cfg.Hosts[i].Checks, c)
+			break
+		}
+	}
+	s.rebuildCheckIndex()
+	return s.saveConfigLocked()
+}
+
+// SetCheckDNSBL updates the blocklists of the dnsbl check at idx. It looks
+// the host up on them on the next run.
+func (s *State) SetCheckDNSBL(hostName string, idx int, opts checks.DNSBLOptions) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	hs, ok := s.hosts[hostName]
+	if !ok {
+		return fmt.Errorf("host not found")
+	}
+	if idx < 0 || idx >= len(hs.Checks) {
+		return fmt.Errorf("bad index")
+	}
+	c := &hs.Checks[idx]
+	if c.Type != config.CheckDNSBL {
+		return fmt.Errorf("not dnsbl check")
+	}
+	c.DNSBLOpts = opts
+	c.DNSBLChecked, c.DNSBLListings = 0, nil // Due on the next run
+	for i := range s.cfg.Hosts {
+		if s.cfg.Hosts[i].Name == hostName {
+			if idx < len(s.cfg.Hosts[i].Checks) {
+				s.cfg.Hosts[i].Checks[idx].Blocklists = slices.Clone(opts.Blocklists)
+			}
+			break
+		}
+	}
+	return s.saveConfigLocked()
+}
@@ -0,0 +1,111 @@
+package state
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/andrewsjg/simple-healthchecker/copilot/internal/checks"
+	"github.com/andrewsjg/simple-healthchecker/copilot/internal/config"
+)
+
+// domainOptionsFromConfig extracts the domain a domain check looks up and
+// how soon before expiry it fails
+func domainOptionsFromConfig(c config.Check) checks.DomainOptions {
+	return checks.DomainOptions{Domain: c.Domain, WarnDays: c.WarnDays}
+}
+
+// domainName returns the domain a domain check on hs looks up
+func domainName(hs *HostStatus, c *CheckStatus) string {
+	if c.DomainOpts.Domain != "" {
+		return c.DomainOpts.Domain
+	}
+	return hs.Address
+}
+
+// domainMessage describes a domain check's result, e.g. "example.com
+// expires 2027-08-13, in 299 days (Example Registrar)" or "example.com
+// expires in 12 days, on 2026-10-30"
+func domainMessage(res checks.DomainResult, now time.Time) string {
+	if res.Err != nil {
+		return res.Err.Error()
+	}
+	left := res.Expiry.Sub(now)
+	date := res.Expiry.Format(time.DateOnly)
+	var msg string
+	switch {
+	case left <= 0:
+		msg = fmt.Sprintf("%s expired %s ago, on %s", res.Domain, checks.FormatDays(-left), date)
+	case !res.OK:
+		msg = fmt.Sprintf("%s expires in %s, on %s", res.Domain, checks.FormatDays(left), date)
+	default:
+		msg = fmt.Sprintf("%s expires %s, in %s", res.Domain, date, checks.FormatDays(left))
+	}
+	if res.Registrar != "" {
+		msg += " (" + res.Registrar + ")"
+	}
+	for _, s := range res.Status {
+		if s == "redemption period" || s == "pending delete" {
+			msg += "; " + s
+		}
+	}
+	return msg
+}
+
+// domainDue reports whether a domain check is due to look its domain up:
+// it hasn't yet, the last lookup failed, or checks.DomainInterval has passed
+func (c *CheckStatus) domainDue(now time.Time) bool {
+	return c.CheckedAt.IsZero() || c.DomainExpiry.IsZero() || now.Sub(c.CheckedAt) >= checks.DomainInterval
+}
+
+// AddDomainCheck appends a domain check to the named host
+func (s *State) AddDomainCheck(hostName string, opts checks.DomainOptions, id, dependsOn string, mqttNotify, pushoverNotify, telegramNotify bool) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	hs, ok := s.hosts[hostName]
+	if !ok {
+		return fmt.Errorf("host not found")
+	}
+	if s.checkIDInUseLocked(id, "") {
+		return fmt.Errorf("check id %q already in use", id)
+	}
+	c := config.Check{Type: config.CheckDomain, Enabled: true, Domain: opts.Domain, WarnDays: opts.WarnDays, ID: id, DependsOn: dependsOn, MQTTNotify: mqttNotify, PushoverNotify: pushoverNotify, TelegramNotify: telegramNotify}
+	hs.Checks = append(hs.Checks, CheckStatus{Type: config.CheckDomain, Enabled: true, DomainOpts: opts, ID: id, DependsOn: dependsOn, MQTTNotify: mqttNotify, PushoverNotify: pushoverNotify, TelegramNotify: telegramNotify, Severity: config.SeverityWarning})
+	for i := range s.cfg.Hosts {
+		if s.cfg.Hosts[i].Name == hostName {
+			s.cfg.Hosts[i].Checks = append(s.cfg.Hosts[i].Checks, c)
+			break
+		}
+	}
+	s.rebuildCheckIndex()
+	return s.saveConfigLocked()
+}
+
+// SetCheckDomain updates the domain and expiry warning of the domain check
+// at idx. It is looked up again on the next run.
+func (s *State) SetCheckDomain(hostName string, idx int, opts checks.DomainOptions) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	hs, ok := s.hosts[hostName]
+	if !ok {
+		return fmt.Errorf("host not found")
+	}
+	if idx < 0 || idx >= len(hs.Checks) {
+		return fmt.Errorf("bad index")
+	}
+	c := &hs.Checks[idx]
+	if c.Type != config.CheckDomain {
+		return fmt.Errorf("not domain check")
+	}
+	c.DomainOpts = opts
+	c.DomainExpiry = time.Time{} // Due on the next run
+	for i := range s.cfg.Hosts {
+		if s.cfg.Hosts[i].Name == hostName {
+			if idx < len(s.cfg.Hosts[i].Checks) {
+				s.cfg.Hosts[i].Checks[idx].Domain = opts.Domain
+				s.cfg.Hosts[i].Checks[idx].WarnDays = opts.WarnDays
+			}
+			break
+		}
+	}
+	return s.saveConfigLocked()
+}
@@ -0,0 +1,39 @@
+package state
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+)
+
+// EmbedToken returns the token that authorises embedding hostName's status
+// widget, or "" if embeds are open because no embed secret is configured.
+// Tokens are tied to the host name, so one can't be reused for another host,
+// and changing the secret revokes them all.
+func (s *State) EmbedToken(hostName string) string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return embedToken(s.cfg.Settings.Embed.Secret, hostName)
+}
+
+// EmbedsSigned reports whether embeds need a token
+func (s *State) EmbedsSigned() bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.cfg.Settings.Embed.Secret != ""
+}
+
+// CheckEmbedToken reports whether token authorises embedding hostName
+func (s *State) CheckEmbedToken(hostName, token string) bool {
+	want := s.EmbedToken(hostName)
+	return want == "" || hmac.Equal([]byte(token), []byte(want))
+}
+
+func embedToken(secret, hostName string) string {
+	if secret == "" {
+		return ""
+	}
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte("embed:" + hostName))
+	return hex.EncodeToString(mac.Sum(nil))[:32]
+}
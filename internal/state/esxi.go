@@ -0,0 +1,117 @@
+package state
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/andrewsjg/simple-healthchecker/copilot/internal/checks"
+	"github.com/andrewsjg/simple-healthchecker/copilot/internal/config"
+)
+
+// esxiOptionsFromConfig extracts an esxi check's login and what it looks at
+func esxiOptionsFromConfig(c config.Check) checks.ESXiOptions {
+	return checks.ESXiOptions{
+		User:               c.Username,
+		Password:           c.Password,
+		VM:                 c.VM,
+		MaxUsage:           c.MaxUsage,
+		Port:               c.Port,
+		InsecureSkipVerify: c.InsecureSkipVerify,
+	}
+}
+
+// setCfgESXiOptions copies an esxi check's options into its config
+func setCfgESXiOptions(c *config.Check, opts checks.ESXiOptions) {
+	c.Username = opts.User
+	c.Password = opts.Password
+	c.VM = opts.VM
+	c.MaxUsage = opts.MaxUsage
+	c.Port = opts.Port
+	c.InsecureSkipVerify = opts.InsecureSkipVerify
+}
+
+// esxiMessage describes an esxi check's result, e.g. "VMware ESXi 8.0.2
+// build-22380479, 6 of 8 VMs on; Power Supply 2 red"
+func esxiMessage(res checks.ESXiResult, opts checks.ESXiOptions) string {
+	if res.Err != nil {
+		return res.Err.Error()
+	}
+	msg := res.Product
+	if res.Maintenance {
+		msg += " (maintenance mode)"
+	}
+	if opts.VM != "" {
+		msg += fmt.Sprintf(", VM %s %s", opts.VM, res.VMState)
+	} else {
+		msg += fmt.Sprintf(", %d of %d VMs on", res.Running, res.VMs)
+	}
+	problems := append(append([]string(nil), res.Alarms...), res.Warnings...)
+	if res.Status == "red" && len(res.Alarms) == 0 {
+		problems = append([]string{"host status red"}, problems...)
+	}
+	if len(problems) > 0 {
+		msg += "; " + strings.Join(problems, ", ")
+	}
+	return msg
+}
+
+// AddESXiCheck appends an ESXi host check to the named host
+func (s *State) AddESXiCheck(hostName string, opts checks.ESXiOptions, id, dependsOn string, mqttNotify, pushoverNotify, telegramNotify bool) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	hs, ok := s.hosts[hostName]
+	if !ok {
+		return fmt.Errorf("host not found")
+	}
+	if opts.User == "" {
+		return fmt.Errorf("a username is required")
+	}
+	if s.checkIDInUseLocked(id, "") {
+		return fmt.Errorf("check id %q already in use", id)
+	}
+	c := config.Check{Type: config.CheckESXi, Enabled: true, ID: id, DependsOn: dependsOn, MQTTNotify: mqttNotify, PushoverNotify: pushoverNotify, TelegramNotify: telegramNotify}
+	setCfgESXiOptions(&c, opts)
+	hs.Checks = append(hs.Checks, CheckStatus{Type: config.CheckESXi, Enabled: true, ESXiOpts: opts, ID: id, DependsOn: dependsOn, MQTTNotify: mqttNotify, PushoverNotify: pushoverNotify, TelegramNotify: telegramNotify, Severity: config.SeverityWarning})
+	for i := range s.cfg.Hosts {
+		if s.cfg.Hosts[i].Name == hostName {
+			s.cfg.Hosts[i].Checks = append(s.cfg.Hosts[i].Checks, c)
+			break
+		}
+	}
+	s.rebuildCheckIndex()
+	return s.saveConfigLocked()
+}
+
+// SetCheckESXi updates the login and options of the esxi check at idx. An
+// empty password keeps the current one.
+func (s *State) SetCheckESXi(hostName string, idx int, opts checks.ESXiOptions) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	hs, ok := s.hosts[hostName]
+	if !ok {
+		return fmt.Errorf("host not found")
+	}
+	if idx < 0 || idx >= len(hs.Checks) {
+		return fmt.Errorf("bad index")
+	}
+	c := &hs.Checks[idx]
+	if c.Type != config.CheckESXi {
+		return fmt.Errorf("not esxi check")
+	}
+	if opts.User == "" {
+		return fmt.Errorf("a username is required")
+	}
+	if opts.Password == "" {
+		opts.Password = c.ESXiOpts.Password
+	}
+	c.ESXiOpts = opts
+	for i := range s.cfg.Hosts {
+		if s.cfg.Hosts[i].Name == hostName {
+			if idx < len(s.cfg.Hosts[i].Checks) {
+				setCfgESXiOptions(&s.cfg.Hosts[i].Checks[idx], opts)
+			}
+			break
+		}
+	}
+	return s.saveConfigLocked()
+}
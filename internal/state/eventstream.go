@@ -0,0 +1,45 @@
+package state
+
+// eventBuffer is how many events a subscriber can fall behind before it is
+// dropped
+const eventBuffer = 64
+
+// Event subscribers, guarded by eventLogMutex
+var eventSubs = make(map[chan Event]struct{})
+
+// SubscribeEvents returns up to recent of the latest events, oldest first,
+// and a channel that receives every event logged after them. Call cancel
+// when done. A subscriber that falls too far behind has its channel closed
+// rather than holding up the scheduler, and should subscribe again.
+func SubscribeEvents(recent int) (backlog []Event, events <-chan Event, cancel func()) {
+	eventLogMutex.Lock()
+	defer eventLogMutex.Unlock()
+
+	recent = min(max(recent, 0), len(eventLog))
+	backlog = append([]Event(nil), eventLog[len(eventLog)-recent:]...)
+
+	ch := make(chan Event, eventBuffer)
+	eventSubs[ch] = struct{}{}
+	cancel = func() {
+		eventLogMutex.Lock()
+		defer eventLogMutex.Unlock()
+		if _, ok := eventSubs[ch]; ok {
+			delete(eventSubs, ch)
+			close(ch)
+		}
+	}
+	return backlog, ch, cancel
+}
+
+// publishEventLocked sends e to every subscriber. eventLogMutex must be
+// held for writing.
+func publishEventLocked(e Event) {
+	for ch := range eventSubs {
+		select {
+		case ch <- e:
+		default:
+			delete(eventSubs, ch)
+			close(ch)
+		}
+	}
+}
@@ -0,0 +1,40 @@
+package state
+
+import (
+	"fmt"
+	"log"
+	"time"
+)
+
+// SetExpectedDown marks the check at idx as expected to be down until the
+// given time, e.g. for maintenance. Until then its failures show grey, don't
+// alert and are left out of uptime; if it is still down afterwards it alerts
+// as usual. A zero time clears the window. Like mutes, windows are kept in
+// memory only, so a restart clears them.
+func (s *State) SetExpectedDown(hostName string, idx int, until time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	hs, ok := s.hosts[hostName]
+	if !ok {
+		return fmt.Errorf("host not found")
+	}
+	if idx < 0 || idx >= len(hs.Checks) {
+		return fmt.Errorf("bad index")
+	}
+	if !until.IsZero() && !until.After(time.Now()) {
+		return fmt.Errorf("expected downtime must end in the future")
+	}
+	hs.Checks[idx].DownUntil = until
+	if until.IsZero() {
+		log.Printf("check %d on %s no longer expected down", idx, hostName)
+	} else {
+		log.Printf("check %d on %s expected down until %s", idx, hostName, until.Format(time.RFC3339))
+	}
+	return nil
+}
+
+// ExpectedDown reports whether the check is failing within its expected
+// downtime, so it is shown grey rather than down
+func (c CheckStatus) ExpectedDown() bool {
+	return c.Expected && !c.OK
+}
@@ -0,0 +1,102 @@
+package state
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/andrewsjg/simple-healthchecker/copilot/internal/checks"
+	"github.com/andrewsjg/simple-healthchecker/copilot/internal/config"
+)
+
+// fileOptionsFromConfig extracts a file check's path, age limit and SFTP login
+func fileOptionsFromConfig(c config.Check) checks.FileOptions {
+	return checks.FileOptions{
+		Path:    c.Path,
+		MaxAge:  c.MaxAgeLimit(),
+		SFTP:    c.SFTP,
+		User:    c.SSHUser,
+		KeyFile: c.SSHKey,
+		Port:    c.Port,
+	}
+}
+
+// setCfgFileOptions copies a file check's options into its config. The age
+// limit is kept as written, e.g. "25h", so it is passed separately.
+func setCfgFileOptions(c *config.Check, opts checks.FileOptions, maxAge string) {
+	c.Path = opts.Path
+	c.MaxAge = maxAge
+	c.SFTP = opts.SFTP
+	c.SSHUser = opts.User
+	c.SSHKey = opts.KeyFile
+	c.Port = opts.Port
+}
+
+// fileMessage describes a file check's result, e.g. "backup-1017.tar.gz
+// modified 3h ago" or "... 26h ago (max 25h)"
+func fileMessage(res checks.FileResult, opts checks.FileOptions, now time.Time) string {
+	if res.Err != nil {
+		return res.Err.Error()
+	}
+	msg := fmt.Sprintf("%s modified %s ago", res.Name, formatAge(max(now.Sub(res.ModTime), 0)))
+	if !res.OK {
+		msg += fmt.Sprintf(" (max %s)", formatAge(opts.MaxAge))
+	}
+	return msg
+}
+
+// AddFileCheck appends a file freshness check to the named host. maxAge is
+// the age limit as written, e.g. "25h", and is required.
+func (s *State) AddFileCheck(hostName string, opts checks.FileOptions, maxAge, id, dependsOn string, mqttNotify, pushoverNotify, telegramNotify bool) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	hs, ok := s.hosts[hostName]
+	if !ok {
+		return fmt.Errorf("host not found")
+	}
+	if strings.TrimSpace(opts.Path) == "" || maxAge == "" {
+		return fmt.Errorf("a file check needs a path and a max age")
+	}
+	if s.checkIDInUseLocked(id, "") {
+		return fmt.Errorf("check id %q already in use", id)
+	}
+	c := config.Check{Type: config.CheckFile, Enabled: true, ID: id, DependsOn: dependsOn, MQTTNotify: mqttNotify, PushoverNotify: pushoverNotify, TelegramNotify: telegramNotify}
+	setCfgFileOptions(&c, opts, maxAge)
+	hs.Checks = append(hs.Checks, CheckStatus{Type: config.CheckFile, Enabled: true, FileOpts: fileOptionsFromConfig(c), ID: id, DependsOn: dependsOn, MQTTNotify: mqttNotify, PushoverNotify: pushoverNotify, TelegramNotify: telegramNotify, Severity: config.SeverityWarning})
+	for i := range s.cfg.Hosts {
+		if s.cfg.Hosts[i].Name == hostName {
+			s.cfg.Hosts[i].Checks = append(s.cfg.Hosts[i].Checks, c)
+			break
+		}
+	}
+	s.rebuildCheckIndex()
+	return s.saveConfigLocked()
+}
+
+// SetCheckFile updates the path, age limit and SFTP login of the file check at idx
+func (s *State) SetCheckFile(hostName string, idx int, opts checks.FileOptions, maxAge string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	hs, ok := s.hosts[hostName]
+	if !ok {
+		return fmt.Errorf("host not found")
+	}
+	if idx < 0 || idx >= len(hs.Checks) {
+		return fmt.Errorf("bad index")
+	}
+	if hs.Checks[idx].Type != config.CheckFile {
+		return fmt.Errorf("not file check")
+	}
+	var c config.Check
+	setCfgFileOptions(&c, opts, maxAge)
+	hs.Checks[idx].FileOpts = fileOptionsFromConfig(c)
+	for i := range s.cfg.Hosts {
+		if s.cfg.Hosts[i].Name == hostName {
+			if idx < len(s.cfg.Hosts[i].Checks) {
+				setCfgFileOptions(&s.cfg.Hosts[i].Checks[idx], opts, maxAge)
+			}
+			break
+		}
+	}
+	return s.saveConfigLocked()
+}
@@ -0,0 +1,115 @@
+package state
+
+import (
+	"fmt"
+	"sort"
+	"time"
+)
+
+// noteFlipLocked records that the check at idx changed state and starts a
+// flap if it has now changed too often. wasDown is its state before the
+// change, which is what its last alert said when a flap starts.
+func (s *State) noteFlipLocked(hs *HostStatus, idx int, now time.Time, wasDown bool) {
+	c := &hs.Checks[idx]
+	threshold, window := s.cfg.Settings.Alerts.Flap()
+	if threshold == 0 {
+		c.flips = nil
+		return
+	}
+	c.flips = append(recentFlips(c.flips, now, window), now)
+	if c.Flapping || len(c.flips) <= threshold {
+		return
+	}
+	c.Flapping = true
+	c.flapDown = wasDown
+	logEvent(Event{
+		Timestamp: now,
+		HostName:  hs.Name,
+		CheckIdx:  idx,
+		CheckID:   c.ID,
+		CheckName: c.Name,
+		CheckType: c.Type,
+		EventType: "flapping",
+		Message:   fmt.Sprintf("Changed state %d times in %v; alerts held until it settles", len(c.flips), window),
+	})
+}
+
+// settleFlapLocked ends the flap of the check at idx once it has changed
+// state no more than the threshold within the window. If it settled in a
+// different state from its last alert, that state is alerted now.
+func (s *State) settleFlapLocked(hs *HostStatus, idx int, now time.Time) {
+	c := &hs.Checks[idx]
+	if !c.Flapping {
+		return
+	}
+	threshold, window := s.cfg.Settings.Alerts.Flap()
+	c.flips = recentFlips(c.flips, now, window)
+	if threshold > 0 && len(c.flips) > threshold {
+		return
+	}
+	c.Flapping = false
+	down := !c.OK && !c.ParentFailed
+	if down == c.flapDown {
+		return
+	}
+	if down {
+		s.dispatchAlert(hs, c, "down", nil, s.blockedByLocked(c))
+	} else {
+		s.dispatchAlert(hs, c, "up", nil, nil)
+	}
+}
+
+// recentFlips drops the state changes that fall outside the window
+func recentFlips(flips []time.Time, now time.Time, window time.Duration) []time.Time {
+	cutoff := now.Add(-window)
+	i := sort.Search(len(flips), func(i int) bool { return flips[i].After(cutoff) })
+	return flips[i:]
+}
+
+// FlapStat counts a check's state changes over the event log
+type FlapStat struct {
+	Host     string
+	Idx      int
+	Label    string
+	Changes  int  // Down and recovered events
+	Flapping bool // Flapping right now
+}
+
+// Flappiest returns the checks that changed state most often over the event
+// log, most changes first, leaving out those that changed less than twice
+func (s *State) Flappiest(limit int) []FlapStat {
+	type key struct {
+		host string
+		idx  int
+	}
+	counts := make(map[key]int)
+	eventLogMutex.RLock()
+	for _, e := range eventLog {
+		if e.CheckType != "" && (e.EventType == "down" || e.EventType == "recovered") {
+			counts[key{e.HostName, e.CheckIdx}]++
+		}
+	}
+	eventLogMutex.RUnlock()
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	var out []FlapStat
+	for k, n := range counts {
+		hs, ok := s.hosts[k.host]
+		if n < 2 || !ok || k.idx < 0 || k.idx >= len(hs.Checks) {
+			continue
+		}
+		c := &hs.Checks[k.idx]
+		out = append(out, FlapStat{Host: k.host, Idx: k.idx, Label: checkLabel(hs, c), Changes: n, Flapping: c.Flapping})
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Changes != out[j].Changes {
+			return out[i].Changes > out[j].Changes
+		}
+		return out[i].Label < out[j].Label
+	})
+	if limit > 0 && len(out) > limit {
+		out = out[:limit]
+	}
+	return out
+}
@@ -0,0 +1,102 @@
+package state
+
+import (
+	"fmt"
+	"slices"
+	"time"
+)
+
+// minNoDataGap is the shortest stretch without runs treated as a gap, so
+// short intervals don't turn a brief stall into one
+const minNoDataGap = time.Minute
+
+// maxNoDataSpans caps the no-data stretches kept. They're kept apart from
+// the event log, whose cap a busy hour can reach, so a gap still comes off
+// the outages and uptime it overlaps after its event has been dropped.
+const maxNoDataSpans = 1000
+
+// noDataSpan is a stretch the monitor recorded nothing
+type noDataSpan struct {
+	start, end time.Time
+}
+
+// No-data stretches, oldest first, guarded by eventLogMutex
+var noDataLog []noDataSpan
+
+// noteGapLocked checks how long it has been, by the wall clock, since the
+// last run finished. Runs normally start within an interval of it, even
+// after one overran, so more than two intervals means the scheduler didn't
+// run at all: the machine was asleep, as laptops running the menu bar app
+// are, or the clock jumped ahead. The stretch is logged as a no_data event
+// and marked on each check's next data point; outage and reliability
+// figures leave it out rather than counting it as up or down. The event has
+// no host name, which no host can have, so it can't be taken for one.
+func (s *State) noteGapLocked(now time.Time) {
+	last, interval := s.ticks.lastEnd, s.ticks.Interval
+	if last.IsZero() || interval <= 0 {
+		return
+	}
+	wallNow := time.Now()
+	// Round(0) drops the monotonic reading, which stops while suspended
+	wall := wallNow.Round(0).Sub(last.Round(0))
+	// A clock that went back is seen to by runTimeLocked
+	if wall <= 2*interval || wall < minNoDataGap {
+		return
+	}
+	why := "the scheduler didn't run"
+	if wallNow.Sub(last) < wall/2 {
+		why = "the monitor was asleep, or its clock jumped ahead"
+	}
+	s.ticks.current.Gap = wall
+	s.ticks.Gaps++
+	s.ticks.GapTime += wall
+	eventLogMutex.Lock()
+	noDataLog = append(noDataLog, noDataSpan{start: now.UTC().Add(-wall), end: now.UTC()})
+	if len(noDataLog) > maxNoDataSpans {
+		noDataLog = noDataLog[1:]
+	}
+	eventLogMutex.Unlock()
+	logEvent(Event{
+		Timestamp: now,
+		EventType: "no_data",
+		Message:   fmt.Sprintf("No data for %v: %s; it doesn't count towards uptime or downtime", wall.Round(time.Second), why),
+		Duration:  wall,
+	})
+	for _, hs := range s.hosts {
+		for i := range hs.Checks {
+			hs.Checks[i].gapBefore = wall
+		}
+	}
+}
+
+// noDataSpansLocked returns the no-data stretches, oldest first. The caller
+// holds eventLogMutex.
+func noDataSpansLocked() []noDataSpan {
+	return slices.Clone(noDataLog)
+}
+
+// noDataWithin returns how much of from to to falls in spans
+func noDataWithin(spans []noDataSpan, from, to time.Time) time.Duration {
+	var d time.Duration
+	for _, sp := range spans {
+		start, end := sp.start, sp.end
+		if start.Before(from) {
+			start = from
+		}
+		if end.After(to) {
+			end = to
+		}
+		if end.After(start) {
+			d += end.Sub(start)
+		}
+	}
+	return d
+}
+
+// noDataBetween returns how much of from to to the monitor recorded
+// nothing, so the time a check was down can leave it out
+func noDataBetween(from, to time.Time) time.Duration {
+	eventLogMutex.RLock()
+	defer eventLogMutex.RUnlock()
+	return noDataWithin(noDataSpansLocked(), from, to)
+}
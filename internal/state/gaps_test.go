@@ -0,0 +1,57 @@
+package state
+
+import (
+	"testing"
+	"time"
+
+	"github.com/andrewsjg/simple-healthchecker/copilot/internal/config"
+)
+
+func TestNoDataOutlastsTheEventLog(t *testing.T) {
+	eventLogMutex.Lock()
+	savedLog, savedSpans := eventLog, noDataLog
+	eventLog, noDataLog = nil, nil
+	eventLogMutex.Unlock()
+	t.Cleanup(func() {
+		eventLogMutex.Lock()
+		eventLog, noDataLog = savedLog, savedSpans
+		eventLogMutex.Unlock()
+	})
+
+	st, _ := newFakeState(&config.Config{Hosts: []config.Host{
+		{Name: "Monitor", Address: "10.0.0.1", Checks: []config.Check{{Type: config.CheckPing, Enabled: true}}},
+	}})
+	now := time.Now()
+	st.ticks.Interval = time.Minute
+	st.ticks.lastEnd = now.Add(-time.Hour)
+	st.beginTickStatsLocked(now, "", false)
+	st.noteGapLocked(now)
+
+	events := GetEvents(0)
+	if len(events) != 1 || events[0].EventType != "no_data" {
+		t.Fatalf("events = %+v, want a no_data event", events)
+	}
+	// A host can be called Monitor, so the monitor's own events have no host
+	if events[0].HostName != "" {
+		t.Errorf("no_data host = %q, want none", events[0].HostName)
+	}
+	if got := eventTypes("Monitor"); len(got) != 0 {
+		t.Errorf("host Monitor has events %q, want none", got)
+	}
+
+	// Push the no_data event out of the event log
+	for range maxEvents {
+		logEvent(Event{Timestamp: now, HostName: "Monitor", EventType: "down"})
+	}
+	for _, e := range GetEvents(0) {
+		if e.EventType == "no_data" {
+			t.Fatal("the no_data event is still in the log")
+		}
+	}
+	if got := noDataBetween(now.Add(-2*time.Hour), now); got < time.Hour-time.Second || got > time.Hour+time.Second {
+		t.Errorf("no data for %v, want an hour", got)
+	}
+	if got := noDataBetween(now.Add(-30*time.Minute), now.Add(time.Hour)); got < 30*time.Minute-time.Second || got > 30*time.Minute+time.Second {
+		t.Errorf("no data for %v of the last half hour, want all of it", got)
+	}
+}
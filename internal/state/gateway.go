@@ -0,0 +1,220 @@
+package state
+
+import (
+	"fmt"
+	"log"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/andrewsjg/simple-healthchecker/copilot/internal/mqtt"
+)
+
+// minConnectivityHosts is how many hosts must be failing together before
+// the failure is put down to local connectivity rather than real outages
+const minConnectivityHosts = 3
+
+// gatewayLocked returns the designated gateway host and its first enabled
+// check, which stands for the gateway in dependency checks. It returns nils
+// if no gateway is set or it has no enabled checks.
+func (s *State) gatewayLocked() (*HostStatus, *CheckStatus) {
+	for _, h := range s.cfg.Hosts {
+		hs, ok := s.hosts[h.Name]
+		if !ok || !hs.Gateway {
+			continue
+		}
+		for i := range hs.Checks {
+			if hs.Checks[i].Enabled {
+				return hs, &hs.Checks[i]
+			}
+		}
+		return hs, nil
+	}
+	return nil, nil
+}
+
+// parentLocked returns the check c depends on: its depends_on target if it
+// has one, otherwise the gateway's check unless c is on the gateway host.
+// It returns nil for checks with no dependency.
+func (s *State) parentLocked(c *CheckStatus) *CheckStatus {
+	if c.DependsOn != "" {
+		return s.checksByID[c.DependsOn]
+	}
+	gw, gwCheck := s.gatewayLocked()
+	if gwCheck == nil {
+		return nil
+	}
+	for i := range gw.Checks {
+		if &gw.Checks[i] == c {
+			return nil
+		}
+	}
+	return gwCheck
+}
+
+// parentLabelLocked names c's parent for the dashboard's "blocked" badge
+func (s *State) parentLabelLocked(c *CheckStatus) string {
+	if c.DependsOn != "" {
+		return c.DependsOn
+	}
+	if s.parentLocked(c) == nil {
+		return ""
+	}
+	gw, _ := s.gatewayLocked()
+	return gw.Name + " (gateway)"
+}
+
+// SetHostGateway makes hostName the gateway, or clears it. There is at most
+// one gateway, so designating a host clears the flag on any other.
+func (s *State) SetHostGateway(hostName string, gateway bool) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	hs, ok := s.hosts[hostName]
+	if !ok {
+		return fmt.Errorf("host not found")
+	}
+	if hs.Gateway == gateway {
+		return nil
+	}
+	for name, other := range s.hosts {
+		other.Gateway = gateway && name == hostName
+	}
+	for i := range s.cfg.Hosts {
+		s.cfg.Hosts[i].Gateway = gateway && s.cfg.Hosts[i].Name == hostName
+	}
+	return s.saveConfigLocked()
+}
+
+// ConnectivityDownSince returns when every host started failing at once,
+// or the zero time if that isn't happening
+func (s *State) ConnectivityDownSince() time.Time {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.connectivityDown
+}
+
+// allDownLocked reports whether every host that has run a check is failing
+// all of its enabled checks, across at least minConnectivityHosts hosts
+func (s *State) allDownLocked() bool {
+	hosts := 0
+	for _, hs := range s.hosts {
+		checked := false
+		for i := range hs.Checks {
+			c := &hs.Checks[i]
+			if !c.Enabled || c.OffSchedule || c.ExpectedDown() || c.Pending() {
+				continue
+			}
+			if c.OK {
+				return false
+			}
+			checked = true
+		}
+		if checked {
+			hosts++
+		}
+	}
+	return hosts >= minConnectivityHosts
+}
+
+// reportConnectivityLocked spots every host failing at once and reports it
+// as one probable local connectivity problem. roots are the checks that
+// went down this run with no failing parent. It returns true if their
+// individual alerts should be held back.
+func (s *State) reportConnectivityLocked(now time.Time, roots []newDown) bool {
+	allDown := s.allDownLocked()
+	switch {
+	case allDown && s.connectivityDown.IsZero() && len(roots) > 1:
+		s.connectivityDown = now
+		var labels []string
+		for _, d := range roots {
+			c := &d.hs.Checks[d.idx]
+			c.alertSuppressed = true
+			labels = append(labels, checkLabel(d.hs, c))
+		}
+		slices.Sort(labels)
+		msg := fmt.Sprintf("%d checks failed at once; probable local connectivity issue", len(roots))
+		logEvent(Event{Timestamp: now, HostName: "All hosts", EventType: "connectivity", Message: msg, Blocked: labels})
+		text := msg + ". This is more likely a problem with this monitor's own network than separate outages.\n\nAffected: " + strings.Join(labels, ", ")
+		s.sendNoticeLocked(mqtt.ConnectivityMessage{Timestamp: time.Now(), Status: "down", Message: msg, Checks: labels},
+			"📡 Probable local connectivity issue", text)
+		return true
+
+	case allDown && !s.connectivityDown.IsZero():
+		// Part of the same incident
+		for _, d := range roots {
+			d.hs.Checks[d.idx].alertSuppressed = true
+		}
+		return true
+
+	case !allDown && !s.connectivityDown.IsZero():
+		downtime := now.Sub(s.connectivityDown).Round(time.Second)
+		s.connectivityDown = time.Time{}
+		msg := fmt.Sprintf("Checks are passing again after %v", downtime)
+		logEvent(Event{Timestamp: now, HostName: "All hosts", EventType: "recovered", Message: msg, Duration: downtime})
+		s.sendNoticeLocked(mqtt.ConnectivityMessage{Timestamp: time.Now(), Status: "up", Message: msg},
+			"📡 Connectivity restored", msg+".")
+
+		// Anything still down now is a real outage that was held back
+		for _, hs := range s.hosts {
+			for i := range hs.Checks {
+				c := &hs.Checks[i]
+				if !c.alertSuppressed {
+					continue
+				}
+				c.alertSuppressed = false
+				if c.Enabled && !c.OffSchedule && !c.OK && !c.ParentFailed {
+					blocked := s.blockedByLocked(c)
+					logEvent(Event{Timestamp: now, HostName: hs.Name, CheckIdx: i, CheckID: c.ID, CheckName: c.Name, CheckType: c.Type, EventType: "down", Message: c.Message, Blocked: blocked})
+					s.dispatchAlert(hs, c, "down", nil, blocked)
+				}
+			}
+		}
+	}
+	return false
+}
+
+// sendNoticeLocked sends a notice that isn't about one check to each channel
+// that any check uses. MQTT gets m on <topic>/connectivity.
+func (s *State) sendNoticeLocked(m mqtt.ConnectivityMessage, title, text string) {
+	var mqttOn, pushoverOn, telegramOn, smsOn bool
+	var shoutrrrLabels []string
+	for _, hs := range s.hosts {
+		for _, c := range hs.Checks {
+			mqttOn = mqttOn || c.MQTTNotify
+			pushoverOn = pushoverOn || c.PushoverNotify
+			telegramOn = telegramOn || c.TelegramNotify
+			smsOn = smsOn || c.SMSNotify
+			for _, label := range c.ShoutrrrNotify {
+				if !slices.Contains(shoutrrrLabels, label) {
+					shoutrrrLabels = append(shoutrrrLabels, label)
+				}
+			}
+		}
+	}
+
+	if mqttOn && s.mqttClient != nil && !s.channelMutedLocked(ChannelMQTT, "connectivity") {
+		if err := s.notifyTraced(ChannelMQTT, "notice", func() error { return s.mqttClient.PublishConnectivity(m) }); err != nil {
+			log.Printf("MQTT publish error: %v", err)
+		}
+	}
+	if pushoverOn && s.pushoverClient != nil && !s.channelMutedLocked(ChannelPushover, "connectivity") {
+		if err := s.notifyTraced(ChannelPushover, "notice", func() error { return s.pushoverClient.SendNotice(title, text) }); err != nil {
+			log.Printf("Pushover error: %v", err)
+		}
+	}
+	if telegramOn && s.telegramClient != nil && !s.channelMutedLocked(ChannelTelegram, "connectivity") {
+		if err := s.notifyTraced(ChannelTelegram, "notice", func() error { return s.telegramClient.SendNotice(title, text) }); err != nil {
+			log.Printf("Telegram error: %v", err)
+		}
+	}
+	if len(shoutrrrLabels) > 0 && s.shoutrrrClient != nil && !s.channelMutedLocked(ChannelShoutrrr, "connectivity") {
+		if err := s.notifyTraced(ChannelShoutrrr, "notice", func() error { return s.shoutrrrClient.SendNotice(shoutrrrLabels, title, text) }); err != nil {
+			log.Printf("Shoutrrr error: %v", err)
+		}
+	}
+	if smsOn && s.twilioClient != nil && !s.channelMutedLocked(ChannelSMS, "connectivity") {
+		if err := s.notifyTraced(ChannelSMS, "notice", func() error { return s.twilioClient.SendNotice(title, text) }); err != nil {
+			log.Printf("Twilio error: %v", err)
+		}
+	}
+}
@@ -0,0 +1,17 @@
+package state
+
+import "time"
+
+// inGraceLocked reports whether now falls within the startup grace period,
+// when failures are likely the monitor's own network still coming up after
+// a reboot rather than real outages
+func (s *State) inGraceLocked(now time.Time) bool {
+	grace := s.cfg.Settings.Alerts.Grace()
+	return grace > 0 && now.Sub(s.started) < grace
+}
+
+// Pending reports whether the check has no result to show yet: it has never
+// run, or it is failing within the startup grace period
+func (c CheckStatus) Pending() bool {
+	return c.CheckedAt.IsZero() || c.Warmup
+}
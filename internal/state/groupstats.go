@@ -0,0 +1,57 @@
+package state
+
+import (
+	"fmt"
+	"sort"
+)
+
+// UntaggedGroup names the group of hosts without tags in GetGroupedStats
+const UntaggedGroup = "untagged"
+
+// GroupStats is the health of one group of hosts
+type GroupStats struct {
+	Name  string
+	Stats AggregateStats
+}
+
+// GetGroupedStats breaks the aggregate statistics down by "tag" or by "host".
+// Tags are sorted by name with untagged hosts last, and a host with several
+// tags counts towards each of them; hosts are in config order.
+func (s *State) GetGroupedStats(by string) ([]GroupStats, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	switch by {
+	case "host":
+		out := make([]GroupStats, 0, len(s.cfg.Hosts))
+		for _, h := range s.cfg.Hosts {
+			if hs, ok := s.hosts[h.Name]; ok {
+				out = append(out, GroupStats{Name: hs.Name, Stats: aggregate([]*HostStatus{hs})})
+			}
+		}
+		return out, nil
+	case "tag":
+		groups := make(map[string][]*HostStatus)
+		for _, hs := range s.hosts {
+			if len(hs.Tags) == 0 {
+				groups[UntaggedGroup] = append(groups[UntaggedGroup], hs)
+				continue
+			}
+			for _, tag := range hs.Tags {
+				groups[tag] = append(groups[tag], hs)
+			}
+		}
+		out := make([]GroupStats, 0, len(groups))
+		for name, hosts := range groups {
+			out = append(out, GroupStats{Name: name, Stats: aggregate(hosts)})
+		}
+		sort.Slice(out, func(i, j int) bool {
+			if (out[i].Name == UntaggedGroup) != (out[j].Name == UntaggedGroup) {
+				return out[j].Name == UntaggedGroup
+			}
+			return out[i].Name < out[j].Name
+		})
+		return out, nil
+	}
+	return nil, fmt.Errorf("unknown grouping %q: use tag or host", by)
+}
@@ -0,0 +1,383 @@
+package state
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/andrewsjg/simple-healthchecker/copilot/internal/config"
+)
+
+// maxRevisions is how many versions of the config the change history keeps
+const maxRevisions = 200
+
+// Revision is one saved version of the config, kept so a change can be
+// traced to who made it and undone
+type Revision struct {
+	ID      int             `json:"id"`
+	Time    time.Time       `json:"time"`
+	Actor   string          `json:"actor,omitempty"`   // Who made the change; empty for the monitor itself
+	Action  string          `json:"action,omitempty"`  // What made it, e.g. "POST /silence-all"
+	Summary string          `json:"summary,omitempty"` // The first few changed paths
+	Changes int             `json:"changes"`           // How many paths changed since the previous revision
+	Config  json.RawMessage `json:"config"`            // The local hosts and settings as saved
+}
+
+// ConfigChange is one difference between two revisions. Old is empty for
+// something added and New for something removed.
+type ConfigChange struct {
+	Path     string // e.g. "hosts[NAS].checks[0].url"
+	Old, New string
+}
+
+// configHistory holds the saved revisions and where they are kept
+type configHistory struct {
+	path      string // JSON lines next to the config file
+	revisions []Revision
+}
+
+// change is who a config save is put down to in the history: the actor
+// and what they did. It is empty for the monitor itself.
+type change struct {
+	actor, action string
+}
+
+// As returns a State whose config saves are put down to actor, doing
+// action, in the change history. It shares everything else with s, so a
+// request can make its changes through it without holding anyone else up.
+func (s *State) As(actor, action string) *State {
+	return &State{shared: s.shared, change: change{actor: actor, action: action}}
+}
+
+// historySnapshotLocked is what a revision records: the local hosts and the
+// settings, as they are written to the config
+func (s *State) historySnapshotLocked() json.RawMessage {
+	b, _ := json.Marshal(config.Config{Hosts: s.localHostsLocked(), Settings: s.cfg.Settings})
+	return b
+}
+
+// loadHistoryLocked reads the history kept next to the config file, and
+// records the config as loaded if it differs from the last revision (it was
+// edited by hand, or there is no history yet)
+func (s *State) loadHistoryLocked() {
+	h := &s.history
+	h.path = s.configPath + ".history.jsonl"
+	h.revisions = nil
+	if f, err := os.Open(h.path); err == nil {
+		sc := bufio.NewScanner(f)
+		sc.Buffer(make([]byte, 64*1024), 16<<20)
+		for sc.Scan() {
+			var rev Revision
+			if json.Unmarshal(sc.Bytes(), &rev) == nil {
+				h.revisions = append(h.revisions, rev)
+			}
+		}
+		f.Close()
+	}
+	s.recordRevisionLocked("", "loaded from file")
+}
+
+// recordRevisionLocked adds the current config to the history if it has
+// changed since the last revision
+func (s *State) recordRevisionLocked(actor, action string) {
+	h := &s.history
+	if h.path == "" {
+		return
+	}
+	snap := s.historySnapshotLocked()
+	rev := Revision{ID: 1, Time: time.Now().UTC(), Actor: actor, Action: action, Config: snap}
+	if n := len(h.revisions); n > 0 {
+		last := h.revisions[n-1]
+		if bytes.Equal(last.Config, snap) {
+			return
+		}
+		rev.ID = last.ID + 1
+		changes := diffConfigs(last.Config, snap)
+		rev.Changes = len(changes)
+		rev.Summary = summarizeChanges(changes)
+	} else {
+		rev.Summary = "first recorded version"
+	}
+	h.revisions = append(h.revisions, rev)
+	if len(h.revisions) > maxRevisions {
+		h.revisions = slices.Clone(h.revisions[len(h.revisions)-maxRevisions:])
+		h.rewriteLocked()
+		return
+	}
+	// The snapshot holds the same secrets as the config, so the file is
+	// kept private
+	f, err := os.OpenFile(h.path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0600)
+	if err != nil {
+		log.Printf("change history: %v", err)
+		return
+	}
+	defer f.Close()
+	line, _ := json.Marshal(rev)
+	if _, err := f.Write(append(line, '\n')); err != nil {
+		log.Printf("change history: %v", err)
+	}
+}
+
+// rewriteLocked writes the whole history again, after old revisions are
+// dropped
+func (h *configHistory) rewriteLocked() {
+	var b bytes.Buffer
+	for _, rev := range h.revisions {
+		line, _ := json.Marshal(rev)
+		b.Write(append(line, '\n'))
+	}
+	tmp := h.path + ".tmp"
+	if err := os.WriteFile(tmp, b.Bytes(), 0600); err != nil {
+		log.Printf("change history: %v", err)
+		return
+	}
+	if err := os.Rename(tmp, h.path); err != nil {
+		log.Printf("change history: %v", err)
+	}
+}
+
+// recordSaveLocked records a revision for a config save, put down to whoever
+// s was made for by As
+func (s *State) recordSaveLocked() {
+	s.recordRevisionLocked(s.change.actor, s.change.action)
+}
+
+// Revisions returns the change history, newest first, without the configs
+func (s *State) Revisions() []Revision {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	out := make([]Revision, 0, len(s.history.revisions))
+	for i := len(s.history.revisions) - 1; i >= 0; i-- {
+		rev := s.history.revisions[i]
+		rev.Config = nil
+		out = append(out, rev)
+	}
+	return out
+}
+
+// RevisionChanges returns revision id and what changed in it since the
+// revision before. The changes are nil for the oldest revision kept.
+func (s *State) RevisionChanges(id int) (Revision, []ConfigChange, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	revs := s.history.revisions
+	i := slices.IndexFunc(revs, func(r Revision) bool { return r.ID == id })
+	if i < 0 {
+		return Revision{}, nil, false
+	}
+	if i == 0 {
+		return revs[i], nil, true
+	}
+	return revs[i], diffConfigs(revs[i-1].Config, revs[i].Config), true
+}
+
+// RevisionHosts returns revision id and the local hosts as they were then
+func (s *State) RevisionHosts(id int) (Revision, []config.Host, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	for _, rev := range s.history.revisions {
+		if rev.ID == id {
+			var cfg config.Config
+			if err := json.Unmarshal(rev.Config, &cfg); err != nil {
+				return rev, nil, fmt.Errorf("version %d: %w", id, err)
+			}
+			return rev, cfg.Hosts, nil
+		}
+	}
+	return Revision{}, nil, fmt.Errorf("version %d is no longer in the history", id)
+}
+
+// diffConfigs lists what changed between two config snapshots
+func diffConfigs(old, new json.RawMessage) []ConfigChange {
+	var a, b any
+	_ = json.Unmarshal(old, &a)
+	_ = json.Unmarshal(new, &b)
+	var out []ConfigChange
+	diffValues(&out, "", a, b)
+	return out
+}
+
+func diffValues(out *[]ConfigChange, path string, a, b any) {
+	switch {
+	case a == nil && b == nil:
+		return
+	case a == nil:
+		*out = append(*out, ConfigChange{Path: path, New: describeValue(path, b)})
+		return
+	case b == nil:
+		*out = append(*out, ConfigChange{Path: path, Old: describeValue(path, a)})
+		return
+	}
+	switch av := a.(type) {
+	case map[string]any:
+		bv, ok := b.(map[string]any)
+		if !ok {
+			break
+		}
+		keys := make([]string, 0, len(av)+len(bv))
+		for k := range av {
+			keys = append(keys, k)
+		}
+		for k := range bv {
+			if _, ok := av[k]; !ok {
+				keys = append(keys, k)
+			}
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			diffValues(out, joinPath(path, k), av[k], bv[k])
+		}
+		return
+	case []any:
+		bv, ok := b.([]any)
+		if !ok {
+			break
+		}
+		// Lists of named things (hosts) are matched by name, so deleting
+		// one doesn't show every later one as changed
+		if an, bn := byName(av), byName(bv); an != nil && bn != nil {
+			names := make([]string, 0, len(an)+len(bn))
+			for _, item := range av {
+				names = append(names, itemName(item))
+			}
+			for _, item := range bv {
+				if _, ok := an[itemName(item)]; !ok {
+					names = append(names, itemName(item))
+				}
+			}
+			for _, name := range names {
+				diffValues(out, fmt.Sprintf("%s[%s]", path, name), an[name], bn[name])
+			}
+			return
+		}
+		for i := 0; i < max(len(av), len(bv)); i++ {
+			var x, y any
+			if i < len(av) {
+				x = av[i]
+			}
+			if i < len(bv) {
+				y = bv[i]
+			}
+			diffValues(out, fmt.Sprintf("%s[%d]", path, i), x, y)
+		}
+		return
+	}
+	// Compared before describing, so a changed secret still shows, hidden
+	x, _ := json.Marshal(a)
+	y, _ := json.Marshal(b)
+	if !bytes.Equal(x, y) {
+		*out = append(*out, ConfigChange{Path: path, Old: describeValue(path, a), New: describeValue(path, b)})
+	}
+}
+
+// byName indexes a list of objects by their "name", or returns nil if they
+// aren't all uniquely named objects
+func byName(items []any) map[string]any {
+	m := make(map[string]any, len(items))
+	for _, item := range items {
+		name := itemName(item)
+		if name == "" {
+			return nil
+		}
+		if _, dup := m[name]; dup {
+			return nil
+		}
+		m[name] = item
+	}
+	return m
+}
+
+func itemName(item any) string {
+	obj, _ := item.(map[string]any)
+	name, _ := obj["name"].(string)
+	return name
+}
+
+func joinPath(path, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
+
+// describeValue renders a value for the history, hiding secrets and
+// shortening whole hosts or sections to their JSON's first line
+func describeValue(path string, v any) string {
+	if isSecretPath(path) {
+		if s, ok := v.(string); ok && s == "" {
+			return `""`
+		}
+		return "(hidden)"
+	}
+	b, _ := json.Marshal(redact(v))
+	s := string(b)
+	if len(s) > 120 {
+		s = s[:117] + "..."
+	}
+	return s
+}
+
+// redact masks the credentials in a whole host or settings section
+func redact(v any) any {
+	switch v := v.(type) {
+	case map[string]any:
+		out := make(map[string]any, len(v))
+		for k, x := range v {
+			if s, ok := x.(string); ok && s != "" && isSecretPath(k) {
+				x = "(hidden)"
+			}
+			out[k] = redact(x)
+		}
+		return out
+	case []any:
+		out := make([]any, len(v))
+		for i, x := range v {
+			out[i] = redact(x)
+		}
+		return out
+	}
+	return v
+}
+
+// isSecretPath reports whether the setting at path holds a credential
+func isSecretPath(path string) bool {
+	key := path[strings.LastIndex(path, ".")+1:]
+	if strings.HasSuffix(key, "]") {
+		// A list item, e.g. hosts[Secrets vault]
+		return false
+	}
+	for _, word := range []string{"password", "token", "secret", "user_key"} {
+		if strings.Contains(key, word) {
+			return true
+		}
+	}
+	return false
+}
+
+// summarizeChanges names the first few changed paths, for the history list
+func summarizeChanges(changes []ConfigChange) string {
+	const shown = 3
+	parts := make([]string, 0, shown)
+	for _, c := range changes[:min(shown, len(changes))] {
+		verb := "changed"
+		switch {
+		case c.Old == "":
+			verb = "added"
+		case c.New == "":
+			verb = "removed"
+		}
+		parts = append(parts, c.Path+" "+verb)
+	}
+	s := strings.Join(parts, ", ")
+	if len(changes) > shown {
+		s += fmt.Sprintf(" and %d more", len(changes)-shown)
+	}
+	return s
+}
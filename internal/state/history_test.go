@@ -0,0 +1,49 @@
+package state
+
+import (
+	"fmt"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/andrewsjg/simple-healthchecker/copilot/internal/config"
+)
+
+func TestChangesArePutDownToWhoMadeThem(t *testing.T) {
+	st, _ := newFakeState(&config.Config{Hosts: []config.Host{{Name: "router", Address: "192.168.1.1"}}})
+	st.SetConfigPath(filepath.Join(t.TempDir(), "config.yaml"))
+
+	// Changes made at once each keep their own actor
+	var wg sync.WaitGroup
+	for i := range 8 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			name := fmt.Sprintf("host-%d", i)
+			if err := st.As("user-"+name, "POST /addhost").AddHost(name, "10.0.0.1", ""); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+	if err := st.SetHostTags("router", []string{"edge"}); err != nil {
+		t.Fatal(err)
+	}
+
+	revs := st.Revisions()
+	if len(revs) != 10 {
+		t.Fatalf("got %d revisions, want 10: the loaded config, 8 hosts and the tags", len(revs))
+	}
+	if revs[0].Actor != "" {
+		t.Errorf("a change made without As was put down to %q", revs[0].Actor)
+	}
+	for _, rev := range revs[1:9] {
+		_, changes, _ := st.RevisionChanges(rev.ID)
+		if len(changes) != 1 {
+			t.Fatalf("revision %d has %d changes, want 1", rev.ID, len(changes))
+		}
+		if want := "user-" + changes[0].Path[len("hosts["):len(changes[0].Path)-1]; rev.Actor != want || rev.Action != "POST /addhost" {
+			t.Errorf("revision %d adding %s was put down to %q, %q; want %q", rev.ID, changes[0].Path, rev.Actor, rev.Action, want)
+		}
+	}
+}
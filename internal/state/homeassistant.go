@@ -0,0 +1,55 @@
+package state
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/andrewsjg/simple-healthchecker/copilot/internal/homeassistant"
+)
+
+// checkHomeAssistant records a warning if Home Assistant publishing is
+// enabled but can't work
+func (s *State) checkHomeAssistant() {
+	settings := s.cfg.Settings.HomeAssistant
+	if !settings.Enabled {
+		return
+	}
+	if err := homeassistant.Validate(settings); err != nil {
+		msg := fmt.Sprintf("Home Assistant publishing disabled: %v", err)
+		log.Printf("warning: %s", msg)
+		s.warnings = append(s.warnings, msg)
+	}
+}
+
+// exportHomeAssistantLocked publishes the results of the checks that ran at
+// now as Home Assistant entities. Like the metrics push it runs in the
+// background, and a failed update is retried after the next run.
+func (s *State) exportHomeAssistantLocked(now time.Time) {
+	if s.haClient == nil || !s.haClient.IsEnabled() {
+		return
+	}
+	var results []homeassistant.Result
+	for _, hs := range s.hosts {
+		for i := range hs.Checks {
+			c := &hs.Checks[i]
+			if !c.Enabled || !c.CheckedAt.Equal(now) {
+				continue
+			}
+			results = append(results, homeassistant.Result{
+				Time:      now,
+				Host:      hs.Name,
+				CheckType: string(c.Type),
+				Check:     exportName(c, i),
+				Status:    exportStatus(c),
+				Latency:   c.Latency,
+				Message:   c.Message,
+			})
+		}
+	}
+	go func() {
+		if err := s.haClient.Push(results); err != nil {
+			log.Printf("Home Assistant export error: %v", err)
+		}
+	}()
+}
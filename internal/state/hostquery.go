@@ -0,0 +1,168 @@
+package state
+
+import (
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/andrewsjg/simple-healthchecker/copilot/internal/config"
+)
+
+// Host statuses, worst first. See HostStatus.Status for how a host's checks
+// roll up into one.
+const (
+	HostDown     = "down"
+	HostDegraded = "degraded"
+	HostBlocked  = "blocked"
+	HostPending  = "pending"
+	HostUp       = "up"
+	HostDisabled = "disabled"
+)
+
+var hostStatusRank = map[string]int{HostDown: 0, HostDegraded: 1, HostBlocked: 2, HostPending: 3, HostUp: 4, HostDisabled: 5}
+
+// HostQuery filters and orders the hosts shown on the dashboard
+type HostQuery struct {
+	Search string // Case-insensitive words that must all match the name, address, a tag or a check's URL, ID or name
+	Status string // One of the Host* statuses; empty shows every host
+	Sort   string // "status", "uptime" or "latency"; empty keeps config order
+
+	// Only hosts with one of these tags, for a user limited to them; empty
+	// shows every host. Not a filter the user chose, so Active ignores it.
+	Tags []string
+}
+
+// Active reports whether q hides any hosts
+func (q HostQuery) Active() bool {
+	return strings.TrimSpace(q.Search) != "" || q.Status != ""
+}
+
+// QueryHosts returns a snapshot of the hosts matching q, in q's order.
+// Sorts are stable, so ties keep config order.
+func (s *State) QueryHosts(q HostQuery) []*HostStatus {
+	hosts := s.Snapshot()
+	words := strings.Fields(strings.ToLower(q.Search))
+	out := hosts[:0]
+	for _, hs := range hosts {
+		if (q.Status == "" || hs.Status() == q.Status) && hs.matches(words) && hs.VisibleTo(q.Tags) {
+			out = append(out, hs)
+		}
+	}
+	switch q.Sort {
+	case "status":
+		sort.SliceStable(out, func(i, j int) bool {
+			return hostStatusRank[out[i].Status()] < hostStatusRank[out[j].Status()]
+		})
+	case "uptime":
+		sort.SliceStable(out, func(i, j int) bool { return out[i].uptimePct() < out[j].uptimePct() })
+	case "latency":
+		sort.SliceStable(out, func(i, j int) bool { return out[i].maxLatency() > out[j].maxLatency() })
+	}
+	return out
+}
+
+// Status rolls a host's enabled checks up into one of the Host* statuses.
+// A host is down if a key check is down, or if none of its checks is up;
+// degraded if only some of them are down, or one is up but slower than
+// expected; otherwise blocked, pending or up, whichever is worst.
+// Info-severity checks never make a host down or degraded, and checks that are disabled, off schedule or within expected
+// downtime are left out; a host with nothing left is disabled.
+func (hs *HostStatus) Status() string {
+	up, down, keyDown := 0, 0, false
+	blocked, pending, slow := false, false, false
+	counted := false
+	for _, c := range hs.Checks {
+		if !c.Enabled || c.OffSchedule || c.ExpectedDown() {
+			continue
+		}
+		counted = true
+		switch {
+		case c.Pending():
+			pending = true
+		case c.OK:
+			up++
+			slow = slow || c.Degraded && c.Severity != config.SeverityInfo
+		case c.ParentFailed:
+			blocked = true
+		case c.Severity == config.SeverityInfo:
+		default:
+			down++
+			keyDown = keyDown || c.isKey()
+		}
+	}
+	switch {
+	case down > 0 && (keyDown || up == 0):
+		return HostDown
+	case down > 0, slow:
+		return HostDegraded
+	case blocked:
+		return HostBlocked
+	case pending:
+		return HostPending
+	case counted:
+		return HostUp
+	}
+	return HostDisabled
+}
+
+// isKey reports whether the check being down means its host is down, not
+// just degraded: it is critical, or it pings the host to see it is there
+func (c CheckStatus) isKey() bool {
+	return c.Severity == config.SeverityCritical || (c.Type == config.CheckPing && !c.Invert)
+}
+
+// VisibleTo reports whether a user limited to hosts with one of tags may
+// see the host; no tags see every host
+func (hs *HostStatus) VisibleTo(tags []string) bool {
+	if len(tags) == 0 {
+		return true
+	}
+	return slices.ContainsFunc(hs.Tags, func(t string) bool { return slices.Contains(tags, t) })
+}
+
+func (hs *HostStatus) matches(words []string) bool {
+	if len(words) == 0 {
+		return true
+	}
+	fields := []string{hs.Name, hs.Address}
+	fields = append(fields, hs.Tags...)
+	for _, c := range hs.Checks {
+		fields = append(fields, c.URL, c.ID, c.Name)
+	}
+	text := strings.ToLower(strings.Join(fields, "\n"))
+	for _, w := range words {
+		if !strings.Contains(text, w) {
+			return false
+		}
+	}
+	return true
+}
+
+// uptimePct averages the uptime of the host's enabled checks that have run
+func (hs *HostStatus) uptimePct() float64 {
+	var sum float64
+	n := 0
+	for i := range hs.Checks {
+		c := &hs.Checks[i]
+		if c.Enabled && c.TotalChecks > 0 {
+			sum += c.uptimePct()
+			n++
+		}
+	}
+	if n == 0 {
+		return 100
+	}
+	return sum / float64(n)
+}
+
+// maxLatency is the slowest current latency among the host's enabled checks
+func (hs *HostStatus) maxLatency() time.Duration {
+	var slowest time.Duration
+	for _, c := range hs.Checks {
+		if c.Enabled && c.Latency > slowest {
+			slowest = c.Latency
+		}
+	}
+	return slowest
+}
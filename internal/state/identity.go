@@ -0,0 +1,23 @@
+package state
+
+import (
+	"github.com/andrewsjg/simple-healthchecker/copilot/internal/checks"
+)
+
+// probeIdentityLocked is how the next probe of c identifies itself: the
+// check's own User-Agent or the configured one, and a fresh ID if a probe
+// header is configured
+func (s *State) probeIdentityLocked(c *CheckStatus) checks.ProbeIdentity {
+	settings := s.cfg.Settings.Probes
+	id := checks.ProbeIdentity{UserAgent: c.UserAgent, Header: settings.ProbeHeader}
+	if id.UserAgent == "" {
+		id.UserAgent = settings.UserAgent
+	}
+	if id.UserAgent == "" {
+		id.UserAgent = checks.DefaultUserAgent
+	}
+	if id.Header != "" {
+		id.ID = checks.NewProbeID()
+	}
+	return id
+}
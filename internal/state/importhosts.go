@@ -0,0 +1,37 @@
+package state
+
+import (
+	"fmt"
+	"slices"
+
+	"github.com/andrewsjg/simple-healthchecker/copilot/internal/config"
+)
+
+// ImportHosts adds hosts converted from another monitoring tool. Hosts whose
+// name is already taken are skipped and returned, and check IDs that clash
+// with existing ones get a numeric suffix.
+func (s *State) ImportHosts(hosts []config.Host) (added, skipped []string, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, h := range hosts {
+		if _, exists := s.hosts[h.Name]; exists {
+			skipped = append(skipped, h.Name)
+			continue
+		}
+		h.Checks = slices.Clone(h.Checks)
+		for i := range h.Checks {
+			id := h.Checks[i].ID
+			for n := 2; s.checkIDInUseLocked(h.Checks[i].ID, ""); n++ {
+				h.Checks[i].ID = fmt.Sprintf("%s-%d", id, n)
+			}
+		}
+		s.hosts[h.Name] = s.hostStatusFromConfig(h)
+		s.cfg.Hosts = append(s.cfg.Hosts, h)
+		added = append(added, h.Name)
+	}
+	if len(added) == 0 {
+		return nil, skipped, nil
+	}
+	s.rebuildCheckIndex()
+	return added, skipped, s.saveConfigLocked()
+}
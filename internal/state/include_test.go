@@ -0,0 +1,56 @@
+package state
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/andrewsjg/simple-healthchecker/copilot/internal/config"
+)
+
+func TestDuplicateCheckIDsAcrossIncludes(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, data string) string {
+		t.Helper()
+		p := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, []byte(data), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		return p
+	}
+	path := write("config.yaml", "include: [conf.d]\nhosts:\n  - name: router\n    address: 192.168.1.1\n    checks:\n      - type: ping\n        id: up\n        enabled: true\n")
+	nas := write("conf.d/nas.yaml", "name: nas\naddress: 192.168.1.10\nchecks:\n  - type: ping\n    id: up\n    enabled: true\n")
+	cfg, err := config.Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	st, _ := newFakeState(cfg)
+	st.SetConfigPath(path)
+
+	// The later file's check is renamed, with a warning
+	if c := check(t, st, "router", 0); c.ID != "up" {
+		t.Errorf("router check ID = %q, want up", c.ID)
+	}
+	if c := check(t, st, "nas", 0); c.ID != "up-2" {
+		t.Errorf("nas check ID = %q, want up-2", c.ID)
+	}
+	if w := st.Warnings(); len(w) != 1 || !strings.Contains(w[0], `"up" on host "nas" was renamed to "up-2"`) {
+		t.Errorf("warnings = %q", w)
+	}
+
+	// and the rename is saved to the file the host came from
+	if err := st.SetHostTags("nas", []string{"storage"}); err != nil {
+		t.Fatal(err)
+	}
+	b, err := os.ReadFile(nas)
+	if err != nil || !strings.Contains(string(b), "id: up-2") || !strings.Contains(string(b), "storage") {
+		t.Errorf("nas.yaml after saving = %q, %v; want the renamed ID and new tag", b, err)
+	}
+	if b, _ := os.ReadFile(path); strings.Contains(string(b), "nas") {
+		t.Errorf("config.yaml gained the included host:\n%s", b)
+	}
+}
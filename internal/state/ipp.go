@@ -0,0 +1,79 @@
+package state
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/andrewsjg/simple-healthchecker/copilot/internal/checks"
+	"github.com/andrewsjg/simple-healthchecker/copilot/internal/config"
+)
+
+// ippMessage describes a printer's state, e.g. "idle; Black Toner 62%" or
+// "stopped (media-jam-error): Paper jam"
+func ippMessage(res checks.IPPResult) string {
+	if res.Err != nil {
+		return res.Err.Error()
+	}
+	msg := res.State
+	if len(res.Reasons) > 0 {
+		msg += " (" + strings.Join(res.Reasons, ", ") + ")"
+	}
+	if !res.OK && res.Message != "" {
+		msg += ": " + res.Message
+	}
+	if res.Marker != "" {
+		msg += fmt.Sprintf("; %s %d%%", res.Marker, res.Level)
+	}
+	return msg
+}
+
+// AddIPPCheck appends a printer status check for the IPP printer at url to
+// the named host
+func (s *State) AddIPPCheck(hostName, url string, opts checks.IPPOptions, id, dependsOn string, mqttNotify, pushoverNotify, telegramNotify bool) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	hs, ok := s.hosts[hostName]
+	if !ok {
+		return fmt.Errorf("host not found")
+	}
+	if s.checkIDInUseLocked(id, "") {
+		return fmt.Errorf("check id %q already in use", id)
+	}
+	c := config.Check{Type: config.CheckIPP, Enabled: true, URL: url, InsecureSkipVerify: opts.InsecureSkipVerify, ID: id, DependsOn: dependsOn, MQTTNotify: mqttNotify, PushoverNotify: pushoverNotify, TelegramNotify: telegramNotify}
+	hs.Checks = append(hs.Checks, CheckStatus{Type: config.CheckIPP, Enabled: true, URL: url, IPPOpts: opts, ID: id, DependsOn: dependsOn, MQTTNotify: mqttNotify, PushoverNotify: pushoverNotify, TelegramNotify: telegramNotify, Severity: config.SeverityWarning})
+	for i := range s.cfg.Hosts {
+		if s.cfg.Hosts[i].Name == hostName {
+			s.cfg.Hosts[i].Checks = append(s.cfg.Hosts[i].Checks, c)
+			break
+		}
+	}
+	s.rebuildCheckIndex()
+	return s.saveConfigLocked()
+}
+
+// SetCheckIPP updates the printer URL and TLS option of the ipp check at idx
+func (s *State) SetCheckIPP(hostName string, idx int, url string, opts checks.IPPOptions) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	hs, ok := s.hosts[hostName]
+	if !ok {
+		return fmt.Errorf("host not found")
+	}
+	if idx < 0 || idx >= len(hs.Checks) {
+		return fmt.Errorf("bad index")
+	}
+	if hs.Checks[idx].Type != config.CheckIPP {
+		return fmt.Errorf("not ipp check")
+	}
+	hs.Checks[idx].URL, hs.Checks[idx].IPPOpts = url, opts
+	for i := range s.cfg.Hosts {
+		if s.cfg.Hosts[i].Name == hostName {
+			if idx < len(s.cfg.Hosts[i].Checks) {
+				s.cfg.Hosts[i].Checks[idx].URL = url
+				s.cfg.Hosts[i].Checks[idx].InsecureSkipVerify = opts.InsecureSkipVerify
+			}
+			break
+		}
+	}
+	return s.saveConfigLocked()
+}
@@ -0,0 +1,56 @@
+package state
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/andrewsjg/simple-healthchecker/copilot/internal/checks"
+)
+
+// JitterStats summarises the spread of the replies in a ping check's
+// history; it is zero for checks sending one echo request a run
+type JitterStats struct {
+	Avg, Max time.Duration // Jitter
+	StdDev   time.Duration // Mean standard deviation
+}
+
+// checkJitter applies a ping check's jitter limit to a run's jitter,
+// returning why it is unacceptable or "" if it passes
+func (c *CheckStatus) checkJitter(jitter time.Duration) string {
+	if c.MaxJitter > 0 && jitter > c.MaxJitter {
+		return fmt.Sprintf("jitter %s, expected at most %s", checks.FormatLatency(jitter), checks.FormatLatency(c.MaxJitter))
+	}
+	return ""
+}
+
+// notePing adds a ping run's spread to the data point just recorded for it,
+// as noteResponse does for http probes
+func (c *CheckStatus) notePing(at time.Time, res checks.PingResult) {
+	n := len(c.FullHistory)
+	if n == 0 || !c.FullHistory[n-1].Timestamp.Equal(at) || c.FullHistory[n-1].Latency == 0 {
+		return
+	}
+	dp := &c.FullHistory[n-1]
+	dp.Jitter, dp.StdDev = res.Jitter, res.StdDev
+}
+
+// jitterStats summarises the spread recorded in history. Runs of a single
+// reply have none and are left out.
+func jitterStats(history []CheckDataPoint) JitterStats {
+	var st JitterStats
+	var jitter, stddev time.Duration
+	var n time.Duration
+	for _, dp := range history {
+		if dp.Jitter == 0 && dp.StdDev == 0 {
+			continue
+		}
+		jitter += dp.Jitter
+		stddev += dp.StdDev
+		st.Max = max(st.Max, dp.Jitter)
+		n++
+	}
+	if n > 0 {
+		st.Avg, st.StdDev = jitter/n, stddev/n
+	}
+	return st
+}
@@ -0,0 +1,75 @@
+package state
+
+import (
+	"fmt"
+
+	"github.com/andrewsjg/simple-healthchecker/copilot/internal/checks"
+	"github.com/andrewsjg/simple-healthchecker/copilot/internal/config"
+)
+
+// kafkaMessage describes a kafka check's result, e.g. "3 brokers, 12
+// topics, 36 partitions" or "topic orders: 6 partitions, 1
+// under-replicated"
+func kafkaMessage(res checks.KafkaResult, opts checks.KafkaOptions) string {
+	if res.Err != nil {
+		return res.Err.Error()
+	}
+	msg := fmt.Sprintf("%d brokers, %d topics, %d partitions", res.Brokers, res.Topics, res.Partitions)
+	if opts.Topic != "" {
+		msg = fmt.Sprintf("topic %s: %d partitions, %d brokers", opts.Topic, res.Partitions, res.Brokers)
+	}
+	if res.UnderReplicated > 0 {
+		msg += fmt.Sprintf(", %d under-replicated", res.UnderReplicated)
+	}
+	return msg
+}
+
+// AddKafkaCheck appends a Kafka broker check to the named host
+func (s *State) AddKafkaCheck(hostName string, opts checks.KafkaOptions, id, dependsOn string, mqttNotify, pushoverNotify, telegramNotify bool) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	hs, ok := s.hosts[hostName]
+	if !ok {
+		return fmt.Errorf("host not found")
+	}
+	if s.checkIDInUseLocked(id, "") {
+		return fmt.Errorf("check id %q already in use", id)
+	}
+	c := config.Check{Type: config.CheckKafka, Enabled: true, Topic: opts.Topic, Port: opts.Port, ID: id, DependsOn: dependsOn, MQTTNotify: mqttNotify, PushoverNotify: pushoverNotify, TelegramNotify: telegramNotify}
+	hs.Checks = append(hs.Checks, CheckStatus{Type: config.CheckKafka, Enabled: true, KafkaOpts: opts, ID: id, DependsOn: dependsOn, MQTTNotify: mqttNotify, PushoverNotify: pushoverNotify, TelegramNotify: telegramNotify, Severity: config.SeverityWarning})
+	for i := range s.cfg.Hosts {
+		if s.cfg.Hosts[i].Name == hostName {
+			s.cfg.Hosts[i].Checks = append(s.cfg.Hosts[i].Checks, c)
+			break
+		}
+	}
+	s.rebuildCheckIndex()
+	return s.saveConfigLocked()
+}
+
+// SetCheckKafka updates the topic and port of the kafka check at idx
+func (s *State) SetCheckKafka(hostName string, idx int, opts checks.KafkaOptions) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	hs, ok := s.hosts[hostName]
+	if !ok {
+		return fmt.Errorf("host not found")
+	}
+	if idx < 0 || idx >= len(hs.Checks) {
+		return fmt.Errorf("bad index")
+	}
+	if hs.Checks[idx].Type != config.CheckKafka {
+		return fmt.Errorf("not kafka check")
+	}
+	hs.Checks[idx].KafkaOpts = opts
+	for i := range s.cfg.Hosts {
+		if s.cfg.Hosts[i].Name == hostName {
+			if idx < len(s.cfg.Hosts[i].Checks) {
+				s.cfg.Hosts[i].Checks[idx].Topic = opts.Topic
+				s.cfg.Hosts[i].Checks[idx].Port = opts.Port
+			}
+			break
+		}
+	}
+	return s.saveConfigLocked()
+}
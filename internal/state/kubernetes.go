@@ -0,0 +1,111 @@
+package state
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/andrewsjg/simple-healthchecker/copilot/internal/checks"
+	"github.com/andrewsjg/simple-healthchecker/copilot/internal/config"
+)
+
+// kubernetesOptionsFromConfig extracts a kubernetes check's credentials and
+// what it looks at
+func kubernetesOptionsFromConfig(c config.Check) checks.KubernetesOptions {
+	return checks.KubernetesOptions{
+		Kubeconfig:         c.Kubeconfig,
+		Context:            c.KubeContext,
+		Token:              c.Token,
+		Deployment:         c.Deployment,
+		InsecureSkipVerify: c.InsecureSkipVerify,
+	}
+}
+
+// setCfgKubernetesOptions copies a kubernetes check's API server, credentials
+// and deployment into its config
+func setCfgKubernetesOptions(c *config.Check, url string, opts checks.KubernetesOptions) {
+	c.URL = url
+	c.Kubeconfig = opts.Kubeconfig
+	c.KubeContext = opts.Context
+	c.Token = opts.Token
+	c.Deployment = opts.Deployment
+	c.InsecureSkipVerify = opts.InsecureSkipVerify
+}
+
+// kubernetesMessage describes a kubernetes check's result, e.g. "3 of 3
+// nodes ready" or "media/plex: 1 of 2 replicas available"
+func kubernetesMessage(res checks.KubernetesResult, opts checks.KubernetesOptions) string {
+	if res.Err != nil {
+		return res.Err.Error()
+	}
+	if opts.Deployment != "" {
+		return fmt.Sprintf("%s: %d of %d replicas available", opts.Deployment, res.Available, res.Replicas)
+	}
+	msg := fmt.Sprintf("%d of %d nodes ready", res.Ready, res.Nodes)
+	if len(res.NotReady) > 0 {
+		msg += " (not ready: " + strings.Join(res.NotReady, ", ") + ")"
+	}
+	return msg
+}
+
+// AddKubernetesCheck appends a cluster check to the named host. url is the
+// API server, or empty to use the kubeconfig's.
+func (s *State) AddKubernetesCheck(hostName, url string, opts checks.KubernetesOptions, id, dependsOn string, mqttNotify, pushoverNotify, telegramNotify bool) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	hs, ok := s.hosts[hostName]
+	if !ok {
+		return fmt.Errorf("host not found")
+	}
+	if url == "" && opts.Kubeconfig == "" {
+		return fmt.Errorf("needs the API server's url or a kubeconfig")
+	}
+	if s.checkIDInUseLocked(id, "") {
+		return fmt.Errorf("check id %q already in use", id)
+	}
+	c := config.Check{Type: config.CheckKubernetes, Enabled: true, ID: id, DependsOn: dependsOn, MQTTNotify: mqttNotify, PushoverNotify: pushoverNotify, TelegramNotify: telegramNotify}
+	setCfgKubernetesOptions(&c, url, opts)
+	hs.Checks = append(hs.Checks, CheckStatus{Type: config.CheckKubernetes, Enabled: true, URL: url, KubernetesOpts: opts, ID: id, DependsOn: dependsOn, MQTTNotify: mqttNotify, PushoverNotify: pushoverNotify, TelegramNotify: telegramNotify, Severity: config.SeverityWarning})
+	for i := range s.cfg.Hosts {
+		if s.cfg.Hosts[i].Name == hostName {
+			s.cfg.Hosts[i].Checks = append(s.cfg.Hosts[i].Checks, c)
+			break
+		}
+	}
+	s.rebuildCheckIndex()
+	return s.saveConfigLocked()
+}
+
+// SetCheckKubernetes updates the API server, credentials and deployment of
+// the kubernetes check at idx. An empty token keeps the current one, so it
+// needn't be shown to be edited.
+func (s *State) SetCheckKubernetes(hostName string, idx int, url string, opts checks.KubernetesOptions) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	hs, ok := s.hosts[hostName]
+	if !ok {
+		return fmt.Errorf("host not found")
+	}
+	if idx < 0 || idx >= len(hs.Checks) {
+		return fmt.Errorf("bad index")
+	}
+	c := &hs.Checks[idx]
+	if c.Type != config.CheckKubernetes {
+		return fmt.Errorf("not kubernetes check")
+	}
+	if url == "" && opts.Kubeconfig == "" {
+		return fmt.Errorf("needs the API server's url or a kubeconfig")
+	}
+	if opts.Token == "" {
+		opts.Token = c.KubernetesOpts.Token
+	}
+	c.URL, c.KubernetesOpts = url, opts
+	for i := range s.cfg.Hosts {
+		if s.cfg.Hosts[i].Name == hostName {
+			if idx < len(s.cfg.Hosts[i].Checks) {
+				setCfgKubernetesOptions(&s.cfg.Hosts[i].Checks[idx], url, opts)
+			}
+			break
+		}
+	}
+	return s.saveConfigLocked()
+}
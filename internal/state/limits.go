@@ -0,0 +1,42 @@
+package state
+
+import (
+	"net/url"
+	"strings"
+
+	"github.com/andrewsjg/simple-healthchecker/copilot/internal/checks"
+	"github.com/andrewsjg/simple-healthchecker/copilot/internal/config"
+)
+
+// probeLimitsLocked builds the probe limits from the config. A host's own
+// limit covers its address and the hosts in its HTTP and WebSocket URLs; if
+// two hosts share a target, the lower limit wins.
+func (s *State) probeLimitsLocked() checks.ProbeLimits {
+	settings := s.cfg.Settings.Concurrency
+	limits := checks.ProbeLimits{
+		Global:  max(settings.MaxProbes, 0),
+		PerHost: max(settings.PerHost, 0),
+		Hosts:   make(map[string]int),
+	}
+	set := func(target string, n int) {
+		target = strings.ToLower(target)
+		if cur, ok := limits.Hosts[target]; target == "" || (ok && cur <= n) {
+			return
+		}
+		limits.Hosts[target] = n
+	}
+	for _, h := range s.cfg.Hosts {
+		if h.MaxConcurrentProbes <= 0 {
+			continue
+		}
+		set(h.Address, h.MaxConcurrentProbes)
+		for _, c := range h.Checks {
+			if c.Type == config.CheckHTTP || c.Type == config.CheckWS {
+				if u, err := url.Parse(c.URL); err == nil {
+					set(u.Hostname(), h.MaxConcurrentProbes)
+				}
+			}
+		}
+	}
+	return limits
+}
@@ -0,0 +1,66 @@
+package state
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/andrewsjg/simple-healthchecker/copilot/internal/metrics"
+)
+
+// checkMetrics records a warning if metrics export is enabled but can't work
+func (s *State) checkMetrics() {
+	settings := s.cfg.Settings.Metrics
+	if !settings.Enabled {
+		return
+	}
+	if err := metrics.Validate(settings); err != nil {
+		msg := fmt.Sprintf("Metrics export disabled: %v", err)
+		log.Printf("warning: %s", msg)
+		s.warnings = append(s.warnings, msg)
+	}
+}
+
+// exportMetricsLocked pushes the results of the checks that ran at now. The
+// push runs in the background so a slow or unreachable database can't hold
+// up the scheduler; failures are logged and the points dropped.
+func (s *State) exportMetricsLocked(now time.Time) {
+	if s.metricsClient == nil || !s.metricsClient.IsEnabled() {
+		return
+	}
+	var points []metrics.Point
+	for _, hs := range s.hosts {
+		for i := range hs.Checks {
+			c := &hs.Checks[i]
+			if !c.Enabled || !c.CheckedAt.Equal(now) {
+				continue
+			}
+			points = append(points, metrics.Point{Time: now, Host: hs.Name, CheckType: string(c.Type), Check: exportName(c, i), Status: exportStatus(c), Latency: c.Latency})
+		}
+	}
+	go func() {
+		if err := s.metricsClient.Push(points); err != nil {
+			log.Printf("Metrics export error: %v", err)
+		}
+	}()
+}
+
+// exportName names a check for external systems: its ID, or its type and
+// index if it has none
+func exportName(c *CheckStatus, idx int) string {
+	if c.ID != "" {
+		return c.ID
+	}
+	return fmt.Sprintf("%s_%d", c.Type, idx)
+}
+
+// exportStatus is "up", "down" or "blocked" for a check's last result
+func exportStatus(c *CheckStatus) string {
+	switch {
+	case c.ParentFailed:
+		return "blocked"
+	case !c.OK:
+		return "down"
+	}
+	return "up"
+}
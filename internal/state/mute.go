@@ -0,0 +1,82 @@
+package state
+
+import (
+	"fmt"
+	"log"
+	"slices"
+	"time"
+)
+
+// Notification channels that can be muted
+const (
+	ChannelMQTT     = "mqtt"
+	ChannelPushover = "pushover"
+	ChannelTelegram = "telegram"
+	ChannelShoutrrr = "shoutrrr"
+	ChannelSMS      = "sms"
+)
+
+// Channels lists the mutable notification channels in display order
+var Channels = []string{ChannelMQTT, ChannelPushover, ChannelTelegram, ChannelShoutrrr, ChannelSMS}
+
+// MuteChannel suppresses notifications on channel for d. Mutes are kept in
+// memory only, so a restart clears them.
+func (s *State) MuteChannel(channel string, d time.Duration) error {
+	if !validChannel(channel) {
+		return fmt.Errorf("unknown channel %q", channel)
+	}
+	if d <= 0 {
+		return fmt.Errorf("mute duration must be positive")
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	until := time.Now().Add(d)
+	s.mutes[channel] = until
+	log.Printf("%s notifications muted until %s", channel, until.Format(time.RFC3339))
+	return nil
+}
+
+// UnmuteChannel lifts any mute on channel
+func (s *State) UnmuteChannel(channel string) error {
+	if !validChannel(channel) {
+		return fmt.Errorf("unknown channel %q", channel)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.mutes[channel]; ok {
+		delete(s.mutes, channel)
+		log.Printf("%s notifications unmuted", channel)
+	}
+	return nil
+}
+
+// ChannelMutedUntil returns when the mute on channel expires, or the zero
+// time if it is not muted
+func (s *State) ChannelMutedUntil(channel string) time.Time {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.mutedUntilLocked(channel, time.Now())
+}
+
+func (s *State) mutedUntilLocked(channel string, now time.Time) time.Time {
+	until, ok := s.mutes[channel]
+	if !ok || !now.Before(until) {
+		return time.Time{}
+	}
+	return until
+}
+
+// channelMutedLocked reports whether alerts on channel should be dropped,
+// logging the skip so muted outages still leave a trace
+func (s *State) channelMutedLocked(channel, hostName string) bool {
+	until := s.mutedUntilLocked(channel, time.Now())
+	if until.IsZero() {
+		return false
+	}
+	log.Printf("%s muted until %s, not notifying for %s", channel, until.Format(time.RFC3339), hostName)
+	return true
+}
+
+func validChannel(channel string) bool {
+	return slices.Contains(Channels, channel)
+}
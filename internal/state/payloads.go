@@ -0,0 +1,29 @@
+package state
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/andrewsjg/simple-healthchecker/copilot/internal/schema"
+)
+
+// checkPayloads picks the payload schema version from settings.payloads,
+// falling back to the latest with a warning if the compat mode is unknown
+func (s *State) checkPayloads() {
+	v, err := schema.Resolve(s.cfg.Settings.Payloads.Compat)
+	if err != nil {
+		msg := fmt.Sprintf("Payload format: %v; sending v%d", err, v)
+		log.Printf("warning: %s", msg)
+		s.warnings = append(s.warnings, msg)
+	}
+	s.payloadVersion = v
+	s.mqttClient.SetSchemaVersion(v)
+}
+
+// PayloadSchemaVersion returns the schema version of MQTT and event stream
+// payloads, or schema.Legacy for the unversioned format
+func (s *State) PayloadSchemaVersion() int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.payloadVersion
+}
@@ -0,0 +1,65 @@
+package state
+
+import (
+	"fmt"
+	"time"
+)
+
+// maxProbeLog is how many probe attempts each check keeps in its log
+const maxProbeLog = 50
+
+// ProbeAttempt is one run of a check, kept so intermittent failures can be
+// looked into from the dashboard
+type ProbeAttempt struct {
+	At      time.Time
+	OK      bool
+	Blocked bool // Failed while a parent check was down
+	Latency time.Duration
+	Message string // What the check showed for this run
+	Error   string // The probe's own error, when the message doesn't give it
+	Addr    string // IP address the probe reached or tried, if known
+	ProbeID string // Sent in the probe header, to find this run in the target's logs
+}
+
+// Result names the outcome, as the card's status badge does
+func (p ProbeAttempt) Result() string {
+	switch {
+	case p.OK:
+		return "up"
+	case p.Blocked:
+		return "blocked"
+	}
+	return "down"
+}
+
+// logProbe adds the result just recorded on c to its probe log
+func (c *CheckStatus) logProbe(at time.Time, addr, probeID string, err error) {
+	p := ProbeAttempt{At: at, OK: c.OK, Blocked: c.ParentFailed, Latency: c.Latency, Message: c.Message, Addr: addr, ProbeID: probeID}
+	if err != nil && err.Error() != c.Message {
+		p.Error = err.Error()
+	}
+	c.probes = append(c.probes, p)
+	if len(c.probes) > maxProbeLog {
+		c.probes = c.probes[1:]
+	}
+}
+
+// ProbeLog returns check idx on hostName and its recent probe attempts,
+// newest first
+func (s *State) ProbeLog(hostName string, idx int) (CheckStatus, []ProbeAttempt, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	hs, ok := s.hosts[hostName]
+	if !ok {
+		return CheckStatus{}, nil, fmt.Errorf("host not found")
+	}
+	if idx < 0 || idx >= len(hs.Checks) {
+		return CheckStatus{}, nil, fmt.Errorf("check not found")
+	}
+	c := hs.Checks[idx]
+	out := make([]ProbeAttempt, len(c.probes))
+	for i, p := range c.probes {
+		out[len(out)-1-i] = p
+	}
+	return c, out, nil
+}
@@ -0,0 +1,111 @@
+package state
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/andrewsjg/simple-healthchecker/copilot/internal/checks"
+	"github.com/andrewsjg/simple-healthchecker/copilot/internal/config"
+)
+
+// proxmoxOptionsFromConfig extracts a proxmox check's token and what it
+// looks at
+func proxmoxOptionsFromConfig(c config.Check) checks.ProxmoxOptions {
+	return checks.ProxmoxOptions{
+		Token:              c.Token,
+		Node:               c.Node,
+		VM:                 c.VM,
+		MaxUsage:           c.MaxUsage,
+		Port:               c.Port,
+		InsecureSkipVerify: c.InsecureSkipVerify,
+	}
+}
+
+// setCfgProxmoxOptions copies a proxmox check's options into its config
+func setCfgProxmoxOptions(c *config.Check, opts checks.ProxmoxOptions) {
+	c.Token = opts.Token
+	c.Node = opts.Node
+	c.VM = opts.VM
+	c.MaxUsage = opts.MaxUsage
+	c.Port = opts.Port
+	c.InsecureSkipVerify = opts.InsecureSkipVerify
+}
+
+// proxmoxMessage describes a proxmox check's result, e.g. "3 of 3 nodes
+// online, 12 of 14 guests running; storage local-lvm on pve1 96% full"
+func proxmoxMessage(res checks.ProxmoxResult, opts checks.ProxmoxOptions) string {
+	if res.Err != nil {
+		return res.Err.Error()
+	}
+	msg := fmt.Sprintf("%d of %d nodes online", res.Online, res.Nodes)
+	if len(res.Offline) > 0 {
+		msg += " (offline: " + strings.Join(res.Offline, ", ") + ")"
+	}
+	if opts.VM != "" {
+		msg += fmt.Sprintf(", guest %s %s", res.VM, res.VMState)
+	} else {
+		msg += fmt.Sprintf(", %d of %d guests running", res.Running, res.Guests)
+	}
+	if len(res.Alarms) > 0 {
+		msg += "; " + strings.Join(res.Alarms, ", ")
+	}
+	return msg
+}
+
+// AddProxmoxCheck appends a Proxmox VE check to the named host
+func (s *State) AddProxmoxCheck(hostName string, opts checks.ProxmoxOptions, id, dependsOn string, mqttNotify, pushoverNotify, telegramNotify bool) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	hs, ok := s.hosts[hostName]
+	if !ok {
+		return fmt.Errorf("host not found")
+	}
+	if opts.Token == "" {
+		return fmt.Errorf("an API token is required")
+	}
+	if s.checkIDInUseLocked(id, "") {
+		return fmt.Errorf("check id %q already in use", id)
+	}
+	c := config.Check{Type: config.CheckProxmox, Enabled: true, ID: id, DependsOn: dependsOn, MQTTNotify: mqttNotify, PushoverNotify: pushoverNotify, TelegramNotify: telegramNotify}
+	setCfgProxmoxOptions(&c, opts)
+	hs.Checks = append(hs.Checks, CheckStatus{Type: config.CheckProxmox, Enabled: true, ProxmoxOpts: opts, ID: id, DependsOn: dependsOn, MQTTNotify: mqttNotify, PushoverNotify: pushoverNotify, TelegramNotify: telegramNotify, Severity: config.SeverityWarning})
+	for i := range s.cfg.Hosts {
+		if s.cfg.Hosts[i].Name == hostName {
+			s.cfg.Hosts[i].Checks = append(s.cfg.Hosts[i].Checks, c)
+			break
+		}
+	}
+	s.rebuildCheckIndex()
+	return s.saveConfigLocked()
+}
+
+// SetCheckProxmox updates the token and options of the proxmox check at
+// idx. An empty token keeps the current one.
+func (s *State) SetCheckProxmox(hostName string, idx int, opts checks.ProxmoxOptions) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	hs, ok := s.hosts[hostName]
+	if !ok {
+		return fmt.Errorf("host not found")
+	}
+	if idx < 0 || idx >= len(hs.Checks) {
+		return fmt.Errorf("bad index")
+	}
+	c := &hs.Checks[idx]
+	if c.Type != config.CheckProxmox {
+		return fmt.Errorf("not proxmox check")
+	}
+	if opts.Token == "" {
+		opts.Token = c.ProxmoxOpts.Token
+	}
+	c.ProxmoxOpts = opts
+	for i := range s.cfg.Hosts {
+		if s.cfg.Hosts[i].Name == hostName {
+			if idx < len(s.cfg.Hosts[i].Checks) {
+				setCfgProxmoxOptions(&s.cfg.Hosts[i].Checks[idx], opts)
+			}
+			break
+		}
+	}
+	return s.saveConfigLocked()
+}
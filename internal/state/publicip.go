@@ -0,0 +1,125 @@
+package state
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/andrewsjg/simple-healthchecker/copilot/internal/checks"
+	"github.com/andrewsjg/simple-healthchecker/copilot/internal/config"
+)
+
+// publicIPMessage describes a publicip check's result: the address, and the
+// one before it for a day after a change
+func publicIPMessage(res checks.PublicIPResult, c *CheckStatus) string {
+	if res.Err != nil {
+		return res.Err.Error()
+	}
+	if c.RecentIPChange() && c.PrevIP != "" {
+		return fmt.Sprintf("%s (was %s)", res.IP, c.PrevIP)
+	}
+	return res.IP
+}
+
+// notePublicIPLocked records the address a publicip check at idx looked up,
+// keeping it in the config so a change while the monitor was stopped is
+// still noticed. A change is logged and always notified, as that is what
+// the check is for; the first address ever seen is taken as it is.
+func (s *State) notePublicIPLocked(hs *HostStatus, idx int, now time.Time, res checks.PublicIPResult) {
+	c := &hs.Checks[idx]
+	if !res.OK || res.IP == c.PublicIP {
+		return
+	}
+	prev := c.PublicIP
+	c.PublicIP = res.IP
+	s.setCfgPublicIPLocked(hs.Name, idx, res.IP)
+	if err := s.saveConfigLocked(); err != nil {
+		log.Printf("save public IP for %q failed: %v", hs.Name, err)
+	}
+	if prev == "" {
+		return
+	}
+	c.PrevIP = prev
+	c.IPChangedAt = now
+	msg := fmt.Sprintf("The public IP is now %s (was %s)", res.IP, prev)
+	logEvent(Event{
+		Timestamp: now,
+		HostName:  hs.Name,
+		CheckIdx:  idx,
+		CheckID:   c.ID,
+		CheckName: c.Name,
+		CheckType: c.Type,
+		EventType: "ip_changed",
+		Message:   msg,
+	})
+	s.sendCheckNoticeLocked(hs, c, "🌐 "+hs.Name+" public IP changed", msg+".")
+}
+
+func (s *State) setCfgPublicIPLocked(hostName string, idx int, ip string) {
+	for i := range s.cfg.Hosts {
+		if s.cfg.Hosts[i].Name == hostName {
+			if idx < len(s.cfg.Hosts[i].Checks) {
+				s.cfg.Hosts[i].Checks[idx].PublicIP = ip
+			}
+			return
+		}
+	}
+}
+
+// AddPublicIPCheck appends a publicip check asking the lookup service at
+// url, or the default one, to the named host
+func (s *State) AddPublicIPCheck(hostName, url string, opts checks.PublicIPOptions, id, dependsOn string, mqttNotify, pushoverNotify, telegramNotify bool) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	hs, ok := s.hosts[hostName]
+	if !ok {
+		return fmt.Errorf("host not found")
+	}
+	if s.checkIDInUseLocked(id, "") {
+		return fmt.Errorf("check id %q already in use", id)
+	}
+	c := config.Check{Type: config.CheckPublicIP, Enabled: true, URL: url, IPVersion: opts.IPVersion, ID: id, DependsOn: dependsOn, MQTTNotify: mqttNotify, PushoverNotify: pushoverNotify, TelegramNotify: telegramNotify}
+	hs.Checks = append(hs.Checks, CheckStatus{Type: config.CheckPublicIP, Enabled: true, URL: url, PublicIPOpts: opts, ID: id, DependsOn: dependsOn, MQTTNotify: mqttNotify, PushoverNotify: pushoverNotify, TelegramNotify: telegramNotify, Severity: config.SeverityWarning})
+	for i := range s.cfg.Hosts {
+		if s.cfg.Hosts[i].Name == hostName {
+			s.cfg.Hosts[i].Checks = append(s.cfg.Hosts[i].Checks, c)
+			break
+		}
+	}
+	s.rebuildCheckIndex()
+	return s.saveConfigLocked()
+}
+
+// SetCheckPublicIP updates the lookup service and address family of the
+// publicip check at idx. Switching family forgets the address, so the other
+// family's isn't taken for a change.
+func (s *State) SetCheckPublicIP(hostName string, idx int, url string, opts checks.PublicIPOptions) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	hs, ok := s.hosts[hostName]
+	if !ok {
+		return fmt.Errorf("host not found")
+	}
+	if idx < 0 || idx >= len(hs.Checks) {
+		return fmt.Errorf("bad index")
+	}
+	c := &hs.Checks[idx]
+	if c.Type != config.CheckPublicIP {
+		return fmt.Errorf("not publicip check")
+	}
+	if opts.IPVersion != c.PublicIPOpts.IPVersion {
+		c.PublicIP, c.PrevIP, c.IPChangedAt = "", "", time.Time{}
+		s.setCfgPublicIPLocked(hostName, idx, "")
+	}
+	c.URL, c.PublicIPOpts = url, opts
+	for i := range s.cfg.Hosts {
+		if s.cfg.Hosts[i].Name == hostName {
+			if idx < len(s.cfg.Hosts[i].Checks) {
+				s.cfg.Hosts[i].Checks[idx].URL = url
+				s.cfg.Hosts[i].Checks[idx].IPVersion = opts.IPVersion
+			}
+			break
+		}
+	}
+	return s.saveConfigLocked()
+}
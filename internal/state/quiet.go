@@ -0,0 +1,118 @@
+package state
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/andrewsjg/simple-healthchecker/copilot/internal/config"
+	"github.com/andrewsjg/simple-healthchecker/copilot/internal/pushover"
+	"github.com/andrewsjg/simple-healthchecker/copilot/internal/shoutrrr"
+	"github.com/andrewsjg/simple-healthchecker/copilot/internal/telegram"
+	"github.com/andrewsjg/simple-healthchecker/copilot/internal/twilio"
+)
+
+// QuietChannels lists the channels quiet hours apply to. MQTT feeds
+// automations rather than people, so it is never held back.
+var QuietChannels = []string{ChannelPushover, ChannelTelegram, ChannelShoutrrr, ChannelSMS}
+
+// quietHoursLocked returns the quiet hours for channel: its own window if
+// one is set, otherwise the global one
+func (s *State) quietHoursLocked(channel string) config.QuietHours {
+	alerts := s.cfg.Settings.Alerts
+	if qh, ok := alerts.ChannelQuietHours[channel]; ok && qh.Enabled() {
+		return qh
+	}
+	return alerts.QuietHours
+}
+
+// holdForQuietHoursLocked reports whether an alert for c on channel should
+// wait for the quiet-hours digest. Critical checks always get through.
+func (s *State) holdForQuietHoursLocked(channel string, c *CheckStatus, now time.Time) bool {
+	return c.Severity != config.SeverityCritical && s.quietHoursLocked(channel).Contains(now)
+}
+
+// queuePushoverLocked holds msg for the morning digest. Reminders are
+// dropped, as the digest already reports the outage.
+func (s *State) queuePushoverLocked(msg pushover.AlertMessage) {
+	if msg.Reminder {
+		return
+	}
+	s.pushoverDigest = append(s.pushoverDigest, msg)
+	log.Printf("pushover in quiet hours, holding %s alert for %s", msg.Status, msg.Host)
+}
+
+// queueTelegramLocked is queuePushoverLocked for Telegram
+func (s *State) queueTelegramLocked(msg telegram.AlertMessage) {
+	if msg.Reminder {
+		return
+	}
+	s.telegramDigest = append(s.telegramDigest, msg)
+	log.Printf("telegram in quiet hours, holding %s alert for %s", msg.Status, msg.Host)
+}
+
+// queueShoutrrrLocked is queuePushoverLocked for Shoutrrr
+func (s *State) queueShoutrrrLocked(msg shoutrrr.AlertMessage) {
+	if msg.Reminder {
+		return
+	}
+	s.shoutrrrDigest = append(s.shoutrrrDigest, msg)
+	log.Printf("shoutrrr in quiet hours, holding %s alert for %s", msg.Status, msg.Host)
+}
+
+// queueSMSLocked is queuePushoverLocked for SMS
+func (s *State) queueSMSLocked(msg twilio.AlertMessage) {
+	if msg.Reminder {
+		return
+	}
+	s.smsDigest = append(s.smsDigest, msg)
+	log.Printf("sms in quiet hours, holding %s alert for %s", msg.Status, msg.Host)
+}
+
+// flushQuietDigestsLocked sends the alerts held during quiet hours once a
+// channel's window has ended
+func (s *State) flushQuietDigestsLocked(now time.Time) {
+	if len(s.pushoverDigest) > 0 && !s.quietHoursLocked(ChannelPushover).Contains(now) {
+		msgs := s.pushoverDigest
+		s.pushoverDigest = nil
+		if !s.channelMutedLocked(ChannelPushover, "quiet hours digest") {
+			if err := s.notifyTraced(ChannelPushover, "digest", func() error { return s.pushoverClient.SendDigest(digestTitle(len(msgs)), msgs) }); err != nil {
+				log.Printf("Pushover error: %v", err)
+			}
+		}
+	}
+	if len(s.telegramDigest) > 0 && !s.quietHoursLocked(ChannelTelegram).Contains(now) {
+		msgs := s.telegramDigest
+		s.telegramDigest = nil
+		if !s.channelMutedLocked(ChannelTelegram, "quiet hours digest") {
+			if err := s.notifyTraced(ChannelTelegram, "digest", func() error { return s.telegramClient.SendDigest(digestTitle(len(msgs)), msgs) }); err != nil {
+				log.Printf("Telegram error: %v", err)
+			}
+		}
+	}
+	if len(s.shoutrrrDigest) > 0 && !s.quietHoursLocked(ChannelShoutrrr).Contains(now) {
+		msgs := s.shoutrrrDigest
+		s.shoutrrrDigest = nil
+		if !s.channelMutedLocked(ChannelShoutrrr, "quiet hours digest") {
+			if err := s.notifyTraced(ChannelShoutrrr, "digest", func() error { return s.shoutrrrClient.SendDigest(digestTitle(len(msgs)), msgs) }); err != nil {
+				log.Printf("Shoutrrr error: %v", err)
+			}
+		}
+	}
+	if len(s.smsDigest) > 0 && !s.quietHoursLocked(ChannelSMS).Contains(now) {
+		msgs := s.smsDigest
+		s.smsDigest = nil
+		if !s.channelMutedLocked(ChannelSMS, "quiet hours digest") {
+			if err := s.notifyTraced(ChannelSMS, "digest", func() error { return s.twilioClient.SendDigest(digestTitle(len(msgs)), msgs) }); err != nil {
+				log.Printf("Twilio error: %v", err)
+			}
+		}
+	}
+}
+
+func digestTitle(n int) string {
+	if n == 1 {
+		return "🌅 1 alert during quiet hours"
+	}
+	return fmt.Sprintf("🌅 %d alerts during quiet hours", n)
+}
@@ -0,0 +1,94 @@
+package state
+
+import (
+	"sort"
+	"time"
+)
+
+// Reliability summarises a check's or host's outages over the event log
+type Reliability struct {
+	Failures int           // Outages seen, including one still ongoing
+	MTTR     time.Duration // Mean time to recovery; zero until an outage ends
+	MTBF     time.Duration // Mean time up between outages; zero until a second one
+	// Both leave out time the monitor recorded nothing, e.g. asleep
+}
+
+// outage is one span of a check being down
+type outage struct {
+	start, end time.Time // end is now while ongoing
+	ongoing    bool
+}
+
+// checkOutages reads the outages of each check on a host from the event
+// log, oldest first, keyed by check index, along with the stretches the
+// monitor recorded nothing
+func checkOutages(hostName string, now time.Time) (map[int][]outage, []noDataSpan) {
+	eventLogMutex.RLock()
+	defer eventLogMutex.RUnlock()
+	out := make(map[int][]outage)
+	open := make(map[int]time.Time)
+	for _, e := range eventLog {
+		if e.HostName != hostName || e.CheckType == "" {
+			continue
+		}
+		switch e.EventType {
+		case "down":
+			if _, ok := open[e.CheckIdx]; !ok {
+				open[e.CheckIdx] = e.Timestamp
+			}
+		case "recovered":
+			out[e.CheckIdx] = append(out[e.CheckIdx], outage{start: e.OutageStart(), end: e.Timestamp})
+			delete(open, e.CheckIdx)
+		}
+	}
+	for idx, start := range open {
+		out[idx] = append(out[idx], outage{start: start, end: now, ongoing: true})
+	}
+	return out, noDataSpansLocked()
+}
+
+// mergeOutages joins overlapping outages, so a host counts as down while
+// any of its checks is
+func mergeOutages(outs []outage) []outage {
+	outs = append([]outage(nil), outs...)
+	sort.Slice(outs, func(i, j int) bool { return outs[i].start.Before(outs[j].start) })
+	var merged []outage
+	for _, o := range outs {
+		if n := len(merged); n > 0 && !o.start.After(merged[n-1].end) {
+			last := &merged[n-1]
+			if o.end.After(last.end) {
+				last.end = o.end
+			}
+			last.ongoing = last.ongoing || o.ongoing
+			continue
+		}
+		merged = append(merged, o)
+	}
+	return merged
+}
+
+// reliability averages the recovery times and the gaps between outages,
+// which must be oldest first and not overlap, leaving out the time in
+// noData
+func reliability(outs []outage, noData []noDataSpan) Reliability {
+	r := Reliability{Failures: len(outs)}
+	var repair, between time.Duration
+	var repaired, gaps int
+	for i, o := range outs {
+		if !o.ongoing {
+			repair += o.end.Sub(o.start) - noDataWithin(noData, o.start, o.end)
+			repaired++
+		}
+		if i > 0 {
+			between += o.start.Sub(outs[i-1].end) - noDataWithin(noData, outs[i-1].end, o.start)
+			gaps++
+		}
+	}
+	if repaired > 0 {
+		r.MTTR = repair / time.Duration(repaired)
+	}
+	if gaps > 0 {
+		r.MTBF = between / time.Duration(gaps)
+	}
+	return r
+}
@@ -0,0 +1,186 @@
+package state
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"slices"
+	"time"
+
+	"github.com/andrewsjg/simple-healthchecker/copilot/internal/config"
+	"github.com/andrewsjg/simple-healthchecker/copilot/internal/remote"
+)
+
+// RemoteStatus describes the last fetch of settings.remote
+type RemoteStatus struct {
+	URL         string    // Without credentials or query string
+	Hosts       int       // Remote hosts being monitored
+	LastFetch   time.Time // Last attempt
+	LastSuccess time.Time
+	LastError   string // Empty if the last attempt succeeded
+}
+
+// checkRemote records a warning if remote hosts are configured but can't
+// be fetched
+func (s *State) checkRemote() {
+	settings := s.cfg.Settings.Remote
+	if settings.URL == "" {
+		return
+	}
+	if err := remote.Validate(settings); err != nil {
+		msg := fmt.Sprintf("Remote hosts disabled: %v", err)
+		log.Printf("warning: %s", msg)
+		s.warnings = append(s.warnings, msg)
+	}
+}
+
+// StartRemoteRefresh fetches the hosts in settings.remote, then fetches
+// them again every refresh interval until stop is closed. The first fetch
+// finishes before it returns, so the first scheduler run includes them. If
+// a fetch fails the hosts from the last good one are kept.
+func (s *State) StartRemoteRefresh(stop <-chan struct{}) {
+	s.mu.RLock()
+	settings := s.cfg.Settings.Remote
+	s.mu.RUnlock()
+	if settings.URL == "" || remote.Validate(settings) != nil {
+		return
+	}
+	src := remote.NewSource(settings)
+	s.refreshRemote(src)
+	go func() {
+		t := time.NewTicker(settings.RefreshInterval())
+		defer t.Stop()
+		for {
+			select {
+			case <-t.C:
+				s.refreshRemote(src)
+			case <-stop:
+				return
+			}
+		}
+	}()
+}
+
+// refreshRemote fetches the remote hosts once and applies them
+func (s *State) refreshRemote(src *remote.Source) {
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Minute)
+	defer cancel()
+	hosts, err := src.Fetch(ctx)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now()
+	s.remoteStatus.URL = remote.Redact(s.cfg.Settings.Remote.URL)
+	s.remoteStatus.LastFetch = now
+	if err != nil {
+		s.remoteStatus.LastError = err.Error()
+		log.Printf("remote hosts: fetch failed, keeping the last good copy: %v", err)
+		return
+	}
+	s.remoteStatus.LastSuccess = now
+	s.remoteStatus.LastError = ""
+	s.applyRemoteHostsLocked(hosts)
+	s.remoteStatus.Hosts = 0
+	for _, h := range s.cfg.Hosts {
+		if h.Remote() {
+			s.remoteStatus.Hosts++
+		}
+	}
+}
+
+// GetRemoteStatus returns the state of the remote hosts source
+func (s *State) GetRemoteStatus() RemoteStatus {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.remoteStatus
+}
+
+// applyRemoteHostsLocked replaces the remote hosts with a freshly fetched
+// set. Hosts and checks whose config is unchanged keep their runtime state
+// untouched; changed ones are rebuilt but keep the history of checks that
+// still match by ID, or by type and position. Local hosts are left alone,
+// and remote hosts clashing with one are skipped.
+func (s *State) applyRemoteHostsLocked(hosts []config.Host) {
+	old := make(map[string]config.Host)
+	var local []config.Host
+	for _, h := range s.cfg.Hosts {
+		if h.Remote() {
+			old[h.Name] = h
+		} else {
+			local = append(local, h)
+		}
+	}
+
+	next := slices.Clone(local)
+	seen := make(map[string]bool)
+	var added, changed []string
+	for _, h := range hosts {
+		if seen[h.Name] {
+			log.Printf("remote hosts: duplicate host %q skipped", h.Name)
+			continue
+		}
+		if slices.ContainsFunc(local, func(l config.Host) bool { return l.Name == h.Name }) {
+			log.Printf("remote hosts: %q is already defined locally, remote copy skipped", h.Name)
+			continue
+		}
+		seen[h.Name] = true
+		next = append(next, h)
+		prev, existed := old[h.Name]
+		switch {
+		case !existed:
+			added = append(added, h.Name)
+		case !config.SameHost(prev, h):
+			changed = append(changed, h.Name)
+		}
+		s.syncHostLocked(h, prev, existed)
+	}
+	var removed []string
+	for name := range old {
+		if !seen[name] {
+			delete(s.hosts, name)
+			removed = append(removed, name)
+		}
+	}
+	s.cfg.Hosts = next
+	if len(added)+len(changed)+len(removed) == 0 {
+		return
+	}
+	slices.Sort(removed)
+	s.rebuildCheckIndex()
+	log.Printf("remote hosts: %d added %v, %d changed %v, %d removed %v", len(added), added, len(changed), changed, len(removed), removed)
+}
+
+// carryCheckHistory copies the runtime state of was's checks onto the
+// matching checks of a rebuilt host
+func carryCheckHistory(was, hs *HostStatus) {
+	used := make([]bool, len(was.Checks))
+	for i := range hs.Checks {
+		c := &hs.Checks[i]
+		match := -1
+		for j := range was.Checks {
+			w := &was.Checks[j]
+			if used[j] || w.Type != c.Type {
+				continue
+			}
+			if c.ID != "" && w.ID == c.ID || c.ID == "" && w.ID == "" && j == i {
+				match = j
+				break
+			}
+		}
+		if match < 0 {
+			continue
+		}
+		used[match] = true
+		w := &was.Checks[match]
+		c.OK, c.ParentFailed, c.ParentID, c.Message = w.OK, w.ParentFailed, w.ParentID, w.Message
+		c.Latency, c.LatencyHistory, c.FullHistory, c.CheckedAt = w.Latency, w.LatencyHistory, w.FullHistory, w.CheckedAt
+		c.PingMethod, c.LastFailure, c.ChangedHash, c.FailStreak = w.PingMethod, w.LastFailure, w.ChangedHash, w.FailStreak
+		c.Annotations = w.Annotations
+		c.TotalChecks, c.SuccessChecks = w.TotalChecks, w.SuccessChecks
+		c.LastDownAt, c.LastUpAt, c.LastRemindedAt, c.LastCalledAt = w.LastDownAt, w.LastUpAt, w.LastRemindedAt, w.LastCalledAt
+		c.alertSuppressed, c.MonitorOffline, c.Warmup, c.OffSchedule = w.alertSuppressed, w.MonitorOffline, w.Warmup, w.OffSchedule
+		c.DownUntil, c.Expected = w.DownUntil, w.Expected
+		c.Flapping, c.flips, c.flapDown = w.Flapping, w.flips, w.flapDown
+		c.Degraded, c.DegradedSince, c.slowRuns = w.Degraded, w.DegradedSince, w.slowRuns
+	}
+}
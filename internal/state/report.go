@@ -0,0 +1,207 @@
+package state
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/andrewsjg/simple-healthchecker/copilot/internal/config"
+)
+
+// Report summarises every check over a span of whole days, for the
+// printable report. Uptime comes from the daily trends, which are kept for
+// a year; incidents come from the event log, which only holds the last 500
+// events.
+type Report struct {
+	From, To     time.Time // Start of the first day and end of the last
+	Hosts        []HostReport
+	Runs, Passed int64
+	Incidents    []Outage      // Outages overlapping the span, oldest first
+	Downtime     time.Duration // Incidents' time down within the span, summed, leaving out time with no data
+	Longest      time.Duration
+	EventsSince  time.Time // Oldest event in the log; zero if it is empty
+}
+
+// HostReport is one host's part of a Report
+type HostReport struct {
+	Name         string
+	Checks       []CheckReport
+	Runs, Passed int64
+	Incidents    int
+	Downtime     time.Duration
+}
+
+// CheckReport is one check's part of a Report
+type CheckReport struct {
+	Label     string // Without the host name
+	Enabled   bool
+	Trend     Trend
+	Incidents int
+	Downtime  time.Duration
+}
+
+// Uptime is the percentage of the span's runs that passed
+func (r Report) Uptime() float64 { return uptimePercent(r.Runs, r.Passed) }
+
+// Uptime is the percentage of the host's runs in the span that passed
+func (h HostReport) Uptime() float64 { return uptimePercent(h.Runs, h.Passed) }
+
+// LastDay is the start of the span's last day
+func (r Report) LastDay() time.Time { return r.To.AddDate(0, 0, -1) }
+
+// Checks counts the checks in the report
+func (r Report) Checks() int {
+	n := 0
+	for _, h := range r.Hosts {
+		n += len(h.Checks)
+	}
+	return n
+}
+
+// MTTR is the mean length of the incidents that have ended
+func (r Report) MTTR() time.Duration {
+	var total time.Duration
+	n := 0
+	for _, o := range r.Incidents {
+		if !o.Ongoing {
+			total += o.Downtime()
+			n++
+		}
+	}
+	if n == 0 {
+		return 0
+	}
+	return total / time.Duration(n)
+}
+
+// Partial reports whether the event log starts after the span does, so
+// earlier incidents are missing from the report
+func (r Report) Partial() bool {
+	return !r.EventsSince.IsZero() && r.EventsSince.After(r.From)
+}
+
+func uptimePercent(runs, passed int64) float64 {
+	if runs == 0 {
+		return 0
+	}
+	return float64(passed) / float64(runs) * 100
+}
+
+// Report summarises every host and check from the day of first to the day
+// of last, both included, in the display timezone
+func (s *State) Report(first, last time.Time) Report {
+	outs := s.Outages()
+	eventLogMutex.RLock()
+	var since time.Time
+	if len(eventLog) > 0 {
+		since = eventLog[0].Timestamp
+	}
+	spans := noDataSpansLocked()
+	eventLogMutex.RUnlock()
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	loc := s.displayLocationLocked()
+	first, last = first.In(loc), last.In(loc)
+	r := Report{
+		From:        time.Date(first.Year(), first.Month(), first.Day(), 0, 0, 0, 0, loc),
+		To:          time.Date(last.Year(), last.Month(), last.Day()+1, 0, 0, 0, 0, loc),
+		EventsSince: since,
+	}
+
+	type key struct {
+		host string
+		idx  int
+	}
+	down := make(map[key]time.Duration)
+	count := make(map[key]int)
+	now := time.Now()
+	for _, o := range outs {
+		end := o.Start.Add(o.Duration)
+		if o.Ongoing {
+			end = now
+		}
+		if !o.Start.Before(r.To) || !end.After(r.From) {
+			continue
+		}
+		from, to := o.Start, end
+		if from.Before(r.From) {
+			from = r.From
+		}
+		if to.After(r.To) {
+			to = r.To
+		}
+		d := to.Sub(from) - noDataWithin(spans, from, to)
+		r.Incidents = append(r.Incidents, o)
+		r.Downtime += d
+		k := key{o.Host, o.Idx}
+		down[k] += d
+		count[k]++
+		if o.Ongoing {
+			r.Longest = max(r.Longest, now.Sub(o.Start)-o.NoData)
+		} else {
+			r.Longest = max(r.Longest, o.Downtime())
+		}
+	}
+
+	for _, h := range s.cfg.Hosts {
+		hs, ok := s.hosts[h.Name]
+		if !ok {
+			continue
+		}
+		hr := HostReport{Name: hs.Name}
+		for i := range hs.Checks {
+			c := &hs.Checks[i]
+			k := key{hs.Name, i}
+			cr := CheckReport{
+				Label:     reportCheckLabel(c),
+				Enabled:   c.Enabled,
+				Trend:     s.checkTrendLocked(trendKey(hs.Name, c, i), first, last),
+				Incidents: count[k],
+				Downtime:  down[k],
+			}
+			hr.Checks = append(hr.Checks, cr)
+			hr.Runs += cr.Trend.Runs
+			hr.Passed += cr.Trend.Passed
+			hr.Incidents += cr.Incidents
+			hr.Downtime += cr.Downtime
+		}
+		r.Hosts = append(r.Hosts, hr)
+		r.Runs += hr.Runs
+		r.Passed += hr.Passed
+	}
+	return r
+}
+
+// reportCheckLabel describes a check within its host's rows
+func reportCheckLabel(c *CheckStatus) string {
+	switch {
+	case c.Name != "":
+		return c.Name
+	case c.Type == config.CheckHTTP && c.URL != "":
+		return "HTTP " + c.URL
+	case c.Type == config.CheckFile:
+		return "FILE " + c.FileOpts.Path
+	case c.Type == config.CheckS3:
+		return "S3 " + strings.TrimSuffix(c.S3Opts.Bucket+"/"+c.S3Opts.Object, "/")
+	case c.Type == config.CheckSMB && c.SMBOpts.Share != "":
+		return "SMB " + c.SMBOpts.Share
+	case c.Type == config.CheckKafka && c.KafkaOpts.Topic != "":
+		return "KAFKA " + c.KafkaOpts.Topic
+	case c.Type == config.CheckKubernetes && c.KubernetesOpts.Deployment != "":
+		return "KUBERNETES " + c.KubernetesOpts.Deployment
+	case c.Type == config.CheckProxmox && c.ProxmoxOpts.VM != "":
+		return "PROXMOX " + c.ProxmoxOpts.VM
+	case c.Type == config.CheckESXi && c.ESXiOpts.VM != "":
+		return "ESXI " + c.ESXiOpts.VM
+	case c.Type == config.CheckUPS && c.UPSOpts.UPS != "":
+		return "UPS " + c.UPSOpts.UPS
+	case c.Type == config.CheckSpeedtest && c.URL != "":
+		return "SPEEDTEST " + c.URL
+	case c.Type == config.CheckDomain && c.DomainOpts.Domain != "":
+		return "DOMAIN " + c.DomainOpts.Domain
+	case c.Port > 0:
+		return fmt.Sprintf("%s :%d", strings.ToUpper(string(c.Type)), c.Port)
+	}
+	return strings.ToUpper(string(c.Type))
+}
@@ -0,0 +1,141 @@
+package state
+
+import (
+	"fmt"
+	"log"
+	"net"
+	"net/url"
+	"time"
+
+	"github.com/andrewsjg/simple-healthchecker/copilot/internal/config"
+)
+
+// recentIPChange is how long the card highlights a changed address
+const recentIPChange = 24 * time.Hour
+
+// targetHost returns the host name or address a check on hs probes
+func targetHost(hs *HostStatus, c *CheckStatus) string {
+	switch c.Type {
+	case config.CheckHTTP, config.CheckWS, config.CheckS3, config.CheckIPP:
+		if c.URL == "" {
+			return hs.Address
+		}
+		if u, err := url.Parse(c.URL); err == nil {
+			return u.Hostname()
+		}
+		return ""
+	case config.CheckSpeedtest:
+		if c.URL == "" {
+			return hs.Address // iperf3 against the host
+		}
+		if u, err := url.Parse(c.URL); err == nil {
+			return u.Hostname()
+		}
+		return ""
+	case config.CheckKubernetes:
+		if u, err := url.Parse(c.URL); err == nil {
+			return u.Hostname() // Empty when the server comes from a kubeconfig
+		}
+		return ""
+	case config.CheckComposite, config.CheckWebhook, config.CheckPublicIP, config.CheckDomain:
+		return ""
+	case config.CheckFile:
+		if !c.FileOpts.SFTP {
+			return "" // Read on the monitor
+		}
+	}
+	return hs.Address
+}
+
+// RecentIPChange reports whether the check's hostname resolved to a new
+// address within the last day
+func (c CheckStatus) RecentIPChange() bool {
+	return !c.IPChangedAt.IsZero() && time.Since(c.IPChangedAt) < recentIPChange
+}
+
+// noteResolvedIPLocked records the address the probe of the check at idx
+// reached. Checks of an IP address, or through a proxy, aren't tracked. A
+// change is logged, and notified if the host asks for it; the first address
+// seen after a start is taken as it is.
+func (s *State) noteResolvedIPLocked(hs *HostStatus, idx int, now time.Time, addr string) {
+	c := &hs.Checks[idx]
+	host := targetHost(hs, c)
+	if addr == "" || host == "" || net.ParseIP(host) != nil || c.HTTPOpts.Proxy != "" {
+		return
+	}
+	prev := c.ResolvedIP
+	c.ResolvedIP = addr
+	if prev == "" || prev == addr {
+		return
+	}
+	c.PrevIP = prev
+	c.IPChangedAt = now
+	if !hs.AlertIPChange {
+		return
+	}
+	msg := fmt.Sprintf("%s now resolves to %s (was %s)", host, addr, prev)
+	logEvent(Event{
+		Timestamp: now,
+		HostName:  hs.Name,
+		CheckIdx:  idx,
+		CheckID:   c.ID,
+		CheckName: c.Name,
+		CheckType: c.Type,
+		EventType: "ip_changed",
+		Message:   msg,
+	})
+	s.sendCheckNoticeLocked(hs, c, "🔀 "+hs.Name+" address changed", msg+".")
+}
+
+// sendCheckNoticeLocked sends a notice about c that isn't a change of state
+// to the channels c notifies. MQTT only carries state changes, so it isn't
+// sent there. Quiet hours drop the notice rather than hold it, as the digest
+// only reports outages.
+func (s *State) sendCheckNoticeLocked(hs *HostStatus, c *CheckStatus, title, text string) {
+	now := time.Now()
+	send := func(channel string, enabled bool, notice func() error) {
+		if !enabled || s.channelMutedLocked(channel, hs.Name) {
+			return
+		}
+		if s.holdForQuietHoursLocked(channel, c, now) {
+			log.Printf("%s in quiet hours, not sending notice for %s: %s", channel, hs.Name, text)
+			return
+		}
+		if err := s.notifyTraced(channel, "notice", notice); err != nil {
+			log.Printf("%s error: %v", channel, err)
+		}
+	}
+	send(ChannelPushover, c.PushoverNotify && s.pushoverClient != nil && s.pushoverClient.IsEnabled(), func() error {
+		return s.pushoverClient.SendNotice(title, text)
+	})
+	send(ChannelTelegram, c.TelegramNotify && s.telegramClient != nil && s.telegramClient.IsEnabled(), func() error {
+		return s.telegramClient.SendNotice(title, text)
+	})
+	send(ChannelShoutrrr, len(c.ShoutrrrNotify) > 0 && s.shoutrrrClient != nil && s.shoutrrrClient.IsEnabled(), func() error {
+		return s.shoutrrrClient.SendNotice(c.ShoutrrrNotify, title, text)
+	})
+	send(ChannelSMS, c.SMSNotify && s.twilioClient != nil && s.twilioClient.IsEnabled(), func() error {
+		return s.twilioClient.SendNotice(title, text)
+	})
+}
+
+// SetHostAlertIPChange sets whether hostName notifies when a hostname its
+// checks probe resolves to a new address
+func (s *State) SetHostAlertIPChange(hostName string, alert bool) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	hs, ok := s.hosts[hostName]
+	if !ok {
+		return fmt.Errorf("host not found")
+	}
+	if hs.AlertIPChange == alert {
+		return nil
+	}
+	hs.AlertIPChange = alert
+	for i := range s.cfg.Hosts {
+		if s.cfg.Hosts[i].Name == hostName {
+			s.cfg.Hosts[i].AlertIPChange = alert
+		}
+	}
+	return s.saveConfigLocked()
+}
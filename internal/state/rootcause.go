@@ -0,0 +1,102 @@
+package state
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+)
+
+// newDown is a check that went down during a run, identified by host and
+// index so the report can be made once every check has been probed
+type newDown struct {
+	hs  *HostStatus
+	idx int
+}
+
+// reportDownsLocked logs and alerts the checks that went down this run,
+// grouped by root cause. Checks run in no particular order, so a child can
+// fail before its parent has been probed; once everything has run such a
+// child is reclassified as blocked and reported under its parent instead.
+func (s *State) reportDownsLocked(now time.Time, downs []newDown) {
+	for _, d := range downs {
+		c := &d.hs.Checks[d.idx]
+		if !s.IsParentOK(c) {
+			c.ParentFailed = true
+			c.Message = "parent check failed"
+		}
+	}
+	var roots []newDown
+	for _, d := range downs {
+		if !d.hs.Checks[d.idx].ParentFailed {
+			roots = append(roots, d)
+		}
+	}
+	if s.reportConnectivityLocked(now, roots) {
+		return
+	}
+	for _, d := range roots {
+		c := &d.hs.Checks[d.idx]
+		blocked := s.blockedByLocked(c)
+		logEvent(Event{
+			Timestamp: now,
+			HostName:  d.hs.Name,
+			CheckIdx:  d.idx,
+			CheckID:   c.ID,
+			CheckName: c.Name,
+			CheckType: c.Type,
+			EventType: "down",
+			Message:   c.Message,
+			Blocked:   blocked,
+		})
+		s.noteFlipLocked(d.hs, d.idx, now, false)
+		if !c.Flapping {
+			s.dispatchAlert(d.hs, c, "down", nil, blocked)
+		}
+	}
+}
+
+// rootCauseLocked follows a blocked check's dependencies up to the failing
+// check that blocks it. Unblocked checks are their own root cause.
+func (s *State) rootCauseLocked(c *CheckStatus) *CheckStatus {
+	// Bound the walk so a dependency cycle can't spin forever
+	for range len(s.checksByID) + 1 {
+		if !c.ParentFailed {
+			break
+		}
+		parent := s.parentLocked(c)
+		if parent == nil {
+			break
+		}
+		c = parent
+	}
+	return c
+}
+
+// blockedByLocked returns labels for the checks currently blocked because
+// root is down, sorted for stable output
+func (s *State) blockedByLocked(root *CheckStatus) []string {
+	var blocked []string
+	for _, hs := range s.hosts {
+		for i := range hs.Checks {
+			c := &hs.Checks[i]
+			if c != root && c.Enabled && c.ParentFailed && s.rootCauseLocked(c) == root {
+				blocked = append(blocked, checkLabel(hs, c))
+			}
+		}
+	}
+	slices.Sort(blocked)
+	return blocked
+}
+
+// checkLabel names a check for notifications, e.g. "nas HTTP", "nas [web]"
+// or "nas Plex web UI"
+func checkLabel(hs *HostStatus, c *CheckStatus) string {
+	if c.Name != "" {
+		return fmt.Sprintf("%s %s", hs.Name, c.Name)
+	}
+	if c.ID != "" {
+		return fmt.Sprintf("%s [%s]", hs.Name, c.ID)
+	}
+	return fmt.Sprintf("%s %s", hs.Name, strings.ToUpper(string(c.Type)))
+}
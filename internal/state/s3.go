@@ -0,0 +1,116 @@
+package state
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/andrewsjg/simple-healthchecker/copilot/internal/checks"
+	"github.com/andrewsjg/simple-healthchecker/copilot/internal/config"
+)
+
+// s3OptionsFromConfig extracts an s3 check's bucket, object and credentials
+func s3OptionsFromConfig(c config.Check) checks.S3Options {
+	return checks.S3Options{
+		Bucket:             c.Bucket,
+		Object:             c.Object,
+		Region:             c.Region,
+		AccessKey:          c.AccessKey,
+		SecretKey:          c.SecretKey,
+		MaxAge:             c.MaxAgeLimit(),
+		InsecureSkipVerify: c.InsecureSkipVerify,
+	}
+}
+
+// setCfgS3Options copies an s3 check's options into its config, with the
+// age limit as written, e.g. "25h"
+func setCfgS3Options(c *config.Check, url string, opts checks.S3Options, maxAge string) {
+	c.URL = url
+	c.Bucket = opts.Bucket
+	c.Object = opts.Object
+	c.Region = opts.Region
+	c.AccessKey = opts.AccessKey
+	c.SecretKey = opts.SecretKey
+	c.MaxAge = maxAge
+	c.InsecureSkipVerify = opts.InsecureSkipVerify
+}
+
+// s3Message describes an s3 check's result, e.g. "bucket ok" or
+// "db.tar.gz modified 3h ago, 1.25 GB"
+func s3Message(res checks.S3Result, opts checks.S3Options, now time.Time) string {
+	switch {
+	case res.Err != nil:
+		return res.Err.Error()
+	case opts.Object == "":
+		return "bucket ok"
+	case res.LastModified.IsZero():
+		return fmt.Sprintf("%s found, %s", opts.Object, checks.FormatBytes(res.Size))
+	}
+	msg := fmt.Sprintf("%s modified %s ago, %s", opts.Object, formatAge(max(now.Sub(res.LastModified), 0)), checks.FormatBytes(res.Size))
+	if !res.OK {
+		msg += fmt.Sprintf(" (max %s)", formatAge(opts.MaxAge))
+	}
+	return msg
+}
+
+// AddS3Check appends an object storage check against the endpoint at url to
+// the named host. maxAge is the object's age limit as written, e.g. "25h",
+// or empty for none.
+func (s *State) AddS3Check(hostName, url string, opts checks.S3Options, maxAge, id, dependsOn string, mqttNotify, pushoverNotify, telegramNotify bool) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	hs, ok := s.hosts[hostName]
+	if !ok {
+		return fmt.Errorf("host not found")
+	}
+	if opts.AccessKey != "" && opts.SecretKey == "" {
+		return fmt.Errorf("an access key needs its secret key")
+	}
+	if s.checkIDInUseLocked(id, "") {
+		return fmt.Errorf("check id %q already in use", id)
+	}
+	c := config.Check{Type: config.CheckS3, Enabled: true, ID: id, DependsOn: dependsOn, MQTTNotify: mqttNotify, PushoverNotify: pushoverNotify, TelegramNotify: telegramNotify}
+	setCfgS3Options(&c, url, opts, maxAge)
+	hs.Checks = append(hs.Checks, CheckStatus{Type: config.CheckS3, Enabled: true, URL: url, S3Opts: s3OptionsFromConfig(c), ID: id, DependsOn: dependsOn, MQTTNotify: mqttNotify, PushoverNotify: pushoverNotify, TelegramNotify: telegramNotify, Severity: config.SeverityWarning})
+	for i := range s.cfg.Hosts {
+		if s.cfg.Hosts[i].Name == hostName {
+			s.cfg.Hosts[i].Checks = append(s.cfg.Hosts[i].Checks, c)
+			break
+		}
+	}
+	s.rebuildCheckIndex()
+	return s.saveConfigLocked()
+}
+
+// SetCheckS3 updates the endpoint, bucket, object and credentials of the s3
+// check at idx. An empty secret key keeps the current one, so it needn't be
+// shown to be edited.
+func (s *State) SetCheckS3(hostName string, idx int, url string, opts checks.S3Options, maxAge string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	hs, ok := s.hosts[hostName]
+	if !ok {
+		return fmt.Errorf("host not found")
+	}
+	if idx < 0 || idx >= len(hs.Checks) {
+		return fmt.Errorf("bad index")
+	}
+	c := &hs.Checks[idx]
+	if c.Type != config.CheckS3 {
+		return fmt.Errorf("not s3 check")
+	}
+	if opts.SecretKey == "" && opts.AccessKey != "" {
+		opts.SecretKey = c.S3Opts.SecretKey
+	}
+	var cc config.Check
+	setCfgS3Options(&cc, url, opts, maxAge)
+	c.URL, c.S3Opts = url, s3OptionsFromConfig(cc)
+	for i := range s.cfg.Hosts {
+		if s.cfg.Hosts[i].Name == hostName {
+			if idx < len(s.cfg.Hosts[i].Checks) {
+				setCfgS3Options(&s.cfg.Hosts[i].Checks[idx], url, opts, maxAge)
+			}
+			break
+		}
+	}
+	return s.saveConfigLocked()
+}
@@ -0,0 +1,52 @@
+package state
+
+import (
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/andrewsjg/simple-healthchecker/copilot/internal/config"
+)
+
+// scheduleFromConfig parses a check's schedule, monitoring it all the time
+// with a warning if the schedule can't be read
+func (s *State) scheduleFromConfig(hostName string, c config.Check) (string, config.Schedule) {
+	sched, err := config.ParseSchedule(c.Schedule)
+	if err != nil {
+		msg := fmt.Sprintf("%s check on %q is always monitored: %v", strings.ToUpper(string(c.Type)), hostName, err)
+		log.Printf("warning: %s", msg)
+		s.warnings = append(s.warnings, msg)
+		return "", nil
+	}
+	return strings.TrimSpace(c.Schedule), sched
+}
+
+// SetCheckSchedule sets when the check at idx is monitored; empty for always.
+// It takes effect on the next run.
+func (s *State) SetCheckSchedule(hostName string, idx int, schedule string) error {
+	schedule = strings.TrimSpace(schedule)
+	sched, err := config.ParseSchedule(schedule)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	hs, ok := s.hosts[hostName]
+	if !ok {
+		return fmt.Errorf("host not found")
+	}
+	if idx < 0 || idx >= len(hs.Checks) {
+		return fmt.Errorf("bad index")
+	}
+	hs.Checks[idx].Schedule = schedule
+	hs.Checks[idx].schedule = sched
+	for i := range s.cfg.Hosts {
+		if s.cfg.Hosts[i].Name == hostName {
+			if idx < len(s.cfg.Hosts[i].Checks) {
+				s.cfg.Hosts[i].Checks[idx].Schedule = schedule
+			}
+			break
+		}
+	}
+	return s.saveConfigLocked()
+}
@@ -0,0 +1,88 @@
+package state
+
+import (
+	"fmt"
+	"log"
+	"net"
+	"strconv"
+	"time"
+
+	"github.com/andrewsjg/simple-healthchecker/copilot/internal/checks"
+	"github.com/andrewsjg/simple-healthchecker/copilot/internal/mqtt"
+)
+
+// selfCheckTimeout bounds each reference probe
+const selfCheckTimeout = 3 * time.Second
+
+// checkSelfCheckReferences records a warning for each self-check reference
+// that isn't a usable "host:port"
+func (s *State) checkSelfCheckReferences() {
+	for _, ref := range s.cfg.Settings.SelfCheck.References {
+		if _, _, err := splitReference(ref); err != nil {
+			msg := fmt.Sprintf("Self-check reference %q ignored: %v", ref, err)
+			log.Printf("warning: %s", msg)
+			s.warnings = append(s.warnings, msg)
+		}
+	}
+}
+
+func splitReference(ref string) (string, int, error) {
+	host, portStr, err := net.SplitHostPort(ref)
+	if err != nil {
+		return "", 0, fmt.Errorf("want host:port")
+	}
+	port, err := strconv.Atoi(portStr)
+	if err != nil || port < 1 || port > 65535 {
+		return "", 0, fmt.Errorf("invalid port %q", portStr)
+	}
+	return host, port, nil
+}
+
+// monitorOnlineLocked probes the self-check references and reports whether
+// the monitor can reach the outside world. With no references configured it
+// always reports true. Going offline and coming back are logged as events.
+func (s *State) monitorOnlineLocked(now time.Time) bool {
+	probed := false
+	online := false
+	for _, ref := range s.cfg.Settings.SelfCheck.References {
+		host, port, err := splitReference(ref)
+		if err != nil {
+			continue
+		}
+		probed = true
+		if s.checker.TCP(host, port, selfCheckTimeout, checks.DialOptions{}).OK {
+			online = true
+			break
+		}
+	}
+	if !probed {
+		online = true
+	}
+
+	switch {
+	case !online && s.monitorOffline.IsZero():
+		s.monitorOffline = now
+		logEvent(Event{Timestamp: now, HostName: "Monitor", EventType: "offline", Message: "No self-check reference answered; skipping checks until one does"})
+		for _, hs := range s.hosts {
+			for i := range hs.Checks {
+				hs.Checks[i].MonitorOffline = hs.Checks[i].Enabled
+			}
+		}
+	case online && !s.monitorOffline.IsZero():
+		downtime := now.Sub(s.monitorOffline).Round(time.Second)
+		s.monitorOffline = time.Time{}
+		msg := fmt.Sprintf("Monitor was offline for %v; checks are running again", downtime)
+		logEvent(Event{Timestamp: now, HostName: "Monitor", EventType: "recovered", Message: msg, Duration: downtime})
+		s.sendNoticeLocked(mqtt.ConnectivityMessage{Timestamp: time.Now(), Status: "monitor_online", Message: msg},
+			"📡 Monitor back online", msg+".")
+	}
+	return online
+}
+
+// MonitorOfflineSince returns when the self-check references stopped
+// answering, or the zero time if the monitor is online
+func (s *State) MonitorOfflineSince() time.Time {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.monitorOffline
+}
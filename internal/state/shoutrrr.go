@@ -0,0 +1,93 @@
+package state
+
+import (
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/andrewsjg/simple-healthchecker/copilot/internal/config"
+	"github.com/andrewsjg/simple-healthchecker/copilot/internal/shoutrrr"
+)
+
+// checkShoutrrr records a warning for each Shoutrrr URL that can't be used
+// and each check that notifies a label no URL has
+func (s *State) checkShoutrrr() {
+	settings := s.cfg.Settings.Shoutrrr
+	if !settings.Enabled {
+		return
+	}
+	var msgs []string
+	if err := shoutrrr.Validate(settings); err != nil {
+		for _, line := range strings.Split(err.Error(), "\n") {
+			msgs = append(msgs, fmt.Sprintf("Shoutrrr URL ignored: %s", line))
+		}
+	}
+	for _, h := range s.cfg.Hosts {
+		for _, c := range h.Checks {
+			for _, label := range c.ShoutrrrNotify {
+				if !s.shoutrrrClient.HasLabel(label) {
+					msgs = append(msgs, fmt.Sprintf("%s check on %q: no Shoutrrr URL is labelled %q", strings.ToUpper(string(c.Type)), h.Name, label))
+				}
+			}
+		}
+	}
+	for _, msg := range msgs {
+		log.Printf("warning: %s", msg)
+		s.warnings = append(s.warnings, msg)
+	}
+}
+
+// SetCheckShoutrrr updates the Shoutrrr labels the check at idx notifies
+func (s *State) SetCheckShoutrrr(hostName string, idx int, labels []string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	hs, ok := s.hosts[hostName]
+	if !ok {
+		return fmt.Errorf("host not found")
+	}
+	if idx < 0 || idx >= len(hs.Checks) {
+		return fmt.Errorf("bad index")
+	}
+	hs.Checks[idx].ShoutrrrNotify = labels
+	for i := range s.cfg.Hosts {
+		if s.cfg.Hosts[i].Name == hostName {
+			if idx < len(s.cfg.Hosts[i].Checks) {
+				s.cfg.Hosts[i].Checks[idx].ShoutrrrNotify = labels
+			}
+			break
+		}
+	}
+	return s.saveConfigLocked()
+}
+
+// GetShoutrrrSettings returns the current Shoutrrr settings
+func (s *State) GetShoutrrrSettings() config.ShoutrrrSettings {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.cfg.Settings.Shoutrrr
+}
+
+// ShoutrrrLabels returns the labels of the usable Shoutrrr URLs, in config
+// order
+func (s *State) ShoutrrrLabels() []string {
+	if s.shoutrrrClient == nil {
+		return nil
+	}
+	return s.shoutrrrClient.Labels()
+}
+
+// IsShoutrrrEnabled returns whether Shoutrrr notifications are enabled
+func (s *State) IsShoutrrrEnabled() bool {
+	if s.shoutrrrClient == nil {
+		return false
+	}
+	return s.shoutrrrClient.IsEnabled()
+}
+
+// TestShoutrrr sends a test notification to the URLs labelled label
+func (s *State) TestShoutrrr(label string) error {
+	if s.shoutrrrClient == nil {
+		return fmt.Errorf("shoutrrr client not initialized")
+	}
+	return s.shoutrrrClient.TestNotification(label)
+}
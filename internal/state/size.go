@@ -0,0 +1,60 @@
+package state
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/andrewsjg/simple-healthchecker/copilot/internal/checks"
+)
+
+// minThroughputSize is the smallest body a download speed is worked out
+// from; smaller ones arrive in a packet or two and say nothing about it
+const minThroughputSize = 64 << 10
+
+// SizeStats summarises the response sizes in an http check's history
+type SizeStats struct {
+	Avg, Min, Max int64
+	Throughput    float64 // Bytes per second reading bodies of at least 64 KB; 0 if none were
+}
+
+// checkSize applies an http check's size limits to a response body of size
+// bytes, returning why it is unacceptable or "" if it passes
+func (c *CheckStatus) checkSize(size int64) string {
+	switch {
+	case c.MinSize > 0 && size < c.MinSize:
+		return fmt.Sprintf("body is %s, expected at least %s", checks.FormatBytes(size), checks.FormatBytes(c.MinSize))
+	case c.MaxSize > 0 && size > c.MaxSize:
+		return fmt.Sprintf("body is %s, expected at most %s", checks.FormatBytes(size), checks.FormatBytes(c.MaxSize))
+	}
+	return ""
+}
+
+// sizeStats summarises the sizes recorded in history. Points without a
+// response, or with an empty body, are left out.
+func sizeStats(history []CheckDataPoint) SizeStats {
+	var st SizeStats
+	var sum, n, bulk int64
+	var download time.Duration
+	for _, dp := range history {
+		if dp.Size <= 0 {
+			continue
+		}
+		if n == 0 || dp.Size < st.Min {
+			st.Min = dp.Size
+		}
+		st.Max = max(st.Max, dp.Size)
+		sum += dp.Size
+		n++
+		if dp.Size >= minThroughputSize && dp.Download > 0 {
+			bulk += dp.Size
+			download += dp.Download
+		}
+	}
+	if n > 0 {
+		st.Avg = sum / n
+	}
+	if download > 0 {
+		st.Throughput = float64(bulk) / download.Seconds()
+	}
+	return st
+}
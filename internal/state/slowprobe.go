@@ -0,0 +1,123 @@
+package state
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/andrewsjg/simple-healthchecker/copilot/internal/checks"
+	"github.com/andrewsjg/simple-healthchecker/copilot/internal/config"
+)
+
+// slowProbe is the result of a probe that can take many seconds: a
+// speedtest, a WHOIS or RDAP lookup, or a round of blocklist queries. These are run before a run takes the write lock, so the
+// dashboard and edits aren't held up while they go, and recorded with the
+// rest of the run.
+type slowProbe struct {
+	target string // What was probed; see slowTarget
+	id     string // The probe's ID, for the probe log
+	speed  checks.SpeedtestResult
+	domain checks.DomainResult
+	dnsbl  checks.DNSBLResult
+}
+
+// slowKey identifies a check by its host and position
+type slowKey struct {
+	host string
+	idx  int
+}
+
+// isSlow reports whether checks of type t are probed before the lock is
+// taken
+func isSlow(t config.CheckType) bool {
+	return t == config.CheckSpeedtest || t == config.CheckDomain || t == config.CheckDNSBL
+}
+
+// slowTarget describes what a slow check probes, so a result isn't
+// recorded against a check edited while it ran
+func slowTarget(hs *HostStatus, c *CheckStatus) string {
+	switch c.Type {
+	case config.CheckDomain:
+		return fmt.Sprintf("%s %s %+v", c.Type, domainName(hs, c), c.DomainOpts)
+	case config.CheckDNSBL:
+		return fmt.Sprintf("%s %s %+v", c.Type, hs.Address, c.DNSBLOpts)
+	}
+	return fmt.Sprintf("%s %s %+v", c.Type, hs.Address, c.SpeedtestOpts)
+}
+
+// pendingSlowProbe is a slow probe to run, with what it needs snapshotted
+// under the lock
+type pendingSlowProbe struct {
+	key     slowKey
+	typ     config.CheckType
+	target  string
+	address string
+	speed   checks.SpeedtestOptions
+	domain  checks.DomainOptions
+	dnsbl   checks.DNSBLOptions
+}
+
+// runSlowProbes runs the slow checks in scope that are due, holding only a
+// read lock while it finds them and none while they run
+func (s *State) runSlowProbes(now time.Time, scope runScope) map[slowKey]slowProbe {
+	var pending []pendingSlowProbe
+	s.mu.RLock()
+	for _, hs := range s.hosts {
+		if scope.host != "" && hs.Name != scope.host {
+			continue
+		}
+		for i := range hs.Checks {
+			c := &hs.Checks[i]
+			if !isSlow(c.Type) || !c.Enabled || (scope.check != "" && c.ID != scope.check) || !c.schedule.Active(now) || (!scope.manual && !c.slowProbeDue(now)) {
+				continue
+			}
+			p := pendingSlowProbe{key: slowKey{hs.Name, i}, typ: c.Type, target: slowTarget(hs, c), address: hs.Address}
+			switch c.Type {
+			case config.CheckSpeedtest:
+				p.speed = c.SpeedtestOpts
+				if p.speed.URL != "" {
+					p.speed.Identity = s.probeIdentityLocked(c)
+				}
+			case config.CheckDomain:
+				p.address = domainName(hs, c)
+				p.domain = c.DomainOpts
+				p.domain.Identity = s.probeIdentityLocked(c)
+			case config.CheckDNSBL:
+				p.dnsbl = c.DNSBLOpts
+			}
+			pending = append(pending, p)
+		}
+	}
+	checker := s.checker
+	s.mu.RUnlock()
+
+	results := make(map[slowKey]slowProbe, len(pending))
+	for _, p := range pending {
+		res := slowProbe{target: p.target}
+		switch p.typ {
+		case config.CheckSpeedtest:
+			res.speed = checker.Speedtest(p.address, checks.SpeedtestDuration+20*time.Second, p.speed)
+			res.id = p.speed.Identity.ID
+		case config.CheckDomain:
+			res.domain = checker.Domain(p.address, 20*time.Second, p.domain)
+			res.id = p.domain.Identity.ID
+		case config.CheckDNSBL:
+			res.dnsbl = checker.DNSBL(p.address, 20*time.Second, p.dnsbl)
+		}
+		results[p.key] = res
+	}
+	return results
+}
+
+// slowProbeDue reports whether a slow check is due to run on its own
+// interval
+func (c *CheckStatus) slowProbeDue(now time.Time) bool {
+	switch c.Type {
+	case config.CheckSpeedtest:
+		return c.speedtestDue(now)
+	case config.CheckDomain:
+		return c.domainDue(now)
+	case config.CheckDNSBL:
+		return c.dnsblDue(now)
+	}
+	return true
+}
@@ -0,0 +1,106 @@
+package state
+
+import (
+	"testing"
+	"time"
+
+	"github.com/andrewsjg/simple-healthchecker/copilot/internal/checks"
+	"github.com/andrewsjg/simple-healthchecker/copilot/internal/config"
+)
+
+// heldChecker holds up slow probes until released
+type heldChecker struct {
+	checks.Checker
+	started, release chan struct{}
+}
+
+func (h heldChecker) Speedtest(host string, timeout time.Duration, opts checks.SpeedtestOptions) checks.SpeedtestResult {
+	h.started <- struct{}{}
+	<-h.release
+	return h.Checker.Speedtest(host, timeout, opts)
+}
+
+func (h heldChecker) Domain(domain string, timeout time.Duration, opts checks.DomainOptions) checks.DomainResult {
+	h.started <- struct{}{}
+	<-h.release
+	return h.Checker.Domain(domain, timeout, opts)
+}
+
+func (h heldChecker) DNSBL(host string, timeout time.Duration, opts checks.DNSBLOptions) checks.DNSBLResult {
+	h.started <- struct{}{}
+	<-h.release
+	return h.Checker.DNSBL(host, timeout, opts)
+}
+
+func TestSlowProbesRunWithoutTheLock(t *testing.T) {
+	for _, typ := range []config.CheckType{config.CheckSpeedtest, config.CheckDomain, config.CheckDNSBL} {
+		t.Run(string(typ), func(t *testing.T) {
+			st, fake := newFakeState(&config.Config{Hosts: []config.Host{{
+				Name:    "lan",
+				Address: "192.168.1.10",
+				Checks:  []config.Check{{Type: typ, Enabled: true}},
+			}}})
+			held := heldChecker{Checker: fake, started: make(chan struct{}), release: make(chan struct{})}
+			st.SetChecker(held)
+
+			done := make(chan struct{})
+			go func() {
+				st.runAt(time.Now())
+				close(done)
+			}()
+			<-held.started
+
+			// Edits and page loads go ahead while the probe runs
+			edited := make(chan error)
+			go func() { edited <- st.SetHostTags("lan", []string{"office"}) }()
+			select {
+			case err := <-edited:
+				if err != nil {
+					t.Fatal(err)
+				}
+			case <-time.After(5 * time.Second):
+				t.Fatalf("an edit waited for a %s probe to finish", typ)
+			}
+			if _, ok := st.GetHost("lan"); !ok {
+				t.Fatal("host lan is missing")
+			}
+
+			close(held.release)
+			<-done
+			c := check(t, st, "lan", 0)
+			if c.CheckedAt.IsZero() || !c.OK || len(c.FullHistory) != 1 {
+				t.Fatalf("%s check recorded as %v, %q with %d points; want one pass", typ, c.OK, c.Message, len(c.FullHistory))
+			}
+			if typ == config.CheckSpeedtest && c.FullHistory[0].Speed != 100_000_000 {
+				t.Errorf("speed = %d, want 100 Mbps", c.FullHistory[0].Speed)
+			}
+		})
+	}
+}
+
+func TestSlowProbeOfEditedCheckIsDropped(t *testing.T) {
+	st, fake := newFakeState(&config.Config{Hosts: []config.Host{{
+		Name:    "lan",
+		Address: "192.168.1.10",
+		Checks:  []config.Check{{Type: config.CheckSpeedtest, Enabled: true}},
+	}}})
+	held := heldChecker{Checker: fake, started: make(chan struct{}), release: make(chan struct{})}
+	st.SetChecker(held)
+
+	done := make(chan struct{})
+	go func() {
+		st.runAt(time.Now())
+		close(done)
+	}()
+	<-held.started
+	if err := st.SetCheckSpeedtest("lan", 0, checks.SpeedtestOptions{URL: "https://speed.example.com/1GB.bin"}); err != nil {
+		t.Fatal(err)
+	}
+	close(held.release)
+	<-done
+
+	// The result was for iperf3, not the download now configured
+	if c := check(t, st, "lan", 0); !c.CheckedAt.IsZero() {
+		t.Errorf("a result for the check as it was before the edit was recorded: %q", c.Message)
+	}
+}
@@ -0,0 +1,94 @@
+package state
+
+import (
+	"fmt"
+
+	"github.com/andrewsjg/simple-healthchecker/copilot/internal/checks"
+	"github.com/andrewsjg/simple-healthchecker/copilot/internal/config"
+)
+
+// smbOptionsFromConfig extracts an smb check's share and login
+func smbOptionsFromConfig(c config.Check) checks.SMBOptions {
+	return checks.SMBOptions{Share: c.Share, User: c.Username, Password: c.Password, Port: c.Port}
+}
+
+// setCfgSMBOptions copies an smb check's share and login into its config
+func setCfgSMBOptions(c *config.Check, opts checks.SMBOptions) {
+	c.Share = opts.Share
+	c.Username = opts.User
+	c.Password = opts.Password
+	c.Port = opts.Port
+}
+
+// smbMessage describes an smb check's result, e.g. "share backups ok (SMB
+// 3.0.2)", or just the dialect when no share is set
+func smbMessage(res checks.SMBResult, opts checks.SMBOptions) string {
+	switch {
+	case res.Err != nil:
+		return res.Err.Error()
+	case opts.Share == "":
+		return "SMB " + res.Dialect
+	case res.Guest:
+		return fmt.Sprintf("share %s ok as guest (SMB %s)", opts.Share, res.Dialect)
+	}
+	return fmt.Sprintf("share %s ok (SMB %s)", opts.Share, res.Dialect)
+}
+
+// AddSMBCheck appends a file share check to the named host
+func (s *State) AddSMBCheck(hostName string, opts checks.SMBOptions, id, dependsOn string, mqttNotify, pushoverNotify, telegramNotify bool) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	hs, ok := s.hosts[hostName]
+	if !ok {
+		return fmt.Errorf("host not found")
+	}
+	if opts.Password != "" && opts.User == "" {
+		return fmt.Errorf("a password needs a username")
+	}
+	if s.checkIDInUseLocked(id, "") {
+		return fmt.Errorf("check id %q already in use", id)
+	}
+	c := config.Check{Type: config.CheckSMB, Enabled: true, ID: id, DependsOn: dependsOn, MQTTNotify: mqttNotify, PushoverNotify: pushoverNotify, TelegramNotify: telegramNotify}
+	setCfgSMBOptions(&c, opts)
+	hs.Checks = append(hs.Checks, CheckStatus{Type: config.CheckSMB, Enabled: true, SMBOpts: opts, ID: id, DependsOn: dependsOn, MQTTNotify: mqttNotify, PushoverNotify: pushoverNotify, TelegramNotify: telegramNotify, Severity: config.SeverityWarning})
+	for i := range s.cfg.Hosts {
+		if s.cfg.Hosts[i].Name == hostName {
+			s.cfg.Hosts[i].Checks = append(s.cfg.Hosts[i].Checks, c)
+			break
+		}
+	}
+	s.rebuildCheckIndex()
+	return s.saveConfigLocked()
+}
+
+// SetCheckSMB updates the share and login of the smb check at idx. An empty
+// password with a username keeps the current one, so it needn't be shown
+// to be edited.
+func (s *State) SetCheckSMB(hostName string, idx int, opts checks.SMBOptions) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	hs, ok := s.hosts[hostName]
+	if !ok {
+		return fmt.Errorf("host not found")
+	}
+	if idx < 0 || idx >= len(hs.Checks) {
+		return fmt.Errorf("bad index")
+	}
+	c := &hs.Checks[idx]
+	if c.Type != config.CheckSMB {
+		return fmt.Errorf("not smb check")
+	}
+	if opts.Password == "" && opts.User != "" {
+		opts.Password = c.SMBOpts.Password
+	}
+	c.SMBOpts = opts
+	for i := range s.cfg.Hosts {
+		if s.cfg.Hosts[i].Name == hostName {
+			if idx < len(s.cfg.Hosts[i].Checks) {
+				setCfgSMBOptions(&s.cfg.Hosts[i].Checks[idx], opts)
+			}
+			break
+		}
+	}
+	return s.saveConfigLocked()
+}
@@ -0,0 +1,146 @@
+package state
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/andrewsjg/simple-healthchecker/copilot/internal/checks"
+	"github.com/andrewsjg/simple-healthchecker/copilot/internal/config"
+)
+
+// SpeedStats summarises the speeds in a speedtest check's history, in bits
+// per second
+type SpeedStats struct {
+	Avg, Min, Max int64
+}
+
+// speedtestOptionsFromConfig extracts how a speedtest check measures
+// bandwidth, its speed limit and how often it runs
+func speedtestOptionsFromConfig(c config.Check) checks.SpeedtestOptions {
+	return checks.SpeedtestOptions{
+		URL:      c.URL,
+		Upload:   c.Upload,
+		Port:     c.Port,
+		MinSpeed: c.SpeedLimit(),
+		Interval: c.SpeedtestInterval(),
+	}
+}
+
+// setCfgSpeedtestOptions copies a speedtest check's options into its config
+func setCfgSpeedtestOptions(c *config.Check, opts checks.SpeedtestOptions) {
+	c.URL = opts.URL
+	c.Upload = opts.Upload
+	c.Port = opts.Port
+	c.MinSpeed = checks.FormatSpeedLimit(opts.MinSpeed)
+	c.Interval = ""
+	if opts.Interval > 0 {
+		c.Interval = formatAge(opts.Interval)
+	}
+}
+
+// speedtestMessage describes a speedtest check's result, e.g. "download
+// 94.2 Mbps" or "upload 8.12 Mbps (below 10.0 Mbps)"
+func speedtestMessage(res checks.SpeedtestResult, opts checks.SpeedtestOptions) string {
+	if res.Err != nil {
+		return res.Err.Error()
+	}
+	msg := "download " + checks.FormatSpeed(res.Speed)
+	if opts.Upload && opts.URL == "" {
+		msg = "upload " + checks.FormatSpeed(res.Speed)
+	}
+	if res.Speed < opts.MinSpeed {
+		msg += fmt.Sprintf(" (below %s)", checks.FormatSpeed(opts.MinSpeed))
+	}
+	return msg
+}
+
+// speedtestDue reports whether a speedtest check is due to run: it hasn't
+// yet, or its interval has passed since it last did
+func (c *CheckStatus) speedtestDue(now time.Time) bool {
+	return c.CheckedAt.IsZero() || now.Sub(c.CheckedAt) >= c.SpeedtestOpts.Every()
+}
+
+// noteSpeed adds a speedtest's speed to the data point just recorded for
+// it. Unlike a response's size, it is kept when the check failed for being
+// too slow, as those are the runs worth seeing on the chart.
+func (c *CheckStatus) noteSpeed(at time.Time, res checks.SpeedtestResult) {
+	n := len(c.FullHistory)
+	if n == 0 || !c.FullHistory[n-1].Timestamp.Equal(at) || res.Err != nil {
+		return
+	}
+	c.FullHistory[n-1].Speed = res.Speed
+}
+
+// speedStats summarises the speeds recorded in history. Points without one
+// are left out.
+func speedStats(history []CheckDataPoint) SpeedStats {
+	var st SpeedStats
+	var sum, n int64
+	for _, dp := range history {
+		if dp.Speed <= 0 {
+			continue
+		}
+		if n == 0 || dp.Speed < st.Min {
+			st.Min = dp.Speed
+		}
+		st.Max = max(st.Max, dp.Speed)
+		sum += dp.Speed
+		n++
+	}
+	if n > 0 {
+		st.Avg = sum / n
+	}
+	return st
+}
+
+// AddSpeedtestCheck appends a speedtest check to the named host
+func (s *State) AddSpeedtestCheck(hostName string, opts checks.SpeedtestOptions, id, dependsOn string, mqttNotify, pushoverNotify, telegramNotify bool) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	hs, ok := s.hosts[hostName]
+	if !ok {
+		return fmt.Errorf("host not found")
+	}
+	if s.checkIDInUseLocked(id, "") {
+		return fmt.Errorf("check id %q already in use", id)
+	}
+	c := config.Check{Type: config.CheckSpeedtest, Enabled: true, ID: id, DependsOn: dependsOn, MQTTNotify: mqttNotify, PushoverNotify: pushoverNotify, TelegramNotify: telegramNotify}
+	setCfgSpeedtestOptions(&c, opts)
+	hs.Checks = append(hs.Checks, CheckStatus{Type: config.CheckSpeedtest, Enabled: true, URL: opts.URL, SpeedtestOpts: opts, ID: id, DependsOn: dependsOn, MQTTNotify: mqttNotify, PushoverNotify: pushoverNotify, TelegramNotify: telegramNotify, Severity: config.SeverityWarning})
+	for i := range s.cfg.Hosts {
+		if s.cfg.Hosts[i].Name == hostName {
+			s.cfg.Hosts[i].Checks = append(s.cfg.Hosts[i].Checks, c)
+			break
+		}
+	}
+	s.rebuildCheckIndex()
+	return s.saveConfigLocked()
+}
+
+// SetCheckSpeedtest updates how the speedtest check at idx measures
+// bandwidth, its speed limit and how often it runs
+func (s *State) SetCheckSpeedtest(hostName string, idx int, opts checks.SpeedtestOptions) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	hs, ok := s.hosts[hostName]
+	if !ok {
+		return fmt.Errorf("host not found")
+	}
+	if idx < 0 || idx >= len(hs.Checks) {
+		return fmt.Errorf("bad index")
+	}
+	c := &hs.Checks[idx]
+	if c.Type != config.CheckSpeedtest {
+		return fmt.Errorf("not speedtest check")
+	}
+	c.URL, c.SpeedtestOpts = opts.URL, opts
+	for i := range s.cfg.Hosts {
+		if s.cfg.Hosts[i].Name == hostName {
+			if idx < len(s.cfg.Hosts[i].Checks) {
+				setCfgSpeedtestOptions(&s.cfg.Hosts[i].Checks[idx], opts)
+			}
+			break
+		}
+	}
+	return s.saveConfigLocked()
+}
@@ -5,64 +5,191 @@ import (
 	"fmt"
 	"log"
 	"net/http"
+	"net/url"
 	"os"
 	"path/filepath"
+	"regexp"
+	"slices"
 	"sort"
+	"strconv"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/BurntSushi/toml"
-	"gopkg.in/yaml.v3"
 
 	"github.com/andrewsjg/simple-healthchecker/copilot/internal/checks"
 	"github.com/andrewsjg/simple-healthchecker/copilot/internal/config"
+	"github.com/andrewsjg/simple-healthchecker/copilot/internal/homeassistant"
+	"github.com/andrewsjg/simple-healthchecker/copilot/internal/metrics"
 	"github.com/andrewsjg/simple-healthchecker/copilot/internal/mqtt"
+	"github.com/andrewsjg/simple-healthchecker/copilot/internal/notify"
 	"github.com/andrewsjg/simple-healthchecker/copilot/internal/pushover"
+	"github.com/andrewsjg/simple-healthchecker/copilot/internal/shoutrrr"
 	"github.com/andrewsjg/simple-healthchecker/copilot/internal/telegram"
+	"github.com/andrewsjg/simple-healthchecker/copilot/internal/tracing"
+	"github.com/andrewsjg/simple-healthchecker/copilot/internal/twilio"
+	"github.com/andrewsjg/simple-healthchecker/copilot/internal/validate"
 )
 
 // CheckDataPoint represents a single check result with timestamp
 type CheckDataPoint struct {
 	Timestamp time.Time
 	OK        bool
-	LatencyMS int64
+	Latency   time.Duration
+	Timing    checks.HTTPTiming // Where an http check's time went; zero for other checks
+	Size      int64             // Response body bytes, for http checks
+	Download  time.Duration     // Time reading the body took, for http checks
+	Jitter    time.Duration     // Mean difference between consecutive replies, for ping checks sending several
+	StdDev    time.Duration     // Standard deviation of the replies, likewise
+	Speed     int64             // Bits per second, for speedtest checks
+	Gap       time.Duration     // Time with no data before it, while the monitor was asleep
+	Seq       uint64            // Increases with every point recorded on any check, so charts can be cached by the points they show
 }
 
+// dataPointSeq numbers recorded data points; see CheckDataPoint.Seq
+var dataPointSeq atomic.Uint64
+
 // Event represents a state change (up->down or down->up)
 type Event struct {
 	Timestamp time.Time
 	HostName  string
 	CheckIdx  int
+	CheckID   string
+	CheckName string // The check's display name, if it has one
 	CheckType config.CheckType
 	EventType string // "down", "up", "recovered"
 	Message   string
-	Duration  time.Duration // For recovery events, how long it was down
+	Duration  time.Duration // For recovery events, how long it was down, including any time with no data
+	Blocked   []string      // For down events, the dependent checks this failure blocks
 }
 
 type CheckStatus struct {
 	Type           config.CheckType
+	Name           string // Display name; empty to describe the check by type and target
 	Enabled        bool
 	OK             bool
 	ParentFailed   bool   // True if this check's parent dependency is down
-	ParentID       string // ID of the parent check this depends on
+	ParentID       string // ID of the parent check this depends on, or the gateway host
 	Message        string
-	LatencyMS      int64
-	LatencyHistory []int64          // Rolling history for sparkline (last 20)
+	Latency        time.Duration
+	LatencyHistory []time.Duration  // Rolling history for sparkline (last 20)
 	FullHistory    []CheckDataPoint // Extended history for analytics (last 1000)
 	CheckedAt      time.Time
 	URL            string
 	Expect         int
-	Port           int    // TCP port for tcp checks
-	ID             string // Unique identifier for this check (for dependencies)
-	DependsOn      string // ID of the check this depends on
-	MQTTNotify     bool   // Send MQTT notifications on state change
-	PushoverNotify bool   // Send Pushover notifications on state change
-	TelegramNotify bool   // Send Telegram notifications on state change
+	Port           int                      // TCP port for tcp checks
+	ID             string                   // Unique identifier for this check (for dependencies)
+	DependsOn      string                   // ID of the check this depends on
+	MQTTNotify     bool                     // Send MQTT notifications on state change
+	PushoverNotify bool                     // Send Pushover notifications on state change
+	TelegramNotify bool                     // Send Telegram notifications on state change
+	ShoutrrrNotify []string                 // Labels of the Shoutrrr URLs to notify on state change
+	SMSNotify      bool                     // Text the Twilio numbers on state change
+	Invert         bool                     // Passes when the probe fails, i.e. the target can't be reached
+	HTTPOpts       checks.HTTPOptions       // Redirect, proxy and TLS options for http checks
+	DialOpts       checks.DialOptions       // Address family and source address for http, tcp and ports checks
+	PingOpts       checks.PingOptions       // Probe method for ping checks
+	PingMethod     string                   // How the last ping was sent, e.g. "icmp" or "tcp/443"
+	MaxJitter      time.Duration            // Highest acceptable jitter, for ping checks; 0 for no limit
+	MaxAge         time.Duration            // How long a webhook check can go without a result; 0 for no limit
+	SSHOpts        checks.SSHOptions        // Command and expectations for ssh checks
+	FileOpts       checks.FileOptions       // Path, age limit and SFTP login for file checks
+	S3Opts         checks.S3Options         // Bucket, object and credentials for s3 checks
+	IPPOpts        checks.IPPOptions        // TLS verification for ipp checks
+	SMBOpts        checks.SMBOptions        // Share and login for smb checks
+	KafkaOpts      checks.KafkaOptions      // Topic and port for kafka checks
+	AMQPOpts       checks.AMQPOptions       // Virtual host, login and TLS for amqp checks
+	KubernetesOpts checks.KubernetesOptions // Credentials and deployment for kubernetes checks
+	ProxmoxOpts    checks.ProxmoxOptions    // API token, node and guest for proxmox checks
+	ESXiOpts       checks.ESXiOptions       // Login and VM for esxi checks
+	UPSOpts        checks.UPSOptions        // Daemon, UPS and battery limit for ups checks
+	SpeedtestOpts  checks.SpeedtestOptions  // Download or iperf3 server, speed limit and interval for speedtest checks
+	PublicIPOpts   checks.PublicIPOptions   // Address family looked up by publicip checks
+	PublicIP       string                   // Monitor's public address, for publicip checks
+	DomainOpts     checks.DomainOptions     // Domain and expiry warning for domain checks
+	DomainExpiry   time.Time                // When the domain expires, or zero if the last lookup failed
+	DNSBLOpts      checks.DNSBLOptions      // Blocklists looked up by dnsbl checks
+	DNSBLChecked   int                      // Blocklists that answered the last lookup, or 0 if none did
+	DNSBLListings  []checks.DNSBLListing    // The host's listings found by the last lookup
+	WSOpts         checks.WebSocketOptions  // Message exchange for websocket checks
+	ScanOpts       checks.PortScanOptions   // Ports expected open and closed, for ports checks
+	AllOf          []string                 // Member check IDs that must all be up, for composite checks
+	AnyOf          []string                 // Member check IDs of which one must be up, for composite checks
+	LastFailure    *ResponseDetail          // Response from the last failed http check, if any
+	ContentHash    string                   // Accepted body hash, for http checks with WatchContent
+	MinSize        int64                    // Smallest acceptable body in bytes, for http checks; 0 for no limit
+	MaxSize        int64                    // Largest acceptable body in bytes, for http checks; 0 for no limit
+	ChangedHash    string                   // Body hash that differs from ContentHash, awaiting acceptance
+	Notes          string                   // What this check covers
+	RunbookURL     string                   // Where to start when this check fails
+	UserAgent      string                   // Overrides the configured User-Agent, for http and websocket checks
+	Severity       config.Severity          // info, warning or critical; never empty
+	FailStreak     int                      // Consecutive failed probes, reset on success
+	Schedule       string                   // When the check is monitored, e.g. "mon-fri 07:00-23:00"; empty for always
+	Annotations    []Annotation             // Notes on spans of history, oldest first
+	schedule       config.Schedule          // Schedule parsed
+	// Healthchecks.io signalling
+	HCNotify bool   // Result counts towards the host's Healthchecks.io URL
+	HCURL    string // Own Healthchecks.io ping URL, signalled with this check's result alone
 	// Uptime tracking
-	TotalChecks   int64
-	SuccessChecks int64
-	LastDownAt    time.Time // When the check last went down
-	LastUpAt      time.Time // When the check last came up
+	TotalChecks     int64
+	SuccessChecks   int64
+	LastDownAt      time.Time // When the check last went down
+	LastUpAt        time.Time // When the check last came up
+	LastRemindedAt  time.Time // When the last still-down reminder was sent
+	LastCalledAt    time.Time // When the Twilio numbers were last called about an outage
+	alertSuppressed bool      // Down alert held back as part of a connectivity incident
+	MonitorOffline  bool      // Last run was skipped because the monitor itself was offline
+	Warmup          bool      // Failed during the startup grace period, so shown as pending
+	OffSchedule     bool      // Outside its schedule, so not being run
+	DownUntil       time.Time // Expected downtime set from the UI; failures before then are expected
+	Expected        bool      // Last run fell within the expected downtime
+	// Flap detection
+	Flapping bool        // Changing state too often; alerts are held until it settles
+	flips    []time.Time // Recent state changes, oldest first
+	flapDown bool        // The last alert before the flap was a down alert
+	// Expected latency
+	ExpectedLatency time.Duration // Latency above which a passing check is degraded; 0 for none
+	Degraded        bool          // Passed, but slower than ExpectedLatency
+	DegradedSince   time.Time     // When it became degraded
+	slowRuns        int           // Runs in a row over ExpectedLatency
+	// Gaps
+	gapBefore time.Duration // Time with no data before the next data point; see noteGapLocked
+	// Probe log
+	probes []ProbeAttempt // Recent runs, oldest first; see ProbeLog
+	// Resolved address, for checks of a hostname
+	ResolvedIP  string    // IP the last probe reached or tried
+	PrevIP      string    // IP before the last change
+	IPChangedAt time.Time // When ResolvedIP, or a publicip check's PublicIP, last changed
+	// TLS, for https and wss checks
+	TLS   *checks.TLSInfo // Connection the last handshake made, kept through failures
+	TLSAt time.Time       // When TLS was recorded
+}
+
+// ResponseDetail records what a server returned when an http check failed.
+// A new value is stored for each failure, so callers may share the pointer.
+type ResponseDetail struct {
+	At        time.Time
+	Status    string   // Status line, e.g. "502 Bad Gateway"
+	Headers   []string // "Name: value" lines, sorted by name
+	Body      string   // Start of the response body
+	Truncated bool     // Body was cut short
+}
+
+func newResponseDetail(at time.Time, res checks.HTTPResult) *ResponseDetail {
+	rd := &ResponseDetail{At: at, Status: res.Status, Body: res.Body, Truncated: res.Truncated}
+	if rd.Status == "" {
+		rd.Status = strconv.Itoa(res.Code)
+	}
+	for name, vals := range res.Header {
+		for _, v := range vals {
+			rd.Headers = append(rd.Headers, name+": "+v)
+		}
+	}
+	sort.Strings(rd.Headers)
+	return rd
 }
 
 const (
@@ -78,21 +205,63 @@ var (
 )
 
 type HostStatus struct {
-	Name    string
-	Address string
-	Checks  []CheckStatus
-	HCURL   string
+	Name       string
+	Address    string
+	Checks     []CheckStatus
+	HCURL      string
+	Notes      string   // Free-text notes about the host
+	RunbookURL string   // Runbook for the host's checks
+	Gateway    bool     // Uplink host every other host implicitly depends on
+	Tags       []string // Labels for grouping and searching
+	Remote     bool     // Fetched from settings.remote; edits last until the next refresh
+
+	AlertIPChange bool // Notify when a checked hostname resolves to a new IP
 }
 
 type State struct {
-	mu             sync.RWMutex
-	cfg            *config.Config
-	hosts          map[string]*HostStatus  // key: host name
-	checksByID     map[string]*CheckStatus // lookup checks by ID for dependency resolution
-	configPath     string
-	mqttClient     *mqtt.Client
-	pushoverClient *pushover.Client
-	telegramClient *telegram.Client
+	*shared
+	change change // Who config saves made through this State are put down to; see As
+}
+
+// shared is everything a State and the copies As makes of it have in common
+type shared struct {
+	mu               versionMutex
+	cfg              *config.Config
+	hosts            map[string]*HostStatus  // key: host name
+	checksByID       map[string]*CheckStatus // lookup checks by ID for dependency resolution
+	configPath       string
+	mqttClient       *mqtt.Client
+	pushoverClient   *pushover.Client
+	telegramClient   *telegram.Client
+	shoutrrrClient   *shoutrrr.Client
+	twilioClient     *twilio.Client
+	metricsClient    *metrics.Client
+	haClient         *homeassistant.Client
+	tracer           *tracing.Tracer
+	tickSpan         *tracing.Span           // Span of the scheduler run in progress, parent of its check and notification spans
+	warnings         []string                // Config problems shown as a banner in the UI
+	checker          checks.Checker          // Runs probes; swapped for a fake in tests
+	limiter          *checks.Limited         // Wraps checker to enforce the concurrency limits
+	paused           bool                    // Scheduler skips ticks while monitoring is paused
+	mutes            map[string]time.Time    // Notification channel -> mute expiry
+	pushoverDigest   []pushover.AlertMessage // Alerts held during quiet hours
+	telegramDigest   []telegram.AlertMessage
+	shoutrrrDigest   []shoutrrr.AlertMessage
+	smsDigest        []twilio.AlertMessage
+	batch            *alertBatch              // Open alert batch, nil when none is pending
+	connectivityDown time.Time                // When every host started failing at once; zero otherwise
+	monitorOffline   time.Time                // When the self-check references stopped answering; zero otherwise
+	displayLoc       *time.Location           // Configured display timezone; nil defers to each browser
+	payloadVersion   int                      // Schema version of MQTT and event stream payloads
+	annotationSeq    int                      // Last annotation ID handed out
+	started          time.Time                // When monitoring began, for the startup grace period
+	remoteStatus     RemoteStatus             // Last fetch of settings.remote
+	ticks            tickHistory              // Recent scheduler runs, for the monitor health page
+	lastRunAt        time.Time                // When the last run was recorded, with its monotonic reading; see runTimeLocked
+	runs             *runGate                 // Keeps scheduler runs from overlapping
+	history          configHistory            // Saved versions of the config, for undoing changes
+	trends           trendStore               // Daily aggregates of each check's runs
+	webhooks         map[string]WebhookResult // Last result posted for each webhook check, by check ID
 }
 
 func New(cfg *config.Config) *State {
@@ -110,93 +279,442 @@ func New(cfg *config.Config) *State {
 	// Initialize Telegram client
 	telegramClient := telegram.NewClient(cfg.Settings.Telegram)
 
-	st := &State{
+	// Initialize Shoutrrr client; checkShoutrrr warns about bad URLs
+	shoutrrrClient := shoutrrr.NewClient(cfg.Settings.Shoutrrr)
+
+	// Initialize Twilio client
+	twilioClient := twilio.NewClient(cfg.Settings.Twilio)
+
+	// Initialize metrics exporter; checkMetrics warns if it can't work
+	metricsClient := metrics.NewClient(cfg.Settings.Metrics)
+
+	// Every probe goes through the limiter; runHostsAt keeps its limits
+	// current
+	limiter := checks.NewLimited(checks.Network{})
+
+	// Initialize Home Assistant publisher; checkHomeAssistant warns if it
+	// can't work
+	haClient := homeassistant.NewClient(cfg.Settings.HomeAssistant)
+
+	st := &State{shared: &shared{
 		cfg:            cfg,
 		hosts:          make(map[string]*HostStatus),
 		checksByID:     make(map[string]*CheckStatus),
 		mqttClient:     mqttClient,
 		pushoverClient: pushoverClient,
 		telegramClient: telegramClient,
-	}
+		shoutrrrClient: shoutrrrClient,
+		twilioClient:   twilioClient,
+		metricsClient:  metricsClient,
+		haClient:       haClient,
+		tracer:         tracing.New(cfg.Settings.Tracing),
+		checker:        limiter,
+		limiter:        limiter,
+		mutes:          make(map[string]time.Time),
+		started:        time.Now(),
+		runs:           newRunGate(),
+	}}
 	for _, h := range cfg.Hosts {
-		hs := &HostStatus{Name: h.Name, Address: h.Address, HCURL: h.HealthchecksPingURL}
-		for _, c := range h.Checks {
-			cs := CheckStatus{
-				Type:           c.Type,
-				Enabled:        c.Enabled,
-				ID:             c.ID,
-				DependsOn:      c.DependsOn,
-				MQTTNotify:     c.MQTTNotify,
-				PushoverNotify: c.PushoverNotify,
-				TelegramNotify: c.TelegramNotify,
-			}
-			if c.Type == config.CheckHTTP {
-				cs.URL = c.URL
-				cs.Expect = c.Expect
-			}
-			if c.Type == config.CheckTCP {
-				cs.Port = c.Port
-			}
-			hs.Checks = append(hs.Checks, cs)
-		}
-		st.hosts[h.Name] = hs
-	}
+		st.hosts[h.Name] = st.hostStatusFromConfig(h)
+	}
+	// Duplicate IDs would make dependency resolution ambiguous
+	st.dedupeCheckIDs()
+	st.checkSelfCheckReferences()
+	st.checkDisplayTimezone()
+	st.checkMetrics()
+	st.checkShoutrrr()
+	st.checkHomeAssistant()
+	st.checkPayloads()
+	st.checkRemote()
 	// Build check lookup map for dependency resolution
 	st.rebuildCheckIndex()
+	st.checkCompositeMembers()
 	return st
 }
 
-// rebuildCheckIndex rebuilds the checksByID map after any changes
+// hostStatusFromConfig builds the runtime status of a configured host and its
+// checks, recording warnings for options that can't be used
+func (s *State) hostStatusFromConfig(h config.Host) *HostStatus {
+	hs := &HostStatus{Name: h.Name, Address: h.Address, HCURL: h.HealthchecksPingURL, Notes: h.Notes, RunbookURL: h.RunbookURL, Gateway: h.Gateway, Tags: h.Tags, Remote: h.Remote(), AlertIPChange: h.AlertIPChange}
+	if hcURL, err := validate.HealthchecksURL(h.HealthchecksPingURL); err != nil {
+		msg := fmt.Sprintf("Healthchecks.io URL on %q won't be pinged: %v", h.Name, err)
+		log.Printf("warning: %s", msg)
+		s.warnings = append(s.warnings, msg)
+	} else {
+		hs.HCURL = hcURL
+	}
+	for _, c := range h.Checks {
+		cs := CheckStatus{
+			Type:           c.Type,
+			Enabled:        c.Enabled,
+			Name:           c.Name,
+			ID:             c.ID,
+			DependsOn:      c.DependsOn,
+			MQTTNotify:     c.MQTTNotify,
+			PushoverNotify: c.PushoverNotify,
+			TelegramNotify: c.TelegramNotify,
+			ShoutrrrNotify: c.ShoutrrrNotify,
+			SMSNotify:      c.SMSNotify,
+			Invert:         c.Invert,
+			Notes:          c.Notes,
+			RunbookURL:     c.RunbookURL,
+			Severity:       c.Severity.OrDefault(),
+			UserAgent:      c.UserAgent,
+		}
+		cs.HCNotify = c.ReportsToHost()
+		if hcURL, err := validate.HealthchecksURL(c.HealthchecksPingURL); err != nil {
+			msg := fmt.Sprintf("%s check on %q: Healthchecks.io URL won't be pinged: %v", strings.ToUpper(string(c.Type)), h.Name, err)
+			log.Printf("warning: %s", msg)
+			s.warnings = append(s.warnings, msg)
+		} else {
+			cs.HCURL = hcURL
+		}
+		cs.Schedule, cs.schedule = s.scheduleFromConfig(h.Name, c)
+		cs.ExpectedLatency = c.LatencyLimit(s.cfg.Settings.Latency)
+		if c.Type == config.CheckHTTP {
+			cs.URL = c.URL
+			cs.Expect = c.Expect
+			cs.HTTPOpts = httpOptionsFromConfig(c)
+			cs.MinSize, cs.MaxSize = c.MinSize, c.MaxSize
+			cs.ContentHash = c.ContentHash
+		}
+		if c.Type == config.CheckTCP {
+			cs.Port = c.Port
+		}
+		if c.Type == config.CheckHTTP || c.Type == config.CheckTCP || c.Type == config.CheckPorts {
+			cs.DialOpts = s.dialOptionsFromConfig(h.Name, c)
+		}
+		if c.Type == config.CheckPing {
+			cs.PingOpts = s.pingOptionsFromConfig(h.Name, c)
+			cs.MaxJitter = c.JitterLimit()
+		}
+		if c.Type == config.CheckSSH {
+			cs.SSHOpts = s.sshOptionsFromConfig(h.Name, c)
+		}
+		if c.Type == config.CheckWS {
+			cs.URL = c.URL
+			cs.WSOpts = checks.WebSocketOptions{Send: c.WSSend, Expect: c.WSExpect}
+			s.checkPattern(h.Name, c, "ws_expect", c.WSExpect)
+		}
+		if c.Type == config.CheckPorts {
+			cs.ScanOpts = s.portScanOptionsFromConfig(h.Name, c)
+		}
+		if c.Type == config.CheckComposite {
+			cs.AllOf, cs.AnyOf = c.AllOf, c.AnyOf
+		}
+		if c.Type == config.CheckWebhook {
+			cs.MaxAge = c.MaxAgeLimit()
+		}
+		if c.Type == config.CheckFile {
+			cs.FileOpts = fileOptionsFromConfig(c)
+		}
+		if c.Type == config.CheckS3 {
+			cs.URL = c.URL
+			cs.S3Opts = s3OptionsFromConfig(c)
+		}
+		if c.Type == config.CheckIPP {
+			cs.URL = c.URL
+			cs.IPPOpts = checks.IPPOptions{InsecureSkipVerify: c.InsecureSkipVerify}
+		}
+		if c.Type == config.CheckSMB {
+			cs.SMBOpts = smbOptionsFromConfig(c)
+		}
+		if c.Type == config.CheckKafka {
+			cs.KafkaOpts = checks.KafkaOptions{Topic: c.Topic, Port: c.Port}
+		}
+		if c.Type == config.CheckAMQP {
+			cs.AMQPOpts = amqpOptionsFromConfig(c)
+		}
+		if c.Type == config.CheckKubernetes {
+			cs.URL = c.URL
+			cs.KubernetesOpts = kubernetesOptionsFromConfig(c)
+		}
+		if c.Type == config.CheckProxmox {
+			cs.ProxmoxOpts = proxmoxOptionsFromConfig(c)
+		}
+		if c.Type == config.CheckESXi {
+			cs.ESXiOpts = esxiOptionsFromConfig(c)
+		}
+		if c.Type == config.CheckUPS {
+			cs.UPSOpts = upsOptionsFromConfig(c)
+		}
+		if c.Type == config.CheckSpeedtest {
+			cs.URL = c.URL
+			cs.SpeedtestOpts = speedtestOptionsFromConfig(c)
+		}
+		if c.Type == config.CheckPublicIP {
+			cs.URL = c.URL
+			cs.PublicIPOpts = checks.PublicIPOptions{IPVersion: s.dialOptionsFromConfig(h.Name, c).IPVersion}
+			cs.PublicIP = c.PublicIP
+		}
+		if c.Type == config.CheckDomain {
+			cs.DomainOpts = domainOptionsFromConfig(c)
+		}
+		if c.Type == config.CheckDNSBL {
+			cs.DNSBLOpts = checks.DNSBLOptions{Blocklists: slices.Clone(c.Blocklists)}
+		}
+		hs.Checks = append(hs.Checks, cs)
+	}
+	return hs
+}
+
+// httpOptionsFromConfig extracts the HTTP client options from a check's config
+func httpOptionsFromConfig(c config.Check) checks.HTTPOptions {
+	return checks.HTTPOptions{
+		NoFollowRedirects:  c.NoFollowRedirects,
+		MaxRedirects:       c.MaxRedirects,
+		Proxy:              c.Proxy,
+		InsecureSkipVerify: c.InsecureSkipVerify,
+		MustContain:        c.MustContain,
+		MustNotContain:     c.MustNotContain,
+		WatchContent:       c.WatchContent,
+	}
+}
+
+// pingOptionsFromConfig extracts a ping check's method, port and count,
+// falling back to auto with a warning if the method isn't recognised
+func (s *State) pingOptionsFromConfig(hostName string, c config.Check) checks.PingOptions {
+	method, err := checks.ParsePingMethod(c.PingMethod)
+	if err != nil {
+		msg := fmt.Sprintf("Ping check on %q uses auto: ping_method %v", hostName, err)
+		log.Printf("warning: %s", msg)
+		s.warnings = append(s.warnings, msg)
+	}
+	return checks.PingOptions{Method: method, TCPPort: c.PingPort, Count: c.PingCount}
+}
+
+// dialOptionsFromConfig extracts a check's address family and source
+// address, ignoring an ip_version other than 4 or 6 with a warning
+func (s *State) dialOptionsFromConfig(hostName string, c config.Check) checks.DialOptions {
+	opts := checks.DialOptions{IPVersion: c.IPVersion, Source: c.Source}
+	if opts.IPVersion != 0 && opts.IPVersion != 4 && opts.IPVersion != 6 {
+		msg := fmt.Sprintf("%s check on %q: ip_version %d ignored; use 4 or 6", strings.ToUpper(string(c.Type)), hostName, c.IPVersion)
+		log.Printf("warning: %s", msg)
+		s.warnings = append(s.warnings, msg)
+		opts.IPVersion = 0
+	}
+	return opts
+}
+
+// checkPattern warns if a check's regexp option won't compile; the check
+// then fails on every run until the pattern is fixed
+func (s *State) checkPattern(hostName string, c config.Check, field, pattern string) {
+	if _, err := regexp.Compile(pattern); err != nil {
+		msg := fmt.Sprintf("%s check on %q: %s %v", strings.ToUpper(string(c.Type)), hostName, field, err)
+		log.Printf("warning: %s", msg)
+		s.warnings = append(s.warnings, msg)
+	}
+}
+
+// sshOptionsFromConfig extracts an ssh check's command and expectations
+func (s *State) sshOptionsFromConfig(hostName string, c config.Check) checks.SSHOptions {
+	s.checkPattern(hostName, c, "expect_output", c.ExpectOutput)
+	return checks.SSHOptions{
+		User:         c.SSHUser,
+		KeyFile:      c.SSHKey,
+		Port:         c.Port,
+		Command:      c.Command,
+		ExpectExit:   c.ExpectExit,
+		ExpectOutput: c.ExpectOutput,
+	}
+}
+
+// portScanOptionsFromConfig extracts a ports check's port lists. A list
+// that can't be read is left empty with a warning.
+func (s *State) portScanOptionsFromConfig(hostName string, c config.Check) checks.PortScanOptions {
+	var opts checks.PortScanOptions
+	for _, f := range []struct {
+		name, spec string
+		ports      *[]int
+	}{{"ports", c.Ports, &opts.Open}, {"closed_ports", c.ClosedPorts, &opts.Closed}} {
+		ports, err := validate.Ports(f.spec)
+		if err != nil {
+			msg := fmt.Sprintf("PORTS check on %q: %s %v", hostName, f.name, err)
+			log.Printf("warning: %s", msg)
+			s.warnings = append(s.warnings, msg)
+		}
+		*f.ports = ports
+	}
+	return opts
+}
+
+// setCfgSSHOptions copies ssh check options into a check's config
+func setCfgSSHOptions(c *config.Check, opts checks.SSHOptions) {
+	c.SSHUser = opts.User
+	c.SSHKey = opts.KeyFile
+	c.Port = opts.Port
+	c.Command = opts.Command
+	c.ExpectExit = opts.ExpectExit
+	c.ExpectOutput = opts.ExpectOutput
+}
+
+// setCfgHTTPOptions copies HTTP client options into a check's config
+func setCfgHTTPOptions(c *config.Check, opts checks.HTTPOptions) {
+	c.NoFollowRedirects = opts.NoFollowRedirects
+	c.MaxRedirects = opts.MaxRedirects
+	c.Proxy = opts.Proxy
+	c.InsecureSkipVerify = opts.InsecureSkipVerify
+	c.MustContain = opts.MustContain
+	c.MustNotContain = opts.MustNotContain
+	c.WatchContent = opts.WatchContent
+}
+
+// rebuildCheckIndex rebuilds the checksByID map after any changes. Hosts are
+// walked in config order so that if duplicate IDs slip through, the first
+// check in the config always wins dependency resolution.
 func (s *State) rebuildCheckIndex() {
 	s.checksByID = make(map[string]*CheckStatus)
-	for _, hs := range s.hosts {
+	for _, hs := range s.orderedHostsLocked() {
+		for i := range hs.Checks {
+			id := hs.Checks[i].ID
+			if id == "" {
+				continue
+			}
+			if _, dup := s.checksByID[id]; dup {
+				log.Printf("warning: duplicate check id %q on host %q ignored for dependencies", id, hs.Name)
+				continue
+			}
+			s.checksByID[id] = &hs.Checks[i]
+		}
+	}
+}
+
+// orderedHostsLocked returns the runtime hosts in config order
+func (s *State) orderedHostsLocked() []*HostStatus {
+	out := make([]*HostStatus, 0, len(s.hosts))
+	seen := make(map[string]bool, len(s.hosts))
+	for _, h := range s.cfg.Hosts {
+		if hs, ok := s.hosts[h.Name]; ok && !seen[h.Name] {
+			out = append(out, hs)
+			seen[h.Name] = true
+		}
+	}
+	for name, hs := range s.hosts {
+		if !seen[name] {
+			out = append(out, hs)
+		}
+	}
+	return out
+}
+
+// dedupeCheckIDs renames checks whose ID repeats an earlier one (in config
+// order) by appending a numeric suffix, recording a warning for the UI
+func (s *State) dedupeCheckIDs() {
+	used := make(map[string]bool)
+	for _, hs := range s.orderedHostsLocked() {
 		for i := range hs.Checks {
-			if hs.Checks[i].ID != "" {
-				s.checksByID[hs.Checks[i].ID] = &hs.Checks[i]
+			id := hs.Checks[i].ID
+			if id == "" {
+				continue
 			}
+			if !used[id] {
+				used[id] = true
+				continue
+			}
+			newID := id
+			for n := 2; used[newID]; n++ {
+				newID = fmt.Sprintf("%s-%d", id, n)
+			}
+			used[newID] = true
+			hs.Checks[i].ID = newID
+			s.setCfgCheckID(hs.Name, i, newID)
+			msg := fmt.Sprintf("Duplicate check ID %q on host %q was renamed to %q", id, hs.Name, newID)
+			log.Printf("warning: %s", msg)
+			s.warnings = append(s.warnings, msg)
 		}
 	}
 }
 
+// setCfgCheckID updates the persisted ID of a check so a rename survives the next save
+func (s *State) setCfgCheckID(hostName string, idx int, id string) {
+	for i := range s.cfg.Hosts {
+		if s.cfg.Hosts[i].Name == hostName {
+			if idx >= 0 && idx < len(s.cfg.Hosts[i].Checks) {
+				s.cfg.Hosts[i].Checks[idx].ID = id
+			}
+			return
+		}
+	}
+}
+
+// Warnings returns problems found in the config that the UI should surface
+func (s *State) Warnings() []string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return append([]string(nil), s.warnings...)
+}
+
 // GetCheckByID returns a check by its ID
 func (s *State) GetCheckByID(id string) (*CheckStatus, bool) {
 	c, ok := s.checksByID[id]
 	return c, ok
 }
 
+// CheckIDInUse reports whether id is used by any check other than the checks
+// at the skipped indices of hostName (the ones currently being edited)
+func (s *State) CheckIDInUse(id, hostName string, skip ...int) bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.checkIDInUseLocked(id, hostName, skip...)
+}
+
+func (s *State) checkIDInUseLocked(id, hostName string, skip ...int) bool {
+	if id == "" {
+		return false
+	}
+	for name, hs := range s.hosts {
+		for i := range hs.Checks {
+			if name == hostName && slices.Contains(skip, i) {
+				continue
+			}
+			if hs.Checks[i].ID == id {
+				return true
+			}
+		}
+	}
+	return false
+}
+
 // IsParentOK checks if the parent dependency (if any) is OK
 // Returns true if no dependency or parent is OK
 func (s *State) IsParentOK(c *CheckStatus) bool {
-	if c.DependsOn == "" {
-		return true // No dependency
-	}
-	parent, ok := s.checksByID[c.DependsOn]
-	if !ok {
-		return true // Dependency not found, treat as OK
-	}
-	if !parent.Enabled {
-		return true // Parent disabled, treat as OK
-	}
-	if parent.CheckedAt.IsZero() {
-		return true // Parent not checked yet, treat as OK
-	}
-	// Recursively check parent's parent
-	if !s.IsParentOK(parent) {
-		return false
+	// Walk up the chain iteratively; the bound stops a dependency cycle
+	// (possible once the gateway adds implicit edges) from looping forever
+	for range len(s.checksByID) + 2 {
+		parent := s.parentLocked(c)
+		if parent == nil {
+			return true // No dependency, or dependency not found
+		}
+		if !parent.Enabled || parent.OffSchedule {
+			return true // Parent disabled or off schedule, treat as OK
+		}
+		if parent.CheckedAt.IsZero() {
+			return true // Parent not checked yet, treat as OK
+		}
+		if !parent.OK {
+			return false
+		}
+		c = parent
 	}
-	return parent.OK
+	return true
 }
 
 // AggregateStats holds overall system health statistics
 type AggregateStats struct {
 	TotalHosts         int
+	HostsUp            int // Hosts whose rollup status is up
+	HostsDegraded      int // Hosts with some, but no key, checks down
+	HostsDown          int // Hosts with a key check down, or no check up
 	TotalChecks        int
 	ChecksUp           int
 	ChecksDown         int
 	ChecksParentFailed int // Checks down due to parent failure
 	ChecksDisabled     int
 	ChecksUnknown      int
-	OverallUptime      float64 // Percentage
+	ChecksOffSchedule  int     // Enabled checks outside their schedule
+	ChecksExpectedDown int     // Checks failing within expected downtime
+	ChecksInfoDown     int     // Down info-severity checks, not counted against health
+	OverallUptime      float64 // Percentage, excluding info-severity checks
 }
 
 // GetAggregateStats returns overall system health statistics
@@ -204,31 +722,74 @@ func (s *State) GetAggregateStats() AggregateStats {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
 
-	stats := AggregateStats{TotalHosts: len(s.hosts)}
+	hosts := make([]*HostStatus, 0, len(s.hosts))
+	for _, hs := range s.hosts {
+		hosts = append(hosts, hs)
+	}
+	return aggregate(hosts)
+}
+
+// GetAggregateStatsFor totals the checks of the hosts visible to a user
+// limited to tags, as HostStatus.VisibleTo decides
+func (s *State) GetAggregateStatsFor(tags []string) AggregateStats {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var hosts []*HostStatus
+	for _, hs := range s.hosts {
+		if hs.VisibleTo(tags) {
+			hosts = append(hosts, hs)
+		}
+	}
+	return aggregate(hosts)
+}
+
+// aggregate totals the checks of the given hosts; callers hold the lock
+func aggregate(hosts []*HostStatus) AggregateStats {
+	stats := AggregateStats{TotalHosts: len(hosts)}
 
 	var totalUptimeSum float64
 	var uptimeCount int
 
-	for _, hs := range s.hosts {
+	for _, hs := range hosts {
+		switch hs.Status() {
+		case HostUp:
+			stats.HostsUp++
+		case HostDegraded:
+			stats.HostsDegraded++
+		case HostDown:
+			stats.HostsDown++
+		}
 		for _, c := range hs.Checks {
 			stats.TotalChecks++
 			if !c.Enabled {
 				stats.ChecksDisabled++
 				continue
 			}
-			if c.CheckedAt.IsZero() {
+			if c.OffSchedule {
+				stats.ChecksOffSchedule++
+				continue
+			}
+			if c.ExpectedDown() {
+				stats.ChecksExpectedDown++
+				continue
+			}
+			if c.Pending() {
 				stats.ChecksUnknown++
 				continue
 			}
+			info := c.Severity == config.SeverityInfo
 			if c.OK {
 				stats.ChecksUp++
 			} else if c.ParentFailed {
 				stats.ChecksParentFailed++
+			} else if info {
+				stats.ChecksInfoDown++
 			} else {
 				stats.ChecksDown++
 			}
 			// Calculate uptime for this check
-			if c.TotalChecks > 0 {
+			if c.TotalChecks > 0 && !info {
 				totalUptimeSum += float64(c.SuccessChecks) / float64(c.TotalChecks) * 100
 				uptimeCount++
 			}
@@ -256,26 +817,40 @@ type HostAnalytics struct {
 	OverallUptime    float64
 	HealthScore      int // 0-100
 	HasBlockedChecks bool
+	Reliability      Reliability // The host counts as down while any check is
 }
 
 // CheckAnalytics contains detailed analytics for a single check
 type CheckAnalytics struct {
 	Type          config.CheckType
+	Name          string // Display name, if the check has one
 	URL           string
 	Enabled       bool
 	OK            bool
 	ParentFailed  bool
-	LatencyMS     int64
+	Latency       time.Duration
 	Uptime        float64 // Percentage
-	AvgLatency    float64
-	MinLatency    int64
-	MaxLatency    int64
-	P95Latency    int64
+	AvgLatency    time.Duration
+	MinLatency    time.Duration
+	MaxLatency    time.Duration
+	P95Latency    time.Duration
 	TotalChecks   int64
 	SuccessChecks int64
 	FailedChecks  int64
 	History       []CheckDataPoint
-	HeatmapData   []bool // Last 60 check results for heatmap
+	HeatmapData   []bool            // Last 60 check results for heatmap
+	LastFailure   *ResponseDetail   // Response from the last failed http check, if any
+	Idx           int               // Position of the check on its host
+	Annotations   []Annotation      // Notes on spans of history, oldest first
+	Reliability   Reliability       // MTTR and MTBF from the event log
+	AvgTiming     checks.HTTPTiming // Average phases of the http probes in History; zero if none were timed
+	Sizes         SizeStats         // Response sizes of the http probes in History
+	MinSize       int64             // The check's size limits, drawn on the size chart
+	MaxSize       int64
+	Jitter        JitterStats   // Spread of the replies of the ping probes in History
+	MaxJitter     time.Duration // The check's jitter limit, drawn on the jitter chart
+	Speeds        SpeedStats    // Speeds measured by the speedtest probes in History
+	MinSpeed      int64         // The check's speed limit, drawn on the speed chart
 }
 
 // GetHostAnalytics returns detailed analytics for a specific host
@@ -296,21 +871,36 @@ func (s *State) GetHostAnalytics(hostName string) (HostAnalytics, bool) {
 	var uptimeSum float64
 	var healthSum int
 	var hasBlockedChecks bool
+	outages, noData := checkOutages(hs.Name, time.Now())
+	var allOutages []outage
 
-	for _, c := range hs.Checks {
+	for i, c := range hs.Checks {
 		ca := CheckAnalytics{
+			Idx:           i,
 			Type:          c.Type,
+			Name:          c.Name,
 			URL:           c.URL,
 			Enabled:       c.Enabled,
 			OK:            c.OK,
 			ParentFailed:  c.ParentFailed,
-			LatencyMS:     c.LatencyMS,
+			Latency:       c.Latency,
 			TotalChecks:   c.TotalChecks,
 			SuccessChecks: c.SuccessChecks,
 			FailedChecks:  c.TotalChecks - c.SuccessChecks,
+			LastFailure:   c.LastFailure,
 			History:       make([]CheckDataPoint, len(c.FullHistory)),
+			Annotations:   slices.Clone(c.Annotations),
 		}
 		copy(ca.History, c.FullHistory)
+		ca.AvgTiming = averageTiming(c.FullHistory)
+		ca.Sizes = sizeStats(c.FullHistory)
+		ca.MinSize, ca.MaxSize = c.MinSize, c.MaxSize
+		ca.Jitter = jitterStats(c.FullHistory)
+		ca.MaxJitter = c.MaxJitter
+		ca.Speeds = speedStats(c.FullHistory)
+		ca.MinSpeed = c.SpeedtestOpts.MinSpeed
+		ca.Reliability = reliability(mergeOutages(outages[i]), noData)
+		allOutages = append(allOutages, outages[i]...)
 
 		// Track if any checks are blocked by parent failure
 		if c.ParentFailed {
@@ -325,28 +915,28 @@ func (s *State) GetHostAnalytics(hostName string) (HostAnalytics, bool) {
 
 		// Calculate latency stats from history
 		if len(c.FullHistory) > 0 {
-			var sum int64
+			var sum time.Duration
 			var count int64
-			ca.MinLatency = c.FullHistory[0].LatencyMS
-			ca.MaxLatency = c.FullHistory[0].LatencyMS
-			latencies := make([]int64, 0, len(c.FullHistory))
+			ca.MinLatency = c.FullHistory[0].Latency
+			ca.MaxLatency = c.FullHistory[0].Latency
+			latencies := make([]time.Duration, 0, len(c.FullHistory))
 
 			for _, dp := range c.FullHistory {
-				if dp.OK && dp.LatencyMS > 0 {
-					sum += dp.LatencyMS
+				if dp.OK && dp.Latency > 0 {
+					sum += dp.Latency
 					count++
-					latencies = append(latencies, dp.LatencyMS)
-					if dp.LatencyMS < ca.MinLatency {
-						ca.MinLatency = dp.LatencyMS
+					latencies = append(latencies, dp.Latency)
+					if dp.Latency < ca.MinLatency {
+						ca.MinLatency = dp.Latency
 					}
-					if dp.LatencyMS > ca.MaxLatency {
-						ca.MaxLatency = dp.LatencyMS
+					if dp.Latency > ca.MaxLatency {
+						ca.MaxLatency = dp.Latency
 					}
 				}
 			}
 
 			if count > 0 {
-				ca.AvgLatency = float64(sum) / float64(count)
+				ca.AvgLatency = sum / time.Duration(count)
 				// Calculate P95
 				sort.Slice(latencies, func(i, j int) bool { return latencies[i] < latencies[j] })
 				p95Idx := int(float64(len(latencies)) * 0.95)
@@ -385,6 +975,7 @@ func (s *State) GetHostAnalytics(hostName string) (HostAnalytics, bool) {
 		analytics.HealthScore = healthSum / len(hs.Checks)
 	}
 	analytics.HasBlockedChecks = hasBlockedChecks
+	analytics.Reliability = reliability(mergeOutages(allOutages), noData)
 
 	return analytics, true
 }
@@ -445,7 +1036,7 @@ func (s *State) AddHost(name, address, hcurl string) error {
 		return fmt.Errorf("host exists")
 	}
 	hs := &HostStatus{Name: name, Address: address, HCURL: hcurl}
-	hs.Checks = append(hs.Checks, CheckStatus{Type: config.CheckPing, Enabled: true})
+	hs.Checks = append(hs.Checks, CheckStatus{Type: config.CheckPing, Enabled: true, HCNotify: true, Severity: config.SeverityWarning})
 	s.hosts[name] = hs
 	// update cfg
 	s.cfg.Hosts = append(s.cfg.Hosts, config.Host{
@@ -512,19 +1103,407 @@ func (s *State) UpdateHost(oldName, newName, address, hcurl string) error {
 	return s.saveConfigLocked()
 }
 
-func (s *State) AddHTTPCheck(hostName, url string, expect int, id, dependsOn string, mqttNotify, pushoverNotify, telegramNotify bool) error {
+func (s *State) AddHTTPCheck(hostName, url string, expect int, opts checks.HTTPOptions, id, dependsOn string, mqttNotify, pushoverNotify, telegramNotify bool) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	hs, ok := s.hosts[hostName]
+	if !ok {
+		return fmt.Errorf("host not found")
+	}
+	if s.checkIDInUseLocked(id, "") {
+		return fmt.Errorf("check id %q already in use", id)
+	}
+	// append to runtime
+	hs.Checks = append(hs.Checks, CheckStatus{Type: config.CheckHTTP, Enabled: true, URL: url, Expect: expect, HTTPOpts: opts, ID: id, DependsOn: dependsOn, MQTTNotify: mqttNotify, PushoverNotify: pushoverNotify, TelegramNotify: telegramNotify, Severity: config.SeverityWarning})
+	// append to cfg
+	for i := range s.cfg.Hosts {
+		if s.cfg.Hosts[i].Name == hostName {
+			cc := config.Check{Type: config.CheckHTTP, Enabled: true, URL: url, Expect: expect, ID: id, DependsOn: dependsOn, MQTTNotify: mqttNotify, PushoverNotify: pushoverNotify, TelegramNotify: telegramNotify}
+			setCfgHTTPOptions(&cc, opts)
+			s.cfg.Hosts[i].Checks = append(s.cfg.Hosts[i].Checks, cc)
+			break
+		}
+	}
+	s.rebuildCheckIndex()
+	return s.saveConfigLocked()
+}
+
+// SetHostNotes updates a host's notes and runbook URL
+func (s *State) SetHostNotes(hostName, notes, runbookURL string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	hs, ok := s.hosts[hostName]
+	if !ok {
+		return fmt.Errorf("host not found")
+	}
+	hs.Notes = notes
+	hs.RunbookURL = runbookURL
+	for i := range s.cfg.Hosts {
+		if s.cfg.Hosts[i].Name == hostName {
+			s.cfg.Hosts[i].Notes = notes
+			s.cfg.Hosts[i].RunbookURL = runbookURL
+			break
+		}
+	}
+	return s.saveConfigLocked()
+}
+
+// SetHostTags replaces a host's tags
+func (s *State) SetHostTags(hostName string, tags []string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	hs, ok := s.hosts[hostName]
+	if !ok {
+		return fmt.Errorf("host not found")
+	}
+	hs.Tags = tags
+	for i := range s.cfg.Hosts {
+		if s.cfg.Hosts[i].Name == hostName {
+			s.cfg.Hosts[i].Tags = tags
+			break
+		}
+	}
+	return s.saveConfigLocked()
+}
+
+// SetCheckNotes updates the notes and runbook URL of the check at idx
+func (s *State) SetCheckNotes(hostName string, idx int, notes, runbookURL string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	hs, ok := s.hosts[hostName]
+	if !ok {
+		return fmt.Errorf("host not found")
+	}
+	if idx < 0 || idx >= len(hs.Checks) {
+		return fmt.Errorf("bad index")
+	}
+	hs.Checks[idx].Notes = notes
+	hs.Checks[idx].RunbookURL = runbookURL
+	for i := range s.cfg.Hosts {
+		if s.cfg.Hosts[i].Name == hostName {
+			if idx < len(s.cfg.Hosts[i].Checks) {
+				s.cfg.Hosts[i].Checks[idx].Notes = notes
+				s.cfg.Hosts[i].Checks[idx].RunbookURL = runbookURL
+			}
+			break
+		}
+	}
+	return s.saveConfigLocked()
+}
+
+// SetCheckName updates the display name of the check at idx
+func (s *State) SetCheckName(hostName string, idx int, name string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	hs, ok := s.hosts[hostName]
+	if !ok {
+		return fmt.Errorf("host not found")
+	}
+	if idx < 0 || idx >= len(hs.Checks) {
+		return fmt.Errorf("bad index")
+	}
+	hs.Checks[idx].Name = name
+	for i := range s.cfg.Hosts {
+		if s.cfg.Hosts[i].Name == hostName {
+			if idx < len(s.cfg.Hosts[i].Checks) {
+				s.cfg.Hosts[i].Checks[idx].Name = name
+			}
+			break
+		}
+	}
+	return s.saveConfigLocked()
+}
+
+// SetCheckSeverity updates the severity of the check at idx
+func (s *State) SetCheckSeverity(hostName string, idx int, severity config.Severity) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	hs, ok := s.hosts[hostName]
+	if !ok {
+		return fmt.Errorf("host not found")
+	}
+	if idx < 0 || idx >= len(hs.Checks) {
+		return fmt.Errorf("bad index")
+	}
+	hs.Checks[idx].Severity = severity.OrDefault()
+	for i := range s.cfg.Hosts {
+		if s.cfg.Hosts[i].Name == hostName {
+			if idx < len(s.cfg.Hosts[i].Checks) {
+				s.cfg.Hosts[i].Checks[idx].Severity = severity
+			}
+			break
+		}
+	}
+	return s.saveConfigLocked()
+}
+
+// SetCheckInvert sets whether the check at idx passes when its probe fails
+func (s *State) SetCheckInvert(hostName string, idx int, invert bool) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	hs, ok := s.hosts[hostName]
+	if !ok {
+		return fmt.Errorf("host not found")
+	}
+	if idx < 0 || idx >= len(hs.Checks) {
+		return fmt.Errorf("bad index")
+	}
+	hs.Checks[idx].Invert = invert
+	for i := range s.cfg.Hosts {
+		if s.cfg.Hosts[i].Name == hostName {
+			if idx < len(s.cfg.Hosts[i].Checks) {
+				s.cfg.Hosts[i].Checks[idx].Invert = invert
+			}
+			break
+		}
+	}
+	return s.saveConfigLocked()
+}
+
+// SetCheckDialOptions updates the address family and source address of the
+// http, tcp or ports check at idx
+func (s *State) SetCheckDialOptions(hostName string, idx int, opts checks.DialOptions) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	hs, ok := s.hosts[hostName]
+	if !ok {
+		return fmt.Errorf("host not found")
+	}
+	if idx < 0 || idx >= len(hs.Checks) {
+		return fmt.Errorf("bad index")
+	}
+	if t := hs.Checks[idx].Type; t != config.CheckHTTP && t != config.CheckTCP && t != config.CheckPorts {
+		return fmt.Errorf("not http, tcp or ports check")
+	}
+	hs.Checks[idx].DialOpts = opts
+	for i := range s.cfg.Hosts {
+		if s.cfg.Hosts[i].Name == hostName {
+			if idx < len(s.cfg.Hosts[i].Checks) {
+				s.cfg.Hosts[i].Checks[idx].IPVersion = opts.IPVersion
+				s.cfg.Hosts[i].Checks[idx].Source = opts.Source
+			}
+			break
+		}
+	}
+	return s.saveConfigLocked()
+}
+
+// SetCheckPingOptions updates how the ping check at idx is probed
+func (s *State) SetCheckPingOptions(hostName string, idx int, opts checks.PingOptions) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	hs, ok := s.hosts[hostName]
+	if !ok {
+		return fmt.Errorf("host not found")
+	}
+	if idx < 0 || idx >= len(hs.Checks) {
+		return fmt.Errorf("bad index")
+	}
+	if hs.Checks[idx].Type != config.CheckPing {
+		return fmt.Errorf("not a ping check")
+	}
+	// The count is only set in the config file
+	opts.Count = hs.Checks[idx].PingOpts.Count
+	if hs.Checks[idx].PingOpts != opts {
+		hs.Checks[idx].PingMethod = ""
+	}
+	hs.Checks[idx].PingOpts = opts
+	for i := range s.cfg.Hosts {
+		if s.cfg.Hosts[i].Name == hostName {
+			if idx < len(s.cfg.Hosts[i].Checks) {
+				s.cfg.Hosts[i].Checks[idx].PingMethod = opts.Method
+				s.cfg.Hosts[i].Checks[idx].PingPort = opts.TCPPort
+			}
+			break
+		}
+	}
+	return s.saveConfigLocked()
+}
+
+// DeleteHost deletes a host and its checks. orphans says what happens to
+// checks on other hosts that depend on them.
+func (s *State) DeleteHost(name string, orphans OrphanAction) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.hosts[name]; !ok {
+		return fmt.Errorf("host not found")
+	}
+	del, err := s.resolveOrphansLocked(s.deleteSetLocked(name, -1), orphans)
+	if err != nil {
+		return err
+	}
+	delete(del, name)
+	s.removeChecksLocked(del)
+	delete(s.hosts, name)
+	// remove from cfg
+	for i := range s.cfg.Hosts {
+		if s.cfg.Hosts[i].Name == name {
+			s.cfg.Hosts = append(s.cfg.Hosts[:i], s.cfg.Hosts[i+1:]...)
+			break
+		}
+	}
+	s.rebuildCheckIndex()
+	return s.saveConfigLocked()
+}
+
+func (s *State) AddPingCheck(hostName, id, dependsOn string, mqttNotify, pushoverNotify, telegramNotify bool) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	hs, ok := s.hosts[hostName]
+	if !ok {
+		return fmt.Errorf("host not found")
+	}
+	if s.checkIDInUseLocked(id, "") {
+		return fmt.Errorf("check id %q already in use", id)
+	}
+	hs.Checks = append(hs.Checks, CheckStatus{Type: config.CheckPing, Enabled: true, HCNotify: true, ID: id, DependsOn: dependsOn, MQTTNotify: mqttNotify, PushoverNotify: pushoverNotify, TelegramNotify: telegramNotify, Severity: config.SeverityWarning})
+	for i := range s.cfg.Hosts {
+		if s.cfg.Hosts[i].Name == hostName {
+			s.cfg.Hosts[i].Checks = append(s.cfg.Hosts[i].Checks, config.Check{Type: config.CheckPing, Enabled: true, ID: id, DependsOn: dependsOn, MQTTNotify: mqttNotify, PushoverNotify: pushoverNotify, TelegramNotify: telegramNotify})
+			break
+		}
+	}
+	s.rebuildCheckIndex()
+	return s.saveConfigLocked()
+}
+
+func (s *State) AddTCPCheck(hostName string, port int, id, dependsOn string, mqttNotify, pushoverNotify, telegramNotify bool) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	hs, ok := s.hosts[hostName]
+	if !ok {
+		return fmt.Errorf("host not found")
+	}
+	if s.checkIDInUseLocked(id, "") {
+		return fmt.Errorf("check id %q already in use", id)
+	}
+	hs.Checks = append(hs.Checks, CheckStatus{Type: config.CheckTCP, Enabled: true, Port: port, ID: id, DependsOn: dependsOn, MQTTNotify: mqttNotify, PushoverNotify: pushoverNotify, TelegramNotify: telegramNotify, Severity: config.SeverityWarning})
+	for i := range s.cfg.Hosts {
+		if s.cfg.Hosts[i].Name == hostName {
+			s.cfg.Hosts[i].Checks = append(s.cfg.Hosts[i].Checks, config.Check{Type: config.CheckTCP, Enabled: true, Port: port, ID: id, DependsOn: dependsOn, MQTTNotify: mqttNotify, PushoverNotify: pushoverNotify, TelegramNotify: telegramNotify})
+			break
+		}
+	}
+	s.rebuildCheckIndex()
+	return s.saveConfigLocked()
+}
+
+// AddSSHCheck appends a remote command check to the named host
+func (s *State) AddSSHCheck(hostName string, opts checks.SSHOptions, id, dependsOn string, mqttNotify, pushoverNotify, telegramNotify bool) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	hs, ok := s.hosts[hostName]
+	if !ok {
+		return fmt.Errorf("host not found")
+	}
+	if s.checkIDInUseLocked(id, "") {
+		return fmt.Errorf("check id %q already in use", id)
+	}
+	hs.Checks = append(hs.Checks, CheckStatus{Type: config.CheckSSH, Enabled: true, SSHOpts: opts, ID: id, DependsOn: dependsOn, MQTTNotify: mqttNotify, PushoverNotify: pushoverNotify, TelegramNotify: telegramNotify, Severity: config.SeverityWarning})
+	for i := range s.cfg.Hosts {
+		if s.cfg.Hosts[i].Name == hostName {
+			cc := config.Check{Type: config.CheckSSH, Enabled: true, ID: id, DependsOn: dependsOn, MQTTNotify: mqttNotify, PushoverNotify: pushoverNotify, TelegramNotify: telegramNotify}
+			setCfgSSHOptions(&cc, opts)
+			s.cfg.Hosts[i].Checks = append(s.cfg.Hosts[i].Checks, cc)
+			break
+		}
+	}
+	s.rebuildCheckIndex()
+	return s.saveConfigLocked()
+}
+
+// SetCheckSSHOptions updates the command and expectations of the ssh check at idx
+func (s *State) SetCheckSSHOptions(hostName string, idx int, opts checks.SSHOptions) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	hs, ok := s.hosts[hostName]
+	if !ok {
+		return fmt.Errorf("host not found")
+	}
+	if idx < 0 || idx >= len(hs.Checks) {
+		return fmt.Errorf("bad index")
+	}
+	if hs.Checks[idx].Type != config.CheckSSH {
+		return fmt.Errorf("not ssh check")
+	}
+	hs.Checks[idx].SSHOpts = opts
+	for i := range s.cfg.Hosts {
+		if s.cfg.Hosts[i].Name == hostName {
+			if idx < len(s.cfg.Hosts[i].Checks) {
+				setCfgSSHOptions(&s.cfg.Hosts[i].Checks[idx], opts)
+			}
+			break
+		}
+	}
+	return s.saveConfigLocked()
+}
+
+// AddWebSocketCheck appends a WebSocket handshake check to the named host
+func (s *State) AddWebSocketCheck(hostName string, url string, opts checks.WebSocketOptions, id, dependsOn string, mqttNotify, pushoverNotify, telegramNotify bool) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	hs, ok := s.hosts[hostName]
+	if !ok {
+		return fmt.Errorf("host not found")
+	}
+	if s.checkIDInUseLocked(id, "") {
+		return fmt.Errorf("check id %q already in use", id)
+	}
+	hs.Checks = append(hs.Checks, CheckStatus{Type: config.CheckWS, Enabled: true, URL: url, WSOpts: opts, ID: id, DependsOn: dependsOn, MQTTNotify: mqttNotify, PushoverNotify: pushoverNotify, TelegramNotify: telegramNotify, Severity: config.SeverityWarning})
+	for i := range s.cfg.Hosts {
+		if s.cfg.Hosts[i].Name == hostName {
+			s.cfg.Hosts[i].Checks = append(s.cfg.Hosts[i].Checks, config.Check{Type: config.CheckWS, Enabled: true, URL: url, WSSend: opts.Send, WSExpect: opts.Expect, ID: id, DependsOn: dependsOn, MQTTNotify: mqttNotify, PushoverNotify: pushoverNotify, TelegramNotify: telegramNotify})
+			break
+		}
+	}
+	s.rebuildCheckIndex()
+	return s.saveConfigLocked()
+}
+
+// SetCheckWebSocket updates the URL and message exchange of the websocket check at idx
+func (s *State) SetCheckWebSocket(hostName string, idx int, url string, opts checks.WebSocketOptions) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	hs, ok := s.hosts[hostName]
+	if !ok {
+		return fmt.Errorf("host not found")
+	}
+	if idx < 0 || idx >= len(hs.Checks) {
+		return fmt.Errorf("bad index")
+	}
+	if hs.Checks[idx].Type != config.CheckWS {
+		return fmt.Errorf("not websocket check")
+	}
+	hs.Checks[idx].URL = url
+	hs.Checks[idx].WSOpts = opts
+	for i := range s.cfg.Hosts {
+		if s.cfg.Hosts[i].Name == hostName {
+			if idx < len(s.cfg.Hosts[i].Checks) {
+				s.cfg.Hosts[i].Checks[idx].URL = url
+				s.cfg.Hosts[i].Checks[idx].WSSend = opts.Send
+				s.cfg.Hosts[i].Checks[idx].WSExpect = opts.Expect
+			}
+			break
+		}
+	}
+	return s.saveConfigLocked()
+}
+
+// AddPortsCheck appends a port scan check to the named host
+func (s *State) AddPortsCheck(hostName string, opts checks.PortScanOptions, id, dependsOn string, mqttNotify, pushoverNotify, telegramNotify bool) error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 	hs, ok := s.hosts[hostName]
 	if !ok {
 		return fmt.Errorf("host not found")
 	}
-	// append to runtime
-	hs.Checks = append(hs.Checks, CheckStatus{Type: config.CheckHTTP, Enabled: true, URL: url, Expect: expect, ID: id, DependsOn: dependsOn, MQTTNotify: mqttNotify, PushoverNotify: pushoverNotify, TelegramNotify: telegramNotify})
-	// append to cfg
+	if s.checkIDInUseLocked(id, "") {
+		return fmt.Errorf("check id %q already in use", id)
+	}
+	hs.Checks = append(hs.Checks, CheckStatus{Type: config.CheckPorts, Enabled: true, ScanOpts: opts, ID: id, DependsOn: dependsOn, MQTTNotify: mqttNotify, PushoverNotify: pushoverNotify, TelegramNotify: telegramNotify, Severity: config.SeverityWarning})
 	for i := range s.cfg.Hosts {
 		if s.cfg.Hosts[i].Name == hostName {
-			s.cfg.Hosts[i].Checks = append(s.cfg.Hosts[i].Checks, config.Check{Type: config.CheckHTTP, Enabled: true, URL: url, Expect: expect, ID: id, DependsOn: dependsOn, MQTTNotify: mqttNotify, PushoverNotify: pushoverNotify, TelegramNotify: telegramNotify})
+			s.cfg.Hosts[i].Checks = append(s.cfg.Hosts[i].Checks, config.Check{Type: config.CheckPorts, Enabled: true, Ports: checks.FormatPorts(opts.Open), ClosedPorts: checks.FormatPorts(opts.Closed), ID: id, DependsOn: dependsOn, MQTTNotify: mqttNotify, PushoverNotify: pushoverNotify, TelegramNotify: telegramNotify})
 			break
 		}
 	}
@@ -532,34 +1511,49 @@ func (s *State) AddHTTPCheck(hostName, url string, expect int, id, dependsOn str
 	return s.saveConfigLocked()
 }
 
-func (s *State) DeleteHost(name string) error {
+// SetCheckPorts updates the ports the ports check at idx expects open and closed
+func (s *State) SetCheckPorts(hostName string, idx int, opts checks.PortScanOptions) error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
-	if _, ok := s.hosts[name]; !ok {
+	hs, ok := s.hosts[hostName]
+	if !ok {
 		return fmt.Errorf("host not found")
 	}
-	delete(s.hosts, name)
-	// remove from cfg
+	if idx < 0 || idx >= len(hs.Checks) {
+		return fmt.Errorf("bad index")
+	}
+	if hs.Checks[idx].Type != config.CheckPorts {
+		return fmt.Errorf("not ports check")
+	}
+	hs.Checks[idx].ScanOpts = opts
 	for i := range s.cfg.Hosts {
-		if s.cfg.Hosts[i].Name == name {
-			s.cfg.Hosts = append(s.cfg.Hosts[:i], s.cfg.Hosts[i+1:]...)
+		if s.cfg.Hosts[i].Name == hostName {
+			if idx < len(s.cfg.Hosts[i].Checks) {
+				s.cfg.Hosts[i].Checks[idx].Ports = checks.FormatPorts(opts.Open)
+				s.cfg.Hosts[i].Checks[idx].ClosedPorts = checks.FormatPorts(opts.Closed)
+			}
 			break
 		}
 	}
 	return s.saveConfigLocked()
 }
 
-func (s *State) AddPingCheck(hostName, id, dependsOn string, mqttNotify, pushoverNotify, telegramNotify bool) error {
+// AddCompositeCheck appends a composite check combining the checks with the
+// given IDs to the named host
+func (s *State) AddCompositeCheck(hostName string, allOf, anyOf []string, id, dependsOn string, mqttNotify, pushoverNotify, telegramNotify bool) error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 	hs, ok := s.hosts[hostName]
 	if !ok {
 		return fmt.Errorf("host not found")
 	}
-	hs.Checks = append(hs.Checks, CheckStatus{Type: config.CheckPing, Enabled: true, ID: id, DependsOn: dependsOn, MQTTNotify: mqttNotify, PushoverNotify: pushoverNotify, TelegramNotify: telegramNotify})
+	if s.checkIDInUseLocked(id, "") {
+		return fmt.Errorf("check id %q already in use", id)
+	}
+	hs.Checks = append(hs.Checks, CheckStatus{Type: config.CheckComposite, Enabled: true, AllOf: allOf, AnyOf: anyOf, ID: id, DependsOn: dependsOn, MQTTNotify: mqttNotify, PushoverNotify: pushoverNotify, TelegramNotify: telegramNotify, Severity: config.SeverityWarning})
 	for i := range s.cfg.Hosts {
 		if s.cfg.Hosts[i].Name == hostName {
-			s.cfg.Hosts[i].Checks = append(s.cfg.Hosts[i].Checks, config.Check{Type: config.CheckPing, Enabled: true, ID: id, DependsOn: dependsOn, MQTTNotify: mqttNotify, PushoverNotify: pushoverNotify, TelegramNotify: telegramNotify})
+			s.cfg.Hosts[i].Checks = append(s.cfg.Hosts[i].Checks, config.Check{Type: config.CheckComposite, Enabled: true, AllOf: allOf, AnyOf: anyOf, ID: id, DependsOn: dependsOn, MQTTNotify: mqttNotify, PushoverNotify: pushoverNotify, TelegramNotify: telegramNotify})
 			break
 		}
 	}
@@ -567,17 +1561,26 @@ func (s *State) AddPingCheck(hostName, id, dependsOn string, mqttNotify, pushove
 	return s.saveConfigLocked()
 }
 
-func (s *State) AddTCPCheck(hostName string, port int, id, dependsOn string, mqttNotify, pushoverNotify, telegramNotify bool) error {
+// AddWebhookCheck appends a webhook check, whose results are posted to
+// /api/webhook/<id>, to the named host
+func (s *State) AddWebhookCheck(hostName, maxAge, token, id, dependsOn string, mqttNotify, pushoverNotify, telegramNotify bool) error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 	hs, ok := s.hosts[hostName]
 	if !ok {
 		return fmt.Errorf("host not found")
 	}
-	hs.Checks = append(hs.Checks, CheckStatus{Type: config.CheckTCP, Enabled: true, Port: port, ID: id, DependsOn: dependsOn, MQTTNotify: mqttNotify, PushoverNotify: pushoverNotify, TelegramNotify: telegramNotify})
+	if id == "" {
+		return fmt.Errorf("a webhook check needs an id")
+	}
+	if s.checkIDInUseLocked(id, "") {
+		return fmt.Errorf("check id %q already in use", id)
+	}
+	c := config.Check{Type: config.CheckWebhook, Enabled: true, MaxAge: maxAge, WebhookToken: token, ID: id, DependsOn: dependsOn, MQTTNotify: mqttNotify, PushoverNotify: pushoverNotify, TelegramNotify: telegramNotify}
+	hs.Checks = append(hs.Checks, CheckStatus{Type: config.CheckWebhook, Enabled: true, MaxAge: c.MaxAgeLimit(), ID: id, DependsOn: dependsOn, MQTTNotify: mqttNotify, PushoverNotify: pushoverNotify, TelegramNotify: telegramNotify, Severity: config.SeverityWarning})
 	for i := range s.cfg.Hosts {
 		if s.cfg.Hosts[i].Name == hostName {
-			s.cfg.Hosts[i].Checks = append(s.cfg.Hosts[i].Checks, config.Check{Type: config.CheckTCP, Enabled: true, Port: port, ID: id, DependsOn: dependsOn, MQTTNotify: mqttNotify, PushoverNotify: pushoverNotify, TelegramNotify: telegramNotify})
+			s.cfg.Hosts[i].Checks = append(s.cfg.Hosts[i].Checks, c)
 			break
 		}
 	}
@@ -585,7 +1588,8 @@ func (s *State) AddTCPCheck(hostName string, port int, id, dependsOn string, mqt
 	return s.saveConfigLocked()
 }
 
-func (s *State) RemoveCheck(hostName string, idx int) error {
+// SetCheckComposite updates the member check IDs of the composite check at idx
+func (s *State) SetCheckComposite(hostName string, idx int, allOf, anyOf []string) error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 	hs, ok := s.hosts[hostName]
@@ -595,20 +1599,44 @@ func (s *State) RemoveCheck(hostName string, idx int) error {
 	if idx < 0 || idx >= len(hs.Checks) {
 		return fmt.Errorf("bad index")
 	}
-	hs.Checks = append(hs.Checks[:idx], hs.Checks[idx+1:]...)
+	if hs.Checks[idx].Type != config.CheckComposite {
+		return fmt.Errorf("not composite check")
+	}
+	hs.Checks[idx].AllOf, hs.Checks[idx].AnyOf = allOf, anyOf
 	for i := range s.cfg.Hosts {
 		if s.cfg.Hosts[i].Name == hostName {
-			if idx < 0 || idx >= len(s.cfg.Hosts[i].Checks) {
-				break
+			if idx < len(s.cfg.Hosts[i].Checks) {
+				s.cfg.Hosts[i].Checks[idx].AllOf = allOf
+				s.cfg.Hosts[i].Checks[idx].AnyOf = anyOf
 			}
-			s.cfg.Hosts[i].Checks = append(s.cfg.Hosts[i].Checks[:idx], s.cfg.Hosts[i].Checks[idx+1:]...)
 			break
 		}
 	}
 	return s.saveConfigLocked()
 }
 
-func (s *State) UpdateHTTPCheck(hostName string, idx int, url string, expect int, id, dependsOn string, mqttNotify, pushoverNotify, telegramNotify bool) error {
+// RemoveCheck deletes the check at idx. orphans says what happens to checks
+// that depend on it.
+func (s *State) RemoveCheck(hostName string, idx int, orphans OrphanAction) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	hs, ok := s.hosts[hostName]
+	if !ok {
+		return fmt.Errorf("host not found")
+	}
+	if idx < 0 || idx >= len(hs.Checks) {
+		return fmt.Errorf("bad index")
+	}
+	del, err := s.resolveOrphansLocked(s.deleteSetLocked(hostName, idx), orphans)
+	if err != nil {
+		return err
+	}
+	s.removeChecksLocked(del)
+	s.rebuildCheckIndex()
+	return s.saveConfigLocked()
+}
+
+func (s *State) UpdateHTTPCheck(hostName string, idx int, url string, expect int, opts checks.HTTPOptions, id, dependsOn string, mqttNotify, pushoverNotify, telegramNotify bool) error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 	hs, ok := s.hosts[hostName]
@@ -618,11 +1646,22 @@ func (s *State) UpdateHTTPCheck(hostName string, idx int, url string, expect int
 	if idx < 0 || idx >= len(hs.Checks) {
 		return fmt.Errorf("bad index")
 	}
+	if s.checkIDInUseLocked(id, hostName, idx) {
+		return fmt.Errorf("check id %q already in use", id)
+	}
 	if hs.Checks[idx].Type != config.CheckHTTP {
 		return fmt.Errorf("not http check")
 	}
+	if hs.Checks[idx].URL != url {
+		hs.Checks[idx].LastFailure = nil // Belonged to the old URL
+	}
+	if hs.Checks[idx].URL != url || !opts.WatchContent {
+		s.resetContentLocked(hostName, idx, &hs.Checks[idx])
+	}
 	hs.Checks[idx].URL = url
 	hs.Checks[idx].Expect = expect
+	hs.Checks[idx].HTTPOpts = opts
+	s.renameReferencesLocked(hs.Checks[idx].ID, id)
 	hs.Checks[idx].ID = id
 	hs.Checks[idx].DependsOn = dependsOn
 	hs.Checks[idx].MQTTNotify = mqttNotify
@@ -635,6 +1674,7 @@ func (s *State) UpdateHTTPCheck(hostName string, idx int, url string, expect int
 			}
 			s.cfg.Hosts[i].Checks[idx].URL = url
 			s.cfg.Hosts[i].Checks[idx].Expect = expect
+			setCfgHTTPOptions(&s.cfg.Hosts[i].Checks[idx], opts)
 			s.cfg.Hosts[i].Checks[idx].ID = id
 			s.cfg.Hosts[i].Checks[idx].DependsOn = dependsOn
 			s.cfg.Hosts[i].Checks[idx].MQTTNotify = mqttNotify
@@ -657,10 +1697,14 @@ func (s *State) UpdateTCPCheck(hostName string, idx int, port int, id, dependsOn
 	if idx < 0 || idx >= len(hs.Checks) {
 		return fmt.Errorf("bad index")
 	}
+	if s.checkIDInUseLocked(id, hostName, idx) {
+		return fmt.Errorf("check id %q already in use", id)
+	}
 	if hs.Checks[idx].Type != config.CheckTCP {
 		return fmt.Errorf("not tcp check")
 	}
 	hs.Checks[idx].Port = port
+	s.renameReferencesLocked(hs.Checks[idx].ID, id)
 	hs.Checks[idx].ID = id
 	hs.Checks[idx].DependsOn = dependsOn
 	hs.Checks[idx].MQTTNotify = mqttNotify
@@ -695,6 +1739,10 @@ func (s *State) UpdateCheckDependencies(hostName string, idx int, id, dependsOn
 	if idx < 0 || idx >= len(hs.Checks) {
 		return fmt.Errorf("bad index")
 	}
+	if s.checkIDInUseLocked(id, hostName, idx) {
+		return fmt.Errorf("check id %q already in use", id)
+	}
+	s.renameReferencesLocked(hs.Checks[idx].ID, id)
 	hs.Checks[idx].ID = id
 	hs.Checks[idx].DependsOn = dependsOn
 	hs.Checks[idx].MQTTNotify = mqttNotify
@@ -727,6 +1775,28 @@ func (s *State) Toggle(hostName string, idx int, enabled bool) {
 	}
 }
 
+// SetHostEnabled enables or disables every check on one host and persists it
+func (s *State) SetHostEnabled(hostName string, enabled bool) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	hs, ok := s.hosts[hostName]
+	if !ok {
+		return fmt.Errorf("host not found")
+	}
+	for i := range hs.Checks {
+		hs.Checks[i].Enabled = enabled
+	}
+	for i := range s.cfg.Hosts {
+		if s.cfg.Hosts[i].Name == hostName {
+			for j := range s.cfg.Hosts[i].Checks {
+				s.cfg.Hosts[i].Checks[j].Enabled = enabled
+			}
+			break
+		}
+	}
+	return s.saveConfigLocked()
+}
+
 func (s *State) SetAllEnabled(enabled bool) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
@@ -745,36 +1815,72 @@ func (s *State) SetConfigPath(path string) {
 	} else {
 		s.configPath = path
 	}
+	s.loadHistoryLocked()
+	s.loadTrendsLocked()
 }
 
-func (s *State) SetHCURL(hostName, url string) {
+// SetHCURL sets a host's Healthchecks.io ping URL, normalised by
+// validate.HealthchecksURL, or clears it if hcURL is empty
+func (s *State) SetHCURL(hostName, hcURL string) error {
+	hcURL, err := validate.HealthchecksURL(hcURL)
+	if err != nil {
+		return err
+	}
 	s.mu.Lock()
 	defer s.mu.Unlock()
-	if hs, ok := s.hosts[hostName]; ok {
-		hs.HCURL = url
-		// also persist into cfg
-		found := false
-		for i := range s.cfg.Hosts {
-			if s.cfg.Hosts[i].Name == hostName {
-				s.cfg.Hosts[i].HealthchecksPingURL = url
-				found = true
-				break
-			}
-		}
-		if !found {
-			log.Printf("warning: host %q not found in cfg when saving HCURL", hostName)
+	hs, ok := s.hosts[hostName]
+	if !ok {
+		return fmt.Errorf("host not found")
+	}
+	hs.HCURL = hcURL
+	for i := range s.cfg.Hosts {
+		if s.cfg.Hosts[i].Name == hostName {
+			s.cfg.Hosts[i].HealthchecksPingURL = hcURL
+			break
 		}
-		if err := s.saveConfigLocked(); err != nil {
-			log.Printf("persist config failed: %v", err)
+	}
+	return s.saveConfigLocked()
+}
+
+// SetChecker replaces the probe implementation, e.g. with a checks.Fake
+func (s *State) SetChecker(c checks.Checker) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.limiter = checks.NewLimited(c)
+	s.checker = s.limiter
+}
+
+// SetPaused pauses or resumes the scheduler. Unlike disabling checks this
+// keeps every check's enabled flag and last result intact.
+func (s *State) SetPaused(paused bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.paused != paused {
+		if paused {
+			log.Printf("monitoring paused")
 		} else {
-			log.Printf("persist config ok: %s", s.configPath)
+			log.Printf("monitoring resumed")
+			// Paused on purpose, so not a gap in the data
+			s.ticks.lastEnd = time.Time{}
 		}
-	} else {
-		log.Printf("warning: host %q not found in state when setting HCURL", hostName)
 	}
+	s.paused = paused
+}
+
+// IsPaused returns whether the scheduler is currently paused
+func (s *State) IsPaused() bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.paused
 }
 
 func (s *State) StartScheduler(interval time.Duration, stop <-chan struct{}) {
+	s.mu.Lock()
+	s.ticks.Interval = interval
+	s.mu.Unlock()
+	s.runs.mu.Lock()
+	s.runs.interval = interval
+	s.runs.mu.Unlock()
 	go func() {
 		// run immediately, then on each tick
 		s.runOnce()
@@ -784,7 +1890,9 @@ func (s *State) StartScheduler(interval time.Duration, stop <-chan struct{}) {
 			select {
 			case <-t.C:
 				fmt.Println("scheduler tick")
-				s.runOnce()
+				// Runs in the background so the ticker keeps time; one
+				// still going when the next is due makes it skip
+				go s.runOnce()
 			case <-stop:
 				return
 			}
@@ -792,51 +1900,176 @@ func (s *State) StartScheduler(interval time.Duration, stop <-chan struct{}) {
 	}()
 }
 
+// runOnce runs every check for the scheduler, unless monitoring is paused
+// or the previous run is still going
 func (s *State) runOnce() {
-	fmt.Println("running checks")
+	s.runs.tryRun(func() {
+		// Asked only once no run holds the lock
+		if s.IsPaused() {
+			return
+		}
+		fmt.Println("running checks")
+		s.runAt(time.Now())
+	})
+}
+
+// RunNow runs the enabled checks on hostName, or on every host if it is "",
+// in the background without waiting for the next tick. It runs even while
+// monitoring is paused, since it was asked for explicitly.
+func (s *State) RunNow(hostName string) error {
+	if hostName != "" {
+		s.mu.RLock()
+		_, ok := s.hosts[hostName]
+		s.mu.RUnlock()
+		if !ok {
+			return fmt.Errorf("host not found")
+		}
+	}
+	go s.runs.queue(hostName, func(host string) { s.runHostsAt(time.Now(), runScope{host: host, manual: true}) })
+	return nil
+}
+
+// runAt runs every enabled check once, recording results as of now
+func (s *State) runAt(now time.Time) {
+	s.runHostsAt(now, runScope{})
+}
+
+// runScope is what one run covers
+type runScope struct {
+	host   string // Only this host, or "" for every host
+	check  string // Only the check with this ID on it, for a posted webhook result
+	manual bool   // Asked for with "Run now", so checks on their own interval run too
+}
+
+// runHostsAt runs the enabled checks in scope
+func (s *State) runHostsAt(now time.Time, scope runScope) {
+	slow := s.runSlowProbes(now, scope)
 	s.mu.Lock()
 	defer s.mu.Unlock()
+	s.runHostsLocked(now, scope, slow)
+}
 
-	now := time.Now()
-	for _, hs := range s.hosts {
-		for i := range hs.Checks {
+// runHostsLocked runs the enabled checks in scope. A run of a single check
+// isn't a scheduler run, so it isn't counted on the monitor health page or
+// used to spot gaps. Slow checks aren't probed here but take their results
+// from slow, run by runSlowProbes before the lock was taken.
+func (s *State) runHostsLocked(now time.Time, scope runScope, slow map[slowKey]slowProbe) {
+	now = s.runTimeLocked(now)
+	tick := s.startTickLocked(scope.host)
+	if scope.check == "" {
+		s.beginTickStatsLocked(now, scope.host, scope.manual)
+		s.noteGapLocked(now)
+	}
+	ran, failed := 0, 0
+	defer func() {
+		s.endTickLocked(tick, ran)
+		s.endTickStatsLocked(ran, failed)
+	}()
+	s.limiter.SetLimits(s.probeLimitsLocked())
+
+	if !s.monitorOnlineLocked(now) {
+		// Every probe would fail for our own reasons; record nothing rather
+		// than a burst of false downs that also drag uptime down
+		tick.SetAttrs(tracing.Bool("monitor.offline", true))
+		if s.ticks.current != nil {
+			s.ticks.current.Offline = true
+		}
+		return
+	}
+	s.flushQuietDigestsLocked(now)
+	reminder := s.cfg.Settings.Alerts.Reminder()
+	grace := s.inGraceLocked(now)
+	var downs []newDown // Checks that went down this run, reported once every check has run
+	for _, hs := range s.runOrderLocked() {
+		if scope.host != "" && hs.Name != scope.host {
+			continue
+		}
+		// The host's Healthchecks.io URL fails if any check reporting to it
+		// failed, and is pinged if they all passed
+		hostSignal, hostSignalled := "", false
+		for _, i := range checkRunOrder(hs.Checks) {
 			c := &hs.Checks[i]
-			if !c.Enabled {
+			if !c.Enabled || (scope.check != "" && c.ID != scope.check) {
 				continue
 			}
 
+			c.MonitorOffline = false
+			if !c.schedule.Active(now) {
+				// Not monitored right now, so nothing is run or recorded
+				c.OffSchedule = true
+				continue
+			}
+			resumed := c.OffSchedule || c.Expected
+			c.OffSchedule = false
+			c.Expected = now.Before(c.DownUntil)
 			wasOK := c.OK
 			wasChecked := !c.CheckedAt.IsZero()
+			if c.Warmup || resumed {
+				// Nothing was sent for a failure held back during the grace
+				// period, and an outage from before the check went off
+				// schedule or into expected downtime isn't carried over, so
+				// treat it as having been up: failing now raises the down
+				// alert, recovering says nothing
+				wasOK = true
+			}
+			if c.Type == config.CheckWebhook && !s.webhookDueLocked(c, now) {
+				// Nothing to show until the first result arrives
+				continue
+			}
+			probe, probed := slow[slowKey{hs.Name, i}]
+			if isSlow(c.Type) && (!probed || probe.target != slowTarget(hs, c)) {
+				// Not due on its own interval (a speedtest saturates the
+				// link, registries rate-limit lookups and free use of
+				// blocklists is capped), or added or edited since the slow
+				// probes ran
+				continue
+			}
 			wasParentFailed := c.ParentFailed
+			failedProbes := c.FailStreak
 
 			// Check if parent dependency is failing
 			parentOK := s.IsParentOK(c)
-			if c.DependsOn != "" {
-				c.ParentID = c.DependsOn
-			}
+			c.ParentID = s.parentLabelLocked(c)
 
+			span := s.startCheckSpan(tick, hs, c)
+			ran++
+			var probeAddr string // For the probe log
+			var probeErr error
+			var probeID string
 			switch c.Type {
 			case config.CheckPing:
-				res := checks.PingOnce(hs.Address, 2*time.Second)
+				res := s.checker.Ping(hs.Address, 2*time.Second, c.PingOpts)
+				probeAddr, probeErr = res.Addr, res.Err
 				c.CheckedAt = now
+				c.PingMethod = res.Method
 				actualOK := res.OK
+				jitterMsg := ""
+				if actualOK {
+					jitterMsg = c.checkJitter(res.Jitter)
+					actualOK = jitterMsg == ""
+				}
 
-				if res.OK {
+				if actualOK {
 					c.OK = true
 					c.ParentFailed = false
 					c.Message = "pong"
-					c.LatencyMS = res.Latency.Milliseconds()
-					if hs.HCURL != "" {
-						_ = notifyHealthchecksOK(hs.HCURL)
+					if res.PacketsTx > 1 {
+						c.Message = fmt.Sprintf("pong, jitter %s", checks.FormatLatency(res.Jitter))
 					}
+					c.Latency = res.Latency
+				} else if jitterMsg != "" {
+					// Replies came back, so the parent isn't to blame
+					c.OK = false
+					c.ParentFailed = false
+					c.Message = jitterMsg
+					c.Latency = res.Latency
 				} else {
 					// Check failed - is it because parent is down?
 					if !parentOK {
 						c.OK = false
 						c.ParentFailed = true
 						c.Message = "parent check failed"
-						c.LatencyMS = 0
-						// Don't notify healthchecks when parent is down
+						c.Latency = 0
 					} else {
 						c.OK = false
 						c.ParentFailed = false
@@ -845,21 +2078,23 @@ func (s *State) runOnce() {
 						} else {
 							c.Message = "no reply"
 						}
-						c.LatencyMS = 0
-						if hs.HCURL != "" {
-							_ = notifyHealthchecksFail(hs.HCURL)
-						}
+						c.Latency = 0
 					}
 				}
 				// Record actual result for analytics
-				c.recordDataPoint(now, actualOK, c.LatencyMS)
+				c.recordDataPoint(now, c.passed(actualOK), c.Latency)
+				c.notePing(now, res)
 
 			case config.CheckHTTP:
 				url := c.URL
 				if url == "" {
 					url = "http://" + hs.Address
 				}
-				res := checks.HTTPGet(url, 5*time.Second)
+				opts := c.HTTPOpts
+				opts.DialOptions = c.DialOpts
+				opts.Identity = s.probeIdentityLocked(c)
+				res := s.checker.HTTP(url, 5*time.Second, opts)
+				probeAddr, probeErr, probeID = res.Addr, res.Err, opts.Identity.ID
 				c.CheckedAt = now
 
 				actualOK := false
@@ -870,6 +2105,14 @@ func (s *State) runOnce() {
 					}
 					actualOK = (res.Code == expect)
 				}
+				contentMsg := ""
+				if actualOK {
+					contentMsg = s.checkContentLocked(hs.Name, i, c, res)
+					if contentMsg == "" {
+						contentMsg = c.checkSize(res.Size)
+					}
+					actualOK = contentMsg == ""
+				}
 
 				if actualOK {
 					c.OK = true
@@ -878,19 +2121,21 @@ func (s *State) runOnce() {
 					if c.Expect == 0 {
 						c.Message = fmt.Sprintf("status %d (expect %d)", res.Code, 200)
 					}
-					c.LatencyMS = res.Latency.Milliseconds()
+					c.Latency = res.Latency
 				} else {
 					// Check failed - is it because parent is down?
 					if !parentOK {
 						c.OK = false
 						c.ParentFailed = true
 						c.Message = "parent check failed"
-						c.LatencyMS = 0
+						c.Latency = 0
 					} else {
 						c.OK = false
 						c.ParentFailed = false
 						if res.Err != nil {
 							c.Message = res.Err.Error()
+						} else if contentMsg != "" {
+							c.Message = contentMsg
 						} else {
 							expect := c.Expect
 							if expect == 0 {
@@ -898,18 +2143,24 @@ func (s *State) runOnce() {
 							}
 							c.Message = fmt.Sprintf("status %d (expect %d)", res.Code, expect)
 						}
-						c.LatencyMS = res.Latency.Milliseconds()
+						c.Latency = res.Latency
+					}
+					if res.Err == nil {
+						c.LastFailure = newResponseDetail(now, res)
 					}
 				}
 				// Record actual result for analytics
-				c.recordDataPoint(now, actualOK, c.LatencyMS)
+				c.recordDataPoint(now, c.passed(actualOK), c.Latency)
+				c.noteResponse(now, res)
+				c.noteTLS(now, res.TLS)
 
 			case config.CheckTCP:
 				port := c.Port
 				if port == 0 {
 					port = 80 // default port
 				}
-				res := checks.TCPCheck(hs.Address, port, 5*time.Second)
+				res := s.checker.TCP(hs.Address, port, 5*time.Second, c.DialOpts)
+				probeAddr, probeErr = res.Addr, res.Err
 				c.CheckedAt = now
 				actualOK := res.OK
 
@@ -917,14 +2168,14 @@ func (s *State) runOnce() {
 					c.OK = true
 					c.ParentFailed = false
 					c.Message = fmt.Sprintf("port %d open", port)
-					c.LatencyMS = res.Latency.Milliseconds()
+					c.Latency = res.Latency
 				} else {
 					// Check failed - is it because parent is down?
 					if !parentOK {
 						c.OK = false
 						c.ParentFailed = true
 						c.Message = "parent check failed"
-						c.LatencyMS = 0
+						c.Latency = 0
 					} else {
 						c.OK = false
 						c.ParentFailed = false
@@ -933,131 +2184,345 @@ func (s *State) runOnce() {
 						} else {
 							c.Message = fmt.Sprintf("port %d closed", port)
 						}
-						c.LatencyMS = 0
+						c.Latency = 0
 					}
 				}
 				// Record actual result for analytics
-				c.recordDataPoint(now, actualOK, c.LatencyMS)
+				c.recordDataPoint(now, c.passed(actualOK), c.Latency)
+
+			case config.CheckSSH:
+				res := s.checker.SSH(hs.Address, 15*time.Second, c.SSHOpts)
+				probeErr = res.Err
+				msg := fmt.Sprintf("exit %d", res.ExitCode)
+				if res.Err != nil {
+					msg = res.Err.Error()
+				}
+				c.setResult(now, parentOK, res.OK, res.Latency, msg)
+
+			case config.CheckWS:
+				opts := c.WSOpts
+				opts.Identity = s.probeIdentityLocked(c)
+				res := s.checker.WebSocket(c.URL, 5*time.Second, opts)
+				probeAddr, probeErr, probeID = res.Addr, res.Err, opts.Identity.ID
+				msg := "handshake ok"
+				if res.Err != nil {
+					msg = res.Err.Error()
+				} else if c.WSOpts.Send != "" || c.WSOpts.Expect != "" {
+					msg = "reply received"
+				}
+				c.setResult(now, parentOK, res.OK, res.Latency, msg)
+				c.noteTLS(now, res.TLS)
+
+			case config.CheckPorts:
+				res := checks.ScanPorts(s.checker, hs.Address, 3*time.Second, c.ScanOpts, c.DialOpts)
+				c.setResult(now, parentOK, res.OK, res.Latency, res.Message(c.ScanOpts))
+
+			case config.CheckComposite:
+				ok, latency, msg := s.compositeResultLocked(c)
+				c.setResult(now, parentOK, ok, latency, msg)
+
+			case config.CheckWebhook:
+				ok, latency, msg := s.webhookResultLocked(c, now)
+				c.setResult(now, parentOK, ok, latency, msg)
+
+			case config.CheckFile:
+				res := s.checker.File(hs.Address, 15*time.Second, c.FileOpts)
+				probeErr = res.Err
+				c.setResult(now, parentOK, res.OK, res.Latency, fileMessage(res, c.FileOpts, now))
+
+			case config.CheckS3:
+				opts := c.S3Opts
+				opts.Identity = s.probeIdentityLocked(c)
+				res := s.checker.S3(c.URL, 10*time.Second, opts)
+				probeAddr, probeErr, probeID = res.Addr, res.Err, opts.Identity.ID
+				c.setResult(now, parentOK, res.OK, res.Latency, s3Message(res, opts, now))
+				c.noteTLS(now, res.TLS)
+
+			case config.CheckIPP:
+				opts := c.IPPOpts
+				opts.Identity = s.probeIdentityLocked(c)
+				res := s.checker.IPP(c.URL, 10*time.Second, opts)
+				probeAddr, probeErr, probeID = res.Addr, res.Err, opts.Identity.ID
+				c.setResult(now, parentOK, res.OK, res.Latency, ippMessage(res))
+				c.noteTLS(now, res.TLS)
+
+			case config.CheckSMB:
+				res := s.checker.SMB(hs.Address, 10*time.Second, c.SMBOpts)
+				probeAddr, probeErr = res.Addr, res.Err
+				c.setResult(now, parentOK, res.OK, res.Latency, smbMessage(res, c.SMBOpts))
+
+			case config.CheckKafka:
+				res := s.checker.Kafka(hs.Address, 10*time.Second, c.KafkaOpts)
+				probeAddr, probeErr = res.Addr, res.Err
+				c.setResult(now, parentOK, res.OK, res.Latency, kafkaMessage(res, c.KafkaOpts))
+
+			case config.CheckAMQP:
+				res := s.checker.AMQP(hs.Address, 10*time.Second, c.AMQPOpts)
+				probeAddr, probeErr = res.Addr, res.Err
+				c.setResult(now, parentOK, res.OK, res.Latency, amqpMessage(res, c.AMQPOpts))
+				c.noteTLS(now, res.TLS)
+
+			case config.CheckKubernetes:
+				opts := c.KubernetesOpts
+				opts.Identity = s.probeIdentityLocked(c)
+				res := s.checker.Kubernetes(c.URL, 10*time.Second, opts)
+				probeAddr, probeErr, probeID = res.Addr, res.Err, opts.Identity.ID
+				c.setResult(now, parentOK, res.OK, res.Latency, kubernetesMessage(res, opts))
+				c.noteTLS(now, res.TLS)
+
+			case config.CheckProxmox:
+				opts := c.ProxmoxOpts
+				opts.Identity = s.probeIdentityLocked(c)
+				res := s.checker.Proxmox(hs.Address, 10*time.Second, opts)
+				probeAddr, probeErr, probeID = res.Addr, res.Err, opts.Identity.ID
+				c.setResult(now, parentOK, res.OK, res.Latency, proxmoxMessage(res, opts))
+				c.noteTLS(now, res.TLS)
+
+			case config.CheckESXi:
+				opts := c.ESXiOpts
+				opts.Identity = s.probeIdentityLocked(c)
+				res := s.checker.ESXi(hs.Address, 15*time.Second, opts)
+				probeAddr, probeErr, probeID = res.Addr, res.Err, opts.Identity.ID
+				c.setResult(now, parentOK, res.OK, res.Latency, esxiMessage(res, opts))
+				c.noteTLS(now, res.TLS)
+
+			case config.CheckUPS:
+				res := s.checker.UPS(hs.Address, 10*time.Second, c.UPSOpts)
+				probeAddr, probeErr = res.Addr, res.Err
+				c.setResult(now, parentOK, res.OK, res.Latency, upsMessage(res))
+
+			case config.CheckSpeedtest:
+				res := probe.speed
+				probeAddr, probeErr, probeID = res.Addr, res.Err, probe.id
+				c.setResult(now, parentOK, res.OK, res.Latency, speedtestMessage(res, c.SpeedtestOpts))
+				c.noteSpeed(now, res)
+
+			case config.CheckPublicIP:
+				opts := c.PublicIPOpts
+				opts.Identity = s.probeIdentityLocked(c)
+				res := s.checker.PublicIP(c.URL, 10*time.Second, opts)
+				probeAddr, probeErr, probeID = res.Addr, res.Err, opts.Identity.ID
+				s.notePublicIPLocked(hs, i, now, res)
+				c.setResult(now, parentOK, res.OK, res.Latency, publicIPMessage(res, c))
+
+			case config.CheckDomain:
+				res := probe.domain
+				probeAddr, probeErr, probeID = res.Addr, res.Err, probe.id
+				c.DomainExpiry = res.Expiry
+				c.setResult(now, parentOK, res.OK, res.Latency, domainMessage(res, now))
+
+			case config.CheckDNSBL:
+				res := probe.dnsbl
+				probeAddr, probeErr = res.Addr, res.Err
+				c.DNSBLChecked, c.DNSBLListings = res.Checked, res.Listings
+				c.setResult(now, parentOK, res.OK, res.Latency, dnsblMessage(res))
+			}
+			if c.Invert {
+				c.invertResult()
+			}
+			c.logProbe(now, probeAddr, probeID, probeErr)
+			s.noteTrendLocked(hs, i, now)
+			s.noteResolvedIPLocked(hs, i, now, probeAddr)
+			s.noteLatencyLocked(hs, i, now)
+			endCheckSpan(span, c)
+			if !c.OK && !c.ParentFailed {
+				failed++
+			}
+			c.Warmup = grace && !c.OK
+			signal, send := healthchecksSignal(c, grace)
+			if send && c.HCURL != "" {
+				if err := notifyHealthchecks(c.HCURL, signal); err != nil {
+					log.Printf("healthchecks.io signal for %s failed: %v", checkLabel(hs, c), err)
+				}
+			}
+			if send && c.HCNotify && hostSignal != "fail" {
+				hostSignal = signal
+				hostSignalled = true
 			}
 
 			// Track state changes for events (only fire events when not parent-failed)
-			if wasChecked {
+			if wasChecked && !c.Warmup && !c.Expected {
 				if wasOK && !c.OK && !c.ParentFailed {
 					// Went down (genuine failure, not parent-related)
 					c.LastDownAt = now
-					logEvent(Event{
-						Timestamp: now,
-						HostName:  hs.Name,
-						CheckIdx:  i,
-						CheckType: c.Type,
-						EventType: "down",
-						Message:   c.Message,
-					})
-					// MQTT notification
-					if c.MQTTNotify && s.mqttClient != nil {
-						s.publishMQTTStateChange(hs.Name, hs.Address, c, "down")
-					}
-					// Pushover notification
-					if c.PushoverNotify && s.pushoverClient != nil {
-						s.sendPushoverAlert(hs.Name, hs.Address, c, "down")
-					}
-					// Telegram notification
-					if c.TelegramNotify && s.telegramClient != nil {
-						s.sendTelegramAlert(hs.Name, hs.Address, c, "down")
-					}
+					downs = append(downs, newDown{hs, i})
 				} else if !wasOK && c.OK {
 					// Recovered
-					duration := time.Duration(0)
+					duration, noData := time.Duration(0), time.Duration(0)
 					if !c.LastDownAt.IsZero() {
 						duration = now.Sub(c.LastDownAt)
+						noData = noDataBetween(c.LastDownAt, now)
 					}
 					c.LastUpAt = now
 					// Only log recovery event if we weren't previously parent-failed
 					if !wasParentFailed {
+						sum := &outageSummary{OutageSummary: notify.OutageSummary{
+							Start:        c.LastDownAt,
+							Downtime:     duration - noData,
+							FailedProbes: failedProbes,
+							UptimePct:    c.uptimePct(),
+						}}
+						msg := fmt.Sprintf("Back up after %v", (duration - noData).Round(time.Second))
+						if noData > 0 {
+							msg += fmt.Sprintf(", not counting %v with no data", noData.Round(time.Second))
+						}
 						logEvent(Event{
 							Timestamp: now,
 							HostName:  hs.Name,
 							CheckIdx:  i,
+							CheckID:   c.ID,
+							CheckName: c.Name,
 							CheckType: c.Type,
 							EventType: "recovered",
-							Message:   fmt.Sprintf("Back up after %v", duration.Round(time.Second)),
+							Message:   msg,
 							Duration:  duration,
 						})
-						// MQTT notification
-						if c.MQTTNotify && s.mqttClient != nil {
-							s.publishMQTTStateChange(hs.Name, hs.Address, c, "up")
-						}
-						// Pushover notification
-						if c.PushoverNotify && s.pushoverClient != nil {
-							s.sendPushoverAlert(hs.Name, hs.Address, c, "up")
-						}
-						// Telegram notification
-						if c.TelegramNotify && s.telegramClient != nil {
-							s.sendTelegramAlert(hs.Name, hs.Address, c, "up")
+						s.noteFlipLocked(hs, i, now, true)
+						if c.alertSuppressed {
+							// Its down alert was never sent, so neither is this
+							c.alertSuppressed = false
+						} else if !c.Flapping {
+							s.dispatchAlert(hs, c, "up", sum, nil)
 						}
 					}
 				} else if wasParentFailed && !c.ParentFailed && !c.OK {
 					// Parent recovered but we're still down - now fire the actual down event
 					c.LastDownAt = now
-					logEvent(Event{
-						Timestamp: now,
-						HostName:  hs.Name,
-						CheckIdx:  i,
-						CheckType: c.Type,
-						EventType: "down",
-						Message:   c.Message,
-					})
-					// MQTT notification
-					if c.MQTTNotify && s.mqttClient != nil {
-						s.publishMQTTStateChange(hs.Name, hs.Address, c, "down")
-					}
-					// Pushover notification
-					if c.PushoverNotify && s.pushoverClient != nil {
-						s.sendPushoverAlert(hs.Name, hs.Address, c, "down")
+					downs = append(downs, newDown{hs, i})
+				} else if reminder > 0 && !wasOK && !c.OK && !c.ParentFailed && !c.alertSuppressed && !c.Flapping && !c.LastDownAt.IsZero() {
+					// Still down - remind once per interval so a missed alert isn't the last word
+					since := c.LastDownAt
+					if c.LastRemindedAt.After(since) {
+						since = c.LastRemindedAt
 					}
-					// Telegram notification
-					if c.TelegramNotify && s.telegramClient != nil {
-						s.sendTelegramAlert(hs.Name, hs.Address, c, "down")
+					if now.Sub(since)-noDataBetween(since, now) >= reminder {
+						c.LastRemindedAt = now
+						s.dispatchAlert(hs, c, "down", &outageSummary{
+							OutageSummary: notify.OutageSummary{
+								Start:        c.LastDownAt,
+								Downtime:     now.Sub(c.LastDownAt) - noDataBetween(c.LastDownAt, now),
+								FailedProbes: c.FailStreak,
+								UptimePct:    c.uptimePct(),
+							},
+							Reminder: true,
+						}, nil)
 					}
 				}
+				s.settleFlapLocked(hs, i, now)
+				s.callIfProlongedLocked(hs, c, now)
 			}
 		}
+		// One check alone can't say the host passed; the next scheduled run signals it
+		if hostSignalled && hs.HCURL != "" && scope.check == "" {
+			if err := notifyHealthchecks(hs.HCURL, hostSignal); err != nil {
+				log.Printf("healthchecks.io signal for %q failed: %v", hs.Name, err)
+			}
+		}
+	}
+
+	s.exportMetricsLocked(now)
+	s.exportHomeAssistantLocked(now)
+	s.reportDownsLocked(now, downs)
+}
+
+// setResult records a probe outcome on c. A failure while the parent is
+// down shows as blocked rather than down, as for the built-in check types.
+func (c *CheckStatus) setResult(now time.Time, parentOK, ok bool, latency time.Duration, msg string) {
+	c.CheckedAt = now
+	c.OK = ok
+	c.ParentFailed = !ok && !parentOK
+	c.Message = msg
+	c.Latency = 0
+	if ok {
+		c.Latency = latency
+	} else if c.ParentFailed {
+		c.Message = "parent check failed"
+	}
+	c.recordDataPoint(now, c.passed(ok), c.Latency)
+}
+
+// passed reports whether a probe that succeeded or not passes the check,
+// which is the other way round for inverted checks
+func (c *CheckStatus) passed(probeOK bool) bool {
+	return probeOK != c.Invert
+}
+
+// invertResult turns the probe result just set into an inverted check's:
+// down if the target answered, up if it didn't. A probe that failed while
+// the parent is down stays blocked, as nothing can be said either way.
+func (c *CheckStatus) invertResult() {
+	switch {
+	case c.OK:
+		c.OK = false
+		c.Latency = 0
+		c.Message = "reachable, expected not to be: " + c.Message
+	case !c.ParentFailed:
+		c.OK = true
+		c.Message = "unreachable as expected: " + c.Message
 	}
 }
 
 // recordDataPoint adds a data point and updates uptime stats
-func (c *CheckStatus) recordDataPoint(ts time.Time, ok bool, latencyMS int64) {
+func (c *CheckStatus) recordDataPoint(ts time.Time, ok bool, latency time.Duration) {
 	// Update sparkline history
-	c.LatencyHistory = append(c.LatencyHistory, latencyMS)
+	c.LatencyHistory = append(c.LatencyHistory, latency)
 	if len(c.LatencyHistory) > maxLatencyHistory {
 		c.LatencyHistory = c.LatencyHistory[1:]
 	}
 
+	// Expected downtime is left out of analytics and uptime
+	if c.Expected {
+		return
+	}
+
 	// Update full history for analytics
 	c.FullHistory = append(c.FullHistory, CheckDataPoint{
 		Timestamp: ts,
 		OK:        ok,
-		LatencyMS: latencyMS,
+		Latency:   latency,
+		Gap:       c.gapBefore,
+		Seq:       dataPointSeq.Add(1),
 	})
+	c.gapBefore = 0
 	if len(c.FullHistory) > maxFullHistory {
 		c.FullHistory = c.FullHistory[1:]
+		c.pruneAnnotations()
 	}
 
 	// Update uptime counters
 	c.TotalChecks++
 	if ok {
 		c.SuccessChecks++
+		c.FailStreak = 0
+	} else {
+		c.FailStreak++
+	}
+}
+
+// outageSummary describes an outage for recovery and still-down notifications
+type outageSummary struct {
+	notify.OutageSummary
+	Reminder bool // The outage is ongoing; this is a still-down reminder
+}
+
+// uptimePct returns the share of successful probes since monitoring started
+func (c *CheckStatus) uptimePct() float64 {
+	if c.TotalChecks == 0 {
+		return 100
 	}
+	return float64(c.SuccessChecks) / float64(c.TotalChecks) * 100
 }
 
 // logEvent adds an event to the global event log
 func logEvent(e Event) {
+	e.Timestamp = e.Timestamp.UTC()
 	eventLogMutex.Lock()
 	defer eventLogMutex.Unlock()
 	eventLog = append(eventLog, e)
 	if len(eventLog) > maxEvents {
 		eventLog = eventLog[1:]
 	}
+	publishEventLocked(e)
 	log.Printf("EVENT: %s - %s check on %s: %s", e.EventType, e.CheckType, e.HostName, e.Message)
 }
 
@@ -1093,135 +2558,338 @@ func (s *State) saveConfigLocked() error {
 			}
 		}
 	}
-	ext := filepath.Ext(s.configPath)
-	switch ext {
-	case ".yaml", ".yml":
-		b, err := yaml.Marshal(s.cfg)
+	main, includes := s.cfg.SplitIncludes()
+	if err := writeConfigFile(s.configPath, main); err != nil {
+		return err
+	}
+	for _, f := range includes {
+		if len(f.Hosts) == 0 {
+			// Every host in it was deleted
+			if err := os.Remove(f.Path); err != nil && !os.IsNotExist(err) {
+				return err
+			}
+			log.Printf("removed empty include file %s", f.Path)
+			continue
+		}
+		orig, _ := os.ReadFile(f.Path)
+		b, err := f.Marshal(orig)
 		if err != nil {
 			return err
 		}
-		tmp := s.configPath + ".tmp"
-		if err := os.WriteFile(tmp, b, 0644); err != nil {
+		if bytes.Equal(b, orig) {
+			continue
+		}
+		warnTOMLComments(f.Path, orig)
+		if err := writeFileReplacing(f.Path, b); err != nil {
 			return err
 		}
-		if err := os.Rename(tmp, s.configPath); err != nil {
-			// fallback: write directly
-			if werr := os.WriteFile(s.configPath, b, 0644); werr != nil {
-				return werr
-			}
+		log.Printf("saved config to %s", f.Path)
+	}
+	s.recordSaveLocked()
+	return nil
+}
+
+// writeConfigFile writes cfg to path in the format its extension names.
+// Unknown extensions are left alone.
+func writeConfigFile(path string, cfg *config.Config) error {
+	var b []byte
+	switch filepath.Ext(path) {
+	case ".yaml", ".yml":
+		// Write over the current file so hand-written comments and layout survive
+		orig, _ := os.ReadFile(path)
+		var err error
+		if b, err = config.MarshalYAMLOver(orig, cfg); err != nil {
+			return err
 		}
-		log.Printf("saved config to %s", s.configPath)
-		return nil
 	case ".toml":
 		var buf bytes.Buffer
-		if err := toml.NewEncoder(&buf).Encode(s.cfg); err != nil {
-			return err
-		}
-		tmp := s.configPath + ".tmp"
-		if err := os.WriteFile(tmp, buf.Bytes(), 0644); err != nil {
+		if err := toml.NewEncoder(&buf).Encode(cfg); err != nil {
 			return err
 		}
-		if err := os.Rename(tmp, s.configPath); err != nil {
-			if werr := os.WriteFile(s.configPath, buf.Bytes(), 0644); werr != nil {
-				return werr
-			}
-		}
-		log.Printf("saved config to %s", s.configPath)
-		return nil
+		b = buf.Bytes()
+		orig, _ := os.ReadFile(path)
+		warnTOMLComments(path, orig)
 	default:
 		return nil
 	}
+	if err := writeFileReplacing(path, b); err != nil {
+		return err
+	}
+	log.Printf("saved config to %s", path)
+	return nil
 }
 
-func notifyHealthchecksFail(base string) error {
-	client := &http.Client{Timeout: 5 * time.Second}
-	url := base
-	if url != "" && url[len(url)-1] != '/' {
-		url += "/fail"
-	} else {
-		url += "fail"
+// warnTOMLComments logs that saving drops the comments in a TOML file.
+// Only YAML is updated in place; TOML is written from scratch.
+func warnTOMLComments(path string, orig []byte) {
+	if filepath.Ext(path) == ".toml" && config.HasTOMLComments(orig) {
+		log.Printf("saving %s drops its comments: TOML configs are rewritten from scratch; use YAML to keep them", path)
 	}
-	req, _ := http.NewRequest(http.MethodGet, url, nil)
-	resp, err := client.Do(req)
-	if err != nil {
+}
+
+// writeFileReplacing writes b to a temporary file and renames it over path,
+// falling back to writing path directly
+func writeFileReplacing(path string, b []byte) error {
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, b, 0644); err != nil {
 		return err
 	}
-	_ = resp.Body.Close()
+	if err := os.Rename(tmp, path); err != nil {
+		if werr := os.WriteFile(path, b, 0644); werr != nil {
+			return werr
+		}
+	}
 	return nil
 }
 
-func notifyHealthchecksOK(base string) error {
+// healthchecksSignalURL returns the URL that sends signal (e.g. "fail", or
+// "" for success) to a Healthchecks.io check, keeping any query string
+func healthchecksSignalURL(base, signal string) (string, error) {
+	ping, err := validate.HealthchecksURL(base)
+	if err != nil || ping == "" || signal == "" {
+		return ping, err
+	}
+	u, err := url.Parse(ping)
+	if err != nil {
+		return "", err
+	}
+	u.Path += "/" + signal
+	return u.String(), nil
+}
+
+// healthchecksSignal returns the Healthchecks.io signal for a check that
+// has just run: a ping if it passed, "fail" if it failed. Nothing is sent
+// while its parent is down, in expected downtime or during the startup
+// grace period, as no alert would be.
+func healthchecksSignal(c *CheckStatus, grace bool) (string, bool) {
+	switch {
+	case c.OK:
+		return "", true
+	case c.ParentFailed || c.Expected || grace:
+		return "", false
+	default:
+		return "fail", true
+	}
+}
+
+// notifyHealthchecks sends signal to a Healthchecks.io ping URL
+func notifyHealthchecks(base, signal string) error {
+	target, err := healthchecksSignalURL(base, signal)
+	if err != nil {
+		return fmt.Errorf("healthchecks.io URL %q %w", base, err)
+	}
 	client := &http.Client{Timeout: 5 * time.Second}
-	req, _ := http.NewRequest(http.MethodGet, base, nil)
-	resp, err := client.Do(req)
+	resp, err := client.Get(target)
 	if err != nil {
 		return err
 	}
 	_ = resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("healthchecks.io returned %s", resp.Status)
+	}
 	return nil
 }
 
+// dispatchAlert sends a state change to each channel the check notifies on
+func (s *State) dispatchAlert(hs *HostStatus, c *CheckStatus, status string, sum *outageSummary, blocked []string) {
+	if c.MQTTNotify && s.mqttClient != nil {
+		s.publishMQTTStateChange(hs, c, status, sum, blocked)
+	}
+	if c.PushoverNotify && s.pushoverClient != nil {
+		s.sendPushoverAlert(hs, c, status, sum, blocked)
+	}
+	if c.TelegramNotify && s.telegramClient != nil {
+		s.sendTelegramAlert(hs, c, status, sum, blocked)
+	}
+	if len(c.ShoutrrrNotify) > 0 && s.shoutrrrClient != nil {
+		s.sendShoutrrrAlert(hs, c, status, sum, blocked)
+	}
+	if c.SMSNotify && s.twilioClient != nil {
+		s.sendSMSAlert(hs, c, status, sum, blocked)
+	}
+}
+
+// alertNotes returns the notes and runbook to include in a notification for
+// c. Check notes come first, followed by the host's; the check's runbook
+// takes precedence over the host's.
+func alertNotes(hs *HostStatus, c *CheckStatus) (notes, runbook string) {
+	var parts []string
+	for _, n := range []string{c.Notes, hs.Notes} {
+		if n = strings.TrimSpace(n); n != "" {
+			parts = append(parts, n)
+		}
+	}
+	runbook = c.RunbookURL
+	if runbook == "" {
+		runbook = hs.RunbookURL
+	}
+	return strings.Join(parts, "\n"), runbook
+}
+
 // publishMQTTStateChange publishes a state change to MQTT
-func (s *State) publishMQTTStateChange(hostName, address string, c *CheckStatus, status string) {
-	if s.mqttClient == nil {
+func (s *State) publishMQTTStateChange(hs *HostStatus, c *CheckStatus, status string, sum *outageSummary, blocked []string) {
+	if s.mqttClient == nil || s.channelMutedLocked(ChannelMQTT, hs.Name) {
 		return
 	}
+	notes, runbook := alertNotes(hs, c)
 	msg := mqtt.StateChangeMessage{
-		Timestamp: time.Now(),
-		Host:      hostName,
-		Address:   address,
-		CheckType: string(c.Type),
-		CheckID:   c.ID,
-		Status:    status,
-		LatencyMS: c.LatencyMS,
-		Message:   c.Message,
+		Timestamp:  time.Now(),
+		Host:       hs.Name,
+		Address:    hs.Address,
+		CheckType:  string(c.Type),
+		CheckID:    c.ID,
+		CheckName:  c.Name,
+		Status:     status,
+		LatencyMS:  float64(c.Latency.Microseconds()) / 1000,
+		Message:    c.Message,
+		Notes:      notes,
+		Runbook:    runbook,
+		Severity:   string(c.Severity),
+		Blocked:    blocked,
+		HostStatus: hs.Status(),
 	}
 	if c.Type == config.CheckHTTP {
 		msg.CheckURL = c.URL
 	}
-	if err := s.mqttClient.PublishStateChange(msg); err != nil {
+	if sum != nil {
+		msg.Reminder = sum.Reminder
+		msg.Outage = &mqtt.OutageSummary{
+			DowntimeSeconds: int64(sum.Downtime.Seconds()),
+			FailedProbes:    sum.FailedProbes,
+			UptimePct:       sum.UptimePct,
+		}
+		if !sum.Start.IsZero() {
+			start := sum.Start
+			msg.Outage.Start = &start
+		}
+	}
+	if b := s.batchLocked(); b != nil {
+		b.mqtt = append(b.mqtt, msg)
+		return
+	}
+	if err := s.notifyTraced(ChannelMQTT, "alert", func() error { return s.mqttClient.PublishStateChange(msg) }); err != nil {
 		log.Printf("MQTT publish error: %v", err)
 	}
 }
 
 // sendPushoverAlert sends a notification via Pushover
-func (s *State) sendPushoverAlert(hostName, address string, c *CheckStatus, status string) {
-	if s.pushoverClient == nil || !s.pushoverClient.IsEnabled() {
+func (s *State) sendPushoverAlert(hs *HostStatus, c *CheckStatus, status string, sum *outageSummary, blocked []string) {
+	if s.pushoverClient == nil || !s.pushoverClient.IsEnabled() || s.channelMutedLocked(ChannelPushover, hs.Name) {
 		return
 	}
+	notes, runbook := alertNotes(hs, c)
 	msg := pushover.AlertMessage{
-		Host:      hostName,
-		Address:   address,
-		CheckType: string(c.Type),
-		CheckID:   c.ID,
-		Status:    status,
-		Message:   c.Message,
-		LatencyMS: c.LatencyMS,
-	}
-	if err := s.pushoverClient.SendAlert(msg); err != nil {
+		Host:       hs.Name,
+		Address:    hs.Address,
+		CheckType:  string(c.Type),
+		CheckID:    c.ID,
+		CheckName:  c.Name,
+		Status:     status,
+		Message:    c.Message,
+		Latency:    checks.RoundLatency(c.Latency),
+		Notes:      notes,
+		Runbook:    runbook,
+		Severity:   string(c.Severity),
+		Time:       time.Now(),
+		Blocked:    blocked,
+		HostStatus: hs.Status(),
+	}
+	if sum != nil {
+		msg.Reminder = sum.Reminder
+		msg.Outage = &sum.OutageSummary
+	}
+	if s.holdForQuietHoursLocked(ChannelPushover, c, msg.Time) {
+		s.queuePushoverLocked(msg)
+		return
+	}
+	if b := s.batchLocked(); b != nil {
+		b.pushover = append(b.pushover, msg)
+		return
+	}
+	if err := s.notifyTraced(ChannelPushover, "alert", func() error { return s.pushoverClient.SendAlert(msg) }); err != nil {
 		log.Printf("Pushover error: %v", err)
 	}
 }
 
 // sendTelegramAlert sends a notification via Telegram
-func (s *State) sendTelegramAlert(hostName, address string, c *CheckStatus, status string) {
-	if s.telegramClient == nil || !s.telegramClient.IsEnabled() {
+func (s *State) sendTelegramAlert(hs *HostStatus, c *CheckStatus, status string, sum *outageSummary, blocked []string) {
+	if s.telegramClient == nil || !s.telegramClient.IsEnabled() || s.channelMutedLocked(ChannelTelegram, hs.Name) {
 		return
 	}
+	notes, runbook := alertNotes(hs, c)
 	msg := telegram.AlertMessage{
-		Host:      hostName,
-		Address:   address,
-		CheckType: string(c.Type),
-		CheckID:   c.ID,
-		Status:    status,
-		Message:   c.Message,
-		LatencyMS: c.LatencyMS,
-	}
-	if err := s.telegramClient.SendAlert(msg); err != nil {
+		Host:       hs.Name,
+		Address:    hs.Address,
+		CheckType:  string(c.Type),
+		CheckID:    c.ID,
+		CheckName:  c.Name,
+		Status:     status,
+		Message:    c.Message,
+		Latency:    checks.RoundLatency(c.Latency),
+		Notes:      notes,
+		Runbook:    runbook,
+		Severity:   string(c.Severity),
+		Time:       time.Now(),
+		Blocked:    blocked,
+		HostStatus: hs.Status(),
+	}
+	if sum != nil {
+		msg.Reminder = sum.Reminder
+		msg.Outage = &sum.OutageSummary
+	}
+	if s.holdForQuietHoursLocked(ChannelTelegram, c, msg.Time) {
+		s.queueTelegramLocked(msg)
+		return
+	}
+	if b := s.batchLocked(); b != nil {
+		b.telegram = append(b.telegram, msg)
+		return
+	}
+	if err := s.notifyTraced(ChannelTelegram, "alert", func() error { return s.telegramClient.SendAlert(msg) }); err != nil {
 		log.Printf("Telegram error: %v", err)
 	}
 }
 
+// sendShoutrrrAlert sends a notification to the check's Shoutrrr labels
+func (s *State) sendShoutrrrAlert(hs *HostStatus, c *CheckStatus, status string, sum *outageSummary, blocked []string) {
+	if s.shoutrrrClient == nil || !s.shoutrrrClient.IsEnabled() || s.channelMutedLocked(ChannelShoutrrr, hs.Name) {
+		return
+	}
+	notes, runbook := alertNotes(hs, c)
+	msg := shoutrrr.AlertMessage{
+		Labels:     c.ShoutrrrNotify,
+		Host:       hs.Name,
+		Address:    hs.Address,
+		CheckType:  string(c.Type),
+		CheckID:    c.ID,
+		CheckName:  c.Name,
+		Status:     status,
+		Message:    c.Message,
+		Latency:    checks.RoundLatency(c.Latency),
+		Notes:      notes,
+		Runbook:    runbook,
+		Severity:   string(c.Severity),
+		Time:       time.Now(),
+		Blocked:    blocked,
+		HostStatus: hs.Status(),
+	}
+	if sum != nil {
+		msg.Reminder = sum.Reminder
+		msg.Outage = &sum.OutageSummary
+	}
+	if s.holdForQuietHoursLocked(ChannelShoutrrr, c, msg.Time) {
+		s.queueShoutrrrLocked(msg)
+		return
+	}
+	if b := s.batchLocked(); b != nil {
+		b.shoutrrr = append(b.shoutrrr, msg)
+		return
+	}
+	if err := s.notifyTraced(ChannelShoutrrr, "alert", func() error { return s.shoutrrrClient.SendAlert(msg) }); err != nil {
+		log.Printf("Shoutrrr error: %v", err)
+	}
+}
+
 // GetMQTTSettings returns the current MQTT settings
 func (s *State) GetMQTTSettings() config.MQTTSettings {
 	s.mu.RLock()
@@ -1282,6 +2950,22 @@ func (s *State) TestPushover() error {
 	return s.pushoverClient.TestNotification()
 }
 
+// GetAlertSettings returns the channel-independent alert settings
+func (s *State) GetAlertSettings() config.AlertSettings {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.cfg.Settings.Alerts
+}
+
+// UpdateAlertSettings updates the alert settings; they take effect on the next run
+func (s *State) UpdateAlertSettings(settings config.AlertSettings) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.cfg.Settings.Alerts = settings
+	return s.saveConfigLocked()
+}
+
 // GetTelegramSettings returns the current Telegram settings
 func (s *State) GetTelegramSettings() config.TelegramSettings {
 	s.mu.RLock()
@@ -0,0 +1,172 @@
+package state
+
+import (
+	"testing"
+	"time"
+
+	"github.com/andrewsjg/simple-healthchecker/copilot/internal/checks"
+	"github.com/andrewsjg/simple-healthchecker/copilot/internal/config"
+)
+
+// newFakeState returns a State for cfg whose probes go to a checks.Fake
+func newFakeState(cfg *config.Config) (*State, *checks.Fake) {
+	st := New(cfg)
+	fake := checks.NewFake()
+	st.SetChecker(fake)
+	return st, fake
+}
+
+// check returns the status of a host's check after the last run
+func check(t *testing.T, st *State, host string, idx int) CheckStatus {
+	t.Helper()
+	hs, ok := st.GetHost(host)
+	if !ok || idx >= len(hs.Checks) {
+		t.Fatalf("no check %d on %s", idx, host)
+	}
+	return hs.Checks[idx]
+}
+
+// eventTypes returns the types of host's events, oldest first
+func eventTypes(host string) []string {
+	var types []string
+	events := GetEvents(0)
+	for i := len(events) - 1; i >= 0; i-- {
+		if events[i].HostName == host {
+			types = append(types, events[i].EventType)
+		}
+	}
+	return types
+}
+
+func TestRunAtRecordsResults(t *testing.T) {
+	cfg := &config.Config{Hosts: []config.Host{{
+		Name:    "web",
+		Address: "10.0.0.10",
+		Checks: []config.Check{
+			{Type: config.CheckPing, Enabled: true},
+			{Type: config.CheckHTTP, Enabled: true, URL: "http://10.0.0.10/health"},
+			{Type: config.CheckTCP, Enabled: true, Port: 5432},
+			{Type: config.CheckTCP, Enabled: false, Port: 22},
+		},
+	}}}
+	st, fake := newFakeState(cfg)
+	fake.SetHTTP("http://10.0.0.10/health", checks.HTTPResult{Code: 503, Latency: 40 * time.Millisecond})
+
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	st.runAt(now)
+
+	if c := check(t, st, "web", 0); !c.OK || !c.CheckedAt.Equal(now) {
+		t.Errorf("ping = ok %v at %v, want ok at %v", c.OK, c.CheckedAt, now)
+	}
+	if c := check(t, st, "web", 1); c.OK || c.Message != "status 503 (expect 200)" {
+		t.Errorf("http = ok %v, %q; want down with the status", c.OK, c.Message)
+	}
+	if c := check(t, st, "web", 2); !c.OK {
+		t.Errorf("tcp = %q, want up", c.Message)
+	}
+	if c := check(t, st, "web", 3); !c.CheckedAt.IsZero() {
+		t.Errorf("disabled check ran at %v", c.CheckedAt)
+	}
+	want := []string{"ping 10.0.0.10", "http http://10.0.0.10/health", "tcp 10.0.0.10:5432"}
+	if calls := fake.Calls(); len(calls) != len(want) {
+		t.Errorf("probes = %q, want %q", calls, want)
+	}
+
+	h := st.GetSchedulerHealth()
+	if h.Runs != 1 || h.Checks != 3 || h.Failures != 1 {
+		t.Errorf("scheduler health = %d runs, %d checks, %d failures; want 1, 3, 1", h.Runs, h.Checks, h.Failures)
+	}
+}
+
+func TestRunAtDownAndRecovered(t *testing.T) {
+	cfg := &config.Config{Hosts: []config.Host{{
+		Name:    "flaky-nas",
+		Address: "10.0.0.20",
+		Checks:  []config.Check{{Type: config.CheckPing, Enabled: true}},
+	}}}
+	st, fake := newFakeState(cfg)
+	start := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+
+	st.runAt(start)
+	fake.SetHostDown("10.0.0.20")
+	st.runAt(start.Add(time.Minute))
+	st.runAt(start.Add(2 * time.Minute))
+	fake.SetPing("10.0.0.20", checks.PingResult{OK: true, PacketsTx: 1, PacketsRx: 1})
+	st.runAt(start.Add(5 * time.Minute))
+
+	got := eventTypes("flaky-nas")
+	if len(got) != 2 || got[0] != "down" || got[1] != "recovered" {
+		t.Fatalf("events = %q, want one down and one recovered", got)
+	}
+	c := check(t, st, "flaky-nas", 0)
+	if !c.OK || !c.LastDownAt.Equal(start.Add(time.Minute)) {
+		t.Errorf("check = ok %v, down at %v; want up, down at %v", c.OK, c.LastDownAt, start.Add(time.Minute))
+	}
+	if c.TotalChecks != 4 || c.SuccessChecks != 2 {
+		t.Errorf("runs = %d, %d passed; want 4, 2", c.TotalChecks, c.SuccessChecks)
+	}
+}
+
+func TestRunAtDependencies(t *testing.T) {
+	cfg := &config.Config{Hosts: []config.Host{
+		{
+			Name:    "core-switch",
+			Address: "10.0.0.2",
+			Checks:  []config.Check{{Type: config.CheckPing, Enabled: true, ID: "switch-ping"}},
+		},
+		{
+			Name:    "nas",
+			Address: "10.0.0.30",
+			Checks:  []config.Check{{Type: config.CheckPing, Enabled: true, DependsOn: "switch-ping"}},
+		},
+	}}
+	st, fake := newFakeState(cfg)
+	start := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	st.runAt(start)
+
+	// Both unreachable: the nas is blocked by the switch, not down itself
+	fake.SetHostDown("10.0.0.2")
+	fake.SetHostDown("10.0.0.30")
+	st.runAt(start.Add(time.Minute))
+	nas := check(t, st, "nas", 0)
+	if nas.OK || !nas.ParentFailed || nas.Message != "parent check failed" {
+		t.Errorf("nas = ok %v, parent failed %v, %q; want blocked by its parent", nas.OK, nas.ParentFailed, nas.Message)
+	}
+	if got := eventTypes("nas"); len(got) != 0 {
+		t.Errorf("nas events = %q, want none while its parent is down", got)
+	}
+	if got := eventTypes("core-switch"); len(got) != 1 || got[0] != "down" {
+		t.Errorf("switch events = %q, want one down", got)
+	}
+
+	// The switch comes back but the nas doesn't: now the nas is down
+	fake.SetPing("10.0.0.2", checks.PingResult{OK: true, PacketsTx: 1, PacketsRx: 1})
+	st.runAt(start.Add(2 * time.Minute))
+	nas = check(t, st, "nas", 0)
+	if nas.OK || nas.ParentFailed {
+		t.Errorf("nas = ok %v, parent failed %v; want down on its own account", nas.OK, nas.ParentFailed)
+	}
+	if got := eventTypes("nas"); len(got) != 1 || got[0] != "down" {
+		t.Errorf("nas events = %q, want one down once its parent is back", got)
+	}
+}
+
+func TestRunAtSkipsOffScheduleChecks(t *testing.T) {
+	cfg := &config.Config{Hosts: []config.Host{{
+		Name:    "office-printer",
+		Address: "10.0.0.40",
+		Checks:  []config.Check{{Type: config.CheckPing, Enabled: true, Schedule: "mon-fri 08:00-18:00"}},
+	}}}
+	st, fake := newFakeState(cfg)
+
+	// A Sunday, in the machine's own zone
+	sunday := time.Date(2026, 3, 1, 12, 0, 0, 0, time.Local)
+	st.runAt(sunday)
+	if c := check(t, st, "office-printer", 0); !c.OffSchedule || len(fake.Calls()) != 0 {
+		t.Errorf("Sunday: off schedule %v, probes %q; want skipped", c.OffSchedule, fake.Calls())
+	}
+	st.runAt(sunday.AddDate(0, 0, 1))
+	if c := check(t, st, "office-printer", 0); c.OffSchedule || len(fake.Calls()) != 1 {
+		t.Errorf("Monday: off schedule %v, probes %q; want run", c.OffSchedule, fake.Calls())
+	}
+}
@@ -0,0 +1,206 @@
+package state
+
+import (
+	"log"
+	"sync"
+	"time"
+)
+
+// maxTickHistory is how many scheduler runs the monitor health page keeps
+const maxTickHistory = 360
+
+// TickStats records one scheduler run
+type TickStats struct {
+	Start         time.Time
+	Duration      time.Duration
+	Host          string        // The only host run, for "Run now" on one host
+	Manual        bool          // Started by "Run now" rather than the ticker
+	Offline       bool          // Skipped because the monitor itself was offline
+	Checks        int           // Checks run
+	Failures      int           // Checks run that were down
+	Notifications int           // Notifications delivered during the run
+	NotifyTime    time.Duration // Time spent delivering them
+	Overran       bool          // A scheduled run that took longer than the interval
+	Gap           time.Duration // Time without runs before it, e.g. asleep; see noteGapLocked
+}
+
+// SchedulerHealth summarises how the monitoring loop itself is doing
+type SchedulerHealth struct {
+	Interval time.Duration // Zero until the scheduler has started
+	Recent   []TickStats   // Newest first
+
+	// Totals since startup
+	Runs          int64
+	Overruns      int64 // Scheduled runs that took longer than the interval
+	SkippedTicks  int64 // Scheduled runs skipped because the one before was still going
+	RunTime       time.Duration
+	Checks        int64
+	Failures      int64
+	Notifications int64
+	NotifyTime    time.Duration
+	Gaps          int64         // Stretches with no data, e.g. while the machine slept
+	GapTime       time.Duration // Their total length
+}
+
+// Last returns the most recent run, if there has been one
+func (h SchedulerHealth) Last() (TickStats, bool) {
+	if len(h.Recent) == 0 {
+		return TickStats{}, false
+	}
+	return h.Recent[0], true
+}
+
+// AvgDuration is the mean duration of the recent runs
+func (h SchedulerHealth) AvgDuration() time.Duration {
+	if len(h.Recent) == 0 {
+		return 0
+	}
+	var sum time.Duration
+	for _, t := range h.Recent {
+		sum += t.Duration
+	}
+	return sum / time.Duration(len(h.Recent))
+}
+
+// MaxDuration is the longest of the recent runs
+func (h SchedulerHealth) MaxDuration() time.Duration {
+	var longest time.Duration
+	for _, t := range h.Recent {
+		longest = max(longest, t.Duration)
+	}
+	return longest
+}
+
+// tickHistory holds the recent runs and the totals since startup
+type tickHistory struct {
+	SchedulerHealth
+	current   *TickStats // Run in progress, nil between runs
+	wallStart time.Time  // When the run in progress really started; its Start may be simulated
+	lastEnd   time.Time  // When the last run really finished; zero until one has, and after a pause
+}
+
+// beginTickStatsLocked starts recording a run at now
+func (s *State) beginTickStatsLocked(now time.Time, only string, manual bool) {
+	s.ticks.current = &TickStats{Start: now, Host: only, Manual: manual}
+	s.ticks.wallStart = time.Now()
+}
+
+// endTickStatsLocked finishes the run in progress, having run checks of
+// which failures were down
+func (s *State) endTickStatsLocked(checks, failures int) {
+	t := s.ticks.current
+	if t == nil {
+		return
+	}
+	s.ticks.current = nil
+	s.ticks.lastEnd = time.Now()
+	t.Duration = time.Since(s.ticks.wallStart)
+	t.Checks, t.Failures = checks, failures
+	interval := s.ticks.Interval
+	if !t.Manual && interval > 0 {
+		if t.Duration > interval {
+			t.Overran = true
+			s.ticks.Overruns++
+			log.Printf("warning: scheduler run took %v, longer than the %v interval", t.Duration.Round(time.Millisecond), interval)
+		}
+	}
+
+	h := &s.ticks.SchedulerHealth
+	h.Runs++
+	h.RunTime += t.Duration
+	h.Checks += int64(t.Checks)
+	h.Failures += int64(t.Failures)
+	h.Notifications += int64(t.Notifications)
+	h.NotifyTime += t.NotifyTime
+	h.Recent = append(h.Recent, *t)
+	if len(h.Recent) > maxTickHistory {
+		h.Recent = h.Recent[len(h.Recent)-maxTickHistory:]
+	}
+}
+
+// noteNotificationLocked counts a notification delivered during the run in
+// progress, if any
+func (s *State) noteNotificationLocked(took time.Duration) {
+	if t := s.ticks.current; t != nil {
+		t.Notifications++
+		t.NotifyTime += took
+	}
+}
+
+// GetSchedulerHealth returns the recent scheduler runs and totals
+func (s *State) GetSchedulerHealth() SchedulerHealth {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	h := s.ticks.SchedulerHealth
+	s.runs.mu.Lock()
+	h.SkippedTicks = s.runs.skipped
+	s.runs.mu.Unlock()
+	h.Recent = make([]TickStats, len(s.ticks.Recent))
+	for i, t := range s.ticks.Recent {
+		h.Recent[len(h.Recent)-1-i] = t
+	}
+	return h
+}
+
+// runGate keeps scheduler runs from overlapping. A scheduled run that comes
+// due while another is still going is skipped; a "Run now" waits its turn,
+// and any more asked for while it waits are merged into it.
+type runGate struct {
+	sem chan struct{} // Held by the run in progress
+
+	mu         sync.Mutex
+	interval   time.Duration
+	started    time.Time // When the run in progress started
+	queued     bool      // A "Run now" is waiting for the run in progress
+	queuedHost string    // Host it will run, or "" for every host
+	skipped    int64     // Scheduled runs skipped
+}
+
+func newRunGate() *runGate {
+	return &runGate{sem: make(chan struct{}, 1)}
+}
+
+// tryRun runs run unless another run is in progress, in which case it is
+// skipped with a warning
+func (g *runGate) tryRun(run func()) {
+	select {
+	case g.sem <- struct{}{}:
+	default:
+		g.mu.Lock()
+		defer g.mu.Unlock()
+		g.skipped++
+		busy := time.Since(g.started)
+		log.Printf("warning: scheduler tick skipped: the previous run has taken %v, overrunning the %v interval by %v",
+			busy.Round(time.Millisecond), g.interval, (busy - g.interval).Round(time.Millisecond))
+		return
+	}
+	g.mu.Lock()
+	g.started = time.Now()
+	g.mu.Unlock()
+	defer func() { <-g.sem }()
+	run()
+}
+
+// queue runs run for host once any run in progress has finished. If a
+// queued run is already waiting, host is merged into it instead: the same
+// host, or every host if they differ.
+func (g *runGate) queue(host string, run func(host string)) {
+	g.mu.Lock()
+	if g.queued {
+		if g.queuedHost != host {
+			g.queuedHost = ""
+		}
+		g.mu.Unlock()
+		return
+	}
+	g.queued, g.queuedHost = true, host
+	g.mu.Unlock()
+
+	g.sem <- struct{}{}
+	defer func() { <-g.sem }()
+	g.mu.Lock()
+	host = g.queuedHost
+	g.queued, g.started = false, time.Now()
+	g.mu.Unlock()
+	run(host)
+}
@@ -0,0 +1,39 @@
+package state
+
+import (
+	"time"
+
+	"github.com/andrewsjg/simple-healthchecker/copilot/internal/checks"
+)
+
+// noteResponse adds an http probe's phases and body size to the data point
+// just recorded for it. Probes that were blocked, or in expected downtime,
+// record no point or no latency, so they have neither.
+func (c *CheckStatus) noteResponse(at time.Time, res checks.HTTPResult) {
+	n := len(c.FullHistory)
+	if n == 0 || !c.FullHistory[n-1].Timestamp.Equal(at) || c.FullHistory[n-1].Latency == 0 {
+		return
+	}
+	dp := &c.FullHistory[n-1]
+	dp.Timing, dp.Size, dp.Download = res.Timing, res.Size, res.Download
+}
+
+// averageTiming is the mean of each phase over the timed points in history
+func averageTiming(history []CheckDataPoint) checks.HTTPTiming {
+	var sum checks.HTTPTiming
+	var n time.Duration
+	for _, dp := range history {
+		if dp.Timing.IsZero() {
+			continue
+		}
+		sum.DNS += dp.Timing.DNS
+		sum.Connect += dp.Timing.Connect
+		sum.TLS += dp.Timing.TLS
+		sum.TTFB += dp.Timing.TTFB
+		n++
+	}
+	if n == 0 {
+		return sum
+	}
+	return checks.HTTPTiming{DNS: sum.DNS / n, Connect: sum.Connect / n, TLS: sum.TLS / n, TTFB: sum.TTFB / n}
+}
@@ -0,0 +1,30 @@
+package state
+
+import (
+	"strings"
+	"time"
+
+	"github.com/andrewsjg/simple-healthchecker/copilot/internal/checks"
+)
+
+// noteTLS keeps the connection details of a probe that completed a TLS
+// handshake. A failed handshake leaves the last details in place, so the
+// panel still shows the certificate that was there; they are dropped once
+// the check no longer uses TLS.
+func (c *CheckStatus) noteTLS(at time.Time, info *checks.TLSInfo) {
+	switch {
+	case info != nil:
+		c.TLS, c.TLSAt = info, at
+	case !strings.HasPrefix(c.URL, "https:") && !strings.HasPrefix(c.URL, "wss:"):
+		c.TLS, c.TLSAt = nil, time.Time{}
+	}
+}
+
+// TLSWarnings lists what is wrong with the check's TLS connection now, if
+// it has one
+func (c CheckStatus) TLSWarnings() []string {
+	if c.TLS == nil {
+		return nil
+	}
+	return c.TLS.Warnings(time.Now())
+}
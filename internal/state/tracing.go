@@ -0,0 +1,63 @@
+package state
+
+import (
+	"time"
+
+	"github.com/andrewsjg/simple-healthchecker/copilot/internal/tracing"
+)
+
+// startTickLocked opens the span for a scheduler run. Check and notification
+// spans started before endTickLocked nest under it.
+func (s *State) startTickLocked(only string) *tracing.Span {
+	tick := s.tracer.Start(nil, "scheduler.tick", tracing.KindInternal)
+	if only != "" {
+		tick.SetAttrs(tracing.String("host", only))
+	}
+	s.tickSpan = tick
+	return tick
+}
+
+func (s *State) endTickLocked(tick *tracing.Span, checks int) {
+	tick.SetAttrs(tracing.Int("checks", checks))
+	s.tickSpan = nil
+	tick.End()
+}
+
+// startCheckSpan opens the span for one probe of c on hs
+func (s *State) startCheckSpan(tick *tracing.Span, hs *HostStatus, c *CheckStatus) *tracing.Span {
+	span := s.tracer.Start(tick, "check "+string(c.Type), tracing.KindClient,
+		tracing.String("host", hs.Name), tracing.String("host.address", hs.Address), tracing.String("check.type", string(c.Type)))
+	if c.ID != "" {
+		span.SetAttrs(tracing.String("check.id", c.ID))
+	}
+	return span
+}
+
+// endCheckSpan records c's result on span. Down checks mark the span as
+// failed; blocked ones don't, since the probe isn't what went wrong.
+func endCheckSpan(span *tracing.Span, c *CheckStatus) {
+	status := "up"
+	switch {
+	case c.ParentFailed:
+		status = "blocked"
+	case !c.OK:
+		status = "down"
+		span.SetFailed(c.Message)
+	}
+	span.SetAttrs(tracing.String("check.status", status), tracing.String("check.message", c.Message), tracing.Duration("check.latency_ms", c.Latency))
+	span.End()
+}
+
+// notifyTraced runs send, which delivers a kind of notification ("alert",
+// "batch", "digest" or "notice") on channel, inside a span. During a
+// scheduler run the span joins the run's trace.
+func (s *State) notifyTraced(channel, kind string, send func() error) error {
+	span := s.tracer.Start(s.tickSpan, "notify "+channel, tracing.KindClient,
+		tracing.String("notify.channel", channel), tracing.String("notify.kind", kind))
+	sent := time.Now()
+	err := send()
+	s.noteNotificationLocked(time.Since(sent))
+	span.SetError(err)
+	span.End()
+	return err
+}
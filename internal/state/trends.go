@@ -0,0 +1,308 @@
+package state
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"slices"
+	"time"
+)
+
+// maxTrendDays is how many days of aggregates each check keeps: a year,
+// with some to spare
+const maxTrendDays = 400
+
+// trendDayLayout names a day in the display timezone
+const trendDayLayout = "2006-01-02"
+
+// DailyTrend aggregates one check's runs over a day in the display
+// timezone. The latency percentiles are of the runs that had a latency.
+type DailyTrend struct {
+	Day    string        `json:"day"` // e.g. "2026-10-18"
+	Runs   int64         `json:"runs"`
+	Passed int64         `json:"passed"`
+	Min    time.Duration `json:"min,omitempty"`
+	Median time.Duration `json:"median,omitempty"`
+	P75    time.Duration `json:"p75,omitempty"`
+	P95    time.Duration `json:"p95,omitempty"`
+	Max    time.Duration `json:"max,omitempty"`
+}
+
+// Uptime is the percentage of the day's runs that passed
+func (d DailyTrend) Uptime() float64 {
+	if d.Runs == 0 {
+		return 0
+	}
+	return float64(d.Passed) / float64(d.Runs) * 100
+}
+
+// Trend is a check's daily aggregates over a span of days, oldest first.
+// Days without runs are included, with none. Median and P95 are the means
+// of the daily figures weighted by runs, as percentiles of whole days can't
+// be combined exactly; Max is the highest latency seen.
+type Trend struct {
+	Days         []DailyTrend
+	Runs, Passed int64
+	Median, P95  time.Duration
+	Max          time.Duration
+}
+
+// newTrend totals days
+func newTrend(days []DailyTrend) Trend {
+	t := Trend{Days: days}
+	var median, p95 float64
+	var timed int64
+	for _, d := range days {
+		t.Runs += d.Runs
+		t.Passed += d.Passed
+		t.Max = max(t.Max, d.Max)
+		if d.Median > 0 {
+			median += float64(d.Median) * float64(d.Runs)
+			p95 += float64(d.P95) * float64(d.Runs)
+			timed += d.Runs
+		}
+	}
+	if timed > 0 {
+		t.Median = time.Duration(median / float64(timed))
+		t.P95 = time.Duration(p95 / float64(timed))
+	}
+	return t
+}
+
+// ActiveDays counts the days with runs
+func (t Trend) ActiveDays() int {
+	n := 0
+	for _, d := range t.Days {
+		if d.Runs > 0 {
+			n++
+		}
+	}
+	return n
+}
+
+// Uptime is the percentage of the span's runs that passed
+func (t Trend) Uptime() float64 {
+	if t.Runs == 0 {
+		return 0
+	}
+	return float64(t.Passed) / float64(t.Runs) * 100
+}
+
+// trendRecord is one line of the trends file
+type trendRecord struct {
+	Check string `json:"check"` // See trendKey
+	DailyTrend
+}
+
+// trendStore keeps each check's daily aggregates, the long-term
+// counterpart of FullHistory. Finished days are appended to a file next to
+// the config, so trends outlast restarts; the day in progress is only kept
+// in memory.
+type trendStore struct {
+	path string                  // JSON lines; empty to keep nothing on disk
+	days map[string][]DailyTrend // Finished days by trendKey, oldest first
+	open map[string]*trendDay    // Day in progress by trendKey
+}
+
+// trendDay collects a day's runs until it is over
+type trendDay struct {
+	day          string
+	runs, passed int64
+	latencies    []time.Duration
+}
+
+// trendKey identifies a check in the trends file by its host and ID, or
+// its type and position if it has none, as cfgCheckLabel does
+func trendKey(hostName string, c *CheckStatus, idx int) string {
+	if c.ID != "" {
+		return hostName + "/" + c.ID
+	}
+	return fmt.Sprintf("%s/%s#%d", hostName, c.Type, idx+1)
+}
+
+// loadTrendsLocked reads the trends kept next to the config file, dropping
+// days older than maxTrendDays
+func (s *State) loadTrendsLocked() {
+	t := &s.trends
+	t.path = s.configPath + ".trends.jsonl"
+	t.days = make(map[string][]DailyTrend)
+	f, err := os.Open(t.path)
+	if err != nil {
+		return
+	}
+	cutoff := time.Now().In(s.displayLocationLocked()).AddDate(0, 0, -maxTrendDays).Format(trendDayLayout)
+	dropped := false
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		var rec trendRecord
+		if json.Unmarshal(sc.Bytes(), &rec) != nil {
+			continue
+		}
+		if rec.Day < cutoff {
+			dropped = true
+			continue
+		}
+		t.days[rec.Check] = append(t.days[rec.Check], rec.DailyTrend)
+	}
+	f.Close()
+	if dropped {
+		t.rewrite()
+	}
+}
+
+// noteTrendLocked adds the run of the check at idx just recorded to its
+// day in progress. Runs that recorded no data point, e.g. in expected
+// downtime, are left out as they are from analytics.
+func (s *State) noteTrendLocked(hs *HostStatus, idx int, now time.Time) {
+	c := &hs.Checks[idx]
+	n := len(c.FullHistory)
+	if n == 0 || !c.FullHistory[n-1].Timestamp.Equal(now) {
+		return
+	}
+	dp := c.FullHistory[n-1]
+	s.trends.add(trendKey(hs.Name, c, idx), now.In(s.displayLocationLocked()), dp.OK, dp.Latency)
+}
+
+// add records a run at at, finishing the day in progress if at is on a
+// later one. A run on an earlier day, after the display timezone is moved
+// west, counts towards the day in progress, so finished days stay in order.
+func (t *trendStore) add(key string, at time.Time, ok bool, latency time.Duration) {
+	if t.open == nil {
+		t.open = make(map[string]*trendDay)
+	}
+	day := at.Format(trendDayLayout)
+	td := t.open[key]
+	if td != nil && day > td.day {
+		t.finish(key, td.summary())
+		td = nil
+	}
+	if td == nil {
+		td = &trendDay{day: day}
+		t.open[key] = td
+	}
+	td.runs++
+	if ok {
+		td.passed++
+	}
+	if latency > 0 {
+		td.latencies = append(td.latencies, latency)
+	}
+}
+
+// finish keeps a finished day and appends it to the file
+func (t *trendStore) finish(key string, d DailyTrend) {
+	if t.days == nil {
+		t.days = make(map[string][]DailyTrend)
+	}
+	days := append(t.days[key], d)
+	if len(days) > maxTrendDays {
+		days = slices.Clone(days[len(days)-maxTrendDays:])
+	}
+	t.days[key] = days
+	if t.path == "" {
+		return
+	}
+	f, err := os.OpenFile(t.path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		log.Printf("trends: %v", err)
+		return
+	}
+	defer f.Close()
+	line, _ := json.Marshal(trendRecord{Check: key, DailyTrend: d})
+	if _, err := f.Write(append(line, '\n')); err != nil {
+		log.Printf("trends: %v", err)
+	}
+}
+
+// rewrite writes every kept day again, after old ones are dropped
+func (t *trendStore) rewrite() {
+	var b bytes.Buffer
+	for key, days := range t.days {
+		for _, d := range days {
+			line, _ := json.Marshal(trendRecord{Check: key, DailyTrend: d})
+			b.Write(append(line, '\n'))
+		}
+	}
+	tmp := t.path + ".tmp"
+	if err := os.WriteFile(tmp, b.Bytes(), 0644); err != nil {
+		log.Printf("trends: %v", err)
+		return
+	}
+	if err := os.Rename(tmp, t.path); err != nil {
+		log.Printf("trends: %v", err)
+	}
+}
+
+// summary aggregates the day's runs so far
+func (td *trendDay) summary() DailyTrend {
+	d := DailyTrend{Day: td.day, Runs: td.runs, Passed: td.passed}
+	if len(td.latencies) == 0 {
+		return d
+	}
+	sorted := slices.Clone(td.latencies)
+	slices.Sort(sorted)
+	d.Min, d.Max = sorted[0], sorted[len(sorted)-1]
+	d.Median = sorted[len(sorted)/2]
+	d.P75 = percentile(sorted, 0.75)
+	d.P95 = percentile(sorted, 0.95)
+	return d
+}
+
+// percentile picks the p'th percentile of sorted, which mustn't be empty
+func percentile(sorted []time.Duration, p float64) time.Duration {
+	return sorted[min(int(float64(len(sorted))*p), len(sorted)-1)]
+}
+
+// CheckTrend returns the daily aggregates of the check at idx over the
+// last days days, today included
+func (s *State) CheckTrend(hostName string, idx, days int) (Trend, bool) {
+	today := time.Now()
+	return s.CheckTrendBetween(hostName, idx, today.AddDate(0, 0, 1-days), today)
+}
+
+// CheckTrendBetween returns the daily aggregates of the check at idx from
+// the day of first to the day of last, both included, in the display
+// timezone
+func (s *State) CheckTrendBetween(hostName string, idx int, first, last time.Time) (Trend, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	hs, ok := s.hosts[hostName]
+	if !ok || idx < 0 || idx >= len(hs.Checks) {
+		return Trend{}, false
+	}
+	return s.checkTrendLocked(trendKey(hostName, &hs.Checks[idx], idx), first, last), true
+}
+
+// checkTrendLocked totals the check's days from first to last, as
+// CheckTrendBetween does
+func (s *State) checkTrendLocked(key string, first, last time.Time) Trend {
+	byDay := make(map[string]DailyTrend)
+	for _, d := range s.trends.days[key] {
+		byDay[d.Day] = d
+	}
+	if td := s.trends.open[key]; td != nil {
+		byDay[td.day] = td.summary()
+	}
+	loc := s.displayLocationLocked()
+	first, last = first.In(loc), last.In(loc)
+	stop := last.Format(trendDayLayout)
+	var days []DailyTrend
+	// Stepping from noon, a day is never skipped or repeated where daylight
+	// saving starts or ends at midnight
+	start := time.Date(first.Year(), first.Month(), first.Day(), 12, 0, 0, 0, loc)
+	for day := start; len(days) < maxTrendDays; day = day.AddDate(0, 0, 1) {
+		name := day.Format(trendDayLayout)
+		if name > stop {
+			break
+		}
+		d, ok := byDay[name]
+		if !ok {
+			d = DailyTrend{Day: name}
+		}
+		days = append(days, d)
+	}
+	return newTrend(days)
+}
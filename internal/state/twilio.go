@@ -0,0 +1,124 @@
+package state
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/andrewsjg/simple-healthchecker/copilot/internal/config"
+	"github.com/andrewsjg/simple-healthchecker/copilot/internal/notify"
+	"github.com/andrewsjg/simple-healthchecker/copilot/internal/twilio"
+)
+
+// sendSMSAlert texts a state change to the Twilio numbers
+func (s *State) sendSMSAlert(hs *HostStatus, c *CheckStatus, status string, sum *outageSummary, blocked []string) {
+	if s.twilioClient == nil || !s.twilioClient.IsEnabled() || s.channelMutedLocked(ChannelSMS, hs.Name) {
+		return
+	}
+	msg := smsMessage(hs, c, status, blocked)
+	if sum != nil {
+		msg.Reminder = sum.Reminder
+		msg.Outage = &sum.OutageSummary
+	}
+	if s.holdForQuietHoursLocked(ChannelSMS, c, msg.Time) {
+		s.queueSMSLocked(msg)
+		return
+	}
+	if b := s.batchLocked(); b != nil {
+		b.sms = append(b.sms, msg)
+		return
+	}
+	if err := s.notifyTraced(ChannelSMS, "alert", func() error { return s.twilioClient.SendAlert(msg) }); err != nil {
+		log.Printf("Twilio error: %v", err)
+	}
+}
+
+// callIfProlongedLocked phones the Twilio numbers, once per outage, when a
+// critical check that texts has stayed down for the configured delay. A
+// missed text at night shouldn't be the last word on a serious outage.
+func (s *State) callIfProlongedLocked(hs *HostStatus, c *CheckStatus, now time.Time) {
+	delay := s.cfg.Settings.Twilio.CallDelay()
+	if delay <= 0 || !c.SMSNotify || c.Severity != config.SeverityCritical {
+		return
+	}
+	if c.OK || c.ParentFailed || c.alertSuppressed || c.LastDownAt.IsZero() || !c.LastCalledAt.Before(c.LastDownAt) {
+		return
+	}
+	down := now.Sub(c.LastDownAt) - noDataBetween(c.LastDownAt, now)
+	if down < delay {
+		return
+	}
+	if s.twilioClient == nil || !s.twilioClient.IsEnabled() || s.channelMutedLocked(ChannelSMS, hs.Name) {
+		return
+	}
+	c.LastCalledAt = now
+	msg := smsMessage(hs, c, "down", nil)
+	msg.Outage = &notify.OutageSummary{Start: c.LastDownAt, Downtime: down}
+	if err := s.notifyTraced(ChannelSMS, "call", func() error { return s.twilioClient.Call(msg) }); err != nil {
+		log.Printf("Twilio error: %v", err)
+	}
+}
+
+func smsMessage(hs *HostStatus, c *CheckStatus, status string, blocked []string) twilio.AlertMessage {
+	return twilio.AlertMessage{
+		Host:       hs.Name,
+		Address:    hs.Address,
+		CheckType:  string(c.Type),
+		CheckID:    c.ID,
+		CheckName:  c.Name,
+		Status:     status,
+		Message:    c.Message,
+		Severity:   string(c.Severity),
+		Time:       time.Now(),
+		Blocked:    blocked,
+		HostStatus: hs.Status(),
+	}
+}
+
+// SetCheckSMS sets whether the check at idx texts the Twilio numbers
+func (s *State) SetCheckSMS(hostName string, idx int, notify bool) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	hs, ok := s.hosts[hostName]
+	if !ok {
+		return fmt.Errorf("host not found")
+	}
+	if idx < 0 || idx >= len(hs.Checks) {
+		return fmt.Errorf("bad index")
+	}
+	hs.Checks[idx].SMSNotify = notify
+	for i := range s.cfg.Hosts {
+		if s.cfg.Hosts[i].Name == hostName {
+			if idx < len(s.cfg.Hosts[i].Checks) {
+				s.cfg.Hosts[i].Checks[idx].SMSNotify = notify
+			}
+			break
+		}
+	}
+	return s.saveConfigLocked()
+}
+
+// GetTwilioSettings returns the current Twilio settings
+func (s *State) GetTwilioSettings() config.TwilioSettings {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.cfg.Settings.Twilio
+}
+
+// UpdateTwilioSettings updates the Twilio settings
+func (s *State) UpdateTwilioSettings(settings config.TwilioSettings) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.cfg.Settings.Twilio = settings
+	s.twilioClient.UpdateSettings(settings)
+	return s.saveConfigLocked()
+}
+
+// IsTwilioEnabled returns whether SMS alerts are enabled
+func (s *State) IsTwilioEnabled() bool {
+	if s.twilioClient == nil {
+		return false
+	}
+	return s.twilioClient.IsEnabled()
+}
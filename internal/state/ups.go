@@ -0,0 +1,112 @@
+package state
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/andrewsjg/simple-healthchecker/copilot/internal/checks"
+	"github.com/andrewsjg/simple-healthchecker/copilot/internal/config"
+)
+
+// upsOptionsFromConfig extracts which daemon a ups check asks and its
+// battery limit
+func upsOptionsFromConfig(c config.Check) checks.UPSOptions {
+	return checks.UPSOptions{
+		Daemon:    c.Daemon,
+		UPS:       c.UPS,
+		MinCharge: c.MinCharge,
+		Port:      c.Port,
+	}
+}
+
+// setCfgUPSOptions copies a ups check's options into its config
+func setCfgUPSOptions(c *config.Check, opts checks.UPSOptions) {
+	c.Daemon = opts.Daemon
+	c.UPS = opts.UPS
+	c.MinCharge = opts.MinCharge
+	c.Port = opts.Port
+}
+
+// upsMessage describes a ups check's result, e.g. "Back-UPS XS 700U: on
+// battery, discharging; battery 64%, 19m left, load 21%"
+func upsMessage(res checks.UPSResult) string {
+	if res.Err != nil {
+		return res.Err.Error()
+	}
+	msg := strings.Join(res.Status, ", ")
+	if res.Model != "" {
+		msg = res.Model + ": " + msg
+	}
+	var details []string
+	if res.Charge >= 0 {
+		charge := fmt.Sprintf("battery %.0f%%", res.Charge)
+		for _, a := range res.Alarms {
+			if strings.HasPrefix(a, charge) {
+				charge = a // With the limit it's under
+			}
+		}
+		details = append(details, charge)
+	}
+	if res.Runtime > 0 {
+		details = append(details, formatAge(res.Runtime)+" left")
+	}
+	if res.Load >= 0 {
+		details = append(details, fmt.Sprintf("load %.0f%%", res.Load))
+	}
+	if len(details) > 0 {
+		msg += "; " + strings.Join(details, ", ")
+	}
+	return msg
+}
+
+// AddUPSCheck appends a UPS check to the named host
+func (s *State) AddUPSCheck(hostName string, opts checks.UPSOptions, id, dependsOn string, mqttNotify, pushoverNotify, telegramNotify bool) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	hs, ok := s.hosts[hostName]
+	if !ok {
+		return fmt.Errorf("host not found")
+	}
+	if s.checkIDInUseLocked(id, "") {
+		return fmt.Errorf("check id %q already in use", id)
+	}
+	c := config.Check{Type: config.CheckUPS, Enabled: true, ID: id, DependsOn: dependsOn, MQTTNotify: mqttNotify, PushoverNotify: pushoverNotify, TelegramNotify: telegramNotify}
+	setCfgUPSOptions(&c, opts)
+	hs.Checks = append(hs.Checks, CheckStatus{Type: config.CheckUPS, Enabled: true, UPSOpts: opts, ID: id, DependsOn: dependsOn, MQTTNotify: mqttNotify, PushoverNotify: pushoverNotify, TelegramNotify: telegramNotify, Severity: config.SeverityWarning})
+	for i := range s.cfg.Hosts {
+		if s.cfg.Hosts[i].Name == hostName {
+			s.cfg.Hosts[i].Checks = append(s.cfg.Hosts[i].Checks, c)
+			break
+		}
+	}
+	s.rebuildCheckIndex()
+	return s.saveConfigLocked()
+}
+
+// SetCheckUPS updates the daemon, UPS and battery limit of the ups check at
+// idx
+func (s *State) SetCheckUPS(hostName string, idx int, opts checks.UPSOptions) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	hs, ok := s.hosts[hostName]
+	if !ok {
+		return fmt.Errorf("host not found")
+	}
+	if idx < 0 || idx >= len(hs.Checks) {
+		return fmt.Errorf("bad index")
+	}
+	c := &hs.Checks[idx]
+	if c.Type != config.CheckUPS {
+		return fmt.Errorf("not ups check")
+	}
+	c.UPSOpts = opts
+	for i := range s.cfg.Hosts {
+		if s.cfg.Hosts[i].Name == hostName {
+			if idx < len(s.cfg.Hosts[i].Checks) {
+				setCfgUPSOptions(&s.cfg.Hosts[i].Checks[idx], opts)
+			}
+			break
+		}
+	}
+	return s.saveConfigLocked()
+}
@@ -0,0 +1,49 @@
+package state
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+// versionMutex is the State's lock. Every write unlock bumps its version,
+// so readers can tell that nothing has changed since a version they saw
+// without comparing the state itself.
+type versionMutex struct {
+	sync.RWMutex
+	version atomic.Uint64
+
+	watchMu sync.Mutex
+	changed chan struct{} // Closed on the next write unlock, if anyone is waiting
+}
+
+// Unlock bumps the version before releasing the lock, so a reader that sees
+// the new version is bound to see the change that came with it
+func (m *versionMutex) Unlock() {
+	m.version.Add(1)
+	m.RWMutex.Unlock()
+	m.watchMu.Lock()
+	if m.changed != nil {
+		close(m.changed)
+		m.changed = nil
+	}
+	m.watchMu.Unlock()
+}
+
+// Version changes whenever anything in the state may have. Checks running,
+// edits, mutes and pausing all bump it; the same version means the same
+// hosts, checks and settings.
+func (s *State) Version() uint64 {
+	return s.mu.version.Load()
+}
+
+// Changed returns a channel that is closed the next time the state changes.
+// Take it before reading the state, so a change made while reading is not
+// missed.
+func (s *State) Changed() <-chan struct{} {
+	s.mu.watchMu.Lock()
+	defer s.mu.watchMu.Unlock()
+	if s.mu.changed == nil {
+		s.mu.changed = make(chan struct{})
+	}
+	return s.mu.changed
+}
@@ -0,0 +1,115 @@
+package state
+
+import (
+	"crypto/hmac"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/andrewsjg/simple-healthchecker/copilot/internal/config"
+)
+
+// maxWebhookMessage is the most of a posted message kept, in bytes
+const maxWebhookMessage = 500
+
+var (
+	ErrWebhookNotFound = errors.New("no webhook check has this id")
+	ErrWebhookToken    = errors.New("wrong or missing webhook token")
+)
+
+// WebhookResult is a result posted to a webhook check
+type WebhookResult struct {
+	At      time.Time
+	OK      bool
+	Message string        // The sender's own description, if any
+	Latency time.Duration // How long the job took, if the sender said
+}
+
+// PostWebhook records res as the latest result of the webhook check with
+// the given ID and updates that check, so it shows straight away; the
+// host's other checks wait for the scheduler. token
+// must match the check's webhook_token, if it has one; a check without one
+// accepts any post unless needToken is set, when it accepts none.
+func (s *State) PostWebhook(id, token string, needToken bool, res WebhookResult) error {
+	s.mu.Lock()
+	hostName, want, ok := s.webhookLocked(id)
+	if !ok {
+		s.mu.Unlock()
+		return ErrWebhookNotFound
+	}
+	if (want == "" && needToken) || (want != "" && !hmac.Equal([]byte(token), []byte(want))) {
+		s.mu.Unlock()
+		return ErrWebhookToken
+	}
+	if len(res.Message) > maxWebhookMessage {
+		res.Message = res.Message[:maxWebhookMessage]
+	}
+	if s.webhooks == nil {
+		s.webhooks = make(map[string]WebhookResult)
+	}
+	s.webhooks[id] = res
+	s.mu.Unlock()
+	go func() {
+		// Not queued behind the scheduler's runs, but timed once any run in
+		// progress has let go of the lock, so it comes after it
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		s.runHostsLocked(time.Now(), runScope{host: hostName, check: id}, nil)
+	}()
+	return nil
+}
+
+// webhookLocked finds the webhook check with the given ID, returning its
+// host and token
+func (s *State) webhookLocked(id string) (string, string, bool) {
+	for _, h := range s.cfg.Hosts {
+		for _, c := range h.Checks {
+			if c.ID == id && c.Type == config.CheckWebhook {
+				return h.Name, c.WebhookToken, true
+			}
+		}
+	}
+	return "", "", false
+}
+
+// webhookDueLocked reports whether a webhook check has anything to show:
+// a result, or none for longer than its max age since monitoring started
+func (s *State) webhookDueLocked(c *CheckStatus, now time.Time) bool {
+	if _, ok := s.webhooks[c.ID]; ok {
+		return true
+	}
+	return c.MaxAge > 0 && now.Sub(s.started) > c.MaxAge
+}
+
+// webhookResultLocked repeats a webhook check's last posted result, or
+// fails it when the result is older than its max age
+func (s *State) webhookResultLocked(c *CheckStatus, now time.Time) (bool, time.Duration, string) {
+	res, ok := s.webhooks[c.ID]
+	if !ok {
+		return false, 0, fmt.Sprintf("no result received in %s", formatAge(now.Sub(s.started)))
+	}
+	if age := now.Sub(res.At); c.MaxAge > 0 && age > c.MaxAge {
+		return false, 0, fmt.Sprintf("no result for %s", formatAge(age))
+	}
+	msg := res.Message
+	if msg == "" && res.OK {
+		msg = "reported ok"
+	} else if msg == "" {
+		msg = "reported failure"
+	}
+	return res.OK, res.Latency, msg
+}
+
+// formatAge rounds an age to the minute, e.g. "25h3m" or "26h", or to the
+// second under a minute
+func formatAge(d time.Duration) string {
+	if d < time.Minute {
+		return d.Round(time.Second).String()
+	}
+	s := strings.TrimSuffix(d.Round(time.Minute).String(), "0s")
+	if strings.HasSuffix(s, "h0m") {
+		s = strings.TrimSuffix(s, "0m")
+	}
+	return s
+}
@@ -0,0 +1,65 @@
+package state
+
+import (
+	"testing"
+	"time"
+
+	"github.com/andrewsjg/simple-healthchecker/copilot/internal/config"
+)
+
+func TestPostWebhookRunsOnlyTheWebhookCheck(t *testing.T) {
+	cfg := &config.Config{Hosts: []config.Host{{
+		Name:    "nas",
+		Address: "10.0.0.2",
+		Checks: []config.Check{
+			{Type: config.CheckPing, Enabled: true},
+			{Type: config.CheckWebhook, Enabled: true, ID: "backup"},
+		},
+	}}}
+	st, fake := newFakeState(cfg)
+	st.runAt(time.Now())
+	runs := st.GetSchedulerHealth().Runs
+
+	if err := st.PostWebhook("backup", "", false, WebhookResult{At: time.Now(), OK: true, Message: "nightly backup done"}); err != nil {
+		t.Fatalf("PostWebhook: %v", err)
+	}
+	var webhook CheckStatus
+	for deadline := time.Now().Add(2 * time.Second); time.Now().Before(deadline); time.Sleep(5 * time.Millisecond) {
+		hs, _ := st.GetHost("nas")
+		if webhook = hs.Checks[1]; !webhook.CheckedAt.IsZero() {
+			break
+		}
+	}
+	if !webhook.OK || webhook.Message != "nightly backup done" {
+		t.Fatalf("webhook check = ok %v, %q; want the posted result", webhook.OK, webhook.Message)
+	}
+	if calls := fake.Calls(); len(calls) != 1 {
+		t.Errorf("probes = %q; the post should not run the ping check again", calls)
+	}
+	if got := st.GetSchedulerHealth().Runs; got != runs {
+		t.Errorf("scheduler runs = %d after the post, want %d", got, runs)
+	}
+}
+
+func TestPostWebhookToken(t *testing.T) {
+	cfg := &config.Config{Hosts: []config.Host{{
+		Name:   "nas",
+		Checks: []config.Check{{Type: config.CheckWebhook, Enabled: true, ID: "backup", WebhookToken: "s3cret"}},
+	}}}
+	st, _ := newFakeState(cfg)
+
+	tests := []struct {
+		id, token string
+		want      error
+	}{
+		{"backup", "s3cret", nil},
+		{"backup", "wrong", ErrWebhookToken},
+		{"backup", "", ErrWebhookToken},
+		{"missing", "s3cret", ErrWebhookNotFound},
+	}
+	for _, tt := range tests {
+		if err := st.PostWebhook(tt.id, tt.token, false, WebhookResult{At: time.Now(), OK: true}); err != tt.want {
+			t.Errorf("PostWebhook(%q, %q) = %v, want %v", tt.id, tt.token, err, tt.want)
+		}
+	}
+}
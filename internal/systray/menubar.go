@@ -3,32 +3,55 @@ package systray
 import (
 	"bytes"
 	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
 	"fmt"
 	"image"
 	"image/color"
 	"image/png"
 	"log"
+	"net/http"
+	"net/url"
 	"os"
 	"os/exec"
 	"path/filepath"
 	"runtime"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/getlantern/systray"
 )
 
+// localTokenEnv holds the token the web server accepts from the menu bar on
+// /api/scheduler while sign-in is on; it matches server.LocalTokenEnv
+const localTokenEnv = "POKE443_LOCAL_TOKEN"
+
 // MenuBar represents the system tray menu bar
 type MenuBar struct {
 	port       int
+	token      string // Sent to /api/scheduler, so pausing works with sign-in on
 	onQuit     func()
 	ctx        context.Context
 	cancelFunc context.CancelFunc
 }
 
-// NewMenuBar creates a new menu bar instance
+// NewMenuBar creates a new menu bar instance. It shares the web server's
+// local token through the environment: if the server hasn't set it yet, a
+// new one is set here, for a server forked afterwards to pick up.
 func NewMenuBar(port int, onQuit func()) *MenuBar {
 	ctx, cancel := context.WithCancel(context.Background())
+	token := os.Getenv(localTokenEnv)
+	if token == "" {
+		b := make([]byte, 32)
+		_, _ = rand.Read(b)
+		token = base64.RawURLEncoding.EncodeToString(b)
+		_ = os.Setenv(localTokenEnv, token)
+	}
 	return &MenuBar{
 		port:       port,
+		token:      token,
 		onQuit:     onQuit,
 		ctx:        ctx,
 		cancelFunc: cancel,
@@ -62,6 +85,10 @@ func (m *MenuBar) onReady() {
 	mTitle.Disable()
 	systray.AddSeparator()
 	mOpen := systray.AddMenuItem("Open Web Console", "Open the web interface in browser")
+	mPause := systray.AddMenuItemCheckbox("Pause Monitoring", "Stop running checks until resumed", false)
+	if paused, err := m.schedulerPaused(); err == nil && paused {
+		mPause.Check()
+	}
 	systray.AddSeparator()
 	mQuit := systray.AddMenuItem("Quit", "Quit the application")
 
@@ -73,6 +100,17 @@ func (m *MenuBar) onReady() {
 				return
 			case <-mOpen.ClickedCh:
 				m.openWebUI()
+			case <-mPause.ClickedCh:
+				paused, err := m.setSchedulerPaused(!mPause.Checked())
+				if err != nil {
+					log.Printf("Failed to toggle monitoring pause: %v", err)
+					continue
+				}
+				if paused {
+					mPause.Check()
+				} else {
+					mPause.Uncheck()
+				}
 			case <-mQuit.ClickedCh:
 				log.Println("Quit requested from menu bar")
 				if m.onQuit != nil {
@@ -111,6 +149,56 @@ func (m *MenuBar) openWebUI() {
 	}
 }
 
+// schedulerPaused asks the web server whether monitoring is paused
+func (m *MenuBar) schedulerPaused() (bool, error) {
+	req, err := http.NewRequest(http.MethodGet, m.schedulerURL(), nil)
+	if err != nil {
+		return false, err
+	}
+	return m.doScheduler(req)
+}
+
+// setSchedulerPaused pauses or resumes monitoring via the web server's API
+// and returns the resulting state
+func (m *MenuBar) setSchedulerPaused(paused bool) (bool, error) {
+	form := url.Values{"paused": {strconv.FormatBool(paused)}}
+	req, err := http.NewRequest(http.MethodPost, m.schedulerURL(), strings.NewReader(form.Encode()))
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	return m.doScheduler(req)
+}
+
+func (m *MenuBar) schedulerURL() string {
+	return fmt.Sprintf("http://localhost:%d/api/scheduler", m.port)
+}
+
+// doScheduler sends req to the scheduler API with the local token
+func (m *MenuBar) doScheduler(req *http.Request) (bool, error) {
+	req.Header.Set("Authorization", "Bearer "+m.token)
+	client := &http.Client{Timeout: 2 * time.Second}
+	resp, err := client.Do(req)
+	if err != nil {
+		return false, err
+	}
+	defer resp.Body.Close()
+	return decodeSchedulerStatus(resp)
+}
+
+func decodeSchedulerStatus(resp *http.Response) (bool, error) {
+	if resp.StatusCode != http.StatusOK {
+		return false, fmt.Errorf("scheduler API returned status %d", resp.StatusCode)
+	}
+	var status struct {
+		Paused bool `json:"paused"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&status); err != nil {
+		return false, err
+	}
+	return status.Paused, nil
+}
+
 // getIcon returns an ECG icon for the menu bar
 func getIcon() []byte {
 	// Try to load icon from runtime file first (for easy customization)
@@ -11,17 +11,27 @@ import (
 	"time"
 
 	"github.com/andrewsjg/simple-healthchecker/copilot/internal/config"
+	"github.com/andrewsjg/simple-healthchecker/copilot/internal/notify"
 )
 
 // AlertMessage represents a notification to be sent
 type AlertMessage struct {
-	Host      string
-	Address   string
-	CheckType string
-	CheckID   string
-	Status    string // "up", "down"
-	Message   string
-	LatencyMS int64
+	Host       string
+	Address    string
+	CheckType  string
+	CheckName  string // Display name, if the check has one
+	CheckID    string
+	Status     string // "up", "down"
+	Message    string
+	Latency    time.Duration
+	Notes      string                // What the check covers
+	Runbook    string                // URL of the runbook, if any
+	Severity   string                // "info", "warning" or "critical"; empty means warning
+	Outage     *notify.OutageSummary // Set on recovery and reminders
+	Reminder   bool                  // Still-down reminder rather than a state change
+	Time       time.Time             // When the state changed; used to timestamp digest lines
+	Blocked    []string              // Dependent checks blocked by this failure
+	HostStatus string                // The host's rollup status after the change, e.g. "degraded"
 }
 
 // Client manages Telegram notifications
@@ -67,14 +77,20 @@ func (c *Client) SendAlert(msg AlertMessage) error {
 
 	// Build the notification message
 	var text string
-	if msg.Status == "down" {
+	if msg.Status == "down" && msg.Reminder {
+		text = fmt.Sprintf("⏰ *%s is still DOWN*\n\n", escapeMarkdown(msg.Host))
+	} else if msg.Status == "down" && msg.Severity == "critical" {
+		text = fmt.Sprintf("🚨 *%s is DOWN \\(critical\\)*\n\n", escapeMarkdown(msg.Host))
+	} else if msg.Status == "down" && msg.HostStatus == "degraded" {
+		text = fmt.Sprintf("🟠 *%s is DEGRADED*\n\n", escapeMarkdown(msg.Host))
+	} else if msg.Status == "down" {
 		text = fmt.Sprintf("🔴 *%s is DOWN*\n\n", escapeMarkdown(msg.Host))
 	} else {
 		text = fmt.Sprintf("✅ *%s is UP*\n\n", escapeMarkdown(msg.Host))
 	}
 
 	text += fmt.Sprintf("*Host:* %s \\(%s\\)\n", escapeMarkdown(msg.Host), escapeMarkdown(msg.Address))
-	text += fmt.Sprintf("*Check:* %s", strings.ToUpper(msg.CheckType))
+	text += fmt.Sprintf("*Check:* %s", notify.CheckTitle(msg.CheckName, msg.CheckType))
 	if msg.CheckID != "" {
 		text += fmt.Sprintf(" \\[%s\\]", escapeMarkdown(msg.CheckID))
 	}
@@ -83,8 +99,29 @@ func (c *Client) SendAlert(msg AlertMessage) error {
 	if msg.Message != "" {
 		text += fmt.Sprintf("*Details:* %s\n", escapeMarkdown(msg.Message))
 	}
-	if msg.Status == "up" && msg.LatencyMS > 0 {
-		text += fmt.Sprintf("*Latency:* %dms\n", msg.LatencyMS)
+	if msg.HostStatus != "" {
+		text += fmt.Sprintf("*Host status:* %s\n", escapeMarkdown(msg.HostStatus))
+	}
+	if msg.Status == "up" && msg.Latency > 0 {
+		text += fmt.Sprintf("*Latency:* %v\n", msg.Latency)
+	}
+	if o := msg.Outage; o != nil {
+		outage := o.Downtime.Round(time.Second).String()
+		if !o.Start.IsZero() {
+			outage += " since " + o.Start.Local().Format("Jan 2 15:04")
+		}
+		text += fmt.Sprintf("*Outage:* %s\n", escapeMarkdown(outage))
+		text += fmt.Sprintf("*Failed probes:* %d\n", o.FailedProbes)
+		text += fmt.Sprintf("*Uptime:* %s\n", escapeMarkdown(fmt.Sprintf("%.2f%%", o.UptimePct)))
+	}
+	if len(msg.Blocked) > 0 {
+		text += fmt.Sprintf("*Blocked:* %s\n", escapeMarkdown(notify.BlockedSummary(msg.Blocked)))
+	}
+	if msg.Notes != "" {
+		text += fmt.Sprintf("*Notes:* %s\n", escapeMarkdown(msg.Notes))
+	}
+	if msg.Runbook != "" {
+		text += fmt.Sprintf("*Runbook:* %s\n", escapeMarkdown(msg.Runbook))
 	}
 
 	// Send the request
@@ -100,8 +137,8 @@ func (c *Client) SendAlert(msg AlertMessage) error {
 		data.Set("disable_web_page_preview", "true")
 	}
 
-	// Silent notification if configured
-	if settings.Silent {
+	// Silent notification if configured; info-severity alerts are always silent
+	if settings.Silent || msg.Severity == "info" {
 		data.Set("disable_notification", "true")
 	}
 
@@ -126,6 +163,66 @@ func (c *Client) SendAlert(msg AlertMessage) error {
 	return nil
 }
 
+// SendDigest sends several alerts as a single silent message, one line per
+// alert
+func (c *Client) SendDigest(title string, msgs []AlertMessage) error {
+	c.mu.RLock()
+	settings := c.settings
+	c.mu.RUnlock()
+
+	if !settings.Enabled || settings.BotToken == "" || settings.ChatID == "" || len(msgs) == 0 {
+		return nil
+	}
+
+	text := fmt.Sprintf("*%s*\n\n", escapeMarkdown(title))
+	for _, msg := range msgs {
+		icon := "🔴"
+		if msg.Status == "up" {
+			icon = "✅"
+		}
+		line := fmt.Sprintf("%s %s %s", msg.Time.Local().Format("15:04"), msg.Host, notify.CheckTitle(msg.CheckName, msg.CheckType))
+		if msg.CheckID != "" {
+			line += fmt.Sprintf(" [%s]", msg.CheckID)
+		}
+		if msg.Message != "" {
+			line += " - " + msg.Message
+		}
+		if n := len(msg.Blocked); n > 0 {
+			line += fmt.Sprintf(" (%d dependent checks blocked)", n)
+		}
+		text += icon + " " + escapeMarkdown(line) + "\n"
+	}
+
+	apiURL := fmt.Sprintf("https://api.telegram.org/bot%s/sendMessage", settings.BotToken)
+	data := url.Values{
+		"chat_id":                  {settings.ChatID},
+		"text":                     {text},
+		"parse_mode":               {"MarkdownV2"},
+		"disable_notification":     {"true"},
+		"disable_web_page_preview": {"true"},
+	}
+
+	resp, err := c.http.PostForm(apiURL, data)
+	if err != nil {
+		return fmt.Errorf("telegram request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		var result struct {
+			OK          bool   `json:"ok"`
+			Description string `json:"description"`
+		}
+		if err := json.NewDecoder(resp.Body).Decode(&result); err == nil && result.Description != "" {
+			return fmt.Errorf("telegram error: %s", result.Description)
+		}
+		return fmt.Errorf("telegram returned status %d", resp.StatusCode)
+	}
+
+	log.Printf("Telegram digest sent: %d alerts", len(msgs))
+	return nil
+}
+
 // TestNotification sends a test notification
 func (c *Client) TestNotification() error {
 	c.mu.RLock()
@@ -165,6 +262,50 @@ func (c *Client) TestNotification() error {
 	return nil
 }
 
+// SendNotice sends a plain message that isn't about a single check
+func (c *Client) SendNotice(title, message string) error {
+	c.mu.RLock()
+	settings := c.settings
+	c.mu.RUnlock()
+
+	if !settings.Enabled || settings.BotToken == "" || settings.ChatID == "" {
+		return nil
+	}
+
+	text := fmt.Sprintf("*%s*\n\n%s", escapeMarkdown(title), escapeMarkdown(message))
+
+	apiURL := fmt.Sprintf("https://api.telegram.org/bot%s/sendMessage", settings.BotToken)
+	data := url.Values{
+		"chat_id":                  {settings.ChatID},
+		"text":                     {text},
+		"parse_mode":               {"MarkdownV2"},
+		"disable_web_page_preview": {"true"},
+	}
+	if settings.Silent {
+		data.Set("disable_notification", "true")
+	}
+
+	resp, err := c.http.PostForm(apiURL, data)
+	if err != nil {
+		return fmt.Errorf("telegram request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		var result struct {
+			OK          bool   `json:"ok"`
+			Description string `json:"description"`
+		}
+		if err := json.NewDecoder(resp.Body).Decode(&result); err == nil && result.Description != "" {
+			return fmt.Errorf("telegram error: %s", result.Description)
+		}
+		return fmt.Errorf("telegram returned status %d", resp.StatusCode)
+	}
+
+	log.Printf("Telegram notice sent: %s", title)
+	return nil
+}
+
 // escapeMarkdown escapes special characters for Telegram MarkdownV2
 func escapeMarkdown(s string) string {
 	// Characters that need to be escaped in MarkdownV2
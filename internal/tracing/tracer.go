@@ -0,0 +1,322 @@
+// Package tracing records OpenTelemetry spans and exports them over OTLP/HTTP
+// using the protocol's JSON encoding, which every OTLP receiver accepts.
+package tracing
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/andrewsjg/simple-healthchecker/copilot/internal/config"
+)
+
+const (
+	defaultServiceName = "poke443"
+	flushInterval      = 5 * time.Second
+	maxQueued          = 2048 // Spans held for export; older ones are dropped beyond this
+)
+
+// Span kinds, as numbered by OTLP
+const (
+	KindInternal = 1
+	KindClient   = 3
+)
+
+// Attr is a span attribute
+type Attr struct {
+	Key   string
+	Value any // string, bool, int, int64, float64 or time.Duration (recorded in ms)
+}
+
+// String, Int, Bool and Duration build attributes
+func String(key, value string) Attr             { return Attr{key, value} }
+func Int(key string, value int) Attr            { return Attr{key, value} }
+func Bool(key string, value bool) Attr          { return Attr{key, value} }
+func Duration(key string, d time.Duration) Attr { return Attr{key, d} }
+
+// Tracer creates spans and exports them in batches. A nil *Tracer hands out
+// nil spans and every Span method is a no-op on nil, so callers never need
+// to check whether tracing is on.
+type Tracer struct {
+	endpoint string // Full traces URL, e.g. http://collector:4318/v1/traces
+	headers  map[string]string
+	service  string
+	http     *http.Client
+
+	mu    sync.Mutex
+	queue []*Span
+}
+
+// Span is one timed operation
+type Span struct {
+	t        *Tracer
+	traceID  [16]byte
+	spanID   [8]byte
+	parentID [8]byte
+	name     string
+	kind     int
+	start    time.Time
+	end      time.Time
+	attrs    []Attr
+	errMsg   string
+	failed   bool
+}
+
+// New returns a tracer for settings, or nil if tracing is off. The endpoint
+// falls back to the standard OTEL_EXPORTER_OTLP_TRACES_ENDPOINT and
+// OTEL_EXPORTER_OTLP_ENDPOINT variables, as the OpenTelemetry SDKs do.
+func New(settings config.TracingSettings) *Tracer {
+	if !settings.Enabled {
+		return nil
+	}
+	endpoint := TracesURL(settings.Endpoint)
+	if endpoint == "" {
+		if env := os.Getenv("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT"); env != "" {
+			endpoint = env
+		} else {
+			endpoint = TracesURL(os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT"))
+		}
+	}
+	if endpoint == "" {
+		endpoint = "http://localhost:4318/v1/traces"
+	}
+	service := settings.ServiceName
+	if service == "" {
+		service = defaultServiceName
+	}
+	t := &Tracer{
+		endpoint: endpoint,
+		headers:  settings.Headers,
+		service:  service,
+		http:     &http.Client{Timeout: 10 * time.Second},
+	}
+	go t.run()
+	return t
+}
+
+// TracesURL turns a collector base URL such as http://collector:4318 into
+// its traces endpoint by appending /v1/traces, unless it already ends there
+func TracesURL(base string) string {
+	base = strings.TrimSuffix(strings.TrimSpace(base), "/")
+	if base == "" || strings.HasSuffix(base, "/v1/traces") {
+		return base
+	}
+	return base + "/v1/traces"
+}
+
+// Endpoint returns where spans are sent
+func (t *Tracer) Endpoint() string {
+	if t == nil {
+		return ""
+	}
+	return t.endpoint
+}
+
+// Start begins a span. It joins parent's trace, or starts a new trace if
+// parent is nil.
+func (t *Tracer) Start(parent *Span, name string, kind int, attrs ...Attr) *Span {
+	if t == nil {
+		return nil
+	}
+	s := &Span{t: t, name: name, kind: kind, start: time.Now(), attrs: attrs}
+	if parent != nil {
+		s.traceID = parent.traceID
+		s.parentID = parent.spanID
+	} else {
+		_, _ = rand.Read(s.traceID[:])
+	}
+	_, _ = rand.Read(s.spanID[:])
+	return s
+}
+
+// SetAttrs adds attributes to s
+func (s *Span) SetAttrs(attrs ...Attr) {
+	if s == nil {
+		return
+	}
+	s.attrs = append(s.attrs, attrs...)
+}
+
+// SetError marks s as failed with err's message; a nil err does nothing
+func (s *Span) SetError(err error) {
+	if s == nil || err == nil {
+		return
+	}
+	s.failed = true
+	s.errMsg = err.Error()
+}
+
+// SetFailed marks s as failed with msg, for outcomes that aren't Go errors
+// such as a check reporting down
+func (s *Span) SetFailed(msg string) {
+	if s == nil {
+		return
+	}
+	s.failed = true
+	s.errMsg = msg
+}
+
+// End finishes s and queues it for export
+func (s *Span) End() {
+	if s == nil {
+		return
+	}
+	s.end = time.Now()
+	t := s.t
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if len(t.queue) >= maxQueued {
+		t.queue = t.queue[1:]
+	}
+	t.queue = append(t.queue, s)
+}
+
+// run exports queued spans every flushInterval
+func (t *Tracer) run() {
+	tick := time.NewTicker(flushInterval)
+	defer tick.Stop()
+	for range tick.C {
+		if err := t.Flush(); err != nil {
+			log.Printf("Tracing export error: %v", err)
+		}
+	}
+}
+
+// Flush exports every queued span now
+func (t *Tracer) Flush() error {
+	if t == nil {
+		return nil
+	}
+	t.mu.Lock()
+	spans := t.queue
+	t.queue = nil
+	t.mu.Unlock()
+	if len(spans) == 0 {
+		return nil
+	}
+
+	body, err := json.Marshal(t.request(spans))
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest(http.MethodPost, t.endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range t.headers {
+		req.Header.Set(k, v)
+	}
+	resp, err := t.http.Do(req)
+	if err != nil {
+		return fmt.Errorf("%d spans dropped: %w", len(spans), err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("%d spans dropped: %s: %s", len(spans), resp.Status, strings.TrimSpace(string(msg)))
+	}
+	return nil
+}
+
+// OTLP/JSON request body. IDs are hex and 64-bit integers are strings, as
+// the protocol's JSON mapping requires.
+type (
+	otlpRequest struct {
+		ResourceSpans []otlpResourceSpans `json:"resourceSpans"`
+	}
+	otlpResourceSpans struct {
+		Resource   otlpResource     `json:"resource"`
+		ScopeSpans []otlpScopeSpans `json:"scopeSpans"`
+	}
+	otlpResource struct {
+		Attributes []otlpKeyValue `json:"attributes"`
+	}
+	otlpScopeSpans struct {
+		Scope otlpScope  `json:"scope"`
+		Spans []otlpSpan `json:"spans"`
+	}
+	otlpScope struct {
+		Name string `json:"name"`
+	}
+	otlpSpan struct {
+		TraceID           string         `json:"traceId"`
+		SpanID            string         `json:"spanId"`
+		ParentSpanID      string         `json:"parentSpanId,omitempty"`
+		Name              string         `json:"name"`
+		Kind              int            `json:"kind"`
+		StartTimeUnixNano string         `json:"startTimeUnixNano"`
+		EndTimeUnixNano   string         `json:"endTimeUnixNano"`
+		Attributes        []otlpKeyValue `json:"attributes,omitempty"`
+		Status            otlpStatus     `json:"status"`
+	}
+	otlpStatus struct {
+		Code    int    `json:"code"` // 0 unset, 1 ok, 2 error
+		Message string `json:"message,omitempty"`
+	}
+	otlpKeyValue struct {
+		Key   string         `json:"key"`
+		Value map[string]any `json:"value"`
+	}
+)
+
+func (t *Tracer) request(spans []*Span) otlpRequest {
+	out := make([]otlpSpan, 0, len(spans))
+	for _, s := range spans {
+		o := otlpSpan{
+			TraceID:           hex.EncodeToString(s.traceID[:]),
+			SpanID:            hex.EncodeToString(s.spanID[:]),
+			Name:              s.name,
+			Kind:              s.kind,
+			StartTimeUnixNano: strconv.FormatInt(s.start.UnixNano(), 10),
+			EndTimeUnixNano:   strconv.FormatInt(s.end.UnixNano(), 10),
+			Attributes:        keyValues(s.attrs),
+		}
+		if s.parentID != [8]byte{} {
+			o.ParentSpanID = hex.EncodeToString(s.parentID[:])
+		}
+		if s.failed {
+			o.Status = otlpStatus{Code: 2, Message: s.errMsg}
+		}
+		out = append(out, o)
+	}
+	return otlpRequest{ResourceSpans: []otlpResourceSpans{{
+		Resource:   otlpResource{Attributes: keyValues([]Attr{String("service.name", t.service)})},
+		ScopeSpans: []otlpScopeSpans{{Scope: otlpScope{Name: defaultServiceName}, Spans: out}},
+	}}}
+}
+
+func keyValues(attrs []Attr) []otlpKeyValue {
+	out := make([]otlpKeyValue, 0, len(attrs))
+	for _, a := range attrs {
+		var v map[string]any
+		switch val := a.Value.(type) {
+		case string:
+			v = map[string]any{"stringValue": val}
+		case bool:
+			v = map[string]any{"boolValue": val}
+		case int:
+			v = map[string]any{"intValue": strconv.Itoa(val)}
+		case int64:
+			v = map[string]any{"intValue": strconv.FormatInt(val, 10)}
+		case float64:
+			v = map[string]any{"doubleValue": val}
+		case time.Duration:
+			v = map[string]any{"doubleValue": float64(val.Microseconds()) / 1000}
+		default:
+			v = map[string]any{"stringValue": fmt.Sprint(val)}
+		}
+		out = append(out, otlpKeyValue{Key: a.Key, Value: v})
+	}
+	return out
+}
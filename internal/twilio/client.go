@@ -0,0 +1,287 @@
+package twilio
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	"github.com/andrewsjg/simple-healthchecker/copilot/internal/config"
+	"github.com/andrewsjg/simple-healthchecker/copilot/internal/notify"
+)
+
+const (
+	apiBase = "https://api.twilio.com/2010-04-01"
+	maxBody = 1600 // Longest SMS body Twilio accepts
+)
+
+// AlertMessage represents a notification to be sent
+type AlertMessage struct {
+	Host       string
+	Address    string
+	CheckType  string
+	CheckName  string // Display name, if the check has one
+	CheckID    string
+	Status     string // "up", "down"
+	Message    string
+	Severity   string                // "info", "warning" or "critical"; empty means warning
+	Outage     *notify.OutageSummary // Set on recovery and reminders
+	Reminder   bool                  // Still-down reminder rather than a state change
+	Time       time.Time             // When the state changed; used to timestamp digest lines
+	Blocked    []string              // Dependent checks blocked by this failure
+	HostStatus string                // The host's rollup status after the change, e.g. "degraded"
+}
+
+// Client sends SMS and places voice calls through the Twilio REST API
+type Client struct {
+	mu       sync.RWMutex
+	settings config.TwilioSettings
+	http     *http.Client
+}
+
+// NewClient creates a new Twilio client
+func NewClient(settings config.TwilioSettings) *Client {
+	return &Client{
+		settings: settings,
+		http: &http.Client{
+			Timeout: 15 * time.Second,
+		},
+	}
+}
+
+// UpdateSettings updates the Twilio settings
+func (c *Client) UpdateSettings(settings config.TwilioSettings) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.settings = settings
+}
+
+// IsEnabled returns whether Twilio alerts are enabled and configured
+func (c *Client) IsEnabled() bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return configured(c.settings)
+}
+
+func configured(s config.TwilioSettings) bool {
+	return s.Enabled && s.AccountSID != "" && s.AuthToken != "" && s.From != "" && len(s.To) > 0
+}
+
+// SendAlert texts one check's state change to every number. Messages are
+// kept short so each fits in as few SMS segments as possible.
+func (c *Client) SendAlert(msg AlertMessage) error {
+	if !c.IsEnabled() {
+		return nil
+	}
+	if err := c.sendSMS(alertText(msg)); err != nil {
+		return err
+	}
+	log.Printf("Twilio SMS sent: %s - %s", msg.Host, msg.Status)
+	return nil
+}
+
+// SendDigest texts several alerts as one message, one line per alert
+func (c *Client) SendDigest(title string, msgs []AlertMessage) error {
+	if !c.IsEnabled() || len(msgs) == 0 {
+		return nil
+	}
+	text := title
+	for _, msg := range msgs {
+		state := "DOWN"
+		if msg.Status == "up" {
+			state = "UP"
+		}
+		text += fmt.Sprintf("\n%s %s %s", msg.Time.Local().Format("15:04"), state, checkName(msg))
+	}
+	if err := c.sendSMS(text); err != nil {
+		return err
+	}
+	log.Printf("Twilio digest sent: %d alerts", len(msgs))
+	return nil
+}
+
+// SendNotice texts a message that isn't about a single check
+func (c *Client) SendNotice(title, message string) error {
+	if !c.IsEnabled() {
+		return nil
+	}
+	if err := c.sendSMS(title + "\n" + message); err != nil {
+		return err
+	}
+	log.Printf("Twilio notice sent: %s", title)
+	return nil
+}
+
+// Call phones every number and reads out msg, for critical outages that
+// have outlasted a text
+func (c *Client) Call(msg AlertMessage) error {
+	if !c.IsEnabled() {
+		return nil
+	}
+	check := msg.CheckType + " check"
+	if msg.CheckName != "" {
+		check = msg.CheckName
+	}
+	speech := fmt.Sprintf("POKE 443 alert. %s, %s", msg.Host, check)
+	if msg.CheckID != "" {
+		speech += " " + msg.CheckID
+	}
+	speech += ", is down"
+	if o := msg.Outage; o != nil && o.Downtime > 0 {
+		speech += " and has been for " + spokenDuration(o.Downtime)
+	}
+	speech += "."
+	if msg.Message != "" {
+		speech += " " + msg.Message + "."
+	}
+	twiml := fmt.Sprintf(`<Response><Say loop="2">%s</Say></Response>`, html.EscapeString(speech))
+
+	err := c.each(func(settings config.TwilioSettings, to string) error {
+		return c.post(settings, "Calls", url.Values{"To": {to}, "From": {settings.From}, "Twiml": {twiml}})
+	})
+	if err != nil {
+		return err
+	}
+	log.Printf("Twilio call placed: %s", msg.Host)
+	return nil
+}
+
+// TestNotification texts a test message to every number
+func (c *Client) TestNotification() error {
+	c.mu.RLock()
+	settings := c.settings
+	c.mu.RUnlock()
+	if settings.AccountSID == "" || settings.AuthToken == "" || settings.From == "" || len(settings.To) == 0 {
+		return fmt.Errorf("account SID, auth token, from and to numbers are required")
+	}
+	settings.Enabled = true
+	test := &Client{settings: settings, http: c.http}
+	return test.sendSMS("POKE443 test message. If you got this, Twilio SMS alerts are set up correctly.")
+}
+
+// alertText builds the SMS body for msg, e.g.
+// "DOWN (critical): web HTTP [site] - status 500"
+func alertText(msg AlertMessage) string {
+	var text string
+	switch {
+	case msg.Status == "up":
+		text = "UP: " + checkName(msg)
+		if o := msg.Outage; o != nil {
+			text += " after " + o.Downtime.Round(time.Second).String()
+		}
+		return text
+	case msg.Reminder:
+		text = "STILL DOWN: " + checkName(msg)
+		if o := msg.Outage; o != nil {
+			text += " for " + o.Downtime.Round(time.Second).String()
+		}
+	case msg.Severity == "critical":
+		text = "DOWN (critical): " + checkName(msg)
+	case msg.HostStatus == "degraded":
+		text = "DEGRADED: " + checkName(msg)
+	default:
+		text = "DOWN: " + checkName(msg)
+	}
+	if msg.Message != "" {
+		text += " - " + msg.Message
+	}
+	if n := len(msg.Blocked); n > 0 {
+		text += fmt.Sprintf(" (%d dependent checks blocked)", n)
+	}
+	return text
+}
+
+// checkName identifies a check in a few words, e.g. "web HTTP [site]"
+func checkName(msg AlertMessage) string {
+	name := msg.Host + " " + notify.CheckTitle(msg.CheckName, msg.CheckType)
+	if msg.CheckID != "" {
+		name += " [" + msg.CheckID + "]"
+	}
+	return name
+}
+
+// spokenDuration rounds d to whole minutes for reading aloud
+func spokenDuration(d time.Duration) string {
+	m := int(d.Round(time.Minute) / time.Minute)
+	switch {
+	case m < 2:
+		return "1 minute"
+	case m < 120:
+		return fmt.Sprintf("%d minutes", m)
+	default:
+		return fmt.Sprintf("%d hours and %d minutes", m/60, m%60)
+	}
+}
+
+// sendSMS texts body to every number, trimmed to Twilio's limit. Emoji
+// (as in the shared digest titles) are dropped: one would switch the whole
+// message to UCS-2 and cut each segment from 160 characters to 70.
+func (c *Client) sendSMS(body string) error {
+	body = strings.TrimSpace(strings.Map(func(r rune) rune {
+		if unicode.Is(unicode.So, r) || r == '\u200d' || r == '\ufe0f' {
+			return -1
+		}
+		return r
+	}, body))
+	if len(body) > maxBody {
+		body = strings.ToValidUTF8(body[:maxBody-3], "") + "..."
+	}
+	return c.each(func(settings config.TwilioSettings, to string) error {
+		return c.post(settings, "Messages", url.Values{"To": {to}, "From": {settings.From}, "Body": {body}})
+	})
+}
+
+// each runs send for every configured number, carrying on past failures so
+// one bad number doesn't stop the rest being alerted
+func (c *Client) each(send func(settings config.TwilioSettings, to string) error) error {
+	c.mu.RLock()
+	settings := c.settings
+	c.mu.RUnlock()
+
+	if !configured(settings) {
+		return nil
+	}
+	var errs []error
+	for _, to := range settings.To {
+		if err := send(settings, to); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", to, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// post creates a Messages or Calls resource
+func (c *Client) post(settings config.TwilioSettings, resource string, data url.Values) error {
+	apiURL := fmt.Sprintf("%s/Accounts/%s/%s.json", apiBase, url.PathEscape(settings.AccountSID), resource)
+	req, err := http.NewRequest(http.MethodPost, apiURL, strings.NewReader(data.Encode()))
+	if err != nil {
+		return fmt.Errorf("twilio request failed: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.SetBasicAuth(settings.AccountSID, settings.AuthToken)
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return fmt.Errorf("twilio request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode/100 != 2 {
+		var result struct {
+			Code    int    `json:"code"`
+			Message string `json:"message"`
+		}
+		if err := json.NewDecoder(resp.Body).Decode(&result); err == nil && result.Message != "" {
+			return fmt.Errorf("twilio error %d: %s", result.Code, result.Message)
+		}
+		return fmt.Errorf("twilio returned status %d", resp.StatusCode)
+	}
+	return nil
+}
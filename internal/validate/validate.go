@@ -0,0 +1,700 @@
+package validate
+
+import (
+	"fmt"
+	"math"
+	"net"
+	"net/netip"
+	"net/url"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	maxHostnameLen = 253
+	maxLabelLen    = 63
+	maxIDLen       = 64
+	maxNameLen     = 100 // Check display names
+	maxRedirects   = 50
+	maxPorts       = 1024 // Ports one ports check may scan
+
+	minSpeedtestInterval = 5 * time.Minute
+	maxWarnDays          = 365
+)
+
+// FieldError describes a problem with a single form field
+type FieldError struct {
+	Field   string // Human-readable field label, e.g. "Address" or "Check 2 port"
+	Message string
+}
+
+// Errors collects field problems so a form can report them all at once
+type Errors []FieldError
+
+// Add records a problem for the given field
+func (e *Errors) Add(field, format string, args ...any) {
+	*e = append(*e, FieldError{Field: field, Message: fmt.Sprintf(format, args...)})
+}
+
+// Check records err against field if it is non-nil
+func (e *Errors) Check(field string, err error) {
+	if err != nil {
+		e.Add(field, "%s", err.Error())
+	}
+}
+
+// Any returns true if at least one problem was recorded
+func (e Errors) Any() bool {
+	return len(e) > 0
+}
+
+// Error implements error so validation results can be returned from state methods
+func (e Errors) Error() string {
+	parts := make([]string, 0, len(e))
+	for _, fe := range e {
+		parts = append(parts, fe.Field+": "+fe.Message)
+	}
+	return strings.Join(parts, "; ")
+}
+
+// Address checks that s is an IP address or a syntactically valid hostname
+func Address(s string) error {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return fmt.Errorf("is required")
+	}
+	if _, err := netip.ParseAddr(s); err == nil {
+		return nil
+	}
+	if !isHostname(s) {
+		return fmt.Errorf("%q is not a valid hostname or IP address", s)
+	}
+	return nil
+}
+
+// isHostname reports whether s follows RFC 1123 hostname syntax
+func isHostname(s string) bool {
+	s = strings.TrimSuffix(s, ".")
+	if s == "" || len(s) > maxHostnameLen {
+		return false
+	}
+	for _, label := range strings.Split(s, ".") {
+		if label == "" || len(label) > maxLabelLen {
+			return false
+		}
+		if label[0] == '-' || label[len(label)-1] == '-' {
+			return false
+		}
+		for _, r := range label {
+			if !isAlnum(r) && r != '-' && r != '_' {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// URL checks that s is an absolute http or https URL with a host
+func URL(s string) error {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return fmt.Errorf("is required")
+	}
+	u, err := url.Parse(s)
+	if err != nil {
+		return fmt.Errorf("%q is not a valid URL", s)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return fmt.Errorf("must start with http:// or https://")
+	}
+	if u.Hostname() == "" {
+		return fmt.Errorf("%q has no host", s)
+	}
+	return nil
+}
+
+// WebSocketURL checks that s is an absolute ws or wss URL with a host
+func WebSocketURL(s string) error {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return fmt.Errorf("is required")
+	}
+	u, err := url.Parse(s)
+	if err != nil {
+		return fmt.Errorf("%q is not a valid URL", s)
+	}
+	if u.Scheme != "ws" && u.Scheme != "wss" {
+		return fmt.Errorf("must start with ws:// or wss://")
+	}
+	if u.Hostname() == "" {
+		return fmt.Errorf("%q has no host", s)
+	}
+	return nil
+}
+
+// PrinterURL checks a printer's IPP address: ipp:// or ipps://, or the
+// http:// and https:// forms some printers list
+func PrinterURL(s string) error {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return fmt.Errorf("is required")
+	}
+	u, err := url.Parse(s)
+	if err != nil {
+		return fmt.Errorf("%q is not a valid URL", s)
+	}
+	switch u.Scheme {
+	case "ipp", "ipps", "http", "https":
+	default:
+		return fmt.Errorf("must start with ipp://, ipps://, http:// or https://")
+	}
+	if u.Hostname() == "" {
+		return fmt.Errorf("%q has no host", s)
+	}
+	return nil
+}
+
+// ShareName checks an SMB share name, which is optional
+func ShareName(s string) error {
+	if strings.ContainsAny(s, `\/`) {
+		return fmt.Errorf("%q should be just the share's name, e.g. backups", s)
+	}
+	if len(s) > 80 {
+		return fmt.Errorf("is too long")
+	}
+	return nil
+}
+
+// KafkaTopic checks a Kafka topic name, which is optional
+func KafkaTopic(s string) error {
+	if s == "" {
+		return nil
+	}
+	if len(s) > 249 || s == "." || s == ".." {
+		return fmt.Errorf("%q is not a valid topic name", s)
+	}
+	for _, r := range s {
+		if !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '.' || r == '_' || r == '-') {
+			return fmt.Errorf("%q may only contain letters, digits, '.', '_' and '-'", s)
+		}
+	}
+	return nil
+}
+
+// Deployment checks a Kubernetes deployment given as namespace/name or just
+// name, which is optional
+func Deployment(s string) error {
+	if s == "" {
+		return nil
+	}
+	ns, name, ok := strings.Cut(s, "/")
+	if !ok {
+		ns, name = "default", s
+	}
+	ok = isDNSLabel(ns) && len(name) <= 253
+	for _, label := range strings.Split(name, ".") {
+		ok = ok && isDNSLabel(label)
+	}
+	if !ok {
+		return fmt.Errorf("%q should be namespace/name or a name, in lowercase letters, digits, '-' and '.'", s)
+	}
+	return nil
+}
+
+// ProxmoxToken checks a Proxmox VE API token, written user@realm!tokenid=secret
+func ProxmoxToken(s string) error {
+	if s == "" {
+		return fmt.Errorf("is required")
+	}
+	id, secret, ok := strings.Cut(s, "=")
+	user, name, ok2 := strings.Cut(id, "!")
+	if !ok || !ok2 || secret == "" || name == "" || !strings.Contains(user, "@") {
+		return fmt.Errorf("should be written user@realm!tokenid=secret, e.g. monitor@pve!poke443=0a1b...")
+	}
+	return nil
+}
+
+// MaxUsage checks a usage limit in percent, where empty means the default
+func MaxUsage(s string) (int, error) {
+	return percent(s)
+}
+
+// MinCharge checks a battery charge limit in percent, where empty means none
+func MinCharge(s string) (int, error) {
+	return percent(s)
+}
+
+// UPSDaemon checks the daemon a ups check asks, where empty means nut
+func UPSDaemon(s string) error {
+	switch s {
+	case "", "nut", "apcupsd":
+		return nil
+	}
+	return fmt.Errorf("%q must be nut or apcupsd", s)
+}
+
+// UPSName checks NUT's name for a UPS, which is optional
+func UPSName(s string) error {
+	if len(s) > 64 {
+		return fmt.Errorf("is too long")
+	}
+	for _, r := range s {
+		if !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '.' || r == '_' || r == '-') {
+			return fmt.Errorf("%q may only contain letters, digits, '.', '_' and '-'", s)
+		}
+	}
+	return nil
+}
+
+// Speed checks a network speed in bits per second, e.g. 50Mbps, 50 Mbit/s
+// or 1.5Gbps, where empty means no limit
+func Speed(s string) (int64, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return 0, nil
+	}
+	bad := fmt.Errorf("%q should be a speed in bits per second, e.g. 50Mbps", s)
+	i := strings.IndexFunc(s, func(r rune) bool { return !(r >= '0' && r <= '9' || r == '.') })
+	if i <= 0 {
+		return 0, bad
+	}
+	n, err := strconv.ParseFloat(s[:i], 64)
+	unit := strings.TrimSpace(s[i:])
+	mult := 1.0
+	switch unit[0] {
+	case 'k', 'K':
+		mult = 1e3
+	case 'm', 'M':
+		mult = 1e6
+	case 'g', 'G':
+		mult = 1e9
+	}
+	if mult > 1 {
+		unit = unit[1:]
+	}
+	switch unit {
+	case "bps", "bit/s", "b/s":
+	case "Bps", "B/s", "byte/s":
+		return 0, fmt.Errorf("%q is in bytes; give it in bits, e.g. %.0fMbps", s, n*8*mult/1e6)
+	default:
+		return 0, bad
+	}
+	if err != nil || n <= 0 || n*mult < 1 || n*mult > 1e12 {
+		return 0, bad
+	}
+	return int64(math.Round(n * mult)), nil
+}
+
+// SpeedtestInterval checks how often a speedtest check runs, where empty
+// means the default. Each run uses the link flat out, so it can't be often.
+func SpeedtestInterval(s string) (time.Duration, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return 0, nil
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		return 0, fmt.Errorf("%q is not a duration, e.g. 1h", s)
+	}
+	if d < minSpeedtestInterval {
+		return 0, fmt.Errorf("must be at least 5m, as each run uses all the bandwidth it can")
+	}
+	return d, nil
+}
+
+// Domain checks the optional domain name of a domain check, which must have
+// at least two labels, e.g. example.com
+func Domain(s string) error {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return nil
+	}
+	if _, err := netip.ParseAddr(s); err == nil {
+		return fmt.Errorf("%q is an IP address; give the domain it belongs to", s)
+	}
+	if !isHostname(s) || !strings.Contains(strings.TrimSuffix(s, "."), ".") {
+		return fmt.Errorf("%q is not a domain name such as example.com", s)
+	}
+	return nil
+}
+
+// Blocklists parses a dnsbl check's list of DNSBL zones such as
+// "zen.spamhaus.org, bl.spamcop.net", lowercased and without duplicates.
+// Empty means the default lists.
+func Blocklists(s string) ([]string, error) {
+	var lists []string
+	for _, f := range strings.FieldsFunc(s, func(r rune) bool { return r == ',' || r == ' ' || r == '\n' }) {
+		f = strings.ToLower(strings.TrimSuffix(f, "."))
+		if _, err := netip.ParseAddr(f); err == nil || !isHostname(f) || !strings.Contains(f, ".") {
+			return nil, fmt.Errorf("%q is not a blocklist zone such as zen.spamhaus.org", f)
+		}
+		if !slices.Contains(lists, f) {
+			lists = append(lists, f)
+		}
+	}
+	return lists, nil
+}
+
+// WarnDays checks how many days before expiry a domain check fails, where
+// empty means the default
+func WarnDays(s string) (int, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return 0, nil
+	}
+	n, err := strconv.Atoi(s)
+	if err != nil || n < 1 || n > maxWarnDays {
+		return 0, fmt.Errorf("%q must be a number of days between 1 and %d", s, maxWarnDays)
+	}
+	return n, nil
+}
+
+// percent checks a whole percentage from 1 to 100, where empty means 0
+func percent(s string) (int, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return 0, nil
+	}
+	n, err := strconv.Atoi(s)
+	if err != nil || n < 1 || n > 100 {
+		return 0, fmt.Errorf("%q must be a percentage between 1 and 100", s)
+	}
+	return n, nil
+}
+
+// isDNSLabel reports whether s is an RFC 1123 label, as namespaces and
+// each part of other Kubernetes names must be
+func isDNSLabel(s string) bool {
+	if s == "" || len(s) > 63 || s[0] == '-' || s[len(s)-1] == '-' {
+		return false
+	}
+	for _, r := range s {
+		if !(r >= 'a' && r <= 'z' || r >= '0' && r <= '9' || r == '-') {
+			return false
+		}
+	}
+	return true
+}
+
+// OptionalURL is like URL but accepts an empty string
+func OptionalURL(s string) error {
+	if strings.TrimSpace(s) == "" {
+		return nil
+	}
+	return URL(s)
+}
+
+var (
+	hcUUID    = regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`)
+	hcPingKey = regexp.MustCompile(`^[A-Za-z0-9_-]{22}$`)
+	hcSlug    = regexp.MustCompile(`^[a-z0-9_-]+$`)
+
+	// hcSignal matches the endpoint suffixes a pasted ping URL may carry
+	hcSignal = regexp.MustCompile(`/(fail|start|log|[0-9]{1,3})$`)
+)
+
+// HealthchecksURL checks a Healthchecks.io ping URL (if set) and returns it
+// in the form signals are appended to: without a trailing slash or a
+// /fail, /start, /log or exit status suffix. On hc-ping.com the path must
+// be a check's UUID, or a project ping key and a check slug.
+func HealthchecksURL(s string) (string, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return "", nil
+	}
+	if err := URL(s); err != nil {
+		return "", err
+	}
+	u, _ := url.Parse(s)
+	u.Fragment = ""
+	u.Path = hcSignal.ReplaceAllString(strings.TrimRight(u.Path, "/"), "")
+	u.RawPath = ""
+	if strings.EqualFold(u.Hostname(), "hc-ping.com") {
+		parts := strings.Split(strings.TrimPrefix(u.Path, "/"), "/")
+		switch {
+		case len(parts) == 1 && hcUUID.MatchString(parts[0]):
+		case len(parts) == 2 && hcPingKey.MatchString(parts[0]) && hcSlug.MatchString(parts[1]):
+		default:
+			return "", fmt.Errorf("should be https://hc-ping.com/<uuid> (e.g. https://hc-ping.com/0f3c4d2e-8a1b-4c5d-9e6f-7a8b9c0d1e2f) or https://hc-ping.com/<ping-key>/<slug>")
+		}
+	}
+	if u.Path == "" {
+		return "", fmt.Errorf("%q has no check UUID in its path", s)
+	}
+	return u.String(), nil
+}
+
+// ProxyURL checks that s (if set) is an http, https or socks5 proxy URL with a host
+func ProxyURL(s string) error {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return nil
+	}
+	u, err := url.Parse(s)
+	if err != nil {
+		return fmt.Errorf("%q is not a valid URL", s)
+	}
+	switch u.Scheme {
+	case "http", "https", "socks5", "socks5h":
+	default:
+		return fmt.Errorf("must start with http://, https:// or socks5://")
+	}
+	if u.Hostname() == "" {
+		return fmt.Errorf("%q has no host", s)
+	}
+	return nil
+}
+
+// MaxRedirects parses s as a redirect hop limit, returning 0 (the default) when empty
+func MaxRedirects(s string) (int, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return 0, nil
+	}
+	n, err := strconv.Atoi(s)
+	if err != nil {
+		return 0, fmt.Errorf("%q is not a number", s)
+	}
+	if n < 0 || n > maxRedirects {
+		return 0, fmt.Errorf("must be between 0 and %d", maxRedirects)
+	}
+	return n, nil
+}
+
+// Port parses s as a TCP port in the range 1-65535
+func Port(s string) (int, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return 0, fmt.Errorf("is required")
+	}
+	p, err := strconv.Atoi(s)
+	if err != nil {
+		return 0, fmt.Errorf("%q is not a number", s)
+	}
+	if p < 1 || p > 65535 {
+		return 0, fmt.Errorf("must be between 1 and 65535")
+	}
+	return p, nil
+}
+
+// Ports parses a list of ports and ranges such as "22, 80, 8000-8010" into
+// sorted, distinct port numbers. Empty means none.
+func Ports(s string) ([]int, error) {
+	var ports []int
+	for _, f := range strings.FieldsFunc(s, func(r rune) bool { return r == ',' || r == ' ' }) {
+		lo, hi, isRange := strings.Cut(f, "-")
+		first, err := Port(lo)
+		if err != nil {
+			return nil, fmt.Errorf("%q: port %v", f, err)
+		}
+		last := first
+		if isRange {
+			if last, err = Port(hi); err != nil {
+				return nil, fmt.Errorf("%q: port %v", f, err)
+			}
+			if last < first {
+				return nil, fmt.Errorf("%q: range must run from low to high", f)
+			}
+		}
+		if len(ports)+last-first >= maxPorts {
+			return nil, fmt.Errorf("at most %d ports can be scanned", maxPorts)
+		}
+		for p := first; p <= last; p++ {
+			ports = append(ports, p)
+		}
+	}
+	slices.Sort(ports)
+	return slices.Compact(ports), nil
+}
+
+// IPVersion parses s as an address family, 4 or 6, defaulting to 0 (either) when empty
+func IPVersion(s string) (int, error) {
+	switch strings.TrimSpace(s) {
+	case "", "0", "auto":
+		return 0, nil
+	case "4":
+		return 4, nil
+	case "6":
+		return 6, nil
+	}
+	return 0, fmt.Errorf("%q must be 4 or 6", s)
+}
+
+// Source checks that s (if set) is an IP address or the name of a local
+// network interface
+func Source(s string) error {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return nil
+	}
+	if _, err := netip.ParseAddr(s); err == nil {
+		return nil
+	}
+	if _, err := net.InterfaceByName(s); err != nil {
+		return fmt.Errorf("%q is not an IP address or local interface", s)
+	}
+	return nil
+}
+
+// ExitStatus parses s as an expected process exit status, defaulting to 0 when empty
+func ExitStatus(s string) (int, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return 0, nil
+	}
+	n, err := strconv.Atoi(s)
+	if err != nil {
+		return 0, fmt.Errorf("%q is not a number", s)
+	}
+	if n < 0 || n > 255 {
+		return 0, fmt.Errorf("must be between 0 and 255")
+	}
+	return n, nil
+}
+
+// Regexp checks that s (if set) is a valid regular expression
+func Regexp(s string) error {
+	if s == "" {
+		return nil
+	}
+	if _, err := regexp.Compile(s); err != nil {
+		return fmt.Errorf("is not a valid pattern: %v", err)
+	}
+	return nil
+}
+
+// StatusCode parses s as an expected HTTP status code, defaulting to 200 when empty
+func StatusCode(s string) (int, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return 200, nil
+	}
+	code, err := strconv.Atoi(s)
+	if err != nil {
+		return 0, fmt.Errorf("%q is not a number", s)
+	}
+	if code < 100 || code > 599 {
+		return 0, fmt.Errorf("must be between 100 and 599")
+	}
+	return code, nil
+}
+
+// CheckID checks that id (if set) only uses letters, digits, '-', '_' and '.'
+func CheckID(id string) error {
+	if id == "" {
+		return nil
+	}
+	if len(id) > maxIDLen {
+		return fmt.Errorf("must be at most %d characters", maxIDLen)
+	}
+	for _, r := range id {
+		if !isAlnum(r) && r != '-' && r != '_' && r != '.' {
+			return fmt.Errorf("%q may only contain letters, digits, '-', '_' and '.'", id)
+		}
+	}
+	return nil
+}
+
+// Tags splits a comma-separated tag list, dropping blanks and duplicates.
+// Tags follow the same rules as check IDs.
+func Tags(s string) ([]string, error) {
+	var tags []string
+	seen := make(map[string]bool)
+	for _, tag := range strings.Split(s, ",") {
+		tag = strings.TrimSpace(tag)
+		if tag == "" || seen[tag] {
+			continue
+		}
+		if err := CheckID(tag); err != nil {
+			return nil, err
+		}
+		seen[tag] = true
+		tags = append(tags, tag)
+	}
+	return tags, nil
+}
+
+// PhoneNumber checks that s is an E.164 number such as +14155550100
+func PhoneNumber(s string) error {
+	if len(s) < 8 || len(s) > 16 || s[0] != '+' || s[1] == '0' {
+		return fmt.Errorf("%q must be in international format, e.g. +14155550100", s)
+	}
+	for _, r := range s[1:] {
+		if r < '0' || r > '9' {
+			return fmt.Errorf("%q must be in international format, e.g. +14155550100", s)
+		}
+	}
+	return nil
+}
+
+// PhoneNumbers splits a comma-separated list of E.164 numbers, dropping
+// blanks and duplicates
+func PhoneNumbers(s string) ([]string, error) {
+	var numbers []string
+	for _, n := range strings.Split(s, ",") {
+		n = strings.TrimSpace(n)
+		if n == "" || slices.Contains(numbers, n) {
+			continue
+		}
+		if err := PhoneNumber(n); err != nil {
+			return nil, err
+		}
+		numbers = append(numbers, n)
+	}
+	return numbers, nil
+}
+
+// Name checks that a host name is present and printable
+func Name(s string) error {
+	if strings.TrimSpace(s) == "" {
+		return fmt.Errorf("is required")
+	}
+	for _, r := range s {
+		if r < 0x20 || r == 0x7f {
+			return fmt.Errorf("must not contain control characters")
+		}
+	}
+	return nil
+}
+
+// DisplayName checks that a check's display name (if set) is printable and
+// short enough for a card
+func DisplayName(s string) error {
+	if len([]rune(s)) > maxNameLen {
+		return fmt.Errorf("must be at most %d characters", maxNameLen)
+	}
+	for _, r := range s {
+		if r < 0x20 || r == 0x7f {
+			return fmt.Errorf("must not contain control characters")
+		}
+	}
+	return nil
+}
+
+// UserAgent checks that a User-Agent (if set) is printable ASCII, as HTTP
+// headers must be
+func UserAgent(s string) error {
+	for _, r := range s {
+		if r < 0x20 || r >= 0x7f {
+			return fmt.Errorf("must be printable ASCII")
+		}
+	}
+	return nil
+}
+
+// HeaderName checks that s (if set) is a valid HTTP header name, e.g. "X-Probe-ID"
+func HeaderName(s string) error {
+	for _, r := range s {
+		if !isAlnum(r) && !strings.ContainsRune("!#$%&'*+-.^_`|~", r) {
+			return fmt.Errorf("%q is not a valid header name, e.g. X-Probe-ID", s)
+		}
+	}
+	return nil
+}
+
+func isAlnum(r rune) bool {
+	return (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9')
+}
@@ -0,0 +1,361 @@
+package validate
+
+import (
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+// checkErr fails t unless err matches wantErr: nil when wantErr is empty,
+// otherwise an error mentioning it
+func checkErr(t *testing.T, call string, err error, wantErr string) {
+	t.Helper()
+	if wantErr == "" {
+		if err != nil {
+			t.Errorf("%s = %v, want no error", call, err)
+		}
+		return
+	}
+	if err == nil || !strings.Contains(err.Error(), wantErr) {
+		t.Errorf("%s = %v, want an error mentioning %q", call, err, wantErr)
+	}
+}
+
+func TestAddress(t *testing.T) {
+	tests := []struct {
+		in      string
+		wantErr string
+	}{
+		{"192.168.1.1", ""},
+		{"2001:db8::1", ""},
+		{"fe80::1%eth0", ""},
+		{"router", ""},
+		{"nas.lan", ""},
+		{"nas.lan.", ""},
+		{"my_host.example.com", ""},
+		{" 10.0.0.1 ", ""},
+		{"", "is required"},
+		{"   ", "is required"},
+		{"-router", "not a valid hostname"},
+		{"router-", "not a valid hostname"},
+		{"nas..lan", "not a valid hostname"},
+		{"nas lan", "not a valid hostname"},
+		{"http://nas.lan", "not a valid hostname"},
+		{"nas.lan:8080", "not a valid hostname"},
+		{"192.168.1.1/24", "not a valid hostname"},
+		{strings.Repeat("a", 64) + ".lan", "not a valid hostname"},
+		{strings.Repeat("abcdefgh.", 29) + "lan", "not a valid hostname"},
+	}
+	for _, tt := range tests {
+		checkErr(t, "Address("+tt.in+")", Address(tt.in), tt.wantErr)
+	}
+}
+
+func TestURLs(t *testing.T) {
+	tests := []struct {
+		name    string
+		fn      func(string) error
+		in      string
+		wantErr string
+	}{
+		{"URL", URL, "https://example.com/health", ""},
+		{"URL", URL, "http://192.168.1.10:8080", ""},
+		{"URL", URL, "http://[2001:db8::1]/", ""},
+		{"URL", URL, "", "is required"},
+		{"URL", URL, "example.com", "must start with http"},
+		{"URL", URL, "ftp://example.com", "must start with http"},
+		{"URL", URL, "https://", "has no host"},
+		{"URL", URL, "https://exa mple.com/%zz", "not a valid URL"},
+		{"OptionalURL", OptionalURL, "", ""},
+		{"OptionalURL", OptionalURL, "https://example.com", ""},
+		{"OptionalURL", OptionalURL, "example.com", "must start with http"},
+		{"WebSocketURL", WebSocketURL, "wss://example.com/socket", ""},
+		{"WebSocketURL", WebSocketURL, "https://example.com/socket", "must start with ws"},
+		{"WebSocketURL", WebSocketURL, "ws://", "has no host"},
+		{"PrinterURL", PrinterURL, "ipp://printer.lan/ipp/print", ""},
+		{"PrinterURL", PrinterURL, "https://printer.lan:631/ipp", ""},
+		{"PrinterURL", PrinterURL, "lpd://printer.lan", "must start with ipp"},
+		{"ProxyURL", ProxyURL, "", ""},
+		{"ProxyURL", ProxyURL, "socks5://127.0.0.1:1080", ""},
+		{"ProxyURL", ProxyURL, "ftp://proxy.lan", "must start with http"},
+		{"ProxyURL", ProxyURL, "http://", "has no host"},
+	}
+	for _, tt := range tests {
+		checkErr(t, tt.name+"("+tt.in+")", tt.fn(tt.in), tt.wantErr)
+	}
+}
+
+func TestHealthchecksURL(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    string
+		wantErr string
+	}{
+		{"", "", ""},
+		{"https://hc-ping.com/0f3c4d2e-8a1b-4c5d-9e6f-7a8b9c0d1e2f", "https://hc-ping.com/0f3c4d2e-8a1b-4c5d-9e6f-7a8b9c0d1e2f", ""},
+		{"https://hc-ping.com/0f3c4d2e-8a1b-4c5d-9e6f-7a8b9c0d1e2f/fail", "https://hc-ping.com/0f3c4d2e-8a1b-4c5d-9e6f-7a8b9c0d1e2f", ""},
+		{"https://hc-ping.com/0f3c4d2e-8a1b-4c5d-9e6f-7a8b9c0d1e2f/", "https://hc-ping.com/0f3c4d2e-8a1b-4c5d-9e6f-7a8b9c0d1e2f", ""},
+		{"https://hc-ping.com/abcdefghijklmnopqrstuv/nightly-backup/1", "https://hc-ping.com/abcdefghijklmnopqrstuv/nightly-backup", ""},
+		{"https://hc.example.com/ping/my-check/start", "https://hc.example.com/ping/my-check", ""},
+		{"https://hc-ping.com/not-a-uuid", "", "should be https://hc-ping.com/<uuid>"},
+		{"https://hc-ping.com/abcdefghijklmnopqrstuv/Bad_Slug", "", "should be https://hc-ping.com/<uuid>"},
+		{"https://hc.example.com/", "", "has no check UUID"},
+		{"hc-ping.com/0f3c4d2e-8a1b-4c5d-9e6f-7a8b9c0d1e2f", "", "must start with http"},
+	}
+	for _, tt := range tests {
+		got, err := HealthchecksURL(tt.in)
+		checkErr(t, "HealthchecksURL("+tt.in+")", err, tt.wantErr)
+		if got != tt.want {
+			t.Errorf("HealthchecksURL(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestPort(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    int
+		wantErr string
+	}{
+		{"1", 1, ""},
+		{"443", 443, ""},
+		{" 8080 ", 8080, ""},
+		{"65535", 65535, ""},
+		{"", 0, "is required"},
+		{"0", 0, "between 1 and 65535"},
+		{"65536", 0, "between 1 and 65535"},
+		{"-22", 0, "between 1 and 65535"},
+		{"http", 0, "not a number"},
+		{"22.5", 0, "not a number"},
+	}
+	for _, tt := range tests {
+		got, err := Port(tt.in)
+		checkErr(t, "Port("+tt.in+")", err, tt.wantErr)
+		if got != tt.want {
+			t.Errorf("Port(%q) = %d, want %d", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestPorts(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    []int
+		wantErr string
+	}{
+		{"", nil, ""},
+		{"22, 80,443", []int{22, 80, 443}, ""},
+		{"443 22 22", []int{22, 443}, ""},
+		{"8000-8003,8001", []int{8000, 8001, 8002, 8003}, ""},
+		{"1-1024", nil, ""},
+		{"1-1025", nil, "at most 1024 ports"},
+		{"80-22", nil, "from low to high"},
+		{"22,ssh", nil, `"ssh": port`},
+		{"0", nil, "between 1 and 65535"},
+		{"8000-70000", nil, "between 1 and 65535"},
+	}
+	for _, tt := range tests {
+		got, err := Ports(tt.in)
+		checkErr(t, "Ports("+tt.in+")", err, tt.wantErr)
+		if tt.want != nil && !slices.Equal(got, tt.want) {
+			t.Errorf("Ports(%q) = %v, want %v", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestCheckID(t *testing.T) {
+	tests := []struct {
+		in      string
+		wantErr string
+	}{
+		{"", ""},
+		{"web", ""},
+		{"api-v2.health_check", ""},
+		{strings.Repeat("a", 64), ""},
+		{strings.Repeat("a", 65), "at most 64 characters"},
+		{"web site", "may only contain"},
+		{"web/site", "may only contain"},
+		{"wéb", "may only contain"},
+		{"<script>", "may only contain"},
+	}
+	for _, tt := range tests {
+		checkErr(t, "CheckID("+tt.in+")", CheckID(tt.in), tt.wantErr)
+	}
+}
+
+func TestTags(t *testing.T) {
+	got, err := Tags(" office, media,,office , lab ")
+	if err != nil || !slices.Equal(got, []string{"office", "media", "lab"}) {
+		t.Errorf("Tags = %v, %v; want office, media, lab", got, err)
+	}
+	if _, err := Tags("office, front door"); err == nil {
+		t.Error("Tags with a space in a tag succeeded")
+	}
+}
+
+func TestNames(t *testing.T) {
+	tests := []struct {
+		name    string
+		fn      func(string) error
+		in      string
+		wantErr string
+	}{
+		{"Name", Name, "Living room NAS", ""},
+		{"Name", Name, "", "is required"},
+		{"Name", Name, "  ", "is required"},
+		{"Name", Name, "nas\nlan", "control characters"},
+		{"DisplayName", DisplayName, "", ""},
+		{"DisplayName", DisplayName, "Plex web UI ✓", ""},
+		{"DisplayName", DisplayName, strings.Repeat("é", 100), ""},
+		{"DisplayName", DisplayName, strings.Repeat("a", 101), "at most 100 characters"},
+		{"DisplayName", DisplayName, "tab\there", "control characters"},
+		{"UserAgent", UserAgent, "poke443/1.0", ""},
+		{"UserAgent", UserAgent, "poke443 ✓", "printable ASCII"},
+		{"HeaderName", HeaderName, "X-Probe-ID", ""},
+		{"HeaderName", HeaderName, "X Probe", "not a valid header name"},
+		{"HeaderName", HeaderName, "X-Probe:", "not a valid header name"},
+	}
+	for _, tt := range tests {
+		checkErr(t, tt.name+"("+tt.in+")", tt.fn(tt.in), tt.wantErr)
+	}
+}
+
+func TestNumbers(t *testing.T) {
+	tests := []struct {
+		name    string
+		fn      func(string) (int, error)
+		in      string
+		want    int
+		wantErr string
+	}{
+		{"StatusCode", StatusCode, "", 200, ""},
+		{"StatusCode", StatusCode, "204", 204, ""},
+		{"StatusCode", StatusCode, "99", 0, "between 100 and 599"},
+		{"StatusCode", StatusCode, "600", 0, "between 100 and 599"},
+		{"StatusCode", StatusCode, "ok", 0, "not a number"},
+		{"ExitStatus", ExitStatus, "", 0, ""},
+		{"ExitStatus", ExitStatus, "255", 255, ""},
+		{"ExitStatus", ExitStatus, "256", 0, "between 0 and 255"},
+		{"MaxRedirects", MaxRedirects, "", 0, ""},
+		{"MaxRedirects", MaxRedirects, "50", 50, ""},
+		{"MaxRedirects", MaxRedirects, "51", 0, "between 0 and 50"},
+		{"IPVersion", IPVersion, "auto", 0, ""},
+		{"IPVersion", IPVersion, "6", 6, ""},
+		{"IPVersion", IPVersion, "5", 0, "must be 4 or 6"},
+		{"MaxUsage", MaxUsage, "", 0, ""},
+		{"MaxUsage", MaxUsage, "100", 100, ""},
+		{"MaxUsage", MaxUsage, "0", 0, "between 1 and 100"},
+		{"MinCharge", MinCharge, "101", 0, "between 1 and 100"},
+		{"WarnDays", WarnDays, "30", 30, ""},
+		{"WarnDays", WarnDays, "366", 0, "between 1 and 365"},
+	}
+	for _, tt := range tests {
+		got, err := tt.fn(tt.in)
+		checkErr(t, tt.name+"("+tt.in+")", err, tt.wantErr)
+		if got != tt.want {
+			t.Errorf("%s(%q) = %d, want %d", tt.name, tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestSpeed(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    int64
+		wantErr string
+	}{
+		{"", 0, ""},
+		{"50Mbps", 50_000_000, ""},
+		{"50 Mbit/s", 50_000_000, ""},
+		{"1.5Gbps", 1_500_000_000, ""},
+		{"800kbps", 800_000, ""},
+		{"12MB/s", 0, "is in bytes"},
+		{"50", 0, "should be a speed"},
+		{"fast", 0, "should be a speed"},
+		{"0Mbps", 0, "should be a speed"},
+		{"2000Gbps", 0, "should be a speed"},
+	}
+	for _, tt := range tests {
+		got, err := Speed(tt.in)
+		checkErr(t, "Speed("+tt.in+")", err, tt.wantErr)
+		if got != tt.want {
+			t.Errorf("Speed(%q) = %d, want %d", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestSpeedtestInterval(t *testing.T) {
+	if d, err := SpeedtestInterval("2h"); err != nil || d != 2*time.Hour {
+		t.Errorf("SpeedtestInterval(2h) = %v, %v", d, err)
+	}
+	checkErr(t, "SpeedtestInterval(1m)", func() error { _, err := SpeedtestInterval("1m"); return err }(), "at least 5m")
+	checkErr(t, "SpeedtestInterval(hourly)", func() error { _, err := SpeedtestInterval("hourly"); return err }(), "not a duration")
+}
+
+func TestDomainsAndLists(t *testing.T) {
+	checkErr(t, "Domain(example.com)", Domain("example.com"), "")
+	checkErr(t, "Domain()", Domain(""), "")
+	checkErr(t, "Domain(localhost)", Domain("localhost"), "not a domain name")
+	checkErr(t, "Domain(192.0.2.1)", Domain("192.0.2.1"), "is an IP address")
+
+	lists, err := Blocklists("Zen.Spamhaus.org., bl.spamcop.net zen.spamhaus.org")
+	if err != nil || !slices.Equal(lists, []string{"zen.spamhaus.org", "bl.spamcop.net"}) {
+		t.Errorf("Blocklists = %v, %v", lists, err)
+	}
+	_, err = Blocklists("zen.spamhaus.org, spamhaus")
+	checkErr(t, "Blocklists(spamhaus)", err, "not a blocklist zone")
+}
+
+func TestPhoneNumbers(t *testing.T) {
+	got, err := PhoneNumbers("+14155550100, +447700900123,+14155550100")
+	if err != nil || !slices.Equal(got, []string{"+14155550100", "+447700900123"}) {
+		t.Errorf("PhoneNumbers = %v, %v", got, err)
+	}
+	for _, bad := range []string{"4155550100", "+04155550100", "+1 415 555 0100", "+1234"} {
+		checkErr(t, "PhoneNumber("+bad+")", PhoneNumber(bad), "international format")
+	}
+}
+
+func TestOptionalNames(t *testing.T) {
+	tests := []struct {
+		name    string
+		fn      func(string) error
+		in      string
+		wantErr string
+	}{
+		{"ShareName", ShareName, "backups", ""},
+		{"ShareName", ShareName, `\\nas\backups`, "just the share's name"},
+		{"KafkaTopic", KafkaTopic, "orders.v1", ""},
+		{"KafkaTopic", KafkaTopic, "..", "not a valid topic name"},
+		{"KafkaTopic", KafkaTopic, "orders v1", "may only contain"},
+		{"Deployment", Deployment, "web/frontend", ""},
+		{"Deployment", Deployment, "frontend", ""},
+		{"Deployment", Deployment, "Web/Frontend", "namespace/name"},
+		{"ProxmoxToken", ProxmoxToken, "monitor@pve!poke443=abc", ""},
+		{"ProxmoxToken", ProxmoxToken, "monitor@pve=abc", "user@realm!tokenid=secret"},
+		{"UPSDaemon", UPSDaemon, "apcupsd", ""},
+		{"UPSDaemon", UPSDaemon, "upsd", "must be nut or apcupsd"},
+		{"UPSName", UPSName, "ups;rm", "may only contain"},
+		{"Regexp", Regexp, "^ok$", ""},
+		{"Regexp", Regexp, "(", "not a valid pattern"},
+		{"Source", Source, "192.0.2.1", ""},
+		{"Source", Source, "no-such-interface0", "not an IP address or local interface"},
+	}
+	for _, tt := range tests {
+		checkErr(t, tt.name+"("+tt.in+")", tt.fn(tt.in), tt.wantErr)
+	}
+}
+
+func TestErrors(t *testing.T) {
+	var errs Errors
+	errs.Check("Address", Address("192.168.1.1"))
+	if errs.Any() {
+		t.Fatalf("Errors after a valid field = %v", errs)
+	}
+	errs.Check("Address", Address(""))
+	errs.Add("Check 2 port", "must be between %d and %d", 1, 65535)
+	if want := "Address: is required; Check 2 port: must be between 1 and 65535"; errs.Error() != want {
+		t.Errorf("Error() = %q, want %q", errs.Error(), want)
+	}
+}